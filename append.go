@@ -0,0 +1,138 @@
+package cbor
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// appendHeader appends the header of a CBOR item with the given major type
+// and argument to dst, using the shortest possible encoding of the argument.
+//
+// https://www.rfc-editor.org/rfc/rfc8949.html#section-3
+func appendHeader(dst []byte, mt MajorType, n uint64) []byte {
+	b := byte(mt) << 5
+	switch {
+	case n <= 23:
+		return append(dst, b|byte(n))
+	case n <= math.MaxUint8:
+		return append(dst, b|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(dst, b|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return append(dst, b|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	default:
+		return append(dst, b|27, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32), byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+}
+
+// AppendHeader appends the header of a CBOR item with the given major type
+// and argument to dst, using the shortest encoding of the argument. The
+// argument is the value of an integer, the length of a string, the number
+// of elements of an array or pairs of a map, or the number of a tag.
+//
+// For MajorTypeSimple, the argument is written as for the other types,
+// which encodes simple values, but not floats; use AppendFloat32 and
+// AppendFloat64 for them.
+func AppendHeader(dst []byte, mt MajorType, arg uint64) []byte {
+	return appendHeader(dst, mt, arg)
+}
+
+// AppendIndefiniteHeader appends the header of an indefinite-length item
+// of the given major type to dst, which must be MajorTypeByteString,
+// MajorTypeTextString, MajorTypeArray or MajorTypeMap. The caller must
+// append the chunks, elements or pairs of the item, and then AppendBreak.
+func AppendIndefiniteHeader(dst []byte, mt MajorType) []byte {
+	return append(dst, byte(mt)<<5|31)
+}
+
+// AppendBreak appends the break code ending an indefinite-length item to
+// dst.
+func AppendBreak(dst []byte) []byte {
+	return append(dst, 0xff)
+}
+
+// HeaderLen returns the length in bytes of the header appended by
+// AppendHeader for the argument arg, which is 1, 2, 3, 5 or 9 bytes.
+func HeaderLen(arg uint64) int {
+	switch {
+	case arg <= 23:
+		return 1
+	case arg <= math.MaxUint8:
+		return 2
+	case arg <= math.MaxUint16:
+		return 3
+	case arg <= math.MaxUint32:
+		return 5
+	default:
+		return 9
+	}
+}
+
+// AppendUint appends the CBOR encoding of an unsigned integer to dst.
+func AppendUint(dst []byte, v uint64) []byte {
+	return appendHeader(dst, MajorTypeUnsignedInt, v)
+}
+
+// AppendInt appends the CBOR encoding of a signed integer to dst.
+func AppendInt(dst []byte, v int64) []byte {
+	if v < 0 {
+		return appendHeader(dst, MajorTypeNegativeInt, uint64(-1-v))
+	}
+	return appendHeader(dst, MajorTypeUnsignedInt, uint64(v))
+}
+
+// AppendBool appends the CBOR encoding of a boolean to dst.
+func AppendBool(dst []byte, v bool) []byte {
+	if v {
+		return append(dst, 0xf5)
+	}
+	return append(dst, 0xf4)
+}
+
+// AppendNull appends the CBOR encoding of null to dst.
+func AppendNull(dst []byte) []byte {
+	return append(dst, 0xf6)
+}
+
+// AppendFloat32 appends the CBOR encoding of a 32-bit float to dst.
+func AppendFloat32(dst []byte, v float32) []byte {
+	dst = append(dst, 0xfa)
+	return binary.BigEndian.AppendUint32(dst, math.Float32bits(v))
+}
+
+// AppendFloat64 appends the CBOR encoding of a 64-bit float to dst.
+func AppendFloat64(dst []byte, v float64) []byte {
+	dst = append(dst, 0xfb)
+	return binary.BigEndian.AppendUint64(dst, math.Float64bits(v))
+}
+
+// AppendString appends the CBOR encoding of a text string to dst.
+func AppendString(dst []byte, s string) []byte {
+	dst = appendHeader(dst, MajorTypeTextString, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// AppendBytes appends the CBOR encoding of a byte string to dst.
+func AppendBytes(dst []byte, b []byte) []byte {
+	dst = appendHeader(dst, MajorTypeByteString, uint64(len(b)))
+	return append(dst, b...)
+}
+
+// AppendArrayHeader appends the header of an array with n elements to dst.
+// The caller must append exactly n items after it.
+func AppendArrayHeader(dst []byte, n int) []byte {
+	return appendHeader(dst, MajorTypeArray, uint64(n))
+}
+
+// AppendMapHeader appends the header of a map with n key/value pairs to dst.
+// The caller must append exactly 2*n items after it, alternating keys and
+// values.
+func AppendMapHeader(dst []byte, n int) []byte {
+	return appendHeader(dst, MajorTypeMap, uint64(n))
+}
+
+// AppendTag appends the header of a tag with the given number to dst. The
+// caller must append exactly one item after it, the tag content.
+func AppendTag(dst []byte, tag uint64) []byte {
+	return appendHeader(dst, MajorTypeTag, tag)
+}
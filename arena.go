@@ -0,0 +1,142 @@
+package cbor
+
+// defaultArenaChunkSize is the size of the chunks of an Arena with no
+// ChunkSize.
+const defaultArenaChunkSize = 64 << 10
+
+// Arena allocates the text strings, byte strings, and arrays decoded into
+// interface values by a Decoder out of a few large chunks, rather than
+// allocating each of them separately. It is useful to decode large
+// documents in the scope of a request, since the chunks are then released
+// together when the arena is reset or no longer referenced.
+//
+// Decoded values share the memory of the arena, so they must not be used
+// after the arena is reset. Items larger than a quarter of a chunk are
+// allocated separately.
+//
+// The zero value is ready to use. An Arena is not safe to be used by
+// multiple goroutines.
+type Arena struct {
+	// ChunkSize is the size in bytes of the chunks of the arena. If it is
+	// zero, 64 KiB chunks are used.
+	ChunkSize int
+
+	// chunks are the byte chunks allocated by the arena, and cur is the
+	// index of the chunk being carved.
+	chunks [][]byte
+	cur    int
+
+	// ifaces are the chunks of interface values, and icur is the index of
+	// the chunk being carved.
+	ifaces [][]interface{}
+	icur   int
+}
+
+// SetArena sets the arena the decoder allocates decoded strings and arrays
+// from, or stops using an arena if a is nil.
+func (dec *Decoder) SetArena(a *Arena) {
+	dec.arena = a
+}
+
+// Reset releases the memory of all the values allocated by the arena,
+// which is reused for the values allocated afterwards.
+func (a *Arena) Reset() {
+	for i := range a.chunks {
+		a.chunks[i] = a.chunks[i][:0]
+	}
+	for i, chunk := range a.ifaces {
+		// Clear the values so the arena doesn't keep them alive.
+		for j := range chunk {
+			chunk[j] = nil
+		}
+		a.ifaces[i] = chunk[:0]
+	}
+	a.cur, a.icur = 0, 0
+}
+
+// chunkSize returns the size of the chunks of the arena.
+func (a *Arena) chunkSize() int {
+	if a.ChunkSize <= 0 {
+		return defaultArenaChunkSize
+	}
+	return a.ChunkSize
+}
+
+// bytes returns a slice of n bytes with a capacity of n.
+func (a *Arena) bytes(n int) []byte {
+	size := a.chunkSize()
+	if n > size/4 {
+		return make([]byte, n)
+	}
+
+	for ; a.cur < len(a.chunks); a.cur++ {
+		if chunk := a.chunks[a.cur]; cap(chunk)-len(chunk) >= n {
+			return a.carve(n)
+		}
+	}
+	a.chunks = append(a.chunks, make([]byte, 0, size))
+	return a.carve(n)
+}
+
+// carve returns the next n bytes of the current chunk.
+func (a *Arena) carve(n int) []byte {
+	chunk := a.chunks[a.cur]
+	start := len(chunk)
+	chunk = chunk[:start+n]
+	a.chunks[a.cur] = chunk
+	return chunk[start : start+n : start+n]
+}
+
+// interfaces returns an empty slice of interface values with a capacity
+// of n.
+func (a *Arena) interfaces(n int) []interface{} {
+	// Interface values are 16 bytes.
+	size := a.chunkSize() / 16
+	if n > size/4 {
+		return make([]interface{}, 0, n)
+	}
+
+	for ; a.icur < len(a.ifaces); a.icur++ {
+		if chunk := a.ifaces[a.icur]; cap(chunk)-len(chunk) >= n {
+			break
+		}
+	}
+	if a.icur == len(a.ifaces) {
+		a.ifaces = append(a.ifaces, make([]interface{}, 0, size))
+	}
+
+	chunk := a.ifaces[a.icur]
+	start := len(chunk)
+	chunk = chunk[:start+n]
+	a.ifaces[a.icur] = chunk
+	return chunk[start : start : start+n]
+}
+
+// makeBytes returns a new slice of n bytes, from the decoder's arena if it
+// has one.
+func (dec *Decoder) makeBytes(n int) []byte {
+	if dec.arena != nil {
+		return dec.arena.bytes(n)
+	}
+	return make([]byte, n)
+}
+
+// makeString returns a string with the content of b, from the decoder's
+// arena if it has one.
+func (dec *Decoder) makeString(b []byte) string {
+	if dec.arena != nil && len(b) > 0 {
+		buf := dec.arena.bytes(len(b))
+		copy(buf, b)
+		return bytesToString(buf)
+	}
+	return string(b)
+}
+
+// makeInterfaces returns an empty slice of interface values with a
+// capacity of n, from the decoder's arena if it has one.
+func (dec *Decoder) makeInterfaces(n int) []interface{} {
+	if dec.arena != nil {
+		return dec.arena.interfaces(n)
+	}
+	return make([]interface{}, 0, n)
+}
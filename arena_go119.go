@@ -0,0 +1,9 @@
+//go:build !go1.20
+
+package cbor
+
+// bytesToString returns a string with the content of b. Before Go 1.20,
+// strings can't share the memory of an Arena, so they are copied.
+func bytesToString(b []byte) string {
+	return string(b)
+}
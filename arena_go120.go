@@ -0,0 +1,11 @@
+//go:build go1.20
+
+package cbor
+
+import "unsafe"
+
+// bytesToString returns a string sharing the memory of b, which must not
+// be modified while the string is in use.
+func bytesToString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
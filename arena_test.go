@@ -0,0 +1,59 @@
+package cbor_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestArena(t *testing.T) {
+	doc := map[string]interface{}{
+		"name":  "example",
+		"data":  []byte{1, 2, 3},
+		"items": []interface{}{"a", "b", []interface{}{"c"}},
+	}
+	data, err := cbor.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var arena cbor.Arena
+	decode := func(a *cbor.Arena) (v struct {
+		Name  string        `cbor:"name"`
+		Data  []byte        `cbor:"data"`
+		Items []interface{} `cbor:"items"`
+	}) {
+		dec := cbor.NewDecoder(bytes.NewReader(data))
+		dec.SetArena(a)
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	v := decode(&arena)
+	if v.Name != "example" || !bytes.Equal(v.Data, []byte{1, 2, 3}) {
+		t.Fatalf("unexpected %+v", v)
+	}
+	if want := []interface{}{"a", "b", []interface{}{"c"}}; !reflect.DeepEqual(v.Items, want) {
+		t.Fatalf("got %#v, want %#v", v.Items, want)
+	}
+
+	// Slices carved out of the arena can't be appended to in place.
+	if cap(v.Data) != len(v.Data) {
+		t.Fatalf("expected a capacity of %d, got %d", len(v.Data), cap(v.Data))
+	}
+
+	// Values decoded after the arena is reset reuse its memory, so they
+	// need fewer allocations.
+	withArena := testing.AllocsPerRun(10, func() {
+		arena.Reset()
+		decode(&arena)
+	})
+	withoutArena := testing.AllocsPerRun(10, func() { decode(nil) })
+	if withArena >= withoutArena {
+		t.Fatalf("expected fewer than %v allocations with an arena, got %v", withoutArena, withArena)
+	}
+}
@@ -0,0 +1,110 @@
+package cbor
+
+import (
+	"math/big"
+	"reflect"
+)
+
+// BigIntMode controls how big.Int values are encoded.
+type BigIntMode int
+
+const (
+	// BigIntTagged encodes big.Int values as bignums, whatever their
+	// value.
+	BigIntTagged BigIntMode = iota
+
+	// BigIntShrink encodes big.Int values which fit in 64 bits as plain
+	// integers, following the preferred serialization of RFC 8949, and
+	// larger values as bignums.
+	BigIntShrink
+)
+
+// bigIntType is the reflect.Type of big.Int.
+var bigIntType = reflect.TypeOf(big.Int{})
+
+// encodeBigInt writes a big.Int according to the BigIntMode of the
+// encoder.
+func encodeBigInt(e *Encoder, v reflect.Value) error {
+	var x *big.Int
+	if v.CanAddr() {
+		x = v.Addr().Interface().(*big.Int)
+	} else {
+		x = ptrTo(v.Interface().(big.Int))
+	}
+	return e.writeBigInt(x, e.options.BigIntMode == BigIntShrink)
+}
+
+// writeBigInt writes x as a bignum, or as a plain integer if shrink is set
+// and it fits in 64 bits.
+func (e *Encoder) writeBigInt(x *big.Int, shrink bool) error {
+	mt, tag, n := MajorTypeUnsignedInt, TagPositiveBignum, x
+	if x.Sign() < 0 {
+		// Negative integers are encoded as -1-x.
+		mt, tag = MajorTypeNegativeInt, TagNegativeBignum
+		n = new(big.Int).Not(x)
+	}
+
+	if shrink && n.IsUint64() {
+		return e.writeHeader(mt, n.Uint64())
+	}
+	e.buf = AppendTag(e.buf, uint64(tag))
+	return e.writeBytes(n.Bytes())
+}
+
+// ptrTo returns a pointer to a copy of x, so values of types like big.Int,
+// whose methods have pointer receivers, can be read when they aren't
+// addressable.
+func ptrTo[T any](x T) *T {
+	return &x
+}
+
+// decodeBigInt decodes an integer or a bignum (RFC 8949, section 3.4.3)
+// into a big.Int.
+func decodeBigInt(dec *Decoder, rv reflect.Value, b byte) error {
+	x := rv.Addr().Interface().(*big.Int)
+	switch MajorType(b >> 5) {
+	case MajorTypeUnsignedInt, MajorTypeNegativeInt:
+		n, err := dec.readArgument(b & 0x1f)
+		if err != nil {
+			return err
+		}
+		x.SetUint64(n)
+		if MajorType(b>>5) == MajorTypeNegativeInt {
+			x.Not(x)
+		}
+		return nil
+	case MajorTypeTag:
+		tag, err := dec.readArgument(b & 0x1f)
+		if err != nil {
+			return err
+		}
+		if Tag(tag) != TagPositiveBignum && Tag(tag) != TagNegativeBignum {
+			return typeError("tag "+itoa(int64(tag)), rv.Type())
+		}
+		return dec.decodeBignum(rv, Tag(tag) == TagNegativeBignum)
+	}
+	return dec.decodeItem(rv, b)
+}
+
+// decodeBignum decodes the byte string content of a bignum into rv, which
+// may be a big.Int, or an interface, which is set to a *big.Int.
+func (dec *Decoder) decodeBignum(rv reflect.Value, negative bool) error {
+	var magnitude []byte
+	if err := dec.decodeValue(reflect.ValueOf(&magnitude).Elem()); err != nil {
+		return err
+	}
+	x := new(big.Int).SetBytes(magnitude)
+	if negative {
+		x.Not(x)
+	}
+
+	switch {
+	case rv.Type() == bigIntType:
+		rv.Addr().Interface().(*big.Int).Set(x)
+	case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+		rv.Set(reflect.ValueOf(x))
+	default:
+		return typeError("bignum", rv.Type())
+	}
+	return nil
+}
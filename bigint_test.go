@@ -0,0 +1,89 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestBigInt(t *testing.T) {
+	parse := func(s string) *big.Int {
+		x, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			t.Fatalf("invalid integer %q", s)
+		}
+		return x
+	}
+
+	tests := []struct {
+		x      string
+		tagged string
+		shrunk string
+	}{
+		{"0", "c240", "00"},
+		{"1", "c24101", "01"},
+		{"-1", "c340", "20"},
+		{"18446744073709551615", "c248ffffffffffffffff", "1bffffffffffffffff"},
+		{"-18446744073709551616", "c348ffffffffffffffff", "3bffffffffffffffff"},
+		{"18446744073709551616", "c249010000000000000000", "c249010000000000000000"},
+		{"-18446744073709551617", "c349010000000000000000", "c349010000000000000000"},
+	}
+
+	for _, test := range tests {
+		for _, mode := range []cbor.BigIntMode{cbor.BigIntTagged, cbor.BigIntShrink} {
+			want := test.tagged
+			if mode == cbor.BigIntShrink {
+				want = test.shrunk
+			}
+
+			var buf bytes.Buffer
+			enc := cbor.NewEncoder(&buf)
+			enc.SetBigIntMode(mode)
+			if err := enc.Encode(parse(test.x)); err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != want {
+				t.Fatalf("%s in mode %d: expected %s, got %s", test.x, mode, want, got)
+			}
+
+			var got big.Int
+			if err := cbor.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != test.x {
+				t.Fatalf("%s: decoded %s", test.x, got.String())
+			}
+		}
+	}
+
+	// Values and pointers in structs, and bignums in interfaces.
+	type account struct {
+		Balance big.Int
+		Limit   *big.Int
+	}
+	v := account{Limit: parse("-5")}
+	v.Balance.SetInt64(7)
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got account
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Balance.Int64() != 7 || got.Limit.Int64() != -5 {
+		t.Fatalf("expected 7 and -5, got %v and %v", &got.Balance, got.Limit)
+	}
+
+	var i interface{}
+	if err := cbor.Unmarshal(data, &i); err != nil {
+		t.Fatal(err)
+	}
+	m := i.(map[interface{}]interface{})
+	if x, ok := m["Limit"].(*big.Int); !ok || x.Int64() != -5 {
+		t.Fatalf("expected a *big.Int of -5, got %#v", m["Limit"])
+	}
+}
@@ -0,0 +1,115 @@
+package cbor
+
+import "reflect"
+
+// ByteString is a byte string which can be used as a map key. Byte strings
+// which are map keys are decoded into interface values as ByteString,
+// since []byte isn't hashable. It's encoded as a byte string, rather than
+// a text string like other string types.
+type ByteString string
+
+// byteStringType is the reflect.Type of ByteString.
+var byteStringType = reflect.TypeOf(ByteString(""))
+
+// encodeByteString writes a ByteString as a byte string.
+func encodeByteString(e *Encoder, v reflect.Value) error {
+	s := v.String()
+	if err := e.writeHeader(MajorTypeByteString, uint64(len(s))); err != nil {
+		return err
+	}
+	if len(s) >= flushSize && e.w != nil {
+		return e.writeLarge([]byte(s))
+	}
+	e.buf = append(e.buf, s...)
+	return nil
+}
+
+// decodeByteString decodes a byte string into a ByteString. Text strings
+// are decoded as usual.
+func decodeByteString(dec *Decoder, rv reflect.Value, b byte) error {
+	if MajorType(b>>5) != MajorTypeByteString {
+		return dec.decodeItem(rv, b)
+	}
+	var buf []byte
+	if err := dec.decodeItem(reflect.ValueOf(&buf).Elem(), b); err != nil {
+		return err
+	}
+	rv.SetString(string(buf))
+	return nil
+}
+
+// ArrayKey is an array which can be used as a map key. Arrays which are
+// map keys are decoded into interface values as ArrayKey, since slices
+// aren't hashable. It holds the deterministic encoding of the array, so
+// arrays which are Equal are the same key, and it's encoded as that array.
+type ArrayKey struct {
+	data string
+}
+
+// NewArrayKey returns the ArrayKey of the array of elems, such as to look
+// up a key of a decoded map.
+func NewArrayKey(elems ...interface{}) (ArrayKey, error) {
+	if elems == nil {
+		elems = []interface{}{}
+	}
+	data, err := Marshal(elems)
+	if err != nil {
+		return ArrayKey{}, err
+	}
+	data, err = appendDeterministic(nil, data)
+	if err != nil {
+		return ArrayKey{}, err
+	}
+	return ArrayKey{data: string(data)}, nil
+}
+
+// Elems decodes the elements of the array.
+func (k ArrayKey) Elems() ([]interface{}, error) {
+	elems := []interface{}{}
+	if k.data == "" {
+		return elems, nil
+	}
+	if err := Unmarshal([]byte(k.data), &elems); err != nil {
+		return nil, err
+	}
+	return elems, nil
+}
+
+// MarshalCBOR returns the encoding of the array.
+func (k ArrayKey) MarshalCBOR() ([]byte, error) {
+	if k.data == "" {
+		return []byte{0x80}, nil
+	}
+	return []byte(k.data), nil
+}
+
+// hashableKey returns the decoded map key v as a value which can be used
+// as a key of a map with interface keys: byte strings become ByteString,
+// arrays become ArrayKey, and the content of TagValue keys is converted
+// the same way. Maps can't be keys, and are an error.
+func hashableKey(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case []byte:
+		return ByteString(v), nil
+	case []interface{}:
+		elems := make([]interface{}, len(v))
+		for i, elem := range v {
+			k, err := hashableKey(elem)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = k
+		}
+		return NewArrayKey(elems...)
+	case TagValue:
+		content, err := hashableKey(v.Content)
+		if err != nil {
+			return nil, err
+		}
+		return TagValue{Number: v.Number, Content: content}, nil
+	}
+	if v != nil && !reflect.TypeOf(v).Comparable() {
+		return nil, newError(ErrInvalidType, "cbor: unhashable map key of type "+reflect.TypeOf(v).String())
+	}
+	return v, nil
+}
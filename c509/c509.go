@@ -0,0 +1,535 @@
+// Package c509 decodes CBOR-encoded X.509 certificates (C509) on top of the
+// cbor package, for public key infrastructures of constrained devices.
+//
+// C509 is being specified by the COSE working group of the IETF, in the
+// draft "CBOR Encoded X.509 Certificates (C509 Certificates)".
+//
+// https://datatracker.ietf.org/doc/draft-ietf-cose-cbor-encoded-cert/
+package c509
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+// Certificate types, the first item of a C509 certificate.
+const (
+	// TypeNative is a natively signed C509 certificate, whose signature is
+	// over the CBOR encoding of the certificate.
+	TypeNative = 2
+
+	// TypeReencoded is the CBOR re-encoding of a DER encoded X.509
+	// certificate, whose signature is over the DER encoding.
+	TypeReencoded = 3
+)
+
+// Tags used by C509 certificates.
+const (
+	// tagMAC is the CBOR tag for a MAC address, used for names which are
+	// EUI-64 identifiers.
+	tagMAC = 48
+)
+
+// noWellDefinedExpiration is the time used by X.509 certificates which
+// have no expiration, encoded as null in C509 certificates.
+var noWellDefinedExpiration = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// Certificate is a decoded C509 certificate.
+type Certificate struct {
+	// Raw is the complete CBOR encoding of the certificate.
+	Raw []byte
+
+	// RawTBSCertificate is the CBOR sequence of the items of the
+	// certificate before its signature, which the signature of a native
+	// certificate is computed over.
+	RawTBSCertificate []byte
+
+	// Type is the certificate type, TypeNative or TypeReencoded.
+	Type int64
+
+	// SerialNumber is the serial number of the certificate.
+	SerialNumber *big.Int
+
+	// SignatureAlgorithm is the algorithm used by the issuer to sign the
+	// certificate.
+	SignatureAlgorithm AlgorithmIdentifier
+
+	// Issuer is the name of the issuer, which is the same as Subject if
+	// it was encoded as null.
+	Issuer Name
+
+	// NotBefore and NotAfter are the validity period of the certificate,
+	// in UTC. A certificate without an expiration has a NotAfter of
+	// 9999-12-31 23:59:59, as in X.509.
+	NotBefore, NotAfter time.Time
+
+	// Subject is the name of the subject.
+	Subject Name
+
+	// PublicKeyAlgorithm is the algorithm of the subject public key.
+	PublicKeyAlgorithm AlgorithmIdentifier
+
+	// RawPublicKey is the CBOR encoding of the subject public key, whose
+	// format depends on PublicKeyAlgorithm.
+	RawPublicKey []byte
+
+	// Extensions are the extensions of the certificate.
+	Extensions []Extension
+
+	// Signature is the signature of the issuer. ECDSA signatures are the
+	// concatenation of r and s, as in COSE.
+	Signature []byte
+}
+
+// AlgorithmIdentifier identifies an algorithm, either by its value in the
+// C509 registries, or by its object identifier and optional parameters.
+type AlgorithmIdentifier struct {
+	// ID is the value of the algorithm in the C509 Signature Algorithms or
+	// Public Key Algorithms registry. It is only meaningful if OID is nil.
+	ID int64
+
+	// OID is the object identifier of an unregistered algorithm.
+	OID asn1.ObjectIdentifier
+
+	// Parameters are the DER encoded parameters of an unregistered
+	// algorithm, if any.
+	Parameters []byte
+}
+
+// Name is the issuer or subject of a certificate, as a sequence of
+// attributes, each of which is a relative distinguished name in X.509.
+type Name []Attribute
+
+// Attribute is an attribute of a Name.
+type Attribute struct {
+	// Type is the object identifier of the attribute type.
+	Type asn1.ObjectIdentifier
+
+	// Value is the value of the attribute. Values of registered attribute
+	// types are strings, while those of other types are whatever their DER
+	// encoding decodes to with encoding/asn1.
+	Value interface{}
+
+	// Printable reports whether a registered attribute is a
+	// PrintableString in X.509, rather than a UTF8String.
+	Printable bool
+}
+
+// String returns the common name, if the name only has one, or the
+// attributes of the name in the style of RFC 4514 otherwise, in the order
+// they were encoded.
+func (n Name) String() string {
+	if len(n) == 1 && n[0].Type.Equal(oidCommonName) {
+		if s, ok := n[0].Value.(string); ok {
+			return s
+		}
+	}
+	parts := make([]string, len(n))
+	for i, a := range n {
+		parts[i] = fmt.Sprintf("%s=%v", a.Type, a.Value)
+		if name, ok := attributeNames[a.Type.String()]; ok {
+			parts[i] = fmt.Sprintf("%s=%v", name, a.Value)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Extension is an extension of a certificate.
+type Extension struct {
+	// ID is the value of the extension in the C509 Extensions registry.
+	// It is only meaningful if OID is nil.
+	ID int64
+
+	// OID is the object identifier of an unregistered extension.
+	OID asn1.ObjectIdentifier
+
+	// Critical reports whether the extension must be understood.
+	Critical bool
+
+	// Value is the CBOR encoding of the value of a registered extension,
+	// or the DER encoded value of an unregistered one.
+	Value []byte
+}
+
+// Extension values in the C509 Extensions registry.
+const (
+	ExtensionSubjectKeyIdentifier   = 1
+	ExtensionKeyUsage               = 2
+	ExtensionSubjectAltName         = 3
+	ExtensionBasicConstraints       = 4
+	ExtensionAuthorityKeyIdentifier = 7
+	ExtensionExtKeyUsage            = 8
+)
+
+// Parse decodes a C509 certificate, which is a CBOR array of the items of
+// the certificate followed by its signature.
+func Parse(data []byte) (*Certificate, error) {
+	n, b, err := cbor.ReadArrayHeader(data)
+	if err != nil {
+		return nil, fmt.Errorf("c509: invalid certificate: %w", err)
+	}
+	if n != 11 {
+		return nil, fmt.Errorf("c509: invalid certificate: expected array of 11 items, got %d", n)
+	}
+	tbs := b
+
+	c := &Certificate{}
+	if c.Type, b, err = cbor.ReadInt(b); err != nil {
+		return nil, fmt.Errorf("c509: invalid certificate type: %w", err)
+	}
+	if c.Type != TypeNative && c.Type != TypeReencoded {
+		return nil, fmt.Errorf("c509: unsupported certificate type %d", c.Type)
+	}
+
+	serial, b, err := cbor.ReadBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("c509: invalid serial number: %w", err)
+	}
+	c.SerialNumber = new(big.Int).SetBytes(serial)
+
+	if c.SignatureAlgorithm, b, err = readAlgorithm(b); err != nil {
+		return nil, fmt.Errorf("c509: invalid signature algorithm: %w", err)
+	}
+
+	issuerNull := len(b) > 0 && b[0] == 0xf6
+	if issuerNull {
+		b = b[1:]
+	} else if c.Issuer, b, err = readName(b); err != nil {
+		return nil, fmt.Errorf("c509: invalid issuer: %w", err)
+	}
+
+	if c.NotBefore, b, err = readTime(b); err != nil {
+		return nil, fmt.Errorf("c509: invalid validity: %w", err)
+	}
+	if len(b) > 0 && b[0] == 0xf6 {
+		c.NotAfter, b = noWellDefinedExpiration, b[1:]
+	} else if c.NotAfter, b, err = readTime(b); err != nil {
+		return nil, fmt.Errorf("c509: invalid validity: %w", err)
+	}
+
+	if c.Subject, b, err = readName(b); err != nil {
+		return nil, fmt.Errorf("c509: invalid subject: %w", err)
+	}
+	if issuerNull {
+		c.Issuer = c.Subject
+	}
+
+	if c.PublicKeyAlgorithm, b, err = readAlgorithm(b); err != nil {
+		return nil, fmt.Errorf("c509: invalid public key algorithm: %w", err)
+	}
+	rest, err := cbor.Skip(b)
+	if err != nil {
+		return nil, fmt.Errorf("c509: invalid public key: %w", err)
+	}
+	c.RawPublicKey, b = b[:len(b)-len(rest)], rest
+
+	if c.Extensions, b, err = readExtensions(b); err != nil {
+		return nil, fmt.Errorf("c509: invalid extensions: %w", err)
+	}
+	c.RawTBSCertificate = tbs[:len(tbs)-len(b)]
+
+	if c.Signature, b, err = cbor.ReadBytes(b); err != nil {
+		return nil, fmt.Errorf("c509: invalid signature: %w", err)
+	}
+	if len(b) != 0 {
+		return nil, errors.New("c509: unexpected data after certificate")
+	}
+	c.Raw = data
+	return c, nil
+}
+
+// readAlgorithm reads an AlgorithmIdentifier from the start of b, which is
+// an integer, an unwrapped object identifier, or an array of an object
+// identifier and its parameters.
+func readAlgorithm(b []byte) (AlgorithmIdentifier, []byte, error) {
+	var alg AlgorithmIdentifier
+	mt, err := cbor.NextType(b)
+	if err != nil {
+		return alg, b, err
+	}
+
+	switch mt {
+	case cbor.MajorTypeUnsignedInt, cbor.MajorTypeNegativeInt:
+		alg.ID, b, err = cbor.ReadInt(b)
+	case cbor.MajorTypeByteString:
+		alg.OID, b, err = readOID(b)
+	default:
+		var n int
+		if n, b, err = cbor.ReadArrayHeader(b); err != nil {
+			return alg, b, err
+		}
+		if n != 2 {
+			return alg, b, errors.New("expected array of 2 items")
+		}
+		if alg.OID, b, err = readOID(b); err != nil {
+			return alg, b, err
+		}
+		alg.Parameters, b, err = cbor.ReadBytes(b)
+	}
+	return alg, b, err
+}
+
+// readOID reads an unwrapped object identifier from the start of b, which
+// is a byte string with the content of its DER encoding.
+func readOID(b []byte) (asn1.ObjectIdentifier, []byte, error) {
+	content, b, err := cbor.ReadBytes(b)
+	if err != nil {
+		return nil, b, err
+	}
+	der, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagOID, Bytes: content})
+	if err != nil {
+		return nil, b, err
+	}
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(der, &oid); err != nil {
+		return nil, b, fmt.Errorf("invalid object identifier %x", content)
+	}
+	return oid, b, nil
+}
+
+// readTime reads a time from the start of b, which is the number of
+// seconds since the Unix epoch.
+func readTime(b []byte) (time.Time, []byte, error) {
+	sec, b, err := cbor.ReadInt(b)
+	if err != nil {
+		return time.Time{}, b, err
+	}
+	return time.Unix(sec, 0).UTC(), b, nil
+}
+
+// readName reads a Name from the start of b. A name is either an array of
+// attribute types and values, or the value of a single common name, which
+// is a text string, a byte string for a lowercase hex value, or a tagged
+// MAC address for an EUI-64 identifier.
+func readName(b []byte) (Name, []byte, error) {
+	mt, err := cbor.NextType(b)
+	if err != nil {
+		return nil, b, err
+	}
+
+	var cn string
+	switch mt {
+	case cbor.MajorTypeTextString:
+		cn, b, err = cbor.ReadString(b)
+	case cbor.MajorTypeByteString:
+		var v []byte
+		v, b, err = cbor.ReadBytes(b)
+		cn = hex.EncodeToString(v)
+	case cbor.MajorTypeTag:
+		cn, b, err = readEUI64(b)
+	case cbor.MajorTypeArray:
+		return readAttributes(b)
+	default:
+		return nil, b, fmt.Errorf("unexpected %s", mt)
+	}
+	if err != nil {
+		return nil, b, err
+	}
+	return Name{{Type: oidCommonName, Value: cn}}, b, nil
+}
+
+// readEUI64 reads a name encoded as a MAC address from the start of b, and
+// returns it as an EUI-64 identifier in the form "HH-HH-HH-HH-HH-HH-HH-HH".
+// A 48-bit MAC address is mapped to an EUI-64 by inserting FF-FE in the
+// middle.
+func readEUI64(b []byte) (string, []byte, error) {
+	tag, b, err := cbor.ReadTag(b)
+	if err != nil {
+		return "", b, err
+	}
+	if tag != tagMAC {
+		return "", b, fmt.Errorf("unexpected tag %d", tag)
+	}
+	mac, b, err := cbor.ReadBytes(b)
+	if err != nil {
+		return "", b, err
+	}
+	switch len(mac) {
+	case 6:
+		mac = append(mac[:3:3], append([]byte{0xff, 0xfe}, mac[3:]...)...)
+	case 8:
+	default:
+		return "", b, fmt.Errorf("invalid MAC address length %d", len(mac))
+	}
+	parts := make([]string, len(mac))
+	for i, c := range mac {
+		parts[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(parts, "-"), b, nil
+}
+
+// readAttributes reads an array of attribute types and values from the
+// start of b. Registered types are integers, which are negative for
+// PrintableString values, and other types are object identifiers followed
+// by the DER encoding of their value.
+func readAttributes(b []byte) (Name, []byte, error) {
+	n, b, err := cbor.ReadArrayHeader(b)
+	if err != nil {
+		return nil, b, err
+	}
+	if n%2 != 0 {
+		return nil, b, errors.New("attributes must be pairs of types and values")
+	}
+
+	name := make(Name, n/2)
+	for i := range name {
+		a := &name[i]
+		mt, err := cbor.NextType(b)
+		if err != nil {
+			return nil, b, err
+		}
+		if mt == cbor.MajorTypeByteString {
+			if a.Type, b, err = readOID(b); err != nil {
+				return nil, b, err
+			}
+			var der []byte
+			if der, b, err = cbor.ReadBytes(b); err != nil {
+				return nil, b, err
+			}
+			if rest, err := asn1.Unmarshal(der, &a.Value); err != nil || len(rest) != 0 {
+				return nil, b, fmt.Errorf("invalid value of attribute %s", a.Type)
+			}
+			continue
+		}
+
+		var typ int64
+		if typ, b, err = cbor.ReadInt(b); err != nil {
+			return nil, b, err
+		}
+		if typ < 0 {
+			a.Printable, typ = true, -typ
+		}
+		if typ >= int64(len(attributeTypes)) {
+			return nil, b, fmt.Errorf("unknown attribute type %d", typ)
+		}
+		a.Type = attributeTypes[typ]
+		if a.Value, b, err = cbor.ReadString(b); err != nil {
+			return nil, b, err
+		}
+	}
+	return name, b, nil
+}
+
+// readExtensions reads the extensions from the start of b, which are an
+// array of extension identifiers and values, or just the value of a key
+// usage extension. Registered extensions are critical if their identifier
+// is negative, while unregistered ones have an object identifier followed
+// by an optional true for critical extensions and their DER encoded value.
+func readExtensions(b []byte) ([]Extension, []byte, error) {
+	mt, err := cbor.NextType(b)
+	if err != nil {
+		return nil, b, err
+	}
+	if mt != cbor.MajorTypeArray {
+		usage, rest, err := cbor.ReadInt(b)
+		if err != nil {
+			return nil, b, err
+		}
+		ext := Extension{ID: ExtensionKeyUsage, Critical: usage < 0}
+		if usage < 0 {
+			usage = -usage
+		}
+		ext.Value = cbor.AppendUint(nil, uint64(usage))
+		return []Extension{ext}, rest, nil
+	}
+
+	n, b, err := cbor.ReadArrayHeader(b)
+	if err != nil {
+		return nil, b, err
+	}
+
+	var exts []Extension
+	for i := 0; i < n; i++ {
+		var ext Extension
+		if mt, err = cbor.NextType(b); err != nil {
+			return nil, b, err
+		}
+		if mt == cbor.MajorTypeByteString {
+			if ext.OID, b, err = readOID(b); err != nil {
+				return nil, b, err
+			}
+			if len(b) > 0 && b[0] == 0xf5 {
+				if i++; i >= n {
+					return nil, b, errors.New("missing extension value")
+				}
+				ext.Critical, b = true, b[1:]
+			}
+			if i++; i >= n {
+				return nil, b, errors.New("missing extension value")
+			}
+			if ext.Value, b, err = cbor.ReadBytes(b); err != nil {
+				return nil, b, err
+			}
+			exts = append(exts, ext)
+			continue
+		}
+
+		if ext.ID, b, err = cbor.ReadInt(b); err != nil {
+			return nil, b, err
+		}
+		if ext.ID < 0 {
+			ext.Critical, ext.ID = true, -ext.ID
+		}
+		if i++; i >= n {
+			return nil, b, errors.New("missing extension value")
+		}
+		rest, err := cbor.Skip(b)
+		if err != nil {
+			return nil, b, err
+		}
+		ext.Value, b = b[:len(b)-len(rest)], rest
+		exts = append(exts, ext)
+	}
+	return exts, b, nil
+}
+
+// Object identifiers of the registered attribute types.
+var (
+	oidCommonName   = asn1.ObjectIdentifier{2, 5, 4, 3}
+	oidEmailAddress = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+)
+
+// attributeTypes are the object identifiers of the attribute types in the
+// C509 Attributes registry, indexed by their value.
+var attributeTypes = []asn1.ObjectIdentifier{
+	0:  oidEmailAddress,
+	1:  oidCommonName,
+	2:  {2, 5, 4, 4},  // surname
+	3:  {2, 5, 4, 5},  // serialNumber
+	4:  {2, 5, 4, 6},  // countryName
+	5:  {2, 5, 4, 7},  // localityName
+	6:  {2, 5, 4, 8},  // stateOrProvinceName
+	7:  {2, 5, 4, 9},  // streetAddress
+	8:  {2, 5, 4, 10}, // organizationName
+	9:  {2, 5, 4, 11}, // organizationalUnitName
+	10: {2, 5, 4, 12}, // title
+	11: {2, 5, 4, 15}, // businessCategory
+	12: {2, 5, 4, 17}, // postalCode
+	13: {2, 5, 4, 42}, // givenName
+	14: {2, 5, 4, 43}, // initials
+	15: {2, 5, 4, 44}, // generationQualifier
+	16: {2, 5, 4, 46}, // dnQualifier
+	17: {2, 5, 4, 65}, // pseudonym
+	18: {2, 5, 4, 97}, // organizationIdentifier
+}
+
+// attributeNames are the short names of the attribute types used by Name's
+// String method, keyed by their object identifier.
+var attributeNames = map[string]string{
+	"2.5.4.3":  "CN",
+	"2.5.4.5":  "SERIALNUMBER",
+	"2.5.4.6":  "C",
+	"2.5.4.7":  "L",
+	"2.5.4.8":  "ST",
+	"2.5.4.9":  "STREET",
+	"2.5.4.10": "O",
+	"2.5.4.11": "OU",
+	"2.5.4.17": "POSTALCODE",
+}
@@ -0,0 +1,236 @@
+package c509_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/picatz/cbor"
+	"github.com/picatz/cbor/c509"
+)
+
+// tbs returns the CBOR sequence of the items of a certificate before its
+// signature, with the given issuer, subject, public key and extensions,
+// which are already encoded.
+func tbs(issuer, subject, pubAlg, pub, exts []byte) []byte {
+	b := cbor.AppendInt(nil, c509.TypeNative)
+	b = cbor.AppendBytes(b, []byte{0x12, 0x82, 0x69})
+	b = cbor.AppendInt(b, 0) // ecdsa-with-SHA256
+	b = append(b, issuer...)
+	b = cbor.AppendInt(b, 1672531200)
+	b = cbor.AppendInt(b, 1767225600)
+	b = append(b, subject...)
+	b = append(b, pubAlg...)
+	b = append(b, pub...)
+	return append(b, exts...)
+}
+
+// certificate returns the encoding of a certificate with the items of tbs
+// and the given signature.
+func certificate(tbs, sig []byte) []byte {
+	b := cbor.AppendArrayHeader(nil, 11)
+	b = append(b, tbs...)
+	return cbor.AppendBytes(b, sig)
+}
+
+func TestParse(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := elliptic.Marshal(elliptic.P256(), key.X, key.Y)
+
+	subject := cbor.AppendArrayHeader(nil, 4)
+	subject = cbor.AppendInt(subject, 1)
+	subject = cbor.AppendString(subject, "device 42")
+	subject = cbor.AppendInt(subject, -4)
+	subject = cbor.AppendString(subject, "SE")
+
+	exts := cbor.AppendArrayHeader(nil, 11)
+	exts = cbor.AppendInt(exts, -4) // critical basicConstraints
+	exts = cbor.AppendInt(exts, -2) // not a CA
+	exts = cbor.AppendInt(exts, 2)  // keyUsage
+	exts = cbor.AppendInt(exts, int64(x509.KeyUsageDigitalSignature|x509.KeyUsageKeyAgreement))
+	exts = cbor.AppendInt(exts, 3) // subjectAltName
+	exts = cbor.AppendArrayHeader(exts, 4)
+	exts = cbor.AppendInt(exts, 2)
+	exts = cbor.AppendString(exts, "device.example.com")
+	exts = cbor.AppendInt(exts, 7)
+	exts = cbor.AppendBytes(exts, []byte{192, 0, 2, 1})
+	exts = cbor.AppendInt(exts, 8) // extKeyUsage
+	exts = cbor.AppendInt(exts, 2)
+	exts = cbor.AppendBytes(exts, []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37}) // 1.3.6.1.4.1.311
+	exts = cbor.AppendBool(exts, true)
+	exts = cbor.AppendBytes(exts, []byte{0x05, 0x00})
+
+	data := tbs(
+		cbor.AppendString(nil, "RFC test CA"),
+		subject,
+		cbor.AppendInt(nil, 1), // id-ecPublicKey with secp256r1
+		cbor.AppendBytes(nil, point[1:]),
+		exts,
+	)
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	data = certificate(data, sig)
+
+	c, err := c509.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Type != c509.TypeNative || c.SerialNumber.Cmp(big.NewInt(0x128269)) != 0 {
+		t.Fatalf("unexpected type %d or serial number %v", c.Type, c.SerialNumber)
+	}
+	if got, want := c.Issuer.String(), "RFC test CA"; got != want {
+		t.Fatalf("expected issuer %q, got %q", want, got)
+	}
+	if got, want := c.Subject.String(), "CN=device 42,C=SE"; got != want {
+		t.Fatalf("expected subject %q, got %q", want, got)
+	}
+	if !c.Subject[1].Printable {
+		t.Fatal("expected printable country name")
+	}
+	if want := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC); !c.NotBefore.Equal(want) {
+		t.Fatalf("expected not before %v, got %v", want, c.NotBefore)
+	}
+	if len(c.Extensions) != 5 || !c.Extensions[0].Critical || !c.Extensions[4].Critical {
+		t.Fatalf("unexpected extensions %+v", c.Extensions)
+	}
+
+	cert, err := c.X509()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "device 42" || len(cert.Subject.Country) != 1 || cert.Issuer.CommonName != "RFC test CA" {
+		t.Fatalf("unexpected names %v and %v", cert.Subject, cert.Issuer)
+	}
+	if !cert.BasicConstraintsValid || cert.IsCA {
+		t.Fatal("expected a certificate which is not a CA")
+	}
+	if cert.KeyUsage != x509.KeyUsageDigitalSignature|x509.KeyUsageKeyAgreement {
+		t.Fatalf("unexpected key usage %v", cert.KeyUsage)
+	}
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageClientAuth {
+		t.Fatalf("unexpected extended key usage %v", cert.ExtKeyUsage)
+	}
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "device.example.com" || !cert.IPAddresses[0].Equal(net.IPv4(192, 0, 2, 1)) {
+		t.Fatalf("unexpected names %v and %v", cert.DNSNames, cert.IPAddresses)
+	}
+	if len(cert.UnhandledCriticalExtensions) != 1 || !cert.UnhandledCriticalExtensions[0].Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311}) {
+		t.Fatalf("unexpected critical extensions %v", cert.UnhandledCriticalExtensions)
+	}
+	if cert.SignatureAlgorithm != x509.ECDSAWithSHA256 || cert.PublicKeyAlgorithm != x509.ECDSA {
+		t.Fatalf("unexpected algorithms %v and %v", cert.SignatureAlgorithm, cert.PublicKeyAlgorithm)
+	}
+
+	// The signature of a native certificate is over RawTBSCertificate, and
+	// is converted to ASN.1 DER.
+	pub := cert.PublicKey.(*ecdsa.PublicKey)
+	if !pub.Equal(&key.PublicKey) {
+		t.Fatal("unexpected public key")
+	}
+	digest = sha256.Sum256(c.RawTBSCertificate)
+	if !ecdsa.VerifyASN1(pub, digest[:], cert.Signature) {
+		t.Fatal("signature doesn't verify")
+	}
+}
+
+func TestParse_specialNames(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		subject []byte
+		want    string
+	}{
+		{"hex", cbor.AppendBytes(nil, []byte{0x01, 0x23, 0xab}), "0123ab"},
+		{"EUI-64", cbor.AppendBytes(cbor.AppendTag(nil, 48), []byte{1, 2, 3, 4, 5, 6, 7, 8}), "01-02-03-04-05-06-07-08"},
+		{"MAC-48", cbor.AppendBytes(cbor.AppendTag(nil, 48), []byte{0xac, 0xde, 0x48, 0x00, 0x11, 0x22}), "AC-DE-48-FF-FE-00-11-22"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := tbs(
+				cbor.AppendNull(nil),
+				test.subject,
+				cbor.AppendInt(nil, 10), // Ed25519
+				cbor.AppendBytes(nil, pub),
+				cbor.AppendInt(nil, -int64(x509.KeyUsageCertSign)),
+			)
+			c, err := c509.Parse(certificate(data, make([]byte, 64)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// A null issuer is the same as the subject.
+			if c.Subject.String() != test.want || c.Issuer.String() != test.want {
+				t.Fatalf("expected %q, got subject %q and issuer %q", test.want, c.Subject, c.Issuer)
+			}
+			if len(c.Extensions) != 1 || c.Extensions[0].ID != c509.ExtensionKeyUsage || !c.Extensions[0].Critical {
+				t.Fatalf("unexpected extensions %+v", c.Extensions)
+			}
+
+			cert, err := c.X509()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cert.KeyUsage != x509.KeyUsageCertSign {
+				t.Fatalf("unexpected key usage %v", cert.KeyUsage)
+			}
+			if !pub.Equal(cert.PublicKey) {
+				t.Fatal("unexpected public key")
+			}
+		})
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	valid := tbs(cbor.AppendString(nil, "CA"), cbor.AppendString(nil, "device"), cbor.AppendInt(nil, 10), cbor.AppendBytes(nil, make([]byte, 32)), cbor.AppendArrayHeader(nil, 0))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not an array", cbor.AppendInt(nil, 1)},
+		{"short array", cbor.AppendArrayHeader(nil, 2)},
+		{"unknown type", certificate(append(cbor.AppendInt(nil, 7), valid[1:]...), nil)},
+		{"trailing data", append(certificate(valid, nil), 0x00)},
+		{"truncated", certificate(valid, nil)[:20]},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := c509.Parse(test.data); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+
+	// Critical extensions which can't be converted are reported by X509.
+	exts := cbor.AppendArrayHeader(nil, 2)
+	exts = cbor.AppendInt(exts, -99)
+	exts = cbor.AppendNull(exts)
+	data := tbs(cbor.AppendString(nil, "CA"), cbor.AppendString(nil, "device"), cbor.AppendInt(nil, 10), cbor.AppendBytes(nil, make([]byte, 32)), exts)
+	c, err := c509.Parse(certificate(data, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.X509(); err == nil {
+		t.Fatal("expected error for unsupported critical extension")
+	}
+}
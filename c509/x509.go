@@ -0,0 +1,355 @@
+package c509
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+
+	"github.com/picatz/cbor"
+)
+
+// Values of the C509 Signature Algorithms registry which have an
+// equivalent x509.SignatureAlgorithm.
+var signatureAlgorithms = map[int64]x509.SignatureAlgorithm{
+	-256: x509.SHA1WithRSA,
+	-255: x509.ECDSAWithSHA1,
+	0:    x509.ECDSAWithSHA256,
+	1:    x509.ECDSAWithSHA384,
+	2:    x509.ECDSAWithSHA512,
+	12:   x509.PureEd25519,
+	23:   x509.SHA256WithRSA,
+	24:   x509.SHA384WithRSA,
+	25:   x509.SHA512WithRSA,
+	26:   x509.SHA256WithRSAPSS,
+	27:   x509.SHA384WithRSAPSS,
+	28:   x509.SHA512WithRSAPSS,
+}
+
+// Values of the C509 Public Key Algorithms registry.
+const (
+	publicKeyRSA     = 0
+	publicKeyP256    = 1
+	publicKeyP384    = 2
+	publicKeyP521    = 3
+	publicKeyEd25519 = 10
+)
+
+// Values of the C509 General Names registry.
+const (
+	generalNameRFC822Name = 1
+	generalNameDNSName    = 2
+	generalNameURI        = 6
+	generalNameIPAddress  = 7
+)
+
+// X509 converts the certificate to an *x509.Certificate, with the fields
+// which have an equivalent in the crypto/x509 package.
+//
+// The Raw fields of the result are not set, since a C509 certificate
+// doesn't keep the DER encoding it was re-encoded from, so the result
+// can't be used to check signatures. Algorithms and extensions which
+// crypto/x509 doesn't support are left out, except for unsupported
+// critical extensions, which return an error.
+func (c *Certificate) X509() (*x509.Certificate, error) {
+	cert := &x509.Certificate{
+		Version:      3,
+		SerialNumber: c.SerialNumber,
+		Issuer:       c.Issuer.pkix(),
+		Subject:      c.Subject.pkix(),
+		NotBefore:    c.NotBefore,
+		NotAfter:     c.NotAfter,
+		Signature:    c.Signature,
+	}
+
+	if c.SignatureAlgorithm.OID == nil {
+		cert.SignatureAlgorithm = signatureAlgorithms[c.SignatureAlgorithm.ID]
+	}
+	switch cert.SignatureAlgorithm {
+	case x509.ECDSAWithSHA1, x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512:
+		// X.509 uses ASN.1 DER signatures, rather than the concatenation of
+		// r and s.
+		half := len(c.Signature) / 2
+		sig, err := asn1.Marshal(struct{ R, S *big.Int }{
+			new(big.Int).SetBytes(c.Signature[:half]),
+			new(big.Int).SetBytes(c.Signature[half:]),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("c509: invalid ECDSA signature: %w", err)
+		}
+		cert.Signature = sig
+	}
+
+	pub, err := c.PublicKey()
+	if err == nil {
+		cert.PublicKey = pub
+		switch pub.(type) {
+		case *rsa.PublicKey:
+			cert.PublicKeyAlgorithm = x509.RSA
+		case *ecdsa.PublicKey:
+			cert.PublicKeyAlgorithm = x509.ECDSA
+		case ed25519.PublicKey:
+			cert.PublicKeyAlgorithm = x509.Ed25519
+		}
+	} else if !errors.Is(err, errUnsupportedPublicKey) {
+		return nil, err
+	}
+
+	for _, ext := range c.Extensions {
+		if ext.OID != nil {
+			cert.Extensions = append(cert.Extensions, pkix.Extension{Id: ext.OID, Critical: ext.Critical, Value: ext.Value})
+			if ext.Critical {
+				cert.UnhandledCriticalExtensions = append(cert.UnhandledCriticalExtensions, ext.OID)
+			}
+			continue
+		}
+		if err := ext.apply(cert); err != nil {
+			return nil, fmt.Errorf("c509: invalid extension %d: %w", ext.ID, err)
+		}
+	}
+	return cert, nil
+}
+
+// errUnsupportedPublicKey is returned by PublicKey for algorithms which
+// don't have an equivalent in the crypto packages.
+var errUnsupportedPublicKey = errors.New("c509: unsupported public key algorithm")
+
+// PublicKey decodes the subject public key of an RSA, ECDSA or Ed25519
+// certificate into an *rsa.PublicKey, *ecdsa.PublicKey or
+// ed25519.PublicKey.
+func (c *Certificate) PublicKey() (crypto.PublicKey, error) {
+	if c.PublicKeyAlgorithm.OID != nil {
+		return nil, errUnsupportedPublicKey
+	}
+
+	switch alg := c.PublicKeyAlgorithm.ID; alg {
+	case publicKeyRSA:
+		// The key is the modulus, or an array of the modulus and the
+		// exponent if that isn't 65537.
+		exp := []byte{0x01, 0x00, 0x01}
+		b := c.RawPublicKey
+		if mt, _ := cbor.NextType(b); mt == cbor.MajorTypeArray {
+			n, rest, err := cbor.ReadArrayHeader(b)
+			if err != nil || n != 2 {
+				return nil, errors.New("c509: invalid RSA public key")
+			}
+			b = rest
+			if _, rest, err = cbor.ReadBytes(b); err != nil {
+				return nil, errors.New("c509: invalid RSA public key")
+			}
+			if exp, _, err = cbor.ReadBytes(rest); err != nil {
+				return nil, errors.New("c509: invalid RSA public key")
+			}
+		}
+		mod, _, err := cbor.ReadBytes(b)
+		if err != nil || len(exp) > 4 {
+			return nil, errors.New("c509: invalid RSA public key")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(mod),
+			E: int(new(big.Int).SetBytes(exp).Int64()),
+		}, nil
+	case publicKeyP256, publicKeyP384, publicKeyP521:
+		curve := map[int64]elliptic.Curve{
+			publicKeyP256: elliptic.P256(),
+			publicKeyP384: elliptic.P384(),
+			publicKeyP521: elliptic.P521(),
+		}[alg]
+		point, _, err := cbor.ReadBytes(c.RawPublicKey)
+		if err != nil || len(point) == 0 {
+			return nil, errors.New("c509: invalid EC public key")
+		}
+
+		// Compressed points have their 0x02 or 0x03 prefix replaced by
+		// 0xfe or 0xfd, and uncompressed points leave out their 0x04
+		// prefix.
+		var x, y *big.Int
+		switch point[0] {
+		case 0xfe:
+			point[0] = 0x02
+			x, y = elliptic.UnmarshalCompressed(curve, point)
+		case 0xfd:
+			point[0] = 0x03
+			x, y = elliptic.UnmarshalCompressed(curve, point)
+		default:
+			x, y = elliptic.Unmarshal(curve, append([]byte{0x04}, point...))
+		}
+		if x == nil {
+			return nil, errors.New("c509: invalid EC public key")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case publicKeyEd25519:
+		key, _, err := cbor.ReadBytes(c.RawPublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("c509: invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(key), nil
+	}
+	return nil, errUnsupportedPublicKey
+}
+
+// apply sets the fields of cert for a registered extension.
+func (ext *Extension) apply(cert *x509.Certificate) error {
+	var err error
+	switch ext.ID {
+	case ExtensionSubjectKeyIdentifier:
+		cert.SubjectKeyId, _, err = cbor.ReadBytes(ext.Value)
+	case ExtensionAuthorityKeyIdentifier:
+		// The key identifier alone, or an array of it, the issuer and the
+		// serial number.
+		b := ext.Value
+		if mt, _ := cbor.NextType(b); mt == cbor.MajorTypeArray {
+			if _, b, err = cbor.ReadArrayHeader(b); err != nil {
+				return err
+			}
+		}
+		cert.AuthorityKeyId, _, err = cbor.ReadBytes(b)
+	case ExtensionKeyUsage:
+		var usage uint64
+		usage, _, err = cbor.ReadUint(ext.Value)
+		cert.KeyUsage = x509.KeyUsage(usage)
+	case ExtensionBasicConstraints:
+		// -2 is not a CA, -1 is a CA without a path length constraint, and
+		// other values are the path length constraint of a CA.
+		var v int64
+		if v, _, err = cbor.ReadInt(ext.Value); err != nil {
+			return err
+		}
+		cert.BasicConstraintsValid = true
+		cert.IsCA = v != -2
+		cert.MaxPathLen = -1
+		if v >= 0 {
+			cert.MaxPathLen = int(v)
+			cert.MaxPathLenZero = v == 0
+		}
+	case ExtensionExtKeyUsage:
+		err = ext.applyExtKeyUsage(cert)
+	case ExtensionSubjectAltName:
+		err = ext.applySubjectAltName(cert)
+	default:
+		if ext.Critical {
+			return errors.New("unsupported critical extension")
+		}
+	}
+	return err
+}
+
+// applyExtKeyUsage sets the extended key usages of cert, which are a
+// single integer or an array of them. Their values are the last arc of
+// the object identifiers under id-kp, 1.3.6.1.5.5.7.3.
+func (ext *Extension) applyExtKeyUsage(cert *x509.Certificate) error {
+	b := ext.Value
+	n := 1
+	if mt, _ := cbor.NextType(b); mt == cbor.MajorTypeArray {
+		var err error
+		if n, b, err = cbor.ReadArrayHeader(b); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < n; i++ {
+		var (
+			v   uint64
+			err error
+		)
+		if v, b, err = cbor.ReadUint(b); err != nil {
+			return err
+		}
+		switch v {
+		case 1:
+			cert.ExtKeyUsage = append(cert.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+		case 2:
+			cert.ExtKeyUsage = append(cert.ExtKeyUsage, x509.ExtKeyUsageClientAuth)
+		case 3:
+			cert.ExtKeyUsage = append(cert.ExtKeyUsage, x509.ExtKeyUsageCodeSigning)
+		case 4:
+			cert.ExtKeyUsage = append(cert.ExtKeyUsage, x509.ExtKeyUsageEmailProtection)
+		case 8:
+			cert.ExtKeyUsage = append(cert.ExtKeyUsage, x509.ExtKeyUsageTimeStamping)
+		case 9:
+			cert.ExtKeyUsage = append(cert.ExtKeyUsage, x509.ExtKeyUsageOCSPSigning)
+		default:
+			cert.UnknownExtKeyUsage = append(cert.UnknownExtKeyUsage, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, int(v)})
+		}
+	}
+	return nil
+}
+
+// applySubjectAltName sets the subject alternative names of cert, which
+// are a single DNS name, or an array of general name types and values.
+// Names of types crypto/x509 doesn't support are left out.
+func (ext *Extension) applySubjectAltName(cert *x509.Certificate) error {
+	b := ext.Value
+	if mt, _ := cbor.NextType(b); mt == cbor.MajorTypeTextString {
+		name, _, err := cbor.ReadString(b)
+		cert.DNSNames = append(cert.DNSNames, name)
+		return err
+	}
+
+	n, b, err := cbor.ReadArrayHeader(b)
+	if err != nil {
+		return err
+	}
+	if n%2 != 0 {
+		return errors.New("general names must be pairs of types and values")
+	}
+	for i := 0; i < n/2; i++ {
+		var typ int64
+		if typ, b, err = cbor.ReadInt(b); err != nil {
+			return err
+		}
+		switch typ {
+		case generalNameRFC822Name, generalNameDNSName, generalNameURI:
+			var s string
+			if s, b, err = cbor.ReadString(b); err != nil {
+				return err
+			}
+			switch typ {
+			case generalNameRFC822Name:
+				cert.EmailAddresses = append(cert.EmailAddresses, s)
+			case generalNameDNSName:
+				cert.DNSNames = append(cert.DNSNames, s)
+			default:
+				u, err := url.Parse(s)
+				if err != nil {
+					return err
+				}
+				cert.URIs = append(cert.URIs, u)
+			}
+		case generalNameIPAddress:
+			var ip []byte
+			if ip, b, err = cbor.ReadBytes(b); err != nil {
+				return err
+			}
+			if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+				return fmt.Errorf("invalid IP address length %d", len(ip))
+			}
+			cert.IPAddresses = append(cert.IPAddresses, net.IP(ip))
+		default:
+			if b, err = cbor.Skip(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pkix converts the name to a pkix.Name, with one attribute in each
+// relative distinguished name.
+func (n Name) pkix() pkix.Name {
+	rdns := make(pkix.RDNSequence, len(n))
+	for i, a := range n {
+		rdns[i] = pkix.RelativeDistinguishedNameSET{{Type: a.Type, Value: a.Value}}
+	}
+	var name pkix.Name
+	name.FillFromRDNSequence(&rdns)
+	return name
+}
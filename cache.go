@@ -2,73 +2,230 @@ package cbor
 
 import (
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 )
 
-// structTypeCache is a cache of struct types used to reduce allocations
-// when decoding CBOR into structs, avoiding the need to reflect on the
-// struct type for each field.
-var structTypeCache sync.Map
+// fieldCache is a cache of the indices of reflect.Type fields by name,
+// sorted by name, used to speed up decoding CBOR maps into struct values.
+//
+// It stores indices rather than field values, since the cache is shared by
+// all values of the struct type. A sorted slice is used rather than a map
+// so that keys can be looked up as byte slices without converting them to
+// strings, and since structs rarely have enough fields for a map to be
+// faster.
+type fieldCache []cachedField
+
+// cachedField is the map key name and index of a struct field.
+type cachedField struct {
+	name  string
+	index int
+}
 
-// storeFieldCache adds a struct type to the cache from the given reflect.Value
-// if it is not already in the cache.
-func storeFieldCache(rv reflect.Value) fieldCache {
-	// Check if the type is already in the cache.
-	t := rv.Type()
+// lookupField returns the index of the field in fc named key.
+func lookupField[K string | []byte](fc fieldCache, key K) (int, bool) {
+	i := sort.Search(len(fc), func(i int) bool { return fc[i].name >= string(key) })
+	if i < len(fc) && fc[i].name == string(key) {
+		return fc[i].index, true
+	}
+	return 0, false
+}
 
-	if v, ok := structTypeCache.Load(t); ok {
-		fc, ok := v.(fieldCache)
-		if !ok {
-			panic("cbor: invalid field cache")
+// lookupFold returns the index of the first field whose name matches key
+// case-insensitively.
+func (fc fieldCache) lookupFold(key string) (int, bool) {
+	index, ok := 0, false
+	for _, f := range fc {
+		if strings.EqualFold(f.name, key) && (!ok || f.index < index) {
+			index, ok = f.index, true
 		}
-		return fc
 	}
+	return index, ok
+}
 
-	fieldCache := make(fieldCache, rv.NumField())
+// structTypeCache is a cache of the fieldCache of struct types, keyed by
+// fieldCacheKey, used to avoid reflecting on the struct type for each
+// value decoded.
+var structTypeCache sync.Map
 
-	// Iterate over the map fields in the struct to build
-	// a cache of field names and keyasint values.
-	for i := 0; i < rv.NumField(); i++ {
-		field := rv.Type().Field(i)
+// fieldCacheKey is the key of a fieldCache in structTypeCache, since the
+// keys of fields depend on whether json tags are used.
+type fieldCacheKey struct {
+	t        reflect.Type
+	jsonTags bool
+}
 
-		// If the field is unexported, skip it.
-		if field.PkgPath != "" {
+// loadFieldCache returns the field cache for the given struct type,
+// building it on first use. If jsonTags is set, fields without a cbor tag
+// use their json tag.
+func loadFieldCache(t reflect.Type, jsonTags bool) fieldCache {
+	ck := fieldCacheKey{t: t, jsonTags: jsonTags}
+	if v, ok := structTypeCache.Load(ck); ok {
+		return v.(fieldCache)
+	}
+
+	keys := structKeys(t, jsonTags)
+	fc := make(fieldCache, 0, len(keys))
+
+	// Add the exported fields to the cache by their map key, which is
+	// either the field name, the name in their cbor tag, or their
+	// automatic integer key.
+	for _, k := range keys {
+		fc = append(fc, cachedField{name: k.name, index: k.index})
+	}
+
+	// Sort the fields by name, keeping only the last field with a
+	// duplicate name.
+	sort.SliceStable(fc, func(i, j int) bool { return fc[i].name < fc[j].name })
+	dedup := fc[:0]
+	for i, f := range fc {
+		if i+1 < len(fc) && fc[i+1].name == f.name {
 			continue
 		}
+		dedup = append(dedup, f)
+	}
+
+	v, _ := structTypeCache.LoadOrStore(ck, dedup)
+	return v.(fieldCache)
+}
 
-		// If the field has no cbor tag, add it to the
-		// field name cache with the field name as the key.
-		if field.Tag == "" {
-			fieldCache[field.Name] = rv.Field(i)
+// structKey is the map key of an exported struct field, and the options
+// in its cbor tag.
+type structKey struct {
+	index     int
+	name      string
+	keyAsInt  bool
+	omitEmpty bool
+	omitZero  bool
+	transform string
+}
+
+// structKeys returns the map keys of the exported fields of t, in order,
+// leaving out fields with the cbor tag "-". The key of a field is the name
+// in its cbor tag, or its Go name if the tag doesn't have one.
+//
+// If jsonTags is set, the json tag of fields without a cbor tag is used as
+// if it were their cbor tag, for its name and its omitempty and omitzero
+// options, and fields with the json tag "-" are left out.
+//
+// If t has a blank field with the intkeys option, such as
+//
+//	_ struct{} `cbor:",intkeys"`
+//
+// fields without a name in their cbor tag are given sequential integer
+// keys, as if they had the keyasint option, counting every exported field
+// which isn't left out from the number in the tag of the blank field, or 0.
+func structKeys(t reflect.Type, jsonTags bool) []structKey {
+	next, auto := intKeysBase(t)
+
+	var keys []structKey
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if ignoredField(field) || fieldTag(field, jsonTags) == "-" {
 			continue
 		}
 
-		// Check cbor tag for keyasint.
-		if tag := field.Tag.Get("cbor"); tag != "" {
-			// Use index to avoid allocating a new string.
-			if idx := strings.Index(tag, ",keyasint"); idx != -1 {
-				// If the tag is "keyasint", add it to the field cache.
-				fieldCache[tag[:idx]] = rv.Field(field.Index[0])
-			} else {
-				// If the tag is not "keyasint", add it to the field cache
-				// with the tag value as the key.
-				fieldCache[tag] = rv.Field(field.Index[0])
+		// Invalid tags are reported by CheckStructTags, and otherwise
+		// used as far as they could be parsed. Options of json tags which
+		// cbor tags don't have, like "string", are ignored.
+		tag, _ := parseStructTag(fieldTag(field, jsonTags))
+		k := structKey{
+			index:     i,
+			name:      tag.name,
+			keyAsInt:  tag.keyAsInt,
+			omitEmpty: tag.omitEmpty,
+			omitZero:  tag.omitZero,
+			transform: tag.transform,
+		}
+		if auto {
+			if k.name == "" {
+				k.name, k.keyAsInt = strconv.FormatInt(next, 10), true
 			}
+			next++
+		}
+		if k.name == "" {
+			k.name = field.Name
 		}
+		keys = append(keys, k)
 	}
+	return keys
+}
 
-	structTypeCache.Store(t, fieldCache)
+// ignoredField reports whether a struct field is left out of encoding and
+// decoding, because it's unexported or its cbor tag is "-". As with
+// encoding/json, a field can still have the key "-" with the tag "-,".
+func ignoredField(field reflect.StructField) bool {
+	return field.PkgPath != "" || field.Tag.Get("cbor") == "-"
+}
 
-	return fieldCache
+// unexportedFields returns the names of the unexported fields of the
+// struct type t, other than blank fields and fields with the cbor tag "-".
+func unexportedFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && field.Name != "_" && field.Tag.Get("cbor") != "-" {
+			names = append(names, field.Name)
+		}
+	}
+	return names
 }
 
-// loadFieldCache returns the field cache for the given struct type, or nil
-// if the type is not in the cache.
-func loadFieldCache(t reflect.Type) fieldCache {
-	if v, ok := structTypeCache.Load(t); ok {
-		return v.(fieldCache)
+// fieldTag returns the cbor tag of a struct field, or its json tag if it
+// doesn't have one and jsonTags is set.
+func fieldTag(field reflect.StructField, jsonTags bool) string {
+	if tag, ok := field.Tag.Lookup("cbor"); ok || !jsonTags {
+		return tag
+	}
+	return field.Tag.Get("json")
+}
+
+// hasJSONTags reports whether the struct type t has exported fields with a
+// json tag and without a cbor tag, whose keys depend on whether json tags
+// are used.
+func hasJSONTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if _, ok := field.Tag.Lookup("cbor"); ok {
+			continue
+		}
+		if _, ok := field.Tag.Lookup("json"); ok {
+			return true
+		}
 	}
+	return false
+}
 
-	return nil
+// typeTag returns the options of t set in the cbor tags of its blank
+// fields, such as intkeys and toarray.
+func typeTag(t reflect.Type) structTag {
+	var tt structTag
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Name != "_" {
+			continue
+		}
+		tag, _ := parseStructTag(field.Tag.Get("cbor"))
+		if tag.intKeys && !tt.intKeys {
+			tt.intKeys, tt.name = true, tag.name
+		}
+		tt.toArray = tt.toArray || tag.toArray
+	}
+	return tt
+}
+
+// intKeysBase returns the first automatic integer key of the fields of t,
+// and whether t has a blank field with the intkeys option.
+func intKeysBase(t reflect.Type) (int64, bool) {
+	tt := typeTag(t)
+	if !tt.intKeys {
+		return 0, false
+	}
+	base, _ := strconv.ParseInt(tt.name, 10, 64)
+	return base, true
 }
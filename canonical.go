@@ -0,0 +1,335 @@
+package cbor
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"math"
+	"sort"
+)
+
+// Equal reports whether the CBOR items at the start of a and b are
+// semantically equal.
+//
+// Unlike bytes.Equal, it ignores differences in how the items are encoded:
+// the length of integer and length arguments, definite and indefinite
+// lengths, how strings are split into chunks, the precision of floats
+// which represent the same value, and the order of map keys. All NaN
+// values are equal to each other. Integers and floats are never equal,
+// even if they have the same numeric value.
+//
+// Malformed items are not equal to anything.
+func Equal(a, b []byte) bool {
+	ca, err := appendDeterministic(nil, a)
+	if err != nil {
+		return false
+	}
+	cb, err := appendDeterministic(nil, b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ca, cb)
+}
+
+// HashCanonical encodes v in the deterministic encoding described by
+// appendDeterministic, and returns its hash computed with h, such as a
+// sha256.New() hash. Values which are Equal when encoded have the same
+// hash, so it is suitable for content addressing, or computing the
+// pre-image of a signature.
+//
+// h is reset before the encoding is written to it.
+func HashCanonical(h hash.Hash, v interface{}) ([]byte, error) {
+	data, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	data, err = appendDeterministic(nil, data)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Reset()
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// appendDeterministic appends the deterministic encoding of the CBOR item
+// at the start of data to dst, as described by the core deterministic
+// encoding requirements of RFC 8949 section 4.2.1: arguments and floats
+// use their shortest form, lengths are definite, and map keys are sorted
+// by the bytewise order of their encodings. NaNs are encoded as 0xf97e00.
+//
+// https://www.rfc-editor.org/rfc/rfc8949.html#section-4.2.1
+func appendDeterministic(dst, data []byte) ([]byte, error) {
+	n, err := itemLength(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	dst, _ = appendDeterministicItem(dst, data[:n])
+	return dst, nil
+}
+
+// appendDeterministicItem appends the deterministic encoding of the
+// well-formed item at the start of data to dst, returning the remaining
+// bytes of data.
+func appendDeterministicItem(dst, data []byte) ([]byte, []byte) {
+	mt, ai, arg, n, _ := parseHeader(data)
+
+	switch mt {
+	case MajorTypeUnsignedInt, MajorTypeNegativeInt, MajorTypeTag:
+		dst = appendHeader(dst, mt, arg)
+		if mt == MajorTypeTag {
+			return appendDeterministicItem(dst, data[n:])
+		}
+		return dst, data[n:]
+	case MajorTypeByteString, MajorTypeTextString:
+		s, rest, _ := readStringBytes(data, mt)
+		dst = appendHeader(dst, mt, uint64(len(s)))
+		return append(dst, s...), rest
+	case MajorTypeArray:
+		v := Value{raw: data}
+		dst = appendHeader(dst, mt, uint64(v.Len()))
+		v.each(func(item []byte) bool {
+			dst, _ = appendDeterministicItem(dst, item)
+			return true
+		})
+		l, _ := itemLength(data, 0)
+		return dst, data[l:]
+	case MajorTypeMap:
+		v := Value{raw: data}
+		keys, values := mapItems(v)
+
+		type pair struct {
+			key, entry []byte
+		}
+		pairs := make([]pair, len(keys))
+		for i := range keys {
+			var entry []byte
+			entry, _ = appendDeterministicItem(entry, keys[i])
+			keyLen := len(entry)
+			entry, _ = appendDeterministicItem(entry, values[i])
+			pairs[i] = pair{key: entry[:keyLen], entry: entry}
+		}
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return bytes.Compare(pairs[i].key, pairs[j].key) < 0
+		})
+
+		dst = appendHeader(dst, mt, uint64(len(pairs)))
+		for _, p := range pairs {
+			dst = append(dst, p.entry...)
+		}
+		l, _ := itemLength(data, 0)
+		return dst, data[l:]
+	default: // MajorTypeSimpleValue
+		switch ai {
+		case 25, 26, 27:
+			f, rest, _ := ReadFloat64(data)
+			return appendShortestFloat(dst, f), rest
+		}
+		return append(dst, data[:n]...), data[n:]
+	}
+}
+
+// CanonicalKeyOrder is the order map keys must be in for IsCanonical.
+type CanonicalKeyOrder int
+
+const (
+	// CanonicalKeyOrderBytewise sorts keys by the bytewise order of their
+	// encodings, as required by the core deterministic encoding of
+	// RFC 8949 section 4.2.1.
+	CanonicalKeyOrderBytewise CanonicalKeyOrder = iota
+
+	// CanonicalKeyOrderLengthFirst sorts shorter encodings of keys first,
+	// and keys of the same length by their bytewise order, as required by
+	// the canonical encoding of RFC 7049 section 3.9 and by CTAP2.
+	CanonicalKeyOrderLengthFirst
+)
+
+// CanonicalOptions are the options of IsCanonical.
+type CanonicalOptions struct {
+	// KeyOrder is the order map keys must be in.
+	KeyOrder CanonicalKeyOrder
+}
+
+// DefaultCanonicalOptions is the default options used by IsCanonical,
+// which check the core deterministic encoding of RFC 8949.
+var DefaultCanonicalOptions = CanonicalOptions{
+	KeyOrder: CanonicalKeyOrderBytewise,
+}
+
+// A CanonicalError is returned by IsCanonical for data which isn't in the
+// canonical encoding, describing the first violation.
+type CanonicalError struct {
+	msg string
+
+	// Offset is the offset in the data of the initial byte of the item
+	// which violates the encoding rules.
+	Offset int64
+}
+
+// Error implements the error interface.
+func (e *CanonicalError) Error() string {
+	return fmt.Sprintf("cbor: non-canonical encoding at offset %d: %s", e.Offset, e.msg)
+}
+
+// IsCanonical returns nil if data contains exactly one CBOR item, in the
+// deterministic encoding described by opts: arguments, lengths and floats
+// use their shortest form, with NaNs encoded as 0xf97e00, lengths are
+// definite, and map keys are sorted without duplicates. Otherwise, it
+// returns a *CanonicalError for the first violation, or the error of
+// malformed data.
+//
+// It is meant to check data from other encoders, such as golden files in
+// tests, or data whose encoding is signed. If opts is nil,
+// DefaultCanonicalOptions is used.
+func IsCanonical(data []byte, opts *CanonicalOptions) error {
+	if opts == nil {
+		opts = &DefaultCanonicalOptions
+	}
+	n, err := itemLength(data, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := checkCanonical(data, 0, opts); err != nil {
+		return err
+	}
+	if n != len(data) {
+		return &CanonicalError{msg: "extra data after item", Offset: int64(n)}
+	}
+	return nil
+}
+
+// checkCanonical checks the well-formed item at data[off:], returning the
+// offset after it.
+func checkCanonical(data []byte, off int, opts *CanonicalOptions) (int, error) {
+	mt, ai, arg, n, _ := parseHeader(data[off:])
+	fail := func(format string, args ...interface{}) (int, error) {
+		return 0, &CanonicalError{msg: fmt.Sprintf(format, args...), Offset: int64(off)}
+	}
+
+	if ai == 31 {
+		return fail("indefinite-length %s", mt)
+	}
+	if mt == MajorTypeSimple {
+		if ai >= 25 {
+			f, _, _ := ReadFloat64(data[off:])
+			if !bytes.Equal(appendShortestFloat(nil, f), data[off:off+n]) {
+				return fail("float %v not in its shortest form", f)
+			}
+		}
+		return off + n, nil
+	}
+	if !shortestArgument(ai, arg) {
+		return fail("argument %d of %s not in its shortest form", arg, mt)
+	}
+
+	end := off + n
+	var err error
+	switch mt {
+	case MajorTypeByteString, MajorTypeTextString:
+		end += int(arg)
+	case MajorTypeArray:
+		for i := uint64(0); i < arg; i++ {
+			if end, err = checkCanonical(data, end, opts); err != nil {
+				return 0, err
+			}
+		}
+	case MajorTypeMap:
+		var prev []byte
+		for i := uint64(0); i < arg; i++ {
+			start := end
+			if end, err = checkCanonical(data, end, opts); err != nil {
+				return 0, err
+			}
+			key := data[start:end]
+			if i > 0 {
+				switch c := compareKeys(prev, key, opts.KeyOrder); {
+				case c == 0:
+					off = start
+					return fail("duplicate map key")
+				case c > 0:
+					off = start
+					return fail("map key out of order")
+				}
+			}
+			prev = key
+			if end, err = checkCanonical(data, end, opts); err != nil {
+				return 0, err
+			}
+		}
+	case MajorTypeTag:
+		return checkCanonical(data, end, opts)
+	}
+	return end, nil
+}
+
+// shortestArgument reports whether arg is encoded in its shortest form
+// with the additional information ai.
+func shortestArgument(ai byte, arg uint64) bool {
+	switch ai {
+	case 24:
+		return arg >= 24
+	case 25:
+		return arg > math.MaxUint8
+	case 26:
+		return arg > math.MaxUint16
+	case 27:
+		return arg > math.MaxUint32
+	}
+	return true
+}
+
+// compareKeys compares the encodings of two map keys in the given order.
+func compareKeys(a, b []byte, order CanonicalKeyOrder) int {
+	if order == CanonicalKeyOrderLengthFirst && len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return bytes.Compare(a, b)
+}
+
+// appendShortestFloat appends the shortest float encoding which preserves
+// the value of f to dst. NaNs are encoded as 0xf97e00.
+func appendShortestFloat(dst []byte, f float64) []byte {
+	if math.IsNaN(f) {
+		return append(dst, 0xf9, 0x7e, 0x00)
+	}
+	if h, ok := float64ToFloat16(f); ok {
+		return append(dst, 0xf9, byte(h>>8), byte(h))
+	}
+	if float64(float32(f)) == f {
+		return AppendFloat32(dst, float32(f))
+	}
+	return AppendFloat64(dst, f)
+}
+
+// float64ToFloat16 converts f to an IEEE 754 half-precision float,
+// reporting whether the conversion is exact. NaNs are not converted.
+func float64ToFloat16(f float64) (uint16, bool) {
+	bits := math.Float64bits(f)
+	sign := uint16(bits>>48) & 0x8000
+	exp := int(bits>>52) & 0x7ff
+	frac := bits & (1<<52 - 1)
+
+	var h uint16
+	switch e := exp - 1023; {
+	case exp == 0x7ff:
+		if frac != 0 {
+			return 0, false
+		}
+		h = sign | 0x7c00
+	case exp == 0 && frac == 0:
+		h = sign
+	case e >= -14 && e <= 15:
+		// Normal numbers.
+		h = sign | uint16(e+15)<<10 | uint16(frac>>42)
+	case e >= -24 && e < -14:
+		// Subnormal numbers, which are multiples of 2^-24.
+		h = sign | uint16((1<<52|frac)>>uint(52-(e+24)))
+	default:
+		return 0, false
+	}
+	return h, float16ToFloat64(h) == f
+}
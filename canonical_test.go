@@ -0,0 +1,137 @@
+package cbor_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"01", "01", true},
+		{"01", "1801", true},                 // non-shortest integer
+		{"20", "3800", true},                 // non-shortest negative integer
+		{"6161", "7f6161ff", true},           // indefinite-length string
+		{"626163", "7f61616162ff", false},    // different strings
+		{"420102", "5f41014102ff", true},     // chunked byte string
+		{"820102", "9f0102ff", true},         // indefinite-length array
+		{"a201020304", "a203040102", true},   // map order
+		{"a201020304", "bf03040102ff", true}, // indefinite-length map
+		{"a10102", "a10103", false},          // different value
+		{"a10102", "a201020304", false},
+		{"f93c00", "fb3ff0000000000000", true}, // float precision
+		{"fa3fc00000", "f93e00", true},
+		{"f97e00", "fb7ff8000000000001", true}, // NaNs
+		{"f93c00", "01", false},                // float and integer
+		{"c101", "d80101", true},               // non-shortest tag
+		{"c101", "c201", false},
+		{"f5", "f4", false},
+		{"f6", "f7", false},
+		{"82", "82", false}, // malformed
+	}
+
+	for _, test := range tests {
+		a, err := hex.DecodeString(test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := hex.DecodeString(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cbor.Equal(a, b); got != test.equal {
+			t.Errorf("Equal(%s, %s) = %v, want %v", test.a, test.b, got, test.equal)
+		}
+		if got := cbor.Equal(b, a); got != test.equal {
+			t.Errorf("Equal(%s, %s) = %v, want %v", test.b, test.a, got, test.equal)
+		}
+	}
+}
+
+func TestHashCanonical(t *testing.T) {
+	m := map[string]interface{}{}
+	for _, k := range []string{"e", "d", "c", "b", "a", "aa"} {
+		m[k] = 1.5
+	}
+
+	// {"a": 1.5, "b": 1.5, "c": 1.5, "d": 1.5, "e": 1.5, "aa": 1.5}
+	want, err := hex.DecodeString("a66161f93e006162f93e006163f93e006164f93e006165f93e00626161f93e00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSum := sha256.Sum256(want)
+
+	h := sha256.New()
+	for i := 0; i < 10; i++ {
+		got, err := cbor.HashCanonical(h, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, wantSum[:]) {
+			t.Fatalf("expected %x, got %x", wantSum, got)
+		}
+	}
+
+	if _, err := cbor.HashCanonical(h, make(chan int)); err == nil {
+		t.Fatal("expected an error for an unsupported type")
+	}
+}
+
+func TestIsCanonical(t *testing.T) {
+	tests := []struct {
+		data   string
+		offset int64 // -1 if canonical
+		opts   *cbor.CanonicalOptions
+	}{
+		{"00", -1, nil},
+		{"1817", 0, nil}, // non-shortest integer
+		{"3818", -1, nil},
+		{"821901000a", -1, nil},
+		{"82011a0000ffff", 2, nil},
+		{"7f6161ff", 0, nil},          // indefinite-length string
+		{"8201820263616263", -1, nil}, // nested
+		{"9f01ff", 0, nil},
+		{"f93e00", -1, nil},
+		{"fa3fc00000", 0, nil}, // float which fits in 16 bits
+		{"fb3ff199999999999a", -1, nil},
+		{"fb7ff8000000000000", 0, nil}, // non-canonical NaN
+		{"c11a514b67b0", -1, nil},
+		{"d801f6", 0, nil}, // non-shortest tag
+		{"a2616101616202", -1, nil},
+		{"a2616201616102", 4, nil}, // out of order
+		{"a2616101616102", 4, nil}, // duplicate key
+		// 1000 sorts before "a" bytewise, but after it length-first.
+		{"a21903e801616102", -1, nil},
+		{"a21903e801616102", 5, &cbor.CanonicalOptions{KeyOrder: cbor.CanonicalKeyOrderLengthFirst}},
+		{"a26161021903e801", -1, &cbor.CanonicalOptions{KeyOrder: cbor.CanonicalKeyOrderLengthFirst}},
+		{"0102", 1, nil}, // extra data
+	}
+
+	for _, test := range tests {
+		data, err := hex.DecodeString(test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cbor.IsCanonical(data, test.opts)
+		var cerr *cbor.CanonicalError
+		switch {
+		case test.offset < 0 && err != nil:
+			t.Errorf("IsCanonical(%s) = %v, want nil", test.data, err)
+		case test.offset >= 0 && (!errors.As(err, &cerr) || cerr.Offset != test.offset):
+			t.Errorf("IsCanonical(%s) = %v, want error at offset %d", test.data, err, test.offset)
+		}
+	}
+
+	if err := cbor.IsCanonical([]byte{0x82, 0x01}, nil); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected unexpected EOF for truncated data, got %v", err)
+	}
+
+}
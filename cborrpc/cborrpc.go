@@ -0,0 +1,235 @@
+// Package cborrpc implements CBOR codecs for the net/rpc package, in the
+// same style as net/rpc/jsonrpc.
+//
+// Each request is encoded as a CBOR map with "method", "id", and "params"
+// keys, and each response as a map with "id", "result", and "error" keys.
+// The error is a text string, or null if the call succeeded.
+package cborrpc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+
+	"github.com/picatz/cbor"
+)
+
+// message reads the next message from r, returning io.EOF if the stream
+// ended cleanly before it.
+func message(r *bufio.Reader, dec *cbor.Decoder) (cbor.Value, error) {
+	if _, err := r.Peek(1); err != nil {
+		return cbor.Value{}, err
+	}
+
+	var msg cbor.Value
+	if err := dec.Decode(&msg); err != nil {
+		return cbor.Value{}, err
+	}
+	if msg.Type() != cbor.MajorTypeMap {
+		return cbor.Value{}, fmt.Errorf("cborrpc: invalid message type %s", msg.Type())
+	}
+	return msg, nil
+}
+
+// appendEntry appends a text string key and the encoding of v to a map
+// being built in b.
+func appendEntry(b []byte, key string, v interface{}) ([]byte, error) {
+	b = cbor.AppendString(b, key)
+	item, err := cbor.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, item...), nil
+}
+
+// readSeq reads the "id" of a message.
+func readSeq(msg cbor.Value) (uint64, error) {
+	id, ok := msg.Get("id")
+	if !ok {
+		return 0, errors.New("cborrpc: message without id")
+	}
+	seq, _, err := cbor.ReadUint(id.Raw())
+	if err != nil {
+		return 0, fmt.Errorf("cborrpc: invalid id: %w", err)
+	}
+	return seq, nil
+}
+
+type serverCodec struct {
+	r   *bufio.Reader
+	dec *cbor.Decoder
+	w   io.Writer
+	c   io.Closer
+
+	// params of the request being read.
+	params cbor.Value
+}
+
+// NewServerCodec returns a new rpc.ServerCodec using CBOR on conn.
+func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	r := bufio.NewReader(conn)
+	return &serverCodec{
+		r:   r,
+		dec: cbor.NewDecoder(r),
+		w:   conn,
+		c:   conn,
+	}
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	msg, err := message(c.r, c.dec)
+	if err != nil {
+		return err
+	}
+
+	method, ok := msg.Get("method")
+	if !ok {
+		return errors.New("cborrpc: request without method")
+	}
+	if r.ServiceMethod, _, err = cbor.ReadString(method.Raw()); err != nil {
+		return fmt.Errorf("cborrpc: invalid method: %w", err)
+	}
+	if r.Seq, err = readSeq(msg); err != nil {
+		return err
+	}
+
+	c.params, _ = msg.Get("params")
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	if c.params.Raw() == nil {
+		return errors.New("cborrpc: request without params")
+	}
+	return c.params.Decode(x)
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, x interface{}) error {
+	b := cbor.AppendMapHeader(nil, 3)
+	b = cbor.AppendString(b, "id")
+	b = cbor.AppendUint(b, r.Seq)
+
+	var err error
+	if r.Error == "" {
+		if b, err = appendEntry(b, "result", x); err != nil {
+			return err
+		}
+		b = cbor.AppendString(b, "error")
+		b = cbor.AppendNull(b)
+	} else {
+		b = cbor.AppendString(b, "result")
+		b = cbor.AppendNull(b)
+		b = cbor.AppendString(b, "error")
+		b = cbor.AppendString(b, r.Error)
+	}
+
+	_, err = c.w.Write(b)
+	return err
+}
+
+func (c *serverCodec) Close() error {
+	return c.c.Close()
+}
+
+// ServeConn runs the CBOR RPC server on a single connection, like
+// rpc.ServeConn. It blocks, serving the connection until the client hangs
+// up.
+func ServeConn(conn io.ReadWriteCloser) {
+	rpc.ServeCodec(NewServerCodec(conn))
+}
+
+type clientCodec struct {
+	r   *bufio.Reader
+	dec *cbor.Decoder
+	w   io.Writer
+	c   io.Closer
+
+	// result of the response being read.
+	result cbor.Value
+}
+
+// NewClientCodec returns a new rpc.ClientCodec using CBOR on conn.
+func NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	r := bufio.NewReader(conn)
+	return &clientCodec{
+		r:   r,
+		dec: cbor.NewDecoder(r),
+		w:   conn,
+		c:   conn,
+	}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, param interface{}) error {
+	b := cbor.AppendMapHeader(nil, 3)
+	b = cbor.AppendString(b, "method")
+	b = cbor.AppendString(b, r.ServiceMethod)
+	b = cbor.AppendString(b, "id")
+	b = cbor.AppendUint(b, r.Seq)
+
+	b, err := appendEntry(b, "params", param)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.w.Write(b)
+	return err
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	msg, err := message(c.r, c.dec)
+	if err != nil {
+		return err
+	}
+
+	if r.Seq, err = readSeq(msg); err != nil {
+		return err
+	}
+
+	r.Error = ""
+	if e, ok := msg.Get("error"); ok && e.Type() == cbor.MajorTypeTextString {
+		if r.Error, _, err = cbor.ReadString(e.Raw()); err != nil {
+			return err
+		}
+		if r.Error == "" {
+			r.Error = "unspecified error"
+		}
+	}
+
+	c.result, _ = msg.Get("result")
+	return nil
+}
+
+func (c *clientCodec) ReadResponseBody(x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	if c.result.Raw() == nil {
+		return errors.New("cborrpc: response without result")
+	}
+	return c.result.Decode(x)
+}
+
+func (c *clientCodec) Close() error {
+	return c.c.Close()
+}
+
+// NewClient returns a new rpc.Client to handle requests to the set of
+// services at the other end of the connection.
+func NewClient(conn io.ReadWriteCloser) *rpc.Client {
+	return rpc.NewClientWithCodec(NewClientCodec(conn))
+}
+
+// Dial connects to a CBOR RPC server at the specified network address.
+func Dial(network, address string) (*rpc.Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
@@ -0,0 +1,118 @@
+package cborrpc_test
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/picatz/cbor/cborrpc"
+)
+
+type Args struct {
+	A int `cbor:"a"`
+	B int `cbor:"b"`
+}
+
+type Reply struct {
+	C int `cbor:"c"`
+}
+
+type Arith int
+
+func (t *Arith) Add(args *Args, reply *Reply) error {
+	reply.C = args.A + args.B
+	return nil
+}
+
+func (t *Arith) Div(args *Args, reply *Reply) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	reply.C = args.A / args.B
+	return nil
+}
+
+func (t *Arith) Echo(args string, reply *string) error {
+	*reply = args
+	return nil
+}
+
+func newClient(t *testing.T) *rpc.Client {
+	t.Helper()
+
+	server := rpc.NewServer()
+	if err := server.Register(new(Arith)); err != nil {
+		t.Fatal(err)
+	}
+
+	cli, srv := net.Pipe()
+	go server.ServeCodec(cborrpc.NewServerCodec(srv))
+
+	client := cborrpc.NewClient(cli)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestCall(t *testing.T) {
+	client := newClient(t)
+
+	for i := 0; i < 3; i++ {
+		var reply Reply
+		if err := client.Call("Arith.Add", &Args{A: i, B: 8}, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.C != i+8 {
+			t.Fatalf("got %d, want %d", reply.C, i+8)
+		}
+	}
+
+	var s string
+	if err := client.Call("Arith.Echo", "hello", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s != "hello" {
+		t.Fatalf("got %q", s)
+	}
+}
+
+func TestCallError(t *testing.T) {
+	client := newClient(t)
+
+	var reply Reply
+	err := client.Call("Arith.Div", &Args{A: 1, B: 0}, &reply)
+	if err == nil || err.Error() != "divide by zero" {
+		t.Fatalf("got error %v", err)
+	}
+
+	err = client.Call("Arith.Missing", &Args{}, &reply)
+	if err == nil {
+		t.Fatal("expected error for unknown method")
+	}
+
+	// The connection is still usable after errors.
+	if err := client.Call("Arith.Div", &Args{A: 9, B: 3}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.C != 3 {
+		t.Fatalf("got %d", reply.C)
+	}
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	client := newClient(t)
+
+	calls := make([]*rpc.Call, 10)
+	for i := range calls {
+		calls[i] = client.Go("Arith.Add", &Args{A: i * 1000, B: -i}, new(Reply), nil)
+	}
+	for i, call := range calls {
+		<-call.Done
+		if call.Error != nil {
+			t.Fatal(call.Error)
+		}
+		if got := call.Reply.(*Reply).C; got != 999*i {
+			t.Fatalf("call %d: got %d, want %d", i, got, 999*i)
+		}
+	}
+}
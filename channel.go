@@ -0,0 +1,111 @@
+package cbor
+
+import (
+	"context"
+	"io"
+	"reflect"
+)
+
+// DecodeToChannel decodes the elements of the next item read by dec, if
+// it's an array, or else the remaining items of the CBOR sequence (RFC
+// 8742) read by dec, into values of type T, sending each one to ch as soon
+// as it's decoded, such as to feed the records of a bulk import to a
+// pipeline of workers.
+//
+// Only one value is decoded ahead of the receiver, so a slow consumer
+// applies backpressure: the input is read no faster than ch is drained,
+// and a large array is never held in memory at once. Sequences of arrays
+// must be wrapped in an outer array, or read with Decode.
+//
+// DecodeToChannel returns nil at the end of the array or of the input, or
+// the first error, after sending the values decoded before it. It returns
+// ctx.Err() if ctx is done while waiting for the receiver, and checks ctx
+// while reading as DecodeContext does. It doesn't close ch.
+func DecodeToChannel[T any](ctx context.Context, dec *Decoder, ch chan<- T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dec.ctx = ctx
+	defer func() { dec.ctx = nil }()
+
+	t := typeOf[T]()
+	dec.refill()
+	b, err := dec.readByte()
+	if err != nil {
+		dec.commit()
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	if MajorType(b>>5) != MajorTypeArray {
+		for {
+			var v T
+			err := dec.decodeTop(reflect.ValueOf(&v).Elem(), b, rootPath(t))
+			dec.commit()
+			if err != nil {
+				return err
+			}
+			if err := sendValue(ctx, ch, v); err != nil {
+				return err
+			}
+
+			dec.refill()
+			if b, err = dec.readByte(); err != nil {
+				dec.commit()
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+
+	path := rootPath(reflect.SliceOf(t))
+	n, err := dec.readArrayLength(b & 0x1f)
+	dec.commit()
+	if err != nil {
+		return dec.pathError(err, path)
+	}
+	indefinite := b&0x1f == 31
+	for i := 0; indefinite || i < int(n); i++ {
+		dec.refill()
+		b, err := dec.readByte()
+		if err == nil && b == 0xff {
+			dec.commit()
+			if indefinite {
+				return nil
+			}
+			return dec.pathError(newError(ErrMalformed, "cbor: unexpected break"), path)
+		}
+		if err == nil && indefinite && i >= dec.options.MaxArrayElements {
+			err = dec.limitExceeded(ErrArrayTooLong)
+		}
+		if err != nil {
+			err = dec.pathError(unexpectedEOF(err), path)
+			dec.commit()
+			return err
+		}
+
+		var v T
+		err = dec.decodeTop(reflect.ValueOf(&v).Elem(), b, path+indexPath(i))
+		dec.commit()
+		if err != nil {
+			return err
+		}
+		if err := sendValue(ctx, ch, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendValue sends v to ch, or returns ctx.Err() if ctx is done first.
+func sendValue[T any](ctx context.Context, ch chan<- T, v T) error {
+	select {
+	case ch <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,93 @@
+package cbor_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+// decodeToSlice collects the values sent by DecodeToChannel.
+func decodeToSlice[T any](ctx context.Context, r io.Reader) ([]T, error) {
+	ch := make(chan T)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cbor.DecodeToChannel(ctx, cbor.NewDecoder(r), ch)
+		close(ch)
+	}()
+	var got []T
+	for v := range ch {
+		got = append(got, v)
+	}
+	return got, <-errc
+}
+
+func TestDecodeToChannel(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []int
+	}{
+		{"array", "83010203", []int{1, 2, 3}},
+		{"indefinite array", "9f010203ff", []int{1, 2, 3}},
+		{"empty array", "80", nil},
+		{"sequence", "010203", []int{1, 2, 3}},
+		{"empty sequence", "", nil},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.data)
+		for _, r := range []io.Reader{bytes.NewReader(data), io.MultiReader(bytes.NewReader(data))} {
+			got, err := decodeToSlice[int](context.Background(), r)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("%s: expected %v, got %v", test.name, test.want, got)
+			}
+		}
+	}
+}
+
+func TestDecodeToChannel_errors(t *testing.T) {
+	// [1, "a", 3]: the value before the error is sent.
+	data, _ := hex.DecodeString("83016161")
+	got, err := decodeToSlice[int](context.Background(), bytes.NewReader(data))
+	var de *cbor.DecodeError
+	if !errors.As(err, &de) || !errors.Is(err, cbor.ErrInvalidType) || de.Path != "[]int[1]" {
+		t.Fatalf("expected a type error at []int[1], got %v", err)
+	}
+	if !reflect.DeepEqual(got, []int{1}) {
+		t.Fatalf("expected [1], got %v", got)
+	}
+
+	// A truncated array.
+	data, _ = hex.DecodeString("830102")
+	if _, err := decodeToSlice[int](context.Background(), bytes.NewReader(data)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestDecodeToChannel_cancel(t *testing.T) {
+	data, _ := hex.DecodeString("83010203")
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan int)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cbor.DecodeToChannel(ctx, cbor.NewDecoder(bytes.NewReader(data)), ch)
+	}()
+
+	// The decoder blocks on the second value until it's received.
+	if v := <-ch; v != 1 {
+		t.Fatalf("expected 1, got %d", v)
+	}
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
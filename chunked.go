@@ -0,0 +1,176 @@
+package cbor
+
+import (
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// stringChunkSize is the maximum size of the chunks of the strings written
+// with ByteStringWriter and TextStringWriter.
+const stringChunkSize = 16 << 10
+
+var (
+	// errWriterOpen is returned by Encode while a string writer is open.
+	errWriterOpen = errors.New("cbor: string writer is not closed")
+
+	// errWriterClosed is returned by string writers once they're closed.
+	errWriterClosed = errors.New("cbor: string writer is closed")
+)
+
+// ByteStringWriter starts an indefinite-length byte string, and returns a
+// writer for its content, so large blobs can be streamed without holding
+// them in memory. The data written is emitted in chunks of 16 KiB, each of
+// which is written to the underlying writer as soon as it's complete, and
+// Close writes the last, shorter chunk and ends the byte string.
+//
+// The byte string is one value in the output of the encoder, which must be
+// closed before other values are encoded, and counts toward the
+// MaxOutputBytes limit. If writing fails, the error is returned by all
+// later calls.
+func (e *Encoder) ByteStringWriter() io.WriteCloser {
+	return e.stringWriter(MajorTypeByteString)
+}
+
+// TextStringWriter starts an indefinite-length text string, and returns a
+// writer for its content, like ByteStringWriter. Since each chunk of a text
+// string must be valid UTF-8 on its own, chunks are cut before any
+// character which doesn't fit in them, and are up to 16 KiB.
+//
+// The content isn't checked to be valid UTF-8, like strings passed to
+// Encode.
+func (e *Encoder) TextStringWriter() io.WriteCloser {
+	return e.stringWriter(MajorTypeTextString)
+}
+
+// stringWriter starts an indefinite-length string of type mt, and returns
+// its writer.
+func (e *Encoder) stringWriter(mt MajorType) *chunkWriter {
+	w := &chunkWriter{e: e, mt: mt, start: len(e.buf)}
+	if e.writer != nil {
+		w.err = errWriterOpen
+		return w
+	}
+	e.written = -len(e.buf)
+	e.buf = append(e.buf, byte(mt)<<5|31)
+	e.writer = w
+	return w
+}
+
+// chunkWriter is the writer returned by Encoder.ByteStringWriter and
+// Encoder.TextStringWriter.
+type chunkWriter struct {
+	e  *Encoder
+	mt MajorType
+
+	// start is the offset of the byte string in the buffer of the encoder,
+	// which is where it's truncated if encoding into memory fails.
+	start int
+
+	// chunk holds the data written since the last complete chunk.
+	chunk []byte
+
+	err error
+}
+
+// Write writes p to the content of the string. Complete chunks are written
+// from p directly, rather than copied.
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n := len(p)
+	for len(p) > 0 {
+		if len(w.chunk) == 0 && len(p) >= stringChunkSize {
+			k := w.cut(p[:stringChunkSize])
+			if err := w.writeChunk(p[:k]); err != nil {
+				return n - len(p), err
+			}
+			p = p[k:]
+			continue
+		}
+
+		if w.chunk == nil {
+			w.chunk = make([]byte, 0, stringChunkSize)
+		}
+		k := copy(w.chunk[len(w.chunk):cap(w.chunk)], p)
+		w.chunk = w.chunk[:len(w.chunk)+k]
+		p = p[k:]
+		if len(w.chunk) == stringChunkSize {
+			k := w.cut(w.chunk)
+			if err := w.writeChunk(w.chunk[:k]); err != nil {
+				return n - len(p), err
+			}
+			w.chunk = w.chunk[:copy(w.chunk, w.chunk[k:])]
+		}
+	}
+	return n, nil
+}
+
+// cut returns the length of the chunk to write from c, which is full. Text
+// chunks end before a character which is cut off at the end of c.
+func (w *chunkWriter) cut(c []byte) int {
+	if w.mt != MajorTypeTextString {
+		return len(c)
+	}
+	for i := len(c) - 1; i >= 0 && i >= len(c)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(c[i]) {
+			if i > 0 && !utf8.FullRune(c[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(c)
+}
+
+// Close writes the rest of the content and the end of the string.
+func (w *chunkWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.chunk) > 0 {
+		if err := w.writeChunk(w.chunk); err != nil {
+			return err
+		}
+	}
+
+	e := w.e
+	e.buf = append(e.buf, 0xff)
+	if err := e.checkOutput(0); err != nil {
+		return w.fail(err)
+	}
+	e.writer = nil
+	w.err = errWriterClosed
+	return e.finish(w.start)
+}
+
+// writeChunk writes a chunk of the string, flushing it to the underlying
+// writer of the encoder.
+func (w *chunkWriter) writeChunk(c []byte) error {
+	e := w.e
+	if err := e.writeHeader(w.mt, uint64(len(c))); err != nil {
+		return w.fail(err)
+	}
+	e.buf = append(e.buf, c...)
+	if err := e.checkOutput(0); err != nil {
+		return w.fail(err)
+	}
+	if err := e.flush(); err != nil {
+		return w.fail(err)
+	}
+	return nil
+}
+
+// fail makes err the error of all later calls, and releases the encoder
+// for other values. An encoder without an underlying writer is left as it
+// was before the string.
+func (w *chunkWriter) fail(err error) error {
+	if w.e.w == nil {
+		w.e.buf = w.e.buf[:w.start]
+	}
+	w.e.writer = nil
+	w.err = err
+	return err
+}
@@ -0,0 +1,181 @@
+package cbor_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/picatz/cbor"
+)
+
+// recordingWriter records the size of each write.
+type recordingWriter struct {
+	bytes.Buffer
+	writes []int
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, len(p))
+	return w.Buffer.Write(p)
+}
+
+func TestEncoder_ByteStringWriter(t *testing.T) {
+	blob := make([]byte, 40000)
+	for i := range blob {
+		blob[i] = byte(i)
+	}
+
+	var out recordingWriter
+	enc := cbor.NewEncoder(&out)
+	w := enc.ByteStringWriter()
+
+	// Writes of any size are emitted in 16 KiB chunks, as soon as each
+	// one is complete.
+	for rest := blob; len(rest) > 0; {
+		n := 7000
+		if n > len(rest) {
+			n = len(rest)
+		}
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if out.Len() != 2*(3+16384)+1 {
+		t.Fatalf("expected two chunks to be written before Close, got %d bytes", out.Len())
+	}
+
+	if err := enc.Encode(1); err == nil {
+		t.Fatal("expected error encoding a value before Close")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte{1}); err == nil {
+		t.Fatal("expected error writing after Close")
+	}
+	if err := enc.Encode(1); err != nil {
+		t.Fatal(err)
+	}
+
+	data := out.Bytes()
+	want := []byte{0x5f, 0x59, 0x40, 0x00}
+	if !bytes.HasPrefix(data, want) || data[len(data)-2] != 0xff {
+		t.Fatalf("unexpected encoding % x ... % x", data[:8], data[len(data)-8:])
+	}
+
+	// Every chunk but the last is 16 KiB.
+	var got []byte
+	for rest := data[1:]; rest[0] != 0xff; {
+		chunk, next, err := cbor.ReadBytes(rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(next) > 2 && len(chunk) != 16<<10 {
+			t.Fatalf("unexpected chunk of %d bytes", len(chunk))
+		}
+		got = append(got, chunk...)
+		rest = next
+	}
+	if !bytes.Equal(got, blob) {
+		t.Fatal("byte string doesn't match")
+	}
+
+	// The content of chunked byte strings counts toward the MaxBytes limit
+	// of decoders.
+	if err := cbor.Unmarshal(data, &got); !errors.Is(err, cbor.ErrStringTooLong) {
+		t.Fatalf("expected string too long error, got %v", err)
+	}
+}
+
+func TestEncoder_ByteStringWriter_buffer(t *testing.T) {
+	enc := cbor.NewEncoderBuffer([]byte{0x01})
+	w := enc.ByteStringWriter()
+	if _, err := w.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := enc.Bytes(), []byte{0x01, 0x5f, 0x43, 'a', 'b', 'c', 0xff}; !bytes.Equal(got, want) {
+		t.Fatalf("expected % x, got % x", want, got)
+	}
+	var got []byte
+	if err := cbor.Unmarshal(enc.Bytes()[1:], &got); err != nil || string(got) != "abc" {
+		t.Fatalf("expected abc, got %q (err %v)", got, err)
+	}
+
+	// The byte string counts toward the output limit, and is removed if
+	// it exceeds it.
+	enc.SetMaxOutputBytes(100)
+	w = enc.ByteStringWriter()
+	if _, err := w.Write(make([]byte, 20000)); !errors.Is(err, cbor.ErrOutputTooLarge) {
+		t.Fatalf("expected output too large error, got %v", err)
+	}
+	if err := w.Close(); !errors.Is(err, cbor.ErrOutputTooLarge) {
+		t.Fatalf("expected output too large error, got %v", err)
+	}
+	if got := len(enc.Bytes()); got != 7 {
+		t.Fatalf("expected the buffer to be left as it was, got %d bytes", got)
+	}
+}
+
+func TestEncoder_TextStringWriter(t *testing.T) {
+	// Three-byte characters don't divide 16 KiB evenly, so each chunk
+	// has to be cut before the character which doesn't fit.
+	text := strings.Repeat("a€", 6000)
+
+	var out bytes.Buffer
+	enc := cbor.NewEncoder(&out)
+	w := enc.TextStringWriter()
+	for rest := text; len(rest) > 0; {
+		n := 1001
+		if n > len(rest) {
+			n = len(rest)
+		}
+		if _, err := w.Write([]byte(rest[:n])); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := out.Bytes()
+	if data[0] != 0x7f || data[len(data)-1] != 0xff {
+		t.Fatalf("unexpected encoding % x ... % x", data[:8], data[len(data)-8:])
+	}
+	var got strings.Builder
+	chunks := 0
+	for rest := data[1:]; rest[0] != 0xff; chunks++ {
+		chunk, next, err := cbor.ReadString(rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !utf8.ValidString(chunk) {
+			t.Fatalf("chunk %d isn't valid UTF-8", chunks)
+		}
+		got.WriteString(chunk)
+		rest = next
+	}
+	if chunks != 2 || got.String() != text {
+		t.Fatalf("text string doesn't match, got %d chunks", chunks)
+	}
+
+	// Chunks written directly from a single write are cut the same way.
+	var direct bytes.Buffer
+	enc = cbor.NewEncoder(&direct)
+	w = enc.TextStringWriter()
+	if _, err := w.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(direct.Bytes(), data) {
+		t.Fatal("encoding of a single write doesn't match")
+	}
+}
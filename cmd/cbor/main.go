@@ -0,0 +1,163 @@
+// Command cbor inspects, transcodes and validates CBOR data, using the
+// cbor package.
+//
+// Usage:
+//
+//	cbor diag [file]       print the diagnostic notation of CBOR items
+//	cbor json2cbor [file]  transcode JSON values to CBOR
+//	cbor cbor2json [file]  transcode CBOR items to JSON, one per line
+//	cbor validate [file]   check that CBOR items are valid
+//
+// Each command reads the named file, or the standard input if there isn't
+// one, and writes to the standard output. Input containing several items
+// is treated as a CBOR sequence (RFC 8742), or a stream of JSON values.
+//
+// The -x flag reads and writes CBOR as hex rather than binary, which is
+// convenient for pasting data from specifications and logs:
+//
+//	echo a16161820121 | cbor -x diag
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/picatz/cbor"
+)
+
+// usage is the usage message of the command.
+const usage = `usage: cbor [-x] command [file]
+
+commands:
+  diag       print the diagnostic notation of CBOR items
+  json2cbor  transcode JSON values to CBOR
+  cbor2json  transcode CBOR items to JSON, one per line
+  validate   check that CBOR items are valid
+
+flags:
+`
+
+// errUsage is returned by run for invalid arguments.
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if err != errUsage {
+			fmt.Fprintf(os.Stderr, "cbor: %v\n", strings.TrimPrefix(err.Error(), "cbor: "))
+			os.Exit(1)
+		}
+		os.Exit(2)
+	}
+}
+
+// run runs the command with the given arguments, input and output.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("cbor", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	hexMode := flags.Bool("x", false, "read and write CBOR as hex")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return errUsage
+	}
+
+	in := stdin
+	if flags.NArg() == 2 {
+		f, err := os.Open(flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	switch cmd := flags.Arg(0); cmd {
+	case "diag":
+		data, err := readCBOR(in, *hexMode)
+		if err != nil {
+			return err
+		}
+		diag, err := cbor.Diagnose(data)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, diag)
+		return err
+	case "json2cbor":
+		if !*hexMode {
+			return cbor.JSONToCBOR(stdout, in, nil)
+		}
+		var buf bytes.Buffer
+		if err := cbor.JSONToCBOR(&buf, in, nil); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(stdout, hex.EncodeToString(buf.Bytes()))
+		return err
+	case "cbor2json":
+		if !*hexMode {
+			return cbor.CBORToJSON(stdout, in)
+		}
+		data, err := readCBOR(in, true)
+		if err != nil {
+			return err
+		}
+		return cbor.CBORToJSON(stdout, bytes.NewReader(data))
+	case "validate":
+		data, err := readCBOR(in, *hexMode)
+		if err != nil {
+			return err
+		}
+		n, err := validate(data)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "ok: %d items, %d bytes\n", n, len(data))
+		return err
+	default:
+		fmt.Fprintf(stderr, "cbor: unknown command %q\n", cmd)
+		flags.Usage()
+		return errUsage
+	}
+}
+
+// readCBOR reads all of in, decoding it from hex if hexMode is set, in
+// which case whitespace is ignored.
+func readCBOR(in io.Reader, hexMode bool) ([]byte, error) {
+	data, err := io.ReadAll(in)
+	if err != nil || !hexMode {
+		return data, err
+	}
+	return hex.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+}
+
+// validate checks that data is a sequence of well-formed CBOR items which
+// can be decoded, within the limits in cbor.DefaultDecoderOptions, and
+// returns the number of items.
+func validate(data []byte) (int, error) {
+	n := 0
+	for rest := data; len(rest) > 0; n++ {
+		off := len(data) - len(rest)
+		next, err := cbor.Skip(rest)
+		if err != nil {
+			return n, fmt.Errorf("item %d at offset %d: %w", n, off, err)
+		}
+		var v interface{}
+		if err := cbor.Unmarshal(rest[:len(rest)-len(next)], &v); err != nil {
+			return n, fmt.Errorf("item %d at offset %d: %w", n, off, err)
+		}
+		rest = next
+	}
+	return n, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		want  string
+	}{
+		{"diag", []string{"-x", "diag"}, "a2 6161 820121 6162 5f4101ff\n", `{"a": [1, -2], "b": (_ h'01')}` + "\n"},
+		{"diag sequence", []string{"-x", "diag"}, "01 c11a514b67b0 f97e00", "1, 1(1363896240), NaN\n"},
+		{"json2cbor", []string{"-x", "json2cbor"}, `{"a": [1, -2]} true`, "a16161820121f5\n"},
+		{"cbor2json", []string{"-x", "cbor2json"}, "a16161820121 4101", "{\"a\":[1,-2]}\n\"AQ\"\n"},
+		{"validate", []string{"-x", "validate"}, "a16161820121 f6", "ok: 2 items, 7 bytes\n"},
+		{"binary", []string{"cbor2json"}, "\x82\x01\x02", "[1,2]\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := run(test.args, strings.NewReader(test.stdin), &stdout, &stderr); err != nil {
+				t.Fatalf("%v: %s", err, stderr.String())
+			}
+			if got := stdout.String(); got != test.want {
+				t.Fatalf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.json")
+	if err := os.WriteFile(path, []byte(`[1, "x"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	if err := run([]string{"json2cbor", path}, nil, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "\x82\x01\x61x"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		want  string
+	}{
+		{"truncated", []string{"-x", "validate"}, "01 8201", "item 1 at offset 1"},
+		{"undecodable", []string{"-x", "validate"}, "f6 a1a00101", "item 1 at offset 1"},
+		{"malformed diag", []string{"-x", "diag"}, "ff", "break"},
+		{"bad hex", []string{"-x", "diag"}, "zz", "invalid byte"},
+		{"unknown command", []string{"frobnicate"}, "", "invalid arguments"},
+		{"no command", nil, "", "invalid arguments"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := run(test.args, strings.NewReader(test.stdin), &bytes.Buffer{}, &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("expected error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/printer"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Kinds of field types supported by the generator, other than the basic
+// Go kinds (bool, string, intN, uintN, floatN).
+const (
+	kindBytes = "bytes" // []byte
+	kindSlice = "slice" // slice of a basic kind
+	kindMap   = "map"   // map, encoded through reflection
+	kindOther = "other" // anything else, encoded through reflection
+)
+
+// fieldType describes the type of a struct field.
+type fieldType struct {
+	// kind is the basic kind of the type, or one of kindBytes, kindSlice,
+	// or kindOther.
+	kind string
+
+	// name is the Go source for the type, such as "int" or "[]string".
+	name string
+
+	// elem is the element type of a kindSlice type.
+	elem *fieldType
+
+	// empty is how omitempty checks a kindOther type, like the cbor
+	// package: emptyNil for pointers and interfaces, emptyLen for arrays,
+	// or "" to call cbor.IsEmpty for types whose kind isn't known.
+	empty string
+}
+
+// Ways of checking whether a kindOther value is empty.
+const (
+	emptyNil = "nil"
+	emptyLen = "len"
+)
+
+// field is a struct field to be encoded as a map entry.
+type field struct {
+	name      string // Go field name
+	key       string // map key, as a string
+	keyAsInt  bool   // whether key is an integer
+	omitEmpty bool
+	typ       *fieldType
+}
+
+// generator generates MarshalCBOR and UnmarshalCBOR methods.
+type generator struct {
+	buf bytes.Buffer
+
+	fset *token.FileSet
+
+	// types are the type declarations in the package, used to resolve
+	// named types to their underlying types.
+	types map[string]*ast.TypeSpec
+
+	// needFmt is set when the generated code uses the fmt package.
+	needFmt bool
+
+	// needErrors is set when the generated code uses the errors package.
+	needErrors bool
+
+	// needStrings is set when the generated code uses the strings package.
+	needStrings bool
+}
+
+// printf writes formatted generated code.
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate returns the formatted source of a file with methods for the
+// given struct types.
+func (g *generator) generate(pkg string, names []string) ([]byte, error) {
+	var body generator
+	body.fset = g.fset
+	body.types = g.types
+
+	for _, name := range names {
+		spec, ok := g.types[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found", name)
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return nil, fmt.Errorf("type %s is not a struct", name)
+		}
+		fields, err := body.fields(name, st)
+		if err != nil {
+			return nil, err
+		}
+		body.marshal(name, fields)
+		body.unmarshal(name, fields)
+	}
+
+	g.printf("// Code generated by cborgen; DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", pkg)
+	g.printf("import (\n")
+	if body.needErrors {
+		g.printf("\t\"errors\"\n")
+	}
+	if body.needFmt {
+		g.printf("\t\"fmt\"\n")
+	}
+	if body.needStrings {
+		g.printf("\t\"strings\"\n")
+	}
+	g.printf("\n\t\"github.com/picatz/cbor\"\n")
+	g.printf(")\n")
+	g.buf.Write(body.buf.Bytes())
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, g.buf.Bytes())
+	}
+	return src, nil
+}
+
+// fields returns the encoded fields of a struct type, based on their cbor
+// struct tags.
+func (g *generator) fields(typeName string, st *ast.StructType) ([]field, error) {
+	next, auto, err := intKeysBase(typeName, st)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []field
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded fields are not supported", typeName)
+		}
+
+		var tag string
+		if f.Tag != nil {
+			raw, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid struct tag %s", typeName, f.Tag.Value)
+			}
+			tag = reflect.StructTag(raw).Get("cbor")
+		}
+		if tag == "-" {
+			continue
+		}
+
+		typ := g.resolve(f.Type)
+
+		for _, name := range f.Names {
+			if !name.IsExported() {
+				continue
+			}
+
+			fd := field{
+				name: name.Name,
+				key:  name.Name,
+				typ:  typ,
+			}
+
+			if strings.HasPrefix(tag, "'") {
+				return nil, fmt.Errorf("%s.%s: quoted keys are not supported", typeName, fd.name)
+			}
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				fd.key = parts[0]
+			} else if auto {
+				fd.key, fd.keyAsInt = strconv.FormatInt(next, 10), true
+			}
+			if auto {
+				next++
+			}
+			for _, opt := range parts[1:] {
+				switch opt {
+				case "keyasint":
+					fd.keyAsInt = true
+				case "omitempty":
+					fd.omitEmpty = true
+				case "omitzero", "toarray":
+					return nil, fmt.Errorf("%s.%s: option %q is not supported", typeName, fd.name, opt)
+				}
+			}
+
+			if fd.keyAsInt {
+				if _, err := strconv.ParseInt(fd.key, 10, 64); err != nil {
+					return nil, fmt.Errorf("%s.%s: invalid keyasint key %q", typeName, fd.name, fd.key)
+				}
+			}
+
+			fields = append(fields, fd)
+		}
+	}
+	return fields, nil
+}
+
+// intKeysBase returns the first automatic integer key of the fields of a
+// struct type, and whether it has a blank field with the intkeys option.
+func intKeysBase(typeName string, st *ast.StructType) (int64, bool, error) {
+	for _, f := range st.Fields.List {
+		if f.Tag == nil || len(f.Names) != 1 || f.Names[0].Name != "_" {
+			continue
+		}
+		raw, err := strconv.Unquote(f.Tag.Value)
+		if err != nil {
+			return 0, false, fmt.Errorf("%s: invalid struct tag %s", typeName, f.Tag.Value)
+		}
+		parts := strings.Split(reflect.StructTag(raw).Get("cbor"), ",")
+		for _, opt := range parts[1:] {
+			if opt == "toarray" {
+				return 0, false, fmt.Errorf("%s: option %q is not supported", typeName, opt)
+			}
+			if opt != "intkeys" {
+				continue
+			}
+			if parts[0] == "" {
+				return 0, true, nil
+			}
+			base, err := strconv.ParseInt(parts[0], 10, 64)
+			if err != nil {
+				return 0, false, fmt.Errorf("%s: invalid intkeys base %q", typeName, parts[0])
+			}
+			return base, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// basicKinds are the Go basic types supported without reflection, mapped
+// to their canonical kind.
+var basicKinds = map[string]string{
+	"bool":    "bool",
+	"string":  "string",
+	"int":     "int",
+	"int8":    "int8",
+	"int16":   "int16",
+	"int32":   "int32",
+	"rune":    "int32",
+	"int64":   "int64",
+	"uint":    "uint",
+	"uint8":   "uint8",
+	"byte":    "uint8",
+	"uint16":  "uint16",
+	"uint32":  "uint32",
+	"uint64":  "uint64",
+	"float32": "float32",
+	"float64": "float64",
+}
+
+// resolve returns the fieldType of a type expression.
+func (g *generator) resolve(expr ast.Expr) *fieldType {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, g.fset, expr)
+	name := buf.String()
+
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if kind, ok := basicKinds[t.Name]; ok {
+			return &fieldType{kind: kind, name: name}
+		}
+		// Named types declared in the package use the encoding of their
+		// underlying type, except structs which may have their own
+		// methods.
+		if spec, ok := g.types[t.Name]; ok && spec.Assign == 0 {
+			if _, isStruct := spec.Type.(*ast.StructType); !isStruct {
+				underlying := g.resolve(spec.Type)
+				if underlying.kind != kindMap {
+					return &fieldType{kind: underlying.kind, name: name, elem: underlying.elem, empty: underlying.empty}
+				}
+			}
+		}
+		if t.Name == "any" || t.Name == "error" {
+			return &fieldType{kind: kindOther, name: name, empty: emptyNil}
+		}
+	case *ast.StarExpr, *ast.InterfaceType:
+		return &fieldType{kind: kindOther, name: name, empty: emptyNil}
+	case *ast.MapType:
+		return &fieldType{kind: kindMap, name: name}
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return &fieldType{kind: kindOther, name: name, empty: emptyLen}
+		}
+		elem := g.resolve(t.Elt)
+		switch {
+		case elem.kind == "uint8":
+			return &fieldType{kind: kindBytes, name: name}
+		case basicKinds[elem.kind] != "":
+			return &fieldType{kind: kindSlice, name: name, elem: elem}
+		}
+	}
+	return &fieldType{kind: kindOther, name: name}
+}
+
+// zeroCheck returns an expression that is true if expr is empty for the
+// omitempty option, following the same rules as the cbor package.
+func (g *generator) zeroCheck(expr string, typ *fieldType) string {
+	switch typ.kind {
+	case "bool":
+		return "!" + expr
+	case "string":
+		return expr + ` == ""`
+	case kindBytes, kindSlice, kindMap:
+		return "len(" + expr + ") == 0"
+	case kindOther:
+		switch typ.empty {
+		case emptyNil:
+			return expr + " == nil"
+		case emptyLen:
+			return "len(" + expr + ") == 0"
+		}
+		// Structs are never empty, except nullable types such as
+		// cbor.Optional, which only the cbor package knows.
+		return "cbor.IsEmpty(&" + expr + ")"
+	default:
+		return expr + " == 0"
+	}
+}
+
+// convert returns expr converted to the Go type name, unless it's already
+// of that type.
+func convert(expr string, typ *fieldType, name string) string {
+	if typ.name == name {
+		return expr
+	}
+	return name + "(" + expr + ")"
+}
+
+// appendBasic returns a statement appending the encoding of expr to b.
+func appendBasic(expr string, typ *fieldType) string {
+	switch typ.kind {
+	case "bool":
+		return "b = cbor.AppendBool(b, " + convert(expr, typ, "bool") + ")"
+	case "string":
+		return "b = cbor.AppendString(b, " + convert(expr, typ, "string") + ")"
+	case "int", "int8", "int16", "int32", "int64":
+		return "b = cbor.AppendInt(b, " + convert(expr, typ, "int64") + ")"
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		return "b = cbor.AppendUint(b, " + convert(expr, typ, "uint64") + ")"
+	case "float32", "float64":
+		// Like the cbor package, floats are always encoded as float64.
+		return "b = cbor.AppendFloat64(b, " + convert(expr, typ, "float64") + ")"
+	case kindBytes:
+		return "b = cbor.AppendBytes(b, " + convert(expr, typ, "[]byte") + ")"
+	}
+	panic("cborgen: unexpected kind " + typ.kind)
+}
+
+// marshal generates the MarshalCBOR method of a struct type.
+func (g *generator) marshal(typeName string, fields []field) {
+	g.printf("\n// MarshalCBOR implements cbor.Marshaler.\n")
+	g.printf("func (x %s) MarshalCBOR() ([]byte, error) {\n", typeName)
+	g.printf("n := %d\n", len(fields))
+	for _, f := range fields {
+		if f.omitEmpty {
+			g.printf("if %s {\nn--\n}\n", g.zeroCheck("x."+f.name, f.typ))
+		}
+	}
+	g.printf("b := make([]byte, 0, %d)\n", 8+16*len(fields))
+	g.printf("b = cbor.AppendMapHeader(b, n)\n")
+
+	for _, f := range fields {
+		expr := "x." + f.name
+		if f.omitEmpty {
+			g.printf("if !(%s) {\n", g.zeroCheck(expr, f.typ))
+		}
+
+		g.printf("// %s\n", f.name)
+		if f.keyAsInt {
+			g.printf("b = cbor.AppendInt(b, %s)\n", f.key)
+		} else {
+			g.printf("b = cbor.AppendString(b, %q)\n", f.key)
+		}
+
+		switch f.typ.kind {
+		case kindSlice:
+			g.printf("b = cbor.AppendArrayHeader(b, len(%s))\n", expr)
+			g.printf("for _, v := range %s {\n%s\n}\n", expr, appendBasic("v", f.typ.elem))
+		case kindMap, kindOther:
+			g.printf("if v, err := cbor.Marshal(%s); err != nil {\n", expr)
+			g.printf("return nil, err\n} else {\nb = append(b, v...)\n}\n")
+		default:
+			g.printf("%s\n", appendBasic(expr, f.typ))
+		}
+
+		if f.omitEmpty {
+			g.printf("}\n")
+		}
+	}
+
+	g.printf("return b, nil\n}\n")
+}
+
+// readBasic generates statements that read a value of the given type from
+// b into the assignable expression target.
+func (g *generator) readBasic(target string, typ *fieldType) {
+	var readType string
+	switch typ.kind {
+	case "bool":
+		readType = "bool"
+		g.printf("var v bool\nif v, b, err = cbor.ReadBool(b); err != nil {\nreturn err\n}\n")
+	case "string":
+		readType = "string"
+		g.printf("var v string\nif v, b, err = cbor.ReadString(b); err != nil {\nreturn err\n}\n")
+	case "int", "int8", "int16", "int32", "int64":
+		readType = "int64"
+		g.printf("var v int64\nif v, b, err = cbor.ReadInt(b); err != nil {\nreturn err\n}\n")
+		if typ.kind != "int64" {
+			g.needErrors, g.needFmt, g.needStrings = true, true, true
+			g.printf("if int64(%s(v)) != v {\nreturn fmt.Errorf(\"cbor: integer %%d overflows %s\", v)\n}\n", typ.kind, typ.name)
+		}
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		readType = "uint64"
+		g.printf("var v uint64\nif v, b, err = cbor.ReadUint(b); err != nil {\nreturn err\n}\n")
+		if typ.kind != "uint64" {
+			g.needErrors, g.needFmt, g.needStrings = true, true, true
+			g.printf("if uint64(%s(v)) != v {\nreturn fmt.Errorf(\"cbor: integer %%d overflows %s\", v)\n}\n", typ.kind, typ.name)
+		}
+	case "float32", "float64":
+		readType = "float64"
+		g.printf("var v float64\nif v, b, err = cbor.ReadFloat64(b); err != nil {\nreturn err\n}\n")
+	case kindBytes:
+		readType = "[]byte"
+		g.printf("var v []byte\nif v, b, err = cbor.ReadBytes(b); err != nil {\nreturn err\n}\n")
+	default:
+		panic("cborgen: unexpected kind " + typ.kind)
+	}
+
+	if typ.name == readType {
+		g.printf("%s = v\n", target)
+	} else {
+		g.printf("%s = %s(v)\n", target, typ.name)
+	}
+}
+
+// readField generates statements that read the value of a field from b.
+func (g *generator) readField(f field) {
+	target := "x." + f.name
+
+	switch f.typ.kind {
+	case kindBytes, kindSlice:
+		// Null decodes to a nil slice.
+		g.printf("if len(b) > 0 && b[0] == 0xf6 {\n%s = nil\nb = b[1:]\n} else {\n", target)
+		if f.typ.kind == kindBytes {
+			g.readBasic(target, f.typ)
+		} else {
+			g.printf("var l int\nif l, b, err = cbor.ReadArrayHeader(b); err != nil {\nreturn err\n}\n")
+			g.printf("%s = make(%s, l)\n", target, f.typ.name)
+			g.printf("for k := range %s {\n", target)
+			g.readBasic(target+"[k]", f.typ.elem)
+			g.printf("}\n")
+		}
+		g.printf("}\n")
+	case kindMap, kindOther:
+		g.printf("rest, err := cbor.Skip(b)\nif err != nil {\nreturn err\n}\n")
+		g.printf("if err := cbor.Unmarshal(b[:len(b)-len(rest)], &%s); err != nil {\nreturn err\n}\n", target)
+		g.printf("b = rest\n")
+	default:
+		g.readBasic(target, f.typ)
+	}
+}
+
+// unmarshal generates the UnmarshalCBOR method of a struct type. It reads
+// the common encodings of the fields directly, and decodes anything else,
+// such as indefinite lengths, nulls, or keys of other types, with
+// cbor.Unmarshal, so the method accepts exactly what the cbor package does
+// without it.
+func (g *generator) unmarshal(typeName string, fields []field) {
+	g.needErrors, g.needFmt, g.needStrings = true, true, true
+
+	g.printf("\n// UnmarshalCBOR implements cbor.Unmarshaler.\n")
+	g.printf("func (x *%s) UnmarshalCBOR(b []byte) error {\n", typeName)
+	g.printf("orig := *x\nif x.unmarshalCBOR(b) == nil {\nreturn nil\n}\n")
+	g.printf("// Decode what unmarshalCBOR doesn't handle with reflection, which\n")
+	g.printf("// also reports the errors, through a type without these methods.\n")
+	g.printf("type plain %s\n*x = orig\nerr := cbor.Unmarshal(b, (*plain)(x))\n", typeName)
+	g.printf("// Errors name %s rather than plain, the type decoded into.\n", typeName)
+	g.printf("var de *cbor.DecodeError\n")
+	g.printf("if errors.As(err, &de) && strings.HasPrefix(de.Path, \"plain\") {\n")
+	g.printf("de.Path = %q + de.Path[len(\"plain\"):]\n}\nreturn err\n}\n", typeName)
+
+	// Like the cbor package, keys match the field with the same key,
+	// whatever their type, keeping the last field of a duplicate key.
+	var byKey []field
+	seen := make(map[string]int)
+	for _, f := range fields {
+		if i, ok := seen[f.key]; ok {
+			byKey[i] = f
+			continue
+		}
+		seen[f.key] = len(byKey)
+		byKey = append(byKey, f)
+	}
+
+	// Integer keys match the fields whose key is the decimal integer.
+	var intFields []field
+	var intKeys, foldKeys []string
+	isInt := make(map[string]bool)
+	for _, f := range byKey {
+		if n, err := strconv.ParseInt(f.key, 10, 64); err == nil && strconv.FormatInt(n, 10) == f.key {
+			intFields = append(intFields, f)
+			intKeys = append(intKeys, strconv.Quote(f.key))
+			isInt[f.key] = true
+		}
+		if strings.ToLower(f.key) != strings.ToUpper(f.key) {
+			foldKeys = append(foldKeys, f.key)
+		}
+	}
+
+	g.printf("\n// unmarshalCBOR decodes the common encodings of %s, returning an\n", typeName)
+	g.printf("// error for anything else.\n")
+	g.printf("func (x *%s) unmarshalCBOR(b []byte) error {\n", typeName)
+	g.printf("n, b, err := cbor.ReadMapHeader(b)\nif err != nil {\nreturn err\n}\n")
+	g.printf("for i := 0; i < n; i++ {\n")
+	g.printf("mt, err := cbor.NextType(b)\nif err != nil {\nreturn err\n}\n")
+	g.printf("switch mt {\n")
+
+	g.printf("case cbor.MajorTypeUnsignedInt, cbor.MajorTypeNegativeInt:\n")
+	if len(intFields) > 0 {
+		g.printf("var key int64\nif key, b, err = cbor.ReadInt(b); err != nil {\nreturn err\n}\n")
+		g.printf("switch key {\n")
+		for _, f := range intFields {
+			g.printf("case %s:\n", f.key)
+			g.readField(f)
+		}
+		g.printf("default:\nif b, err = cbor.Skip(b); err != nil {\nreturn err\n}\n}\n")
+	} else {
+		g.printf("for j := 0; j < 2; j++ {\nif b, err = cbor.Skip(b); err != nil {\nreturn err\n}\n}\n")
+	}
+
+	g.printf("case cbor.MajorTypeTextString:\n")
+	g.printf("var key string\nif key, b, err = cbor.ReadString(b); err != nil {\nreturn err\n}\n")
+	g.printf("switch key {\n")
+	if len(intKeys) > 0 {
+		// Text keys of integer fields are rare enough to be left to the
+		// cbor package.
+		g.printf("case %s:\n", strings.Join(intKeys, ", "))
+		g.printf("return fmt.Errorf(\"cbor: text key %%q of an integer field\", key)\n")
+	}
+	for _, f := range byKey {
+		if isInt[f.key] {
+			continue
+		}
+		g.printf("case %q:\n", f.key)
+		g.readField(f)
+	}
+	g.printf("default:\n")
+	if len(foldKeys) > 0 {
+		// Keys matching a field case-insensitively are left to the cbor
+		// package, which prefers the first such field.
+		g.needStrings = true
+		conds := make([]string, len(foldKeys))
+		for i, k := range foldKeys {
+			conds[i] = fmt.Sprintf("strings.EqualFold(key, %q)", k)
+		}
+		g.printf("if %s {\n", strings.Join(conds, " || "))
+		g.printf("return fmt.Errorf(\"cbor: key %%q only matches a field case-insensitively\", key)\n}\n")
+	}
+	g.printf("if b, err = cbor.Skip(b); err != nil {\nreturn err\n}\n}\n")
+
+	g.printf("default:\n// Keys of other types are formatted to match fields by the cbor\n// package.\n")
+	g.printf("return fmt.Errorf(\"cbor: unexpected key of major type %%d\", mt)\n")
+	g.printf("}\n}\nreturn nil\n}\n")
+}
@@ -0,0 +1,35 @@
+// Package example contains types with methods generated by cborgen, used to
+// test the generator and benchmark the generated code.
+package example
+
+//go:generate go run github.com/picatz/cbor/cmd/cborgen
+
+// Claims is a CWT claims set (RFC 8392) with a few private claims.
+//
+//cborgen:generate
+type Claims struct {
+	Issuer     string `cbor:"1,keyasint,omitempty"`
+	Subject    string `cbor:"2,keyasint,omitempty"`
+	Audience   string `cbor:"3,keyasint,omitempty"`
+	Expiration int64  `cbor:"4,keyasint,omitempty"`
+	NotBefore  int64  `cbor:"5,keyasint,omitempty"`
+	IssuedAt   int64  `cbor:"6,keyasint,omitempty"`
+	CWTID      []byte `cbor:"7,keyasint,omitempty"`
+
+	Scopes   []string          `cbor:"scope,omitempty"`
+	Level    Level             `cbor:"level,omitempty"`
+	Metadata map[string]string `cbor:"meta,omitempty"`
+
+	// Session is not encoded.
+	Session string `cbor:"-"`
+}
+
+// Level is an access level.
+type Level uint8
+
+// Access levels.
+const (
+	LevelNone Level = iota
+	LevelRead
+	LevelWrite
+)
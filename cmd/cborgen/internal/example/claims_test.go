@@ -0,0 +1,216 @@
+package example_test
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/picatz/cbor"
+	"github.com/picatz/cbor/cmd/cborgen/internal/example"
+)
+
+// Data from https://tools.ietf.org/html/rfc8392#appendix-A section A.1
+//
+// {1: "coap://as.example.com", 2: "erikw", 3: "coap://light.example.com", 4: 1444064944, 5: 1443944944, 6: 1443944944, 7: h'0B71'}
+const rfc8392Claims = "a70175636f61703a2f2f61732e6578616d706c652e636f6d02656572696b77037818636f61703a2f2f6c696768742e6578616d706c652e636f6d041a5612aeb0051a5610d9f0061a5610d9f007420b71"
+
+func TestClaimsMarshalRFC8392(t *testing.T) {
+	c := example.Claims{
+		Issuer:     "coap://as.example.com",
+		Subject:    "erikw",
+		Audience:   "coap://light.example.com",
+		Expiration: 1444064944,
+		NotBefore:  1443944944,
+		IssuedAt:   1443944944,
+		CWTID:      []byte{0x0b, 0x71},
+		Session:    "not encoded",
+	}
+
+	b, err := cbor.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := hex.EncodeToString(b); got != rfc8392Claims {
+		t.Fatalf("got %s, want %s", got, rfc8392Claims)
+	}
+}
+
+func TestClaimsUnmarshalRFC8392(t *testing.T) {
+	data, err := hex.DecodeString(rfc8392Claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c example.Claims
+	if err := cbor.Unmarshal(data, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	want := example.Claims{
+		Issuer:     "coap://as.example.com",
+		Subject:    "erikw",
+		Audience:   "coap://light.example.com",
+		Expiration: 1444064944,
+		NotBefore:  1443944944,
+		IssuedAt:   1443944944,
+		CWTID:      []byte{0x0b, 0x71},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Fatalf("got %+v, want %+v", c, want)
+	}
+}
+
+func TestClaimsRoundTrip(t *testing.T) {
+	c := example.Claims{
+		Issuer:     "issuer",
+		Expiration: -1,
+		Scopes:     []string{"read", "write"},
+		Level:      example.LevelWrite,
+		Metadata:   map[string]string{"region": "us"},
+	}
+
+	b, err := c.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got example.Claims
+	if err := got.UnmarshalCBOR(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Fatalf("got %+v, want %+v", got, c)
+	}
+}
+
+func TestClaimsUnmarshalUnknownKeys(t *testing.T) {
+	// {1: "a", -70000: [1, 2], "x": {"y": 1}, h'00': 1, "level": 2}
+	data, err := hex.DecodeString("a50161613a0001116f8201026178a1617901410001656c6576656c02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c example.Claims
+	if err := c.UnmarshalCBOR(data); err != nil {
+		t.Fatal(err)
+	}
+	if c.Issuer != "a" || c.Level != example.LevelWrite {
+		t.Fatalf("unexpected claims: %+v", c)
+	}
+}
+
+func TestClaimsUnmarshalErrors(t *testing.T) {
+	tests := map[string]string{
+		"not a map":      "8101",
+		"truncated":      "a2016161",
+		"wrong type":     "a10101",
+		"level overflow": "a1656c6576656c190100",
+	}
+
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := hex.DecodeString(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var c example.Claims
+			if err := c.UnmarshalCBOR(data); err == nil {
+				t.Fatalf("expected error, got %+v", c)
+			}
+		})
+	}
+}
+
+// plainClaims has the fields of example.Claims without its generated
+// methods, so it's decoded by reflection.
+type plainClaims example.Claims
+
+func TestClaimsUnmarshalMatchesReflection(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		ok   bool
+	}{
+		{"rfc8392", rfc8392Claims, true},
+		{"unknown keys", "a50161613a0001116f8201026178a1617901410001656c6576656c02", true},
+		{"indefinite map", "bf0163697373ff", true},
+		{"null", "a201f6026161", true},
+		{"null slice", "a16573636f7065f6", true},
+		{"indefinite array", "a16573636f70659f6161ff", true},
+		{"indefinite bytes", "a1075f41014102ff", true},
+		{"huge unsigned key", "a21bffffffffffffffff01016161", true},
+		{"huge negative key", "a23bffffffffffffffff01016161", true},
+		{"text key of int", "a161316161", true},
+		{"case-insensitive key", "a1654c6576656c02", true},
+		{"go name key", "a1664973737565726161", true},
+		{"byte string key", "a1456c6576656c02", true},
+		{"float key", "a1f93c006161", true},
+		{"duplicate keys", "a201616101616202", true},
+		{"float into int", "a104fb41d584abac200000", false},
+		{"wrong type", "a10101", false},
+		{"level overflow", "a1656c6576656c190100", false},
+		{"truncated", "a2016161", false},
+		{"not a map", "8101", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := hex.DecodeString(test.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			initial := example.Claims{Subject: "s", Scopes: []string{"x"}}
+			got, want := initial, plainClaims(initial)
+			gotErr := cbor.Unmarshal(data, &got)
+			wantErr := cbor.Unmarshal(data, &want)
+			if (gotErr == nil) != (wantErr == nil) {
+				t.Fatalf("got error %v, reflection got %v", gotErr, wantErr)
+			}
+			if (gotErr == nil) != test.ok {
+				t.Fatalf("unexpected error %v", gotErr)
+			}
+			if wantErr == nil && !reflect.DeepEqual(got, example.Claims(want)) {
+				t.Fatalf("got %+v, reflection got %+v", got, want)
+			}
+		})
+	}
+}
+
+func BenchmarkClaimsMarshal(b *testing.B) {
+	data, err := hex.DecodeString(rfc8392Claims)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var c example.Claims
+	if err := c.UnmarshalCBOR(data); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.MarshalCBOR(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClaimsUnmarshal(b *testing.B) {
+	data, err := hex.DecodeString(rfc8392Claims)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var c example.Claims
+		if err := c.UnmarshalCBOR(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package example
+
+import (
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+// Record has fields of the kinds which the generator encodes through
+// reflection or checks specially for omitempty.
+//
+//cborgen:generate
+type Record struct {
+	Ratio    float32               `cbor:"ratio,omitempty"`
+	Ratios   []float32             `cbor:"ratios,omitempty"`
+	Parent   *Record               `cbor:"parent,omitempty"`
+	Value    interface{}           `cbor:"value,omitempty"`
+	Err      error                 `cbor:"err,omitempty"`
+	At       time.Time             `cbor:"at,omitempty"`
+	Digest   [4]byte               `cbor:"digest,omitempty"`
+	Empty    [0]int                `cbor:"empty,omitempty"`
+	Position Position              `cbor:"pos,omitempty"`
+	Note     cbor.Optional[string] `cbor:"note,omitempty"`
+}
+
+// Position is a point in a plane.
+type Position struct {
+	X, Y int
+}
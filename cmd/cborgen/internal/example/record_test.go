@@ -0,0 +1,82 @@
+package example_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/picatz/cbor"
+	"github.com/picatz/cbor/cmd/cborgen/internal/example"
+)
+
+// plainRecord has the fields of example.Record without its generated
+// methods, so it's encoded by reflection.
+type plainRecord example.Record
+
+func TestRecordMarshalMatchesReflection(t *testing.T) {
+	zero := 0
+	tests := map[string]example.Record{
+		"zero":        {},
+		"float32":     {Ratio: 1.5, Ratios: []float32{0.25, 3}},
+		"pointer":     {Parent: &example.Record{Ratio: 2}},
+		"interface 0": {Value: 0},
+		"interface":   {Value: &zero},
+		"zero time":   {At: time.Time{}},
+		"time":        {At: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		"zero array":  {Digest: [4]byte{}},
+		"zero struct": {Position: example.Position{}},
+		"struct":      {Position: example.Position{X: 1}},
+		"absent":      {Note: cbor.Optional[string]{}},
+		"present":     {Note: cbor.Some("")},
+		"every field": {Ratio: 1, Value: "v", Digest: [4]byte{1}, Position: example.Position{Y: -1}, Note: cbor.Some("n")},
+	}
+
+	for name, r := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := r.MarshalCBOR()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := cbor.Marshal(plainRecord(r))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("got %x, reflection got %x", got, want)
+			}
+		})
+	}
+}
+
+func TestClaimsMarshalMatchesReflection(t *testing.T) {
+	for _, c := range []example.Claims{
+		{},
+		{Issuer: "i", Expiration: -1, CWTID: []byte{}, Scopes: []string{}},
+		{Scopes: []string{"a"}, Level: example.LevelRead, Metadata: map[string]string{"k": "v"}, Session: "s"},
+	} {
+		got, err := c.MarshalCBOR()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := cbor.Marshal(plainClaims(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%+v: got %x, reflection got %x", c, got, want)
+		}
+	}
+}
+
+func TestClaimsUnmarshalErrorPath(t *testing.T) {
+	// {"scope": [_ 1]}
+	data := []byte{0xa1, 0x65, 's', 'c', 'o', 'p', 'e', 0x9f, 0x01, 0xff}
+
+	var c example.Claims
+	err := c.UnmarshalCBOR(data)
+	var de *cbor.DecodeError
+	if !errors.As(err, &de) || de.Path != "Claims.Scopes[0]" {
+		t.Fatalf("expected an error at Claims.Scopes[0], got %v", err)
+	}
+}
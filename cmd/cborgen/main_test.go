@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGolden checks that the generated code in internal/example is up to
+// date with the generator.
+func TestGolden(t *testing.T) {
+	dir := filepath.Join("internal", "example")
+
+	got, names, err := generateDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "Claims" || names[1] != "Record" {
+		t.Fatalf("unexpected annotated types %v", names)
+	}
+
+	want, err := os.ReadFile(filepath.Join(dir, "claims_cbor.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("generated code is out of date, run go generate in %s", dir)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := map[string]string{
+		"not a struct": "package p\n\ntype T int\n",
+		"embedded":     "package p\n\ntype E struct{}\n\ntype T struct {\n\tE\n}\n",
+		"bad int key":  "package p\n\ntype T struct {\n\tA int `cbor:\"a,keyasint\"`\n}\n",
+		"bad intkeys":  "package p\n\ntype T struct {\n\t_ struct{} `cbor:\"a,intkeys\"`\n\tA int\n}\n",
+	}
+
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := generateDir(dir, []string{"T"}); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
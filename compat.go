@@ -0,0 +1,51 @@
+package cbor
+
+// FxamackerEncoderOptions are encoder options which match the defaults of
+// github.com/fxamacker/cbor/v2, so programs migrating from it, or using
+// both packages, encode the same data:
+//
+//   - nil slices and maps are encoded as null,
+//   - time.Time values are encoded as integer seconds without a tag, and
+//     zero times as null,
+//   - big.Int values which fit in 64 bits are encoded as integers.
+//
+// Struct tags use the same options, "keyasint", "omitempty", "omitzero"
+// and "toarray", so structs don't need to be tagged again, and fields
+// without a cbor tag use their json tag, as with fxamacker/cbor. Maps are
+// encoded in the iteration order of Go maps by both packages, unless they
+// are sorted by the caller. Unlike with fxamacker/cbor, the fields of
+// embedded structs aren't promoted, and are encoded in a map under the
+// name of the embedded type.
+//
+// The options can be used for an encoder with SetOptions, or for Marshal
+// by assigning them to DefaultEncoderOptions.
+var FxamackerEncoderOptions = EncoderOptions{
+	DurationMode:  DurationNanoseconds,
+	ByteSliceMode: ByteSliceBytes,
+	BigIntMode:    BigIntShrink,
+	TimeMode:      TimeUnix,
+	NilContainers: NilContainerAsNull,
+	JSONTags:      true,
+}
+
+// FxamackerDecoderOptions are decoder options which match the default
+// limits of github.com/fxamacker/cbor/v2, of 131072 elements in arrays
+// and pairs in maps, and 32 levels of nesting, and which use the json tags
+// of fields without a cbor tag.
+//
+// Strings aren't limited by fxamacker/cbor, which decodes from memory, so
+// their length is bounded by the input. They are limited to 16 MiB here,
+// since decoders reading from streams allocate the space of a string
+// before reading it.
+//
+// The options can be used for a decoder with SetOptions, or for Unmarshal
+// by assigning them to DefaultDecoderOptions.
+var FxamackerDecoderOptions = DecoderOptions{
+	MaxArrayElements: 131072,
+	MaxMapPairs:      131072,
+	MaxStringBytes:   16 << 20,
+	MaxBytes:         16 << 20,
+	MaxDepth:         32,
+	ErrorContext:     DefaultErrorContext,
+	JSONTags:         true,
+}
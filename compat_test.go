@@ -0,0 +1,114 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+// fxamackerRecord is tagged as it would be for github.com/fxamacker/cbor.
+type fxamackerRecord struct {
+	ID      int            `cbor:"1,keyasint"`
+	Tags    []string       `cbor:"2,keyasint"`
+	Note    string         `cbor:"3,keyasint,omitempty"`
+	Created time.Time      `cbor:"4,keyasint"`
+	Size    *big.Int       `cbor:"5,keyasint"`
+	Point   fxamackerPoint `cbor:"6,keyasint"`
+	Skipped int            `cbor:"-"`
+}
+
+type fxamackerPoint struct {
+	_    struct{} `cbor:",toarray"`
+	X, Y int
+}
+
+func TestFxamackerOptions(t *testing.T) {
+	if err := cbor.CheckStructTags(fxamackerRecord{}); err != nil {
+		t.Fatal(err)
+	}
+
+	v := fxamackerRecord{
+		ID:      1,
+		Created: time.Unix(1700000000, 5e8),
+		Size:    big.NewInt(7),
+		Point:   fxamackerPoint{X: 1, Y: 2},
+	}
+	var buf bytes.Buffer
+	enc := cbor.NewEncoder(&buf)
+	enc.SetOptions(cbor.FxamackerEncoderOptions)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	want := "a5" + "0101" + "02f6" + "041a6553f100" + "0507" + "06820102"
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	var got fxamackerRecord
+	dec := cbor.NewDecoder(&buf)
+	dec.SetOptions(cbor.FxamackerDecoderOptions)
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 1 || got.Tags != nil || got.Created.Unix() != 1700000000 || got.Size.Int64() != 7 || got.Point.Y != 2 {
+		t.Fatalf("unexpected decoded value %+v", got)
+	}
+
+	// The options of the decoder don't change the defaults.
+	if cbor.DefaultDecoderOptions.MaxDepth == cbor.FxamackerDecoderOptions.MaxDepth {
+		t.Fatal("expected SetOptions to leave the default options unchanged")
+	}
+}
+
+func TestEncoder_SetTimeMode(t *testing.T) {
+	tm := time.Unix(1, 5e8)
+	tests := []struct {
+		mode cbor.TimeMode
+		tag  bool
+		t    time.Time
+		want string
+	}{
+		{cbor.TimeUnix, false, tm, "01"},
+		{cbor.TimeUnix, true, tm, "c101"},
+		{cbor.TimeUnixMicro, false, tm, "fb3ff8000000000000"},
+		{cbor.TimeUnixDynamic, true, tm, "c1fb3ff8000000000000"},
+		{cbor.TimeUnixDynamic, false, time.Unix(2, 0), "02"},
+		{cbor.TimeUnix, true, time.Time{}, "f6"},
+	}
+	for _, test := range tests {
+		enc := cbor.NewEncoderBuffer(nil)
+		enc.SetTimeMode(test.mode, test.tag)
+		if err := enc.Encode(test.t); err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(enc.Bytes()); got != test.want {
+			t.Errorf("mode %d, tag %v: expected %s, got %s", test.mode, test.tag, test.want, got)
+		}
+	}
+}
+
+func TestEncoder_SetNilContainers(t *testing.T) {
+	v := struct {
+		S []int
+		B []byte
+		M map[string]int
+		E []int
+	}{E: []int{}}
+
+	enc := cbor.NewEncoderBuffer(nil)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	enc.SetNilContainers(cbor.NilContainerAsNull)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	want := "a4615380614240614da0614580" + "a46153f66142f6614df6614580"
+	if got := hex.EncodeToString(enc.Bytes()); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,232 @@
+package cbor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// containerFrame is an array or map being decoded by decodeContainer.
+type containerFrame struct {
+	// array is the decoded elements of an array, or nil for maps.
+	array []interface{}
+
+	// m is the decoded pairs of a map, or nil for arrays, and sm is used
+	// instead of m when the decoder's StringKeys option is set.
+	m  map[interface{}]interface{}
+	sm map[string]interface{}
+
+	// remaining is the number of elements or pairs left to decode, unless
+	// the container is indefinite-length and terminated by a break.
+	remaining  uint64
+	indefinite bool
+
+	// pairs is the number of pairs of a map decoded so far, counting those
+	// whose keys repeat, which the limit on the pairs applies to.
+	pairs int
+
+	// key is the decoded key of the pair being decoded, if hasKey is set.
+	key    interface{}
+	hasKey bool
+}
+
+// isArray reports whether the container is an array.
+func (f *containerFrame) isArray() bool {
+	return f.m == nil && f.sm == nil
+}
+
+// value returns the decoded container.
+func (f *containerFrame) value() interface{} {
+	switch {
+	case f.m != nil:
+		return f.m
+	case f.sm != nil:
+		return f.sm
+	}
+	return f.array
+}
+
+// decodeContainer decodes the array or map whose initial byte b has
+// already been read into the given reflect.Value, which must be an
+// interface. Arrays and maps are decoded into slices, arrays, maps and
+// structs by their compiled decoders.
+//
+// Nested arrays and maps are decoded with an explicit stack of frames
+// rather than by recursion, so adversarial inputs nested up to the
+// decoder's MaxDepth don't grow the goroutine stack.
+func (dec *Decoder) decodeContainer(rv reflect.Value, b byte) error {
+	if rv.Kind() != reflect.Interface {
+		return typeError(MajorType(b>>5).String(), rv.Type())
+	}
+
+	var stack []containerFrame
+	push := func(b byte) error {
+		if dec.depth+len(stack) >= dec.options.MaxDepth {
+			return dec.limitExceeded(ErrMaxDepth)
+		}
+		ai := b & 0x1f
+		read := dec.readMapLength
+		if MajorType(b>>5) == MajorTypeArray {
+			read = dec.readArrayLength
+		}
+		n, err := read(ai)
+		if err != nil {
+			return err
+		}
+		f := containerFrame{remaining: n, indefinite: ai == 31}
+		if MajorType(b>>5) == MajorTypeArray {
+			f.array = dec.makeInterfaces(dec.allocHint(n, 1))
+		} else if dec.options.StringKeys {
+			f.sm = make(map[string]interface{})
+		} else {
+			f.m = make(map[interface{}]interface{})
+		}
+		stack = append(stack, f)
+		return nil
+	}
+
+	// add adds a decoded item to the container on top of the stack.
+	add := func(v interface{}) error {
+		f := &stack[len(stack)-1]
+		if f.isArray() {
+			if f.indefinite && len(f.array) >= dec.options.MaxArrayElements {
+				return dec.limitExceeded(ErrArrayTooLong)
+			}
+			f.array = append(f.array, v)
+			f.remaining--
+			return nil
+		}
+		if !f.hasKey {
+			if f.indefinite && f.pairs >= dec.options.MaxMapPairs {
+				return dec.limitExceeded(ErrMapTooLong)
+			}
+			if f.sm != nil {
+				k, err := stringKey(v)
+				if err != nil {
+					return err
+				}
+				v = k
+			} else {
+				k, err := hashableKey(v)
+				if err != nil {
+					return err
+				}
+				v = k
+			}
+			f.key, f.hasKey = v, true
+			return nil
+		}
+		if f.sm != nil {
+			f.sm[f.key.(string)] = v
+		} else {
+			f.m[f.key] = v
+		}
+		f.key, f.hasKey = nil, false
+		f.pairs++
+		f.remaining--
+		return nil
+	}
+
+	// pathError returns err with the path to the item being decoded from
+	// the containers on the stack, one element per container.
+	pathError := func(err error) error {
+		err = dec.pathError(err, "")
+		for i := len(stack) - 1; i >= 0; i-- {
+			switch f := &stack[i]; {
+			case f.isArray():
+				err = dec.pathError(err, indexPath(len(f.array)))
+			case f.hasKey:
+				err = dec.pathError(err, keyPath(f.key))
+			}
+		}
+		return err
+	}
+
+	if err := push(b); err != nil {
+		return err
+	}
+	for {
+		f := &stack[len(stack)-1]
+
+		done := !f.indefinite && f.remaining == 0
+		if !done {
+			c, err := dec.readByte()
+			if err != nil {
+				return pathError(unexpectedEOF(err))
+			}
+			switch {
+			case c == 0xff:
+				if !f.indefinite {
+					return pathError(dec.syntaxError("unexpected break code"))
+				}
+				if f.hasKey {
+					return newError(ErrMalformed, "cbor: map is missing a value")
+				}
+				done = true
+			case MajorType(c>>5) == MajorTypeArray || MajorType(c>>5) == MajorTypeMap:
+				if err := push(c); err != nil {
+					return pathError(err)
+				}
+				continue
+			default:
+				// Other items, including tags, are decoded by the usual
+				// decoders, which see the depth of the enclosing containers.
+				var v interface{}
+				dec.depth += len(stack)
+				err := dec.decodeItem(reflect.ValueOf(&v).Elem(), c)
+				dec.depth -= len(stack)
+				if err != nil {
+					return pathError(err)
+				}
+				if err := add(v); err != nil {
+					return pathError(err)
+				}
+				continue
+			}
+		}
+
+		// The container on top of the stack is complete, so it's added
+		// to its parent, or returned if it's the outermost one.
+		v := f.value()
+		stack = stack[:len(stack)-1]
+		if len(stack) == 0 {
+			rv.Set(reflect.ValueOf(v))
+			return nil
+		}
+		if err := add(v); err != nil {
+			return pathError(err)
+		}
+	}
+}
+
+// stringKey returns the string a decoded map key is coerced to when the
+// decoder's StringKeys option is set: integers and floats are formatted
+// in decimal, byte strings are encoded in base64url without padding, and
+// booleans and null are written as in JSON.
+func stringKey(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return base64.RawURLEncoding.EncodeToString(v), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "null", nil
+	case *big.Int:
+		return v.String(), nil
+	}
+	return "", newError(ErrInvalidType, fmt.Sprintf("cbor: cannot convert map key of type %T to a string", v))
+}
@@ -0,0 +1,178 @@
+// Package cose implements CBOR Object Signing and Encryption (COSE) messages
+// on top of the cbor package.
+//
+// COSE is defined in RFC 9052, and the algorithms it uses in RFC 9053.
+//
+// https://www.rfc-editor.org/rfc/rfc9052.html
+package cose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/picatz/cbor"
+)
+
+var (
+	// ErrUnsupportedAlgorithm is returned when a message uses an algorithm
+	// that is not supported by this package, or that doesn't match the
+	// given key.
+	ErrUnsupportedAlgorithm = errors.New("cose: unsupported algorithm")
+
+	// ErrVerification is returned when a signature fails to verify.
+	ErrVerification = errors.New("cose: verification error")
+)
+
+// Algorithm is a COSE algorithm identifier.
+//
+// https://www.iana.org/assignments/cose/cose.xhtml#algorithms
+type Algorithm int64
+
+// Signature algorithms defined in RFC 9053.
+const (
+	// AlgorithmES256 is ECDSA w/ SHA-256.
+	AlgorithmES256 Algorithm = -7
+
+	// AlgorithmES384 is ECDSA w/ SHA-384.
+	AlgorithmES384 Algorithm = -35
+
+	// AlgorithmES512 is ECDSA w/ SHA-512.
+	AlgorithmES512 Algorithm = -36
+
+	// AlgorithmEdDSA is EdDSA, which is used with Ed25519 keys.
+	AlgorithmEdDSA Algorithm = -8
+)
+
+// String returns the IANA name of the algorithm.
+func (a Algorithm) String() string {
+	switch a {
+	case AlgorithmES256:
+		return "ES256"
+	case AlgorithmES384:
+		return "ES384"
+	case AlgorithmES512:
+		return "ES512"
+	case AlgorithmEdDSA:
+		return "EdDSA"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int64(a))
+	}
+}
+
+// hash returns the hash function used by the algorithm, or 0 if the
+// algorithm signs the message directly.
+func (a Algorithm) hash() (crypto.Hash, error) {
+	switch a {
+	case AlgorithmES256:
+		return crypto.SHA256, nil
+	case AlgorithmES384:
+		return crypto.SHA384, nil
+	case AlgorithmES512:
+		return crypto.SHA512, nil
+	case AlgorithmEdDSA:
+		return 0, nil
+	default:
+		return 0, ErrUnsupportedAlgorithm
+	}
+}
+
+// algorithmForKey returns the default algorithm for the given public key.
+func algorithmForKey(pub crypto.PublicKey) (Algorithm, error) {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return AlgorithmES256, nil
+		case elliptic.P384():
+			return AlgorithmES384, nil
+		case elliptic.P521():
+			return AlgorithmES512, nil
+		}
+	case ed25519.PublicKey:
+		return AlgorithmEdDSA, nil
+	}
+	return 0, ErrUnsupportedAlgorithm
+}
+
+// Common header parameter labels defined in RFC 9052, section 3.1.
+const (
+	HeaderLabelAlgorithm   int64 = 1
+	HeaderLabelCritical    int64 = 2
+	HeaderLabelContentType int64 = 3
+	HeaderLabelKeyID       int64 = 4
+	HeaderLabelIV          int64 = 5
+	HeaderLabelPartialIV   int64 = 6
+)
+
+// Headers is a map of COSE header parameters.
+//
+// Integer labels are always represented as int64 values, such as
+// HeaderLabelAlgorithm, and text labels as strings.
+type Headers map[interface{}]interface{}
+
+// Algorithm returns the value of the algorithm header parameter.
+func (h Headers) Algorithm() (Algorithm, bool) {
+	if alg, ok := h[HeaderLabelAlgorithm].(Algorithm); ok {
+		return alg, true
+	}
+	n, ok := toInt64(h[HeaderLabelAlgorithm])
+	return Algorithm(n), ok
+}
+
+// KeyID returns the value of the key identifier header parameter.
+func (h Headers) KeyID() ([]byte, bool) {
+	kid, ok := h[HeaderLabelKeyID].([]byte)
+	return kid, ok
+}
+
+// marshalProtected returns the serialized form of protected headers, which
+// is a zero-length byte string when there are no headers.
+func (h Headers) marshalProtected() ([]byte, error) {
+	if len(h) == 0 {
+		return []byte{}, nil
+	}
+	return cbor.Marshal(map[interface{}]interface{}(h))
+}
+
+// unmarshalProtected decodes the serialized form of protected headers.
+func unmarshalProtected(data []byte) (Headers, error) {
+	if len(data) == 0 {
+		return Headers{}, nil
+	}
+
+	var v interface{}
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("cose: invalid protected headers: %w", err)
+	}
+	return toHeaders(v)
+}
+
+// toHeaders converts a decoded CBOR map into Headers, normalizing integer
+// labels to int64.
+func toHeaders(v interface{}) (Headers, error) {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cose: invalid headers type %T", v)
+	}
+
+	h := make(Headers, len(m))
+	for k, v := range m {
+		switch k := k.(type) {
+		case uint64:
+			if k > math.MaxInt64 {
+				return nil, fmt.Errorf("cose: header label %d is out of range", k)
+			}
+			h[int64(k)] = v
+		case int64, string:
+			h[k] = v
+		default:
+			return nil, fmt.Errorf("cose: invalid header label type %T", k)
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,235 @@
+package cose
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/picatz/cbor"
+)
+
+// Header parameter labels for countersignatures, defined in RFC 9338,
+// section 3.1.
+const (
+	// HeaderLabelCountersignature holds one or more full countersignatures
+	// (Countersignature version 2), which are unprotected headers.
+	HeaderLabelCountersignature int64 = 11
+)
+
+// Countersignable is a COSE structure which can be countersigned: a
+// *Sign1Message, a *SignMessage, or a *Signature of a SignMessage.
+//
+// Countersignatures sign a structure which is already signed, such as when
+// a timestamping or release authority attests to a signed firmware image,
+// and are carried in its unprotected headers.
+//
+// https://www.rfc-editor.org/rfc/rfc9338.html
+type Countersignable interface {
+	// countersignTarget returns the byte string fields of the structure,
+	// starting with its protected headers, and its unprotected headers.
+	countersignTarget() (fields [][]byte, unprotected *Headers, err error)
+}
+
+// Countersign countersigns the target with the given signer and adds the
+// countersignature to its unprotected headers, under
+// HeaderLabelCountersignature.
+//
+// The header parameters of the countersignature are taken from cs, which
+// may be nil. If its protected headers don't contain an algorithm, one is
+// chosen based on the signer's public key and added to them. The external
+// data is additional authenticated data supplied by the application, and
+// may be nil.
+func Countersign(rand io.Reader, target Countersignable, external []byte, signer crypto.Signer, cs *Signature) error {
+	fields, unprotected, err := target.countersignTarget()
+	if err != nil {
+		return err
+	}
+
+	existing, err := Countersignatures(target)
+	if err != nil {
+		return err
+	}
+
+	if cs == nil {
+		cs = &Signature{}
+	}
+	alg, err := setAlgorithm(&cs.Protected, signer)
+	if err != nil {
+		return err
+	}
+	signProtected, err := cs.Protected.marshalProtected()
+	if err != nil {
+		return err
+	}
+
+	toBeSigned, err := countersignStructure(fields, signProtected, external)
+	if err != nil {
+		return err
+	}
+
+	sig, err := sign(rand, alg, signer, toBeSigned)
+	if err != nil {
+		return err
+	}
+	cs.rawProtected = signProtected
+	cs.Signature = sig
+
+	// A single countersignature is stored on its own, and several as an
+	// array of them.
+	all := append(existing, cs)
+	values := make([]interface{}, len(all))
+	for i, s := range all {
+		if values[i], err = s.value(); err != nil {
+			return err
+		}
+	}
+	if *unprotected == nil {
+		*unprotected = Headers{}
+	}
+	if len(values) == 1 {
+		(*unprotected)[HeaderLabelCountersignature] = values[0]
+	} else {
+		(*unprotected)[HeaderLabelCountersignature] = values
+	}
+	return nil
+}
+
+// Countersignatures returns the countersignatures in the unprotected
+// headers of the target, or nil if there aren't any.
+func Countersignatures(target Countersignable) ([]*Signature, error) {
+	_, unprotected, err := target.countersignTarget()
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := (*unprotected)[HeaderLabelCountersignature]
+	if !ok {
+		return nil, nil
+	}
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) == 0 {
+		return nil, errors.New("cose: invalid countersignature header: expected an array")
+	}
+
+	// A single COSE_Countersignature starts with its protected headers,
+	// while an array of them starts with an array.
+	if _, ok := arr[0].([]byte); ok {
+		cs, err := toSignature(arr, "COSE_Countersignature")
+		if err != nil {
+			return nil, err
+		}
+		return []*Signature{cs}, nil
+	}
+
+	all := make([]*Signature, len(arr))
+	for i, item := range arr {
+		if all[i], err = toSignature(item, "COSE_Countersignature"); err != nil {
+			return nil, err
+		}
+	}
+	return all, nil
+}
+
+// VerifyCountersignature verifies a countersignature of the target using
+// the given public key, which must be an *ecdsa.PublicKey or
+// ed25519.PublicKey.
+//
+// The external data must match what was given to Countersign. If the
+// countersignature is invalid, ErrVerification is returned.
+func VerifyCountersignature(target Countersignable, cs *Signature, external []byte, pub crypto.PublicKey) error {
+	alg, ok := cs.Protected.Algorithm()
+	if !ok {
+		return fmt.Errorf("%w: missing algorithm header", ErrUnsupportedAlgorithm)
+	}
+
+	fields, _, err := target.countersignTarget()
+	if err != nil {
+		return err
+	}
+	signProtected, err := protectedBytes(cs.rawProtected, cs.Protected)
+	if err != nil {
+		return err
+	}
+
+	toBeSigned, err := countersignStructure(fields, signProtected, external)
+	if err != nil {
+		return err
+	}
+
+	return verify(alg, pub, toBeSigned, cs.Signature)
+}
+
+// countersignStructure returns the encoded Countersign_structure for a
+// full countersignature of a structure with the given byte string fields,
+// which is the input to the signature algorithm.
+//
+// https://www.rfc-editor.org/rfc/rfc9338.html#section-3.3
+func countersignStructure(fields [][]byte, signProtected, external []byte) ([]byte, error) {
+	if external == nil {
+		external = []byte{}
+	}
+	s := []interface{}{
+		"CounterSignatureV2",
+		fields[0],
+		signProtected,
+		external,
+		fields[1],
+	}
+	if len(fields) > 2 {
+		other := make([]interface{}, len(fields)-2)
+		for i, f := range fields[2:] {
+			other[i] = f
+		}
+		s = append(s, other)
+	}
+	return cbor.Marshal(s)
+}
+
+// countersignTarget implements Countersignable. The fields of a
+// COSE_Sign1 message are its protected headers, payload and signature.
+func (m *Sign1Message) countersignTarget() ([][]byte, *Headers, error) {
+	if m.Signature == nil {
+		return nil, nil, errors.New("cose: message is not signed")
+	}
+	protected, err := protectedBytes(m.rawProtected, m.Protected)
+	if err != nil {
+		return nil, nil, err
+	}
+	return [][]byte{protected, orEmpty(m.Payload), m.Signature}, &m.Unprotected, nil
+}
+
+// countersignTarget implements Countersignable. The fields of a COSE_Sign
+// message are its protected headers and payload.
+func (m *SignMessage) countersignTarget() ([][]byte, *Headers, error) {
+	if len(m.Signatures) == 0 {
+		return nil, nil, errors.New("cose: message is not signed")
+	}
+	protected, err := protectedBytes(m.rawProtected, m.Protected)
+	if err != nil {
+		return nil, nil, err
+	}
+	return [][]byte{protected, orEmpty(m.Payload)}, &m.Unprotected, nil
+}
+
+// countersignTarget implements Countersignable. The fields of a
+// COSE_Signature are its protected headers and signature.
+func (s *Signature) countersignTarget() ([][]byte, *Headers, error) {
+	if s.Signature == nil {
+		return nil, nil, errors.New("cose: signature is not signed")
+	}
+	protected, err := protectedBytes(s.rawProtected, s.Protected)
+	if err != nil {
+		return nil, nil, err
+	}
+	return [][]byte{protected, s.Signature}, &s.Unprotected, nil
+}
+
+// orEmpty returns b, or an empty byte string if b is nil, for detached
+// payloads.
+func orEmpty(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
@@ -0,0 +1,135 @@
+package cose_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/picatz/cbor/cose"
+)
+
+func TestCountersign(t *testing.T) {
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, authority, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, auditor, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Sign1", func(t *testing.T) {
+		msg := &cose.Sign1Message{Payload: []byte("firmware image")}
+		if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+			t.Fatal(err)
+		}
+		if err := cose.Countersign(rand.Reader, msg, nil, authority, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := msg.MarshalCBOR()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got cose.Sign1Message
+		if err := got.UnmarshalCBOR(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := got.Verify(nil, signer.Public()); err != nil {
+			t.Fatal(err)
+		}
+
+		css, err := cose.Countersignatures(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(css) != 1 {
+			t.Fatalf("expected 1 countersignature, got %d", len(css))
+		}
+		if err := cose.VerifyCountersignature(&got, css[0], nil, authority.Public()); err != nil {
+			t.Fatal(err)
+		}
+
+		// The countersignature covers the signature it countersigns.
+		got.Signature[0] ^= 1
+		if err := cose.VerifyCountersignature(&got, css[0], nil, authority.Public()); !errors.Is(err, cose.ErrVerification) {
+			t.Fatalf("expected verification error, got %v", err)
+		}
+	})
+
+	t.Run("Sign", func(t *testing.T) {
+		msg := &cose.SignMessage{Payload: []byte("firmware image")}
+		if err := msg.AddSignature(rand.Reader, nil, signer, nil); err != nil {
+			t.Fatal(err)
+		}
+		external := []byte("release")
+		if err := cose.Countersign(rand.Reader, msg, external, authority, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := cose.Countersign(rand.Reader, msg, external, auditor, &cose.Signature{
+			Unprotected: cose.Headers{cose.HeaderLabelKeyID: []byte("auditor")},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cose.Countersign(rand.Reader, msg.Signatures[0], nil, auditor, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := msg.MarshalCBOR()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got cose.SignMessage
+		if err := got.UnmarshalCBOR(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := got.Verify(nil, signer.Public()); err != nil {
+			t.Fatal(err)
+		}
+
+		css, err := cose.Countersignatures(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(css) != 2 {
+			t.Fatalf("expected 2 countersignatures, got %d", len(css))
+		}
+		if err := cose.VerifyCountersignature(&got, css[0], external, authority.Public()); err != nil {
+			t.Fatal(err)
+		}
+		if err := cose.VerifyCountersignature(&got, css[1], external, auditor.Public()); err != nil {
+			t.Fatal(err)
+		}
+		if kid, ok := css[1].Unprotected.KeyID(); !ok || string(kid) != "auditor" {
+			t.Fatalf("unexpected key ID: %q", kid)
+		}
+		if err := cose.VerifyCountersignature(&got, css[0], nil, authority.Public()); !errors.Is(err, cose.ErrVerification) {
+			t.Fatalf("expected verification error, got %v", err)
+		}
+
+		sigCSS, err := cose.Countersignatures(got.Signatures[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sigCSS) != 1 {
+			t.Fatalf("expected 1 countersignature, got %d", len(sigCSS))
+		}
+		if err := cose.VerifyCountersignature(got.Signatures[0], sigCSS[0], nil, auditor.Public()); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		msg := &cose.Sign1Message{Payload: []byte("firmware image")}
+		if err := cose.Countersign(rand.Reader, msg, nil, authority, nil); err == nil {
+			t.Fatal("expected error countersigning an unsigned message")
+		}
+	})
+}
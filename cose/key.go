@@ -0,0 +1,328 @@
+package cose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/picatz/cbor"
+)
+
+// ErrInvalidKey is returned when a COSE_Key is malformed, or can't be
+// converted to a crypto key.
+var ErrInvalidKey = errors.New("cose: invalid key")
+
+// KeyType is a COSE key type.
+//
+// https://www.iana.org/assignments/cose/cose.xhtml#key-type
+type KeyType int64
+
+// Key types defined in RFC 9053.
+const (
+	// KeyTypeOKP is an octet key pair, used for Ed25519 and X25519 keys.
+	KeyTypeOKP KeyType = 1
+
+	// KeyTypeEC2 is an elliptic curve key with x and y coordinates.
+	KeyTypeEC2 KeyType = 2
+
+	// KeyTypeSymmetric is a symmetric key.
+	KeyTypeSymmetric KeyType = 4
+)
+
+// Curve is a COSE elliptic curve identifier.
+//
+// https://www.iana.org/assignments/cose/cose.xhtml#elliptic-curves
+type Curve int64
+
+// Elliptic curves defined in RFC 9053.
+const (
+	CurveP256    Curve = 1
+	CurveP384    Curve = 2
+	CurveP521    Curve = 3
+	CurveX25519  Curve = 4
+	CurveX448    Curve = 5
+	CurveEd25519 Curve = 6
+	CurveEd448   Curve = 7
+)
+
+// Key parameter labels defined in RFC 9052, section 7.1, and RFC 9053,
+// section 7.
+const (
+	keyLabelKeyType   int64 = 1
+	keyLabelKeyID     int64 = 2
+	keyLabelAlgorithm int64 = 3
+	keyLabelCurve     int64 = -1 // EC2 and OKP
+	keyLabelX         int64 = -2 // EC2 and OKP
+	keyLabelY         int64 = -3 // EC2
+	keyLabelD         int64 = -4 // EC2 and OKP
+	keyLabelK         int64 = -1 // Symmetric
+)
+
+// Key is a COSE_Key, such as the credential public key of a WebAuthn
+// authenticator.
+//
+// https://www.rfc-editor.org/rfc/rfc9052.html#section-7
+type Key struct {
+	// KeyType identifies the family of the key.
+	KeyType KeyType
+
+	// KeyID is the optional key identifier.
+	KeyID []byte
+
+	// Algorithm is the optional algorithm the key is restricted to.
+	Algorithm Algorithm
+
+	// Curve is the curve of an EC2 or OKP key.
+	Curve Curve
+
+	// X is the x-coordinate of an EC2 key, or the public key of an OKP key.
+	X []byte
+
+	// Y is the y-coordinate of an EC2 key. It is nil when the point is
+	// compressed, in which case YSign holds the sign bit.
+	Y []byte
+
+	// YSign is the sign bit of the y-coordinate of a compressed EC2 key.
+	YSign bool
+
+	// D is the private key of an EC2 or OKP key, if present.
+	D []byte
+
+	// K is the key value of a symmetric key.
+	K []byte
+}
+
+// NewKey returns the COSE_Key representation of an *ecdsa.PublicKey or
+// ed25519.PublicKey.
+func NewKey(pub crypto.PublicKey) (*Key, error) {
+	alg, err := algorithmForKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		k := &Key{
+			KeyType:   KeyTypeEC2,
+			Algorithm: alg,
+			X:         pub.X.FillBytes(make([]byte, size)),
+			Y:         pub.Y.FillBytes(make([]byte, size)),
+		}
+		switch alg {
+		case AlgorithmES256:
+			k.Curve = CurveP256
+		case AlgorithmES384:
+			k.Curve = CurveP384
+		case AlgorithmES512:
+			k.Curve = CurveP521
+		}
+		return k, nil
+	case ed25519.PublicKey:
+		return &Key{
+			KeyType:   KeyTypeOKP,
+			Algorithm: alg,
+			Curve:     CurveEd25519,
+			X:         append([]byte(nil), pub...),
+		}, nil
+	}
+	return nil, ErrUnsupportedAlgorithm
+}
+
+// PublicKey converts the key to an *ecdsa.PublicKey or ed25519.PublicKey.
+func (k *Key) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case KeyTypeEC2:
+		var curve elliptic.Curve
+		switch k.Curve {
+		case CurveP256:
+			curve = elliptic.P256()
+		case CurveP384:
+			curve = elliptic.P384()
+		case CurveP521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: unsupported EC2 curve %d", ErrInvalidKey, k.Curve)
+		}
+
+		size := (curve.Params().BitSize + 7) / 8
+		if len(k.X) != size || (k.Y != nil && len(k.Y) != size) {
+			return nil, fmt.Errorf("%w: invalid coordinate length", ErrInvalidKey)
+		}
+
+		var x, y *big.Int
+		if k.Y == nil {
+			// Compressed point, where the prefix encodes the sign of y.
+			point := make([]byte, 1+size)
+			point[0] = 0x02
+			if k.YSign {
+				point[0] = 0x03
+			}
+			copy(point[1:], k.X)
+			x, y = elliptic.UnmarshalCompressed(curve, point)
+		} else {
+			point := make([]byte, 1+2*size)
+			point[0] = 0x04
+			copy(point[1:], k.X)
+			copy(point[1+size:], k.Y)
+			x, y = elliptic.Unmarshal(curve, point)
+		}
+		if x == nil {
+			return nil, fmt.Errorf("%w: point is not on curve", ErrInvalidKey)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case KeyTypeOKP:
+		if k.Curve != CurveEd25519 {
+			return nil, fmt.Errorf("%w: unsupported OKP curve %d", ErrInvalidKey, k.Curve)
+		}
+		if len(k.X) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 public key length", ErrInvalidKey)
+		}
+		return ed25519.PublicKey(append([]byte(nil), k.X...)), nil
+	default:
+		return nil, fmt.Errorf("%w: key type %d has no public key", ErrInvalidKey, k.KeyType)
+	}
+}
+
+// MarshalCBOR returns the COSE_Key encoding of the key.
+func (k *Key) MarshalCBOR() ([]byte, error) {
+	m := map[interface{}]interface{}{
+		keyLabelKeyType: int64(k.KeyType),
+	}
+	if k.KeyID != nil {
+		m[keyLabelKeyID] = k.KeyID
+	}
+	if k.Algorithm != 0 {
+		m[keyLabelAlgorithm] = int64(k.Algorithm)
+	}
+
+	switch k.KeyType {
+	case KeyTypeEC2:
+		m[keyLabelCurve] = int64(k.Curve)
+		m[keyLabelX] = k.X
+		if k.Y != nil {
+			m[keyLabelY] = k.Y
+		} else {
+			m[keyLabelY] = k.YSign
+		}
+		if k.D != nil {
+			m[keyLabelD] = k.D
+		}
+	case KeyTypeOKP:
+		m[keyLabelCurve] = int64(k.Curve)
+		m[keyLabelX] = k.X
+		if k.D != nil {
+			m[keyLabelD] = k.D
+		}
+	case KeyTypeSymmetric:
+		m[keyLabelK] = k.K
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %d", ErrInvalidKey, k.KeyType)
+	}
+
+	return cbor.Marshal(m)
+}
+
+// UnmarshalCBOR decodes a COSE_Key map with an EC2, OKP, or Symmetric key
+// type.
+func (k *Key) UnmarshalCBOR(data []byte) error {
+	var v interface{}
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	params, err := toHeaders(v)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	kty, ok := toInt64(params[keyLabelKeyType])
+	if !ok {
+		return fmt.Errorf("%w: missing key type", ErrInvalidKey)
+	}
+
+	key := Key{KeyType: KeyType(kty)}
+
+	if kid, ok := params[keyLabelKeyID]; ok {
+		if key.KeyID, ok = kid.([]byte); !ok {
+			return fmt.Errorf("%w: key ID must be a byte string", ErrInvalidKey)
+		}
+	}
+	if alg, ok := params[keyLabelAlgorithm]; ok {
+		n, ok := toInt64(alg)
+		if !ok {
+			return fmt.Errorf("%w: unsupported algorithm type %T", ErrInvalidKey, alg)
+		}
+		key.Algorithm = Algorithm(n)
+	}
+
+	bytesParam := func(label int64, required bool) ([]byte, error) {
+		v, ok := params[label]
+		if !ok {
+			if required {
+				return nil, fmt.Errorf("%w: missing parameter %d", ErrInvalidKey, label)
+			}
+			return nil, nil
+		}
+		b, ok := v.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("%w: parameter %d must be a byte string", ErrInvalidKey, label)
+		}
+		return b, nil
+	}
+
+	switch key.KeyType {
+	case KeyTypeEC2, KeyTypeOKP:
+		crv, ok := toInt64(params[keyLabelCurve])
+		if !ok {
+			return fmt.Errorf("%w: missing curve", ErrInvalidKey)
+		}
+		key.Curve = Curve(crv)
+
+		if key.X, err = bytesParam(keyLabelX, true); err != nil {
+			return err
+		}
+		if key.D, err = bytesParam(keyLabelD, false); err != nil {
+			return err
+		}
+
+		if key.KeyType == KeyTypeEC2 {
+			switch y := params[keyLabelY].(type) {
+			case []byte:
+				key.Y = y
+			case bool:
+				key.YSign = y
+			default:
+				return fmt.Errorf("%w: y-coordinate must be a byte string or bool", ErrInvalidKey)
+			}
+		}
+	case KeyTypeSymmetric:
+		if key.K, err = bytesParam(keyLabelK, true); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: unsupported key type %d", ErrInvalidKey, key.KeyType)
+	}
+
+	*k = key
+	return nil
+}
+
+// toInt64 converts a decoded CBOR integer to an int64.
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case int:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
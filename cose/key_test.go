@@ -0,0 +1,143 @@
+package cose_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/picatz/cbor"
+	"github.com/picatz/cbor/cose"
+)
+
+func TestKey_EC2(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := cose.NewKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := key.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got cose.Key
+	if err := got.UnmarshalCBOR(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.KeyType != cose.KeyTypeEC2 || got.Curve != cose.CurveP256 || got.Algorithm != cose.AlgorithmES256 {
+		t.Fatalf("unexpected key parameters: %+v", got)
+	}
+
+	pub, err := got.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.PublicKey.Equal(pub) {
+		t.Fatal("public keys are not equal")
+	}
+}
+
+func TestKey_EC2Compressed(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := elliptic.MarshalCompressed(priv.Curve, priv.X, priv.Y)
+
+	data, err := cbor.Marshal(map[int64]interface{}{
+		1:  2,                     // kty: EC2
+		-1: 2,                     // crv: P-384
+		-2: compressed[1:],        // x
+		-3: compressed[0] == 0x03, // y: sign bit
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var key cose.Key
+	if err := key.UnmarshalCBOR(data); err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := key.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.PublicKey.Equal(pub) {
+		t.Fatal("public keys are not equal")
+	}
+}
+
+func TestKey_OKP(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := cose.NewKey(edPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := key.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got cose.Key
+	if err := got.UnmarshalCBOR(data); err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := got.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !edPub.Equal(pub) {
+		t.Fatal("public keys are not equal")
+	}
+}
+
+func TestKey_Symmetric(t *testing.T) {
+	// {1: 4, 2: '11', -1: h'231f4c4d4d3051fdc2ec0a3851d5b383'}
+	data, err := hex.DecodeString("a30104024231312050231f4c4d4d3051fdc2ec0a3851d5b383")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var key cose.Key
+	if err := key.UnmarshalCBOR(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if key.KeyType != cose.KeyTypeSymmetric || string(key.KeyID) != "11" || len(key.K) != 16 {
+		t.Fatalf("unexpected key: %+v", key)
+	}
+
+	if _, err := key.PublicKey(); !errors.Is(err, cose.ErrInvalidKey) {
+		t.Fatalf("expected invalid key error, got %v", err)
+	}
+}
+
+func TestKey_invalidPoint(t *testing.T) {
+	key := cose.Key{
+		KeyType: cose.KeyTypeEC2,
+		Curve:   cose.CurveP256,
+		X:       make([]byte, 32),
+		Y:       make([]byte, 32),
+	}
+	if _, err := key.PublicKey(); !errors.Is(err, cose.ErrInvalidKey) {
+		t.Fatalf("expected invalid key error, got %v", err)
+	}
+}
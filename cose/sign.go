@@ -0,0 +1,381 @@
+package cose
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/picatz/cbor"
+)
+
+// TagSign is the CBOR tag for a COSE_Sign message.
+const TagSign cbor.Tag = 98
+
+// signTag is the encoded header of TagSign, which takes a one byte
+// argument (major type 6, additional information 24).
+var signTag = []byte{0xd8, 0x62}
+
+// SignMessage is a COSE_Sign message, which carries a payload and one or
+// more signatures, such as those of several authorities approving the same
+// firmware image.
+//
+// https://www.rfc-editor.org/rfc/rfc9052.html#section-4.1
+type SignMessage struct {
+	// Protected are the header parameters protected by every signature.
+	Protected Headers
+
+	// Unprotected are the header parameters not protected by the
+	// signatures.
+	Unprotected Headers
+
+	// Payload is the content of the message. A nil payload is encoded as
+	// null, for detached content.
+	Payload []byte
+
+	// Signatures are the signatures over the message, added by
+	// AddSignature.
+	Signatures []*Signature
+
+	// rawProtected is the serialized protected headers, as received from
+	// UnmarshalCBOR or produced by the first call to AddSignature. Every
+	// signature is computed over these exact bytes.
+	rawProtected []byte
+}
+
+// Signature is a COSE_Signature, one of the signatures of a SignMessage.
+// It is also the structure of a COSE_Countersignature.
+//
+// https://www.rfc-editor.org/rfc/rfc9052.html#section-4.1
+type Signature struct {
+	// Protected are the header parameters protected by this signature
+	// only, such as its algorithm.
+	Protected Headers
+
+	// Unprotected are the header parameters not protected by the
+	// signature, such as the identifier of the key of the signer.
+	Unprotected Headers
+
+	// Signature is the signature value.
+	Signature []byte
+
+	// rawProtected is the serialized protected headers, as received from
+	// UnmarshalCBOR or produced when signing.
+	rawProtected []byte
+}
+
+// AddSignature signs the message with the given signer and appends the
+// signature to its Signatures.
+//
+// The header parameters of the signature are taken from sig, which may be
+// nil. If its protected headers don't contain an algorithm, one is chosen
+// based on the signer's public key and added to them. The external data is
+// additional authenticated data supplied by the application, and may be
+// nil.
+//
+// Once a message has a signature, changes to its Protected headers or
+// Payload invalidate it, so signers are expected to agree on them first.
+func (m *SignMessage) AddSignature(rand io.Reader, external []byte, signer crypto.Signer, sig *Signature) error {
+	if sig == nil {
+		sig = &Signature{}
+	}
+
+	alg, err := setAlgorithm(&sig.Protected, signer)
+	if err != nil {
+		return err
+	}
+
+	bodyProtected, err := m.protected()
+	if err != nil {
+		return err
+	}
+	signProtected, err := sig.Protected.marshalProtected()
+	if err != nil {
+		return err
+	}
+
+	toBeSigned, err := signSigStructure(bodyProtected, signProtected, external, m.Payload)
+	if err != nil {
+		return err
+	}
+
+	s, err := sign(rand, alg, signer, toBeSigned)
+	if err != nil {
+		return err
+	}
+
+	m.rawProtected = bodyProtected
+	sig.rawProtected = signProtected
+	sig.Signature = s
+	m.Signatures = append(m.Signatures, sig)
+	return nil
+}
+
+// Verify checks that, for each of the given public keys, the message has a
+// valid signature made with that key, so a message needing the approval of
+// several authorities is verified with all of their keys at once. Keys
+// must be *ecdsa.PublicKey or ed25519.PublicKey values.
+//
+// The external data must match what was given to AddSignature. If a key
+// has no valid signature, ErrVerification is returned.
+func (m *SignMessage) Verify(external []byte, pubs ...crypto.PublicKey) error {
+	if len(pubs) == 0 {
+		return errors.New("cose: no public keys to verify with")
+	}
+
+	for i, pub := range pubs {
+		verified := false
+		for _, sig := range m.Signatures {
+			if m.VerifySignature(sig, external, pub) == nil {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			return fmt.Errorf("%w: no valid signature for key %d", ErrVerification, i)
+		}
+	}
+	return nil
+}
+
+// VerifySignature verifies a single signature of the message using the
+// given public key, which must be an *ecdsa.PublicKey or ed25519.PublicKey.
+//
+// The external data must match what was given to AddSignature. If the
+// signature is invalid, ErrVerification is returned.
+func (m *SignMessage) VerifySignature(sig *Signature, external []byte, pub crypto.PublicKey) error {
+	alg, ok := sig.Protected.Algorithm()
+	if !ok {
+		return fmt.Errorf("%w: missing algorithm header", ErrUnsupportedAlgorithm)
+	}
+
+	bodyProtected, err := protectedBytes(m.rawProtected, m.Protected)
+	if err != nil {
+		return err
+	}
+	signProtected, err := protectedBytes(sig.rawProtected, sig.Protected)
+	if err != nil {
+		return err
+	}
+
+	toBeSigned, err := signSigStructure(bodyProtected, signProtected, external, m.Payload)
+	if err != nil {
+		return err
+	}
+
+	return verify(alg, pub, toBeSigned, sig.Signature)
+}
+
+// MarshalCBOR returns the tagged COSE_Sign encoding of the message.
+func (m *SignMessage) MarshalCBOR() ([]byte, error) {
+	if len(m.Signatures) == 0 {
+		return nil, errors.New("cose: message is not signed")
+	}
+
+	protected, err := protectedBytes(m.rawProtected, m.Protected)
+	if err != nil {
+		return nil, err
+	}
+
+	sigs := make([]interface{}, len(m.Signatures))
+	for i, sig := range m.Signatures {
+		if sig.Signature == nil {
+			return nil, fmt.Errorf("cose: signature %d is not signed", i)
+		}
+		if sigs[i], err = sig.value(); err != nil {
+			return nil, err
+		}
+	}
+
+	unprotected := m.Unprotected
+	if unprotected == nil {
+		unprotected = Headers{}
+	}
+
+	var payload interface{}
+	if m.Payload != nil {
+		payload = m.Payload
+	}
+
+	var buf bytes.Buffer
+	buf.Write(signTag)
+	err = cbor.NewEncoder(&buf).Encode([]interface{}{
+		protected,
+		map[interface{}]interface{}(unprotected),
+		payload,
+		sigs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCBOR decodes a COSE_Sign message, which may or may not be
+// tagged with TagSign.
+func (m *SignMessage) UnmarshalCBOR(data []byte) error {
+	data = bytes.TrimPrefix(data, signTag)
+
+	var v interface{}
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("cose: invalid COSE_Sign message: %w", err)
+	}
+
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) != 4 {
+		return errors.New("cose: invalid COSE_Sign message: expected array of 4 items")
+	}
+
+	protected, ok := arr[0].([]byte)
+	if !ok {
+		return errors.New("cose: invalid COSE_Sign message: protected headers must be a byte string")
+	}
+	protectedHeaders, err := unmarshalProtected(protected)
+	if err != nil {
+		return err
+	}
+
+	unprotectedHeaders, err := toHeaders(arr[1])
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	switch p := arr[2].(type) {
+	case []byte:
+		payload = p
+	case nil:
+		// Detached payload.
+	default:
+		return errors.New("cose: invalid COSE_Sign message: payload must be a byte string or null")
+	}
+
+	items, ok := arr[3].([]interface{})
+	if !ok || len(items) == 0 {
+		return errors.New("cose: invalid COSE_Sign message: signatures must be a non-empty array")
+	}
+	sigs := make([]*Signature, len(items))
+	for i, item := range items {
+		if sigs[i], err = toSignature(item, "COSE_Signature"); err != nil {
+			return err
+		}
+	}
+
+	*m = SignMessage{
+		Protected:    protectedHeaders,
+		Unprotected:  unprotectedHeaders,
+		Payload:      payload,
+		Signatures:   sigs,
+		rawProtected: protected,
+	}
+	return nil
+}
+
+// protected returns the serialized protected headers of the message,
+// which are fixed by its first signature.
+func (m *SignMessage) protected() ([]byte, error) {
+	if len(m.Signatures) > 0 {
+		return protectedBytes(m.rawProtected, m.Protected)
+	}
+	return m.Protected.marshalProtected()
+}
+
+// value returns the COSE_Signature array of the signature, for encoding.
+func (s *Signature) value() ([]interface{}, error) {
+	protected, err := protectedBytes(s.rawProtected, s.Protected)
+	if err != nil {
+		return nil, err
+	}
+	unprotected := s.Unprotected
+	if unprotected == nil {
+		unprotected = Headers{}
+	}
+	return []interface{}{
+		protected,
+		map[interface{}]interface{}(unprotected),
+		s.Signature,
+	}, nil
+}
+
+// toSignature converts a decoded COSE_Signature or COSE_Countersignature,
+// named by what in errors, into a Signature.
+func toSignature(v interface{}, what string) (*Signature, error) {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) != 3 {
+		return nil, fmt.Errorf("cose: invalid %s: expected array of 3 items", what)
+	}
+
+	protected, ok := arr[0].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cose: invalid %s: protected headers must be a byte string", what)
+	}
+	protectedHeaders, err := unmarshalProtected(protected)
+	if err != nil {
+		return nil, err
+	}
+
+	unprotectedHeaders, err := toHeaders(arr[1])
+	if err != nil {
+		return nil, err
+	}
+
+	sig, ok := arr[2].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cose: invalid %s: signature must be a byte string", what)
+	}
+
+	return &Signature{
+		Protected:    protectedHeaders,
+		Unprotected:  unprotectedHeaders,
+		Signature:    sig,
+		rawProtected: protected,
+	}, nil
+}
+
+// signSigStructure returns the encoded Sig_structure for a signature of a
+// COSE_Sign message, which is the input to the signature algorithm.
+//
+// https://www.rfc-editor.org/rfc/rfc9052.html#section-4.4
+func signSigStructure(bodyProtected, signProtected, external, payload []byte) ([]byte, error) {
+	if external == nil {
+		external = []byte{}
+	}
+	if payload == nil {
+		payload = []byte{}
+	}
+	return cbor.Marshal([]interface{}{
+		"Signature",
+		bodyProtected,
+		signProtected,
+		external,
+		payload,
+	})
+}
+
+// setAlgorithm returns the algorithm in the given protected headers, first
+// adding the default algorithm for the signer's public key if there isn't
+// one.
+func setAlgorithm(protected *Headers, signer crypto.Signer) (Algorithm, error) {
+	if alg, ok := protected.Algorithm(); ok {
+		return alg, nil
+	}
+	alg, err := algorithmForKey(signer.Public())
+	if err != nil {
+		return 0, err
+	}
+	if *protected == nil {
+		*protected = Headers{}
+	}
+	(*protected)[HeaderLabelAlgorithm] = alg
+	return alg, nil
+}
+
+// protectedBytes returns the serialized protected headers, which are raw
+// if they were received or signed, or otherwise the encoding of h.
+func protectedBytes(raw []byte, h Headers) ([]byte, error) {
+	if raw != nil {
+		return raw, nil
+	}
+	return h.marshalProtected()
+}
@@ -0,0 +1,300 @@
+package cose
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/picatz/cbor"
+)
+
+// TagSign1 is the CBOR tag for a COSE_Sign1 message.
+const TagSign1 cbor.Tag = 18
+
+// sign1TagByte is the encoded header of TagSign1, which fits in the
+// initial byte of the tag (major type 6).
+const sign1TagByte = 0xd2
+
+// Sign1Message is a COSE_Sign1 message, which carries a payload and a single
+// signature.
+//
+// https://www.rfc-editor.org/rfc/rfc9052.html#section-4.2
+type Sign1Message struct {
+	// Protected are the header parameters protected by the signature.
+	Protected Headers
+
+	// Unprotected are the header parameters not protected by the signature.
+	Unprotected Headers
+
+	// Payload is the content of the message. A nil payload is encoded as
+	// null, for detached content.
+	Payload []byte
+
+	// Signature is the signature over the message, set by Sign.
+	Signature []byte
+
+	// rawProtected is the serialized protected headers, as received from
+	// UnmarshalCBOR or produced by Sign. Signatures are always computed
+	// over these exact bytes.
+	rawProtected []byte
+}
+
+// Sign signs the message with the given signer, setting its Signature.
+//
+// If the protected headers don't contain an algorithm, one is chosen based
+// on the signer's public key and added to them. The external data is
+// additional authenticated data supplied by the application, and may be nil.
+//
+// Supported signers are *ecdsa.PrivateKey and ed25519.PrivateKey, or any
+// other crypto.Signer whose public key is of the corresponding type.
+func (m *Sign1Message) Sign(rand io.Reader, external []byte, signer crypto.Signer) error {
+	alg, err := setAlgorithm(&m.Protected, signer)
+	if err != nil {
+		return err
+	}
+
+	protected, err := m.Protected.marshalProtected()
+	if err != nil {
+		return err
+	}
+
+	toBeSigned, err := sigStructure(protected, external, m.Payload)
+	if err != nil {
+		return err
+	}
+
+	sig, err := sign(rand, alg, signer, toBeSigned)
+	if err != nil {
+		return err
+	}
+
+	m.rawProtected = protected
+	m.Signature = sig
+	return nil
+}
+
+// Verify verifies the message signature using the given public key, which
+// must be an *ecdsa.PublicKey or ed25519.PublicKey.
+//
+// The external data must match what was given to Sign. If the signature is
+// invalid, ErrVerification is returned.
+func (m *Sign1Message) Verify(external []byte, pub crypto.PublicKey) error {
+	alg, ok := m.Protected.Algorithm()
+	if !ok {
+		return fmt.Errorf("%w: missing algorithm header", ErrUnsupportedAlgorithm)
+	}
+
+	protected := m.rawProtected
+	if protected == nil {
+		var err error
+		protected, err = m.Protected.marshalProtected()
+		if err != nil {
+			return err
+		}
+	}
+
+	toBeSigned, err := sigStructure(protected, external, m.Payload)
+	if err != nil {
+		return err
+	}
+
+	return verify(alg, pub, toBeSigned, m.Signature)
+}
+
+// MarshalCBOR returns the tagged COSE_Sign1 encoding of the message.
+func (m *Sign1Message) MarshalCBOR() ([]byte, error) {
+	if m.Signature == nil {
+		return nil, errors.New("cose: message is not signed")
+	}
+
+	protected := m.rawProtected
+	if protected == nil {
+		var err error
+		protected, err = m.Protected.marshalProtected()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	unprotected := m.Unprotected
+	if unprotected == nil {
+		unprotected = Headers{}
+	}
+
+	var payload interface{}
+	if m.Payload != nil {
+		payload = m.Payload
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(sign1TagByte)
+	err := cbor.NewEncoder(&buf).Encode([]interface{}{
+		protected,
+		map[interface{}]interface{}(unprotected),
+		payload,
+		m.Signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCBOR decodes a COSE_Sign1 message, which may or may not be
+// tagged with TagSign1.
+func (m *Sign1Message) UnmarshalCBOR(data []byte) error {
+	if len(data) > 0 && data[0] == sign1TagByte {
+		data = data[1:]
+	}
+
+	var v interface{}
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("cose: invalid COSE_Sign1 message: %w", err)
+	}
+
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) != 4 {
+		return errors.New("cose: invalid COSE_Sign1 message: expected array of 4 items")
+	}
+
+	protected, ok := arr[0].([]byte)
+	if !ok {
+		return errors.New("cose: invalid COSE_Sign1 message: protected headers must be a byte string")
+	}
+	protectedHeaders, err := unmarshalProtected(protected)
+	if err != nil {
+		return err
+	}
+
+	unprotectedHeaders, err := toHeaders(arr[1])
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	switch p := arr[2].(type) {
+	case []byte:
+		payload = p
+	case nil:
+		// Detached payload.
+	default:
+		return errors.New("cose: invalid COSE_Sign1 message: payload must be a byte string or null")
+	}
+
+	sig, ok := arr[3].([]byte)
+	if !ok {
+		return errors.New("cose: invalid COSE_Sign1 message: signature must be a byte string")
+	}
+
+	*m = Sign1Message{
+		Protected:    protectedHeaders,
+		Unprotected:  unprotectedHeaders,
+		Payload:      payload,
+		Signature:    sig,
+		rawProtected: protected,
+	}
+	return nil
+}
+
+// sigStructure returns the encoded Sig_structure for a COSE_Sign1 message,
+// which is the input to the signature algorithm.
+//
+// https://www.rfc-editor.org/rfc/rfc9052.html#section-4.4
+func sigStructure(protected, external, payload []byte) ([]byte, error) {
+	if external == nil {
+		external = []byte{}
+	}
+	if payload == nil {
+		payload = []byte{}
+	}
+	return cbor.Marshal([]interface{}{
+		"Signature1",
+		protected,
+		external,
+		payload,
+	})
+}
+
+// sign signs toBeSigned using the given algorithm and signer.
+func sign(rand io.Reader, alg Algorithm, signer crypto.Signer, toBeSigned []byte) ([]byte, error) {
+	if want, err := algorithmForKey(signer.Public()); err != nil || want != alg {
+		return nil, fmt.Errorf("%w: %v with %T", ErrUnsupportedAlgorithm, alg, signer.Public())
+	}
+
+	h, err := alg.hash()
+	if err != nil {
+		return nil, err
+	}
+
+	digest := toBeSigned
+	if h != 0 {
+		hh := h.New()
+		hh.Write(toBeSigned)
+		digest = hh.Sum(nil)
+	}
+
+	sig, err := signer.Sign(rand, digest, h)
+	if err != nil {
+		return nil, err
+	}
+
+	// ECDSA signers return ASN.1 DER signatures, but COSE uses the
+	// fixed-length concatenation of r and s.
+	if pub, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		var esig struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(sig, &esig); err != nil {
+			return nil, fmt.Errorf("cose: invalid ECDSA signature: %w", err)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		esig.R.FillBytes(sig[:size])
+		esig.S.FillBytes(sig[size:])
+	}
+
+	return sig, nil
+}
+
+// verify verifies sig over toBeSigned using the given algorithm and key.
+func verify(alg Algorithm, pub crypto.PublicKey, toBeSigned, sig []byte) error {
+	h, err := alg.hash()
+	if err != nil {
+		return err
+	}
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		// Each ECDSA algorithm is tied to its curve by RFC 9053.
+		if want, _ := algorithmForKey(pub); want != alg {
+			return fmt.Errorf("%w: %v with %T", ErrUnsupportedAlgorithm, alg, pub)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return ErrVerification
+		}
+		hh := h.New()
+		hh.Write(toBeSigned)
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, hh.Sum(nil), r, s) {
+			return ErrVerification
+		}
+	case ed25519.PublicKey:
+		if alg != AlgorithmEdDSA {
+			return fmt.Errorf("%w: %v with %T", ErrUnsupportedAlgorithm, alg, pub)
+		}
+		if len(pub) != ed25519.PublicKeySize || !ed25519.Verify(pub, toBeSigned, sig) {
+			return ErrVerification
+		}
+	default:
+		return fmt.Errorf("%w: unsupported key type %T", ErrUnsupportedAlgorithm, pub)
+	}
+	return nil
+}
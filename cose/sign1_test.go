@@ -0,0 +1,200 @@
+package cose_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/picatz/cbor/cose"
+)
+
+func TestSign1Message(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		signer   crypto.Signer
+		external []byte
+		payload  []byte
+	}{
+		{
+			name:    "ES256",
+			signer:  ecKey,
+			payload: []byte("This is the content."),
+		},
+		{
+			name:     "EdDSA",
+			signer:   edKey,
+			external: []byte("aad"),
+			payload:  []byte("This is the content."),
+		},
+		{
+			name:   "detached",
+			signer: ecKey,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := &cose.Sign1Message{
+				Unprotected: cose.Headers{cose.HeaderLabelKeyID: []byte("11")},
+				Payload:     test.payload,
+			}
+			if err := msg.Sign(rand.Reader, test.external, test.signer); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := msg.MarshalCBOR()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got cose.Sign1Message
+			if err := got.UnmarshalCBOR(data); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := got.Verify(test.external, test.signer.Public()); err != nil {
+				t.Fatal(err)
+			}
+
+			if kid, ok := got.Unprotected.KeyID(); !ok || string(kid) != "11" {
+				t.Fatalf("unexpected key ID: %q", kid)
+			}
+
+			got.Payload = append(got.Payload, '!')
+			if err := got.Verify(test.external, test.signer.Public()); !errors.Is(err, cose.ErrVerification) {
+				t.Fatalf("expected verification error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSign1Message_wrongKey(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &cose.Sign1Message{
+		Protected: cose.Headers{cose.HeaderLabelAlgorithm: cose.AlgorithmES256},
+		Payload:   []byte("hello"),
+	}
+	if err := msg.Sign(rand.Reader, nil, edKey); !errors.Is(err, cose.ErrUnsupportedAlgorithm) {
+		t.Fatalf("expected unsupported algorithm error, got %v", err)
+	}
+}
+
+func TestSign1Message_wrongCurve(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ES256 can't be signed with a P-384 key.
+	msg := &cose.Sign1Message{
+		Protected: cose.Headers{cose.HeaderLabelAlgorithm: cose.AlgorithmES256},
+		Payload:   []byte("hello"),
+	}
+	if err := msg.Sign(rand.Reader, nil, p384); !errors.Is(err, cose.ErrUnsupportedAlgorithm) {
+		t.Fatalf("expected unsupported algorithm error, got %v", err)
+	}
+
+	// An ES256 signature doesn't verify with a P-384 key, nor an ES384
+	// signature with a P-256 key.
+	if err := msg.Sign(rand.Reader, nil, p256); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Verify(nil, p384.Public()); !errors.Is(err, cose.ErrUnsupportedAlgorithm) {
+		t.Fatalf("expected unsupported algorithm error, got %v", err)
+	}
+
+	msg = &cose.Sign1Message{Payload: []byte("hello")}
+	if err := msg.Sign(rand.Reader, nil, p384); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Verify(nil, p256.Public()); !errors.Is(err, cose.ErrUnsupportedAlgorithm) {
+		t.Fatalf("expected unsupported algorithm error, got %v", err)
+	}
+}
+
+// TestSign1Message_RFC9052 verifies the example from RFC 9052, Appendix C.2.1.
+func TestSign1Message_RFC9052(t *testing.T) {
+	data, err := hex.DecodeString("d28443a10126a10442313154546869732069732074686520636f6e74656e742e58408eb33e4ca31d1c465ab05aac34cc6b23d58fef5c083106c4d25a91aef0b0117e2af9a291aa32e14ab834dc56ed2a223444547e01f11d3b0916e5a4c345cacb36")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, _ := new(big.Int).SetString("bac5b11cad8f99f9c72b05cf4b9e26d244dc189f745228255a219a86d6a09eff", 16)
+	y, _ := new(big.Int).SetString("20138bf82dc1b6d562be0fa54ab7804a3a64b6d72ccfed6b6fb6ed28bbfc117e", 16)
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+
+	var msg cose.Sign1Message
+	if err := msg.UnmarshalCBOR(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if alg, ok := msg.Protected.Algorithm(); !ok || alg != cose.AlgorithmES256 {
+		t.Fatalf("unexpected algorithm: %v", alg)
+	}
+
+	if err := msg.Verify(nil, pub); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSign1Message_invalidEd25519Key(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &cose.Sign1Message{Payload: []byte("hello")}
+	if err := msg.Sign(rand.Reader, nil, edKey); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pub := range []ed25519.PublicKey{nil, make(ed25519.PublicKey, 31), make(ed25519.PublicKey, 33)} {
+		if err := msg.Verify(nil, pub); !errors.Is(err, cose.ErrVerification) {
+			t.Fatalf("expected verification error for a %d-byte key, got %v", len(pub), err)
+		}
+	}
+}
+
+func TestSign1Message_headerLabelOutOfRange(t *testing.T) {
+	// 18([h'', {18446744073709551615: 1}, null, h''])
+	data, err := hex.DecodeString("d28440a11bffffffffffffffff01f640")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg cose.Sign1Message
+	if err := msg.UnmarshalCBOR(data); err == nil {
+		t.Fatalf("expected an error, got headers %v", msg.Unprotected)
+	}
+
+	// 18([h'', {-1: 1}, null, h''])
+	data, err = hex.DecodeString("d28440a12001f640")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.UnmarshalCBOR(data); err != nil || msg.Unprotected[int64(-1)] != uint64(1) {
+		t.Fatalf("expected label -1, got %v (err %v)", msg.Unprotected, err)
+	}
+}
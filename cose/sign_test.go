@@ -0,0 +1,121 @@
+package cose_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/picatz/cbor/cose"
+)
+
+func TestSignMessage(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	external := []byte("aad")
+	msg := &cose.SignMessage{
+		Protected: cose.Headers{cose.HeaderLabelContentType: int64(42)},
+		Payload:   []byte("firmware image"),
+	}
+	if err := msg.AddSignature(rand.Reader, external, ecKey, &cose.Signature{
+		Unprotected: cose.Headers{cose.HeaderLabelKeyID: []byte("vendor")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.AddSignature(rand.Reader, external, edKey, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got cose.SignMessage
+	if err := got.UnmarshalCBOR(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Signatures) != 2 {
+		t.Fatalf("expected 2 signatures, got %d", len(got.Signatures))
+	}
+	if alg, ok := got.Signatures[0].Protected.Algorithm(); !ok || alg != cose.AlgorithmES384 {
+		t.Fatalf("unexpected algorithm: %v", alg)
+	}
+	if kid, ok := got.Signatures[0].Unprotected.KeyID(); !ok || string(kid) != "vendor" {
+		t.Fatalf("unexpected key ID: %q", kid)
+	}
+
+	if err := got.Verify(external, ecKey.Public(), edKey.Public()); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.VerifySignature(got.Signatures[1], external, edKey.Public()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every key must have a signature.
+	if err := got.Verify(external, ecKey.Public(), otherKey.Public()); !errors.Is(err, cose.ErrVerification) {
+		t.Fatalf("expected verification error, got %v", err)
+	}
+	if err := got.Verify(nil, ecKey.Public()); !errors.Is(err, cose.ErrVerification) {
+		t.Fatalf("expected verification error, got %v", err)
+	}
+
+	got.Payload = append(got.Payload, '!')
+	if err := got.Verify(external, edKey.Public()); !errors.Is(err, cose.ErrVerification) {
+		t.Fatalf("expected verification error, got %v", err)
+	}
+}
+
+func TestSignMessage_unsigned(t *testing.T) {
+	msg := &cose.SignMessage{Payload: []byte("hello")}
+	if _, err := msg.MarshalCBOR(); err == nil {
+		t.Fatal("expected error for message without signatures")
+	}
+	if err := msg.UnmarshalCBOR([]byte{0xd8, 0x62, 0x84, 0x40, 0xa0, 0xf6, 0x80}); err == nil {
+		t.Fatal("expected error for message without signatures")
+	}
+}
+
+// TestSignMessage_RFC9052 verifies the example from RFC 9052, Appendix C.1.1.
+func TestSignMessage_RFC9052(t *testing.T) {
+	data, err := hex.DecodeString("d8628440a054546869732069732074686520636f6e74656e742e818343a10126a1044231315840e2aeafd40d69d19dfe6e52077c5d7ff4e408282cbefb5d06cbf414af2e19d982ac45ac98b8544c908b4507de1e90b717c3d34816fe926a2b98f53afd2fa0f30a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, _ := new(big.Int).SetString("bac5b11cad8f99f9c72b05cf4b9e26d244dc189f745228255a219a86d6a09eff", 16)
+	y, _ := new(big.Int).SetString("20138bf82dc1b6d562be0fa54ab7804a3a64b6d72ccfed6b6fb6ed28bbfc117e", 16)
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+
+	var msg cose.SignMessage
+	if err := msg.UnmarshalCBOR(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Verify(nil, pub); err != nil {
+		t.Fatal(err)
+	}
+
+	// Re-encoding keeps the exact bytes of the message.
+	out, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(out) != hex.EncodeToString(data) {
+		t.Fatalf("expected %x, got %x", data, out)
+	}
+}
@@ -0,0 +1,166 @@
+// Package cwt implements CBOR Web Token (CWT) claims on top of the cbor
+// package.
+//
+// CWT is defined in RFC 8392.
+//
+// https://www.rfc-editor.org/rfc/rfc8392.html
+package cwt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+var (
+	// ErrExpired is returned by Validate when the expiration time has passed.
+	ErrExpired = errors.New("cwt: token is expired")
+
+	// ErrNotValidYet is returned by Validate when the not before time has not
+	// been reached yet.
+	ErrNotValidYet = errors.New("cwt: token is not valid yet")
+)
+
+// TagCWT is the CBOR tag for a CWT.
+const TagCWT cbor.Tag = 61
+
+// tagCWTBytes is the encoded header of TagCWT.
+var tagCWTBytes = [2]byte{0xd8, 0x3d}
+
+// Claim keys defined in RFC 8392, section 4.
+const (
+	KeyIssuer     = 1
+	KeySubject    = 2
+	KeyAudience   = 3
+	KeyExpiration = 4
+	KeyNotBefore  = 5
+	KeyIssuedAt   = 6
+	KeyCWTID      = 7
+)
+
+// Claims are the registered claims of a CWT.
+//
+// Times are NumericDate values, which are the number of seconds since the
+// Unix epoch. Zero values are considered absent, and are omitted by Marshal.
+type Claims struct {
+	Issuer     string `cbor:"1,keyasint,omitempty"`
+	Subject    string `cbor:"2,keyasint,omitempty"`
+	Audience   string `cbor:"3,keyasint,omitempty"`
+	Expiration int64  `cbor:"4,keyasint,omitempty"`
+	NotBefore  int64  `cbor:"5,keyasint,omitempty"`
+	IssuedAt   int64  `cbor:"6,keyasint,omitempty"`
+	CWTID      []byte `cbor:"7,keyasint,omitempty"`
+}
+
+// Marshal returns the CBOR encoding of the claims set, omitting absent
+// claims. The claims are always encoded in the order of their keys.
+func Marshal(c *Claims) ([]byte, error) {
+	return cbor.Marshal(c)
+}
+
+// Unmarshal decodes a CBOR claims set, which may be tagged with TagCWT, into
+// c. Unknown claims are ignored.
+//
+// NumericDate claims may be encoded as integers or floating point values,
+// in which case they are truncated to whole seconds.
+func Unmarshal(data []byte, c *Claims) error {
+	if len(data) >= 2 && data[0] == tagCWTBytes[0] && data[1] == tagCWTBytes[1] {
+		data = data[2:]
+	}
+
+	var v interface{}
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("cwt: invalid claims set: %w", err)
+	}
+
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("cwt: invalid claims set type %T", v)
+	}
+
+	var claims Claims
+	for k, v := range m {
+		key, ok := k.(uint64)
+		if !ok {
+			// Ignore private and text claim keys.
+			continue
+		}
+
+		var err error
+		switch key {
+		case KeyIssuer:
+			claims.Issuer, err = stringClaim(key, v)
+		case KeySubject:
+			claims.Subject, err = stringClaim(key, v)
+		case KeyAudience:
+			claims.Audience, err = stringClaim(key, v)
+		case KeyExpiration:
+			claims.Expiration, err = numericDateClaim(key, v)
+		case KeyNotBefore:
+			claims.NotBefore, err = numericDateClaim(key, v)
+		case KeyIssuedAt:
+			claims.IssuedAt, err = numericDateClaim(key, v)
+		case KeyCWTID:
+			b, ok := v.([]byte)
+			if !ok {
+				err = fmt.Errorf("cwt: claim %d must be a byte string, got %T", key, v)
+			}
+			claims.CWTID = b
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	*c = claims
+	return nil
+}
+
+// Validate checks the time-based claims against now, allowing for the given
+// clock skew between the issuer and the validator.
+//
+// ErrExpired is returned if now is on or after the expiration time, and
+// ErrNotValidYet if now is before the not before time. Absent claims are not
+// checked.
+func (c *Claims) Validate(now time.Time, skew time.Duration) error {
+	if c.Expiration != 0 && !now.Before(time.Unix(c.Expiration, 0).Add(skew)) {
+		return ErrExpired
+	}
+	if c.NotBefore != 0 && now.Before(time.Unix(c.NotBefore, 0).Add(-skew)) {
+		return ErrNotValidYet
+	}
+	return nil
+}
+
+// stringClaim converts a decoded text string claim.
+func stringClaim(key uint64, v interface{}) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("cwt: claim %d must be a text string, got %T", key, v)
+	}
+	return s, nil
+}
+
+// numericDateClaim converts a decoded NumericDate claim to seconds since the
+// Unix epoch.
+func numericDateClaim(key uint64, v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("cwt: claim %d is out of range", key)
+		}
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) || v >= math.MaxInt64 || v < math.MinInt64 {
+			return 0, fmt.Errorf("cwt: claim %d is out of range", key)
+		}
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("cwt: claim %d must be a NumericDate, got %T", key, v)
+	}
+}
@@ -0,0 +1,145 @@
+package cwt_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/picatz/cbor/cwt"
+)
+
+// Data from https://tools.ietf.org/html/rfc8392#appendix-A section A.1
+const rfc8392Claims = "a70175636f61703a2f2f61732e6578616d706c652e636f6d02656572696b77037818636f61703a2f2f6c696768742e6578616d706c652e636f6d041a5612aeb0051a5610d9f0061a5610d9f007420b71"
+
+func TestUnmarshal(t *testing.T) {
+	data, err := hex.DecodeString(rfc8392Claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c cwt.Claims
+	if err := cwt.Unmarshal(data, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	want := cwt.Claims{
+		Issuer:     "coap://as.example.com",
+		Subject:    "erikw",
+		Audience:   "coap://light.example.com",
+		Expiration: 1444064944,
+		NotBefore:  1443944944,
+		IssuedAt:   1443944944,
+		CWTID:      []byte{0x0b, 0x71},
+	}
+	if c.Issuer != want.Issuer || c.Subject != want.Subject || c.Audience != want.Audience ||
+		c.Expiration != want.Expiration || c.NotBefore != want.NotBefore || c.IssuedAt != want.IssuedAt ||
+		!bytes.Equal(c.CWTID, want.CWTID) {
+		t.Fatalf("unexpected claims: %+v", c)
+	}
+}
+
+func TestUnmarshal_tagged(t *testing.T) {
+	// 61({4: 1444064944.5, 8: "ignored"})
+	data, err := hex.DecodeString("d83da204fb41d584abac200000086769676e6f726564")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c cwt.Claims
+	if err := cwt.Unmarshal(data, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Expiration != 1444064944 {
+		t.Fatal("unexpected Expiration:", c.Expiration)
+	}
+}
+
+func TestUnmarshal_outOfRange(t *testing.T) {
+	for _, s := range []string{
+		"a104fb43e0000000000000", // {4: 2^63}
+		"a104fbc3e0000000000001", // {4: -2^63 - 2048}
+		"a1041b8000000000000000", // {4: 2^63}
+	} {
+		data, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var c cwt.Claims
+		if err := cwt.Unmarshal(data, &c); err == nil {
+			t.Errorf("%s: expected an error, got Expiration %d", s, c.Expiration)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	c := &cwt.Claims{
+		Issuer:     "coap://as.example.com",
+		Expiration: 1444064944,
+	}
+
+	data, err := cwt.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got cwt.Claims
+	if err := cwt.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Issuer != c.Issuer || got.Expiration != c.Expiration || got.Subject != "" || got.CWTID != nil {
+		t.Fatalf("unexpected claims: %+v", got)
+	}
+}
+
+func TestMarshal_deterministic(t *testing.T) {
+	c := &cwt.Claims{
+		Issuer:     "coap://as.example.com",
+		Subject:    "erikw",
+		Audience:   "coap://light.example.com",
+		Expiration: 1444064944,
+		NotBefore:  1443944944,
+		IssuedAt:   1443944944,
+		CWTID:      []byte{0x0b, 0x71},
+	}
+
+	// The claims are encoded in the order of their keys, as in RFC 8392.
+	for i := 0; i < 20; i++ {
+		data, err := cwt.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(data); got != rfc8392Claims {
+			t.Fatalf("expected %s, got %s", rfc8392Claims, got)
+		}
+	}
+}
+
+func TestClaims_Validate(t *testing.T) {
+	c := &cwt.Claims{
+		Expiration: 1444064944,
+		NotBefore:  1443944944,
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		skew time.Duration
+		want error
+	}{
+		{name: "valid", now: time.Unix(1444000000, 0)},
+		{name: "expired", now: time.Unix(1444064944, 0), want: cwt.ErrExpired},
+		{name: "expired within skew", now: time.Unix(1444064944, 0), skew: time.Minute},
+		{name: "not valid yet", now: time.Unix(1443944943, 0), want: cwt.ErrNotValidYet},
+		{name: "not valid yet within skew", now: time.Unix(1443944943, 0), skew: time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := c.Validate(test.now, test.skew); !errors.Is(err, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package cbor
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TagEpochDate is the tag of dates (RFC 8943) encoded as the number of
+// days since 1970-01-01, which is negative for earlier dates.
+const TagEpochDate = 100
+
+// TagFullDate is the tag of dates (RFC 8943) encoded as RFC 3339 full-date
+// strings, such as "2013-03-21".
+const TagFullDate = 1004
+
+// EpochTime is a time.Time which is always encoded as a number of seconds
+// since the Unix epoch with tag 1, whatever the TimeMode of the encoder,
+// for fields which must be epoch times. The number is an integer, or a
+// float if the time has a fraction of a second. Zero times are encoded as
+// null.
+//
+// EpochTime values are decoded like time.Time values.
+type EpochTime struct {
+	time.Time
+}
+
+// DateOnly is a time.Time which is encoded as a date with TagEpochDate,
+// for fields which are calendar dates rather than instants, such as birth
+// dates. The date is the one in the location of the time, as returned by
+// its Date method, and the time of day is left out. Zero times are
+// encoded as null.
+//
+// Dates with TagEpochDate or TagFullDate, and untagged numbers of days,
+// are decoded as midnight UTC on the date.
+type DateOnly struct {
+	time.Time
+}
+
+var (
+	// epochTimeType is the reflect.Type of EpochTime.
+	epochTimeType = reflect.TypeOf(EpochTime{})
+
+	// dateOnlyType is the reflect.Type of DateOnly.
+	dateOnlyType = reflect.TypeOf(DateOnly{})
+)
+
+// encodeEpochTime writes an EpochTime as an epoch time with tag 1.
+func encodeEpochTime(e *Encoder, v reflect.Value) error {
+	t := v.Interface().(EpochTime).Time
+	if t.IsZero() {
+		return e.writeNull()
+	}
+	e.buf = AppendTag(e.buf, uint64(TagUnixTime))
+	if t.Nanosecond() != 0 {
+		return e.writeFloat(float64(t.Unix()) + float64(t.Nanosecond())/1e9)
+	}
+	return e.writeInt(t.Unix())
+}
+
+// decodeEpochTime decodes a time item into an EpochTime.
+func decodeEpochTime(dec *Decoder, rv reflect.Value, b byte) error {
+	return decodeTime(dec, rv.Field(0), b)
+}
+
+// encodeDateOnly writes a DateOnly as a number of days with TagEpochDate.
+func encodeDateOnly(e *Encoder, v reflect.Value) error {
+	t := v.Interface().(DateOnly).Time
+	if t.IsZero() {
+		return e.writeNull()
+	}
+	y, m, d := t.Date()
+	days := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60)
+	e.buf = AppendTag(e.buf, TagEpochDate)
+	return e.writeInt(days)
+}
+
+// decodeDateOnly decodes a date into a DateOnly.
+func decodeDateOnly(dec *Decoder, rv reflect.Value, b byte) error {
+	t, err := dec.readDate(rv.Type(), b)
+	if err != nil {
+		return err
+	}
+	rv.Field(0).Set(reflect.ValueOf(t))
+	return nil
+}
+
+// readDate reads a date item for a value of type rt, given its initial
+// byte b, as midnight UTC on the date. Dates are numbers of days since
+// 1970-01-01, with or without TagEpochDate, or full-date strings with
+// TagFullDate.
+func (dec *Decoder) readDate(rt reflect.Type, b byte) (time.Time, error) {
+	tag := uint64(TagEpochDate)
+	if MajorType(b>>5) == MajorTypeTag {
+		var err error
+		if tag, err = dec.readArgument(b & 0x1f); err != nil {
+			return time.Time{}, err
+		}
+		if tag != TagEpochDate && tag != TagFullDate {
+			return time.Time{}, typeError("tag "+itoa(int64(tag)), rt)
+		}
+		if b, err = dec.readByte(); err != nil {
+			return time.Time{}, unexpectedEOF(err)
+		}
+	}
+	return dec.readDateContent(rt, tag, b)
+}
+
+// readDateContent reads the content of a date with the tag TagEpochDate or
+// TagFullDate, given its initial byte b, as midnight UTC on the date.
+func (dec *Decoder) readDateContent(rt reflect.Type, tag uint64, b byte) (time.Time, error) {
+	switch mt := MajorType(b >> 5); {
+	case tag == TagFullDate:
+		if mt != MajorTypeTextString {
+			return time.Time{}, newError(ErrInvalidType, "cbor: full-date is not a text string")
+		}
+		var s string
+		if err := dec.decodeItem(reflect.ValueOf(&s).Elem(), b); err != nil {
+			return time.Time{}, err
+		}
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return time.Time{}, newError(ErrInvalidType, "cbor: invalid full-date "+strconv.Quote(s))
+		}
+		return t, nil
+	case mt == MajorTypeUnsignedInt || mt == MajorTypeNegativeInt:
+		var days int32
+		if err := dec.decodeItem(reflect.ValueOf(&days).Elem(), b); err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(int64(days)*24*60*60, 0).UTC(), nil
+	case b == 0xf6 || b == 0xf7:
+		return time.Time{}, nil
+	}
+	return time.Time{}, typeError("major type "+itoa(int64(b>>5)), rt)
+}
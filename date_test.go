@@ -0,0 +1,118 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+func TestEpochTime(t *testing.T) {
+	type event struct {
+		At      time.Time       `cbor:"at"`
+		Created cbor.EpochTime  `cbor:"created"`
+		Updated *cbor.EpochTime `cbor:"updated"`
+	}
+
+	tm := time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)
+	v := event{At: tm, Created: cbor.EpochTime{Time: tm}, Updated: &cbor.EpochTime{Time: tm.Add(time.Second / 2)}}
+
+	// The epoch times are encoded with tag 1 even though the other time
+	// is a date/time string.
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("\x67created\xc1\x1a\x51\x4b\x67\xb0")) {
+		t.Fatalf("expected an integer epoch time, got %x", data)
+	}
+	if !bytes.Contains(data, []byte("\x67updated\xc1\xfb")) {
+		t.Fatalf("expected a float epoch time, got %x", data)
+	}
+
+	var got event
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Created.Equal(tm) || !got.Updated.Equal(tm.Add(time.Second/2)) {
+		t.Fatalf("unexpected times %v and %v", got.Created, got.Updated)
+	}
+
+	// Epoch times decode as time.Time values into interfaces.
+	var m map[string]interface{}
+	if err := cbor.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if created, ok := m["created"].(time.Time); !ok || !created.Equal(tm) {
+		t.Fatalf("expected %v, got %#v", tm, m["created"])
+	}
+
+	data, err = cbor.Marshal(cbor.EpochTime{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0xf6}) {
+		t.Fatalf("expected null for the zero time, got %x", data)
+	}
+}
+
+func TestDateOnly(t *testing.T) {
+	tests := []struct {
+		date string
+		hex  string
+	}{
+		{"1970-01-01", "d86400"},
+		{"2013-03-21", "d864193da9"},
+		{"1940-10-09", "d8643929b3"},
+	}
+
+	for _, test := range tests {
+		tm, err := time.Parse("2006-01-02", test.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The date is the one in the location of the time.
+		local := cbor.DateOnly{Time: tm.Add(23 * time.Hour).In(time.FixedZone("", -12*60*60))}
+		data, err := cbor.Marshal(local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(data); got != test.hex {
+			t.Fatalf("%s: expected %s, got %s", test.date, test.hex, got)
+		}
+
+		var d cbor.DateOnly
+		if err := cbor.Unmarshal(data, &d); err != nil {
+			t.Fatal(err)
+		}
+		if !d.Equal(tm) || d.Location() != time.UTC {
+			t.Fatalf("%s: decoded %v", test.date, d)
+		}
+
+		// Full-date strings decode the same way, into interfaces too.
+		data = append([]byte{0xd9, 0x03, 0xec, 0x6a}, test.date...)
+		var v interface{}
+		if err := cbor.Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := v.(time.Time); !ok || !got.Equal(tm) {
+			t.Fatalf("%s: decoded %#v", test.date, v)
+		}
+	}
+
+	for _, data := range []string{
+		"c11a514b67b0",                 // an epoch time
+		"d903ec1a514b67b0",             // a full-date which isn't a string
+		"d903ec6a323031332d30332d3332", // an invalid full-date
+		"d8641b0000000100000000",       // out of range
+	} {
+		b, _ := hex.DecodeString(data)
+		var d cbor.DateOnly
+		if err := cbor.Unmarshal(b, &d); err == nil {
+			t.Errorf("%s: expected an error, got %v", data, d)
+		}
+	}
+}
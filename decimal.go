@@ -0,0 +1,134 @@
+package cbor
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// Decimal is implemented by decimal number types, which are encoded as and
+// decoded from decimal fractions (RFC 8949, section 3.4.4), without the
+// package depending on any decimal library. Types such as those of
+// github.com/shopspring/decimal or github.com/cockroachdb/apd can be
+// plugged in with a small wrapper, such as
+//
+//	type Money struct{ decimal.Decimal }
+//
+//	func (m Money) CoefficientExponent() (*big.Int, int64) {
+//		return m.Coefficient(), int64(m.Exponent())
+//	}
+//
+//	func (m *Money) SetString(s string) (err error) {
+//		m.Decimal, err = decimal.NewFromString(s)
+//		return err
+//	}
+//
+// Decimal fractions are encoded as tag 4 with an array of the exponent and
+// the coefficient, which is a plain integer if it fits in 64 bits, or a
+// bignum. Decimal fractions and integers are decoded by calling SetString
+// on a pointer to the value, so its type is usually a pointer type.
+type Decimal interface {
+	// CoefficientExponent returns the number as coefficient*10^exponent.
+	CoefficientExponent() (coefficient *big.Int, exponent int64)
+
+	// SetString sets the number from a string such as "-12345e-2", with
+	// the coefficient and exponent in decimal.
+	SetString(s string) error
+}
+
+// decimalType is the reflect.Type of the Decimal interface.
+var decimalType = reflect.TypeOf((*Decimal)(nil)).Elem()
+
+// encodeDecimal writes a Decimal as a decimal fraction.
+func encodeDecimal(e *Encoder, v reflect.Value) error {
+	c, exp := v.Interface().(Decimal).CoefficientExponent()
+	if c == nil {
+		c = new(big.Int)
+	}
+	e.buf = AppendTag(e.buf, uint64(TagDecimalFraction))
+	e.buf = appendHeader(e.buf, MajorTypeArray, 2)
+	e.buf = AppendInt(e.buf, exp)
+	return e.writeBigInt(c, true)
+}
+
+// encodeDecimalAddr writes a value whose address implements Decimal,
+// copying it if it isn't addressable.
+func encodeDecimalAddr(e *Encoder, v reflect.Value) error {
+	if !v.CanAddr() {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p.Elem()
+	}
+	return encodeDecimal(e, v.Addr())
+}
+
+// decodeDecimal decodes a decimal fraction or an integer into a value
+// whose address implements Decimal.
+func decodeDecimal(dec *Decoder, rv reflect.Value, b byte) error {
+	var c big.Int
+	exp := int64(0)
+	switch MajorType(b >> 5) {
+	case MajorTypeUnsignedInt, MajorTypeNegativeInt:
+		if err := decodeBigInt(dec, reflect.ValueOf(&c).Elem(), b); err != nil {
+			return err
+		}
+	case MajorTypeTag:
+		tag, err := dec.readArgument(b & 0x1f)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if Tag(tag) != TagDecimalFraction {
+			return typeError("tag "+itoa(int64(tag)), rv.Type())
+		}
+		if exp, err = dec.readDecimalFraction(&c); err != nil {
+			return err
+		}
+	default:
+		return dec.decodeItem(rv, b)
+	}
+	s := c.String() + "e" + strconv.FormatInt(exp, 10)
+	return rv.Addr().Interface().(Decimal).SetString(s)
+}
+
+// readDecimalFraction reads the content of a decimal fraction, setting c to
+// its coefficient and returning its exponent.
+func (dec *Decoder) readDecimalFraction(c *big.Int) (int64, error) {
+	b, err := dec.readByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	if b != byte(MajorTypeArray)<<5|2 {
+		return 0, newError(ErrInvalidType, "cbor: decimal fraction is not an array of two integers")
+	}
+
+	b, err = dec.readByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	mt := MajorType(b >> 5)
+	if mt != MajorTypeUnsignedInt && mt != MajorTypeNegativeInt {
+		return 0, newError(ErrInvalidType, "cbor: decimal fraction exponent is not an integer")
+	}
+	n, err := dec.readArgument(b & 0x1f)
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	if n > math.MaxInt64 {
+		return 0, newError(ErrInvalidType, "cbor: decimal fraction exponent out of range")
+	}
+	exp := int64(n)
+	if mt == MajorTypeNegativeInt {
+		exp = -1 - exp
+	}
+
+	b, err = dec.readByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	mt = MajorType(b >> 5)
+	if mt != MajorTypeUnsignedInt && mt != MajorTypeNegativeInt && mt != MajorTypeTag {
+		return 0, newError(ErrInvalidType, "cbor: decimal fraction coefficient is not an integer")
+	}
+	return exp, decodeBigInt(dec, reflect.ValueOf(c).Elem(), b)
+}
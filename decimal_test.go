@@ -0,0 +1,103 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+// fixed is a decimal number implementing cbor.Decimal.
+type fixed struct {
+	coefficient *big.Int
+	exponent    int64
+}
+
+func (d fixed) CoefficientExponent() (*big.Int, int64) {
+	return d.coefficient, d.exponent
+}
+
+func (d *fixed) SetString(s string) error {
+	c, e, _ := strings.Cut(s, "e")
+	coefficient, ok := new(big.Int).SetString(c, 10)
+	if !ok {
+		return errors.New("invalid coefficient")
+	}
+	exponent, err := strconv.ParseInt(e, 10, 64)
+	if err != nil {
+		return err
+	}
+	d.coefficient, d.exponent = coefficient, exponent
+	return nil
+}
+
+func (d fixed) String() string {
+	return d.coefficient.String() + "e" + strconv.FormatInt(d.exponent, 10)
+}
+
+func TestDecimal(t *testing.T) {
+	parse := func(s string) fixed {
+		var d fixed
+		if err := d.SetString(s); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		d    string
+		want string
+	}{
+		{"27315e-2", "c48221196ab3"},
+		{"-15e3", "c482032e"},
+		{"0e0", "c4820000"},
+		{"18446744073709551616e-1", "c48220c249010000000000000000"},
+	}
+
+	for _, test := range tests {
+		b, err := cbor.Marshal(parse(test.d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(b); got != test.want {
+			t.Fatalf("%s: expected %s, got %s", test.d, test.want, got)
+		}
+
+		var v struct {
+			D *fixed `cbor:"d"`
+		}
+		if err := cbor.Unmarshal(append([]byte{0xa1, 0x61, 'd'}, b...), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.D == nil || v.D.String() != test.d {
+			t.Fatalf("%s: decoded %v", test.d, v.D)
+		}
+	}
+}
+
+func TestDecimal_decode(t *testing.T) {
+	var d fixed
+	if err := cbor.Unmarshal([]byte{0x38, 0x63}, &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.String() != "-100e0" {
+		t.Fatalf("expected -100e0, got %s", d)
+	}
+
+	for _, data := range []string{
+		"c58221196ab3",     // a bigfloat
+		"c4196ab3",         // not an array
+		"c48321196ab300",   // three elements
+		"c482f93c00196ab3", // a float exponent
+		"c482216161",       // a string coefficient
+	} {
+		b, _ := hex.DecodeString(data)
+		if err := cbor.Unmarshal(b, &d); !errors.Is(err, cbor.ErrInvalidType) {
+			t.Errorf("%s: expected a type error, got %v", data, err)
+		}
+	}
+}
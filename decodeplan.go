@@ -0,0 +1,822 @@
+package cbor
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// decoderFunc decodes the item whose initial byte b has already been read
+// into rv, an addressable value of the type the function was compiled for.
+type decoderFunc func(dec *Decoder, rv reflect.Value, b byte) error
+
+// decoderCache is a cache of compiled decoderFuncs, keyed by reflect.Type.
+var decoderCache sync.Map
+
+// decoderFor returns the decoderFunc for t, compiling it on first use, so
+// the reflection on t is only done once rather than for every value.
+func decoderFor(t reflect.Type) decoderFunc {
+	if f, ok := decoderCache.Load(t); ok {
+		return f.(decoderFunc)
+	}
+
+	// Recursive types need the function for t while it's compiled, so an
+	// indirect function waiting for the compiled one is stored first.
+	var (
+		wg sync.WaitGroup
+		f  decoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := decoderCache.LoadOrStore(t, decoderFunc(func(dec *Decoder, rv reflect.Value, b byte) error {
+		wg.Wait()
+		return f(dec, rv, b)
+	}))
+	if loaded {
+		return fi.(decoderFunc)
+	}
+
+	f = typeFuncDecoder(t, compileDecoder(t))
+	wg.Done()
+	decoderCache.Store(t, f)
+	return f
+}
+
+// compileDecoder returns a new decoderFunc for t.
+func compileDecoder(t reflect.Type) decoderFunc {
+	// Types that unmarshal themselves are given the encoded item.
+	switch {
+	case t.Kind() == reflect.Ptr && t.Implements(unmarshalerType):
+		return decodeUnmarshalerPtr
+	case t.Kind() != reflect.Interface && reflect.PtrTo(t).Implements(unmarshalerType):
+		return decodeUnmarshalerAddr
+	case t.Kind() != reflect.Interface && reflect.PtrTo(t).Implements(decimalType):
+		// Decimal types are set from the string of the number.
+		return decodeDecimal
+	}
+
+	switch t {
+	case durationType:
+		return decodeDuration
+	case timeType:
+		return decodeTime
+	case dateTimeType:
+		return decodeDateTime
+	case epochTimeType:
+		return decodeEpochTime
+	case dateOnlyType:
+		return decodeDateOnly
+	case extendedTimeType:
+		return decodeExtendedTime
+	case jsonRawMessageType:
+		return decodeJSONRawMessage
+	case bigIntType:
+		return decodeBigInt
+	case uint128Type:
+		return decodeUint128
+	case int128Type:
+		return decodeInt128
+	case byteStringType:
+		return decodeByteString
+	case orderedMapType:
+		return decodeOrderedMap
+	case tagValueType:
+		return decodeTagValue
+	}
+	if valid, ok := nullableValid(t); ok {
+		return compileNullableDecoder(t, valid)
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return compileInterfaceDecoder(t)
+	case reflect.Ptr:
+		return compilePtrDecoder(t)
+	case reflect.Struct:
+		if typeTag(t).toArray {
+			return compileStructArrayDecoder(t, t.String())
+		}
+		return nestedDecoder(compileStructDecoder(t))
+	case reflect.Slice:
+		// Byte slices are decoded from byte strings, or from arrays of
+		// integers, as encoded with ByteSliceArray.
+		if t.Elem().Kind() == reflect.Uint8 {
+			array := nestedDecoder(compileSliceDecoder(t))
+			return func(dec *Decoder, rv reflect.Value, b byte) error {
+				if MajorType(b>>5) == MajorTypeArray {
+					return array(dec, rv, b)
+				}
+				return dec.decodeItem(rv, b)
+			}
+		}
+		return nestedDecoder(compileSliceDecoder(t))
+	case reflect.Array:
+		return nestedDecoder(compileArrayDecoder(t))
+	case reflect.Map:
+		return nestedDecoder(compileMapDecoder(t))
+	case reflect.Float32, reflect.Float64:
+		return decodeFloatPlan
+	}
+	return (*Decoder).decodeItem
+}
+
+// nestedDecoder wraps the decoderFunc of a container type to count the
+// nesting depth of the items it decodes, so that recursive types can't
+// be used to decode items nested deeper than the decoder's MaxDepth.
+func nestedDecoder(f decoderFunc) decoderFunc {
+	return func(dec *Decoder, rv reflect.Value, b byte) error {
+		if dec.depth >= dec.options.MaxDepth {
+			return dec.limitExceeded(ErrMaxDepth)
+		}
+		dec.depth++
+		err := f(dec, rv, b)
+		dec.depth--
+		return err
+	}
+}
+
+// decodeUnmarshalerPtr decodes an item into a pointer implementing
+// Unmarshaler, allocating a new value if the pointer is nil.
+func decodeUnmarshalerPtr(dec *Decoder, rv reflect.Value, b byte) error {
+	raw, err := dec.appendRawItem(nil, b, 0)
+	if err != nil {
+		return err
+	}
+	if rv.IsNil() {
+		rv.Set(reflect.New(rv.Type().Elem()))
+	}
+	return rv.Interface().(Unmarshaler).UnmarshalCBOR(raw)
+}
+
+// decodeUnmarshalerAddr decodes an item into a value whose address
+// implements Unmarshaler.
+func decodeUnmarshalerAddr(dec *Decoder, rv reflect.Value, b byte) error {
+	raw, err := dec.appendRawItem(nil, b, 0)
+	if err != nil {
+		return err
+	}
+	return rv.Addr().Interface().(Unmarshaler).UnmarshalCBOR(raw)
+}
+
+// compilePtrDecoder returns the decoderFunc for a pointer type, which
+// decodes items into the value pointed to, allocating it if the pointer is
+// nil. Since the value pointed to is decoded with the decoderFunc of its
+// own type, any number of pointers is followed the same way. Null sets the
+// pointer to nil instead, so nil pointers round-trip at every level.
+func compilePtrDecoder(t reflect.Type) decoderFunc {
+	elem := decoderFor(t.Elem())
+	return func(dec *Decoder, rv reflect.Value, b byte) error {
+		if b == 0xf6 {
+			rv.Set(reflect.Zero(t))
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(t.Elem()))
+		}
+		return elem(dec, rv.Elem(), b)
+	}
+}
+
+// compileInterfaceDecoder returns the decoderFunc for an interface type.
+// As with encoding/json, if the interface holds a non-nil pointer, items
+// other than null are decoded into the value it points to. Otherwise, the
+// interface is set to the decoded value, which is only possible for empty
+// interfaces, or to nil for null.
+func compileInterfaceDecoder(t reflect.Type) decoderFunc {
+	return func(dec *Decoder, rv reflect.Value, b byte) error {
+		if b != 0xf6 && !rv.IsNil() {
+			if e := rv.Elem(); e.Kind() == reflect.Ptr && !e.IsNil() {
+				return decoderFor(e.Type())(dec, e, b)
+			}
+		}
+		if t.NumMethod() != 0 {
+			if b == 0xf6 {
+				rv.Set(reflect.Zero(t))
+				return nil
+			}
+			return errors.New("cbor: cannot unmarshal into non-empty interface " + t.String())
+		}
+		return dec.decodeItem(rv, b)
+	}
+}
+
+// structDecoder is the compiled decoder of a struct type.
+type structDecoder struct {
+	// fields are the indices of the fields by map key, and jsonFields
+	// are the same with json tags used for fields without a cbor tag.
+	fields     fieldCache
+	jsonFields fieldCache
+
+	// decoders are the decoderFuncs of the fields, by index. They are nil
+	// for unexported fields.
+	decoders []decoderFunc
+
+	// unexported are the names of the unexported fields, which are an
+	// error to decode with UnexportedFieldError.
+	unexported []string
+}
+
+// compileStructDecoder returns the decoderFunc for a struct type, which
+// decodes maps into the fields matching their keys, skipping the values of
+// unknown keys. Keys match the name of a field in its cbor tag, or its
+// Go name, ignoring case if there is no exact match. With the JSONTags
+// option, fields without a cbor tag match the name in their json tag.
+func compileStructDecoder(t reflect.Type) decoderFunc {
+	sd := &structDecoder{
+		fields:     loadFieldCache(t, false),
+		decoders:   make([]decoderFunc, t.NumField()),
+		unexported: unexportedFields(t),
+	}
+	sd.jsonFields = sd.fields
+	if hasJSONTags(t) {
+		sd.jsonFields = loadFieldCache(t, true)
+	}
+	for _, fc := range []fieldCache{sd.fields, sd.jsonFields} {
+		for _, f := range fc {
+			if sd.decoders[f.index] == nil {
+				sd.decoders[f.index] = decoderFor(t.Field(f.index).Type)
+			}
+		}
+	}
+	return sd.decode
+}
+
+// decode implements decoderFunc.
+func (sd *structDecoder) decode(dec *Decoder, rv reflect.Value, b byte) error {
+	if MajorType(b>>5) != MajorTypeMap {
+		return dec.decodeItem(rv, b)
+	}
+
+	ai := b & 0x1f
+	n, err := dec.readMapLength(ai)
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); ai == 31 || i < n; i++ {
+		c, err := dec.readByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if ai == 31 && c == 0xff {
+			return nil
+		}
+		if i >= uint64(dec.options.MaxMapPairs) {
+			return dec.limitExceeded(ErrMapTooLong)
+		}
+
+		key, name, err := dec.mapKey(c)
+		if err != nil {
+			return err
+		}
+
+		c, err = dec.readByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		fields := sd.fields
+		if dec.options.JSONTags {
+			fields = sd.jsonFields
+		}
+		var (
+			fi int
+			ok bool
+		)
+		if key != nil {
+			fi, ok = structField(fields, key)
+		} else {
+			fi, ok = structField(fields, name)
+		}
+		if !ok {
+			if key != nil && (dec.disallowUnknownFields || dec.options.UnexportedFields == UnexportedFieldError) {
+				name = string(key)
+			}
+			if dec.options.UnexportedFields == UnexportedFieldError {
+				if f, ok := sd.unexportedField(name); ok {
+					return unexportedFieldError(rv.Type(), f)
+				}
+			}
+			if dec.disallowUnknownFields {
+				return newError(ErrUnknownField, fmt.Sprintf("cbor: unknown field %q in %s", name, rv.Type()))
+			}
+
+			// Skip the values of unknown keys.
+			if _, err := dec.appendRawItem(nil, c, 0); err != nil {
+				return fmt.Errorf("cbor: cannot unmarshal map key into %s: %w", rv.Type().String(), err)
+			}
+			continue
+		}
+
+		start, mark := dec.off, len(dec.partial)
+		err = sd.decoders[fi](dec, rv.Field(fi), c)
+		if len(dec.partial) > mark {
+			dec.partialPath(mark, "."+rv.Type().Field(fi).Name)
+		}
+		if err != nil {
+			err = dec.pathError(err, "."+rv.Type().Field(fi).Name)
+			if !dec.skipPartial(err, start, c) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unexportedField returns the name of the unexported field matching key,
+// preferring an exact match to a case-insensitive one, as structField does.
+func (sd *structDecoder) unexportedField(key string) (string, bool) {
+	for _, name := range sd.unexported {
+		if name == key {
+			return name, true
+		}
+	}
+	for _, name := range sd.unexported {
+		if strings.EqualFold(name, key) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// structField returns the index of the field in fc matching key,
+// preferring an exact match to a case-insensitive one.
+func structField[K string | []byte](fc fieldCache, key K) (int, bool) {
+	if i, ok := lookupField(fc, key); ok {
+		return i, true
+	}
+	return fc.lookupFold(string(key))
+}
+
+// compileSliceDecoder returns the decoderFunc for a slice type, other than
+// byte slices, which decodes arrays into the slice, reusing its capacity.
+// The elements are always decoded into zero values.
+func compileSliceDecoder(t reflect.Type) decoderFunc {
+	elem := decoderFor(t.Elem())
+	return func(dec *Decoder, rv reflect.Value, b byte) error {
+		if MajorType(b>>5) != MajorTypeArray {
+			return dec.decodeItem(rv, b)
+		}
+
+		ai := b & 0x1f
+		n, err := dec.readContainerLength(ai)
+		if err != nil {
+			return err
+		}
+
+		if ai == 31 {
+			rv.SetLen(0)
+			return dec.appendElements(rv, elem, -1)
+		}
+
+		if n > uint64(dec.options.MaxArrayElements) {
+			return dec.limitExceeded(newError(ErrArrayTooLong, "cbor: slice (array) too large"))
+		}
+
+		// Reuse the existing slice if possible. If the input is too short
+		// for n elements, the slice only grows as far as they're decoded.
+		switch {
+		case !rv.IsNil() && uint64(rv.Cap()) >= n:
+			// The elements are decoded into zero values, as they are
+			// when the slice is allocated.
+			rv.SetLen(int(n))
+			zero := reflect.Zero(t.Elem())
+			for i := 0; i < int(n); i++ {
+				rv.Index(i).Set(zero)
+			}
+		case dec.allocHint(n, 1) < int(n):
+			rv.Set(reflect.MakeSlice(t, 0, dec.allocHint(n, 1)))
+			return dec.appendElements(rv, elem, int(n))
+		default:
+			rv.Set(reflect.MakeSlice(t, int(n), int(n)))
+		}
+		return dec.decodeElements(rv, elem, int(n))
+	}
+}
+
+// appendElements decodes n elements of an array, or elements up to a break
+// code if n is negative, appending them to the slice rv.
+func (dec *Decoder) appendElements(rv reflect.Value, elem decoderFunc, n int) error {
+	t := rv.Type()
+	for i := 0; n < 0 || i < n; i++ {
+		c, err := dec.readByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if n < 0 && c == 0xff {
+			if rv.IsNil() {
+				rv.Set(reflect.MakeSlice(t, 0, 0))
+			}
+			return nil
+		}
+		if i >= dec.options.MaxArrayElements {
+			return dec.limitExceeded(newError(ErrArrayTooLong, "cbor: slice (array) too large"))
+		}
+		rv.Set(reflect.Append(rv, reflect.Zero(t.Elem())))
+		mark := len(dec.partial)
+		err = elem(dec, rv.Index(i), c)
+		if len(dec.partial) > mark {
+			dec.partialPath(mark, indexPath(i))
+		}
+		if err != nil {
+			return dec.pathError(err, indexPath(i))
+		}
+	}
+	return nil
+}
+
+// compileArrayDecoder returns the decoderFunc for an array type, which
+// decodes arrays of the same length.
+func compileArrayDecoder(t reflect.Type) decoderFunc {
+	elem := decoderFor(t.Elem())
+	return func(dec *Decoder, rv reflect.Value, b byte) error {
+		if MajorType(b>>5) != MajorTypeArray {
+			return dec.decodeItem(rv, b)
+		}
+
+		ai := b & 0x1f
+		n, err := dec.readContainerLength(ai)
+		if err != nil {
+			return err
+		}
+
+		// Like slices, arrays are replaced rather than merged into.
+		rv.Set(reflect.Zero(t))
+		if ai == 31 {
+			for i := 0; ; i++ {
+				c, err := dec.readByte()
+				if err != nil {
+					return unexpectedEOF(err)
+				}
+				if c == 0xff {
+					if i != t.Len() {
+						return newError(ErrInvalidType, "cbor: wrong array length")
+					}
+					return nil
+				}
+				if i >= t.Len() {
+					return newError(ErrInvalidType, "cbor: wrong array length")
+				}
+				mark := len(dec.partial)
+				err = elem(dec, rv.Index(i), c)
+				if len(dec.partial) > mark {
+					dec.partialPath(mark, indexPath(i))
+				}
+				if err != nil {
+					return dec.pathError(err, indexPath(i))
+				}
+			}
+		}
+
+		if n != uint64(t.Len()) {
+			return newError(ErrInvalidType, "cbor: wrong array length")
+		}
+		return dec.decodeElements(rv, elem, t.Len())
+	}
+}
+
+// decodeElements decodes the next n items into the elements of rv, a
+// slice or array of at least n elements.
+func (dec *Decoder) decodeElements(rv reflect.Value, elem decoderFunc, n int) error {
+	for i := 0; i < n; i++ {
+		c, err := dec.readByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		mark := len(dec.partial)
+		err = elem(dec, rv.Index(i), c)
+		if len(dec.partial) > mark {
+			dec.partialPath(mark, indexPath(i))
+		}
+		if err != nil {
+			return dec.pathError(err, indexPath(i))
+		}
+	}
+	return nil
+}
+
+// indexPath returns the path element of the i-th element of an array.
+func indexPath(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// keyPath returns the path element of the value of a map key, quoting
+// string keys.
+func keyPath(key interface{}) string {
+	if s, ok := key.(string); ok {
+		return "[" + strconv.Quote(s) + "]"
+	}
+	return fmt.Sprintf("[%v]", key)
+}
+
+// compileMapDecoder returns the decoderFunc for a map type, which decodes
+// maps into the map, allocating it if it's nil. The pairs already in the
+// map are kept, unless the decoded pairs replace them.
+func compileMapDecoder(t reflect.Type) decoderFunc {
+	textKey := reflect.PtrTo(t.Key()).Implements(textUnmarshalerType)
+	switch t.Key().Kind() {
+	case reflect.String, reflect.Interface, reflect.Ptr,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Struct:
+	default:
+		if !textKey {
+			return func(dec *Decoder, rv reflect.Value, b byte) error {
+				if MajorType(b>>5) != MajorTypeMap {
+					return dec.decodeItem(rv, b)
+				}
+				return typeError("map key", t.Key())
+			}
+		}
+	}
+
+	keyDec, elemDec := decoderFor(t.Key()), decoderFor(t.Elem())
+	if t.Key().Kind() == reflect.Struct && !reflect.PtrTo(t.Key()).Implements(unmarshalerType) {
+		keyDec = compileStructKeyDecoder(t.Key())
+	}
+	if textKey {
+		keyDec = textKeyDecoder(keyDec)
+	}
+	switch {
+	case t.Key() == byteStringType:
+	case t.Key().Kind() == reflect.String:
+		keyDec = stringKeyDecoder(keyDec)
+	case t.Key().Kind() == reflect.Interface:
+		keyDec = interfaceKeyDecoder(keyDec)
+	}
+	keyDec = typeFuncDecoder(t.Key(), keyDec)
+	return func(dec *Decoder, rv reflect.Value, b byte) error {
+		if MajorType(b>>5) != MajorTypeMap {
+			return dec.decodeItem(rv, b)
+		}
+
+		ai := b & 0x1f
+		n, err := dec.readMapLength(ai)
+		if err != nil {
+			return err
+		}
+
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(t))
+		}
+
+		// The key and value are reused for each pair, since SetMapIndex
+		// copies them.
+		key, val := reflect.New(t.Key()).Elem(), reflect.New(t.Elem()).Elem()
+		zeroKey, zeroVal := reflect.Zero(t.Key()), reflect.Zero(t.Elem())
+		for i := uint64(0); ai == 31 || i < n; i++ {
+			c, err := dec.readByte()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			if ai == 31 && c == 0xff {
+				return nil
+			}
+			if i >= uint64(dec.options.MaxMapPairs) {
+				return dec.limitExceeded(ErrMapTooLong)
+			}
+			key.Set(zeroKey)
+			if err := keyDec(dec, key, c); err != nil {
+				return err
+			}
+
+			c, err = dec.readByte()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			val.Set(zeroVal)
+			mark := len(dec.partial)
+			err = elemDec(dec, val, c)
+			if len(dec.partial) > mark {
+				dec.partialPath(mark, keyPath(key.Interface()))
+			}
+			if err != nil {
+				return dec.pathError(err, keyPath(key.Interface()))
+			}
+
+			rv.SetMapIndex(key, val)
+		}
+		return nil
+	}
+}
+
+// interfaceKeyDecoder returns the decoderFunc for a map key of an interface
+// type, which converts keys which aren't hashable with hashableKey.
+func interfaceKeyDecoder(base decoderFunc) decoderFunc {
+	return func(dec *Decoder, rv reflect.Value, b byte) error {
+		if err := base(dec, rv, b); err != nil {
+			return err
+		}
+		if rv.IsNil() {
+			return nil
+		}
+		k, err := hashableKey(rv.Elem().Interface())
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(&k).Elem())
+		return nil
+	}
+}
+
+// stringKeyDecoder returns the decoderFunc for a map key of a string kind,
+// which coerces keys other than text strings to strings when the decoder's
+// StringKeys option is set.
+func stringKeyDecoder(base decoderFunc) decoderFunc {
+	return func(dec *Decoder, rv reflect.Value, b byte) error {
+		if !dec.options.StringKeys || MajorType(b>>5) == MajorTypeTextString {
+			return base(dec, rv, b)
+		}
+		var v interface{}
+		if err := dec.decodeItem(reflect.ValueOf(&v).Elem(), b); err != nil {
+			return err
+		}
+		s, err := stringKey(v)
+		if err != nil {
+			return err
+		}
+		rv.SetString(s)
+		return nil
+	}
+}
+
+// textUnmarshalerType is the reflect.Type of the encoding.TextUnmarshaler
+// interface.
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// textKeyDecoder returns the decoderFunc for a map key type implementing
+// encoding.TextUnmarshaler, like encoding/json: text string keys are
+// decoded with UnmarshalText, and other keys with the decoderFunc of the
+// key type.
+func textKeyDecoder(base decoderFunc) decoderFunc {
+	return func(dec *Decoder, rv reflect.Value, b byte) error {
+		if MajorType(b>>5) != MajorTypeTextString {
+			return base(dec, rv, b)
+		}
+		var s string
+		if err := dec.decodeItem(reflect.ValueOf(&s).Elem(), b); err != nil {
+			return err
+		}
+		if err := rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("cbor: cannot unmarshal map key %q into %s: %w", s, rv.Type(), err)
+		}
+		return nil
+	}
+}
+
+// compileStructKeyDecoder returns the decoderFunc for a struct type used
+// as a map key, which decodes arrays of its exported fields in order, as
+// written by compileStructKeyEncoder.
+func compileStructKeyDecoder(t reflect.Type) decoderFunc {
+	decode := compileStructArrayDecoder(t, "map key "+t.String())
+	return func(dec *Decoder, rv reflect.Value, b byte) error {
+		if MajorType(b>>5) != MajorTypeArray {
+			return typeError(MajorType(b>>5).String()+" map key", t)
+		}
+		return decode(dec, rv, b)
+	}
+}
+
+// compileStructArrayDecoder returns the decoderFunc for a struct type with
+// the toarray option, or used as a map key, which decodes arrays of its
+// exported fields in order. Other items are decoded as usual. The arrays
+// must have an element for each field; what describes them in errors.
+func compileStructArrayDecoder(t reflect.Type, what string) decoderFunc {
+	var (
+		index []int
+		decs  []decoderFunc
+	)
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); !ignoredField(field) {
+			index = append(index, i)
+			decs = append(decs, decoderFor(field.Type))
+		}
+	}
+
+	return nestedDecoder(func(dec *Decoder, rv reflect.Value, b byte) error {
+		if MajorType(b>>5) != MajorTypeArray {
+			return dec.decodeItem(rv, b)
+		}
+		ai := b & 0x1f
+		n, err := dec.readContainerLength(ai)
+		if err != nil {
+			return err
+		}
+		if ai != 31 && n != uint64(len(index)) {
+			return newError(ErrInvalidType, "cbor: wrong array length for "+what)
+		}
+		for i := 0; ai == 31 || i < len(index); i++ {
+			c, err := dec.readByte()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			if ai == 31 && c == 0xff {
+				if i != len(index) {
+					return newError(ErrInvalidType, "cbor: wrong array length for "+what)
+				}
+				return nil
+			}
+			if i >= len(index) {
+				return newError(ErrInvalidType, "cbor: wrong array length for "+what)
+			}
+			if err := decs[i](dec, rv.Field(index[i]), c); err != nil {
+				return dec.pathError(err, "."+t.Field(index[i]).Name)
+			}
+		}
+		return nil
+	})
+}
+
+// decodeFloatPlan decodes floats of any size into a float value, and
+// integers as the nearest float.
+func decodeFloatPlan(dec *Decoder, rv reflect.Value, b byte) error {
+	var f float64
+	switch {
+	case b == 0xf9:
+		var err error
+		if f, err = dec.readFloat16(); err != nil {
+			return err
+		}
+	case b == 0xfa:
+		var err error
+		if f, err = dec.readFloat32(); err != nil {
+			return err
+		}
+	case b == 0xfb:
+		var err error
+		if f, err = dec.readFloat64(); err != nil {
+			return err
+		}
+	case b>>5 == byte(MajorTypeUnsignedInt) || b>>5 == byte(MajorTypeNegativeInt):
+		n, err := dec.readArgument(b & 0x1f)
+		if err != nil {
+			return err
+		}
+		f = float64(n)
+		if MajorType(b>>5) == MajorTypeNegativeInt {
+			f = -1 - f
+		}
+	default:
+		return dec.decodeItem(rv, b)
+	}
+	if f == 0 && dec.options.NegativeZero == NegativeZeroNormalize {
+		f = 0
+	}
+	rv.SetFloat(f)
+	return nil
+}
+
+// readArgument reads the argument of a header with the given additional
+// information, which must not be 31.
+func (dec *Decoder) readArgument(ai byte) (uint64, error) {
+	switch {
+	case ai < 24:
+		return uint64(ai), nil
+	case ai == 24:
+		return dec.readUint8()
+	case ai == 25:
+		return dec.readUint16()
+	case ai == 26:
+		return dec.readUint32()
+	case ai == 27:
+		return dec.readUint64()
+	default:
+		return 0, dec.syntaxError(fmt.Sprintf("reserved additional information %d", ai))
+	}
+}
+
+// readContainerLength reads the number of elements or pairs of an array or
+// map with the given additional information, which is zero for
+// indefinite-length containers.
+func (dec *Decoder) readContainerLength(ai byte) (uint64, error) {
+	if ai == 31 {
+		return 0, nil
+	}
+	n, err := dec.readArgument(ai)
+	return n, unexpectedEOF(err)
+}
+
+// readArrayLength reads the number of elements of an array like
+// readContainerLength, checking it against the decoder's MaxArrayElements
+// limit before anything is allocated for them.
+func (dec *Decoder) readArrayLength(ai byte) (uint64, error) {
+	n, err := dec.readContainerLength(ai)
+	if err == nil && n > uint64(dec.options.MaxArrayElements) {
+		return 0, dec.limitExceeded(ErrArrayTooLong)
+	}
+	return n, err
+}
+
+// readMapLength reads the number of pairs of a map like
+// readContainerLength, checking it against the decoder's MaxMapPairs limit
+// before anything is allocated for them.
+func (dec *Decoder) readMapLength(ai byte) (uint64, error) {
+	n, err := dec.readContainerLength(ai)
+	if err == nil && n > uint64(dec.options.MaxMapPairs) {
+		return 0, dec.limitExceeded(ErrMapTooLong)
+	}
+	return n, err
+}
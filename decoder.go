@@ -1,7 +1,9 @@
 package cbor
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/binary"
 	"errors"
@@ -15,6 +17,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // MajorType is the major type of a CBOR item.
@@ -49,6 +52,30 @@ const (
 	MajorTypeSimple MajorType = 7 // simple (bool, nil, etc.)
 )
 
+// String returns a human readable name of the major type.
+func (mt MajorType) String() string {
+	switch mt {
+	case MajorTypeUnsignedInt:
+		return "unsigned integer"
+	case MajorTypeNegativeInt:
+		return "negative integer"
+	case MajorTypeByteString:
+		return "byte string"
+	case MajorTypeTextString:
+		return "text string"
+	case MajorTypeArray:
+		return "array"
+	case MajorTypeMap:
+		return "map"
+	case MajorTypeTag:
+		return "tag"
+	case MajorTypeSimple:
+		return "simple value"
+	default:
+		return "MajorType(" + strconv.Itoa(int(mt)) + ")"
+	}
+}
+
 // SimpleValue is a simple value.
 //
 // https://tools.ietf.org/html/rfc7049#section-2.3
@@ -223,16 +250,54 @@ type Marshaler interface {
 //
 // Otherwise, if the CBOR data is a CBOR array, Unmarshal decodes the CBOR array
 // into the slice pointed to by v. If v is not a pointer to a slice, Unmarshal
-// returns an InvalidUnmarshalError.
+// returns an error wrapping ErrInvalidType.
 //
 // Otherwise, if the CBOR data is a CBOR map, Unmarshal decodes the CBOR map into
 // the map pointed to by v. If v is not a pointer to a map, Unmarshal returns an
-// InvalidUnmarshalError.
+// error wrapping ErrInvalidType.
 //
-// Otherwise, Unmarshal decodes the CBOR data into the value pointed to by v. If
-// v is not a pointer, Unmarshal returns an InvalidUnmarshalError.
+// Otherwise, Unmarshal decodes the CBOR data into the value pointed to by v.
+//
+// Decoding into a value which isn't zero merges the data into it. Maps
+// keep their pairs, with the decoded pairs added to them or replacing
+// them, and structs keep the fields missing from the data. Pointers which
+// aren't nil, and interfaces holding them, are decoded through. Other
+// values are replaced, including the values of map pairs and the elements
+// of arrays and slices, which are decoded into zero values, though slices
+// reuse their capacity. With the ZeroTarget option, set with
+// SetZeroTarget, values are zeroed before anything is decoded into them,
+// so nothing is merged.
 func Unmarshal(data []byte, v interface{}) error {
-	return NewDecoder(bytes.NewReader(data)).Decode(v)
+	// Types that unmarshal themselves are given the encoded item
+	// directly, without going through a Decoder.
+	if u, ok := v.(Unmarshaler); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+			n, err := itemLength(data, 0)
+			if err != nil {
+				return err
+			}
+			return u.UnmarshalCBOR(data[:n])
+		}
+	}
+	dec := getDecoder(data)
+	err := dec.Decode(v)
+	putDecoder(dec)
+	return err
+}
+
+// UnmarshalToType decodes the CBOR item in data into a new value of type
+// t, and returns it, like Unmarshal. It's meant for code which only knows
+// the type to decode into at run time, such as plugin systems and schema
+// registries.
+func UnmarshalToType(data []byte, t reflect.Type) (interface{}, error) {
+	if t == nil {
+		return nil, &InvalidUnmarshalError{}
+	}
+	rv := reflect.New(t)
+	if err := Unmarshal(data, rv.Interface()); err != nil {
+		return nil, err
+	}
+	return rv.Elem().Interface(), nil
 }
 
 // A Decoder reads and decodes CBOR values from an input stream.
@@ -252,6 +317,42 @@ type Decoder struct {
 
 	// options is the decoder options.
 	options *DecoderOptions
+
+	// stats wraps the underlying reader to count the data read.
+	stats *statsReader
+
+	// depth is the number of containers being decoded.
+	depth int
+
+	// mem is set for decoders reading from memory, which read data with
+	// index arithmetic rather than through r. off is the offset of the
+	// next byte in data, and synced is the offset up to which src and the
+	// stats have been updated.
+	mem    bool
+	data   []byte
+	off    int
+	synced int
+
+	// src is the *bytes.Buffer or *bytes.Reader data is read from, if any,
+	// and base is the offset in a *bytes.Reader of data[0].
+	src  io.Reader
+	base int64
+
+	// br buffers readers which aren't byte-oriented, if any.
+	br *bufio.Reader
+
+	// arena allocates decoded strings and arrays, if set.
+	arena *Arena
+
+	// disallowUnknownFields is set by DisallowUnknownFields.
+	disallowUnknownFields bool
+
+	// partial is the errors skipped while decoding a value in partial
+	// mode.
+	partial []error
+
+	// ctx is the context of the DecodeContext call in progress, if any.
+	ctx context.Context
 }
 
 // Decoder options.
@@ -260,6 +361,61 @@ type DecoderOptions struct {
 	MaxMapPairs      int
 	MaxStringBytes   int
 	MaxBytes         int
+	MaxDepth         int
+
+	// ErrorContext is the number of bytes of input on each side of the
+	// failing offset which are included in a DecodeError, when decoding
+	// from memory.
+	ErrorContext int
+
+	// DurationMode controls whether integers decoded into time.Duration
+	// values are nanoseconds or seconds. Floats are always seconds.
+	DurationMode DurationMode
+
+	// StringKeys coerces map keys to strings when decoding maps into
+	// interface values, which are then map[string]interface{}, and into
+	// maps with string keys. See SetStringKeys.
+	StringKeys bool
+
+	// TimeLayouts are the layouts date/time strings are parsed with when
+	// they aren't RFC 3339. See SetTimeLayouts.
+	TimeLayouts []string
+
+	// Partial continues decoding past fields which fail to decode. See
+	// SetPartial.
+	Partial bool
+
+	// TruncateFloats allows floats with a fraction to be decoded into
+	// integers, discarding the fraction. See SetTruncateFloats.
+	TruncateFloats bool
+
+	// JSONTags makes struct fields without a cbor tag use their json tag.
+	// See SetJSONTags.
+	JSONTags bool
+
+	// UnknownTags controls how tags the decoder doesn't know are decoded.
+	// See SetUnknownTagMode.
+	UnknownTags UnknownTagMode
+
+	// NegativeZero controls whether floats which are negative zero keep
+	// their sign. See SetNegativeZero.
+	NegativeZero NegativeZeroMode
+
+	// FloatChecks are the checks floats must pass to be decoded. See
+	// SetFloatChecks.
+	FloatChecks FloatChecks
+
+	// ZeroTarget zeroes the value decoded into first, rather than merging
+	// the data into it. See SetZeroTarget.
+	ZeroTarget bool
+
+	// UnexportedFields controls whether map keys matching unexported
+	// struct fields are an error. See SetUnexportedFields.
+	UnexportedFields UnexportedFieldMode
+
+	// TypeDecoders are the functions decoding items into values of
+	// specific types. See SetTypeDecoder.
+	TypeDecoders map[reflect.Type]TypeDecoderFunc
 }
 
 // DefaultDecoderOptions is the default decoder options used
@@ -269,6 +425,8 @@ var DefaultDecoderOptions = DecoderOptions{
 	MaxMapPairs:      DefaultMaxValue,
 	MaxStringBytes:   DefaultMaxValue,
 	MaxBytes:         DefaultMaxValue,
+	MaxDepth:         DefaultMaxDepth,
+	ErrorContext:     DefaultErrorContext,
 }
 
 // DefaultMaxValue is the default maximum value for the decoder
@@ -281,21 +439,153 @@ var DefaultDecoderOptions = DecoderOptions{
 // also useful for mitigating DoS attacks.
 const DefaultMaxValue = 10_000
 
+// DefaultMaxDepth is the default maximum nesting depth of arrays and maps
+// for the decoder. The nesting of items decoded into interface values is
+// tracked without recursion, so the limit can be raised without risking
+// the goroutine stack, but each level still uses some memory.
+const DefaultMaxDepth = maxNestingDepth
+
+// DefaultErrorContext is the default number of bytes of input on each side
+// of the failing offset which are included in decoding errors.
+const DefaultErrorContext = 8
+
 // NewDecoder returns a new decoder that reads from r.
+//
+// If r is a *bytes.Buffer or a *bytes.Reader, the decoder reads its
+// content directly, advancing it past each item as it's decoded. Other
+// readers which don't implement io.ByteReader are buffered, so the
+// decoder may read data from r beyond the items it decodes, which is
+// available from Buffered.
 func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{
-		r:       r,
+	var (
+		mem bool
+		br  *bufio.Reader
+	)
+	switch r.(type) {
+	case *bytes.Buffer, *bytes.Reader:
+		mem = true
+	case io.ByteReader:
+		// Already buffered, or in memory.
+	default:
+		br = bufio.NewReader(r)
+	}
+
+	stats := &statsReader{r: r}
+	if br != nil {
+		stats.r = br
+	}
+	stats.br, _ = stats.r.(io.ByteReader)
+	dec := &Decoder{
+		r:       stats,
 		buffer:  make([]byte, 0, 512), // 512 is the default bufio size
 		options: &DefaultDecoderOptions,
+		stats:   stats,
+		br:      br,
+	}
+	if mem {
+		dec.mem = true
+		dec.src = r
+	}
+	return dec
+}
+
+// NewDecoderBytes returns a new decoder that reads from data, without the
+// indirection of a bytes.Reader. It's meant for decoding a sequence of
+// items in memory, or for using the options of a Decoder with data which
+// would otherwise be decoded with Unmarshal.
+func NewDecoderBytes(data []byte) *Decoder {
+	stats := &statsReader{}
+	return &Decoder{
+		r:       stats,
+		options: &DefaultDecoderOptions,
+		stats:   stats,
+		mem:     true,
+		data:    data,
+	}
+}
+
+// Buffered returns a reader of the data read from the decoder's input
+// which hasn't been decoded yet, which is the rest of the data for a
+// decoder from NewDecoderBytes. The reader is valid until the next call
+// to Decode.
+func (dec *Decoder) Buffered() io.Reader {
+	if dec.mem && dec.src == nil {
+		return bytes.NewReader(dec.data[dec.off:])
 	}
+	if dec.br == nil {
+		return bytes.NewReader(nil)
+	}
+	b, _ := dec.br.Peek(dec.br.Buffered())
+	return bytes.NewReader(b)
+}
+
+// refill updates the data of a decoder reading from a *bytes.Buffer or a
+// *bytes.Reader with the current content of its source, which may have
+// changed since the last item was read.
+func (dec *Decoder) refill() {
+	switch src := dec.src.(type) {
+	case *bytes.Buffer:
+		dec.data, dec.off, dec.synced = src.Bytes(), 0, 0
+	case *bytes.Reader:
+		// A bytes.Reader doesn't expose its content, so its unread bytes
+		// are copied, and reused for as long as the reader is only
+		// advanced by the decoder.
+		pos := src.Size() - int64(src.Len())
+		if dec.data != nil && pos == dec.base+int64(dec.synced) && src.Size() == dec.base+int64(len(dec.data)) {
+			return
+		}
+		dec.data = make([]byte, src.Len())
+		src.ReadAt(dec.data, pos)
+		dec.base, dec.off, dec.synced = pos, 0, 0
+	}
+}
+
+// commit advances the source of a decoder reading from memory past the
+// bytes read since the last commit, and counts them in its stats.
+func (dec *Decoder) commit() {
+	if !dec.mem {
+		return
+	}
+	n := dec.off - dec.synced
+	if dec.stats != nil {
+		dec.stats.scan(dec.data[dec.synced:dec.off])
+	}
+	switch src := dec.src.(type) {
+	case *bytes.Buffer:
+		src.Next(n)
+	case *bytes.Reader:
+		src.Seek(int64(n), io.SeekCurrent)
+	}
+	dec.synced = dec.off
+}
+
+// SetOptions replaces all the options of the decoder with opts, such as
+// FxamackerDecoderOptions. Like the setters, it doesn't change
+// DefaultDecoderOptions.
+func (dec *Decoder) SetOptions(opts DecoderOptions) {
+	dec.options = &opts
+}
+
+// ownOptions returns the options of the decoder for a setter to change,
+// copying DefaultDecoderOptions first if the decoder still uses them, so
+// the setters don't change the defaults of every other decoder and of
+// Unmarshal.
+func (dec *Decoder) ownOptions() *DecoderOptions {
+	if dec.options == &DefaultDecoderOptions {
+		options := DefaultDecoderOptions
+		dec.options = &options
+	}
+	return dec.options
 }
 
 // SetMax sets all the maximum values to n.
 func (dec *Decoder) SetMax(n int) {
-	dec.options.MaxArrayElements = n
-	dec.options.MaxMapPairs = n
-	dec.options.MaxStringBytes = n
-	dec.options.MaxBytes = n
+	options := dec.ownOptions()
+	options.MaxArrayElements = n
+	options.MaxMapPairs = n
+	options.MaxStringBytes = n
+	options.MaxBytes = n
+	options.MaxDepth = n
 }
 
 // SetMaxArrayElements sets the maximum number of elements in an array.
@@ -305,7 +595,7 @@ func (dec *Decoder) SetMax(n int) {
 //
 // The default limit is 10,000.
 func (dec *Decoder) SetMaxArrayElements(n int) {
-	dec.options.MaxArrayElements = n
+	dec.ownOptions().MaxArrayElements = n
 }
 
 // SetMaxMapPairs sets the maximum number of pairs in a map.
@@ -314,7 +604,7 @@ func (dec *Decoder) SetMaxArrayElements(n int) {
 //
 // The default limit is 10,000.
 func (dec *Decoder) SetMaxMapPairs(n int) {
-	dec.options.MaxMapPairs = n
+	dec.ownOptions().MaxMapPairs = n
 }
 
 // SetMaxStringBytes sets the maximum number of bytes in a string.
@@ -324,7 +614,7 @@ func (dec *Decoder) SetMaxMapPairs(n int) {
 //
 // The default limit is 10,000.
 func (dec *Decoder) SetMaxStringBytes(n int) {
-	dec.options.MaxStringBytes = n
+	dec.ownOptions().MaxStringBytes = n
 }
 
 // SetMaxBytes sets the maximum number of bytes in a byte string.
@@ -334,7 +624,153 @@ func (dec *Decoder) SetMaxStringBytes(n int) {
 //
 // The default limit is 10,000.
 func (dec *Decoder) SetMaxBytes(n int) {
-	dec.options.MaxBytes = n
+	dec.ownOptions().MaxBytes = n
+}
+
+// SetMaxDepth sets the maximum nesting depth of arrays and maps.
+//
+// If an item is nested deeper than this limit, an error is returned.
+//
+// The default limit is 1,000.
+func (dec *Decoder) SetMaxDepth(n int) {
+	dec.ownOptions().MaxDepth = n
+}
+
+// SetTruncateFloats sets whether floats with a fraction, such as 1.5, can
+// be decoded into integer types, discarding the fraction. Otherwise, they
+// are an UnmarshalTypeError. Floats which are whole numbers are decoded
+// into integers either way, if they're in range.
+//
+// It's disabled by default.
+func (dec *Decoder) SetTruncateFloats(on bool) {
+	dec.ownOptions().TruncateFloats = on
+}
+
+// SetNegativeZero sets whether floats which are negative zero are decoded
+// as negative zero, or as positive zero, for applications which must not
+// tell them apart.
+//
+// The default is NegativeZeroPreserve.
+func (dec *Decoder) SetNegativeZero(mode NegativeZeroMode) {
+	dec.ownOptions().NegativeZero = mode
+}
+
+// SetZeroTarget sets whether the value decoded into is zeroed first, so
+// its maps and structs only have the pairs and fields in the data, rather
+// than the data being merged into it, as described for Unmarshal. This is
+// for decoding into reused values, which could otherwise keep stale pairs
+// and fields from a previous item.
+//
+// It's disabled by default.
+func (dec *Decoder) SetZeroTarget(on bool) {
+	dec.ownOptions().ZeroTarget = on
+}
+
+// SetUnexportedFields sets whether map keys matching the name of an
+// unexported field of the struct they're decoded into are skipped, as
+// unknown keys are, or are an error wrapping ErrUnexportedField. Keys
+// match names as they match the names of exported fields, ignoring case
+// if there is no exact match.
+//
+// The default is UnexportedFieldIgnore.
+func (dec *Decoder) SetUnexportedFields(mode UnexportedFieldMode) {
+	dec.ownOptions().UnexportedFields = mode
+}
+
+// SetFloatChecks sets the checks floats must pass to be decoded, such as
+// StrictFloats, for profiles which restrict them. Floats which fail them
+// are an error wrapping ErrInvalidFloat, or an UnmarshalTypeError for
+// floats decoded into integers.
+//
+// No checks are made by default.
+func (dec *Decoder) SetFloatChecks(checks FloatChecks) {
+	dec.ownOptions().FloatChecks = checks
+}
+
+// SetPartial sets whether decoding is best-effort: struct fields which
+// fail to decode are skipped, and the rest of the value is decoded, which
+// is useful for examining corrupted or truncated data. The errors are
+// returned together as DecodeErrors, and the value is filled with
+// whatever could be decoded, up to the end of the data if it's
+// truncated.
+//
+// Skipping fields requires the input to be in memory, as with Unmarshal,
+// NewDecoderBytes, or a Decoder reading from a *bytes.Reader or
+// *bytes.Buffer. Otherwise,
+// the first error ends decoding as usual.
+//
+// It's disabled by default.
+func (dec *Decoder) SetPartial(on bool) {
+	dec.ownOptions().Partial = on
+}
+
+// SetTimeLayouts sets additional layouts, as accepted by time.Parse, of
+// the date/time strings (tag 0) decoded into time.Time values, such as
+// timestamps without a time zone emitted by embedded devices. Strings are
+// parsed as RFC 3339 first, then with each layout in order.
+//
+// The layouts replace any set previously. The default is none.
+func (dec *Decoder) SetTimeLayouts(layouts ...string) {
+	dec.ownOptions().TimeLayouts = layouts
+}
+
+// SetStringKeys sets whether map keys are coerced to strings when maps are
+// decoded into interface values, which are then map[string]interface{},
+// and into maps with string keys, so the results can be consumed directly
+// by encoding/json and templates.
+//
+// Integers and floats are formatted in decimal, byte strings are encoded
+// in base64url without padding, and booleans and null are written as in
+// JSON. Other keys, such as arrays, can't be coerced and are an error.
+//
+// It's disabled by default.
+func (dec *Decoder) SetStringKeys(on bool) {
+	dec.ownOptions().StringKeys = on
+}
+
+// SetJSONTags sets whether struct fields without a cbor tag match the name
+// in their json tag, so types already tagged for encoding/json don't need
+// cbor tags too. Fields with the json tag "-" are left out.
+//
+// It's disabled by default.
+func (dec *Decoder) SetJSONTags(on bool) {
+	dec.ownOptions().JSONTags = on
+}
+
+// SetUnknownTagMode sets how tags the decoder doesn't know are decoded:
+// as an error, ignoring the tag, or as TagValue values.
+//
+// The default is UnknownTagError.
+func (dec *Decoder) SetUnknownTagMode(mode UnknownTagMode) {
+	dec.ownOptions().UnknownTags = mode
+}
+
+// SetDurationMode sets whether integers decoded into time.Duration values
+// are nanoseconds or seconds. Floats are always decoded as seconds, and
+// items with TagDuration as the units they specify.
+//
+// The default is DurationNanoseconds.
+func (dec *Decoder) SetDurationMode(mode DurationMode) {
+	dec.ownOptions().DurationMode = mode
+}
+
+// SetErrorContext sets the number of bytes of input on each side of the
+// failing offset which are included in decoding errors, as a hex snippet.
+// Setting it to 0 leaves the snippets out.
+//
+// Snippets are only available when decoding from memory: with Unmarshal,
+// or a Decoder reading from a *bytes.Buffer or *bytes.Reader.
+//
+// The default is 8 bytes.
+func (dec *Decoder) SetErrorContext(n int) {
+	dec.ownOptions().ErrorContext = n
+}
+
+// DisallowUnknownFields causes the decoder to return an error wrapping
+// ErrUnknownField when a map key doesn't match any exported field of the
+// struct it's decoded into, rather than skipping its value.
+func (dec *Decoder) DisallowUnknownFields() {
+	dec.disallowUnknownFields = true
 }
 
 // Decode reads the next CBOR-encoded value from its input and stores
@@ -346,290 +782,637 @@ func (dec *Decoder) Decode(v interface{}) error {
 	// Check that v is a pointer and not nil.
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
-		return fmt.Errorf("cbor: Decode(non-pointer %s)", rv.Type())
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
 	}
 
 	// Decode the CBOR value into the value pointed to by v.
-	err := dec.decodeValue(rv.Elem())
-	if err != nil {
-		return fmt.Errorf("cbor: Decode(%v): %v", rv.Type(), err)
-	}
-
-	return nil
+	return dec.decodeRoot(rv.Elem())
 }
 
-// readByte reads a single byte from the input stream.
+// DecodeContext is like Decode, but stops with ctx.Err() if ctx is done
+// before the value is decoded, so a server reading a large body from a
+// slow client can give up when the request is canceled or times out.
 //
-// This is the basic building block for all other CBOR decoding.
-func (dec *Decoder) readByte() (byte, error) {
-	_, err := dec.r.Read(dec.buf[:])
-	if err != nil {
-		return 0, err
+// The context is checked before each read from the underlying reader,
+// which is at least once for each item and chunk of a string, unless the
+// decoder reads from memory, as one from NewDecoderBytes or reading from a
+// *bytes.Buffer or *bytes.Reader does, in which case it's only checked
+// before decoding starts. A read which is blocked waiting for
+// data isn't interrupted, so connections should also have a deadline.
+func (dec *Decoder) DecodeContext(ctx context.Context, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	return dec.buf[0], nil
+	dec.ctx = ctx
+	err := dec.Decode(v)
+	dec.ctx = nil
+	return err
 }
 
-// readHeader reads the header byte and returns the major type and additional
-// information. This is called before obtaining the value of a CBOR item.
-func (dec *Decoder) readHeader() (majorType MajorType, additionalInfo byte, err error) {
-	b, err := dec.readByte()
-	if err != nil {
-		return 0, 0, err
+// DecodeValue reads the next CBOR-encoded value from its input and stores
+// it in rv, which must be settable, like Decode does with the value a
+// pointer points to.
+func (dec *Decoder) DecodeValue(rv reflect.Value) error {
+	if !rv.IsValid() {
+		return &InvalidUnmarshalError{}
 	}
-	return MajorType(b >> 5), b & 0x1f, nil
+	if !rv.CanSet() {
+		return &InvalidUnmarshalError{Type: rv.Type()}
+	}
+	return dec.decodeRoot(rv)
 }
 
-// decodeValue decodes a CBOR value into the given reflect.Value.
-func (dec *Decoder) decodeValue(rv reflect.Value) error {
-	// Read the header, which contains the major type and additional
-	// information about the value.
-	mt, ai, err := dec.readHeader()
+// ReadRaw reads the next CBOR-encoded value from its input and returns its
+// encoded bytes, exactly as they were read, such as to verify a signature
+// over them or to decode them later. The value is checked to be
+// well-formed, and the returned message is a copy which the caller owns.
+func (dec *Decoder) ReadRaw() (RawMessage, error) {
+	dec.refill()
+	raw, err := dec.readRaw()
+	dec.commit()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return raw, nil
+}
 
-	// Decode the value based on the major type.
-	switch MajorType(mt) {
-	case MajorTypeUnsignedInt:
-		return dec.decodeUint(rv, ai)
-	case MajorTypeNegativeInt:
-		return dec.decodeInt(rv, ai)
-	case MajorTypeByteString:
-		return dec.decodeBytes(rv, ai)
-	case MajorTypeTextString:
-		return dec.decodeString(rv, ai)
-	case MajorTypeArray:
-		return dec.decodeArray(rv, ai)
-	case MajorTypeMap:
-		return dec.decodeMap(rv, ai)
-	case MajorTypeTag:
-		return dec.decodeTag(rv, ai)
-	case MajorTypeSimple:
-		return dec.decodeSimpleValue(rv, ai)
-	default:
-		return errors.New("cbor: invalid major type")
+// SkipValue reads the next CBOR-encoded value from its input and discards
+// it, such as a field a protocol decoder doesn't need. The value is checked
+// to be well-formed, but isn't decoded, and the content of its strings
+// isn't copied.
+func (dec *Decoder) SkipValue() error {
+	dec.refill()
+	b, err := dec.readByte()
+	if err == nil {
+		err = dec.skipItem(b, 0)
 	}
+	dec.commit()
+	return err
 }
 
-// decodeSimpleValue decodes a CBOR simple value into the given reflect.Value.
-func (dec *Decoder) decodeSimpleValue(rv reflect.Value, ai byte) error {
-	// Decode the simple value based on the additional information.
-	switch SimpleValue(ai) {
-	case SimpleValueFalse:
-		// If the reflect.Value is a pointer, when we can possibly
-		// convert it to a bool.
-		if rv.Kind() == reflect.Ptr && rv.Type().Elem().Kind() == reflect.Bool {
-			rv.Set(reflect.New(rv.Type().Elem()))
-			rv = rv.Elem()
-		}
-		rv.SetBool(false)
-	case SimpleValueTrue:
-		// If the reflect.Value is a pointer, when we can possibly
-		// convert it to a bool.
-		if rv.Kind() == reflect.Ptr && rv.Type().Elem().Kind() == reflect.Bool {
-			rv.Set(reflect.New(rv.Type().Elem()))
-			rv = rv.Elem()
-		}
-		rv.SetBool(true)
-	case SimpleValueNull:
-		rv.Set(reflect.Zero(rv.Type()))
-	case SimpleValueUndefined:
-	// Do nothing.
-	case SimpleValueFloat16:
-		return errors.New("cbor: float16 not supported") // TODO: Implement float16?
-	case SimpleValueFloat32:
-		f, err := dec.readFloat32()
-		if err != nil {
-			return err
-		}
-
-		switch rv.Kind() {
-		case reflect.Float32:
-			rv.SetFloat(float64(f))
-		case reflect.Float64:
-			rv.SetFloat(float64(f))
-		case reflect.Pointer:
-			// If the reflect.Value is a pointer, when we can possibly
-			// convert it to a float32 or float64.
-			switch rv.Type().Elem().Kind() {
-			case reflect.Float32:
-				f := float32(f)
-				rv.Set(reflect.ValueOf(&f))
-			case reflect.Float64:
-				rv.Set(reflect.ValueOf(&f))
-			default:
-				rv.Set(reflect.ValueOf(f))
-			}
-		default:
-			rv.Set(reflect.ValueOf(f))
-		}
-	case SimpleValueFloat64:
-		f, err := dec.readFloat64()
-		if err != nil {
-			return err
-		}
-
-		switch rv.Kind() {
-		case reflect.Float32:
-			rv.SetFloat(float64(f))
-		case reflect.Float64:
-			rv.SetFloat(f)
-		case reflect.Pointer:
-			// If the reflect.Value is a pointer, when we can possibly
-			// convert it to a float32 or float64.
-			switch rv.Type().Elem().Kind() {
-			case reflect.Float32:
-				f := float32(f)
-				rv.Set(reflect.ValueOf(&f))
-			case reflect.Float64:
-				rv.Set(reflect.ValueOf(&f))
-			default:
-				rv.Set(reflect.ValueOf(f))
-			}
-		default:
-			rv.Set(reflect.ValueOf(f))
-		}
-	default:
-		return fmt.Errorf("cbor: invalid simple value: %v", ai)
+// decodeRoot decodes the next value into rv, the value decoded into by
+// Decode or DecodeValue.
+func (dec *Decoder) decodeRoot(rv reflect.Value) error {
+	dec.refill()
+	b, err := dec.readByte()
+	if err != nil {
+		err = dec.pathError(err, rootPath(rv.Type()))
+	} else {
+		err = dec.decodeTop(rv, b, rootPath(rv.Type()))
 	}
-	return nil
+	dec.commit()
+	return err
 }
 
-// decodeUint decodes a CBOR unsigned integer into the given reflect.Value.
-func (dec *Decoder) decodeUint(rv reflect.Value, ai byte) error {
-	var (
-		n   uint64
-		err error
-	)
-	switch ai {
-	case 24:
-		n, err = dec.readUint8()
-	case 25:
-		n, err = dec.readUint16()
-	case 26:
-		n, err = dec.readUint32()
-	case 27:
-		n, err = dec.readUint64()
-	default:
-		n = uint64(ai)
+// decodeTop decodes the top-level value whose initial byte b has already
+// been read into rv, with elem as the first element of the path of its
+// errors. In partial mode, the errors of the fields which were skipped are
+// returned as DecodeErrors.
+func (dec *Decoder) decodeTop(rv reflect.Value, b byte, elem string) error {
+	if dec.options.ZeroTarget {
+		rv.Set(reflect.Zero(rv.Type()))
+	}
+	dec.partial = dec.partial[:0]
+	err := decoderFor(rv.Type())(dec, rv, b)
+	if len(dec.partial) > 0 {
+		dec.partialPath(0, elem)
 	}
 	if err != nil {
-		return err
+		err = dec.pathError(err, elem)
 	}
-
-	switch rv.Kind() {
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		rv.SetUint(n)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		rv.SetInt(int64(n))
-	case reflect.Interface:
-		rv.Set(reflect.ValueOf(n))
-	case reflect.Ptr:
-		if rv.IsNil() {
-			rv.Set(reflect.New(rv.Type().Elem()))
-		}
-		switch rv.Elem().Kind() {
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			rv.Elem().SetUint(n)
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			rv.Elem().SetInt(int64(n))
-		case reflect.Interface:
-			rv.Elem().Set(reflect.ValueOf(n))
-		default:
-			return errors.New("cbor: cannot unmarshal uint into " + rv.Type().String())
+	if len(dec.partial) > 0 {
+		errs := append(DecodeErrors(nil), dec.partial...)
+		if err != nil {
+			errs = append(errs, err)
 		}
-	default:
-		return errors.New("cbor: cannot unmarshal uint into " + rv.Type().String())
+		return errs
 	}
-	return nil
+	return err
 }
 
-// readUint8 reads an 8-bit unsigned integer from the input stream.
-func (dec *Decoder) readUint8() (uint64, error) {
-	b, err := dec.readByte()
-	return uint64(b), err
+// rootPath returns the first element of the path in a DecodeError, which
+// is the name of the type decoded into, or the type itself if it's
+// unnamed.
+func rootPath(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr && t.Name() == "" {
+		t = t.Elem()
+	}
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return t.String()
 }
 
-// readUint16 reads a 16-bit unsigned integer from the input stream.
-func (dec *Decoder) readUint16() (uint64, error) {
-	// Reuse dec.buffer if it's large enough.
-	if cap(dec.buffer) < 2 {
-		dec.buffer = make([]byte, 2)
+// readByte reads a single byte from the input stream.
+//
+// This is the basic building block for all other CBOR decoding.
+func (dec *Decoder) readByte() (byte, error) {
+	if dec.mem {
+		if dec.off >= len(dec.data) {
+			return 0, io.EOF
+		}
+		b := dec.data[dec.off]
+		dec.off++
+		return b, nil
 	}
-	buf := dec.buffer[:2]
-	if _, err := dec.r.Read(buf[:]); err != nil {
-		if err == io.EOF {
-			err = io.ErrUnexpectedEOF
+	if dec.ctx != nil {
+		if err := dec.contextErr(); err != nil {
+			return 0, err
 		}
+	}
+	if dec.stats != nil && dec.stats.br != nil {
+		return dec.stats.ReadByte()
+	}
+	if _, err := io.ReadFull(dec.r, dec.buf[:]); err != nil {
 		return 0, err
 	}
-	return uint64(buf[0])<<8 | uint64(buf[1]), nil
+	return dec.buf[0], nil
 }
 
-// readUint32 reads a 32-bit unsigned integer from the input stream.
-func (dec *Decoder) readUint32() (uint64, error) {
-	// Reuse dec.buffer if it's large enough.
-	if cap(dec.buffer) < 4 {
-		dec.buffer = make([]byte, 4)
+// readFull reads exactly len(p) bytes into p, returning io.EOF if no
+// bytes were read, and io.ErrUnexpectedEOF if only some were, like
+// io.ReadFull.
+func (dec *Decoder) readFull(p []byte) error {
+	if dec.mem {
+		n := copy(p, dec.data[dec.off:])
+		dec.off += n
+		switch {
+		case n == len(p):
+			return nil
+		case n == 0:
+			return io.EOF
+		default:
+			return io.ErrUnexpectedEOF
+		}
 	}
-	buf := dec.buffer[:4]
-	if _, err := dec.r.Read(buf[:]); err != nil {
-		if err == io.EOF {
-			err = io.ErrUnexpectedEOF
+	if dec.ctx != nil {
+		if err := dec.contextErr(); err != nil {
+			return err
 		}
-		return 0, err
 	}
-
-	return uint64(binary.BigEndian.Uint32(buf)), nil
+	_, err := io.ReadFull(dec.r, p)
+	return err
 }
 
-// readUint64 reads a 64-bit unsigned integer from the input stream.
-func (dec *Decoder) readUint64() (uint64, error) {
-	// Reuse dec.buffer if it's large enough.
-	if cap(dec.buffer) < 8 {
-		dec.buffer = make([]byte, 8)
+// truncated reports whether the decoder reads from memory and the rest of
+// its input is shorter than n bytes, in which case it consumes it, so that
+// nothing is allocated for n bytes which can't be read.
+func (dec *Decoder) truncated(n uint64) bool {
+	if !dec.mem || n <= uint64(len(dec.data)-dec.off) {
+		return false
 	}
-	buf := dec.buffer[:8]
-	if _, err := dec.r.Read(buf[:]); err != nil {
-		if err == io.EOF {
-			err = io.ErrUnexpectedEOF
+	dec.off = len(dec.data)
+	return true
+}
+
+// allocHint returns the number of items to allocate room for before
+// decoding a container which declares n of them, each encoded in at least
+// size bytes. When the decoder reads from memory, it's capped by the
+// number of items the rest of the input could hold, so a short message
+// declaring a huge count can't make the decoder allocate memory for it.
+func (dec *Decoder) allocHint(n uint64, size int) int {
+	if dec.mem {
+		if max := uint64(len(dec.data)-dec.off) / uint64(size); n > max {
+			n = max
 		}
-		return 0, err
 	}
+	return int(n)
+}
 
-	return binary.BigEndian.Uint64(buf), nil
+// contextErr returns the error of the decoder's context if it's done.
+func (dec *Decoder) contextErr() error {
+	select {
+	case <-dec.ctx.Done():
+		return dec.ctx.Err()
+	default:
+		return nil
+	}
 }
 
-// decodeInt decodes a CBOR negative integer into the given reflect.Value.
-func (dec *Decoder) decodeInt(rv reflect.Value, ai byte) error {
-	var n uint64
-	nx := &n
-	err := dec.decodeUint(reflect.ValueOf(nx), ai)
+// next reads the next n bytes, returning a slice which is only valid
+// until the next read.
+func (dec *Decoder) next(n int) ([]byte, error) {
+	if dec.mem && len(dec.data)-dec.off >= n {
+		b := dec.data[dec.off : dec.off+n]
+		dec.off += n
+		return b, nil
+	}
+	if dec.truncated(uint64(n)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if cap(dec.buffer) < n {
+		dec.buffer = make([]byte, n)
+	}
+	buf := dec.buffer[:n]
+	if err := dec.readFull(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readHeader reads the header byte and returns the major type and additional
+// information. This is called before obtaining the value of a CBOR item.
+func (dec *Decoder) readHeader() (majorType MajorType, additionalInfo byte, err error) {
+	b, err := dec.readByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	return MajorType(b >> 5), b & 0x1f, nil
+}
+
+// unmarshalerType is the reflect.Type of the Unmarshaler interface.
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+// readRaw reads the next complete CBOR item from the input stream and
+// returns its encoded bytes.
+func (dec *Decoder) readRaw() ([]byte, error) {
+	b, err := dec.readByte()
+	if err != nil {
+		return nil, err
+	}
+	return dec.appendRawItem(nil, b, 0)
+}
+
+// appendRawItem reads the remainder of the CBOR item whose initial byte b
+// has already been read, appending its encoded bytes to dst.
+func (dec *Decoder) appendRawItem(dst []byte, b byte, depth int) ([]byte, error) {
+	if depth > maxNestingDepth {
+		return nil, ErrMaxDepth
+	}
+	if err := dec.checkHead(b); err != nil {
+		return nil, err
+	}
+
+	start := len(dst)
+	dst, err := dec.appendHeaderBytes(dst, b)
+	if err != nil {
+		return nil, err
+	}
+	mt, ai, arg, _, err := parseHeader(dst[start:])
 	if err != nil {
+		return nil, err
+	}
+
+	switch mt {
+	case MajorTypeByteString, MajorTypeTextString:
+		if ai == 31 {
+			for {
+				c, err := dec.readByte()
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				if c == 0xff {
+					return append(dst, c), nil
+				}
+				if MajorType(c>>5) != mt || c&0x1f == 31 {
+					return nil, newError(ErrMalformed, fmt.Sprintf("cbor: invalid chunk in indefinite-length %s", mt))
+				}
+				if dst, err = dec.appendRawItem(dst, c, depth+1); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		limit := dec.options.MaxStringBytes
+		if mt == MajorTypeByteString {
+			limit = dec.options.MaxBytes
+		}
+		if arg > uint64(limit) {
+			return nil, dec.limitExceeded(newError(ErrStringTooLong, "cbor: "+mt.String()+" too long"))
+		}
+
+		if dec.truncated(arg) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		start := len(dst)
+		dst = append(dst, make([]byte, arg)...)
+		if err := dec.readFull(dst[start:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	case MajorTypeArray, MajorTypeMap:
+		if ai == 31 {
+			for {
+				c, err := dec.readByte()
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				if c == 0xff {
+					return append(dst, c), nil
+				}
+				if dst, err = dec.appendRawItem(dst, c, depth+1); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		count := arg
+		if mt == MajorTypeArray && count > uint64(dec.options.MaxArrayElements) {
+			return nil, dec.limitExceeded(ErrArrayTooLong)
+		}
+		if mt == MajorTypeMap {
+			if count > uint64(dec.options.MaxMapPairs) {
+				return nil, dec.limitExceeded(ErrMapTooLong)
+			}
+			count *= 2
+		}
+
+		for i := uint64(0); i < count; i++ {
+			c, err := dec.readByte()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			if dst, err = dec.appendRawItem(dst, c, depth+1); err != nil {
+				return nil, err
+			}
+		}
+	case MajorTypeTag:
+		c, err := dec.readByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return dec.appendRawItem(dst, c, depth+1)
+	}
+
+	return dst, nil
+}
+
+// skipItem reads the remainder of the CBOR item whose initial byte b has
+// already been read, like appendRawItem, without keeping it.
+func (dec *Decoder) skipItem(b byte, depth int) error {
+	if depth > maxNestingDepth {
+		return ErrMaxDepth
+	}
+	if err := dec.checkHead(b); err != nil {
 		return err
 	}
+
+	mt, ai := MajorType(b>>5), b&0x1f
+	var arg uint64
+	if ai != 31 {
+		var err error
+		if arg, err = dec.readArgument(ai); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+
+	switch mt {
+	case MajorTypeByteString, MajorTypeTextString:
+		if ai == 31 {
+			for {
+				c, err := dec.readByte()
+				if err != nil {
+					return unexpectedEOF(err)
+				}
+				if c == 0xff {
+					return nil
+				}
+				if MajorType(c>>5) != mt || c&0x1f == 31 {
+					return newError(ErrMalformed, fmt.Sprintf("cbor: invalid chunk in indefinite-length %s", mt))
+				}
+				if err := dec.skipItem(c, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+
+		if dec.mem {
+			if arg > uint64(len(dec.data)-dec.off) {
+				dec.off = len(dec.data)
+				return io.ErrUnexpectedEOF
+			}
+			dec.off += int(arg)
+			return nil
+		}
+
+		// The content is read in pieces, so skipping a string doesn't
+		// allocate space for all of it.
+		for arg > 0 {
+			n := uint64(4096)
+			if n > arg {
+				n = arg
+			}
+			if _, err := dec.next(int(n)); err != nil {
+				return unexpectedEOF(err)
+			}
+			arg -= n
+		}
+	case MajorTypeArray, MajorTypeMap:
+		items := 1
+		if mt == MajorTypeMap {
+			items = 2
+		}
+		for i := uint64(0); ai == 31 || i < arg; i++ {
+			for j := 0; j < items; j++ {
+				c, err := dec.readByte()
+				if err != nil {
+					return unexpectedEOF(err)
+				}
+				if c == 0xff && ai == 31 {
+					if j != 0 {
+						return newError(ErrMalformed, "cbor: indefinite-length map has a key without a value")
+					}
+					return nil
+				}
+				if err := dec.skipItem(c, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	case MajorTypeTag:
+		c, err := dec.readByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		return dec.skipItem(c, depth+1)
+	}
+	return nil
+}
+
+// checkHead returns a SyntaxError if b, the initial byte of an item which
+// has just been read, has a reserved additional information value, is a
+// break code, or has an indefinite length for a major type which can't.
+func (dec *Decoder) checkHead(b byte) error {
+	switch mt, ai := MajorType(b>>5), b&0x1f; {
+	case ai < 28:
+		return nil
+	case ai < 31:
+		return dec.syntaxError(fmt.Sprintf("reserved additional information %d", ai))
+	case mt == MajorTypeSimple:
+		return dec.syntaxError("unexpected break code")
+	case mt == MajorTypeUnsignedInt || mt == MajorTypeNegativeInt || mt == MajorTypeTag:
+		return dec.syntaxError(fmt.Sprintf("invalid indefinite-length %s", mt))
+	}
+	return nil
+}
+
+// appendHeaderBytes reads the argument of the header whose initial byte b
+// has already been read, if it has one, appending the encoded header to
+// dst.
+func (dec *Decoder) appendHeaderBytes(dst []byte, b byte) ([]byte, error) {
+	dst = append(dst, b)
+	if ai := b & 0x1f; ai >= 24 && ai <= 27 {
+		start := len(dst)
+		dst = append(dst, make([]byte, 1<<(ai-24))...)
+		if err := dec.readFull(dst[start:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+	return dst, nil
+}
+
+// unexpectedEOF converts an io.EOF in the middle of an item into
+// io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// decodeValue decodes the next CBOR item into the given reflect.Value, which
+// must be addressable, using the compiled decoder of its type.
+func (dec *Decoder) decodeValue(rv reflect.Value) error {
+	b, err := dec.readByte()
+	if err != nil {
+		return err
+	}
+	return decoderFor(rv.Type())(dec, rv, b)
+}
+
+// decodeItem decodes the item whose initial byte b has already been read
+// into the given reflect.Value, based on its major type.
+func (dec *Decoder) decodeItem(rv reflect.Value, b byte) error {
+	// Pointers are all followed by their compiled decoder, which decodes
+	// into the value they point to with the decoder of its type.
+	if rv.Kind() == reflect.Ptr {
+		return decoderFor(rv.Type())(dec, rv, b)
+	}
+
+	ai := b & 0x1f
+	if ai >= 28 {
+		if err := dec.checkHead(b); err != nil {
+			return err
+		}
+	}
+
+	// Decode the value based on the major type.
+	switch MajorType(b >> 5) {
+	case MajorTypeUnsignedInt:
+		return dec.decodeUint(rv, ai)
+	case MajorTypeNegativeInt:
+		return dec.decodeInt(rv, ai)
+	case MajorTypeByteString:
+		return dec.decodeBytes(rv, ai)
+	case MajorTypeTextString:
+		return dec.decodeString(rv, ai)
+	case MajorTypeArray, MajorTypeMap:
+		return dec.decodeContainer(rv, b)
+	case MajorTypeTag:
+		return dec.decodeTag(rv, ai)
+	default: // MajorTypeSimple
+		return dec.decodeSimpleValue(rv, ai)
+	}
+}
+
+// decodeSimpleValue decodes a CBOR simple value into the given reflect.Value.
+func (dec *Decoder) decodeSimpleValue(rv reflect.Value, ai byte) error {
+	// Decode the simple value based on the additional information.
+	switch SimpleValue(ai) {
+	case SimpleValueFalse, SimpleValueTrue:
+		b := SimpleValue(ai) == SimpleValueTrue
+
+		switch rv.Kind() {
+		case reflect.Bool:
+			rv.SetBool(b)
+		case reflect.Interface:
+			rv.Set(reflect.ValueOf(b))
+		default:
+			return typeError("bool", rv.Type())
+		}
+	case SimpleValueNull:
+		rv.Set(reflect.Zero(rv.Type()))
+	case SimpleValueUndefined:
+	// Do nothing.
+	case SimpleValueFloat16:
+		f, err := dec.readFloat16()
+		if err != nil {
+			return err
+		}
+		// float16 values are formatted as float32, which represents
+		// them exactly.
+		return dec.setFloat(rv, f, 32)
+	case SimpleValueFloat32:
+		f, err := dec.readFloat32()
+		if err != nil {
+			return err
+		}
+		return dec.setFloat(rv, f, 32)
+	case SimpleValueFloat64:
+		f, err := dec.readFloat64()
+		if err != nil {
+			return err
+		}
+		return dec.setFloat(rv, f, 64)
+	case SimpleValueSimpleValue:
+		n, err := dec.readUint8()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if n < 32 {
+			return newError(ErrMalformed, fmt.Sprintf("cbor: invalid two-byte simple value %d", n))
+		}
+		return decodeRegisteredValue(rv, SimpleValue(n))
+	default:
+		if ai < 20 {
+			return decodeRegisteredValue(rv, SimpleValue(ai))
+		}
+		return newError(ErrMalformed, fmt.Sprintf("cbor: invalid simple value: %v", ai))
+	}
+	return nil
+}
+
+// setFloat sets rv to the float f, which was encoded with the given number
+// of bits, which are only used to format it in errors. Floats are only decoded into integers if they're whole numbers
+// in range, unless the decoder's TruncateFloats option is set, in which
+// case the fraction is discarded.
+func (dec *Decoder) setFloat(rv reflect.Value, f float64, bits int) error {
+	if f == 0 && dec.options.NegativeZero == NegativeZeroNormalize {
+		f = 0
+	}
 	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		rv.SetFloat(f)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		rv.SetInt(-1 - int64(n))
+		if dec.options.FloatChecks&RejectFloatIntegers != 0 {
+			return typeError("float "+strconv.FormatFloat(f, 'g', -1, bits), rv.Type())
+		}
+		t := math.Trunc(f)
+		if t != f && !dec.options.TruncateFloats || t < math.MinInt64 || t >= math.MaxInt64 || rv.OverflowInt(int64(t)) {
+			return typeError("float "+strconv.FormatFloat(f, 'g', -1, bits), rv.Type())
+		}
+		rv.SetInt(int64(t))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if dec.options.FloatChecks&RejectFloatIntegers != 0 {
+			return typeError("float "+strconv.FormatFloat(f, 'g', -1, bits), rv.Type())
+		}
+		t := math.Trunc(f)
+		if t != f && !dec.options.TruncateFloats || t < 0 || t >= math.MaxUint64 || rv.OverflowUint(uint64(t)) {
+			return typeError("float "+strconv.FormatFloat(f, 'g', -1, bits), rv.Type())
+		}
+		rv.SetUint(uint64(t))
 	case reflect.Interface:
-		rv.Set(reflect.ValueOf(-1 - int64(n)))
-	case reflect.Pointer:
-		switch rv.Elem().Kind() {
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			rv.Elem().SetInt(-1 - int64(n))
-		case reflect.Interface:
-			rv.Elem().Set(reflect.ValueOf(-1 - int64(n)))
-		default:
-			return errors.New("cbor: cannot unmarshal int into " + rv.Type().String())
+		if rv.NumMethod() != 0 {
+			return typeError("float", rv.Type())
 		}
+		rv.Set(reflect.ValueOf(f))
 	default:
-		return errors.New("cbor: cannot unmarshal int into " + rv.Type().String())
+		return typeError("float", rv.Type())
 	}
 	return nil
 }
 
-// decodeBytes decodes a CBOR byte string into the given reflect.Value.
-func (dec *Decoder) decodeBytes(rv reflect.Value, ai byte) error {
+// decodeUint decodes a CBOR unsigned integer into the given reflect.Value.
+func (dec *Decoder) decodeUint(rv reflect.Value, ai byte) error {
 	var (
 		n   uint64
 		err error
@@ -650,427 +1433,239 @@ func (dec *Decoder) decodeBytes(rv reflect.Value, ai byte) error {
 		return err
 	}
 
-	if n > math.MaxInt32 {
-		return errors.New("cbor: byte string too long")
-	}
-
-	if n > uint64(dec.options.MaxBytes) {
-		return errors.New("cbor: byte string too long")
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return setUint(rv, n)
+	case reflect.Interface:
+		rv.Set(reflect.ValueOf(n))
+	default:
+		return typeError("uint", rv.Type())
 	}
+	return nil
+}
 
-	buf := make([]byte, n)
-	if _, err := io.ReadFull(dec.r, buf); err != nil {
-		return err
-	}
+// setUint sets rv, of an integer kind, to n, or returns an error if n
+// overflows it.
+func setUint(rv reflect.Value, n uint64) error {
 	switch rv.Kind() {
-	case reflect.Slice:
-		if rv.Type().Elem().Kind() != reflect.Uint8 {
-			return errors.New("cbor: cannot unmarshal byte string into " + rv.Type().String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n > math.MaxInt64 || rv.OverflowInt(int64(n)) {
+			return newError(ErrInvalidType, fmt.Sprintf("cbor: cannot unmarshal %d into %s", n, rv.Type()))
 		}
-		rv.SetBytes(buf)
-	case reflect.Interface:
-		rv.Set(reflect.ValueOf(buf))
+		rv.SetInt(int64(n))
 	default:
-		return errors.New("cbor: cannot unmarshal byte string into " + rv.Type().String())
+		if rv.OverflowUint(n) {
+			return newError(ErrInvalidType, fmt.Sprintf("cbor: cannot unmarshal %d into %s", n, rv.Type()))
+		}
+		rv.SetUint(n)
 	}
 	return nil
 }
 
-// decodeString decodes a CBOR text string into the given reflect.Value.
-func (dec *Decoder) decodeString(rv reflect.Value, ai byte) error {
-	var (
-		n   uint64
-		err error
-	)
-	switch ai {
-	case 24: // 1-byte uint follows
-		n, err = dec.readUint8()
-	case 25: // 2-byte uint follows
-		n, err = dec.readUint16()
-	case 26: // 4-byte uint follows
-		n, err = dec.readUint32()
-	case 27: // 8-byte uint follows
-		n, err = dec.readUint64()
-	default: // uint is encoded in initial byte
-		n = uint64(ai)
+// setNegative sets rv, of a signed integer kind, to the negative integer
+// -1-n, or returns an error if it overflows rv.
+func setNegative(rv reflect.Value, n uint64) error {
+	if n > math.MaxInt64 || rv.OverflowInt(-1-int64(n)) {
+		v := new(big.Int).Not(new(big.Int).SetUint64(n))
+		return newError(ErrInvalidType, fmt.Sprintf("cbor: cannot unmarshal %s into %s", v, rv.Type()))
 	}
+	rv.SetInt(-1 - int64(n))
+	return nil
+}
+
+// readUint8 reads an 8-bit unsigned integer from the input stream.
+func (dec *Decoder) readUint8() (uint64, error) {
+	b, err := dec.readByte()
+	return uint64(b), err
+}
+
+// readUint16 reads a 16-bit unsigned integer from the input stream.
+func (dec *Decoder) readUint16() (uint64, error) {
+	buf, err := dec.next(2)
 	if err != nil {
-		return err
+		return 0, unexpectedEOF(err)
 	}
-	if n > math.MaxInt32 {
-		return errors.New("cbor: string too long")
-	}
-	if n > uint64(dec.options.MaxStringBytes) {
-		return errors.New("cbor: string too long")
+	return uint64(buf[0])<<8 | uint64(buf[1]), nil
+}
+
+// readUint32 reads a 32-bit unsigned integer from the input stream.
+func (dec *Decoder) readUint32() (uint64, error) {
+	buf, err := dec.next(4)
+	if err != nil {
+		return 0, unexpectedEOF(err)
 	}
+	return uint64(binary.BigEndian.Uint32(buf)), nil
+}
 
-	// Reuse dec.buffer if it's large enough.
-	if cap(dec.buffer) < int(n) {
-		dec.buffer = make([]byte, n)
+// readUint64 reads a 64-bit unsigned integer from the input stream.
+func (dec *Decoder) readUint64() (uint64, error) {
+	buf, err := dec.next(8)
+	if err != nil {
+		return 0, unexpectedEOF(err)
 	}
-	buf := dec.buffer[:n]
+	return binary.BigEndian.Uint64(buf), nil
+}
 
-	_, err = dec.r.Read(buf)
+// decodeInt decodes a CBOR negative integer into the given reflect.Value.
+// Interfaces are set to an int64, or to a *big.Int, like bignums, for
+// integers below math.MinInt64.
+func (dec *Decoder) decodeInt(rv reflect.Value, ai byte) error {
+	var n uint64
+	err := dec.decodeUint(reflect.ValueOf(&n).Elem(), ai)
 	if err != nil {
-		if err == io.EOF {
-			err = io.ErrUnexpectedEOF
-		}
 		return err
 	}
-
 	switch rv.Kind() {
-	case reflect.String:
-		rv.SetString(string(buf))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return setNegative(rv, n)
 	case reflect.Interface:
-		rv.Set(reflect.ValueOf(string(buf)))
-	case reflect.Pointer:
-		// If we have a pointer to a string, then we can use it. Otherwise, we
-		// need to allocate a new string. If it is not a pointer to a string,
-		// then we return an error.
-		if rv.Type().String() != "*string" {
-			return errors.New("cbor: cannot unmarshal string into " + rv.Type().String())
-		}
-		// If the pointer is nil, then we need to allocate a string.
-		if rv.IsNil() {
-			rv.Set(reflect.New(rv.Type().Elem()))
-		}
-		rv.Elem().SetString(string(buf))
+		if n > math.MaxInt64 {
+			rv.Set(reflect.ValueOf(new(big.Int).Not(new(big.Int).SetUint64(n))))
+			return nil
+		}
+		rv.Set(reflect.ValueOf(-1 - int64(n)))
 	default:
-		return errors.New("cbor: cannot unmarshal string into " + rv.Type().String())
+		return typeError("int", rv.Type())
 	}
 	return nil
 }
 
-// decodeArray decodes a CBOR array into the given reflect.Value.
-func (dec *Decoder) decodeArray(rv reflect.Value, ai byte) error {
+// decodeBytes decodes a CBOR byte string into the given reflect.Value.
+func (dec *Decoder) decodeBytes(rv reflect.Value, ai byte) error {
+	if ai == 31 {
+		buf, err := dec.readChunks(MajorTypeByteString, dec.options.MaxBytes)
+		if err != nil {
+			return err
+		}
+		return setBytes(rv, buf)
+	}
+
 	var (
 		n   uint64
 		err error
 	)
 	switch ai {
-	case 24: // 1-byte array length
+	case 24:
 		n, err = dec.readUint8()
-	case 25: // 2-byte array length
+	case 25:
 		n, err = dec.readUint16()
-	case 26: // 4-byte array length
+	case 26:
 		n, err = dec.readUint32()
-	case 27: // 8-byte array length
+	case 27:
 		n, err = dec.readUint64()
-	default: // array length is encoded in the initial byte
+	default:
 		n = uint64(ai)
 	}
 	if err != nil {
 		return err
 	}
 
-	if n > uint64(dec.options.MaxArrayElements) {
-		return errors.New("cbor: array too long")
+	if n > math.MaxInt32 || n > uint64(dec.options.MaxBytes) {
+		return dec.limitExceeded(newError(ErrStringTooLong, "cbor: byte string too long"))
+	}
+
+	if dec.truncated(n) {
+		return io.ErrUnexpectedEOF
+	}
+	buf := dec.makeBytes(int(n))
+	if err := dec.readFull(buf); err != nil {
+		return err
 	}
+	return setBytes(rv, buf)
+}
 
+// setBytes sets rv, a byte slice or an interface, to the content of a
+// byte string.
+func setBytes(rv reflect.Value, buf []byte) error {
 	switch rv.Kind() {
 	case reflect.Slice:
-		// If the slice is not nil, we assume it is already the right size.
-		//
-		// TODO: add a configurable limit to the maximum slice length.
-		if rv.IsNil() {
-
-			rv.Set(reflect.MakeSlice(rv.Type(), int(n), int(n)))
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			return typeError("byte string", rv.Type())
 		}
+		rv.SetBytes(buf)
+	case reflect.Interface:
+		rv.Set(reflect.ValueOf(buf))
+	default:
+		return typeError("byte string", rv.Type())
+	}
+	return nil
+}
 
-		for i := 0; i < int(n); i++ {
-			// If the element is not a pointer, we need to get a pointer to it.
-			if rv.Type().Elem().Kind() != reflect.Ptr {
-				if err := dec.decode(rv.Index(i).Addr()); err != nil {
-					return err
-				}
-			} else {
-				if err := dec.decode(rv.Index(i)); err != nil {
-					return err
-				}
-			}
+// readChunks reads the chunks of an indefinite-length string of major type
+// mt, whose initial byte has been read, up to the break code, returning
+// their content, which must be at most limit bytes long.
+func (dec *Decoder) readChunks(mt MajorType, limit int) ([]byte, error) {
+	buf := []byte{}
+	for {
+		c, err := dec.readByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
 		}
-	case reflect.Array:
-		if rv.Len() != int(n) {
-			return errors.New("cbor: wrong array length")
+		if c == 0xff {
+			return buf, nil
 		}
-		for i := 0; i < int(n); i++ {
-			// If the element is not a pointer, we need to get a pointer to it.
-			if rv.Type().Elem().Kind() != reflect.Ptr {
-				if err := dec.decode(rv.Index(i).Addr()); err != nil {
-					return err
-				}
-			} else {
-				if err := dec.decode(rv.Index(i)); err != nil {
-					return err
-				}
-			}
+		if MajorType(c>>5) != mt || c&0x1f >= 28 {
+			return nil, newError(ErrMalformed, fmt.Sprintf("cbor: invalid chunk in indefinite-length %s", mt))
 		}
-	case reflect.Interface:
-		s := make([]interface{}, n)
-		for i := 0; i < int(n); i++ {
-			if err := dec.decode(reflect.ValueOf(&s[i]).Elem()); err != nil {
-				return err
-			}
+		n, err := dec.readArgument(c & 0x1f)
+		if err != nil {
+			return nil, unexpectedEOF(err)
 		}
-		rv.Set(reflect.ValueOf(s))
-	default:
-		return errors.New("cbor: cannot unmarshal array into " + rv.Type().String())
+		if n > uint64(limit-len(buf)) {
+			return nil, dec.limitExceeded(newError(ErrStringTooLong, "cbor: "+mt.String()+" too long"))
+		}
+		chunk, err := dec.next(int(n))
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		buf = append(buf, chunk...)
 	}
-	return nil
 }
 
-// fieldCache is a cache of reflect.Type fields indexed by name used
-// to speed up decoding CBOR maps into struct values.
-type fieldCache map[string]reflect.Value
-
-// decodeMap decodes a CBOR map into the given reflect.Value.
-//
-// ai is the additional information byte for the map, which contains the
-// number of key/value pairs in the map.
-func (dec *Decoder) decodeMap(rv reflect.Value, ai byte) error {
+// decodeString decodes a CBOR text string into the given reflect.Value.
+func (dec *Decoder) decodeString(rv reflect.Value, ai byte) error {
 	var (
 		n   uint64
 		err error
 	)
 	switch ai {
-	case 24:
+	case 24: // 1-byte uint follows
 		n, err = dec.readUint8()
-	case 25:
+	case 25: // 2-byte uint follows
 		n, err = dec.readUint16()
-	case 26:
+	case 26: // 4-byte uint follows
 		n, err = dec.readUint32()
-	case 27:
+	case 27: // 8-byte uint follows
 		n, err = dec.readUint64()
-	default:
+	default: // uint is encoded in initial byte
 		n = uint64(ai)
 	}
 	if err != nil {
 		return err
 	}
 
-	switch rv.Kind() {
-	case reflect.Map:
-		// If the map is nil, we need to make it.
-		if rv.IsNil() {
-			rv.Set(reflect.MakeMap(rv.Type()))
-		}
-		// Iterate over the key/value pairs in the map based
-		// on the determined length (n).
-		for i := 0; i < int(n); i++ {
-			var key reflect.Value
-
-			// Decode the key.
-			switch rv.Type().Key().Kind() {
-			case reflect.String:
-				key = reflect.New(rv.Type().Key())
-				if err := dec.decode(key); err != nil {
-					return err
-				}
-			case reflect.Interface:
-				key = reflect.New(rv.Type().Key()).Elem()
-				if err := dec.decode(key); err != nil {
-					return err
-				}
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				key = reflect.New(rv.Type().Key())
-				if err := dec.decode(key); err != nil {
-					return err
-				}
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				key = reflect.New(rv.Type().Key())
-				if err := dec.decode(key); err != nil {
-					return err
-				}
-			case reflect.Float32, reflect.Float64:
-				key = reflect.New(rv.Type().Key())
-				if err := dec.decode(key); err != nil {
-					return err
-				}
-			case reflect.Ptr:
-				key = reflect.New(rv.Type().Key().Elem())
-				if err := dec.decode(key); err != nil {
-					return err
-				}
-			default:
-				return errors.New("cbor: cannot unmarshal map key into " + rv.Type().Key().String())
-			}
-
-			// Decode the value.
-			switch rv.Type().Elem().Kind() {
-			case reflect.String:
-				val := reflect.New(rv.Type().Elem())
-				if err := dec.decode(val); err != nil {
-					return err
-				}
-
-				if rv.Type().Key().Kind() != reflect.Ptr {
-					key = key.Elem()
-				}
-
-				if rv.Type().Elem().Kind() != reflect.Ptr {
-					val = val.Elem()
-				}
-
-				rv.SetMapIndex(key, val)
-			case reflect.Interface:
-				var v interface{}
-				if err := dec.decode(reflect.ValueOf(&v).Elem()); err != nil {
-					return err
-				}
-				rv.SetMapIndex(key, reflect.ValueOf(v))
-			case reflect.Ptr:
-				val := reflect.New(rv.Type().Elem().Elem())
-				if err := dec.decode(val); err != nil {
-					return err
-				}
-
-				if rv.Type().Key().Kind() != reflect.Ptr {
-					key = key.Elem()
-				}
-
-				if rv.Type().Elem().Kind() != reflect.Ptr {
-					val = val.Elem()
-				}
-
-				rv.SetMapIndex(key, val)
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				val := reflect.New(rv.Type().Elem())
-				if err := dec.decode(val); err != nil {
-					return err
-				}
-
-				// Ensure the key and val is the same type as the map, otherwise
-				// we'll get a panic when we try to set the map index.
-				//
-				// The val starts as an int64, but if the map is an int32
-				// we need to convert it to an int32.
-				if val.Type().Elem().Kind() != rv.Type().Elem().Kind() {
-					val = val.Elem().Convert(rv.Type().Elem())
-				}
-
-				if key.Type().Elem().Kind() != rv.Type().Key().Kind() {
-					key = key.Elem().Convert(rv.Type().Key())
-				}
-
-				if rv.Type().Key().Kind() != reflect.Ptr && key.Kind() == reflect.Ptr {
-					key = key.Elem()
-				}
-
-				if rv.Type().Elem().Kind() != reflect.Ptr && val.Kind() == reflect.Ptr {
-					val = val.Elem()
-				}
-
-				rv.SetMapIndex(key, val)
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				val := reflect.New(rv.Type().Elem())
-				if err := dec.decode(val); err != nil {
-					return err
-				}
-
-				if rv.Type().Key().Kind() != reflect.Ptr && key.Kind() == reflect.Ptr {
-					key = key.Elem()
-				}
-
-				if rv.Type().Elem().Kind() != reflect.Ptr && val.Kind() == reflect.Ptr {
-					val = val.Elem()
-				}
-
-				rv.SetMapIndex(key, val)
-			case reflect.Float32, reflect.Float64:
-				val := reflect.New(rv.Type().Elem())
-				if err := dec.decode(val); err != nil {
-					return err
-				}
-
-				if rv.Type().Key().Kind() != reflect.Ptr && key.Kind() == reflect.Ptr {
-					key = key.Elem()
-				}
-
-				if rv.Type().Elem().Kind() != reflect.Ptr && val.Kind() == reflect.Ptr {
-					val = val.Elem()
-				}
-
-				rv.SetMapIndex(key, val)
-			default:
-				val := reflect.New(rv.Type().Elem()).Elem()
-				if err := dec.decode(val); err != nil {
-					return err
-				}
-
-				if rv.Type().Key().Kind() != reflect.Ptr {
-					val = val.Elem()
-				}
-
-				if rv.Type().Key().Kind() != reflect.Ptr {
-					key = key.Elem()
-				}
-
-				rv.SetMapIndex(key, val)
-			}
+	var buf []byte
+	if ai == 31 {
+		if buf, err = dec.readChunks(MajorTypeTextString, dec.options.MaxStringBytes); err != nil {
+			return err
 		}
-	case reflect.Interface:
-		m := make(map[interface{}]interface{})
-		for i := 0; i < int(n); i++ {
-			var key interface{}
-			if err := dec.decode(reflect.ValueOf(&key).Elem()); err != nil {
-				return err
-			}
-			var val interface{}
-			if err := dec.decode(reflect.ValueOf(&val).Elem()); err != nil {
-				return err
-			}
-			m[key] = val
+	} else {
+		if n > math.MaxInt32 || n > uint64(dec.options.MaxStringBytes) {
+			return dec.limitExceeded(ErrStringTooLong)
 		}
-		rv.Set(reflect.ValueOf(m))
-	case reflect.Struct:
-		// Structs are treated similarly to maps, but the keys are
-		// the struct field names. CBOR map keys can be any type,
-		// including string, int, etc. We support all of these
-		// types.
-
-		// To reduce allocations, we use a map[int]reflect.Value
-		// to cache the field index and value. This is used to
-		// avoid the need to call rv.FieldByName for each key.
-		cache := loadFieldCache(rv.Type())
-
-		if cache == nil {
-			// If the cache is nil, we need to build it.
-			cache = storeFieldCache(rv)
+		if buf, err = dec.next(int(n)); err != nil {
+			return unexpectedEOF(err)
 		}
+	}
 
-		// For each field in the struct, find the corresponding
-		// key in the map and decode into the field.
-		for i := 0; i < int(n); i++ {
-			key, err := dec.readMapKey()
-			if err != nil {
-				return err
-			}
-
-			fv, ok := cache[toString(key)]
-			if !ok {
-				// If the field is not found in the cache, skip it.
-
-				// Read the value and discard it.
-				if _, err := dec.readValue(); err != nil {
-					return fmt.Errorf("cbor: cannot unmarshal map key into %s: %s", rv.Type().String(), err)
-				}
-
-				continue
-			}
-
-			// If the field value is not a pointer, we need to create
-			// a pointer to the field value and decode into that.
-			if fv.Kind() != reflect.Ptr {
-				fv = fv.Addr()
-			}
-
-			err = dec.decode(fv)
-			if err != nil {
-				return err
-			}
-		}
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(dec.makeString(buf))
+	case reflect.Interface:
+		rv.Set(reflect.ValueOf(dec.makeString(buf)))
 	default:
-		return errors.New("cbor: cannot unmarshal map into " + rv.Type().String())
+		return typeError("string", rv.Type())
 	}
 	return nil
 }
@@ -1099,169 +1694,57 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 		return err
 	}
 	switch n {
-	case 0:
-		// RFC 7049, section
-		// 2.4.1.  Tag 0:  The Semantic Tag for Big Number
-		//
-		// The semantic tag 0 is used to indicate that a CBOR data item
-		// represents a number that is too big to be represented in the
-		// CBOR data item itself.  The number is encoded as a byte string
-		// (major type 2), which contains the number's base 2 exponent and
-		// coefficient.  The exponent is encoded as an integer (major type
-		// 0 or 1), and the coefficient is encoded as an unsigned integer
-		// (major type 0).  The coefficient is multiplied by 2 to the power
-		// of the exponent to obtain the number's value.  For example, the
-		// number 2^1000 is represented as the byte string 0xc4 0x03 0xe8,
-		// which in CBOR diagnostic notation is h'c403e8'.
-		//
-		// The exponent is encoded as a CBOR integer (major type 0 or 1),
-		// and the coefficient is encoded as a CBOR unsigned integer
-		// (major type 0).  The coefficient is multiplied by 2 to the power
-		// of the exponent to obtain the number's value.
-
-		// Read the exponent.
-		ai, err := dec.readByte()
-		if err != nil {
-			return err
-		}
-		var exp uint64
-		switch ai {
-		case 24:
-			exp, err = dec.readUint8()
-		case 25:
-			exp, err = dec.readUint16()
-		case 26:
-			exp, err = dec.readUint32()
-		case 27:
-			exp, err = dec.readUint64()
-		default:
-			exp = uint64(ai)
-		}
-		if err != nil {
-			return err
-		}
-
-		// Read the coefficient.
-		ai, err = dec.readByte()
-		if err != nil {
-			return err
-		}
-
-		// The coefficient is encoded as a CBOR unsigned integer (major
-		// type 0).  The coefficient is multiplied by 2 to the power of
-		// the exponent to obtain the number's value.
-		var coef uint64
-		switch ai {
-		case 24:
-			coef, err = dec.readUint8()
-		case 25:
-			coef, err = dec.readUint16()
-		case 26:
-			coef, err = dec.readUint32()
-		case 27:
-			coef, err = dec.readUint64()
-		default:
-			coef = uint64(ai)
-		}
-		if err != nil {
-			return err
-		}
-
-		// Multiply the coefficient by 2 to the power of the exponent to
-		// obtain the number's value.
-		val := new(big.Int).Lsh(big.NewInt(int64(coef)), uint(exp))
-		rv.Set(reflect.ValueOf(val))
-	case 1:
-		// RFC 7049, section
-		// 2.4.2.  Tag 1:  The Semantic Tag for Decimal Fraction
-		//
-		// The semantic tag 1 is used to indicate that a CBOR data item
-		// represents a decimal fraction.  The number is encoded as an
-		// array (major type 4) of two integers.  The first integer is the
-		// numerator, and the second integer is the denominator.  For
-		// example, the decimal fraction 1/10 is represented as the array
-		// [1, 10], which in CBOR diagnostic notation is [1, 10].
-		//
-		// The numerator and denominator are encoded as CBOR integers
-		// (major type 0 or 1).
-		if err := dec.decode(rv); err != nil {
-			return err
-		}
-		if rv.Kind() != reflect.Slice {
-			return errors.New("cbor: cannot unmarshal decimal fraction into " + rv.Type().String())
-		}
-		if rv.Len() != 2 {
-			return errors.New("cbor: invalid decimal fraction")
-		}
-		num := rv.Index(0)
-		den := rv.Index(1)
-		if num.Kind() != reflect.Int64 || den.Kind() != reflect.Int64 {
-			return errors.New("cbor: invalid decimal fraction")
-		}
-		rv.Set(reflect.ValueOf(big.NewRat(num.Int(), den.Int())))
-	case 2:
-		// RFC 7049, section
-		// 2.4.3.  Tag 2:  The Semantic Tag for Big Float
-		//
-		// The semantic tag 2 is used to indicate that a CBOR data item
-		// represents a floating-point number that is too big to be
-		// represented in the CBOR data item itself.  The number is
-		// encoded as an array (major type 4) of two integers.  The first
-		// integer is the significand, and the second integer is the
-		// base-2 exponent.  For example, the floating-point number
-		// 1.234*10^1000 is represented as the array [1234, 1000], which
-		// in CBOR diagnostic notation is [1234, 1000].
-		//
-		// The significand and exponent are encoded as CBOR integers
-		// (major type 0 or 1).
-		if err := dec.decode(rv); err != nil {
-			return err
-		}
-		if rv.Kind() != reflect.Slice {
-			return errors.New("cbor: cannot unmarshal big float into " + rv.Type().String())
-		}
-		if rv.Len() != 2 {
-			return errors.New("cbor: invalid big float")
-		}
-		sig := rv.Index(0)
-		exp := rv.Index(1)
-		if sig.Kind() != reflect.Int64 || exp.Kind() != reflect.Int64 {
-			return errors.New("cbor: invalid big float")
-		}
-
-		// convert sig to math big.Float
-		sigBf := big.NewFloat(float64(sig.Int()))
-
-		rv.Set(reflect.ValueOf(big.NewFloat(float64(sig.Int())).SetPrec(64).SetMantExp(sigBf, int(exp.Int()))))
-	case 3:
-		// RFC 7049, section
-		// 2.4.4.  Tag 3:  The Semantic Tag for Big Number
-		//
-		// The semantic tag 3 is used to indicate that a CBOR data item
-		// represents a number that is too big to be represented in the
-		// CBOR data item itself.  The number is encoded as an array
-		// (major type 4) of two integers.  The first integer is the
-		// coefficient, and the second integer is the base-2 exponent.
-		// For example, the number 2^1000 is represented as the array
-		// [2, 1000], which in CBOR diagnostic notation is [2, 1000].
+	case uint64(TagDateTimeString):
+		// RFC 8949, section 3.4.1. Standard Date/Time String
 		//
-		// The coefficient and exponent are encoded as CBOR integers
-		// (major type 0 or 1).
-		if err := dec.decode(rv); err != nil {
+		// The content of the tag is a text string in the standard format
+		// described by RFC 3339, which is decoded into a time.Time, or
+		// left as it is when decoding into a string.
+		b, err := dec.readByte()
+		if err != nil {
 			return err
 		}
-		if rv.Kind() != reflect.Slice {
-			return errors.New("cbor: cannot unmarshal big number into " + rv.Type().String())
+		if MajorType(b>>5) != MajorTypeTextString {
+			return newError(ErrInvalidType, "cbor: date/time string is not a text string")
 		}
-		if rv.Len() != 2 {
-			return errors.New("cbor: invalid big number")
+		if rv.Kind() == reflect.String {
+			return dec.decodeItem(rv, b)
 		}
-		coef := rv.Index(0)
-		exp := rv.Index(1)
-		if coef.Kind() != reflect.Int64 || exp.Kind() != reflect.Int64 {
-			return errors.New("cbor: invalid big number")
+		if rv.Type() != timeType && (rv.Kind() != reflect.Interface || rv.NumMethod() != 0) {
+			return typeError("date/time string", rv.Type())
+		}
+		var t time.Time
+		if err := decodeTime(dec, reflect.ValueOf(&t).Elem(), b); err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+	case uint64(TagUnixTime):
+		// RFC 8949, section 3.4.2. Epoch-Based Date/Time
+		//
+		// The content of the tag is an integer or float number of seconds
+		// since the Unix epoch, which is decoded into a time.Time.
+		if rv.Kind() != reflect.Interface || rv.NumMethod() != 0 {
+			return typeError("epoch time", rv.Type())
+		}
+		b, err := dec.readByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if mt := MajorType(b >> 5); mt != MajorTypeUnsignedInt && mt != MajorTypeNegativeInt && (b < 0xf9 || b > 0xfb) {
+			return newError(ErrInvalidType, "cbor: epoch time is not a number")
 		}
-		rv.Set(reflect.ValueOf(big.NewInt(coef.Int()).Lsh(big.NewInt(coef.Int()), uint(exp.Int()))))
+		var t time.Time
+		if err := decodeTime(dec, reflect.ValueOf(&t).Elem(), b); err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+	case uint64(TagPositiveBignum), uint64(TagNegativeBignum):
+		// RFC 8949, section 3.4.3. Bignums
+		//
+		// The content of the tag is a byte string with the big-endian
+		// magnitude of the number n, which is n for tag 2, or -1-n for
+		// tag 3.
+		return dec.decodeBignum(rv, n == uint64(TagNegativeBignum))
 	case 4:
 		// RFC 7049, section
 		// 2.4.5.  Tag 4:  The Semantic Tag for Big Rational
@@ -1280,7 +1763,7 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 			return err
 		}
 		if rv.Kind() != reflect.Slice {
-			return errors.New("cbor: cannot unmarshal big rational into " + rv.Type().String())
+			return typeError("big rational", rv.Type())
 		}
 		if rv.Len() != 2 {
 			return errors.New("cbor: invalid big rational")
@@ -1311,7 +1794,7 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 		}
 
 		if rv.Kind() != reflect.Slice {
-			return errors.New("cbor: cannot unmarshal big complex into " + rv.Type().String())
+			return typeError("big complex", rv.Type())
 		}
 		if rv.Len() != 2 {
 			return errors.New("cbor: invalid big complex")
@@ -1351,7 +1834,7 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 			return err
 		}
 		if rv.Kind() != reflect.Slice {
-			return errors.New("cbor: cannot unmarshal decimal fraction into " + rv.Type().String())
+			return typeError("decimal fraction", rv.Type())
 		}
 		if rv.Len() != 2 {
 			return errors.New("cbor: invalid decimal fraction")
@@ -1384,7 +1867,7 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 			return err
 		}
 		if rv.Kind() != reflect.Slice {
-			return errors.New("cbor: cannot unmarshal big float into " + rv.Type().String())
+			return typeError("big float", rv.Type())
 		}
 		if rv.Len() != 2 {
 			return errors.New("cbor: invalid big float")
@@ -1416,7 +1899,7 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 			return err
 		}
 		if rv.Kind() != reflect.Slice {
-			return errors.New("cbor: cannot unmarshal big decimal into " + rv.Type().String())
+			return typeError("big decimal", rv.Type())
 		}
 		if rv.Len() != 2 {
 			return errors.New("cbor: invalid big decimal")
@@ -1442,7 +1925,7 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 			return err
 		}
 		if rv.Kind() != reflect.String {
-			return errors.New("cbor: cannot unmarshal URI into " + rv.Type().String())
+			return typeError("URI", rv.Type())
 		}
 		uri, err := url.Parse(rv.String())
 		if err != nil {
@@ -1461,7 +1944,7 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 			return err
 		}
 		if rv.Kind() != reflect.String {
-			return errors.New("cbor: cannot unmarshal base64url into " + rv.Type().String())
+			return typeError("base64url", rv.Type())
 		}
 		b, err := base64.URLEncoding.DecodeString(rv.String())
 		if err != nil {
@@ -1480,7 +1963,7 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 			return err
 		}
 		if rv.Kind() != reflect.String {
-			return errors.New("cbor: cannot unmarshal base64 into " + rv.Type().String())
+			return typeError("base64", rv.Type())
 		}
 		b, err := base64.StdEncoding.DecodeString(rv.String())
 		if err != nil {
@@ -1498,7 +1981,7 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 			return err
 		}
 		if rv.Kind() != reflect.String {
-			return errors.New("cbor: cannot unmarshal regular expression into " + rv.Type().String())
+			return typeError("regular expression", rv.Type())
 		}
 		re, err := regexp.Compile(rv.String())
 		if err != nil {
@@ -1516,7 +1999,7 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 			return err
 		}
 		if rv.Kind() != reflect.String {
-			return errors.New("cbor: cannot unmarshal MIME message into " + rv.Type().String())
+			return typeError("MIME message", rv.Type())
 		}
 		mime, err := mail.ReadMessage(strings.NewReader(rv.String()))
 		if err != nil {
@@ -1534,330 +2017,75 @@ func (dec *Decoder) decodeTag(rv reflect.Value, ai byte) error {
 			return err
 		}
 		if rv.Kind() != reflect.Slice {
-			return errors.New("cbor: cannot unmarshal CBOR sequence into " + rv.Type().String())
-		}
-	default:
-		return errors.New("cbor: unknown tag " + strconv.Itoa(int(n)))
-	}
-	return nil
-}
-
-// decode decodes a CBOR value into rv. rv must be a pointer to a value,
-// or an interface value.
-func (dec *Decoder) decode(rv reflect.Value) error {
-	// Check if the value is not a pointer to a value.
-	if rv.Kind() != reflect.Ptr {
-		return errors.New("cbor: cannot unmarshal into non-pointer " + rv.Type().String())
-	}
-
-	// Check if the value is a nil pointer, and if so,
-	// allocate a new value.
-	if rv.IsNil() {
-		rv.Set(reflect.New(rv.Type().Elem()))
-	}
-
-	// Dereference the pointer to get the value.
-	rv = rv.Elem()
-
-	// Check the kind of the dereferenced value
-	switch rv.Kind() {
-	case reflect.Interface:
-		// Check if the interface has any methods
-		if rv.NumMethod() != 0 {
-			return errors.New("cbor: cannot unmarshal into non-empty interface " + rv.Type().String())
-		}
-		// Set the value of the interface to the decoder reader
-		rv.Set(reflect.ValueOf(&dec.r).Elem())
-		return nil
-	case reflect.Ptr:
-		// Check if the pointer is nil
-		if rv.IsNil() {
-			// Create a new value of the same type and set it to the pointer
-			rv.Set(reflect.New(rv.Type().Elem()))
-		}
-		// Dereference the pointer
-		rv = rv.Elem()
-	case reflect.Struct:
-		return dec.decodeStruct(rv)
-	case reflect.Slice:
-		return dec.decodeSlice(rv)
-	case reflect.Map:
-		return dec.decodeMap(rv, byte(rv.Len()))
-	}
-
-	return dec.decodeBasic(rv)
-}
-
-// decodeStruct decodes a CBOR map into rv. rv must be a pointer to a struct.
-//
-// CBOR structs are represented as CBOR maps. The keys of the map are
-// the names of the struct fields. The values of the map are the values
-// of the struct fields.
-func (dec *Decoder) decodeStruct(rv reflect.Value) error {
-	// Read the map header, n is the number of key/value pairs.
-	n, err := dec.readMapHeader()
-	if err != nil {
-		return err
-	}
-
-	for i := 0; i < n; i++ {
-		key, err := dec.readString()
-		if err != nil {
-			return err
-		}
-
-		fv := rv.FieldByNameFunc(func(name string) bool {
-			return bytes.EqualFold([]byte(name), []byte(key))
-		})
-
-		if !fv.IsValid() {
-			return errors.New("cbor: unknown field " + string(key))
-		}
-
-		if err := dec.decode(fv.Addr()); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-// decodeSlice decodes a CBOR array into rv. rv must be a pointer to a slice.
-func (dec *Decoder) decodeSlice(rv reflect.Value) error {
-	n, err := dec.readArrayHeader()
-	if err != nil {
-		return err
-	}
-
-	if n > dec.options.MaxArrayElements {
-		return errors.New("cbor: slice (array) too large")
-	}
-
-	// Reuse the existing slice if possible.
-	if rv.IsNil() || rv.Cap() < n {
-		// Allocate a new slice.
-		sv := reflect.MakeSlice(rv.Type(), n, n)
-		rv.Set(sv)
-	} else {
-		// Reuse the existing slice if the size is the same.
-		if rv.Len() != n || rv.Cap() != n {
-			rv.SetLen(n)
-		}
-	}
-
-	// Read the slice elements.
-	for i := 0; i < n; i++ {
-		if err := dec.decode(rv.Index(i).Addr()); err != nil {
-			return err
+			return typeError("CBOR sequence", rv.Type())
 		}
-	}
-
-	return nil
-}
-
-// decodeBasic decodes a CBOR value into rv. rv must be a pointer to a basic
-// value.
-func (dec *Decoder) decodeBasic(rv reflect.Value) error {
-	switch rv.Kind() {
-	case reflect.Bool:
-		b, err := dec.readBool()
-		if err != nil {
-			return err
+	case TagEncodedSequence:
+		// RFC 8742, section 4.1. Encoded CBOR Sequence
+		//
+		// The content of the tag is a byte string containing a CBOR
+		// sequence, whose items are decoded into the elements of a slice.
+		return dec.decodeEncodedSequence(rv)
+	case TagEpochDate, TagFullDate:
+		// RFC 8943, section 2. Dates
+		//
+		// The content of the tag is a number of days since 1970-01-01, or
+		// a full-date string, which is decoded into a time.Time at
+		// midnight UTC.
+		if rv.Kind() != reflect.Interface || rv.NumMethod() != 0 {
+			return typeError("date", rv.Type())
 		}
-		rv.SetBool(b)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		n, err := dec.readInt()
+		b, err := dec.readByte()
 		if err != nil {
-			return err
+			return unexpectedEOF(err)
 		}
-		rv.SetInt(int64(n))
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		n, err := dec.readUint()
+		t, err := dec.readDateContent(rv.Type(), n, b)
 		if err != nil {
 			return err
 		}
-		rv.SetUint(uint64(n))
-	case reflect.Float32, reflect.Float64:
-		f, err := dec.readFloat()
-		if err != nil {
-			return err
+		rv.Set(reflect.ValueOf(t))
+	case TagExtendedTime:
+		// RFC 9581, section 3. Extended Time
+		//
+		// The content of the tag is a map of the parts of the time, which
+		// is decoded into an ExtendedTime, or a time.Time through its
+		// decoder.
+		if rv.Type() != extendedTimeType && (rv.Kind() != reflect.Interface || rv.NumMethod() != 0) {
+			return typeError("extended time", rv.Type())
 		}
-		rv.SetFloat(f)
-	case reflect.String:
-		s, err := dec.readString()
+		t, err := dec.readExtendedTime()
 		if err != nil {
 			return err
 		}
-		rv.SetString(string(s))
-	case reflect.Interface:
-		if rv.NumMethod() != 0 {
-			return errors.New("cbor: cannot unmarshal into non-empty interface " + rv.Type().String())
-		}
-		rv.Set(reflect.ValueOf(&dec.r).Elem())
+		rv.Set(reflect.ValueOf(t))
 	default:
-		return errors.New("cbor: cannot unmarshal into " + rv.Type().String())
+		switch dec.options.UnknownTags {
+		case UnknownTagUnwrap:
+			return dec.decodeValue(rv)
+		case UnknownTagPreserve:
+			if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+				return dec.decodeTagContent(rv, n)
+			}
+		}
+		return errors.New("cbor: unknown tag " + strconv.FormatUint(n, 10))
 	}
 	return nil
 }
 
-// readArrayHeader reads an array header from the CBOR stream.
-func (dec *Decoder) readArrayHeader() (int, error) {
-	b, err := dec.readByte()
-	if err != nil {
-		return 0, err
-	}
-	switch {
-	case b >= 0x80 && b <= 0x8f:
-		return int(b & 0x0f), nil
-	case b == 0x9f:
-		return dec.readInt()
-	case b >= 0x40 && b <= 0x5f: // handle []byte
-		n := int(b & 0x1f)
-		return n, nil
-	default:
-		return 0, fmt.Errorf("cbor: invalid array header: %X", b)
-	}
-}
-
-// readMapHeader reads a map header from the CBOR stream.
-func (dec *Decoder) readMapHeader() (int, error) {
-	b, err := dec.readByte()
-	if err != nil {
-		return 0, err
-	}
-	switch {
-	case b >= 0xa0 && b <= 0xaf:
-		return int(b & 0x0f), nil
-	case b == 0xbf:
-		return dec.readInt()
-	default:
-		return 0, errors.New("cbor: invalid map header")
-	}
-}
-
-// readBool reads a boolean value from the CBOR stream.
-func (dec *Decoder) readBool() (bool, error) {
-	b, err := dec.readByte()
-	if err != nil {
-		return false, err
-	}
-	switch b {
-	case 0xf4:
-		return false, nil
-	case 0xf5:
-		return true, nil
-	default:
-		return false, errors.New("cbor: invalid boolean value")
-	}
-}
-
-// readInt reads an integer value from the CBOR stream.
-func (dec *Decoder) readInt() (int, error) {
-	b, err := dec.readByte()
-	if err != nil {
-		return 0, err
-	}
-
-	// Check if unsigned 32-bit integer.
-	if b == 0x1A {
-		n, err := dec.readUint32()
-		if err != nil {
-			return 0, err
-		}
-		return int(n), nil
-	}
-
-	// Check if unsigned 64-bit integer.
-	if b == 0x1B {
-		n, err := dec.readUint64()
-		if err != nil {
-			return 0, err
-		}
-		return int(n), nil
-	}
-
-	switch {
-	case b <= 0x17:
-		return int(b), nil
-	case b >= 0x18 && b <= 0x1f:
-		return int(b & 0x1f), nil
-	case b == 0x20:
-		n, err := dec.readUint64()
-		if err != nil {
-			return 0, err
-		}
-		return int(n), nil
-	case b == 0x21:
-		n, err := dec.readUint32()
-		if err != nil {
-			return 0, err
-		}
-		return int(n), nil
-	case b == 0x22:
-		n, err := dec.readUint64()
-		if err != nil {
-			return 0, err
-		}
-		return int(n), nil
-	default:
-		return 0, errors.New("cbor: invalid integer value: " + fmt.Sprintf("%X", b))
+// decode decodes a CBOR value into the value rv points to, allocating it
+// if rv is nil.
+func (dec *Decoder) decode(rv reflect.Value) error {
+	// Check if the value is not a pointer to a value.
+	if rv.Kind() != reflect.Ptr {
+		return errors.New("cbor: cannot unmarshal into non-pointer " + rv.Type().String())
 	}
-}
 
-// readUint reads an unsigned integer value from the CBOR stream.
-func (dec *Decoder) readUint() (uint, error) {
-	b, err := dec.readByte()
-	if err != nil {
-		return 0, err
-	}
-	switch {
-	case b <= 0x17:
-		return uint(b), nil
-	case b >= 0x18 && b <= 0x1f:
-		return uint(b & 0x1f), nil
-	case b == 0x20:
-		n, err := dec.readUint16()
-		if err != nil {
-			return 0, err
-		}
-		return uint(n), nil
-	case b == 0x21:
-		n, err := dec.readUint32()
-		if err != nil {
-			return 0, err
-		}
-		return uint(n), nil
-	case b == 0x22:
-		n, err := dec.readUint64()
-		if err != nil {
-			return 0, err
-		}
-		return uint(n), nil
-	default:
-		return uint(b), nil
-		// return 0, errors.New("cbor: invalid usigned integer value: " + fmt.Sprintf("%X", b))
+	// Check if the value is a nil pointer, and if so,
+	// allocate a new value.
+	if rv.IsNil() {
+		rv.Set(reflect.New(rv.Type().Elem()))
 	}
-}
 
-// readFloat reads a floating point value from the CBOR stream.
-func (dec *Decoder) readFloat() (float64, error) {
-	b, err := dec.readByte()
-	if err != nil {
-		return 0, err
-	}
-	switch b {
-	case 0xf9:
-		return dec.readFloat16()
-	case 0xfa:
-		return dec.readFloat32()
-	case 0xfb:
-		return dec.readFloat64()
-	default:
-		// If the value is not a float, then it must be an integer.
-		// We can convert it to a float by casting it to a float64.
-		// This is a bit of a hack, but it works.
-		return float64(b), nil
-	}
+	return dec.decodeValue(rv.Elem())
 }
 
 // readFloat16 reads a 16-bit floating point value from the CBOR stream.
@@ -1866,7 +2094,10 @@ func (dec *Decoder) readFloat16() (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return float64(math.Float32frombits(uint32(b))), nil
+	if err := dec.checkFloat(b, 2); err != nil {
+		return 0, err
+	}
+	return float16ToFloat64(uint16(b)), nil
 }
 
 // readFloat32 reads a 32-bit floating point value from the CBOR stream.
@@ -1875,6 +2106,9 @@ func (dec *Decoder) readFloat32() (float64, error) {
 	if err != nil {
 		return 0, err
 	}
+	if err := dec.checkFloat(b, 4); err != nil {
+		return 0, err
+	}
 	return float64(math.Float32frombits(uint32(b))), nil
 }
 
@@ -1884,37 +2118,10 @@ func (dec *Decoder) readFloat64() (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return math.Float64frombits(b), nil
-}
-
-// readString reads a string value from the CBOR stream.
-func (dec *Decoder) readString() ([]byte, error) {
-	b, err := dec.readByte()
-	if err != nil {
-		return nil, err
-	}
-	switch {
-	case b >= 0x60 && b <= 0x77: // less than 24 bytes
-		n := int(b & 0x1f)
-
-		return dec.readStringBytes(n)
-	case b >= 0x78 && b <= 0x7f: // more than 24 bytes (less than 256 bytes)
-		n, err := dec.readInt()
-		if err != nil {
-			return nil, err
-		}
-		return dec.readStringBytes(n)
-	case b == 0x7f: // indefinite length (TODO: verify this is correct)
-		n, err := dec.readInt()
-		if err != nil {
-			return nil, err
-		}
-		return dec.readStringBytes(n)
-	case b == 0xf6: // null string
-		return nil, nil
-	default:
-		return nil, fmt.Errorf("cbor: invalid string value: %X", b)
+	if err := dec.checkFloat(b, 8); err != nil {
+		return 0, err
 	}
+	return math.Float64frombits(b), nil
 }
 
 // readStringBytes reads a string value from the CBOR stream.
@@ -1926,115 +2133,100 @@ func (dec *Decoder) readStringBytes(n int) ([]byte, error) {
 
 	// Check that the string is not too large.
 	if n > dec.options.MaxStringBytes {
-		return nil, fmt.Errorf("cbor: string too large: %d bytes", n)
-	}
-
-	// Ensure that the buffer has sufficient capacity
-	if cap(dec.buffer) < n {
-		dec.buffer = make([]byte, n)
+		return nil, dec.limitExceeded(newError(ErrStringTooLong, fmt.Sprintf("cbor: string too large: %d bytes", n)))
 	}
 
-	// Use the buffer slice with the correct length
-	buf := dec.buffer[:n]
-
-	// Read the string bytes
-	_, err := dec.r.Read(buf)
+	buf, err := dec.next(n)
 	if err != nil {
-		if err == io.EOF {
-			return nil, io.ErrUnexpectedEOF
-		}
-		return nil, err
+		return nil, unexpectedEOF(err)
 	}
-
 	return buf, nil
 }
 
-// readMapKey reads a map key from the CBOR stream.
+// mapKey reads the map key whose initial byte b has already been read, for
+// matching it to the name of a struct field. The content of text and byte
+// strings is returned as key, which may alias the decoder's buffer, and
+// other keys are returned as name, such as "-1" for a negative integer,
+// "true" for a boolean, or the decimal string of a float.
 //
-// Used internally by decodeMap for decoding struct fields.
-func (dec *Decoder) readMapKey() (any, error) {
-	b, err := dec.readByte()
-	if err != nil {
-		return nil, err
+// Used internally by the compiled struct decoders for decoding struct
+// fields.
+func (dec *Decoder) mapKey(b byte) (key []byte, name string, err error) {
+	mt, ai := MajorType(b>>5), b&0x1f
+	if ai >= 28 {
+		if err := dec.checkHead(b); err != nil {
+			return nil, "", err
+		}
 	}
-	switch {
-	case b <= 0x17:
-		return int(b), nil
-	case b >= 0x18 && b <= 0x1f:
-		return int(b & 0x1f), nil
-	case b == 0x20:
-		n, err := dec.readUint16()
+
+	switch mt {
+	case MajorTypeUnsignedInt:
+		n, err := dec.readArgument(ai)
 		if err != nil {
-			return nil, err
+			return nil, "", unexpectedEOF(err)
 		}
-		return int(n), nil
-	case b == 0x21:
-		n, err := dec.readUint32()
-		if err != nil {
-			return nil, err
+		if n < uint64(len(smallInts)) {
+			return nil, smallInts[n], nil
 		}
-		return int(n), nil
-	case b == 0x22:
-		n, err := dec.readUint64()
+		return nil, strconv.FormatUint(n, 10), nil
+	case MajorTypeNegativeInt:
+		n, err := dec.readArgument(ai)
 		if err != nil {
-			return nil, err
+			return nil, "", unexpectedEOF(err)
 		}
-		return int(n), nil
-	case b >= 0x30 && b <= 0x37: // less than 24 bytes
-		n := int(b & 0x1f)
-
-		return dec.readStringBytes(n)
-	case b >= 0x38 && b <= 0x3f: // more than 24 bytes (less than 256 bytes)
-		n := int(b & 0x1f)
-
-		return dec.readStringBytes(n)
-	case b == 0x7f: // indefinite length
-		n, err := dec.readInt()
-		if err != nil {
-			return nil, err
+		if n > math.MaxInt64 {
+			return nil, new(big.Int).Not(new(big.Int).SetUint64(n)).String(), nil
 		}
-		return dec.readStringBytes(n)
-	case b == 0x3f: // more than 256 bytes (less than 65536 bytes)
-		n, err := dec.readInt()
-		if err != nil {
-			return nil, err
-		}
-		return dec.readStringBytes(n)
-	case b == 0xf6: // null string
-		return "", nil
-	case b == 0xf7: // null bytes
-		return []byte{}, nil
-	case b == 0xf8: // null array
-		return []any{}, nil
-	case b == 0xf9: // null map
-		return map[any]any{}, nil
-	case b == 0xfa: // null tag
-		return nil, nil
-	case b == 0xfb: // null simple value
-		return nil, nil
-	case b == 0xff: // null
-		return nil, nil
-	case b == 0x40:
-		return false, nil
-	case b >= 0x60 && b <= 0x77: // less than 24 bytes
-		n := int(b & 0x1f)
-
-		return dec.readStringBytes(n)
-	case b >= 0x78 && b <= 0x7f: // more than 24 bytes (less than 256 bytes)
-		n, err := dec.readInt()
+		return nil, strconv.FormatInt(-1-int64(n), 10), nil
+	case MajorTypeByteString, MajorTypeTextString:
+		if ai == 31 {
+			raw, err := dec.appendRawItem(nil, b, dec.depth)
+			if err != nil {
+				return nil, "", err
+			}
+			key, _, err := readStringBytes(raw, mt)
+			return key, "", err
+		}
+		n, err := dec.readArgument(ai)
 		if err != nil {
-			return nil, err
+			return nil, "", unexpectedEOF(err)
+		}
+		if n > math.MaxInt32 || n > uint64(dec.options.MaxStringBytes) {
+			return nil, "", dec.limitExceeded(ErrStringTooLong)
 		}
-		return dec.readStringBytes(n)
-	case b == 0x7f: // indefinite length
-		n, err := dec.readInt()
+		key, err := dec.next(int(n))
 		if err != nil {
-			return nil, err
+			return nil, "", unexpectedEOF(err)
 		}
-		return dec.readStringBytes(n)
-	default:
-		return nil, fmt.Errorf("cbor: invalid map key: %X", b)
+		return key, "", nil
+	}
+
+	// Other keys, such as booleans, floats and tagged items, are decoded
+	// as usual, and formatted.
+	var v interface{}
+	if err := dec.decodeItem(reflect.ValueOf(&v).Elem(), b); err != nil {
+		return nil, "", err
+	}
+	return nil, toString(v), nil
+}
+
+// smallInts are the decimal strings of the integers 0 to 255, which cover
+// the integer keys of virtually all keyasint protocols, so matching them
+// to struct fields doesn't allocate.
+var smallInts = func() (s [256]string) {
+	for i := range s {
+		s[i] = strconv.Itoa(i)
 	}
+	return s
+}()
+
+// itoa returns the decimal string of n, without allocating for small
+// non-negative integers.
+func itoa(n int64) string {
+	if n >= 0 && n < int64(len(smallInts)) {
+		return smallInts[n]
+	}
+	return strconv.FormatInt(n, 10)
 }
 
 // toString converts any Go value to a string as fast as possible
@@ -2046,25 +2238,25 @@ func toString(v any) string {
 	case []byte:
 		return string(v)
 	case int:
-		return strconv.Itoa(v)
+		return itoa(int64(v))
 	case int8:
-		return strconv.Itoa(int(v))
+		return itoa(int64(v))
 	case int16:
-		return strconv.Itoa(int(v))
+		return itoa(int64(v))
 	case int32:
-		return strconv.Itoa(int(v))
+		return itoa(int64(v))
 	case int64:
-		return strconv.Itoa(int(v))
+		return itoa(v)
 	case uint:
-		return strconv.Itoa(int(v))
+		return itoa(int64(v))
 	case uint8:
-		return strconv.Itoa(int(v))
+		return itoa(int64(v))
 	case uint16:
-		return strconv.Itoa(int(v))
+		return itoa(int64(v))
 	case uint32:
-		return strconv.Itoa(int(v))
+		return itoa(int64(v))
 	case uint64:
-		return strconv.Itoa(int(v))
+		return strconv.FormatUint(v, 10)
 	case float32:
 		return strconv.FormatFloat(float64(v), 'f', -1, 32)
 	case float64:
@@ -2080,51 +2272,3 @@ func toString(v any) string {
 		return fmt.Sprintf("%v", v)
 	}
 }
-
-// readValue reads a value from the CBOR stream.
-func (dec *Decoder) readValue() (any, error) {
-	b, err := dec.readByte()
-	if err != nil {
-		return nil, err
-	}
-	switch {
-	case b <= 0x17:
-		return int(b), nil
-	case b >= 0x18 && b <= 0x1f:
-		return int(b & 0x1f), nil
-	case b == 0x20:
-		n, err := dec.readUint16()
-		if err != nil {
-			return nil, err
-		}
-		return int(n), nil
-	case b == 0x21:
-		n, err := dec.readUint32()
-		if err != nil {
-			return nil, err
-		}
-		return int(n), nil
-	case b == 0x22:
-		n, err := dec.readUint64()
-		if err != nil {
-			return nil, err
-		}
-		return int(n), nil
-	case b >= 0x30 && b <= 0x37:
-		n := int(b & 0x1f)
-
-		return dec.readStringBytes(n)
-	case b >= 0x38 && b <= 0x3f:
-		n := int(b & 0x1f)
-
-		return dec.readStringBytes(n)
-	case b == 0x3f:
-		n, err := dec.readInt()
-		if err != nil {
-			return nil, err
-		}
-		return dec.readStringBytes(n)
-	default:
-		return nil, fmt.Errorf("cbor: invalid value: %X", b)
-	}
-}
@@ -2,10 +2,18 @@ package cbor_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/picatz/cbor"
 	// otherCbor "github.com/fxamacker/cbor/v2"
@@ -269,6 +277,30 @@ func TestDecodeBoolPointer(t *testing.T) {
 	}
 }
 
+func TestDecodeInterface(t *testing.T) {
+	tests := []struct {
+		data string
+		want interface{}
+	}{
+		{"f5", true},
+		{"f4", false},
+		{"820102", []interface{}{uint64(1), uint64(2)}},
+		{"8263616263f4", []interface{}{"abc", false}},
+		{"a1616101", map[interface{}]interface{}{"a": uint64(1)}},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.data)
+		var v interface{}
+		if err := cbor.Unmarshal(data, &v); err != nil {
+			t.Fatalf("%s: %v", test.data, err)
+		}
+		if !reflect.DeepEqual(v, test.want) {
+			t.Errorf("%s: expected %#v, got %#v", test.data, test.want, v)
+		}
+	}
+}
+
 func TestDecodeString(t *testing.T) {
 	data := "\x66\x66\x6F\x6F\x62\x61\x72" // "foobar"
 
@@ -464,22 +496,10 @@ func TestDecodeArray(t *testing.T) {
 					t.Fatal("expected bar, got", value[0].Foo)
 				}
 
-				// Note: it's super weird to handle this case.
-				//
-				// If the CBOR map value is null, how should
-				// the struct field be handled? Should it be
-				// set to nil, or should it be set to the
-				// zero value of the field type? How do you distinguish
-				// between a null value, zero value, or a non-existent
-				// value in the data?
-				//
-				// TODO: figure out how to handle this case canonically,
-				//       and document it.
-				if value[0].Baz == nil {
-					t.Fatal("expected non-nil, got nil")
-				}
-				if *value[0].Baz != "" {
-					t.Fatal("expected empty string, got", *value[0].Baz)
+				// A null map value sets a pointer field to nil, so
+				// it round-trips with the encoding of a nil pointer.
+				if value[0].Baz != nil {
+					t.Fatal("expected nil, got", *value[0].Baz)
 				}
 			})
 
@@ -496,11 +516,8 @@ func TestDecodeArray(t *testing.T) {
 				if value[0].Foo != "bar" {
 					t.Fatal("expected bar, got", value[0].Foo)
 				}
-				if value[0].Baz == nil {
-					t.Fatal("expected non-nil, got nil")
-				}
-				if *value[0].Baz != "" {
-					t.Fatal("expected empty string, got", *value[0].Baz)
+				if value[0].Baz != nil {
+					t.Fatal("expected nil, got", *value[0].Baz)
 				}
 			})
 		})
@@ -514,7 +531,7 @@ func TestDecodeArray(t *testing.T) {
 					Baz *string
 				}
 				err := cbor.NewDecoder(bytes.NewBufferString(data)).Decode(&value)
-				if err != nil && !strings.Contains(err.Error(), "EOF") {
+				if err != nil && !errors.Is(err, cbor.ErrTruncated) {
 					t.Fatal(err)
 				}
 
@@ -532,7 +549,7 @@ func TestDecodeArray(t *testing.T) {
 					Baz *string
 				}
 				err := cbor.NewDecoder(bytes.NewBufferString(data)).Decode(&value)
-				if err != nil && !strings.Contains(err.Error(), "EOF") {
+				if err != nil && !errors.Is(err, cbor.ErrTruncated) {
 					t.Fatal(err)
 				}
 
@@ -974,6 +991,27 @@ func BenchmarkUnmarshalCWTClaims(b *testing.B) {
 	}
 }
 
+func TestUnmarshalStructRepeated(t *testing.T) {
+	type pair struct {
+		A int    `cbor:"a"`
+		B string `cbor:"b,omitempty"`
+	}
+
+	// Each value must be decoded into its own struct, not one shared
+	// through the field cache.
+	var first, second pair
+	if err := cbor.Unmarshal([]byte{0xa2, 0x61, 'a', 0x01, 0x61, 'b', 0x61, 'x'}, &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := cbor.Unmarshal([]byte{0xa2, 0x61, 'a', 0x02, 0x61, 'b', 0x61, 'y'}, &second); err != nil {
+		t.Fatal(err)
+	}
+
+	if first != (pair{1, "x"}) || second != (pair{2, "y"}) {
+		t.Fatalf("got %+v and %+v", first, second)
+	}
+}
+
 // Competitive analysis.
 //
 // $ go test -benchmem -run=^$ -bench ^BenchmarkUnmarshalCWTClaims_other$ github.com/picatz/cbor -v
@@ -1005,3 +1043,1566 @@ func BenchmarkUnmarshalCWTClaims(b *testing.B) {
 // 		}
 // 	}
 // }
+
+func TestDecodePlans(t *testing.T) {
+	type inner struct {
+		Name string `cbor:"n"`
+		ID   int    `cbor:"1,keyasint"`
+	}
+	type outer struct {
+		Inner  inner                  `cbor:"inner"`
+		Ptr    *inner                 `cbor:"ptr"`
+		List   []inner                `cbor:"list"`
+		Fixed  [2]float64             `cbor:"fixed"`
+		Any    map[interface{}]string `cbor:"any"`
+		Unused int
+	}
+
+	// {"inner": {"n": "a", 1: 2}, "ptr": {_ "n": "b"}, "list": [_ {"n": "c"}],
+	//  "fixed": [1.5 (float16), -2], "any": {1: "x"}, "other": [1, {2: 3}]}
+	data, err := hex.DecodeString("a6" +
+		"65696e6e6572" + "a2616e61610102" +
+		"63707472" + "bf616e6162ff" +
+		"646c697374" + "9fa1616e6163ff" +
+		"656669786564" + "82f93e0021" +
+		"63616e79" + "a1016178" +
+		"656f74686572" + "8201a10203")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v outer
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Inner != (inner{Name: "a", ID: 2}) {
+		t.Errorf("unexpected Inner: %+v", v.Inner)
+	}
+	if v.Ptr == nil || v.Ptr.Name != "b" {
+		t.Errorf("unexpected Ptr: %+v", v.Ptr)
+	}
+	if len(v.List) != 1 || v.List[0].Name != "c" {
+		t.Errorf("unexpected List: %+v", v.List)
+	}
+	if v.Fixed != [2]float64{1.5, -2} {
+		t.Errorf("unexpected Fixed: %v", v.Fixed)
+	}
+	if len(v.Any) != 1 || v.Any[uint64(1)] != "x" {
+		t.Errorf("unexpected Any: %v", v.Any)
+	}
+}
+
+func TestDecodePlans_recursive(t *testing.T) {
+	type node struct {
+		Value    int     `cbor:"v"`
+		Children []*node `cbor:"c"`
+	}
+
+	// {"v": 1, "c": [{"v": 2, "c": []}]}
+	data, err := hex.DecodeString("a2617601616381a2617602616380")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n node
+	if err := cbor.Unmarshal(data, &n); err != nil {
+		t.Fatal(err)
+	}
+	if n.Value != 1 || len(n.Children) != 1 || n.Children[0].Value != 2 || len(n.Children[0].Children) != 0 {
+		t.Fatalf("unexpected node: %+v", n)
+	}
+}
+
+func TestDecodeDepth(t *testing.T) {
+	// nested returns n nested arrays around the integer 0.
+	nested := func(n int) []byte {
+		return append(bytes.Repeat([]byte{0x81}, n), 0x00)
+	}
+
+	var v interface{}
+	if err := cbor.Unmarshal(nested(cbor.DefaultMaxDepth), &v); err != nil {
+		t.Fatalf("expected %d nested arrays to decode: %v", cbor.DefaultMaxDepth, err)
+	}
+	if err := cbor.Unmarshal(nested(cbor.DefaultMaxDepth+1), &v); !errors.Is(err, cbor.ErrMaxDepth) {
+		t.Fatalf("expected a nesting depth error, got %v", err)
+	}
+
+	// Adversarial inputs fail quickly, without exhausting the stack, both
+	// into interfaces and into recursive types.
+	type list []list
+	var l list
+	for _, target := range []interface{}{&v, &l} {
+		if err := cbor.Unmarshal(nested(1_000_000), target); err == nil {
+			t.Fatalf("expected an error decoding into %T", target)
+		}
+	}
+
+	// Maps and arrays count alike: [{"a": [0]}] is 3 deep.
+	dec := cbor.NewDecoder(bytes.NewReader([]byte{0x81, 0xa1, 0x61, 0x61, 0x81, 0x00}))
+	dec.SetMaxDepth(2)
+	if err := dec.Decode(&v); err == nil {
+		t.Fatal("expected an error with a max depth of 2")
+	}
+}
+
+func TestDecoder_inMemory(t *testing.T) {
+	// A bytes.Buffer is advanced past each item, and can be written to
+	// between items.
+	buf := bytes.NewBuffer([]byte{0x01, 0x82, 0x02, 0x03})
+	dec := cbor.NewDecoder(buf)
+
+	var n int
+	if err := dec.Decode(&n); err != nil || n != 1 {
+		t.Fatalf("unexpected %d (%v)", n, err)
+	}
+	if buf.Len() != 3 {
+		t.Fatalf("expected 3 unread bytes, got %d", buf.Len())
+	}
+	buf.WriteByte(0x04)
+
+	var s []int
+	if err := dec.Decode(&s); err != nil || len(s) != 2 || s[0] != 2 || s[1] != 3 {
+		t.Fatalf("unexpected %v (%v)", s, err)
+	}
+	if err := dec.Decode(&n); err != nil || n != 4 {
+		t.Fatalf("unexpected %d (%v)", n, err)
+	}
+	if err := dec.Decode(&n); err == nil {
+		t.Fatal("expected an error at the end of the buffer")
+	}
+
+	// A bytes.Reader is advanced past each item, and can be read from
+	// between items.
+	r := bytes.NewReader([]byte{0x01, 0xff, 0x61, 0x61})
+	dec = cbor.NewDecoder(r)
+	dec.EnableStats()
+	if err := dec.Decode(&n); err != nil || n != 1 {
+		t.Fatalf("unexpected %d (%v)", n, err)
+	}
+	if b, err := r.ReadByte(); err != nil || b != 0xff {
+		t.Fatalf("unexpected %x (%v)", b, err)
+	}
+	var str string
+	if err := dec.Decode(&str); err != nil || str != "a" {
+		t.Fatalf("unexpected %q (%v)", str, err)
+	}
+	if r.Len() != 0 {
+		t.Fatalf("expected no unread bytes, got %d", r.Len())
+	}
+	if stats := dec.Stats(); stats.Items != 2 || stats.Bytes != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+// countingReader counts the calls to its Read method.
+type countingReader struct {
+	r     io.Reader
+	reads int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	r.reads++
+	return r.r.Read(p)
+}
+
+func TestDecoder_buffered(t *testing.T) {
+	data, err := cbor.Marshal([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, 0x01, 0x02)
+
+	// Readers which aren't byte-oriented are buffered, rather than read
+	// for every byte.
+	r := &countingReader{r: struct{ io.Reader }{bytes.NewReader(data)}}
+	dec := cbor.NewDecoder(r)
+
+	var s []int
+	if err := dec.Decode(&s); err != nil || len(s) != 10 {
+		t.Fatalf("unexpected %v (%v)", s, err)
+	}
+	if r.reads > 2 {
+		t.Fatalf("expected at most 2 reads, got %d", r.reads)
+	}
+
+	// The data read beyond the decoded item is available from Buffered.
+	rest, err := io.ReadAll(dec.Buffered())
+	if err != nil || !bytes.Equal(rest, []byte{0x01, 0x02}) {
+		t.Fatalf("unexpected buffered data %x (%v)", rest, err)
+	}
+	var n int
+	if err := dec.Decode(&n); err != nil || n != 1 {
+		t.Fatalf("unexpected %d (%v)", n, err)
+	}
+	if stats := dec.Stats(); stats.Bytes != uint64(len(data)-1) {
+		t.Fatalf("expected the stats to count the decoded bytes, got %+v", stats)
+	}
+}
+
+func TestDecodeKeyAsInt_allocs(t *testing.T) {
+	type small struct {
+		A int `cbor:"1,keyasint"`
+		B int `cbor:"2,keyasint"`
+	}
+	type large struct {
+		A int `cbor:"200,keyasint"`
+		B int `cbor:"255,keyasint"`
+	}
+
+	// Integer keys up to 255 are matched to fields without converting them
+	// to new strings, so they decode with the same allocations.
+	smallData, err := cbor.Marshal(small{A: 1, B: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	largeData, err := cbor.Marshal(large{A: 1, B: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		s small
+		l large
+	)
+	smallAllocs := testing.AllocsPerRun(100, func() { cbor.Unmarshal(smallData, &s) })
+	largeAllocs := testing.AllocsPerRun(100, func() { cbor.Unmarshal(largeData, &l) })
+	if l.A != 1 || l.B != 2 {
+		t.Fatalf("unexpected %+v", l)
+	}
+	if largeAllocs != smallAllocs {
+		t.Fatalf("expected %v allocations, got %v", smallAllocs, largeAllocs)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	type record struct {
+		Name string `cbor:"name"`
+	}
+
+	tests := []struct {
+		name string
+		data string
+		v    interface{}
+		want error
+	}{
+		{"truncated", "a1646e616d65", new(record), cbor.ErrTruncated},
+		{"truncated string", "a1646e616d6563", new(record), cbor.ErrTruncated},
+		{"malformed", "a1646e616d65ff", new(record), cbor.ErrMalformed},
+		{"invalid type", "a1646e616d6501", new(record), cbor.ErrInvalidType},
+		{"wrong array length", "820102", new([3]int), cbor.ErrInvalidType},
+		{"array too long", "9a00010000", new([]int), cbor.ErrArrayTooLong},
+		{"map too long", "ba00010000", new(map[string]int), cbor.ErrMapTooLong},
+		{"struct map too long", "ba00010000", new(record), cbor.ErrMapTooLong},
+		{"interface map too long", "ba00010000", new(interface{}), cbor.ErrMapTooLong},
+		{"string too long", "7a00010000", new(string), cbor.ErrStringTooLong},
+		{"byte string too long", "5a00010000", new([]byte), cbor.ErrStringTooLong},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := hex.DecodeString(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cbor.Unmarshal(data, test.v); !errors.Is(err, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, err)
+			}
+		})
+	}
+
+	t.Run("map pairs limit", func(t *testing.T) {
+		// {"a": 1, "b": 2}, definite and indefinite-length.
+		for _, data := range []string{"a2616101616202", "bf616101616202ff"} {
+			b, _ := hex.DecodeString(data)
+			for _, v := range []interface{}{new(map[string]int), new(record), new(interface{}), new(cbor.OrderedMap)} {
+				dec := cbor.NewDecoder(bytes.NewReader(b))
+				dec.SetMaxMapPairs(1)
+				err := dec.Decode(v)
+				if !errors.Is(err, cbor.ErrMapTooLong) {
+					t.Errorf("%s into %T: expected %v, got %v", data, v, cbor.ErrMapTooLong, err)
+				}
+			}
+		}
+
+		// An indefinite-length map of 20 pairs with the same key counts
+		// every pair, not just the distinct keys.
+		b := []byte{0xbf}
+		for i := 0; i < 20; i++ {
+			b = append(b, 0x61, 'a', 0x01)
+		}
+		b = append(b, 0xff)
+		for _, v := range []interface{}{new(map[string]int), new(record), new(interface{})} {
+			dec := cbor.NewDecoder(bytes.NewReader(b))
+			dec.SetMaxMapPairs(5)
+			err := dec.Decode(v)
+			if !errors.Is(err, cbor.ErrMapTooLong) {
+				t.Errorf("repeated keys into %T: expected %v, got %v", v, cbor.ErrMapTooLong, err)
+			}
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		// {"name": "a", "other": 1}
+		data, err := hex.DecodeString("a2646e616d656161656f7468657201")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var v record
+		if err := cbor.Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+
+		dec := cbor.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&v); !errors.Is(err, cbor.ErrUnknownField) {
+			t.Fatalf("expected %v, got %v", cbor.ErrUnknownField, err)
+		}
+	})
+}
+
+func TestDecodeErrorPath(t *testing.T) {
+	type claims struct {
+		Iss string
+		Aud string
+	}
+	type token struct {
+		Claims []claims
+		Extra  map[string][]int
+	}
+
+	tests := []struct {
+		name string
+		data string
+		v    interface{}
+		want string
+	}{
+		{
+			"root", "6178", new(int),
+			"cbor: int: cannot unmarshal string into int (offset 2, near 6178|)",
+		},
+		{
+			// {"Iss": "a", "Aud": 1}
+			"field", "a263497373616163417564" + "01", new(claims),
+			"cbor: claims.Aud: cannot unmarshal uint into string (offset 12, near 7361616341756401|)",
+		},
+		{
+			// {"Claims": [{}, {"Aud": 1}]}
+			"slice element", "a166436c61696d7382a0a16341756401", new(token),
+			"cbor: token.Claims[1].Aud: cannot unmarshal uint into string (offset 16, near 82a0a16341756401|)",
+		},
+		{
+			// {"Extra": {"a": [1, "x"]}}
+			"map value", "a1654578747261a161618201" + "6178", new(*token),
+			"cbor: token.Extra[\"a\"][1]: cannot unmarshal string into int (offset 14, near 61a1616182016178|)",
+		},
+		{
+			// [1, "ab", 2, 3, 4, 5, 6, 7, 8, 9]
+			"snippet", "8a0162616202030405060708" + "09", new([]int),
+			"cbor: []int[1]: cannot unmarshal string into int (offset 5, near 8a01626162|0203040506070809)",
+		},
+		{
+			// [1, [2, {"a": <truncated>
+			"interface", "820182" + "02a16161", new(interface{}),
+			"cbor: interface {}[1][1][\"a\"]: unexpected EOF (offset 7, near 82018202a16161|)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := hex.DecodeString(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = cbor.Unmarshal(data, test.v)
+			var target *cbor.DecodeError
+			if !errors.As(err, &target) {
+				t.Fatalf("expected a DecodeError, got %v", err)
+			}
+			if err.Error() != test.want {
+				t.Fatalf("expected %q, got %q", test.want, err)
+			}
+		})
+	}
+
+	t.Run("no snippet", func(t *testing.T) {
+		dec := cbor.NewDecoder(bytes.NewReader([]byte{0x61, 0x78}))
+		dec.SetErrorContext(0)
+
+		var n int
+		want := "cbor: int: cannot unmarshal string into int (offset 2)"
+		if err := dec.Decode(&n); err == nil || err.Error() != want {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		// 1, "x", where the second item is decoded into an int.
+		dec := cbor.NewDecoder(struct{ io.Reader }{bytes.NewReader([]byte{0x01, 0x61, 0x78})})
+		var n int
+		if err := dec.Decode(&n); err != nil {
+			t.Fatal(err)
+		}
+		err := dec.Decode(&n)
+		var target *cbor.DecodeError
+		if !errors.As(err, &target) || target.Offset != 3 || !errors.Is(err, cbor.ErrInvalidType) {
+			t.Fatalf("expected an invalid type error at offset 3, got %v", err)
+		}
+	})
+
+	t.Run("deep", func(t *testing.T) {
+		// 900 nested arrays, truncated.
+		var v interface{}
+		err := cbor.Unmarshal(bytes.Repeat([]byte{0x81}, 900), &v)
+		var target *cbor.DecodeError
+		if !errors.As(err, &target) {
+			t.Fatalf("expected a DecodeError, got %v", err)
+		}
+		want := "interface {}" + strings.Repeat("[0]", 7) + "…" + strings.Repeat("[0]", 8)
+		if target.Path != want {
+			t.Fatalf("expected path %q, got %q", want, target.Path)
+		}
+	})
+}
+
+func TestUnmarshalToType(t *testing.T) {
+	type record struct {
+		Name string `cbor:"name"`
+	}
+
+	data := cbor.MustMarshal(record{Name: "a"})
+	v, err := cbor.UnmarshalToType(data, reflect.TypeOf(record{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := v.(record); !ok || got.Name != "a" {
+		t.Fatalf("expected a record named a, got %#v", v)
+	}
+
+	if _, err := cbor.UnmarshalToType(data, reflect.TypeOf(0)); !errors.Is(err, cbor.ErrInvalidType) {
+		t.Fatalf("expected %v, got %v", cbor.ErrInvalidType, err)
+	}
+	var target *cbor.InvalidUnmarshalError
+	if _, err := cbor.UnmarshalToType(data, nil); !errors.As(err, &target) {
+		t.Fatalf("expected an InvalidUnmarshalError, got %v", err)
+	}
+}
+
+func TestDecoder_DecodeValue(t *testing.T) {
+	dec := cbor.NewDecoder(bytes.NewReader([]byte{0x01, 0x61, 0x61, 0x02}))
+
+	var n int
+	if err := dec.DecodeValue(reflect.ValueOf(&n).Elem()); err != nil || n != 1 {
+		t.Fatalf("expected 1, got %d (err %v)", n, err)
+	}
+
+	rv := reflect.New(reflect.TypeOf("")).Elem()
+	if err := dec.DecodeValue(rv); err != nil || rv.String() != "a" {
+		t.Fatalf("expected a, got %v (err %v)", rv, err)
+	}
+
+	var target *cbor.InvalidUnmarshalError
+	for _, rv := range []reflect.Value{{}, reflect.ValueOf(n)} {
+		if err := dec.DecodeValue(rv); !errors.As(err, &target) {
+			t.Fatalf("expected an InvalidUnmarshalError, got %v", err)
+		}
+	}
+
+	// The invalid values didn't consume any input.
+	if err := dec.DecodeValue(reflect.ValueOf(&n).Elem()); err != nil || n != 2 {
+		t.Fatalf("expected 2, got %d (err %v)", n, err)
+	}
+}
+
+func TestInvalidUnmarshalError(t *testing.T) {
+	for _, v := range []interface{}{nil, 1, (*int)(nil)} {
+		err := cbor.Unmarshal([]byte{0x01}, v)
+		var target *cbor.InvalidUnmarshalError
+		if !errors.As(err, &target) {
+			t.Fatalf("%#v: expected an InvalidUnmarshalError, got %v", v, err)
+		}
+		if target.Type != reflect.TypeOf(v) {
+			t.Fatalf("%#v: unexpected type %v", v, target.Type)
+		}
+	}
+}
+
+func TestDecodePlans_wrongArrayLength(t *testing.T) {
+	for _, s := range []string{"83010203", "9f0102ff", "9f01020304ff"} {
+		data, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var v [3]int
+		err = cbor.Unmarshal(data, &v)
+		if s == "83010203" {
+			if err != nil || v != [3]int{1, 2, 3} {
+				t.Errorf("%s: unexpected %v (%v)", s, v, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
+
+type benchRecord struct {
+	ID        int               `cbor:"id"`
+	Name      string            `cbor:"name"`
+	Email     string            `cbor:"email"`
+	Active    bool              `cbor:"active"`
+	Score     float64           `cbor:"score"`
+	Tags      []string          `cbor:"tags"`
+	Labels    map[string]string `cbor:"labels"`
+	Parent    *benchRecord      `cbor:"parent,omitempty"`
+	CreatedAt int64             `cbor:"created_at"`
+	UpdatedAt int64             `cbor:"updated_at"`
+}
+
+// The struct decoders, including the map of field indices by key, are
+// built once per type, so decoding a struct only reflects on the fields
+// it sets, and text keys are matched to fields without copying them.
+//
+// $ go test -benchmem -run=^$ -bench ^BenchmarkUnmarshalStruct$ github.com/picatz/cbor -v
+//
+// goos: linux
+// goarch: amd64
+// pkg: github.com/picatz/cbor
+// BenchmarkUnmarshalStruct/fields
+// BenchmarkUnmarshalStruct/fields         	  720913	      1526 ns/op	  94.37 MB/s	     976 B/op	      23 allocs/op
+// BenchmarkUnmarshalStruct/nested
+// BenchmarkUnmarshalStruct/nested         	  444873	      2649 ns/op	  90.61 MB/s	    1448 B/op	      39 allocs/op
+// BenchmarkUnmarshalStruct/unknown_keys
+// BenchmarkUnmarshalStruct/unknown_keys   	 1000000	      1039 ns/op	 119.29 MB/s	     536 B/op	      15 allocs/op
+func BenchmarkUnmarshalStruct(b *testing.B) {
+	record := benchRecord{
+		ID:        42,
+		Name:      "Ada Lovelace",
+		Email:     "ada@example.com",
+		Active:    true,
+		Score:     99.5,
+		Tags:      []string{"math", "engines"},
+		Labels:    map[string]string{"team": "analytical"},
+		CreatedAt: 1444064944,
+		UpdatedAt: 1443944944,
+	}
+	nested := record
+	nested.Parent = &benchRecord{ID: 1, Name: "Lord Byron", Tags: []string{}, Labels: map[string]string{}}
+
+	// A record with extra keys which must be skipped.
+	extra, err := cbor.Marshal(map[string]interface{}{
+		"id": 42, "name": "Ada Lovelace", "email": "ada@example.com", "active": true,
+		"score": 99.5, "created_at": 1444064944, "updated_at": 1443944944,
+		"x-trace": "abc", "x-hops": []int{1, 2, 3},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bm := range []struct {
+		name string
+		v    interface{}
+		data []byte
+	}{
+		{name: "fields", v: record},
+		{name: "nested", v: nested},
+		{name: "unknown keys", data: extra},
+	} {
+		data := bm.data
+		if data == nil {
+			if data, err = cbor.Marshal(bm.v); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				var v benchRecord
+				if err := cbor.Unmarshal(data, &v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// deviceID is a map key type which implements encoding.TextUnmarshaler,
+// like uuid.UUID.
+type deviceID [4]byte
+
+func (id deviceID) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(id[:])), nil
+}
+
+func (id *deviceID) UnmarshalText(text []byte) error {
+	if hex.DecodedLen(len(text)) != len(id) {
+		return errors.New("invalid device ID length")
+	}
+	_, err := hex.Decode(id[:], text)
+	return err
+}
+
+func TestDecodeTextUnmarshalerKeys(t *testing.T) {
+	want := map[deviceID]int{{1, 2, 3, 4}: 1, {0xff}: 2}
+	data, err := cbor.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The keys are encoded as their text.
+	var keys map[string]int
+	if err := cbor.Unmarshal(data, &keys); err != nil {
+		t.Fatal(err)
+	}
+	if keys["01020304"] != 1 || keys["ff000000"] != 2 {
+		t.Fatalf("expected hex keys, got %v", keys)
+	}
+
+	var got map[deviceID]int
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// Errors from UnmarshalText are returned.
+	data, err = cbor.Marshal(map[string]int{"0102": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cbor.Unmarshal(data, &got); err == nil || !strings.Contains(err.Error(), "invalid device ID length") {
+		t.Fatalf("expected an invalid length error, got %v", err)
+	}
+}
+
+func TestDecodeStringKeys(t *testing.T) {
+	// {1: "a", -2: {h'0102': true}, "c": [{false: null, 1.5: 0}]}
+	data, err := hex.DecodeString("a301616121a1420102f5616381a2f4f6fa3fc0000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := cbor.NewDecoder(bytes.NewReader(data))
+	dec.SetStringKeys(true)
+
+	var got interface{}
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"1":  "a",
+		"-2": map[string]interface{}{"AQI": true},
+		"c":  []interface{}{map[string]interface{}{"false": nil, "1.5": uint64(0)}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %#v, got %#v", want, got)
+	}
+
+	// The option also applies to maps with string keys.
+	var typed map[string]interface{}
+	dec = cbor.NewDecoder(bytes.NewReader(data))
+	dec.SetStringKeys(true)
+	if err := dec.Decode(&typed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(typed, want) {
+		t.Fatalf("expected %#v, got %#v", want, typed)
+	}
+
+	// Keys that can't be coerced are an error.
+	dec = cbor.NewDecoder(bytes.NewReader([]byte{0xa1, 0x80, 0x00}))
+	dec.SetStringKeys(true)
+	if err := dec.Decode(&got); err == nil {
+		t.Fatal("expected an error for an array key")
+	}
+}
+
+func TestDecodePartial(t *testing.T) {
+	type point struct {
+		X int
+	}
+	type capture struct {
+		A int
+		B string
+		C []point
+		D int
+	}
+
+	data, err := cbor.Marshal(map[string]interface{}{
+		"A": 1,
+		"B": 2,
+		"C": []interface{}{map[string]interface{}{"X": 3}, map[string]interface{}{"X": "four"}},
+		"D": 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := cbor.NewDecoder(bytes.NewReader(data))
+	dec.SetPartial(true)
+
+	var got capture
+	err = dec.Decode(&got)
+	var errs cbor.DecodeErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+	var paths []string
+	for _, err := range errs {
+		var de *cbor.DecodeError
+		if !errors.As(err, &de) {
+			t.Fatalf("expected a DecodeError, got %v", err)
+		}
+		paths = append(paths, de.Path)
+	}
+	sort.Strings(paths)
+	if want := []string{"capture.B", "capture.C[1].X"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("expected paths %q, got %q", want, paths)
+	}
+	if !errors.Is(err, cbor.ErrInvalidType) {
+		t.Fatalf("expected errors to match ErrInvalidType, got %v", err)
+	}
+	if want := (capture{A: 1, C: []point{{3}, {0}}, D: 5}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	// Truncated data is decoded up to where it ends.
+	data, err = cbor.Marshal(struct {
+		A int
+		B string
+		D int
+	}{1, "lost", 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = capture{}
+	err = cbor.Unmarshal(data[:len(data)-4], &got)
+	if !errors.Is(err, cbor.ErrTruncated) {
+		t.Fatalf("expected a truncation error, got %v", err)
+	}
+	if got.A != 1 {
+		t.Fatalf("expected A to be decoded, got %+v", got)
+	}
+}
+
+func TestDecodeSyntaxError(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		offset int64
+	}{
+		{"break", "ff", 0},
+		{"break in array", "8201ff", 2},
+		{"break as map key", "a1ff01", 1},
+		{"reserved uint", "1c", 0},
+		{"reserved negative int", "3d", 0},
+		{"reserved byte string", "5e", 0},
+		{"reserved array", "9c", 0},
+		{"reserved map value", "a16141fd", 3},
+		{"reserved tag", "dc", 0},
+		{"reserved simple value", "fe", 0},
+		{"indefinite uint", "1f", 0},
+	}
+	targets := []func() interface{}{
+		func() interface{} { return new(interface{}) },
+		func() interface{} { return new(int) },
+		func() interface{} { return new([]int) },
+		func() interface{} { return new(struct{ A int }) },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(test.data)
+			for _, target := range targets {
+				v := target()
+				err := cbor.Unmarshal(data, v)
+				// Containers of the wrong type are rejected before their
+				// contents are read.
+				if errors.Is(err, cbor.ErrInvalidType) {
+					continue
+				}
+				var se *cbor.SyntaxError
+				if !errors.As(err, &se) {
+					t.Fatalf("%T: expected a SyntaxError, got %v", v, err)
+				}
+				if se.Offset != test.offset {
+					t.Fatalf("%T: expected offset %d, got %d", v, test.offset, se.Offset)
+				}
+				if !errors.Is(err, cbor.ErrMalformed) {
+					t.Fatalf("%T: expected the error to match ErrMalformed", v)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeStructKeyTypes(t *testing.T) {
+	type keys struct {
+		Neg   int    `cbor:"-1,keyasint"`
+		Name  string `cbor:"Name"`
+		True  int    `cbor:"true"`
+		Big   int    `cbor:"18446744073709551615"`
+		Small int    `cbor:"-18446744073709551616"`
+		Float int    `cbor:"1.5"`
+		Text  int    `cbor:"text"`
+	}
+
+	data, err := hex.DecodeString("a7" +
+		"2001" + // -1: 1
+		"444e616d656178" + // h'4e616d65': "x"
+		"f502" + // true: 2
+		"1bffffffffffffffff03" + // 18446744073709551615: 3
+		"3bffffffffffffffff04" + // -18446744073709551616: 4
+		"fb3ff800000000000005" + // 1.5: 5
+		"7f627465627874ff06") // (_ "te", "xt"): 6
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got keys
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := keys{Neg: 1, Name: "x", True: 2, Big: 3, Small: 4, Float: 5, Text: 6}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	// Byte strings aren't mistaken for booleans.
+	data, _ = hex.DecodeString("a14004")
+	got = keys{}
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != (keys{}) {
+		t.Fatalf("expected no fields to be set, got %+v", got)
+	}
+}
+
+func TestDecodeHugeMapKeys(t *testing.T) {
+	type record struct {
+		Max uint8 `cbor:"18446744073709551615,keyasint"`
+		One uint8 `cbor:"1,keyasint"`
+	}
+
+	data, err := cbor.Marshal(record{Max: 1, One: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(data), "a21bffffffffffffffff010102"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	var r record
+	if err := cbor.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r != (record{Max: 1, One: 2}) {
+		t.Fatalf("unexpected record %+v", r)
+	}
+
+	var m map[uint64]uint8
+	if err := cbor.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[uint64]uint8{math.MaxUint64: 1, 1: 2}; !reflect.DeepEqual(m, want) {
+		t.Fatalf("expected %v, got %v", want, m)
+	}
+
+	var i map[interface{}]interface{}
+	if err := cbor.Unmarshal(data, &i); err != nil {
+		t.Fatal(err)
+	}
+	if i[uint64(math.MaxUint64)] != uint64(1) {
+		t.Fatalf("expected the key to be preserved, got %v", i)
+	}
+
+	// Keys which overflow the key type are an error, rather than wrapping.
+	var s map[int64]uint8
+	if err := cbor.Unmarshal(data, &s); !errors.Is(err, cbor.ErrInvalidType) {
+		t.Fatalf("expected an overflow error, got %v", err)
+	}
+	var small uint8
+	if err := cbor.Unmarshal([]byte{0x19, 0x01, 0x00}, &small); !errors.Is(err, cbor.ErrInvalidType) {
+		t.Fatalf("expected an overflow error, got %v", err)
+	}
+}
+
+func TestDecodeLargeNegativeIntoInterface(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"3b7fffffffffffffff", "-9223372036854775808"},
+		{"3b8000000000000000", "-9223372036854775809"},
+		{"3bffffffffffffffff", "-18446744073709551616"},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.data)
+		var v interface{}
+		if err := cbor.Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(v); got != test.want {
+			t.Fatalf("%s: expected %s, got %s", test.data, test.want, got)
+		}
+	}
+	var v interface{}
+	if err := cbor.Unmarshal([]byte{0x3b, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, &v); v != int64(math.MinInt64) {
+		t.Fatalf("expected int64(math.MinInt64), got %#v (err %v)", v, err)
+	}
+
+	// Map keys aren't truncated.
+	var m map[interface{}]interface{}
+	if err := cbor.Unmarshal(decodeHex(t, "a13bffffffffffffffff01"), &m); err != nil {
+		t.Fatal(err)
+	}
+	for k := range m {
+		if got := fmt.Sprint(k); got != "-18446744073709551616" {
+			t.Fatalf("expected key -18446744073709551616, got %s", got)
+		}
+	}
+}
+
+func TestDecodeInterfaceMapKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		key  interface{}
+	}{
+		{"bool", "a1f501", true},
+		{"float", "a1fb3ff800000000000001", 1.5},
+		{"null", "a1f601", nil},
+		{"byte string", "a142010201", cbor.ByteString("\x01\x02")},
+		{"array", "a1820102" + "01", mustArrayKey(uint64(1), uint64(2))},
+		{"indefinite array", "a19f0102ff" + "01", mustArrayKey(uint64(1), uint64(2))},
+		{"nested array", "a18201820243abcdef" + "01", mustArrayKey(uint64(1), []interface{}{uint64(2), []byte{0xab, 0xcd, 0xef}})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(test.data)
+
+			var v interface{}
+			if err := cbor.Unmarshal(data, &v); err != nil {
+				t.Fatal(err)
+			}
+			if want := map[interface{}]interface{}{test.key: uint64(1)}; !reflect.DeepEqual(v, want) {
+				t.Fatalf("expected %#v, got %#v", want, v)
+			}
+
+			var m map[interface{}]int
+			if err := cbor.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			if want := map[interface{}]int{test.key: 1}; !reflect.DeepEqual(m, want) {
+				t.Fatalf("expected %#v, got %#v", want, m)
+			}
+
+			// The keys are encoded as they were decoded.
+			out, err := cbor.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(out); got != strings.Replace(test.data, "9f0102ff", "820102", 1) {
+				t.Fatalf("expected %s, got %s", test.data, got)
+			}
+		})
+	}
+
+	// Maps can't be keys.
+	for _, v := range []interface{}{new(interface{}), new(map[interface{}]int)} {
+		if err := cbor.Unmarshal([]byte{0xa1, 0xa1, 0x01, 0x02, 0x01}, v); !errors.Is(err, cbor.ErrInvalidType) {
+			t.Fatalf("%T: expected an error for a map key, got %v", v, err)
+		}
+	}
+
+	// Array keys are the same whatever their length, and can be decoded
+	// back into their elements.
+	var m map[interface{}]interface{}
+	data := append(append([]byte{0xa1, 0x99, 0x01, 0x00}, bytes.Repeat([]byte{0x01}, 256)...), 0x02)
+	if err := cbor.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	for k := range m {
+		elems, err := k.(cbor.ArrayKey).Elems()
+		if err != nil || len(elems) != 256 || elems[0] != uint64(1) {
+			t.Fatalf("expected 256 elements, got %v (err %v)", elems, err)
+		}
+	}
+}
+
+// mustArrayKey returns the ArrayKey of elems, panicking on error.
+func mustArrayKey(elems ...interface{}) cbor.ArrayKey {
+	k, err := cbor.NewArrayKey(elems...)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func TestDecodeFloatIntoInteger(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		v        interface{}
+		want     interface{}
+		truncate bool
+		wantErr  bool
+	}{
+		{"whole", "fb4000000000000000", new(int), 2, false, false},
+		{"whole float32", "fa40000000", new(uint8), uint8(2), false, false},
+		{"fraction", "fb3ff8000000000000", new(int), 0, false, true},
+		{"fraction truncated", "fb3ff8000000000000", new(int), 1, true, false},
+		{"negative fraction truncated", "fbbff8000000000000", new(int64), int64(-1), true, false},
+		{"negative into uint", "fbc000000000000000", new(uint), uint(0), true, true},
+		{"overflow", "fb4070000000000000", new(uint8), uint8(0), true, true},
+		{"infinity", "fb7ff0000000000000", new(int), 0, true, true},
+		{"pointer", "fb4000000000000000", new(*int32), int32(2), false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(test.data)
+			dec := cbor.NewDecoder(bytes.NewReader(data))
+			dec.SetTruncateFloats(test.truncate)
+
+			err := dec.Decode(test.v)
+			if test.wantErr {
+				var te *cbor.UnmarshalTypeError
+				if !errors.As(err, &te) || !errors.Is(err, cbor.ErrInvalidType) {
+					t.Fatalf("expected an UnmarshalTypeError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := reflect.ValueOf(test.v).Elem()
+			for got.Kind() == reflect.Ptr {
+				got = got.Elem()
+			}
+			if got.Interface() != test.want {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDecoder_truncatedCounts(t *testing.T) {
+	// Short inputs declaring 2^31-1 elements, pairs or bytes, with limits
+	// which allow them, mustn't make the decoder allocate room for them.
+	opts := cbor.DefaultDecoderOptions
+	opts.MaxArrayElements = math.MaxInt32
+	opts.MaxMapPairs = math.MaxInt32
+	opts.MaxStringBytes = math.MaxInt32
+	opts.MaxBytes = math.MaxInt32
+
+	tests := []struct {
+		data string
+		v    interface{}
+	}{
+		{"9a7fffffff01", new([]int)},
+		{"9a7fffffff6161", new([]string)},
+		{"9a7fffffff01", new(interface{})},
+		{"ba7fffffff0101", new(cbor.OrderedMap)},
+		{"5a7fffffff01", new([]byte)},
+		{"7a7fffffff61", new(string)},
+		{"9a7fffffff01", new(cbor.RawMessage)},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.data)
+		dec := cbor.NewDecoderBytes(data)
+		dec.SetOptions(opts)
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		err := dec.Decode(test.v)
+		runtime.ReadMemStats(&after)
+		if !errors.Is(err, cbor.ErrTruncated) {
+			t.Errorf("%s into %T: expected %v, got %v", test.data, test.v, cbor.ErrTruncated, err)
+		}
+		if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+			t.Errorf("%s into %T: allocated %d bytes", test.data, test.v, n)
+		}
+	}
+}
+
+func TestDecoder_optionScope(t *testing.T) {
+	// The setters of the options, including the limits, only change the
+	// decoder they're called on, and not DefaultDecoderOptions, which
+	// Unmarshal and other decoders use.
+	type record struct {
+		A int
+		B string
+		c int
+		D int `json:"dee"`
+	}
+	tests := []struct {
+		name string
+		set  func(dec *cbor.Decoder)
+		data string
+		v    func() interface{}
+	}{
+		{"SetPartial", func(dec *cbor.Decoder) { dec.SetPartial(true) }, "a26141016142820102", func() interface{} { return new(record) }},
+		{"SetTruncateFloats", func(dec *cbor.Decoder) { dec.SetTruncateFloats(true) }, "f93e00", func() interface{} { return new(int) }},
+		{"SetZeroTarget", func(dec *cbor.Decoder) { dec.SetZeroTarget(true) }, "a1614101", func() interface{} { return &record{B: "x"} }},
+		{"SetFloatChecks", func(dec *cbor.Decoder) { dec.SetFloatChecks(cbor.RejectNaNPayloads) }, "f97e01", func() interface{} { return new(float64) }},
+		{"SetUnexportedFields", func(dec *cbor.Decoder) { dec.SetUnexportedFields(cbor.UnexportedFieldError) }, "a1616301", func() interface{} { return new(record) }},
+		{"SetStringKeys", func(dec *cbor.Decoder) { dec.SetStringKeys(true) }, "a10102", func() interface{} { return new(interface{}) }},
+		{"SetTimeLayouts", func(dec *cbor.Decoder) { dec.SetTimeLayouts("2006-01-02") }, "c06a323030362d30312d3032", func() interface{} { return new(time.Time) }},
+		{"SetUnknownTagMode", func(dec *cbor.Decoder) { dec.SetUnknownTagMode(cbor.UnknownTagUnwrap) }, "d9ffff01", func() interface{} { return new(interface{}) }},
+		{"SetNegativeZero", func(dec *cbor.Decoder) { dec.SetNegativeZero(cbor.NegativeZeroNormalize) }, "f98000", func() interface{} { return new(float64) }},
+		{"SetJSONTags", func(dec *cbor.Decoder) { dec.SetJSONTags(true) }, "a16364656501", func() interface{} { return new(record) }},
+		{"SetDurationMode", func(dec *cbor.Decoder) { dec.SetDurationMode(cbor.DurationSeconds) }, "01", func() interface{} { return new(time.Duration) }},
+		{"SetErrorContext", func(dec *cbor.Decoder) { dec.SetErrorContext(0) }, "a1614160", func() interface{} { return new(record) }},
+		{"SetMax", func(dec *cbor.Decoder) { dec.SetMax(1) }, "818101", func() interface{} { return new(interface{}) }},
+		{"SetMaxArrayElements", func(dec *cbor.Decoder) { dec.SetMaxArrayElements(1) }, "820102", func() interface{} { return new([]int) }},
+		{"SetMaxMapPairs", func(dec *cbor.Decoder) { dec.SetMaxMapPairs(1) }, "a2614101614202", func() interface{} { return new(record) }},
+		{"SetMaxStringBytes", func(dec *cbor.Decoder) { dec.SetMaxStringBytes(1) }, "626162", func() interface{} { return new(string) }},
+		{"SetMaxBytes", func(dec *cbor.Decoder) { dec.SetMaxBytes(1) }, "420102", func() interface{} { return new([]byte) }},
+		{"SetMaxDepth", func(dec *cbor.Decoder) { dec.SetMaxDepth(1) }, "818101", func() interface{} { return new(interface{}) }},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.data)
+		outcome := func(decode func(v interface{}) error) string {
+			v := test.v()
+			err := decode(v)
+			return fmt.Sprintf("%#v, %T %v", reflect.ValueOf(v).Elem().Interface(), err, err)
+		}
+		unmarshal := func(v interface{}) error { return cbor.Unmarshal(data, v) }
+
+		want := outcome(unmarshal)
+		dec := cbor.NewDecoder(bytes.NewReader(data))
+		test.set(dec)
+		if got := outcome(dec.Decode); got == want {
+			t.Fatalf("%s: the option made no difference to %s", test.name, test.data)
+		}
+		if got := outcome(unmarshal); got != want {
+			t.Errorf("%s: changed Unmarshal from %s to %s", test.name, want, got)
+		}
+		if got := outcome(cbor.NewDecoder(bytes.NewReader(data)).Decode); got != want {
+			t.Errorf("%s: changed a new decoder from %s to %s", test.name, want, got)
+		}
+	}
+}
+
+func TestDecoder_SetNegativeZero(t *testing.T) {
+	for _, data := range []string{"f98000", "fa80000000", "fb8000000000000000"} {
+		b, _ := hex.DecodeString(data)
+
+		var f float64
+		if err := cbor.Unmarshal(b, &f); err != nil {
+			t.Fatal(err)
+		}
+		if f != 0 || !math.Signbit(f) {
+			t.Fatalf("%s: expected negative zero, got %v", data, f)
+		}
+
+		dec := cbor.NewDecoder(bytes.NewReader(b))
+		dec.SetNegativeZero(cbor.NegativeZeroNormalize)
+		err := dec.Decode(&f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f != 0 || math.Signbit(f) {
+			t.Fatalf("%s: expected positive zero, got %v", data, f)
+		}
+	}
+
+	dec := cbor.NewDecoder(bytes.NewReader([]byte{0xfb, 0x80, 0, 0, 0, 0, 0, 0, 0}))
+	dec.SetNegativeZero(cbor.NegativeZeroNormalize)
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := v.(float64); !ok || math.Signbit(f) {
+		t.Fatalf("expected positive zero, got %v", v)
+	}
+}
+
+func TestDecoder_SetZeroTarget(t *testing.T) {
+	type pair struct{ A, B int }
+	type target struct {
+		M map[string]pair
+		S []pair
+		P *pair
+		N pair
+		R [1]pair
+	}
+	reused := func() target {
+		return target{
+			M: map[string]pair{"x": {1, 1}, "y": {1, 1}},
+			S: []pair{{1, 1}, {1, 1}},
+			P: &pair{1, 1},
+			N: pair{1, 1},
+			R: [1]pair{{1, 1}},
+		}
+	}
+
+	// {"M": {"x": {"A": 2}}, "S": [{"A": 2}], "P": {"A": 2}, "N": {"A": 2}, "R": [{"A": 2}]},
+	// with an indefinite-length array for S the second time.
+	for _, data := range []string{
+		"a5614da16178a1614102" + "615381a1614102" + "6150a1614102614ea16141026152" + "81a1614102",
+		"a5614da16178a1614102" + "61539fa1614102ff" + "6150a1614102614ea16141026152" + "81a1614102",
+	} {
+		b, _ := hex.DecodeString(data)
+
+		v := reused()
+		if err := cbor.Unmarshal(b, &v); err != nil {
+			t.Fatal(err)
+		}
+		want := target{
+			M: map[string]pair{"x": {2, 0}, "y": {1, 1}},
+			S: []pair{{2, 0}},
+			P: &pair{2, 1},
+			N: pair{2, 1},
+			R: [1]pair{{2, 0}},
+		}
+		if !reflect.DeepEqual(v, want) {
+			t.Fatalf("%s: merged into %+v, expected %+v", data, v, want)
+		}
+
+		v = reused()
+		dec := cbor.NewDecoder(bytes.NewReader(b))
+		dec.SetZeroTarget(true)
+		err := dec.Decode(&v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = target{
+			M: map[string]pair{"x": {2, 0}},
+			S: []pair{{2, 0}},
+			P: &pair{2, 0},
+			N: pair{2, 0},
+			R: [1]pair{{2, 0}},
+		}
+		if !reflect.DeepEqual(v, want) {
+			t.Fatalf("%s: decoded %+v, expected %+v", data, v, want)
+		}
+	}
+}
+
+func TestDecoder_SetUnexportedFields(t *testing.T) {
+	type private struct {
+		A     int
+		token string
+	}
+	tests := []struct {
+		data    string
+		wantErr bool
+	}{
+		{"a1614101", false},                // {"A": 1}
+		{"a2614101617802", false},          // {"A": 1, "x": 2}
+		{"a261410165746f6b656e6174", true}, // {"A": 1, "token": "t"}
+		{"a261410165546f6b656e6174", true}, // {"A": 1, "Token": "t"}
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.data)
+		var v private
+		if err := cbor.Unmarshal(data, &v); err != nil || v.A != 1 || v.token != "" {
+			t.Fatalf("%s: decoded %+v, %v", test.data, v, err)
+		}
+
+		dec := cbor.NewDecoder(bytes.NewReader(data))
+		dec.SetUnexportedFields(cbor.UnexportedFieldError)
+		err := dec.Decode(&v)
+		if test.wantErr {
+			var de *cbor.DecodeError
+			if !errors.Is(err, cbor.ErrUnexportedField) || !errors.As(err, &de) || de.Path != "private" {
+				t.Errorf("%s: expected ErrUnexportedField, got %v", test.data, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", test.data, err)
+		}
+	}
+}
+
+func TestDecoder_SetFloatChecks(t *testing.T) {
+	tests := []struct {
+		data    string
+		v       interface{}
+		checks  cbor.FloatChecks
+		wantErr error
+	}{
+		{"f97e00", new(float64), cbor.StrictFloats, nil},
+		{"fa7fc00000", new(interface{}), cbor.StrictFloats, nil},
+		{"fb7ff8000000000000", new(float32), cbor.StrictFloats, nil},
+		{"f97e01", new(float64), cbor.RejectNaNPayloads, cbor.ErrInvalidFloat},
+		{"f9fe00", new(float64), cbor.RejectNaNPayloads, cbor.ErrInvalidFloat},
+		{"fa7fc00001", new(interface{}), cbor.RejectNaNPayloads, cbor.ErrInvalidFloat},
+		{"fb7ff8000000000001", new(float64), cbor.RejectNaNPayloads, cbor.ErrInvalidFloat},
+		{"f97e01", new(float64), cbor.RejectSubnormalFloat16, nil},
+		{"f97c00", new(float64), cbor.StrictFloats, nil}, // infinity
+		{"f90001", new(float64), cbor.RejectSubnormalFloat16, cbor.ErrInvalidFloat},
+		{"f90001", new(interface{}), cbor.RejectSubnormalFloat16, cbor.ErrInvalidFloat},
+		{"f90001", new(float64), cbor.RejectNaNPayloads, nil},
+		{"f90400", new(float64), cbor.StrictFloats, nil}, // smallest normal
+		{"f93c00", new(int), 0, nil},
+		{"f93c00", new(int), cbor.RejectFloatIntegers, cbor.ErrInvalidType},
+		{"fb3ff0000000000000", new(uint8), cbor.RejectFloatIntegers, cbor.ErrInvalidType},
+		{"01", new(float64), cbor.StrictFloats, nil},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.data)
+		dec := cbor.NewDecoder(bytes.NewReader(data))
+		dec.SetFloatChecks(test.checks)
+		err := dec.Decode(test.v)
+		if !errors.Is(err, test.wantErr) || (err != nil) != (test.wantErr != nil) {
+			t.Errorf("%s into %T with checks %b: expected %v, got %v", test.data, test.v, test.checks, test.wantErr, err)
+		}
+	}
+}
+
+// cancelReader reads from r, and calls cancel after the first read.
+type cancelReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	if len(p) > 16 {
+		p = p[:16]
+	}
+	n, err := r.r.Read(p)
+	r.cancel()
+	return n, err
+}
+
+func TestDecoder_DecodeContext(t *testing.T) {
+	items := make([]int, 1000)
+	data, err := cbor.Marshal(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A context which isn't done doesn't change anything.
+	var got []int
+	if err := cbor.NewDecoder(bytes.NewReader(data)).DecodeContext(context.Background(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(items) {
+		t.Fatalf("expected %d items, got %d", len(items), len(got))
+	}
+
+	// A context canceled while reading stops decoding.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dec := cbor.NewDecoder(&cancelReader{r: bytes.NewReader(data), cancel: cancel})
+	err = dec.DecodeContext(ctx, &got)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// An expired context stops decoding before it starts.
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	err = cbor.NewDecoder(bytes.NewReader(data)).DecodeContext(ctx, &got)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestNewDecoderBytes(t *testing.T) {
+	data, _ := hex.DecodeString("01" + "6161" + "820203" + "ff")
+	dec := cbor.NewDecoderBytes(data)
+	dec.EnableStats()
+
+	var (
+		n int
+		s string
+		a []int
+	)
+	for _, v := range []interface{}{&n, &s, &a} {
+		if err := dec.Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != 1 || s != "a" || !reflect.DeepEqual(a, []int{2, 3}) {
+		t.Fatalf("unexpected values %v, %q, %v", n, s, a)
+	}
+	if got := dec.Stats(); got.Items != 5 || got.Bytes != 6 {
+		t.Fatalf("unexpected stats %+v", got)
+	}
+
+	rest, err := io.ReadAll(dec.Buffered())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rest, []byte{0xff}) {
+		t.Fatalf("expected the rest of the data to be buffered, got %x", rest)
+	}
+	if err := dec.Decode(&n); !errors.Is(err, cbor.ErrMalformed) {
+		t.Fatalf("expected a malformed data error, got %v", err)
+	}
+	if err := cbor.NewDecoderBytes(nil).Decode(&n); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestDecodePointers(t *testing.T) {
+	one := []byte{0x01}
+
+	var pp **int
+	if err := cbor.Unmarshal(one, &pp); err != nil || pp == nil || *pp == nil || **pp != 1 {
+		t.Fatalf("expected **int to 1, got %v (err %v)", pp, err)
+	}
+
+	var ppp ***int8
+	if err := cbor.Unmarshal([]byte{0x20}, &ppp); err != nil || ***ppp != -1 {
+		t.Fatalf("expected ***int8 to -1, got %v (err %v)", ppp, err)
+	}
+
+	var pb *[]byte
+	if err := cbor.Unmarshal([]byte{0x42, 0x01, 0x02}, &pb); err != nil || !bytes.Equal(*pb, []byte{1, 2}) {
+		t.Fatalf("expected *[]byte to 0102, got %v (err %v)", pb, err)
+	}
+
+	var ps **string
+	if err := cbor.Unmarshal([]byte{0x61, 'a'}, &ps); err != nil || **ps != "a" {
+		t.Fatalf("expected **string to a, got %v (err %v)", ps, err)
+	}
+
+	var pf **float64
+	if err := cbor.Unmarshal([]byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, &pf); err != nil || **pf != 1.5 {
+		t.Fatalf("expected **float64 to 1.5, got %v (err %v)", pf, err)
+	}
+
+	// Interfaces holding non-nil pointers are decoded through them.
+	x := 0
+	px := &x
+	var v interface{} = &px
+	if err := cbor.Unmarshal(one, &v); err != nil || x != 1 || v != interface{}(&px) {
+		t.Fatalf("expected x to be 1 through the interface, got %d, %v (err %v)", x, v, err)
+	}
+	s := struct{ V fmt.Stringer }{V: &stringer{}}
+	if err := cbor.Unmarshal([]byte{0xa1, 0x61, 'V', 0x61, 'b'}, &s); err != nil || s.V.String() != "b" {
+		t.Fatalf("expected stringer to be b, got %v (err %v)", s.V, err)
+	}
+
+	// Interfaces holding typed nil pointers are replaced.
+	var typedNil *int
+	v = typedNil
+	if err := cbor.Unmarshal(one, &v); err != nil || v != uint64(1) {
+		t.Fatalf("expected uint64(1), got %#v (err %v)", v, err)
+	}
+}
+
+func TestDecodeNilPointers(t *testing.T) {
+	type S struct {
+		P *int
+		Q **string
+	}
+	data, err := cbor.Marshal(S{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s S
+	if err := cbor.Unmarshal(data, &s); err != nil || s.P != nil || s.Q != nil {
+		t.Fatalf("expected nil pointers, got %+v (err %v)", s, err)
+	}
+
+	// Null sets pointers to nil rather than decoding through them.
+	x, y := 1, "a"
+	py := &y
+	s = S{P: &x, Q: &py}
+	if err := cbor.Unmarshal(data, &s); err != nil || s.P != nil || s.Q != nil {
+		t.Fatalf("expected nil pointers, got %+v (err %v)", s, err)
+	}
+	if x != 1 || y != "a" {
+		t.Fatalf("expected values pointed to be unchanged, got %d and %q", x, y)
+	}
+
+	// Null in a nested pointer sets only the innermost pointer to nil.
+	pp := &py
+	if err := cbor.Unmarshal([]byte{0xf6}, pp); err != nil || *pp != nil || y != "a" {
+		t.Fatalf("expected inner pointer to be nil, got %v (err %v)", *pp, err)
+	}
+}
+
+type stringer struct{ S string }
+
+func (s *stringer) String() string { return s.S }
+
+func (s *stringer) UnmarshalCBOR(data []byte) error {
+	return cbor.Unmarshal(data, &s.S)
+}
+
+func TestDecoder_SkipValue(t *testing.T) {
+	// {"a": [1, h'0102'], "b": (_ "xy", "z")}, 1(1), a 10000-byte string,
+	// then 7.
+	data := decodeHex(t, "a26161820142010261627f627879617affc101")
+	data = append(data, cbor.AppendBytes(nil, make([]byte, 10000))...)
+	data = append(data, 0x07)
+
+	for name, r := range map[string]func() io.Reader{
+		"memory": func() io.Reader { return bytes.NewReader(data) },
+		"stream": func() io.Reader { return struct{ io.Reader }{bytes.NewReader(data)} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			dec := cbor.NewDecoder(r())
+			for i := 0; i < 3; i++ {
+				if err := dec.SkipValue(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var v int
+			if err := dec.Decode(&v); err != nil || v != 7 {
+				t.Fatalf("expected 7, got %d (err %v)", v, err)
+			}
+			if err := dec.SkipValue(); err != io.EOF {
+				t.Fatalf("expected io.EOF, got %v", err)
+			}
+		})
+	}
+
+	for _, s := range []string{"8201", "5a00010000", "7f6178", "bf6161ff", "ff", "1c"} {
+		dec := cbor.NewDecoder(bytes.NewReader(decodeHex(t, s)))
+		if err := dec.SkipValue(); err == nil {
+			t.Fatalf("expected error skipping %s", s)
+		}
+	}
+}
+
+func TestDecoder_ReadRaw(t *testing.T) {
+	// Non-preferred encodings, like 1 in two bytes, are returned as they
+	// were read.
+	data := decodeHex(t, "1801a16161820203")
+	for name, r := range map[string]io.Reader{
+		"memory": bytes.NewReader(data),
+		"stream": struct{ io.Reader }{bytes.NewReader(data)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dec := cbor.NewDecoder(r)
+			var got []string
+			for {
+				raw, err := dec.ReadRaw()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, hex.EncodeToString(raw))
+			}
+			if want := []string{"1801", "a16161820203"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+		})
+	}
+
+	dec := cbor.NewDecoder(bytes.NewReader(decodeHex(t, "820102")[:2]))
+	if _, err := dec.ReadRaw(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package cbor
+
+import "reflect"
+
+// DecodeDepth reads the next CBOR-encoded value from its input and returns
+// it decoded as into an empty interface, down to depth levels of nesting.
+// The items nested deeper are returned as RawMessage values, without being
+// decoded, so the structure of a huge document can be shown cheaply, and
+// parts of it decoded later.
+//
+// With a depth of 1, an array is returned as a []interface{} of the raw
+// elements, and a map as a map of its decoded keys to its raw values. Each
+// level more decodes the arrays and maps one level deeper the same way.
+// Map keys are always decoded. Tags whose content is an array or a map are
+// returned as TagValue values with their content at the same level, and
+// other items are decoded as usual. With a depth of 0 or less, the whole
+// value is returned as a RawMessage.
+//
+// The RawMessage values share the memory of a copy of the value which the
+// caller owns.
+func (dec *Decoder) DecodeDepth(depth int) (interface{}, error) {
+	raw, err := dec.ReadRaw()
+	if err != nil {
+		return nil, err
+	}
+	return dec.decodeDepth(raw, depth)
+}
+
+// UnmarshalDepth decodes the CBOR item in data down to depth levels of
+// nesting, like Decoder.DecodeDepth. The RawMessage values in the result
+// are subslices of data.
+func UnmarshalDepth(data []byte, depth int) (interface{}, error) {
+	n, err := itemLength(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	dec := getDecoder(data)
+	v, err := dec.decodeDepth(data[:n:n], depth)
+	putDecoder(dec)
+	return v, err
+}
+
+// decodeDepth decodes the well-formed item down to depth levels of
+// nesting, leaving the items nested deeper as RawMessage values.
+func (dec *Decoder) decodeDepth(item []byte, depth int) (interface{}, error) {
+	if depth <= 0 {
+		return RawMessage(item), nil
+	}
+
+	switch mt, _, arg, n, _ := parseHeader(item); {
+	case mt == MajorTypeTag && isContainer(skipTags(item[n:])):
+		content, err := dec.decodeDepth(item[n:], depth)
+		if err != nil {
+			return nil, err
+		}
+		return TagValue{Number: arg, Content: content}, nil
+	case mt == MajorTypeArray:
+		// The item is in memory, so its length bounds the number of
+		// elements.
+		elems := make([]interface{}, 0, int(arg))
+		var err error
+		Value{raw: item}.each(func(elem []byte) bool {
+			var v interface{}
+			v, err = dec.decodeDepth(elem, depth-1)
+			elems = append(elems, v)
+			return err == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return elems, nil
+	case mt == MajorTypeMap:
+		return dec.decodeMapDepth(item, depth)
+	}
+	return dec.decodeRawItem(item)
+}
+
+// decodeMapDepth decodes the keys of the map item, and its values down to
+// depth-1 levels of nesting.
+func (dec *Decoder) decodeMapDepth(item []byte, depth int) (interface{}, error) {
+	var (
+		m   map[interface{}]interface{}
+		sm  map[string]interface{}
+		key interface{}
+		err error
+	)
+	if dec.options.StringKeys {
+		sm = make(map[string]interface{})
+	} else {
+		m = make(map[interface{}]interface{})
+	}
+	isKey := true
+	Value{raw: item}.each(func(elem []byte) bool {
+		if isKey {
+			if key, err = dec.decodeRawItem(elem); err != nil {
+				return false
+			}
+			if sm != nil {
+				key, err = stringKey(key)
+			} else {
+				key, err = hashableKey(key)
+			}
+			isKey = false
+			return err == nil
+		}
+
+		var v interface{}
+		if v, err = dec.decodeDepth(elem, depth-1); err != nil {
+			return false
+		}
+		if sm != nil {
+			sm[key.(string)] = v
+		} else {
+			m[key] = v
+		}
+		isKey = true
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sm != nil {
+		return sm, nil
+	}
+	return m, nil
+}
+
+// decodeRawItem decodes the well-formed item into an empty interface, with
+// the options of the decoder.
+func (dec *Decoder) decodeRawItem(item []byte) (interface{}, error) {
+	sub := getDecoder(item)
+	sub.options = dec.options
+	var v interface{}
+	err := sub.decodeValue(reflect.ValueOf(&v).Elem())
+	putDecoder(sub)
+	return v, err
+}
+
+// isContainer reports whether the item at the start of b is an array or a
+// map.
+func isContainer(b []byte) bool {
+	mt := MajorType(b[0] >> 5)
+	return mt == MajorTypeArray || mt == MajorTypeMap
+}
@@ -0,0 +1,74 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestUnmarshalDepth(t *testing.T) {
+	// {"a": [1, [2, 3]], "b": {"c": 4}, "t": 55799([5])}
+	data, _ := hex.DecodeString("a3616182018202036162a16163046174d9d9f78105")
+	raw := func(s string) cbor.RawMessage {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+
+	tests := []struct {
+		depth int
+		want  interface{}
+	}{
+		{0, raw("a3616182018202036162a16163046174d9d9f78105")},
+		{1, map[interface{}]interface{}{
+			"a": raw("8201820203"),
+			"b": raw("a1616304"),
+			"t": raw("d9d9f78105"),
+		}},
+		{2, map[interface{}]interface{}{
+			"a": []interface{}{raw("01"), raw("820203")},
+			"b": map[interface{}]interface{}{"c": raw("04")},
+			"t": cbor.TagValue{Number: 55799, Content: []interface{}{raw("05")}},
+		}},
+		{3, map[interface{}]interface{}{
+			"a": []interface{}{uint64(1), []interface{}{raw("02"), raw("03")}},
+			"b": map[interface{}]interface{}{"c": uint64(4)},
+			"t": cbor.TagValue{Number: 55799, Content: []interface{}{uint64(5)}},
+		}},
+	}
+
+	for _, test := range tests {
+		v, err := cbor.UnmarshalDepth(data, test.depth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v, test.want) {
+			t.Errorf("depth %d: expected %#v, got %#v", test.depth, test.want, v)
+		}
+	}
+}
+
+func TestDecoder_DecodeDepth(t *testing.T) {
+	// [[1]], {1: [2]}
+	data, _ := hex.DecodeString("818101a1018102")
+	dec := cbor.NewDecoder(bytes.NewReader(data))
+
+	v, err := dec.DecodeDepth(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{cbor.RawMessage{0x81, 0x01}}; !reflect.DeepEqual(v, want) {
+		t.Fatalf("expected %#v, got %#v", want, v)
+	}
+
+	dec.SetStringKeys(true)
+	v, err = dec.DecodeDepth(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"1": cbor.RawMessage{0x81, 0x02}}; !reflect.DeepEqual(v, want) {
+		t.Fatalf("expected %#v, got %#v", want, v)
+	}
+}
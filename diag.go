@@ -0,0 +1,141 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Diagnose returns the diagnostic notation of the CBOR items in data, as
+// defined by RFC 8949, section 8, for showing encoded data to people. For
+// example, the encoding of {"a": [1, -2, h'ff']} is shown as just that.
+//
+// Indefinite-length items are marked with an underscore, as in [_ 1, 2],
+// and tags are shown as their number followed by their content in
+// parentheses, as in 1(1363896240). If data is a CBOR sequence, the
+// items are separated by commas, as in RFC 8742, section 4.2.
+//
+// If data isn't well-formed, an error is returned for the first malformed
+// item.
+func Diagnose(data []byte) (string, error) {
+	var dst []byte
+	for rest := data; len(rest) > 0; {
+		if _, err := itemLength(rest, 0); err != nil {
+			return "", err
+		}
+		if len(dst) > 0 {
+			dst = append(dst, ", "...)
+		}
+		dst, rest = appendDiag(dst, rest)
+	}
+	return string(dst), nil
+}
+
+// appendDiag appends the diagnostic notation of the well-formed CBOR
+// item at the start of data to dst, returning the rest of data.
+func appendDiag(dst, data []byte) ([]byte, []byte) {
+	mt, ai, arg, n, _ := parseHeader(data)
+
+	switch mt {
+	case MajorTypeUnsignedInt:
+		return strconv.AppendUint(dst, arg, 10), data[n:]
+	case MajorTypeNegativeInt:
+		return appendNegative(dst, arg), data[n:]
+	case MajorTypeByteString, MajorTypeTextString:
+		if ai == 31 {
+			dst = append(dst, "(_ "...)
+			data = data[n:]
+			for i := 0; data[0] != 0xff; i++ {
+				if i > 0 {
+					dst = append(dst, ", "...)
+				}
+				dst, data = appendDiag(dst, data)
+			}
+			return append(dst, ')'), data[1:]
+		}
+		s := data[n : n+int(arg)]
+		if mt == MajorTypeByteString {
+			dst = append(dst, "h'"...)
+			dst = append(dst, hex.EncodeToString(s)...)
+			return append(dst, '\''), data[n+int(arg):]
+		}
+		return appendJSONString(dst, s), data[n+int(arg):]
+	case MajorTypeArray, MajorTypeMap:
+		open, close := byte('['), byte(']')
+		if mt == MajorTypeMap {
+			open, close = '{', '}'
+		}
+		dst = append(dst, open)
+		if ai == 31 {
+			dst = append(dst, "_ "...)
+		}
+		data = data[n:]
+		for i := uint64(0); ai == 31 || i < arg; i++ {
+			if ai == 31 && data[0] == 0xff {
+				data = data[1:]
+				break
+			}
+			if i > 0 {
+				dst = append(dst, ", "...)
+			}
+			if mt == MajorTypeMap {
+				dst, data = appendDiag(dst, data)
+				dst = append(dst, ": "...)
+			}
+			dst, data = appendDiag(dst, data)
+		}
+		return append(dst, close), data
+	case MajorTypeTag:
+		dst = strconv.AppendUint(dst, arg, 10)
+		dst = append(dst, '(')
+		dst, data = appendDiag(dst, data[n:])
+		return append(dst, ')'), data
+	}
+
+	switch {
+	case ai == 20:
+		return append(dst, "false"...), data[n:]
+	case ai == 21:
+		return append(dst, "true"...), data[n:]
+	case ai == 22:
+		return append(dst, "null"...), data[n:]
+	case ai == 23:
+		return append(dst, "undefined"...), data[n:]
+	case ai >= 25 && ai <= 27:
+		f, rest, _ := ReadFloat64(data)
+		return appendDiagFloat(dst, f), rest
+	}
+	dst = append(dst, "simple("...)
+	dst = strconv.AppendUint(dst, arg, 10)
+	return append(dst, ')'), data[n:]
+}
+
+// appendDiagFloat appends the diagnostic notation of a float, which always
+// has a fraction, to tell it apart from an integer. As in JavaScript, an
+// exponent is only used for very large or small numbers, like 1.0e+300.
+func appendDiagFloat(dst []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(dst, "NaN"...)
+	case math.IsInf(f, 1):
+		return append(dst, "Infinity"...)
+	case math.IsInf(f, -1):
+		return append(dst, "-Infinity"...)
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f, format, -1, 64)
+	mantissa, exp, _ := strings.Cut(s, "e")
+	dst = append(dst, mantissa...)
+	if !strings.Contains(mantissa, ".") {
+		dst = append(dst, ".0"...)
+	}
+	if exp != "" {
+		dst = append(append(dst, 'e'), exp...)
+	}
+	return dst
+}
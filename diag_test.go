@@ -0,0 +1,57 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/picatz/cbor"
+	"github.com/picatz/cbor/testvectors"
+)
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want string
+	}{
+		{"a26161820121" + "6162" + "43010203", `{"a": [1, -2], "b": h'010203'}`},
+		{"9f018202039f0405ffff", "[_ 1, [2, 3], [_ 4, 5]]"},
+		{"bf6346756ef563416d7421ff", `{_ "Fun": true, "Amt": -2}`},
+		{"7f657374726561646d696e67ff", `(_ "strea", "ming")`},
+		{"3bffffffffffffffff", "-18446744073709551616"},
+		{"f93c00" + "fb3ff8000000000000" + "fb4415af1d78b58c40" + "fb7e37e43c8800759c", "1.0, 1.5, 100000000000000000000.0, 1.0e+300"},
+		{"f4f5f6f7f0", "false, true, null, undefined, simple(16)"},
+		{"d9d9f7a0", "55799({})"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.hex)
+		got, err := cbor.Diagnose(data)
+		if err != nil {
+			t.Errorf("%s: %v", test.hex, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: expected %s, got %s", test.hex, test.want, got)
+		}
+	}
+
+	for _, v := range testvectors.AppendixA() {
+		if v.Diagnostic == "" {
+			continue
+		}
+		if got, err := cbor.Diagnose(v.CBOR); err != nil || got != v.Diagnostic {
+			t.Errorf("%s: expected %s, got %s (err %v)", v.Hex, v.Diagnostic, got, err)
+		}
+	}
+
+	for _, s := range []string{"ff", "8201", "1c"} {
+		data, _ := hex.DecodeString(s)
+		if _, err := cbor.Diagnose(data); err == nil {
+			t.Errorf("%s: expected error", s)
+		} else if !errors.Is(err, cbor.ErrMalformed) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: unexpected error %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// PatchOp is the kind of a PatchOperation.
+type PatchOp string
+
+// Patch operations, with the same semantics as JSON Patch (RFC 6902).
+const (
+	// PatchAdd adds a value to a map, replacing any existing value for the
+	// key, or inserts a value into an array at the given index. An index
+	// equal to the length of the array appends to it.
+	PatchAdd PatchOp = "add"
+
+	// PatchRemove removes a key from a map or an element from an array.
+	PatchRemove PatchOp = "remove"
+
+	// PatchReplace replaces the value of an existing map key or array
+	// element.
+	PatchReplace PatchOp = "replace"
+)
+
+// PatchOperation is a single change to a CBOR document.
+type PatchOperation struct {
+	// Op is the kind of operation.
+	Op PatchOp
+
+	// Path is the location of the change, using the same elements as
+	// GetPath. An empty path refers to the whole document.
+	Path []interface{}
+
+	// Value is the new item for add and replace operations.
+	Value Value
+}
+
+// Patch is a list of operations which are applied in order, like a JSON
+// Patch (RFC 6902) over CBOR.
+//
+// A Patch is encoded as an array of maps with "op", "path", and "value"
+// keys, so it can itself be sent as CBOR.
+type Patch []PatchOperation
+
+// Diff returns a Patch which transforms the CBOR item a into b.
+//
+// Maps are compared key by key and arrays element by element, so only the
+// changed parts of a document are included in the patch. Other items, and
+// tagged items with different tags, are replaced whole if they are not
+// Equal.
+func Diff(a, b []byte) (Patch, error) {
+	va, err := NewValue(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := NewValue(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Patch
+	diffItems(&p, nil, va.raw, vb.raw)
+	return p, nil
+}
+
+// diffItems appends the operations transforming the item a into b to p.
+func diffItems(p *Patch, path []interface{}, a, b []byte) {
+	ta, ca := splitTags(a)
+	tb, cb := splitTags(b)
+
+	replace := func() {
+		if !Equal(a, b) {
+			*p = append(*p, PatchOperation{Op: PatchReplace, Path: path, Value: Value{raw: b}})
+		}
+	}
+
+	if !bytes.Equal(ta, tb) {
+		replace()
+		return
+	}
+
+	va, vb := Value{raw: ca}, Value{raw: cb}
+	switch {
+	case va.Type() == MajorTypeMap && vb.Type() == MajorTypeMap:
+		ka, xa := mapItems(va)
+		kb, xb := mapItems(vb)
+
+		matched := make([]bool, len(kb))
+		for i, key := range ka {
+			elem := pathElem(key)
+			j := indexItem(kb, key)
+			if j < 0 {
+				*p = append(*p, PatchOperation{Op: PatchRemove, Path: appendPath(path, elem)})
+				continue
+			}
+			matched[j] = true
+			diffItems(p, appendPath(path, elem), xa[i], xb[j])
+		}
+		for j, key := range kb {
+			if !matched[j] {
+				*p = append(*p, PatchOperation{Op: PatchAdd, Path: appendPath(path, pathElem(key)), Value: Value{raw: xb[j]}})
+			}
+		}
+	case va.Type() == MajorTypeArray && vb.Type() == MajorTypeArray:
+		var xa, xb [][]byte
+		va.each(func(item []byte) bool {
+			xa = append(xa, item)
+			return true
+		})
+		vb.each(func(item []byte) bool {
+			xb = append(xb, item)
+			return true
+		})
+
+		for i := 0; i < len(xa) && i < len(xb); i++ {
+			diffItems(p, appendPath(path, i), xa[i], xb[i])
+		}
+		// Remove from the end, so the indices of earlier elements are
+		// unchanged.
+		for i := len(xa) - 1; i >= len(xb); i-- {
+			*p = append(*p, PatchOperation{Op: PatchRemove, Path: appendPath(path, i)})
+		}
+		for i := len(xa); i < len(xb); i++ {
+			*p = append(*p, PatchOperation{Op: PatchAdd, Path: appendPath(path, i), Value: Value{raw: xb[i]}})
+		}
+	default:
+		replace()
+	}
+}
+
+// appendPath returns a copy of path with elem appended, so paths of
+// different operations don't share memory.
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	p := make([]interface{}, len(path)+1)
+	copy(p, path)
+	p[len(path)] = elem
+	return p
+}
+
+// splitTags splits an item into the headers of its tags and their content.
+func splitTags(item []byte) (tags, content []byte) {
+	content = skipTags(item)
+	return item[:len(item)-len(content)], content
+}
+
+// mapItems returns the encoded keys and values of a map.
+func mapItems(v Value) (keys, values [][]byte) {
+	isKey := true
+	v.each(func(item []byte) bool {
+		if isKey {
+			keys = append(keys, item)
+		} else {
+			values = append(values, item)
+		}
+		isKey = !isKey
+		return true
+	})
+	return keys, values
+}
+
+// indexItem returns the index of the item in items which is Equal to
+// item, or -1.
+func indexItem(items [][]byte, item []byte) int {
+	for i, x := range items {
+		if Equal(x, item) {
+			return i
+		}
+	}
+	return -1
+}
+
+// pathElem returns the path element selecting the encoded map key: a
+// string for text strings, an int64 for integers that fit, and a Value
+// for anything else.
+func pathElem(key []byte) interface{} {
+	switch MajorType(key[0] >> 5) {
+	case MajorTypeTextString:
+		if s, _, err := ReadString(key); err == nil {
+			return s
+		}
+	case MajorTypeUnsignedInt, MajorTypeNegativeInt:
+		if n, _, err := ReadInt(key); err == nil {
+			return n
+		}
+	}
+	return Value{raw: key}
+}
+
+// Apply returns a copy of the CBOR item in data with the operations of the
+// patch applied in order.
+//
+// Only the containers on the paths of the operations are re-encoded, using
+// definite lengths. Everything else is copied as is.
+func (p Patch) Apply(data []byte) ([]byte, error) {
+	v, err := NewValue(data)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &patchNode{raw: v.raw}
+	for i, op := range p {
+		if err := root.apply(op); err != nil {
+			return nil, fmt.Errorf("cbor: patch operation %d (%s %v): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return root.appendTo(nil), nil
+}
+
+// patchNode is an item being patched. Containers are only expanded into
+// their children when an operation needs to change them.
+type patchNode struct {
+	// raw is the encoding of an unexpanded item.
+	raw []byte
+
+	// tags are the headers of the tags of an expanded container.
+	tags []byte
+
+	// mt is the major type of an expanded container.
+	mt MajorType
+
+	// keys are the encoded keys of an expanded map.
+	keys [][]byte
+
+	// elems are the array elements or map values of an expanded container.
+	elems []*patchNode
+}
+
+// expand splits a container item into its children. It reports false if
+// the item isn't an array or map.
+func (n *patchNode) expand() bool {
+	if n.raw == nil {
+		return true
+	}
+
+	tags, content := splitTags(n.raw)
+	v := Value{raw: content}
+	switch v.Type() {
+	case MajorTypeArray:
+		v.each(func(item []byte) bool {
+			n.elems = append(n.elems, &patchNode{raw: item})
+			return true
+		})
+	case MajorTypeMap:
+		keys, values := mapItems(v)
+		n.keys = keys
+		for _, item := range values {
+			n.elems = append(n.elems, &patchNode{raw: item})
+		}
+	default:
+		return false
+	}
+
+	n.tags, n.mt, n.raw = tags, v.Type(), nil
+	return true
+}
+
+// child returns the index of the child selected by the path element elem.
+func (n *patchNode) child(elem interface{}) (int, error) {
+	if !n.expand() {
+		return 0, ErrPathNotFound
+	}
+
+	index, isIndex, err := pathIndex(elem)
+	if err != nil {
+		return 0, err
+	}
+
+	if n.mt == MajorTypeArray {
+		if !isIndex || index < 0 || index > int64(len(n.elems)) {
+			return 0, ErrPathNotFound
+		}
+		return int(index), nil
+	}
+
+	for i, key := range n.keys {
+		if keyMatches(key, elem) {
+			return i, nil
+		}
+	}
+	return len(n.keys), nil
+}
+
+// apply applies a single operation to the item.
+func (n *patchNode) apply(op PatchOperation) error {
+	switch op.Op {
+	case PatchAdd, PatchReplace:
+		if len(op.Value.raw) == 0 {
+			return errors.New("missing value")
+		}
+	case PatchRemove:
+	default:
+		return fmt.Errorf("invalid operation %q", op.Op)
+	}
+
+	if len(op.Path) == 0 {
+		if op.Op == PatchRemove {
+			return errors.New("cannot remove the whole document")
+		}
+		*n = patchNode{raw: op.Value.raw}
+		return nil
+	}
+
+	// Find the parent of the changed item.
+	parent := n
+	for _, elem := range op.Path[:len(op.Path)-1] {
+		i, err := parent.child(elem)
+		if err != nil {
+			return err
+		}
+		if i >= len(parent.elems) {
+			return ErrPathNotFound
+		}
+		parent = parent.elems[i]
+	}
+
+	last := op.Path[len(op.Path)-1]
+	i, err := parent.child(last)
+	if err != nil {
+		return err
+	}
+	exists := i < len(parent.elems)
+	item := &patchNode{raw: op.Value.raw}
+
+	switch {
+	case op.Op == PatchAdd && parent.mt == MajorTypeArray:
+		parent.elems = append(parent.elems, nil)
+		copy(parent.elems[i+1:], parent.elems[i:])
+		parent.elems[i] = item
+	case op.Op == PatchAdd && !exists:
+		parent.keys = append(parent.keys, encodePathKey(last))
+		parent.elems = append(parent.elems, item)
+	case !exists:
+		return ErrPathNotFound
+	case op.Op == PatchRemove:
+		parent.elems = append(parent.elems[:i], parent.elems[i+1:]...)
+		if parent.mt == MajorTypeMap {
+			parent.keys = append(parent.keys[:i], parent.keys[i+1:]...)
+		}
+	default:
+		parent.elems[i] = item
+	}
+	return nil
+}
+
+// appendTo appends the encoding of the item to dst.
+func (n *patchNode) appendTo(dst []byte) []byte {
+	if n.raw != nil {
+		return append(dst, n.raw...)
+	}
+
+	dst = append(dst, n.tags...)
+	if n.mt == MajorTypeArray {
+		dst = AppendArrayHeader(dst, len(n.elems))
+	} else {
+		dst = AppendMapHeader(dst, len(n.elems))
+	}
+	for i, elem := range n.elems {
+		if n.mt == MajorTypeMap {
+			dst = append(dst, n.keys[i]...)
+		}
+		dst = elem.appendTo(dst)
+	}
+	return dst
+}
+
+// encodePathKey returns the encoding of a path element used as a map key.
+func encodePathKey(elem interface{}) []byte {
+	switch elem := elem.(type) {
+	case string:
+		return AppendString(nil, elem)
+	case Value:
+		return elem.raw
+	case uint64:
+		return AppendUint(nil, elem)
+	default:
+		n, _, _ := pathIndex(elem)
+		return AppendInt(nil, n)
+	}
+}
+
+// MarshalCBOR implements Marshaler.
+func (p Patch) MarshalCBOR() ([]byte, error) {
+	b := AppendArrayHeader(nil, len(p))
+	for _, op := range p {
+		n := 2
+		if op.Op != PatchRemove {
+			n = 3
+		}
+		b = AppendMapHeader(b, n)
+		b = AppendString(b, "op")
+		b = AppendString(b, string(op.Op))
+		b = AppendString(b, "path")
+		b = AppendArrayHeader(b, len(op.Path))
+		for _, elem := range op.Path {
+			if _, _, err := pathIndex(elem); err != nil {
+				return nil, err
+			}
+			b = append(b, encodePathKey(elem)...)
+		}
+		if n == 3 {
+			if len(op.Value.raw) == 0 {
+				return nil, fmt.Errorf("cbor: %s operation without a value", op.Op)
+			}
+			b = AppendString(b, "value")
+			b = append(b, op.Value.raw...)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalCBOR implements Unmarshaler.
+func (p *Patch) UnmarshalCBOR(data []byte) error {
+	v, err := NewValue(data)
+	if err != nil {
+		return err
+	}
+	if v.Type() != MajorTypeArray {
+		return fmt.Errorf("cbor: cannot unmarshal %s into Patch", v.Type())
+	}
+
+	var patch Patch
+	v.each(func(item []byte) bool {
+		var op PatchOperation
+		op, err = decodePatchOperation(item)
+		patch = append(patch, op)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	*p = patch
+	return nil
+}
+
+// decodePatchOperation decodes an encoded PatchOperation map.
+func decodePatchOperation(item []byte) (PatchOperation, error) {
+	var op PatchOperation
+
+	v := Value{raw: item}
+	if v.Type() != MajorTypeMap {
+		return op, fmt.Errorf("cbor: cannot unmarshal %s into PatchOperation", v.Type())
+	}
+
+	kind, ok := v.Get("op")
+	if !ok {
+		return op, errors.New("cbor: patch operation without op")
+	}
+	s, _, err := ReadString(kind.raw)
+	if err != nil {
+		return op, err
+	}
+	op.Op = PatchOp(s)
+
+	path, ok := v.Get("path")
+	if !ok || path.Type() != MajorTypeArray {
+		return op, errors.New("cbor: patch operation without path")
+	}
+	op.Path = []interface{}{}
+	path.each(func(elem []byte) bool {
+		op.Path = append(op.Path, pathElem(append([]byte{}, elem...)))
+		return true
+	})
+
+	if value, ok := v.Get("value"); ok {
+		op.Value = Value{raw: append([]byte{}, value.raw...)}
+	}
+	return op, nil
+}
@@ -0,0 +1,151 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestDiffApply(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		ops  int
+	}{
+		{
+			name: "equal",
+			a:    "a26161016162820102",
+			b:    "a26161016162820102",
+			ops:  0,
+		},
+		{
+			// {"a": 1, "b": [1, 2], "c": "x"} to {"a": 2, "b": [1], "d": true}
+			name: "map",
+			a:    "a3616101616282010261636178",
+			b:    "a3616102616281016164f5",
+			ops:  4,
+		},
+		{
+			// [1, {1: h'00'}] to [1, {1: h'01', -1: null}, 3]
+			name: "array",
+			a:    "8201a1014100",
+			b:    "8301a201410120f603",
+			ops:  3,
+		},
+		{
+			// 1(0) to 1(1), then 1(0) to 2(0)
+			name: "tags",
+			a:    "c100",
+			b:    "c101",
+			ops:  1,
+		},
+		{
+			name: "different tags",
+			a:    "c1a0",
+			b:    "c2a0",
+			ops:  1,
+		},
+		{
+			name: "different types",
+			a:    "a0",
+			b:    "80",
+			ops:  1,
+		},
+		{
+			// {h'01': 1, [1]: 2} to {h'01': 3}
+			name: "non-string keys",
+			a:    "a2410101810102",
+			b:    "a1410103",
+			ops:  2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := hex.DecodeString(test.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := hex.DecodeString(test.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			patch, err := cbor.Diff(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(patch) != test.ops {
+				t.Fatalf("got %d operations, want %d: %v", len(patch), test.ops, patch)
+			}
+
+			// The patch must survive a round trip through CBOR.
+			enc, err := cbor.Marshal(patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded cbor.Patch
+			if err := cbor.Unmarshal(enc, &decoded); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := decoded.Apply(a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(got) != test.b {
+				t.Fatalf("got %x, want %s", got, test.b)
+			}
+		})
+	}
+}
+
+func TestPatchApply(t *testing.T) {
+	value := func(s string) cbor.Value {
+		t.Helper()
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := cbor.NewValue(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	// {"list": [1, 2]}
+	doc := value("a1646c697374820102").Raw()
+
+	patch := cbor.Patch{
+		{Op: cbor.PatchAdd, Path: []interface{}{"list", 0}, Value: value("00")},
+		{Op: cbor.PatchAdd, Path: []interface{}{"list", 3}, Value: value("03")},
+		{Op: cbor.PatchRemove, Path: []interface{}{"list", 1}},
+		{Op: cbor.PatchAdd, Path: []interface{}{7}, Value: value("f4")},
+		{Op: cbor.PatchReplace, Path: []interface{}{7}, Value: value("f5")},
+	}
+
+	got, err := patch.Apply(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// {"list": [0, 2, 3], 7: true}
+	if want := "a2646c6973748300020307f5"; hex.EncodeToString(got) != want {
+		t.Fatalf("got %x, want %s", got, want)
+	}
+
+	for _, bad := range []cbor.Patch{
+		{{Op: cbor.PatchRemove, Path: []interface{}{"missing"}}},
+		{{Op: cbor.PatchReplace, Path: []interface{}{"missing"}, Value: value("00")}},
+		{{Op: cbor.PatchAdd, Path: []interface{}{"list", 5}, Value: value("00")}},
+		{{Op: cbor.PatchAdd, Path: []interface{}{"list", 0, 0}, Value: value("00")}},
+		{{Op: cbor.PatchAdd, Path: []interface{}{"list"}}},
+		{{Op: cbor.PatchRemove}},
+		{{Op: "move", Path: []interface{}{"list"}}},
+	} {
+		if _, err := bad.Apply(doc); err == nil {
+			t.Fatalf("expected error applying %v", bad)
+		}
+	}
+}
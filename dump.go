@@ -0,0 +1,290 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// dumpChunkSize is the maximum number of bytes of string content shown on
+// each line of a Dump.
+const dumpChunkSize = 16
+
+// Dump returns an annotated breakdown of the CBOR items in data, similar
+// to the pretty view of cbor.me, for debugging encoded data.
+//
+// Each line shows the offset of the bytes it describes, the bytes in hex,
+// indented by nesting depth, and a comment explaining them. As on cbor.me,
+// negative integers are annotated with their argument, so -10 (0x29) is
+// shown as negative(9). For example, the encoding of {"a": [1, -2]} is
+// dumped as:
+//
+//	0000  a1         # map(1)
+//	0001     61      #   text(1)
+//	0002        61   #     "a"
+//	0003     82      #   array(2)
+//	0004        01   #     unsigned(1)
+//	0005        21   #     negative(1)
+//
+// If data is malformed, the items up to the error are dumped, followed by a
+// line describing the error.
+func Dump(data []byte) string {
+	d := &dumper{data: data}
+	for off := 0; off < len(data); {
+		n, err := d.item(off, 0)
+		if err != nil {
+			d.line(off+n, 0, nil, "error: "+strings.TrimPrefix(err.Error(), "cbor: "))
+			break
+		}
+		off += n
+	}
+	return d.String()
+}
+
+// dumpLine is a line of a Dump.
+type dumpLine struct {
+	offset  int
+	depth   int
+	hex     string
+	comment string
+}
+
+// dumper builds the lines of a Dump.
+type dumper struct {
+	data  []byte
+	lines []dumpLine
+}
+
+// line adds a line describing the given bytes, found at off.
+func (d *dumper) line(off, depth int, b []byte, comment string) {
+	d.lines = append(d.lines, dumpLine{
+		offset:  off,
+		depth:   depth,
+		hex:     hex.EncodeToString(b),
+		comment: comment,
+	})
+}
+
+// header adds a line for the header at off, with the initial byte
+// separated from the argument.
+func (d *dumper) header(off, n, depth int, comment string) {
+	h := d.data[off : off+n]
+	s := hex.EncodeToString(h[:1])
+	if n > 1 {
+		s += " " + hex.EncodeToString(h[1:])
+	}
+	d.lines = append(d.lines, dumpLine{offset: off, depth: depth, hex: s, comment: comment})
+}
+
+// item adds the lines for the item at off, returning its length. On error,
+// the returned length is the offset of the error relative to off.
+func (d *dumper) item(off, depth int) (int, error) {
+	if depth > maxNestingDepth {
+		return 0, ErrMaxDepth
+	}
+
+	mt, ai, arg, n, err := parseHeader(d.data[off:])
+	if err != nil {
+		return 0, err
+	}
+
+	if d.data[off] == 0xff {
+		return 0, newError(ErrMalformed, "cbor: unexpected break")
+	}
+	if ai == 31 {
+		switch mt {
+		case MajorTypeUnsignedInt, MajorTypeNegativeInt, MajorTypeTag:
+			return 0, newError(ErrMalformed, fmt.Sprintf("cbor: invalid indefinite-length %s", mt))
+		}
+	}
+
+	switch mt {
+	case MajorTypeUnsignedInt:
+		d.header(off, n, depth, fmt.Sprintf("unsigned(%d)", arg))
+		return n, nil
+	case MajorTypeNegativeInt:
+		d.header(off, n, depth, fmt.Sprintf("negative(%d)", arg))
+		return n, nil
+	case MajorTypeByteString, MajorTypeTextString:
+		name := "bytes"
+		if mt == MajorTypeTextString {
+			name = "text"
+		}
+		if ai != 31 {
+			d.header(off, n, depth, fmt.Sprintf("%s(%d)", name, arg))
+			if arg > uint64(len(d.data)-off-n) {
+				return n, io.ErrUnexpectedEOF
+			}
+			d.content(off+n, depth+1, mt, d.data[off+n:off+n+int(arg)])
+			return n + int(arg), nil
+		}
+
+		d.header(off, n, depth, name+"(*)")
+		for l := n; ; {
+			if off+l >= len(d.data) {
+				return l, io.ErrUnexpectedEOF
+			}
+			if d.data[off+l] == 0xff {
+				d.line(off+l, depth+1, d.data[off+l:off+l+1], "break")
+				return l + 1, nil
+			}
+			if cmt, cai := MajorType(d.data[off+l]>>5), d.data[off+l]&0x1f; cmt != mt || cai == 31 {
+				return l, newError(ErrMalformed, fmt.Sprintf("cbor: invalid chunk in indefinite-length %s", mt))
+			}
+			cn, err := d.item(off+l, depth+1)
+			if err != nil {
+				return l + cn, err
+			}
+			l += cn
+		}
+	case MajorTypeArray, MajorTypeMap:
+		name := "array"
+		if mt == MajorTypeMap {
+			name = "map"
+		}
+		if ai == 31 {
+			d.header(off, n, depth, name+"(*)")
+			items := 0
+			for l := n; ; items++ {
+				if off+l >= len(d.data) {
+					return l, io.ErrUnexpectedEOF
+				}
+				if d.data[off+l] == 0xff {
+					if mt == MajorTypeMap && items%2 != 0 {
+						return l, newError(ErrMalformed, "cbor: indefinite-length map has a key without a value")
+					}
+					d.line(off+l, depth+1, d.data[off+l:off+l+1], "break")
+					return l + 1, nil
+				}
+				cn, err := d.item(off+l, depth+1)
+				if err != nil {
+					return l + cn, err
+				}
+				l += cn
+			}
+		}
+
+		d.header(off, n, depth, fmt.Sprintf("%s(%d)", name, arg))
+		count := arg
+		if mt == MajorTypeMap {
+			count *= 2
+		}
+		l := n
+		for i := uint64(0); i < count; i++ {
+			if off+l >= len(d.data) {
+				return l, io.ErrUnexpectedEOF
+			}
+			cn, err := d.item(off+l, depth+1)
+			if err != nil {
+				return l + cn, err
+			}
+			l += cn
+		}
+		return l, nil
+	case MajorTypeTag:
+		d.header(off, n, depth, fmt.Sprintf("tag(%d)", arg))
+		if off+n >= len(d.data) {
+			return n, io.ErrUnexpectedEOF
+		}
+		cn, err := d.item(off+n, depth+1)
+		return n + cn, err
+	default: // MajorTypeSimple
+		var comment string
+		switch {
+		case ai == 20:
+			comment = "false"
+		case ai == 21:
+			comment = "true"
+		case ai == 22:
+			comment = "null"
+		case ai == 23:
+			comment = "undefined"
+		case ai < 24:
+			comment = fmt.Sprintf("simple(%d)", ai)
+		case ai == 24:
+			comment = fmt.Sprintf("simple(%d)", arg)
+		case ai == 25:
+			comment = "float16(" + formatDumpFloat(float16ToFloat64(uint16(arg))) + ")"
+		case ai == 26:
+			comment = "float32(" + formatDumpFloat(float64(math.Float32frombits(uint32(arg)))) + ")"
+		default:
+			comment = "float64(" + formatDumpFloat(math.Float64frombits(arg)) + ")"
+		}
+		d.header(off, n, depth, comment)
+		return n, nil
+	}
+}
+
+// content adds the lines for the content of a definite-length string at
+// off, split into chunks of up to dumpChunkSize bytes. The chunks of text
+// strings are split on rune boundaries and annotated with their quoted
+// text.
+func (d *dumper) content(off, depth int, mt MajorType, s []byte) {
+	for len(s) > 0 {
+		n := len(s)
+		if n > dumpChunkSize {
+			n = dumpChunkSize
+			if mt == MajorTypeTextString {
+				for n > 0 && !utf8.RuneStart(s[n]) {
+					n--
+				}
+				if n == 0 {
+					n = dumpChunkSize
+				}
+			}
+		}
+
+		var comment string
+		if mt == MajorTypeTextString {
+			comment = strconv.Quote(string(s[:n]))
+		}
+		d.line(off, depth, s[:n], comment)
+		off += n
+		s = s[n:]
+	}
+}
+
+// formatDumpFloat formats f for a Dump comment, always with a decimal
+// point or exponent so floats can't be mistaken for integers.
+func formatDumpFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// String formats the lines of the dump, aligning their comments.
+func (d *dumper) String() string {
+	const indent = 3
+
+	width := 0
+	for _, l := range d.lines {
+		if w := l.depth*indent + len(l.hex); w > width {
+			width = w
+		}
+	}
+	offsetWidth := len(fmt.Sprintf("%x", len(d.data)))
+	if offsetWidth < 4 {
+		offsetWidth = 4
+	}
+
+	var b strings.Builder
+	for _, l := range d.lines {
+		fmt.Fprintf(&b, "%0*x  ", offsetWidth, l.offset)
+		b.WriteString(strings.Repeat(" ", l.depth*indent))
+		b.WriteString(l.hex)
+		if l.comment != "" {
+			b.WriteString(strings.Repeat(" ", width-l.depth*indent-len(l.hex)+indent))
+			b.WriteString("# ")
+			b.WriteString(strings.Repeat("  ", l.depth))
+			b.WriteString(l.comment)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
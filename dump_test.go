@@ -0,0 +1,108 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func ExampleDump() {
+	data, err := cbor.Marshal(map[string]interface{}{
+		"a": []interface{}{1, -2},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Print(cbor.Dump(data))
+	// Output:
+	// 0000  a1         # map(1)
+	// 0001     61      #   text(1)
+	// 0002        61   #     "a"
+	// 0003     82      #   array(2)
+	// 0004        01   #     unsigned(1)
+	// 0005        21   #     negative(1)
+}
+
+func TestDump(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want []string
+	}{
+		{
+			name: "sequence",
+			hex:  "c11a514b67b0f97e00fa47c35000f8fff6",
+			want: []string{
+				"0000  c1               # tag(1)",
+				"0001     1a 514b67b0   #   unsigned(1363896240)",
+				"0006  f9 7e00          # float16(NaN)",
+				"0009  fa 47c35000      # float32(100000.0)",
+				"000e  f8 ff            # simple(255)",
+				"0010  f6               # null",
+			},
+		},
+		{
+			name: "indefinite-length string",
+			hex:  "7f657374726561646d696e67ff",
+			want: []string{
+				"0000  7f                 # text(*)",
+				"0001     65              #   text(5)",
+				"0002        7374726561   #     \"strea\"",
+				"0007     64              #   text(4)",
+				"0008        6d696e67     #     \"ming\"",
+				"000c     ff              #   break",
+			},
+		},
+		{
+			name: "long byte string",
+			hex:  "5811000102030405060708090a0b0c0d0e0f10",
+			want: []string{
+				"0000  58 11",
+				"0002     000102030405060708090a0b0c0d0e0f",
+				"0012     10",
+			},
+		},
+		{
+			name: "truncated",
+			hex:  "83018202",
+			want: []string{
+				"0000  83         # array(3)",
+				"0001     01      #   unsigned(1)",
+				"0002     82      #   array(2)",
+				"0003        02   #     unsigned(2)",
+				"0004             # error: unexpected EOF",
+			},
+		},
+		{
+			name: "stray break",
+			hex:  "01ff",
+			want: []string{
+				"0000  01   # unsigned(1)",
+				"0001       # error: unexpected break",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := hex.DecodeString(test.hex)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := strings.Split(strings.TrimSuffix(cbor.Dump(data), "\n"), "\n")
+			if len(got) != len(test.want) {
+				t.Fatalf("expected %d lines, got:\n%s", len(test.want), strings.Join(got, "\n"))
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], test.want[i]) {
+					t.Errorf("line %d: expected %q, got %q", i, test.want[i], got[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package cbor
+
+import (
+	"math"
+	"reflect"
+	"time"
+)
+
+// DurationMode controls how time.Duration values are encoded, and how
+// integers are decoded into them.
+type DurationMode int
+
+const (
+	// DurationNanoseconds encodes durations as integer nanoseconds, the
+	// underlying value of a time.Duration.
+	DurationNanoseconds DurationMode = iota
+
+	// DurationSeconds encodes durations as integer seconds, truncating
+	// any fraction of a second.
+	DurationSeconds
+
+	// DurationFloatSeconds encodes durations as floating-point seconds.
+	DurationFloatSeconds
+)
+
+// TagDuration is the tag of durations (RFC 9581), whose content is a map
+// with the number of seconds at key 1, and fractions of a second at the
+// negated base-10 exponent of their unit, such as {1: 90} for 90 seconds
+// or {1: 1, -9: 500} for 1.0000005 seconds.
+const TagDuration = 1002
+
+// durationType is the reflect.Type of time.Duration.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// encodeDuration writes a time.Duration according to the DurationMode of
+// the encoder, with TagDuration if its DurationTag option is set.
+func encodeDuration(e *Encoder, v reflect.Value) error {
+	d := time.Duration(v.Int())
+	if !e.options.DurationTag {
+		switch e.options.DurationMode {
+		case DurationSeconds:
+			return e.writeInt(int64(d / time.Second))
+		case DurationFloatSeconds:
+			return e.writeFloat(d.Seconds())
+		default:
+			return e.writeInt(int64(d))
+		}
+	}
+
+	e.buf = AppendTag(e.buf, TagDuration)
+	switch e.options.DurationMode {
+	case DurationSeconds:
+		e.buf = AppendMapHeader(e.buf, 1)
+		e.buf = AppendInt(AppendUint(e.buf, 1), int64(d/time.Second))
+	case DurationFloatSeconds:
+		e.buf = AppendMapHeader(e.buf, 1)
+		e.buf = AppendFloat64(AppendUint(e.buf, 1), d.Seconds())
+	default:
+		secs, nsecs := d/time.Second, d%time.Second
+		if nsecs == 0 {
+			e.buf = AppendMapHeader(e.buf, 1)
+			e.buf = AppendInt(AppendUint(e.buf, 1), int64(secs))
+			break
+		}
+		e.buf = AppendMapHeader(e.buf, 2)
+		e.buf = AppendInt(AppendUint(e.buf, 1), int64(secs))
+		e.buf = AppendInt(AppendInt(e.buf, -9), int64(nsecs))
+	}
+	return nil
+}
+
+// decodeDuration decodes an item into a time.Duration. Integers are
+// nanoseconds or seconds depending on the DurationMode of the decoder,
+// floats are seconds, and items with TagDuration are decoded whatever the
+// mode.
+func decodeDuration(dec *Decoder, rv reflect.Value, b byte) error {
+	switch {
+	case MajorType(b>>5) == MajorTypeUnsignedInt || MajorType(b>>5) == MajorTypeNegativeInt:
+		var n int64
+		if err := dec.decodeItem(reflect.ValueOf(&n).Elem(), b); err != nil {
+			return err
+		}
+		if dec.options.DurationMode == DurationNanoseconds {
+			rv.SetInt(n)
+			return nil
+		}
+		return setDuration(rv, float64(n))
+	case b == 0xf9 || b == 0xfa || b == 0xfb:
+		var f float64
+		if err := decodeFloatPlan(dec, reflect.ValueOf(&f).Elem(), b); err != nil {
+			return err
+		}
+		return setDuration(rv, f)
+	case MajorType(b>>5) == MajorTypeTag:
+		tag, err := dec.readArgument(b & 0x1f)
+		if err != nil {
+			return err
+		}
+		if tag != TagDuration {
+			return typeError("tag "+itoa(int64(tag)), rv.Type())
+		}
+		var units map[int64]float64
+		if err := dec.decodeValue(reflect.ValueOf(&units).Elem()); err != nil {
+			return err
+		}
+		var secs float64
+		var d time.Duration
+		for key, n := range units {
+			switch {
+			case key == 1:
+				secs += n
+			case key == -9:
+				// Nanoseconds are added exactly, since the rest of the
+				// duration is usually in whole seconds.
+				d += time.Duration(n)
+			case key < 0 && key >= -18:
+				secs += n * math.Pow10(int(key))
+			default:
+				return newError(ErrInvalidType, "cbor: unsupported duration key "+itoa(key))
+			}
+		}
+		if err := setDuration(rv, secs); err != nil {
+			return err
+		}
+		rv.SetInt(rv.Int() + int64(d))
+		return nil
+	}
+	return dec.decodeItem(rv, b)
+}
+
+// setDuration sets rv, a time.Duration, to the given number of seconds.
+// Whole seconds are converted exactly.
+func setDuration(rv reflect.Value, secs float64) error {
+	if secs == math.Trunc(secs) && math.Abs(secs) <= math.MaxInt64/float64(time.Second) {
+		rv.SetInt(int64(secs) * int64(time.Second))
+		return nil
+	}
+	ns := secs * float64(time.Second)
+	if math.IsNaN(ns) || ns >= math.MaxInt64 || ns < math.MinInt64 {
+		return newError(ErrInvalidType, "cbor: duration overflows time.Duration")
+	}
+	rv.SetInt(int64(ns))
+	return nil
+}
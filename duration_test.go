@@ -0,0 +1,69 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+func TestDuration(t *testing.T) {
+	type timeout struct {
+		D time.Duration
+	}
+
+	tests := []struct {
+		mode cbor.DurationMode
+		tag  bool
+		d    time.Duration
+		want string
+	}{
+		{cbor.DurationNanoseconds, false, 1500 * time.Millisecond, "a16144" + "1a59682f00"},
+		{cbor.DurationSeconds, false, 90 * time.Second, "a16144" + "185a"},
+		{cbor.DurationFloatSeconds, false, 1500 * time.Millisecond, "a16144" + "fb3ff8000000000000"},
+		{cbor.DurationNanoseconds, true, 90 * time.Second, "a16144" + "d903ea" + "a101185a"},
+		{cbor.DurationNanoseconds, true, time.Second + 500, "a16144" + "d903ea" + "a2010128" + "1901f4"},
+		{cbor.DurationSeconds, true, 90 * time.Second, "a16144" + "d903ea" + "a101185a"},
+		{cbor.DurationFloatSeconds, true, 1500 * time.Millisecond, "a16144" + "d903ea" + "a101fb3ff8000000000000"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		enc := cbor.NewEncoder(&buf)
+		enc.SetDurationMode(test.mode, test.tag)
+		if err := enc.Encode(timeout{D: test.d}); err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(buf.Bytes()); got != test.want {
+			t.Fatalf("mode %d, tag %v: expected %s, got %s", test.mode, test.tag, test.want, got)
+		}
+
+		dec := cbor.NewDecoder(&buf)
+		dec.SetDurationMode(test.mode)
+		var v timeout
+		err := dec.Decode(&v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.D != test.d {
+			t.Fatalf("mode %d, tag %v: expected %v, got %v", test.mode, test.tag, test.d, v.D)
+		}
+	}
+
+	// Marshal uses DefaultEncoderOptions, and MarshalT doesn't bypass it.
+	data, err := cbor.MarshalT(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(data); got != "1a77359400" {
+		t.Fatalf("expected 1a77359400, got %s", got)
+	}
+
+	// {1: 1, -3: 250}
+	d, err := cbor.UnmarshalT[time.Duration]([]byte{0xd9, 0x03, 0xea, 0xa2, 0x01, 0x01, 0x22, 0x18, 0xfa})
+	if err != nil || d != 1250*time.Millisecond {
+		t.Fatalf("expected 1.25s, got %v (err %v)", d, err)
+	}
+}
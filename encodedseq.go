@@ -0,0 +1,107 @@
+package cbor
+
+import (
+	"reflect"
+)
+
+// TagEncodedSequence is the tag of a byte string containing a CBOR
+// sequence (RFC 8742, section 4.1), which embeds several items in a
+// protocol field holding a single one, such as the byte string of
+// h'0102' tagged as 63(h'0102') for the sequence 1, 2.
+//
+// Items with this tag are decoded into slices one item of the sequence at
+// a time, so they can be decoded into a []RawMessage to split the
+// sequence, or a []T for items of the same type, and into a []interface{}
+// when decoding into an interface. A []byte is given the content of the
+// byte string as it is.
+const TagEncodedSequence = 63
+
+// EncodedSequence is a slice which is encoded as a byte string containing
+// a CBOR sequence of its elements, tagged with TagEncodedSequence.
+//
+// Decoding an EncodedSequence accepts the byte string with or without the
+// tag.
+type EncodedSequence[T any] []T
+
+// MarshalCBOR returns the tagged byte string of the CBOR sequence of the
+// elements of s.
+func (s EncodedSequence[T]) MarshalCBOR() ([]byte, error) {
+	var content []byte
+	for _, v := range s {
+		data, err := Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, data...)
+	}
+	return AppendBytes(AppendTag(nil, TagEncodedSequence), content), nil
+}
+
+// UnmarshalCBOR decodes a byte string containing a CBOR sequence, with or
+// without TagEncodedSequence, into the elements of s.
+func (s *EncodedSequence[T]) UnmarshalCBOR(data []byte) error {
+	if tag, rest, err := ReadTag(data); err == nil {
+		if tag != TagEncodedSequence {
+			return typeError("tag "+itoa(int64(tag)), reflect.TypeOf(s).Elem())
+		}
+		data = rest
+	}
+	if mt, err := NextType(data); err != nil || mt != MajorTypeByteString {
+		return newError(ErrInvalidType, "cbor: encoded CBOR sequence is not a byte string")
+	}
+
+	dec := getDecoder(data)
+	err := dec.decodeEncodedSequence(reflect.ValueOf((*[]T)(s)).Elem())
+	putDecoder(dec)
+	return err
+}
+
+// decodeEncodedSequence decodes the content of an item with
+// TagEncodedSequence into rv, which is a slice or an empty interface.
+func (dec *Decoder) decodeEncodedSequence(rv reflect.Value) error {
+	b, err := dec.readByte()
+	if err != nil {
+		return err
+	}
+	if MajorType(b>>5) != MajorTypeByteString {
+		return newError(ErrInvalidType, "cbor: encoded CBOR sequence is not a byte string")
+	}
+	var content []byte
+	if err := dec.decodeItem(reflect.ValueOf(&content).Elem(), b); err != nil {
+		return err
+	}
+
+	st := rv.Type()
+	switch {
+	case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+		st = reflect.TypeOf([]interface{}(nil))
+	case rv.Kind() != reflect.Slice:
+		return typeError("encoded CBOR sequence", rv.Type())
+	case st.Elem().Kind() == reflect.Uint8:
+		rv.SetBytes(content)
+		return nil
+	}
+
+	// The items are checked to be well-formed before anything is
+	// allocated for them.
+	n := 0
+	for rest := content; len(rest) > 0; n++ {
+		l, err := itemLength(rest, dec.depth+1)
+		if err != nil {
+			return err
+		}
+		rest = rest[l:]
+	}
+
+	s := reflect.MakeSlice(st, n, n)
+	sub := NewDecoderBytes(content)
+	sub.options = dec.options
+	sub.depth = dec.depth + 1
+	for i := 0; i < n; i++ {
+		if err := sub.decodeValue(s.Index(i)); err != nil {
+			return err
+		}
+	}
+	rv.Set(s)
+	return nil
+}
@@ -0,0 +1,98 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestEncodedSequence(t *testing.T) {
+	// 63(h'01 6161 820203'), the sequence 1, "a", [2, 3].
+	data := decodeHex(t, "d83f"+"46"+"016161820203")
+
+	var raw []cbor.RawMessage
+	if err := cbor.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 3 || hex.EncodeToString(raw[2]) != "820203" {
+		t.Fatalf("unexpected items %x", raw)
+	}
+
+	var v interface{}
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{uint64(1), "a", []interface{}{uint64(2), uint64(3)}}; !reflect.DeepEqual(v, want) {
+		t.Fatalf("expected %v, got %v", want, v)
+	}
+
+	// A []byte is given the content of the byte string.
+	var content []byte
+	if err := cbor.Unmarshal(data, &content); err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(content) != "016161820203" {
+		t.Fatalf("unexpected content %x", content)
+	}
+
+	var ints []int
+	if err := cbor.Unmarshal(decodeHex(t, "d83f43010203"), &ints); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ints, []int{1, 2, 3}) {
+		t.Fatalf("unexpected items %v", ints)
+	}
+	if err := cbor.Unmarshal(data, &ints); err == nil {
+		t.Fatal("expected error decoding a text string into an int")
+	}
+
+	for _, bad := range []string{
+		"d83f6101",   // not a byte string
+		"d83f428201", // truncated item in the sequence
+		"d83f41ff",   // stray break in the sequence
+	} {
+		if err := cbor.Unmarshal(decodeHex(t, bad), &raw); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestEncodedSequence_type(t *testing.T) {
+	type message struct {
+		Records cbor.EncodedSequence[string]
+	}
+
+	m := message{Records: cbor.EncodedSequence[string]{"a", "b"}}
+	data, err := cbor.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(data), "a1675265636f726473"+"d83f44"+"61616162"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	var got message
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Fatalf("expected %v, got %v", m, got)
+	}
+
+	// The tag is optional, but must be TagEncodedSequence if present.
+	var s cbor.EncodedSequence[uint64]
+	if err := cbor.Unmarshal(decodeHex(t, "420102"), &s); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, cbor.EncodedSequence[uint64]{1, 2}) {
+		t.Fatalf("unexpected items %v", s)
+	}
+	if err := cbor.Unmarshal(decodeHex(t, "d818420102"), &s); err == nil {
+		t.Fatal("expected error for another tag")
+	}
+	if err := cbor.Unmarshal(decodeHex(t, "820102"), &s); err == nil {
+		t.Fatal("expected error for an array")
+	}
+}
@@ -0,0 +1,464 @@
+package cbor
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// encoderFunc writes the encoding of v, a value of the type the function
+// was compiled for.
+type encoderFunc func(e *Encoder, v reflect.Value) error
+
+// encoderCache is a cache of compiled encoderFuncs, keyed by reflect.Type.
+var encoderCache sync.Map
+
+// encoderFor returns the encoderFunc for t, compiling it on first use, so
+// the reflection on t is only done once rather than for every value.
+func encoderFor(t reflect.Type) encoderFunc {
+	if f, ok := encoderCache.Load(t); ok {
+		return f.(encoderFunc)
+	}
+
+	// Recursive types need the function for t while it's compiled, so an
+	// indirect function waiting for the compiled one is stored first.
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(e *Encoder, v reflect.Value) error {
+		wg.Wait()
+		return f(e, v)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+
+	f = typeFuncEncoder(t, simpleEncoder(t, compileEncoder(t)))
+	wg.Done()
+	encoderCache.Store(t, f)
+	return f
+}
+
+// compileEncoder returns a new encoderFunc for t.
+func compileEncoder(t reflect.Type) encoderFunc {
+	if valid, ok := nullableValid(t); ok {
+		return compileNullableEncoder(t, valid)
+	}
+	switch {
+	case t == durationType:
+		return encodeDuration
+	case t == timeType:
+		return encodeTime
+	case t == dateTimeType:
+		return encodeDateTime
+	case t == epochTimeType:
+		return encodeEpochTime
+	case t == dateOnlyType:
+		return encodeDateOnly
+	case t == extendedTimeType:
+		return encodeExtendedTime
+	case t == jsonRawMessageType:
+		return encodeJSONRawMessage
+	case t == bigIntType:
+		return encodeBigInt
+	case t == uint128Type:
+		return encodeUint128
+	case t == int128Type:
+		return encodeInt128
+	case t == byteStringType:
+		return encodeByteString
+	case t == orderedMapType:
+		return encodeOrderedMap
+	case t == tagValueType:
+		return encodeTagValue
+	case t.Kind() == reflect.Ptr:
+		return compilePtrEncoder(t)
+	case t.Kind() == reflect.Interface:
+		return encodeInterface
+	case t.Implements(marshalerType):
+		// Types that marshal themselves.
+		return encodeMarshaler
+	case reflect.PtrTo(t).Implements(decimalType):
+		return encodeDecimalAddr
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		// Byte slices are encoded as byte strings, unless they are of a
+		// named type and the encoder's ByteSliceMode is ByteSliceArray.
+		if t.Elem().Kind() == reflect.Uint8 {
+			if t.Name() == "" {
+				return nilContainerEncoder(func(e *Encoder, v reflect.Value) error {
+					return e.writeBytes(v.Bytes())
+				})
+			}
+			array := compileArrayEncoder(t)
+			return nilContainerEncoder(func(e *Encoder, v reflect.Value) error {
+				if e.options.ByteSliceMode == ByteSliceArray {
+					return array(e, v)
+				}
+				return e.writeBytes(v.Bytes())
+			})
+		}
+		return nilContainerEncoder(compileArrayEncoder(t))
+	case reflect.Array:
+		return compileArrayEncoder(t)
+	case reflect.Map:
+		return nilContainerEncoder(compileMapEncoder(t))
+	case reflect.Struct:
+		return compileStructEncoder(t)
+	}
+	if f := basicEncoder(t.Kind()); f != nil {
+		return f
+	}
+	return func(e *Encoder, v reflect.Value) error {
+		return &UnsupportedTypeError{Type: t}
+	}
+}
+
+// nilContainerEncoder wraps the encoderFunc f of a slice or map type to
+// encode nil values as null with NilContainerAsNull.
+func nilContainerEncoder(f encoderFunc) encoderFunc {
+	return func(e *Encoder, v reflect.Value) error {
+		if e.options.NilContainers == NilContainerAsNull && v.IsNil() {
+			return e.writeNull()
+		}
+		return f(e, v)
+	}
+}
+
+// basicEncoder returns the encoderFunc of values of a boolean, integer,
+// float or string kind, or nil for other kinds.
+func basicEncoder(k reflect.Kind) encoderFunc {
+	switch k {
+	case reflect.Bool:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.writeBool(v.Bool())
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.writeInt(v.Int())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.writeUint(v.Uint())
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.writeFloat(v.Float())
+		}
+	case reflect.String:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.writeString(v.String())
+		}
+	}
+	return nil
+}
+
+// encodeMarshaler writes the output of a value implementing Marshaler.
+func encodeMarshaler(e *Encoder, v reflect.Value) error {
+	return e.writeMarshaler(v.Interface().(Marshaler))
+}
+
+// encodeInterface writes the encoding of the value in an interface, or
+// null if it's nil.
+func encodeInterface(e *Encoder, v reflect.Value) error {
+	if v.IsNil() {
+		return e.writeNull()
+	}
+	v = v.Elem()
+	return encoderFor(v.Type())(e, v)
+}
+
+// compilePtrEncoder returns the encoderFunc for a pointer type, which
+// encodes nil pointers as null, and other pointers as the value they point
+// to, unless the pointer type implements Marshaler or Decimal.
+func compilePtrEncoder(t reflect.Type) encoderFunc {
+	if t.Implements(marshalerType) {
+		return func(e *Encoder, v reflect.Value) error {
+			if v.IsNil() {
+				return e.writeNull()
+			}
+			return encodeMarshaler(e, v)
+		}
+	}
+	if t.Implements(decimalType) {
+		return func(e *Encoder, v reflect.Value) error {
+			if v.IsNil() {
+				return e.writeNull()
+			}
+			return encodeDecimal(e, v)
+		}
+	}
+
+	elem := encoderFor(t.Elem())
+	return func(e *Encoder, v reflect.Value) error {
+		if v.IsNil() {
+			return e.writeNull()
+		}
+		if err := e.enter(v); err != nil {
+			return err
+		}
+		err := elem(e, v.Elem())
+		e.leave(v)
+		return err
+	}
+}
+
+// compileArrayEncoder returns the encoderFunc for an array or slice type,
+// other than byte slices, which encodes it as an array.
+func compileArrayEncoder(t reflect.Type) encoderFunc {
+	elem := encoderFor(t.Elem())
+	encode := func(e *Encoder, v reflect.Value) error {
+		n := v.Len()
+		if err := e.writeHeader(MajorTypeArray, uint64(n)); err != nil {
+			return err
+		}
+		if err := e.nest(); err != nil {
+			return err
+		}
+		defer e.unnest()
+		for i := 0; i < n; i++ {
+			if err := elem(e, v.Index(i)); err != nil {
+				return err
+			}
+			if err := e.flushFull(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if t.Kind() == reflect.Array {
+		return encode
+	}
+
+	// Slices can contain themselves, through interfaces.
+	return func(e *Encoder, v reflect.Value) error {
+		if err := e.enter(v); err != nil {
+			return err
+		}
+		err := encode(e, v)
+		e.leave(v)
+		return err
+	}
+}
+
+// compileMapEncoder returns the encoderFunc for a map type. Keys of string
+// kinds are encoded as text strings, and keys of other types implementing
+// encoding.TextMarshaler as the text they marshal to, like encoding/json.
+// Keys of other boolean, integer, float or string kinds are encoded as
+// their underlying kind, even if their type implements Marshaler. Struct
+// keys are encoded as arrays, unless their type implements Marshaler.
+func compileMapEncoder(t reflect.Type) encoderFunc {
+	key := basicEncoder(t.Key().Kind())
+	switch {
+	case t.Key() == byteStringType:
+		key = encodeByteString
+	case t.Key().Kind() != reflect.String && t.Key().Implements(textMarshalerType):
+		key = encodeTextKey
+	case key != nil:
+	case t.Key().Kind() == reflect.Struct && !t.Key().Implements(marshalerType):
+		key = compileStructKeyEncoder(t.Key())
+	default:
+		key = encoderFor(t.Key())
+	}
+	key = typeFuncEncoder(t.Key(), key)
+	elem := encoderFor(t.Elem())
+
+	encode := func(e *Encoder, v reflect.Value) error {
+		if err := e.writeHeader(MajorTypeMap, uint64(v.Len())); err != nil {
+			return err
+		}
+		if err := e.nest(); err != nil {
+			return err
+		}
+		defer e.unnest()
+		for iter := v.MapRange(); iter.Next(); {
+			if err := key(e, iter.Key()); err != nil {
+				return err
+			}
+			if err := elem(e, iter.Value()); err != nil {
+				return err
+			}
+			if err := e.flushFull(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return func(e *Encoder, v reflect.Value) error {
+		if err := e.enter(v); err != nil {
+			return err
+		}
+		err := encode(e, v)
+		e.leave(v)
+		return err
+	}
+}
+
+// textMarshalerType is the reflect.Type of the encoding.TextMarshaler
+// interface.
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// encodeTextKey writes a map key implementing encoding.TextMarshaler as a
+// text string.
+func encodeTextKey(e *Encoder, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return e.writeString("")
+	}
+	text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return fmt.Errorf("cbor: error calling MarshalText for type %s: %w", v.Type(), err)
+	}
+	return e.writeString(string(text))
+}
+
+// compileStructKeyEncoder returns the encoderFunc for a struct type used
+// as a map key, which encodes it as an array of its exported fields in
+// order, so that composite keys don't need to be encoded as maps. Fields
+// with the cbor tag "-" are left out.
+func compileStructKeyEncoder(t reflect.Type) encoderFunc {
+	var (
+		index []int
+		encs  []encoderFunc
+	)
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); !ignoredField(field) {
+			enc := encoderFor(field.Type)
+			if tag, _ := parseStructTag(field.Tag.Get("cbor")); tag.transform != "" {
+				enc = transformEncoder(tag.transform, enc)
+			}
+			index = append(index, i)
+			encs = append(encs, enc)
+		}
+	}
+	unexported := unexportedFields(t)
+
+	return func(e *Encoder, v reflect.Value) error {
+		if len(unexported) > 0 && e.options.UnexportedFields == UnexportedFieldError {
+			return unexportedFieldError(t, unexported[0])
+		}
+		if err := e.writeHeader(MajorTypeArray, uint64(len(index))); err != nil {
+			return err
+		}
+		if err := e.nest(); err != nil {
+			return err
+		}
+		defer e.unnest()
+		for i, fi := range index {
+			if err := encs[i](e, v.Field(fi)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// compileStructFields returns the compiled encoders of the fields of the
+// struct type t which are encoded in a map, using json tags for fields
+// without a cbor tag if jsonTags is set.
+func compileStructFields(t reflect.Type, jsonTags bool) []structFieldEncoder {
+	var fields []structFieldEncoder
+	for _, k := range structKeys(t, jsonTags) {
+		f := structFieldEncoder{
+			index:     k.index,
+			key:       AppendString(nil, k.name),
+			omitEmpty: k.omitEmpty,
+			omitZero:  k.omitZero,
+			enc:       encoderFor(t.Field(k.index).Type),
+		}
+		if k.transform != "" {
+			f.enc = transformEncoder(k.transform, f.enc)
+		}
+		if k.keyAsInt {
+			if n, err := strconv.ParseInt(k.name, 10, 64); err == nil {
+				f.key = AppendInt(nil, n)
+			} else if n, err := strconv.ParseUint(k.name, 10, 64); err == nil {
+				f.key = AppendUint(nil, n)
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// structFieldEncoder is the compiled encoder of an exported struct field.
+type structFieldEncoder struct {
+	index int
+
+	// key is the encoded map key of the field.
+	key []byte
+
+	omitEmpty bool
+	omitZero  bool
+	enc       encoderFunc
+}
+
+// omitted reports whether the field is left out of the encoding of a
+// struct, because of its omitempty or omitzero option.
+func (f *structFieldEncoder) omitted(v reflect.Value) bool {
+	return f.omitEmpty && isEmptyValue(v) || f.omitZero && isZeroValue(v)
+}
+
+// compileStructEncoder returns the encoderFunc for a struct type, which
+// encodes it as a map, with a key for each exported field: the field name,
+// or the name in its cbor tag. Fields with the keyasint option use the
+// name as an integer key, as do fields numbered by the intkeys option, and
+// fields with the omitempty or omitzero option are left out if they are
+// empty or zero. With the JSONTags option, fields without a cbor tag use
+// their json tag. Structs with the toarray option are encoded as arrays of
+// their fields instead.
+func compileStructEncoder(t reflect.Type) encoderFunc {
+	if typeTag(t).toArray {
+		return compileStructKeyEncoder(t)
+	}
+
+	fields := compileStructFields(t, false)
+	jsonFields := fields
+	if hasJSONTags(t) {
+		jsonFields = compileStructFields(t, true)
+	}
+	unexported := unexportedFields(t)
+
+	return func(e *Encoder, v reflect.Value) error {
+		if len(unexported) > 0 && e.options.UnexportedFields == UnexportedFieldError {
+			return unexportedFieldError(t, unexported[0])
+		}
+		fields := fields
+		if e.options.JSONTags {
+			fields = jsonFields
+		}
+
+		// Count the fields first, since the map header comes before them.
+		n := len(fields)
+		for i := range fields {
+			if fields[i].omitted(v.Field(fields[i].index)) {
+				n--
+			}
+		}
+		if err := e.writeHeader(MajorTypeMap, uint64(n)); err != nil {
+			return err
+		}
+		if err := e.nest(); err != nil {
+			return err
+		}
+		defer e.unnest()
+
+		for i := range fields {
+			f := &fields[i]
+			fv := v.Field(f.index)
+			if f.omitted(fv) {
+				continue
+			}
+			e.buf = append(e.buf, f.key...)
+			if err := f.enc(e, fv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -1,26 +1,401 @@
 package cbor
 
 import (
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"math"
 	"reflect"
 )
 
+// Marshal returns the CBOR encoding of v.
+//
+// See the documentation for Encode for details about the conversion of
+// Go values to CBOR.
+func Marshal(v interface{}) ([]byte, error) {
+	e := getEncoder()
+	defer putEncoder(e)
+
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.buf...), nil
+}
+
 // Encoder is a minimal CBOR encoder.
 type Encoder struct {
 	// contains filtered or unexported fields
 	w io.Writer
+
+	// buf buffers the encoding of the value being encoded, which is
+	// written to w once it's complete, or once it's larger than
+	// flushSize. Encoders used by Marshal have no w, and keep the whole
+	// encoding in buf.
+	buf []byte
+
+	// stats wraps the underlying writer to count the data written.
+	stats *statsWriter
+
+	// options is the encoder options.
+	options EncoderOptions
+
+	// ptrLevel is the nesting depth of pointers, maps and slices being
+	// encoded, and ptrSeen is the set of those being encoded once it's
+	// deeper than startDetectingCyclesAfter, to detect cycles.
+	ptrLevel uint
+	ptrSeen  map[interface{}]struct{}
+
+	// depth is the nesting depth of the containers being encoded, and
+	// written is the number of bytes of the value being encoded which
+	// have been written to w, for the limits in the options.
+	depth   int
+	written int
+
+	// writer is the open writer from ByteStringWriter or
+	// TextStringWriter, if any.
+	writer *chunkWriter
+}
+
+// startDetectingCyclesAfter is the nesting depth of pointers, maps and
+// slices after which the encoder checks for cycles, so values which are
+// not too deep don't pay for it.
+const startDetectingCyclesAfter = 1000
+
+// cycleKey identifies the value v, a pointer, map or slice, in ptrSeen.
+// Slices are identified by their length too, since a slice and a shorter
+// one starting at the same element aren't a cycle.
+func cycleKey(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Slice {
+		return struct {
+			ptr uintptr
+			len int
+		}{v.Pointer(), v.Len()}
+	}
+	return v.Pointer()
+}
+
+// enter records that v, a pointer, map or slice, is being encoded,
+// returning an UnsupportedValueError if it already was, since it would
+// never finish. It must be followed by leave if it succeeds.
+func (e *Encoder) enter(v reflect.Value) error {
+	e.ptrLevel++
+	if e.ptrLevel <= startDetectingCyclesAfter {
+		return nil
+	}
+	key := cycleKey(v)
+	if _, ok := e.ptrSeen[key]; ok {
+		e.ptrLevel--
+		return &UnsupportedValueError{Value: v, Str: "encountered a cycle via " + v.Type().String()}
+	}
+	if e.ptrSeen == nil {
+		e.ptrSeen = make(map[interface{}]struct{})
+	}
+	e.ptrSeen[key] = struct{}{}
+	return nil
+}
+
+// leave records that v, entered with enter, has been encoded.
+func (e *Encoder) leave(v reflect.Value) {
+	if e.ptrLevel > startDetectingCyclesAfter {
+		delete(e.ptrSeen, cycleKey(v))
+	}
+	e.ptrLevel--
+}
+
+// EncoderOptions are the options of an Encoder.
+type EncoderOptions struct {
+	// DurationMode controls how time.Duration values are encoded.
+	DurationMode DurationMode
+
+	// DurationTag wraps time.Duration values in TagDuration.
+	DurationTag bool
+
+	// ByteSliceMode controls how named byte slice types are encoded.
+	ByteSliceMode ByteSliceMode
+
+	// BigIntMode controls how big.Int values are encoded.
+	BigIntMode BigIntMode
+
+	// MaxDepth is the maximum nesting depth of arrays, slices, maps and
+	// structs in a value, or 0 for no limit.
+	MaxDepth int
+
+	// MaxOutputBytes is the maximum size in bytes of the encoding of a
+	// value, or 0 for no limit.
+	MaxOutputBytes int
+
+	// TimeMode controls how time.Time values are encoded.
+	TimeMode TimeMode
+
+	// TimeTag wraps time.Time values encoded as numbers in tag 1.
+	TimeTag bool
+
+	// TimeLayout is the layout of the date/time strings time.Time values
+	// are encoded as, or time.RFC3339Nano if empty.
+	TimeLayout string
+
+	// NilContainers controls how nil slices and maps are encoded.
+	NilContainers NilContainersMode
+
+	// JSONTags makes struct fields without a cbor tag use their json tag.
+	// See SetJSONTags.
+	JSONTags bool
+
+	// NegativeZero controls how floats which are negative zero are
+	// encoded. See SetNegativeZero.
+	NegativeZero NegativeZeroMode
+
+	// UnexportedFields controls whether structs with unexported fields
+	// are an error. See SetUnexportedFields.
+	UnexportedFields UnexportedFieldMode
+
+	// TypeEncoders are the functions encoding values of specific types.
+	// See SetTypeEncoder.
+	TypeEncoders map[reflect.Type]TypeEncoderFunc
+
+	// TypeTransforms are the functions transforming values of specific
+	// types before they're encoded. See SetTypeTransform.
+	TypeTransforms map[reflect.Type]TransformFunc
+
+	// FieldTransforms are the functions transforming struct fields with
+	// the transform option before they're encoded, by name. See
+	// SetFieldTransform.
+	FieldTransforms map[string]TransformFunc
 }
 
+// ByteSliceMode controls how named types whose underlying type is a byte
+// slice, such as net.IP, are encoded. Values of type []byte are always
+// encoded as byte strings.
+type ByteSliceMode int
+
+const (
+	// ByteSliceBytes encodes named byte slice types as byte strings.
+	ByteSliceBytes ByteSliceMode = iota
+
+	// ByteSliceArray encodes named byte slice types as arrays of
+	// integers, for schemas which define them as arrays.
+	ByteSliceArray
+)
+
+// NilContainersMode controls how nil slices and maps are encoded.
+type NilContainersMode int
+
+const (
+	// NilContainerAsEmpty encodes nil slices and maps as empty arrays,
+	// byte strings and maps, so they decode as empty values.
+	NilContainerAsEmpty NilContainersMode = iota
+
+	// NilContainerAsNull encodes nil slices and maps as null, like
+	// encoding/json does.
+	NilContainerAsNull
+)
+
+// NegativeZeroMode controls whether floats which are negative zero keep
+// their sign when they're encoded or decoded.
+type NegativeZeroMode int
+
+const (
+	// NegativeZeroPreserve keeps the sign of negative zero, so -0.0 is
+	// encoded and decoded as -0.0.
+	NegativeZeroPreserve NegativeZeroMode = iota
+
+	// NegativeZeroNormalize replaces negative zero with positive zero, as
+	// deterministic encodings such as dCBOR require.
+	NegativeZeroNormalize
+)
+
+// UnexportedFieldMode controls what happens to unexported struct fields,
+// which can't be encoded or decoded, other than blank fields and fields
+// with the cbor tag "-".
+type UnexportedFieldMode int
+
+const (
+	// UnexportedFieldIgnore skips unexported fields, which are left out
+	// of the encoding of structs, and don't match the keys of the maps
+	// decoded into structs.
+	UnexportedFieldIgnore UnexportedFieldMode = iota
+
+	// UnexportedFieldError makes encoding a struct with unexported fields
+	// an error, as is decoding a map with a key matching the name of one,
+	// so fields which were unexported by mistake aren't silently lost.
+	UnexportedFieldError
+)
+
+// DefaultEncoderOptions is the default encoder options, used by Marshal
+// and by new encoders.
+var DefaultEncoderOptions = EncoderOptions{
+	DurationMode:  DurationNanoseconds,
+	ByteSliceMode: ByteSliceBytes,
+	BigIntMode:    BigIntTagged,
+}
+
+// flushSize is the size above which an Encoder writes its buffer to the
+// underlying writer in the middle of encoding a value.
+const flushSize = 32 << 10
+
 // NewEncoder returns a new encoder that writes to w.
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: w}
+	stats := &statsWriter{w: w}
+	return &Encoder{w: stats, stats: stats, options: DefaultEncoderOptions}
+}
+
+// NewEncoderBuffer returns a new encoder that appends the encoding of each
+// value to buf, which is returned by Bytes, rather than writing it to an
+// io.Writer. It avoids the copy made by Marshal, and the indirection of
+// writing to a bytes.Buffer, when encoding many values into memory.
+//
+// If a value can't be encoded, the buffer is left as it was before.
+func NewEncoderBuffer(buf []byte) *Encoder {
+	return &Encoder{buf: buf, stats: &statsWriter{}, options: DefaultEncoderOptions}
+}
+
+// Bytes returns the buffer of an encoder from NewEncoderBuffer, with the
+// encoding of the values encoded so far appended to it. It's nil for
+// other encoders.
+func (e *Encoder) Bytes() []byte {
+	if e.w != nil {
+		return nil
+	}
+	return e.buf
+}
+
+// SetBigIntMode sets how big.Int values are encoded.
+//
+// The default is BigIntTagged.
+func (e *Encoder) SetBigIntMode(mode BigIntMode) {
+	e.options.BigIntMode = mode
+}
+
+// SetByteSliceMode sets how named byte slice types are encoded.
+//
+// The default is ByteSliceBytes.
+func (e *Encoder) SetByteSliceMode(mode ByteSliceMode) {
+	e.options.ByteSliceMode = mode
+}
+
+// SetMaxDepth sets the maximum nesting depth of arrays, slices, maps and
+// structs in the values encoded.
+//
+// If a value is nested deeper than this limit, an error wrapping
+// ErrMaxDepth is returned, so that services encoding untrusted data, such
+// as decoded interface{} values, can bound the work done.
+//
+// The default is 0, for no limit.
+func (e *Encoder) SetMaxDepth(n int) {
+	e.options.MaxDepth = n
+}
+
+// SetMaxOutputBytes sets the maximum size in bytes of the encoding of
+// each value.
+//
+// If the encoding of a value is larger than this limit, an error wrapping
+// ErrOutputTooLarge is returned. Parts of the encoding may have been
+// written to the underlying writer already.
+//
+// The default is 0, for no limit.
+func (e *Encoder) SetMaxOutputBytes(n int) {
+	e.options.MaxOutputBytes = n
+}
+
+// SetTimeLayout sets the layout, as accepted by time.Time.Format, of the
+// date/time strings (tag 0) time.Time values are encoded as.
+//
+// The default is time.RFC3339Nano, as required by RFC 8949. Other layouts
+// are only understood by decoders which are configured to accept them.
+func (e *Encoder) SetTimeLayout(layout string) {
+	e.options.TimeLayout = layout
+}
+
+// SetDurationMode sets how time.Duration values are encoded, and whether
+// they are wrapped in TagDuration.
+//
+// The default is DurationNanoseconds, without a tag.
+func (e *Encoder) SetDurationMode(mode DurationMode, tag bool) {
+	e.options.DurationMode = mode
+	e.options.DurationTag = tag
+}
+
+// SetTimeMode sets how time.Time values are encoded, and whether times
+// encoded as numbers are wrapped in tag 1.
+//
+// The default is TimeString, which always has tag 0.
+func (e *Encoder) SetTimeMode(mode TimeMode, tag bool) {
+	e.options.TimeMode = mode
+	e.options.TimeTag = tag
+}
+
+// SetNilContainers sets how nil slices and maps are encoded.
+//
+// The default is NilContainerAsEmpty.
+func (e *Encoder) SetNilContainers(mode NilContainersMode) {
+	e.options.NilContainers = mode
+}
+
+// SetJSONTags sets whether struct fields without a cbor tag use their json
+// tag, for its name and its omitempty and omitzero options, so types
+// already tagged for encoding/json don't need cbor tags too. Fields with
+// the json tag "-" are left out. It only applies to structs encoded as
+// maps.
+//
+// It's disabled by default.
+func (e *Encoder) SetJSONTags(on bool) {
+	e.options.JSONTags = on
+}
+
+// SetNegativeZero sets whether floats which are negative zero are encoded
+// as negative zero, or as positive zero. Both are equal when compared as
+// floats, but their encodings differ, which matters when the encoding is
+// hashed or signed.
+//
+// The default is NegativeZeroPreserve.
+func (e *Encoder) SetNegativeZero(mode NegativeZeroMode) {
+	e.options.NegativeZero = mode
+}
+
+// SetUnexportedFields sets whether structs with unexported fields are
+// encoded without them, or are an error wrapping ErrUnexportedField.
+//
+// The default is UnexportedFieldIgnore.
+func (e *Encoder) SetUnexportedFields(mode UnexportedFieldMode) {
+	e.options.UnexportedFields = mode
+}
+
+// SetOptions replaces all the options of the encoder with opts, such as
+// FxamackerEncoderOptions.
+func (e *Encoder) SetOptions(opts EncoderOptions) {
+	e.options = opts
 }
 
 // Encode writes the CBOR encoding of v to the stream.
+//
+// The encoder of each type is compiled on first use and cached, so the
+// reflection on struct fields and element types is done once per type.
 func (e *Encoder) Encode(v interface{}) error {
+	if e.writer != nil {
+		return errWriterOpen
+	}
+	start := len(e.buf)
+	if err := e.encode(v); err != nil {
+		e.buf = e.buf[:start]
+		return err
+	}
+	return e.finish(start)
+}
+
+// finish completes the encoding of a value, which starts at offset start
+// of the buffer, writing it to the underlying writer, or only counting it
+// for encoders from NewEncoderBuffer.
+func (e *Encoder) finish(start int) error {
+	if e.w == nil {
+		if e.stats != nil {
+			e.stats.scan(e.buf[start:])
+		}
+		return nil
+	}
+	return e.flush()
+}
+
+// encode appends the CBOR encoding of v to the encoder's buffer.
+func (e *Encoder) encode(v interface{}) error {
 	rv := reflect.ValueOf(v)
 
 	// Handle nil.
@@ -28,198 +403,242 @@ func (e *Encoder) Encode(v interface{}) error {
 		return e.writeNull()
 	}
 
-	// Handle types.
-	switch rv.Kind() {
-	case reflect.Bool:
-		return e.writeBool(rv.Bool())
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return e.writeInt(rv.Int())
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		return e.writeUint(rv.Uint())
-	case reflect.Float32, reflect.Float64:
-		return e.writeFloat(rv.Float())
-	case reflect.String:
-		return e.writeString(rv.String())
-	case reflect.Array, reflect.Slice:
-		return e.writeArray(rv)
-	case reflect.Map:
-		return e.writeMap(rv)
-	case reflect.Struct:
-		return e.writeStruct(rv)
+	// Encoders from NewEncoderBuffer keep the values encoded before in
+	// their buffer, which don't count toward the limits.
+	e.written = -len(e.buf)
+	if err := encoderFor(rv.Type())(e, rv); err != nil {
+		return err
+	}
+	return e.checkOutput(0)
+}
+
+// checkOutput returns an ErrOutputTooLarge error if the encoding of the
+// value being encoded would be larger than the MaxOutputBytes limit with
+// n more bytes.
+func (e *Encoder) checkOutput(n int) error {
+	if limit := e.options.MaxOutputBytes; limit > 0 && e.written+len(e.buf)+n > limit {
+		return newError(ErrOutputTooLarge, "cbor: encoding exceeds "+itoa(int64(limit))+" bytes")
 	}
+	return nil
+}
+
+// nest is called before encoding the elements of a container, returning
+// an ErrMaxDepth error if it's nested deeper than the MaxDepth limit. It
+// must be followed by unnest if it succeeds.
+func (e *Encoder) nest() error {
+	if e.options.MaxDepth > 0 && e.depth >= e.options.MaxDepth {
+		return ErrMaxDepth
+	}
+	e.depth++
+	return nil
+}
 
-	return fmt.Errorf("cbor: unsupported type: %T", v)
+// unnest is called after encoding the elements of a container.
+func (e *Encoder) unnest() {
+	e.depth--
+}
+
+// flush writes the encoder's buffer to the underlying writer, if it has
+// one.
+func (e *Encoder) flush() error {
+	if e.w == nil {
+		return nil
+	}
+	_, err := e.w.Write(e.buf)
+	e.written += len(e.buf)
+	e.buf = e.buf[:0]
+	return err
+}
+
+// flushFull flushes the encoder's buffer if it's larger than flushSize.
+// It's called between the elements of containers, so it also checks the
+// MaxOutputBytes limit.
+func (e *Encoder) flushFull() error {
+	if err := e.checkOutput(0); err != nil {
+		return err
+	}
+	if len(e.buf) < flushSize {
+		return nil
+	}
+	return e.flush()
+}
+
+// writeMarshaler writes the output of a Marshaler, which must be exactly
+// one well-formed CBOR item.
+func (e *Encoder) writeMarshaler(m Marshaler) error {
+	b, err := marshalItem(m)
+	if err != nil {
+		return err
+	}
+
+	e.buf = append(e.buf, b...)
+	return nil
+}
+
+// marshalerType is the reflect.Type of the Marshaler interface.
+var marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+// marshalItem calls m.MarshalCBOR, and checks that its output is exactly
+// one well-formed CBOR item.
+func marshalItem(m Marshaler) ([]byte, error) {
+	b, err := m.MarshalCBOR()
+	if err != nil {
+		return nil, fmt.Errorf("cbor: error calling MarshalCBOR for type %T: %w", m, err)
+	}
+
+	if n, err := itemLength(b, 0); err != nil || n != len(b) {
+		if err == nil {
+			err = errors.New("trailing data after item")
+		}
+		return nil, fmt.Errorf("cbor: error calling MarshalCBOR for type %T: %w", m, err)
+	}
+
+	return b, nil
 }
 
 // writeNull writes a null value.
 func (e *Encoder) writeNull() error {
-	_, err := e.w.Write([]byte{0xf6})
-	return err
+	e.buf = append(e.buf, 0xf6)
+	return nil
 }
 
 // writeBool writes a boolean value.
 func (e *Encoder) writeBool(v bool) error {
 	if v {
-		_, err := e.w.Write([]byte{0xf5})
-		return err
+		e.buf = append(e.buf, 0xf5)
+	} else {
+		e.buf = append(e.buf, 0xf4)
 	}
-	_, err := e.w.Write([]byte{0xf4})
-	return err
+	return nil
+}
+
+// writeHeader writes the header of a CBOR item with the given major type
+// and argument, using the shortest possible encoding of the argument.
+func (e *Encoder) writeHeader(mt MajorType, n uint64) error {
+	e.buf = appendHeader(e.buf, mt, n)
+	return nil
 }
 
 // writeInt writes an integer value.
+//
+// Negative integers are encoded as major type 1 with the argument -1-v.
 func (e *Encoder) writeInt(v int64) error {
-	switch {
-	case v >= 0 && v <= 23:
-		_, err := e.w.Write([]byte{byte(v)})
-		return err
-	case v >= 24 && v <= 255:
-		_, err := e.w.Write([]byte{0x18, byte(v)})
-		return err
-	case v >= 256 && v <= 65535:
-		_, err := e.w.Write([]byte{0x19, byte(v >> 8), byte(v)})
-		return err
-	case v >= 65536 && v <= 4294967295:
-		_, err := e.w.Write([]byte{0x1a, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
-		return err
-	case v >= 4294967296 && v <= math.MaxInt64-1:
-		_, err := e.w.Write([]byte{0x1b, byte(v >> 56), byte(v >> 48), byte(v >> 40), byte(v >> 32), byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
-		return err
+	if v < 0 {
+		return e.writeHeader(MajorTypeNegativeInt, uint64(-1-v))
 	}
-	return fmt.Errorf("cbor: integer out of range: %d", v)
+	return e.writeHeader(MajorTypeUnsignedInt, uint64(v))
 }
 
 // writeUint writes an unsigned integer value.
 func (e *Encoder) writeUint(v uint64) error {
-	switch {
-	case v <= 23:
-		_, err := e.w.Write([]byte{byte(v)})
-		return err
-	case v >= 24 && v <= 255:
-		_, err := e.w.Write([]byte{0x18, byte(v)})
-		return err
-	case v >= 256 && v <= 65535:
-		_, err := e.w.Write([]byte{0x19, byte(v >> 8), byte(v)})
-		return err
-	case v >= 65536 && v <= 4294967295:
-		_, err := e.w.Write([]byte{0x1a, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
-		return err
-	case v >= 4294967296 && v <= math.MaxUint64-1:
-		_, err := e.w.Write([]byte{0x1b, byte(v >> 56), byte(v >> 48), byte(v >> 40), byte(v >> 32), byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
-		return err
-	}
-	return fmt.Errorf("cbor: integer out of range: %d", v)
+	return e.writeHeader(MajorTypeUnsignedInt, v)
 }
 
 // writeFloat writes a floating point value.
 func (e *Encoder) writeFloat(v float64) error {
-	// Encode as a 64-bit float.
-	_, err := e.w.Write([]byte{0xfb})
-	if err != nil {
-		return err
+	if v == 0 && e.options.NegativeZero == NegativeZeroNormalize {
+		v = 0
 	}
-
-	var buf [8]byte
-	binary.BigEndian.PutUint64(buf[:], math.Float64bits(v))
-	_, err = e.w.Write(buf[:])
-	return err
+	// Encode as a 64-bit float.
+	e.buf = AppendFloat64(e.buf, v)
+	return nil
 }
 
 // writeString writes a string value.
 func (e *Encoder) writeString(v string) error {
-	// Encode as a test string
-	_, err := e.w.Write([]byte{
-		0x78, // text string
-		byte(len(v)),
-	})
-
-	if err != nil {
+	// Encode as a text string.
+	if err := e.writeHeader(MajorTypeTextString, uint64(len(v))); err != nil {
 		return err
 	}
 
-	_, err = e.w.Write([]byte(v))
-	return err
+	if len(v) >= flushSize && e.w != nil {
+		return e.writeLarge([]byte(v))
+	}
+	e.buf = append(e.buf, v...)
+	return nil
 }
 
-// writeArray writes an array value.
-func (e *Encoder) writeArray(v reflect.Value) error {
-	// Encode as an array.
-	_, err := e.w.Write([]byte{
-		0x98,
-		byte(v.Len()),
-	})
-
-	if err != nil {
+// writeBytes writes a byte string value.
+func (e *Encoder) writeBytes(v []byte) error {
+	if err := e.writeHeader(MajorTypeByteString, uint64(len(v))); err != nil {
 		return err
 	}
 
-	for i := 0; i < v.Len(); i++ {
-		if err := e.Encode(v.Index(i).Interface()); err != nil {
-			return err
-		}
+	if len(v) >= flushSize && e.w != nil {
+		return e.writeLarge(v)
 	}
-
+	e.buf = append(e.buf, v...)
 	return nil
 }
 
-// writeMap writes a map value.
-func (e *Encoder) writeMap(v reflect.Value) error {
-	// Encode as a map.
-	_, err := e.w.Write([]byte{
-		0xb8,
-		byte(v.Len()),
-	})
-
-	if err != nil {
+// writeLarge writes the buffer and then the large content of a string
+// directly to the underlying writer, rather than copying it to the buffer.
+func (e *Encoder) writeLarge(v []byte) error {
+	if err := e.checkOutput(len(v)); err != nil {
 		return err
 	}
-
-	// getKey returns the human friendly key type
-	// to encode the map key.
-	getKey := func(key reflect.Value) interface{} {
-		switch key.Kind() {
-		case reflect.String:
-			return key.String()
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			return key.Int()
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			return key.Uint()
-		default:
-			return key.Interface()
-		}
+	if err := e.flush(); err != nil {
+		return err
 	}
+	_, err := e.w.Write(v)
+	e.written += len(v)
+	return err
+}
 
-	for _, key := range v.MapKeys() {
-		// Encode key, then value.
-		if err := e.Encode(getKey(key)); err != nil {
-			return err
-		}
+// isZeroer is implemented by types with an IsZero method, like time.Time.
+type isZeroer interface {
+	IsZero() bool
+}
+
+// isZeroerType is the reflect.Type of the isZeroer interface.
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
 
-		if err := e.Encode(v.MapIndex(key).Interface()); err != nil {
-			return err
+// isZeroValue reports whether v is zero for the omitzero option: its IsZero
+// method returns true, or it doesn't have one and is the zero value of its
+// type, like with the omitzero option of encoding/json.
+func isZeroValue(v reflect.Value) bool {
+	if v.Type().Implements(isZeroerType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return true
 		}
+		return v.Interface().(isZeroer).IsZero()
 	}
-
-	return nil
+	if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(isZeroerType) {
+		return v.Addr().Interface().(isZeroer).IsZero()
+	}
+	return v.IsZero()
 }
 
-// writeStruct writes a struct value.
-func (e *Encoder) writeStruct(v reflect.Value) error {
-	// Encode as a map.
-	_, err := e.w.Write([]byte{
-		0xb8,
-		byte(v.NumField()),
-	})
-
-	if err != nil {
-		return err
-	}
+// IsEmpty reports whether the value ptr points to is empty for the
+// omitempty option, as when encoding it as a struct field. It's used by
+// code generated by cborgen for fields of types it doesn't know.
+func IsEmpty(ptr interface{}) bool {
+	return isEmptyValue(reflect.ValueOf(ptr).Elem())
+}
 
-	for i := 0; i < v.NumField(); i++ {
-		if err := e.Encode(v.Field(i).Interface()); err != nil {
-			return err
+// isEmptyValue reports whether v is empty for the omitempty option, using
+// the same rules as encoding/json: false, 0, a nil pointer or interface,
+// and an empty array, slice, map, or string, as well as an absent
+// Optional.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	case reflect.Struct:
+		// Absent Optional values and invalid database/sql null types.
+		if valid, ok := nullableValid(v.Type()); ok {
+			return !v.Field(valid).Bool()
 		}
 	}
-
-	return nil
+	return false
 }
@@ -2,7 +2,12 @@ package cbor_test
 
 import (
 	"bytes"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/picatz/cbor"
@@ -48,6 +53,76 @@ func TestEncodeFloat(t *testing.T) {
 	fmt.Printf("%x\n", buf.Bytes())
 }
 
+func TestEncoder_SetNegativeZero(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	tests := []struct {
+		mode cbor.NegativeZeroMode
+		v    interface{}
+		want string
+	}{
+		{cbor.NegativeZeroPreserve, negZero, "fb8000000000000000"},
+		{cbor.NegativeZeroNormalize, negZero, "fb0000000000000000"},
+		{cbor.NegativeZeroNormalize, float32(negZero), "fb0000000000000000"},
+		{cbor.NegativeZeroNormalize, []float64{negZero, 1}, "82fb0000000000000000fb3ff0000000000000"},
+		{cbor.NegativeZeroNormalize, map[string]float64{"a": negZero}, "a16161fb0000000000000000"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		enc := cbor.NewEncoder(&buf)
+		enc.SetNegativeZero(test.mode)
+		if err := enc.Encode(test.v); err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(buf.Bytes()); got != test.want {
+			t.Errorf("%v with mode %d: expected %s, got %s", test.v, test.mode, test.want, got)
+		}
+	}
+}
+
+func TestEncoder_SetUnexportedFields(t *testing.T) {
+	type private struct {
+		A int
+		b int
+	}
+	type ignored struct {
+		_ struct{} `cbor:",toarray"`
+		A int
+		b int `cbor:"-"`
+	}
+	tests := []struct {
+		v       interface{}
+		want    string
+		wantErr bool
+	}{
+		{private{A: 1, b: 2}, "a1614101", true},
+		{&private{A: 1, b: 2}, "a1614101", true},
+		{map[private]int{{A: 1}: 2}, "a1810102", true},
+		{ignored{A: 1}, "8101", false},
+	}
+
+	for _, test := range tests {
+		for _, mode := range []cbor.UnexportedFieldMode{cbor.UnexportedFieldIgnore, cbor.UnexportedFieldError} {
+			var buf bytes.Buffer
+			enc := cbor.NewEncoder(&buf)
+			enc.SetUnexportedFields(mode)
+			err := enc.Encode(test.v)
+			if mode == cbor.UnexportedFieldError && test.wantErr {
+				if !errors.Is(err, cbor.ErrUnexportedField) {
+					t.Errorf("%#v: expected ErrUnexportedField, got %v", test.v, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != test.want {
+				t.Errorf("%#v with mode %d: expected %s, got %s", test.v, mode, test.want, got)
+			}
+		}
+	}
+}
+
 func TestEncodeBool(t *testing.T) {
 	var buf bytes.Buffer
 	enc := cbor.NewEncoder(&buf)
@@ -58,6 +133,28 @@ func TestEncodeBool(t *testing.T) {
 	fmt.Printf("%x\n", buf.Bytes())
 }
 
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{[]byte{1, 2, 3}, "43010203"},
+		{[]byte{}, "40"},
+		{[]int{1, 2, 3}, "83010203"},
+		{"abc", "63616263"},
+	}
+
+	for _, test := range tests {
+		data, err := cbor.Marshal(test.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprintf("%x", data); got != test.want {
+			t.Errorf("%#v: expected %s, got %s", test.v, test.want, got)
+		}
+	}
+}
+
 func TestEncodeArray(t *testing.T) {
 	var buf bytes.Buffer
 	enc := cbor.NewEncoder(&buf)
@@ -92,7 +189,6 @@ type testStruct struct {
 	Two int
 }
 
-// TODO: decode struct
 func TestEncodeStruct(t *testing.T) {
 	var buf bytes.Buffer
 	enc := cbor.NewEncoder(&buf)
@@ -102,3 +198,389 @@ func TestEncodeStruct(t *testing.T) {
 	}
 	fmt.Printf("%x\n", buf.Bytes())
 }
+
+func TestEncodeStructTags(t *testing.T) {
+	type tagged struct {
+		Name    string `cbor:"name"`
+		ID      int    `cbor:"1,keyasint"`
+		Note    string `cbor:"note,omitempty"`
+		Count   int    `json:"count"`
+		private int
+	}
+
+	b, err := cbor.Marshal(tagged{Name: "a", ID: -2, Count: 3, private: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// {"name": "a", 1: -2, "Count": 3}
+	if got, want := fmt.Sprintf("%x", b), "a3646e616d656161012165436f756e7403"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	var v tagged
+	if err := cbor.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "a" || v.ID != -2 || v.Count != 3 {
+		t.Fatalf("unexpected round trip value %+v", v)
+	}
+}
+
+func TestEncodePlans(t *testing.T) {
+	type node struct {
+		Value    int         `cbor:"v"`
+		Next     *node       `cbor:"n,omitempty"`
+		Extra    interface{} `cbor:"x,omitempty"`
+		Children []node      `cbor:"c,omitempty"`
+	}
+
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"recursive", node{Value: 1, Next: &node{Value: 2}}, "a2617601616ea1617602"},
+		{"interface field", node{Extra: []interface{}{"a", nil}}, "a26176006178826161f6"},
+		{"slice of structs", []node{{Value: 1}, {Value: 2, Children: []node{{}}}}, "82a1617601a2617602616381a1617600"},
+		{"nil pointer", (*node)(nil), "f6"},
+		{"nil marshaler", (*point)(nil), "f6"},
+		{"pointer to marshaler", &point{1, 2}, "820102"},
+		{"array", [2]uint8{1, 2}, "820102"},
+		{"map of pointers", map[string]*int{"a": nil}, "a16161f6"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := cbor.Marshal(test.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%x", b); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	_, err := cbor.Marshal(struct{ C chan int }{})
+	var typeErr *cbor.UnsupportedTypeError
+	if !errors.As(err, &typeErr) || typeErr.Type != reflect.TypeOf(make(chan int)) {
+		t.Fatalf("expected an UnsupportedTypeError for chan int, got %v", err)
+	}
+}
+
+// nestedMarshaler marshals itself with Marshal, while it's being encoded.
+type nestedMarshaler int
+
+func (n nestedMarshaler) MarshalCBOR() ([]byte, error) {
+	return cbor.Marshal([]int{int(n)})
+}
+
+func TestMarshal_buffered(t *testing.T) {
+	// Marshal can be called while it's encoding a value.
+	b, err := cbor.Marshal([]nestedMarshaler{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprintf("%x", b); got != "8281018102" {
+		t.Fatalf("got %s, want 8281018102", got)
+	}
+
+	// Values which fail to encode aren't partially written, and don't
+	// affect later values.
+	var buf bytes.Buffer
+	enc := cbor.NewEncoder(&buf)
+	if err := enc.Encode([]interface{}{1, make(chan int)}); err == nil {
+		t.Fatal("expected an error for an unsupported element type")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing to be written, got %x", buf.Bytes())
+	}
+	if err := enc.Encode(1); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := cbor.Marshal(2); err != nil || !bytes.Equal(b, []byte{0x02}) {
+		t.Fatalf("unexpected %x (%v)", b, err)
+	}
+	if got := fmt.Sprintf("%x", buf.Bytes()); got != "01" {
+		t.Fatalf("got %s, want 01", got)
+	}
+}
+
+func BenchmarkMarshalCWTClaims(b *testing.B) {
+	v := claims{
+		Iss: "coap://as.example.com",
+		Sub: "erikw",
+		Aud: "coap://light.example.com",
+		Exp: 1444064944,
+		Nbf: 1443944944,
+		Iat: 1443944944,
+		Cti: []byte{0x0b, 0x71},
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := cbor.Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStructMapKeys(t *testing.T) {
+	type cell struct {
+		X, Y int
+		tag  string
+	}
+
+	data, err := cbor.Marshal(map[cell]string{{X: 1, Y: -2}: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(data), "a182012161"+"61"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	grid := map[cell]string{{X: 1, Y: 2}: "a", {X: 3, Y: 4}: "b", {}: "c"}
+	data, err = cbor.Marshal(grid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[cell]string
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, grid) {
+		t.Fatalf("expected %v, got %v", grid, got)
+	}
+
+	// Keys with the wrong number of fields aren't decoded.
+	for _, s := range []string{"a18101" + "6161", "a1830102036161", "a19f0102036161"} {
+		data, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cbor.Unmarshal(data, &got); !errors.Is(err, cbor.ErrInvalidType) {
+			t.Fatalf("%s: expected %v, got %v", s, cbor.ErrInvalidType, err)
+		}
+	}
+
+	// Indefinite-length keys are decoded.
+	if err := cbor.Unmarshal([]byte{0xa1, 0x9f, 0x05, 0x06, 0xff, 0x61, 0x64}, &got); err != nil || got[cell{X: 5, Y: 6}] != "d" {
+		t.Fatalf("expected {5 6}: d, got %v (err %v)", got, err)
+	}
+}
+
+func TestIntKeys(t *testing.T) {
+	type request struct {
+		_        struct{} `cbor:"1,intkeys"`
+		Hash     []byte
+		Name     string          `cbor:"name"`
+		Options  map[string]bool `cbor:",omitempty"`
+		internal int
+		PinAuth  []byte `cbor:",omitempty"`
+	}
+
+	v := request{Hash: []byte{1}, Name: "a", PinAuth: []byte{2}}
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// {1: h'01', "name": "a", 4: h'02'}
+	if got, want := hex.EncodeToString(data), "a3014101646e616d656161044102"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	var got request
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Fatalf("expected %+v, got %+v", v, got)
+	}
+}
+
+func TestIgnoredFields(t *testing.T) {
+	type session struct {
+		_      struct{} `cbor:",intkeys"`
+		User   string
+		Secret string `cbor:"-"`
+		Dash   bool   `cbor:"-,"`
+		TTL    int
+	}
+
+	v := session{User: "a", Secret: "hunter2", Dash: true, TTL: 60}
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// {0: "a", "-": true, 2: 60}; the ignored field doesn't take a key.
+	if got, want := hex.EncodeToString(data), "a3006161612df502183c"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	// A key matching the field name isn't decoded into it either.
+	data = append([]byte{0xa4, 0x66, 'S', 'e', 'c', 'r', 'e', 't', 0x61, 'x'}, data[1:]...)
+	var got session
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (session{User: "a", Dash: true, TTL: 60}); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestByteSliceMode(t *testing.T) {
+	type rgb []byte
+	type pixel struct {
+		Color rgb
+		Alpha []byte
+	}
+	v := pixel{Color: rgb{1, 2, 0xff}, Alpha: []byte{3}}
+
+	tests := []struct {
+		mode cbor.ByteSliceMode
+		want string
+	}{
+		{cbor.ByteSliceBytes, "a265436f6c6f72430102ff65416c70686141" + "03"},
+		{cbor.ByteSliceArray, "a265436f6c6f7283010218ff65416c70686141" + "03"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		enc := cbor.NewEncoder(&buf)
+		enc.SetByteSliceMode(test.mode)
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(buf.Bytes()); got != test.want {
+			t.Fatalf("mode %d: expected %s, got %s", test.mode, test.want, got)
+		}
+
+		var got pixel
+		if err := cbor.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Fatalf("mode %d: expected %v, got %v", test.mode, v, got)
+		}
+	}
+
+	// Marshal uses the default mode, even through MarshalT.
+	data, err := cbor.MarshalT(rgb{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(data); got != "4101" {
+		t.Fatalf("expected 4101, got %s", got)
+	}
+
+	// Elements which aren't integers aren't decoded.
+	var got []byte
+	if err := cbor.Unmarshal([]byte{0x81, 0x61, 0x61}, &got); !errors.Is(err, cbor.ErrInvalidType) {
+		t.Fatalf("expected %v, got %v", cbor.ErrInvalidType, err)
+	}
+}
+
+func TestEncodeCycles(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+	}
+	n := &node{Name: "a"}
+	n.Next = n
+
+	m := map[string]interface{}{}
+	m["m"] = m
+
+	s := []interface{}{nil}
+	s[0] = s
+
+	for _, v := range []interface{}{n, m, s} {
+		_, err := cbor.Marshal(v)
+		var target *cbor.UnsupportedValueError
+		if !errors.As(err, &target) {
+			t.Fatalf("%T: expected an UnsupportedValueError, got %v", v, err)
+		}
+	}
+
+	// Deep values which aren't cycles are encoded.
+	var list *node
+	for i := 0; i < 1500; i++ {
+		list = &node{Next: list}
+	}
+	if _, err := cbor.Marshal(list); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoderLimits(t *testing.T) {
+	// [[[1]]], as decoded from untrusted data.
+	nested := []interface{}{[]interface{}{map[string]interface{}{"a": 1}}}
+
+	var buf bytes.Buffer
+	enc := cbor.NewEncoder(&buf)
+	enc.SetMaxDepth(2)
+	if err := enc.Encode(nested); !errors.Is(err, cbor.ErrMaxDepth) {
+		t.Fatalf("expected %v, got %v", cbor.ErrMaxDepth, err)
+	}
+	if err := cbor.EncodeSlice(enc, []interface{}{[]interface{}{[]int{1}}}); !errors.Is(err, cbor.ErrMaxDepth) {
+		t.Fatalf("expected %v, got %v", cbor.ErrMaxDepth, err)
+	}
+	enc.SetMaxDepth(3)
+	if err := enc.Encode(nested); err != nil {
+		t.Fatal(err)
+	}
+
+	enc.SetMaxOutputBytes(8)
+	if err := enc.Encode(strings.Repeat("a", 8)); !errors.Is(err, cbor.ErrOutputTooLarge) {
+		t.Fatalf("expected %v, got %v", cbor.ErrOutputTooLarge, err)
+	}
+	if err := enc.Encode(make([]int, 8)); !errors.Is(err, cbor.ErrOutputTooLarge) {
+		t.Fatalf("expected %v, got %v", cbor.ErrOutputTooLarge, err)
+	}
+	if err := enc.Encode(make([]int, 7)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Large strings written directly to the writer are checked first.
+	buf.Reset()
+	enc.SetMaxOutputBytes(64 << 10)
+	if err := enc.Encode([]string{strings.Repeat("a", 40<<10), strings.Repeat("b", 40<<10)}); !errors.Is(err, cbor.ErrOutputTooLarge) {
+		t.Fatalf("expected %v, got %v", cbor.ErrOutputTooLarge, err)
+	}
+	if buf.Len() > 64<<10 {
+		t.Fatalf("expected at most 64 KiB written, got %d bytes", buf.Len())
+	}
+}
+
+func TestNewEncoderBuffer(t *testing.T) {
+	enc := cbor.NewEncoderBuffer([]byte{0xd9, 0xd9, 0xf7}) // self-described CBOR
+	enc.EnableStats()
+	enc.SetMaxOutputBytes(4)
+	defer enc.SetMaxOutputBytes(0)
+
+	if err := enc.Encode(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode("abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cbor.EncodeSlice(enc, []int{2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A value which can't be encoded leaves the buffer as it was.
+	if err := enc.Encode("abcd"); !errors.Is(err, cbor.ErrOutputTooLarge) {
+		t.Fatalf("expected ErrOutputTooLarge, got %v", err)
+	}
+	if got, want := hex.EncodeToString(enc.Bytes()), "d9d9f7"+"01"+"63616263"+"820203"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if got := enc.Stats(); got.Items != 5 || got.Bytes != 8 {
+		t.Fatalf("unexpected stats %+v", got)
+	}
+
+	if cbor.NewEncoder(&bytes.Buffer{}).Bytes() != nil {
+		t.Fatal("expected no buffer for an encoder with a writer")
+	}
+}
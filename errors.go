@@ -0,0 +1,372 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by the decoding and encoding functions, possibly wrapped
+// with more details, so callers can check the class of a failure with
+// errors.Is.
+var (
+	// ErrTruncated is returned when the data ends in the middle of an
+	// item. It is io.ErrUnexpectedEOF, so errors wrapping either of them
+	// match both.
+	ErrTruncated = io.ErrUnexpectedEOF
+
+	// ErrMalformed is returned when the data isn't well-formed CBOR, such
+	// as when it has a reserved additional information value, or a break
+	// outside of an indefinite-length item.
+	ErrMalformed = errors.New("cbor: malformed data")
+
+	// ErrStringTooLong is returned when a text or byte string is longer
+	// than the decoder's MaxStringBytes or MaxBytes limit.
+	ErrStringTooLong = errors.New("cbor: string too long")
+
+	// ErrArrayTooLong is returned when an array has more elements than the
+	// decoder's MaxArrayElements limit.
+	ErrArrayTooLong = errors.New("cbor: array too long")
+
+	// ErrMapTooLong is returned when a map has more pairs than the
+	// decoder's MaxMapPairs limit.
+	ErrMapTooLong = errors.New("cbor: map too long")
+
+	// ErrMaxDepth is returned when items are nested deeper than the
+	// decoder's MaxDepth limit, or values deeper than the encoder's.
+	ErrMaxDepth = errors.New("cbor: exceeded max nesting depth")
+
+	// ErrUnknownField is returned when a map key doesn't match any field
+	// of the struct it's decoded into, and the decoder disallows unknown
+	// fields.
+	ErrUnknownField = errors.New("cbor: unknown field")
+
+	// ErrInvalidType is returned when an item can't be decoded into the
+	// type of the given value.
+	ErrInvalidType = errors.New("cbor: invalid type")
+
+	// ErrOutputTooLarge is returned when the encoding of a value is larger
+	// than the encoder's MaxOutputBytes limit.
+	ErrOutputTooLarge = errors.New("cbor: output too large")
+
+	// ErrInvalidFloat is returned when a float is rejected by the decoder's
+	// FloatChecks.
+	ErrInvalidFloat = errors.New("cbor: invalid float")
+
+	// ErrUnexportedField is returned when a struct field which would be
+	// skipped is unexported, and the encoder or decoder uses
+	// UnexportedFieldError.
+	ErrUnexportedField = errors.New("cbor: unexported field")
+)
+
+// A SyntaxError is returned by a Decoder when the data isn't well-formed
+// CBOR, such as when an item has a reserved additional information value
+// (28 to 30), or there's a break code outside of an indefinite-length
+// item. It wraps ErrMalformed.
+type SyntaxError struct {
+	msg string
+
+	// Offset is the offset in the input of the initial byte of the item
+	// which isn't well-formed.
+	Offset int64
+}
+
+// Error implements the error interface.
+func (e *SyntaxError) Error() string {
+	return "cbor: " + e.msg
+}
+
+// Unwrap returns ErrMalformed.
+func (e *SyntaxError) Unwrap() error {
+	return ErrMalformed
+}
+
+// syntaxError returns a SyntaxError for the item whose initial byte was
+// just read.
+func (dec *Decoder) syntaxError(msg string) error {
+	return &SyntaxError{msg: msg, Offset: dec.offset() - 1}
+}
+
+// wrappedError is an error with its own message, which wraps one of the
+// errors above so it can be matched with errors.Is.
+type wrappedError struct {
+	msg string
+	err error
+}
+
+// newError returns an error with the given message, wrapping err.
+func newError(err error, msg string) error {
+	return &wrappedError{msg: msg, err: err}
+}
+
+// Error implements the error interface.
+func (e *wrappedError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the wrapped error.
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
+
+// An UnmarshalTypeError describes an item which can't be decoded into a
+// value of a Go type. It wraps ErrInvalidType.
+type UnmarshalTypeError struct {
+	// Value describes the item, such as "uint" or "float 1.5".
+	Value string
+
+	// Type is the type of the value it can't be decoded into.
+	Type reflect.Type
+}
+
+// Error implements the error interface.
+func (e *UnmarshalTypeError) Error() string {
+	return "cbor: cannot unmarshal " + e.Value + " into " + e.Type.String()
+}
+
+// Unwrap returns ErrInvalidType.
+func (e *UnmarshalTypeError) Unwrap() error {
+	return ErrInvalidType
+}
+
+// unexportedFieldError returns the error for the unexported field name of
+// the struct type t, with UnexportedFieldError.
+func unexportedFieldError(t reflect.Type, name string) error {
+	return newError(ErrUnexportedField, "cbor: unexported field "+name+" in "+t.String())
+}
+
+// typeError returns an UnmarshalTypeError for an item of the given kind,
+// such as "uint", which can't be decoded into t.
+func typeError(what string, t reflect.Type) error {
+	return &UnmarshalTypeError{Value: what, Type: t}
+}
+
+// A DecodeError is returned by Decode and Unmarshal when an item can't be
+// decoded. It gives the path to the value being decoded, such as
+// "Claims.Aud" or "Items[3]", and the offset in the input, which helps to
+// find the offending item in large documents.
+type DecodeError struct {
+	// Path is the path to the value being decoded, starting with the name
+	// of the type passed to Decode, followed by struct field names, and
+	// slice, array and map indices. Paths of more than maxPathElems
+	// elements have their middle elided with "…", such as in
+	// "interface {}[0][0]…[0]".
+	Path string
+
+	// Offset is the number of bytes read from the input when the error
+	// occurred.
+	Offset int64
+
+	// Snippet is the input around Offset, starting at SnippetOffset, if
+	// it's available. See Decoder.SetErrorContext.
+	Snippet       []byte
+	SnippetOffset int64
+
+	// Err is the underlying error, which may wrap one of the errors above.
+	Err error
+
+	// elems is the number of elements prepended to Path. Once there are
+	// more than maxPathElems, inner is the innermost half of them, and
+	// outer the outermost half so far, innermost first.
+	elems int
+	inner string
+	outer []string
+}
+
+// maxPathElems is the maximum number of elements in the Path of a
+// DecodeError, beyond which its middle is elided, so errors in deeply
+// nested items stay short, and cheap to build as they're returned.
+const maxPathElems = 16
+
+// prependPath prepends elem to the path of e, eliding its middle if it
+// has more than maxPathElems elements.
+func (e *DecodeError) prependPath(elem string) {
+	if elem == "" {
+		return
+	}
+	e.elems++
+	if e.elems > maxPathElems/2 {
+		e.outer = append(e.outer, elem)
+		if len(e.outer) > maxPathElems/2 {
+			e.outer = append(e.outer[:0], e.outer[1:]...)
+		}
+	}
+	if e.elems <= maxPathElems {
+		e.Path = elem + e.Path
+		if e.elems == maxPathElems/2 {
+			e.inner = e.Path
+		}
+		return
+	}
+
+	var b strings.Builder
+	for i := len(e.outer) - 1; i >= 0; i-- {
+		b.WriteString(e.outer[i])
+	}
+	b.WriteString("…")
+	b.WriteString(e.inner)
+	e.Path = b.String()
+}
+
+// Error implements the error interface. The snippet, if any, is written
+// in hex, with a "|" at the offset of the error, such as in
+// "(offset 12, near 6341756401|)".
+func (e *DecodeError) Error() string {
+	msg := strings.TrimPrefix(e.Err.Error(), "cbor: ")
+	if e.Path != "" {
+		msg = e.Path + ": " + msg
+	}
+	msg = "cbor: " + msg + " (offset " + strconv.FormatInt(e.Offset, 10)
+	if n := e.Offset - e.SnippetOffset; len(e.Snippet) > 0 && n >= 0 && n <= int64(len(e.Snippet)) {
+		msg += ", near " + hex.EncodeToString(e.Snippet[:n]) + "|" + hex.EncodeToString(e.Snippet[n:])
+	}
+	return msg + ")"
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// pathError returns err with elem prepended to its path, where elem is
+// the part of the path from the enclosing value to the one which failed
+// to decode, such as ".Aud" or "[3]". Paths are only built as errors are
+// returned, so decoding valid data doesn't pay for them.
+func (dec *Decoder) pathError(err error, elem string) error {
+	if e, ok := err.(*DecodeError); ok {
+		e.prependPath(elem)
+		return e
+	}
+	e := &DecodeError{Offset: dec.offset(), Err: err}
+	e.prependPath(elem)
+	if n := dec.options.ErrorContext; n > 0 && dec.mem {
+		start, end := dec.off-n, dec.off+n
+		if start < 0 {
+			start = 0
+		}
+		if end > len(dec.data) {
+			end = len(dec.data)
+		}
+		e.Snippet = append([]byte(nil), dec.data[start:end]...)
+		e.SnippetOffset = e.Offset - int64(dec.off-start)
+	}
+	return e
+}
+
+// DecodeErrors is returned by a Decoder in partial mode, set with
+// SetPartial, when fields of the value couldn't be decoded. The rest of
+// the value is decoded as usual. The last error may be one which ended
+// decoding, such as ErrTruncated.
+type DecodeErrors []error
+
+// Error implements the error interface.
+func (e DecodeErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = strings.TrimPrefix(err.Error(), "cbor: ")
+	}
+	return "cbor: " + strconv.Itoa(len(e)) + " errors: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors, so errors.Is and errors.As match any of them.
+func (e DecodeErrors) Unwrap() []error {
+	return e
+}
+
+// skipPartial records err, from decoding the item whose initial byte b
+// was read just before start, and skips the item, if the decoder is in
+// partial mode and reading from memory. It reports whether decoding can
+// go on, which it can't if the item itself is malformed or truncated.
+func (dec *Decoder) skipPartial(err error, start int, b byte) bool {
+	if !dec.options.Partial || !dec.mem {
+		return false
+	}
+	dec.off = start
+	if _, serr := dec.appendRawItem(nil, b, dec.depth); serr != nil {
+		return false
+	}
+	dec.partial = append(dec.partial, err)
+	return true
+}
+
+// partialPath prepends elem to the paths of the errors recorded by
+// skipPartial since there were mark of them.
+func (dec *Decoder) partialPath(mark int, elem string) {
+	for _, err := range dec.partial[mark:] {
+		dec.pathError(err, elem)
+	}
+}
+
+// offset returns the number of bytes read by the decoder.
+func (dec *Decoder) offset() int64 {
+	var n int64
+	if dec.stats != nil {
+		n = int64(dec.stats.stats.Bytes)
+	}
+	if dec.mem {
+		n += int64(dec.off - dec.synced)
+	}
+	return n
+}
+
+// An InvalidUnmarshalError describes an invalid argument passed to
+// Unmarshal or Decode. The argument must be a non-nil pointer.
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+// Error implements the error interface.
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "cbor: Unmarshal(nil)"
+	}
+	if e.Type.Kind() != reflect.Ptr {
+		return "cbor: Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "cbor: Unmarshal(nil " + e.Type.String() + ")"
+}
+
+// An UnsupportedTypeError is returned by Marshal and Encode when attempting
+// to encode a value of an unsupported type, such as a channel or a
+// function.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+// Error implements the error interface.
+func (e *UnsupportedTypeError) Error() string {
+	return "cbor: unsupported type: " + e.Type.String()
+}
+
+// An UnsupportedValueError is returned by Marshal and Encode when attempting
+// to encode a value which can't be represented, even though its type is
+// supported.
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Str   string
+}
+
+// Error implements the error interface.
+func (e *UnsupportedValueError) Error() string {
+	return "cbor: unsupported value: " + e.Str
+}
+
+// A StructTagError is returned by CheckStructTags for an invalid cbor
+// struct tag.
+type StructTagError struct {
+	Type  reflect.Type
+	Field string
+	Tag   string
+	msg   string
+}
+
+// Error implements the error interface.
+func (e *StructTagError) Error() string {
+	return "cbor: invalid tag of field " + e.Field + " of " + e.Type.String() + ": " + e.msg
+}
@@ -0,0 +1,183 @@
+package cbor
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"time"
+)
+
+// TagExtendedTime is the tag of extended times (RFC 9581), whose content is
+// a map with the number of seconds since the Unix epoch at key 1, fractions
+// of a second at the negated base-10 exponent of their unit, and the time
+// scale at key -1, such as {1: 1363896240, -3: 500} for half a second past
+// 1363896240.
+const TagExtendedTime = 1001
+
+// TimeScale is the time scale of an ExtendedTime, from the registry of
+// RFC 9581.
+type TimeScale uint64
+
+const (
+	// TimeScaleUTC is Coordinated Universal Time, the default.
+	TimeScaleUTC TimeScale = 0
+
+	// TimeScaleTAI is International Atomic Time, which counts the leap
+	// seconds that UTC leaves out.
+	TimeScaleTAI TimeScale = 1
+)
+
+// ExtendedTime is a time encoded with TagExtendedTime, which can be more
+// precise than a time.Time, down to the attosecond, and use another time
+// scale than UTC.
+//
+// Times with tag 0 or 1, or without a tag, can also be decoded into an
+// ExtendedTime, and extended times in UTC into a time.Time, dropping any
+// fraction of a nanosecond.
+type ExtendedTime struct {
+	// Time is the time, to the nanosecond. For times in TAI, it counts TAI
+	// seconds since the epoch, without converting them to UTC.
+	Time time.Time
+
+	// Attoseconds is the fraction of a nanosecond after Time, from 0 to
+	// 999999999.
+	Attoseconds uint32
+
+	// TimeScale is the time scale of the time.
+	TimeScale TimeScale
+}
+
+// extendedTimeType is the reflect.Type of ExtendedTime.
+var extendedTimeType = reflect.TypeOf(ExtendedTime{})
+
+// encodeExtendedTime writes an ExtendedTime with TagExtendedTime. The
+// fraction of a second is written in the largest unit that represents it
+// exactly, so 1.5 seconds is written as {1: 1, -3: 500}.
+func encodeExtendedTime(e *Encoder, v reflect.Value) error {
+	t := v.Interface().(ExtendedTime)
+	if t.Attoseconds >= 1e9 {
+		return errors.New("cbor: extended time attoseconds out of range")
+	}
+
+	atto := uint64(t.Time.Nanosecond())*1e9 + uint64(t.Attoseconds)
+	key, frac := 0, uint64(0)
+	if atto != 0 {
+		for key = -3; atto%pow10u(18+key) != 0; key -= 3 {
+		}
+		frac = atto / pow10u(18+key)
+	}
+
+	n := 1
+	if t.TimeScale != TimeScaleUTC {
+		n++
+	}
+	if frac != 0 {
+		n++
+	}
+
+	// Keys are written in the order of their encodings, so times are
+	// encoded deterministically.
+	e.buf = AppendTag(e.buf, TagExtendedTime)
+	e.buf = AppendMapHeader(e.buf, n)
+	e.buf = AppendInt(AppendUint(e.buf, 1), t.Time.Unix())
+	if t.TimeScale != TimeScaleUTC {
+		e.buf = AppendUint(AppendInt(e.buf, -1), uint64(t.TimeScale))
+	}
+	if frac != 0 {
+		e.buf = AppendUint(AppendInt(e.buf, int64(key)), frac)
+	}
+	return nil
+}
+
+// decodeExtendedTime decodes an item into an ExtendedTime. Items without
+// TagExtendedTime are decoded like a time.Time, in UTC.
+func decodeExtendedTime(dec *Decoder, rv reflect.Value, b byte) error {
+	t, err := dec.readTime(rv.Type(), b)
+	if err != nil {
+		return err
+	}
+	rv.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// readExtendedTime reads the content of an item with TagExtendedTime.
+//
+// The base time must be integer or floating-point seconds at key 1, and
+// fractions of a second may be given in milliseconds (-3) down to
+// attoseconds (-18). Unknown critical keys, which are negative, are an
+// error, and unknown elective keys are ignored.
+func (dec *Decoder) readExtendedTime() (ExtendedTime, error) {
+	var m map[int64]interface{}
+	if err := dec.decodeValue(reflect.ValueOf(&m).Elem()); err != nil {
+		return ExtendedTime{}, err
+	}
+
+	var (
+		t     ExtendedTime
+		secs  int64
+		atto  uint64
+		based bool
+	)
+	for key, v := range m {
+		switch {
+		case key == 1:
+			switch v := v.(type) {
+			case uint64:
+				if v > math.MaxInt64 {
+					return t, newError(ErrInvalidType, "cbor: extended time overflows int64")
+				}
+				secs = int64(v)
+			case int64:
+				secs = v
+			case float64:
+				if math.IsNaN(v) || math.IsInf(v, 0) || math.Abs(v) >= math.MaxInt64 {
+					return t, newError(ErrInvalidType, "cbor: invalid extended time")
+				}
+				s, frac := math.Modf(v)
+				if frac < 0 {
+					s, frac = s-1, frac+1
+				}
+				secs = int64(s)
+				atto += uint64(frac*1e9) * 1e9
+			default:
+				return t, newError(ErrInvalidType, "cbor: invalid extended time base time")
+			}
+			based = true
+		case key == 4 || key == 5:
+			return t, newError(ErrInvalidType, "cbor: unsupported extended time base time key "+itoa(key))
+		case key == -1:
+			scale, ok := v.(uint64)
+			if !ok || (TimeScale(scale) != TimeScaleUTC && TimeScale(scale) != TimeScaleTAI) {
+				return t, newError(ErrInvalidType, "cbor: unsupported extended time scale")
+			}
+			t.TimeScale = TimeScale(scale)
+		case key <= -3 && key >= -18 && key%3 == 0:
+			n, ok := v.(uint64)
+			if !ok || n >= pow10u(int(-key)) {
+				return t, newError(ErrInvalidType, "cbor: invalid extended time fraction at key "+itoa(key))
+			}
+			atto += n * pow10u(int(18+key))
+		case key < 0:
+			return t, newError(ErrInvalidType, "cbor: unsupported extended time key "+itoa(key))
+		}
+	}
+	if !based {
+		return t, newError(ErrInvalidType, "cbor: extended time has no base time")
+	}
+	if atto >= 1e18 {
+		return t, newError(ErrInvalidType, "cbor: extended time fraction out of range")
+	}
+
+	t.Time = time.Unix(secs, int64(atto/1e9))
+	t.Attoseconds = uint32(atto % 1e9)
+	return t, nil
+}
+
+// pow10u returns 10 to the power of n, for n from 0 to 19.
+func pow10u(n int) uint64 {
+	v := uint64(1)
+	for ; n > 0; n-- {
+		v *= 10
+	}
+	return v
+}
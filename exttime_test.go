@@ -0,0 +1,108 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+func TestExtendedTime(t *testing.T) {
+	base := time.Unix(1363896240, 0)
+
+	tests := []struct {
+		t    cbor.ExtendedTime
+		want string
+	}{
+		{cbor.ExtendedTime{Time: base}, "d903e9" + "a1011a514b67b0"},
+		{cbor.ExtendedTime{Time: base.Add(500 * time.Millisecond)}, "d903e9" + "a2011a514b67b0" + "221901f4"},
+		{cbor.ExtendedTime{Time: base.Add(1500 * time.Nanosecond)}, "d903e9" + "a2011a514b67b0" + "281905dc"},
+		{cbor.ExtendedTime{Time: base, Attoseconds: 1}, "d903e9" + "a2011a514b67b0" + "3101"},
+		{cbor.ExtendedTime{Time: base, TimeScale: cbor.TimeScaleTAI}, "d903e9" + "a2011a514b67b0" + "2001"},
+	}
+
+	for _, test := range tests {
+		data, err := cbor.Marshal(test.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(data); got != test.want {
+			t.Fatalf("expected %s, got %s", test.want, got)
+		}
+
+		var got cbor.ExtendedTime
+		if err := cbor.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.Time.Equal(test.t.Time) || got.Attoseconds != test.t.Attoseconds || got.TimeScale != test.t.TimeScale {
+			t.Fatalf("expected %+v, got %+v", test.t, got)
+		}
+
+		var v interface{}
+		if err := cbor.Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+		if et, ok := v.(cbor.ExtendedTime); !ok || !et.Time.Equal(test.t.Time) {
+			t.Fatalf("expected %+v, got %#v", test.t, v)
+		}
+	}
+}
+
+func TestExtendedTime_decode(t *testing.T) {
+	// Extended times in UTC decode into a time.Time, dropping fractions
+	// of a nanosecond.
+	var tm time.Time
+	if err := cbor.Unmarshal(decodeHex(t, "d903e9a3011a514b67b0221901f43101"), &tm); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1363896240, 500*int64(time.Millisecond)); !tm.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, tm)
+	}
+	if err := cbor.Unmarshal(decodeHex(t, "d903e9a2011a514b67b02001"), &tm); err == nil {
+		t.Fatal("expected error decoding a time in TAI into a time.Time")
+	}
+
+	// Other times decode into an ExtendedTime, as do floating-point base
+	// times.
+	var et cbor.ExtendedTime
+	if err := cbor.Unmarshal(decodeHex(t, "c11a514b67b0"), &et); err != nil {
+		t.Fatal(err)
+	}
+	if !et.Time.Equal(time.Unix(1363896240, 0)) {
+		t.Fatalf("unexpected time %v", et.Time)
+	}
+	if err := cbor.Unmarshal(decodeHex(t, "d903e9a101fbc000000000000000"), &et); err != nil {
+		t.Fatal(err)
+	}
+	if !et.Time.Equal(time.Unix(-2, 0)) {
+		t.Fatalf("unexpected time %v", et.Time)
+	}
+
+	// Elective keys are ignored, while critical ones are an error.
+	if err := cbor.Unmarshal(decodeHex(t, "d903e9a2011a514b67b00a00"), &et); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []string{
+		"d903e9a0",                                 // no base time
+		"d903e9a2011a514b67b02100",                 // critical key -2
+		"d903e9a2011a514b67b0221903e8",             // 1000 milliseconds
+		"d903e9a2011a514b67b02002",                 // unknown time scale
+		"d903e9a2011a514b67b022fb3fe0000000000000", // fraction is a float
+	} {
+		if err := cbor.Unmarshal(decodeHex(t, bad), &et); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+// decodeHex decodes a hex string, failing the test if it's invalid.
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
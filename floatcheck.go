@@ -0,0 +1,58 @@
+package cbor
+
+import (
+	"fmt"
+)
+
+// FloatChecks are checks a Decoder makes on floats, for the requirements
+// of profiles such as dCBOR (draft-mcnally-deterministic-cbor), which may
+// be combined with |.
+type FloatChecks int
+
+const (
+	// RejectNaNPayloads rejects NaNs other than the quiet NaN without a
+	// payload or sign, whose encodings are 0xf97e00, 0xfa7fc00000, and
+	// 0xfb7ff8000000000000, since payloads can carry data which is lost
+	// when the NaN is converted or compared.
+	RejectNaNPayloads FloatChecks = 1 << iota
+
+	// RejectSubnormalFloat16 rejects subnormal half-precision floats,
+	// which some implementations don't decode exactly.
+	RejectSubnormalFloat16
+
+	// RejectFloatIntegers rejects floats decoded into integer types, even
+	// when they're whole numbers, for profiles which require integers to
+	// be encoded as integers.
+	RejectFloatIntegers
+
+	// StrictFloats are all the checks.
+	StrictFloats = RejectNaNPayloads | RejectSubnormalFloat16 | RejectFloatIntegers
+)
+
+// checkFloat checks the bits n of a float of the given size in bytes
+// against the FloatChecks of the decoder.
+func (dec *Decoder) checkFloat(n uint64, size int) error {
+	checks := dec.options.FloatChecks
+	if checks&(RejectNaNPayloads|RejectSubnormalFloat16) == 0 {
+		return nil
+	}
+
+	var nan bool
+	var quietNaN uint64
+	switch size {
+	case 2:
+		exp, frac := n>>10&0x1f, n&0x3ff
+		if checks&RejectSubnormalFloat16 != 0 && exp == 0 && frac != 0 {
+			return newError(ErrInvalidFloat, fmt.Sprintf("cbor: subnormal float16 0x%04x", n))
+		}
+		nan, quietNaN = exp == 0x1f && frac != 0, 0x7e00
+	case 4:
+		nan, quietNaN = n>>23&0xff == 0xff && n&(1<<23-1) != 0, 0x7fc00000
+	default:
+		nan, quietNaN = n>>52&0x7ff == 0x7ff && n&(1<<52-1) != 0, 0x7ff8000000000000
+	}
+	if checks&RejectNaNPayloads != 0 && nan && n != quietNaN {
+		return newError(ErrInvalidFloat, fmt.Sprintf("cbor: NaN with payload 0x%0*x", 2*size, n))
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Package fuzz provides helpers for fuzzing the encoding and decoding of
+// Go types with the cbor package, using go test -fuzz.
+//
+// A fuzz test of a type checks that whatever it decodes can be encoded and
+// decoded again without changing, starting from the examples of RFC 8949:
+//
+//	func FuzzClaims(f *testing.F) {
+//		for _, seed := range fuzz.Seeds() {
+//			f.Add(seed)
+//		}
+//		f.Fuzz(func(t *testing.T, data []byte) {
+//			if err := fuzz.RoundTrip[Claims](data); err != nil {
+//				t.Fatal(err)
+//			}
+//		})
+//	}
+//
+// Compare checks the cbor package against another decoder in the same way.
+package fuzz
+
+import (
+	"fmt"
+
+	"github.com/picatz/cbor"
+	"github.com/picatz/cbor/testvectors"
+)
+
+// extraSeeds are encoded items added to the Appendix A vectors in Seeds,
+// which exercise nesting, indefinite lengths and malformed input.
+var extraSeeds = [][]byte{
+	// {"a": [1, {"b": h'01'}], 2: [_ "x", 1.5]}
+	{0xa2, 0x61, 'a', 0x82, 0x01, 0xa1, 0x61, 'b', 0x41, 0x01, 0x02, 0x9f, 0x61, 'x', 0xf9, 0x3e, 0x00, 0xff},
+	// {_ "k": (_ h'01', h'')}
+	{0xbf, 0x61, 'k', 0x5f, 0x41, 0x01, 0x40, 0xff, 0xff},
+	// A CBOR sequence of three items.
+	{0x01, 0xf6, 0x80},
+	// Truncated, a stray break, and a reserved additional information.
+	{0x82, 0x01},
+	{0xff},
+	{0x1c},
+}
+
+// Seeds returns encoded CBOR items for the corpus of a fuzz test: the
+// examples of Appendix A of RFC 8949, and some items which are nested,
+// have indefinite lengths, or are malformed.
+//
+// Each call returns new slices, which the caller may modify.
+func Seeds() [][]byte {
+	vectors := testvectors.AppendixA()
+	seeds := make([][]byte, 0, len(vectors)+len(extraSeeds))
+	for _, v := range vectors {
+		seeds = append(seeds, v.CBOR)
+	}
+	for _, s := range extraSeeds {
+		seeds = append(seeds, append([]byte(nil), s...))
+	}
+	return seeds
+}
+
+// RoundTrip decodes data into a new value of type T, encodes it, and
+// checks that decoding and encoding the result again gives an equal
+// encoding, as reported by cbor.Equal. Data which can't be decoded into a
+// T is ignored, since most fuzzed input is invalid, so the returned error
+// describes a value which decodes but doesn't round-trip.
+func RoundTrip[T any](data []byte) error {
+	var v T
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+	first, err := cbor.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("fuzz: cannot encode %#v decoded from %x: %w", v, data, err)
+	}
+
+	var again T
+	if err := cbor.Unmarshal(first, &again); err != nil {
+		return fmt.Errorf("fuzz: cannot decode %x, the encoding of %#v decoded from %x: %w", first, v, data, err)
+	}
+	second, err := cbor.Marshal(again)
+	if err != nil {
+		return fmt.Errorf("fuzz: cannot encode %#v decoded from %x: %w", again, first, err)
+	}
+	if !cbor.Equal(first, second) {
+		return fmt.Errorf("fuzz: %x decoded from %x and encoded again as %x", first, data, second)
+	}
+	return nil
+}
+
+// Compare decodes data into new values of type T with cbor.Unmarshal and
+// with unmarshal, a reference decoder such as the Unmarshal function of
+// another CBOR package, and checks that they agree: either both return an
+// error, or the values they decode are equal when encoded by the cbor
+// package.
+func Compare[T any](data []byte, unmarshal func(data []byte, v interface{}) error) error {
+	var got, want T
+	gotErr, wantErr := cbor.Unmarshal(data, &got), unmarshal(data, &want)
+	switch {
+	case gotErr != nil && wantErr != nil:
+		return nil
+	case gotErr != nil:
+		return fmt.Errorf("fuzz: cbor.Unmarshal rejected %x, which the reference decoded as %#v: %w", data, want, gotErr)
+	case wantErr != nil:
+		return fmt.Errorf("fuzz: cbor.Unmarshal decoded %x as %#v, which the reference rejected: %v", data, got, wantErr)
+	}
+
+	a, err := cbor.Marshal(got)
+	if err != nil {
+		return fmt.Errorf("fuzz: cannot encode %#v decoded from %x: %w", got, data, err)
+	}
+	b, err := cbor.Marshal(want)
+	if err != nil {
+		return fmt.Errorf("fuzz: cannot encode %#v decoded from %x by the reference: %w", want, data, err)
+	}
+	if !cbor.Equal(a, b) {
+		return fmt.Errorf("fuzz: cbor.Unmarshal decoded %x as %#v, and the reference as %#v", data, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package fuzz_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/picatz/cbor"
+	"github.com/picatz/cbor/fuzz"
+)
+
+type record struct {
+	_     struct{} `cbor:",intkeys"`
+	ID    uint64
+	Name  string
+	Tags  []string `cbor:",omitempty"`
+	Score float64
+	Attrs map[string]interface{}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	for _, seed := range fuzz.Seeds() {
+		f.Add(seed)
+	}
+	f.Add(cbor.MustMarshal(record{ID: 1, Name: "a", Tags: []string{"x"}, Score: 0.5}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := fuzz.RoundTrip[interface{}](data); err != nil {
+			t.Fatal(err)
+		}
+		if err := fuzz.RoundTrip[record](data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, seed := range fuzz.Seeds() {
+		if err := fuzz.RoundTrip[interface{}](seed); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	data := []byte{0x82, 0x01, 0x02}
+
+	same := func(data []byte, v interface{}) error { return cbor.Unmarshal(data, v) }
+	if err := fuzz.Compare[[]int](data, same); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reference decoding a different value, or rejecting the input, is
+	// reported.
+	other := func(data []byte, v interface{}) error { return json.Unmarshal([]byte("[1, 3]"), v) }
+	if err := fuzz.Compare[[]int](data, other); err == nil {
+		t.Fatal("expected error for different values")
+	}
+	reject := func([]byte, interface{}) error { return errors.New("rejected") }
+	if err := fuzz.Compare[[]int](data, reject); err == nil {
+		t.Fatal("expected error for rejected input")
+	}
+	if err := fuzz.Compare[[]int]([]byte{0xff}, reject); err != nil {
+		t.Fatal(err)
+	}
+}
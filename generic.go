@@ -0,0 +1,376 @@
+package cbor
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// planKind is the strategy used to encode and decode values of a type.
+type planKind uint8
+
+const (
+	// planReflect uses the reflection-based Encoder and Decoder.
+	planReflect planKind = iota
+	planBool
+	planInt
+	planUint
+	planFloat
+	planString
+	planBytes
+)
+
+// typePlan is the cached strategy for encoding and decoding values of a
+// type with MarshalT and UnmarshalT.
+type typePlan struct {
+	kind planKind
+
+	// marshaler is set if the type implements Marshaler.
+	marshaler bool
+
+	// unmarshaler is set if a pointer to the type implements Unmarshaler.
+	unmarshaler bool
+}
+
+// typePlanCache is a cache of typePlans, keyed by reflect.Type.
+var typePlanCache sync.Map
+
+// loadTypePlan returns the typePlan for t, computing it on first use.
+func loadTypePlan(t reflect.Type) *typePlan {
+	if v, ok := typePlanCache.Load(t); ok {
+		return v.(*typePlan)
+	}
+
+	p := &typePlan{
+		marshaler:   t.Implements(marshalerType),
+		unmarshaler: reflect.PtrTo(t).Implements(unmarshalerType),
+	}
+
+	// Durations and times are encoded according to the options of the
+	// Encoder, json.RawMessage values are transcoded, and ByteString
+	// values are byte strings, so they are left to it.
+	kind := t.Kind()
+	if t == durationType || t == timeType || t == jsonRawMessageType || t == byteStringType {
+		kind = reflect.Invalid
+	}
+
+	switch kind {
+	case reflect.Bool:
+		p.kind = planBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		p.kind = planInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		p.kind = planUint
+	case reflect.Float32, reflect.Float64:
+		p.kind = planFloat
+	case reflect.String:
+		p.kind = planString
+	case reflect.Slice:
+		// Named byte slice types are encoded according to the
+		// ByteSliceMode of the Encoder.
+		if t.Elem().Kind() == reflect.Uint8 && t.Name() == "" {
+			p.kind = planBytes
+		}
+	}
+
+	v, _ := typePlanCache.LoadOrStore(t, p)
+	return v.(*typePlan)
+}
+
+// typeOf returns the reflect.Type of T, including interface types.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// MarshalT returns the CBOR encoding of v, like Marshal.
+//
+// The strategy for encoding values of type T is computed on first use and
+// cached, so booleans, integers, floats, strings, byte slices and types
+// implementing Marshaler are encoded without going through an Encoder.
+func MarshalT[T any](v T) ([]byte, error) {
+	t := typeOf[T]()
+	if t.Kind() == reflect.Interface || t.Kind() == reflect.Ptr {
+		return Marshal(v)
+	}
+
+	_, typeFunc := DefaultEncoderOptions.TypeEncoders[t]
+	_, transform := DefaultEncoderOptions.TypeTransforms[t]
+	if typeFunc || transform {
+		return Marshal(v)
+	}
+
+	p := loadTypePlan(t)
+	if p.marshaler {
+		return marshalItem(any(v).(Marshaler))
+	}
+
+	switch p.kind {
+	case planBool:
+		return AppendBool(nil, reflect.ValueOf(v).Bool()), nil
+	case planInt:
+		return AppendInt(nil, reflect.ValueOf(v).Int()), nil
+	case planUint:
+		return AppendUint(nil, reflect.ValueOf(v).Uint()), nil
+	case planFloat:
+		f := reflect.ValueOf(v).Float()
+		if f == 0 && DefaultEncoderOptions.NegativeZero == NegativeZeroNormalize {
+			f = 0
+		}
+		return AppendFloat64(nil, f), nil
+	case planString:
+		rv := reflect.ValueOf(v)
+		return AppendString(make([]byte, 0, rv.Len()+9), rv.String()), nil
+	case planBytes:
+		rv := reflect.ValueOf(v)
+		return AppendBytes(make([]byte, 0, rv.Len()+9), rv.Bytes()), nil
+	}
+
+	return Marshal(v)
+}
+
+// UnmarshalT decodes the CBOR item in data into a new value of type T, like
+// Unmarshal.
+//
+// The strategy for decoding values of type T is computed on first use and
+// cached, so booleans, integers, floats, strings, byte slices and types
+// implementing Unmarshaler are decoded without going through a Decoder.
+// Other types, and items that don't match the kind of T, fall back to
+// Unmarshal. The limits in DefaultDecoderOptions apply in both cases.
+func UnmarshalT[T any](data []byte) (T, error) {
+	var v T
+
+	t := typeOf[T]()
+	if t.Kind() == reflect.Interface || t.Kind() == reflect.Ptr {
+		err := Unmarshal(data, &v)
+		return v, err
+	}
+
+	if _, ok := DefaultDecoderOptions.TypeDecoders[t]; ok {
+		err := Unmarshal(data, &v)
+		return v, err
+	}
+
+	p := loadTypePlan(t)
+	if p.unmarshaler {
+		n, err := itemLength(data, 0)
+		if err != nil {
+			return v, err
+		}
+		err = any(&v).(Unmarshaler).UnmarshalCBOR(data[:n])
+		return v, err
+	}
+
+	if p.kind != planReflect && len(data) > 0 {
+		ok, err := unmarshalPlan(p.kind, data, reflect.ValueOf(&v).Elem())
+		if ok || err != nil {
+			return v, err
+		}
+	}
+
+	err := Unmarshal(data, &v)
+	return v, err
+}
+
+// unmarshalPlan decodes data into rv using the fast path for kind. It
+// reports false if the item doesn't match the kind, in which case rv is
+// left unchanged.
+func unmarshalPlan(kind planKind, data []byte, rv reflect.Value) (bool, error) {
+	mt := MajorType(data[0] >> 5)
+
+	switch kind {
+	case planBool:
+		if data[0] != 0xf4 && data[0] != 0xf5 {
+			return false, nil
+		}
+		rv.SetBool(data[0] == 0xf5)
+	case planInt:
+		if mt != MajorTypeUnsignedInt && mt != MajorTypeNegativeInt {
+			return false, nil
+		}
+		n, _, err := ReadInt(data)
+		if err != nil {
+			return true, err
+		}
+		if rv.OverflowInt(n) {
+			return true, newError(ErrInvalidType, fmt.Sprintf("cbor: cannot unmarshal %d into %s", n, rv.Type()))
+		}
+		rv.SetInt(n)
+	case planUint:
+		if mt != MajorTypeUnsignedInt {
+			return false, nil
+		}
+		n, _, err := ReadUint(data)
+		if err != nil {
+			return true, err
+		}
+		if rv.OverflowUint(n) {
+			return true, newError(ErrInvalidType, fmt.Sprintf("cbor: cannot unmarshal %d into %s", n, rv.Type()))
+		}
+		rv.SetUint(n)
+	case planFloat:
+		if data[0] < 0xf9 || data[0] > 0xfb || DefaultDecoderOptions.FloatChecks != 0 {
+			return false, nil
+		}
+		f, _, err := ReadFloat64(data)
+		if err != nil {
+			return true, err
+		}
+		if f == 0 && DefaultDecoderOptions.NegativeZero == NegativeZeroNormalize {
+			f = 0
+		}
+		rv.SetFloat(f)
+	case planString:
+		if mt != MajorTypeTextString {
+			return false, nil
+		}
+		s, _, err := ReadString(data)
+		if err != nil {
+			return true, err
+		}
+		if len(s) > DefaultDecoderOptions.MaxStringBytes {
+			return true, newError(ErrStringTooLong, fmt.Sprintf("cbor: string length %d exceeds max of %d", len(s), DefaultDecoderOptions.MaxStringBytes))
+		}
+		rv.SetString(s)
+	case planBytes:
+		if mt != MajorTypeByteString {
+			return false, nil
+		}
+		b, _, err := ReadBytes(data)
+		if err != nil {
+			return true, err
+		}
+		if len(b) > DefaultDecoderOptions.MaxBytes {
+			return true, newError(ErrStringTooLong, fmt.Sprintf("cbor: byte string length %d exceeds max of %d", len(b), DefaultDecoderOptions.MaxBytes))
+		}
+		rv.SetBytes(b)
+	default:
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// MarshalSlice returns the CBOR encoding of s, like Marshal, using
+// EncodeSlice.
+func MarshalSlice[T any](s []T) ([]byte, error) {
+	e := getEncoder()
+	defer putEncoder(e)
+
+	if err := encodeSlice(e, s); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.buf...), nil
+}
+
+// EncodeSlice writes the CBOR encoding of s to the encoder, like e.Encode(s).
+//
+// The strategy for encoding elements of type T is computed on first use
+// and cached, so the elements of slices of booleans, integers, floats,
+// strings, byte slices and types implementing Marshaler are appended to a
+// buffer without being converted to interface{} values, and the elements
+// of other slices are encoded with the compiled encoder of their type.
+func EncodeSlice[T any](e *Encoder, s []T) error {
+	start := len(e.buf)
+	if err := encodeSlice(e, s); err != nil {
+		e.buf = e.buf[:start]
+		return err
+	}
+	return e.finish(start)
+}
+
+// encodeSlice appends the CBOR encoding of s to the encoder's buffer.
+func encodeSlice[T any](e *Encoder, s []T) error {
+	e.written = -len(e.buf)
+	if err := appendSlice(e, s); err != nil {
+		return err
+	}
+	return e.checkOutput(0)
+}
+
+// appendSlice appends the CBOR encoding of s to the encoder's buffer,
+// using the fast paths for common element types.
+func appendSlice[T any](e *Encoder, s []T) error {
+	t := typeOf[T]()
+	if t.Kind() == reflect.Uint8 {
+		// Byte slices are encoded as byte strings.
+		return e.writeBytes(reflect.ValueOf(s).Bytes())
+	}
+
+	e.buf = appendHeader(e.buf, MajorTypeArray, uint64(len(s)))
+	if err := e.nest(); err != nil {
+		return err
+	}
+	defer e.unnest()
+
+	p := loadTypePlan(t)
+	_, typeFunc := e.options.TypeEncoders[t]
+	_, transform := e.options.TypeTransforms[t]
+	normalize := p.kind == planFloat && e.options.NegativeZero == NegativeZeroNormalize
+	if t.Kind() == reflect.Interface || t.Kind() == reflect.Ptr || typeFunc || transform || normalize || (!p.marshaler && p.kind == planReflect) {
+		enc, rv := encoderFor(t), reflect.ValueOf(s)
+		for i := range s {
+			if err := enc(e, rv.Index(i)); err != nil {
+				return err
+			}
+			if err := e.flushFull(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	appendElem := planElemAppender(p.kind, s)
+	for i := range s {
+		if p.marshaler {
+			b, err := marshalItem(any(s[i]).(Marshaler))
+			if err != nil {
+				return err
+			}
+			e.buf = append(e.buf, b...)
+		} else {
+			e.buf = appendElem(e.buf, i)
+		}
+
+		if err := e.flushFull(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planElemAppender returns a function appending the encoding of s[i] using
+// the fast path for kind. Common slice types are handled without
+// reflection.
+func planElemAppender[T any](kind planKind, s []T) func(dst []byte, i int) []byte {
+	switch s := any(s).(type) {
+	case []float64:
+		return func(dst []byte, i int) []byte { return AppendFloat64(dst, s[i]) }
+	case []int:
+		return func(dst []byte, i int) []byte { return AppendInt(dst, int64(s[i])) }
+	case []int64:
+		return func(dst []byte, i int) []byte { return AppendInt(dst, s[i]) }
+	case []uint64:
+		return func(dst []byte, i int) []byte { return AppendUint(dst, s[i]) }
+	case []string:
+		return func(dst []byte, i int) []byte { return AppendString(dst, s[i]) }
+	case []bool:
+		return func(dst []byte, i int) []byte { return AppendBool(dst, s[i]) }
+	}
+
+	rv := reflect.ValueOf(s)
+	switch kind {
+	case planBool:
+		return func(dst []byte, i int) []byte { return AppendBool(dst, rv.Index(i).Bool()) }
+	case planInt:
+		return func(dst []byte, i int) []byte { return AppendInt(dst, rv.Index(i).Int()) }
+	case planUint:
+		return func(dst []byte, i int) []byte { return AppendUint(dst, rv.Index(i).Uint()) }
+	case planFloat:
+		return func(dst []byte, i int) []byte { return AppendFloat64(dst, rv.Index(i).Float()) }
+	case planString:
+		return func(dst []byte, i int) []byte { return AppendString(dst, rv.Index(i).String()) }
+	case planBytes:
+		return func(dst []byte, i int) []byte { return AppendBytes(dst, rv.Index(i).Bytes()) }
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+type level uint8
+
+type point struct {
+	X, Y int
+}
+
+func (p point) MarshalCBOR() ([]byte, error) {
+	b := cbor.AppendArrayHeader(nil, 2)
+	b = cbor.AppendInt(b, int64(p.X))
+	return cbor.AppendInt(b, int64(p.Y)), nil
+}
+
+func (p *point) UnmarshalCBOR(data []byte) error {
+	n, data, err := cbor.ReadArrayHeader(data)
+	if err != nil {
+		return err
+	}
+	if n != 2 {
+		return errors.New("point must have two elements")
+	}
+	x, data, err := cbor.ReadInt(data)
+	if err != nil {
+		return err
+	}
+	y, _, err := cbor.ReadInt(data)
+	if err != nil {
+		return err
+	}
+	p.X, p.Y = int(x), int(y)
+	return nil
+}
+
+func TestMarshalT(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func() ([]byte, error)
+		v    interface{}
+		want string
+	}{
+		{"bool", func() ([]byte, error) { return cbor.MarshalT(true) }, true, "f5"},
+		{"int", func() ([]byte, error) { return cbor.MarshalT(-500) }, -500, "3901f3"},
+		{"named uint", func() ([]byte, error) { return cbor.MarshalT(level(24)) }, level(24), "1818"},
+		{"float", func() ([]byte, error) { return cbor.MarshalT(1.5) }, 1.5, "fb3ff8000000000000"},
+		{"string", func() ([]byte, error) { return cbor.MarshalT("IETF") }, "IETF", "6449455446"},
+		{"bytes", func() ([]byte, error) { return cbor.MarshalT([]byte{1, 2}) }, []byte{1, 2}, "420102"},
+		{"marshaler", func() ([]byte, error) { return cbor.MarshalT(point{1, -2}) }, point{1, -2}, "820121"},
+		{"slice", func() ([]byte, error) { return cbor.MarshalT([]int{1, 2}) }, []int{1, 2}, "820102"},
+		{"interface", func() ([]byte, error) { return cbor.MarshalT[interface{}]("a") }, "a", "6161"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := test.fn()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(b); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+
+			// MarshalT must match Marshal.
+			v, err := cbor.Marshal(test.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, v) {
+				t.Fatalf("MarshalT returned %x, Marshal returned %x", b, v)
+			}
+		})
+	}
+}
+
+func TestUnmarshalT(t *testing.T) {
+	decode := func(t *testing.T, s string) []byte {
+		t.Helper()
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	t.Run("basic", func(t *testing.T) {
+		if v, err := cbor.UnmarshalT[bool](decode(t, "f5")); err != nil || !v {
+			t.Fatalf("got %v, %v", v, err)
+		}
+		if v, err := cbor.UnmarshalT[int16](decode(t, "3901f3")); err != nil || v != -500 {
+			t.Fatalf("got %v, %v", v, err)
+		}
+		if v, err := cbor.UnmarshalT[level](decode(t, "1818")); err != nil || v != 24 {
+			t.Fatalf("got %v, %v", v, err)
+		}
+		if v, err := cbor.UnmarshalT[float32](decode(t, "f93e00")); err != nil || v != 1.5 {
+			t.Fatalf("got %v, %v", v, err)
+		}
+		if v, err := cbor.UnmarshalT[string](decode(t, "6449455446")); err != nil || v != "IETF" {
+			t.Fatalf("got %v, %v", v, err)
+		}
+		if v, err := cbor.UnmarshalT[[]byte](decode(t, "420102")); err != nil || !bytes.Equal(v, []byte{1, 2}) {
+			t.Fatalf("got %v, %v", v, err)
+		}
+	})
+
+	t.Run("unmarshaler", func(t *testing.T) {
+		v, err := cbor.UnmarshalT[point](decode(t, "820121"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != (point{1, -2}) {
+			t.Fatalf("got %+v", v)
+		}
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		v, err := cbor.UnmarshalT[map[string]string](decode(t, "a16568656c6c6f65776f726c64"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v["hello"] != "world" {
+			t.Fatalf("got %v", v)
+		}
+
+		// Null decodes to the zero value.
+		s, err := cbor.UnmarshalT[string](decode(t, "f6"))
+		if err != nil || s != "" {
+			t.Fatalf("got %q, %v", s, err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := cbor.UnmarshalT[int8](decode(t, "1901f4")); err == nil {
+			t.Fatal("expected overflow error")
+		}
+		if _, err := cbor.UnmarshalT[uint](decode(t, "20")); err == nil {
+			t.Fatal("expected error for negative integer")
+		}
+		if _, err := cbor.UnmarshalT[string](decode(t, "6449")); err == nil {
+			t.Fatal("expected error for truncated string")
+		}
+		if _, err := cbor.UnmarshalT[point](decode(t, "8301")); err == nil {
+			t.Fatal("expected error for truncated array")
+		}
+	})
+}
+
+func BenchmarkUnmarshalTString(b *testing.B) {
+	data, err := hex.DecodeString("6449455446")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := cbor.UnmarshalT[string](data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMarshalSlice(t *testing.T) {
+	type named float32
+
+	type record struct {
+		ID   int      `cbor:"id"`
+		Tags []string `cbor:"tags,omitempty"`
+	}
+
+	tests := []struct {
+		name string
+		fn   func() ([]byte, error)
+		v    interface{}
+	}{
+		{"float64", func() ([]byte, error) { return cbor.MarshalSlice([]float64{1.5, -2}) }, []float64{1.5, -2}},
+		{"named float", func() ([]byte, error) { return cbor.MarshalSlice([]named{1.5}) }, []named{1.5}},
+		{"int", func() ([]byte, error) { return cbor.MarshalSlice([]int{1, -500, 70000}) }, []int{1, -500, 70000}},
+		{"named uint", func() ([]byte, error) { return cbor.MarshalSlice([]level{24}) }, []level{24}},
+		{"bool", func() ([]byte, error) { return cbor.MarshalSlice([]bool{true, false}) }, []bool{true, false}},
+		{"string", func() ([]byte, error) { return cbor.MarshalSlice([]string{"a", "IETF"}) }, []string{"a", "IETF"}},
+		{"bytes", func() ([]byte, error) { return cbor.MarshalSlice([]byte{1, 2}) }, []byte{1, 2}},
+		{"byte slices", func() ([]byte, error) { return cbor.MarshalSlice([][]byte{{1}, nil}) }, [][]byte{{1}, nil}},
+		{"marshaler", func() ([]byte, error) { return cbor.MarshalSlice([]point{{1, -2}, {3, 4}}) }, []point{{1, -2}, {3, 4}}},
+		{"struct", func() ([]byte, error) {
+			return cbor.MarshalSlice([]record{{ID: 1}, {ID: 2, Tags: []string{"x"}}})
+		}, []record{{ID: 1}, {ID: 2, Tags: []string{"x"}}}},
+		{"nested", func() ([]byte, error) { return cbor.MarshalSlice([][]int{{1}, {2, 3}}) }, [][]int{{1}, {2, 3}}},
+		{"pointer", func() ([]byte, error) { return cbor.MarshalSlice([]*int{nil}) }, []*int{nil}},
+		{"interface", func() ([]byte, error) { return cbor.MarshalSlice([]interface{}{1, "a"}) }, []interface{}{1, "a"}},
+		{"empty", func() ([]byte, error) { return cbor.MarshalSlice([]int(nil)) }, []int(nil)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := test.fn()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// MarshalSlice must match Marshal.
+			v, err := cbor.Marshal(test.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, v) {
+				t.Fatalf("MarshalSlice returned %x, Marshal returned %x", b, v)
+			}
+		})
+	}
+}
+
+func TestEncodeSlice_large(t *testing.T) {
+	s := make([]float64, 10000)
+	for i := range s {
+		s[i] = float64(i) / 3
+	}
+
+	var buf bytes.Buffer
+	if err := cbor.EncodeSlice(cbor.NewEncoder(&buf), s); err != nil {
+		t.Fatal(err)
+	}
+	v, err := cbor.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), v) {
+		t.Fatal("EncodeSlice doesn't match Marshal")
+	}
+}
+
+func BenchmarkMarshalSliceFloat64(b *testing.B) {
+	s := make([]float64, 1000)
+	for i := range s {
+		s[i] = float64(i) / 3
+	}
+
+	b.Run("Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := cbor.Marshal(s); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("MarshalSlice", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := cbor.MarshalSlice(s); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,45 @@
+// Package grpccbor registers a CBOR codec with gRPC, so services that don't
+// use protocol buffers can exchange messages encoded with the cbor package.
+//
+// Importing the package registers the codec under the name "cbor":
+//
+//	import _ "github.com/picatz/cbor/grpccbor"
+//
+// Clients select it per call with grpc.CallContentSubtype(grpccbor.Name),
+// or for all calls with grpc.WithDefaultCallOptions. Servers use the codec
+// for requests with a content type of "application/grpc+cbor".
+//
+// It's a separate module, so the cbor package doesn't depend on gRPC.
+package grpccbor
+
+import (
+	"github.com/picatz/cbor"
+	"google.golang.org/grpc/encoding"
+)
+
+// Name is the name the codec is registered under, which is also the gRPC
+// content subtype.
+const Name = "cbor"
+
+func init() {
+	encoding.RegisterCodec(Codec{})
+}
+
+// Codec is a gRPC encoding.Codec using cbor.Marshal and cbor.Unmarshal, so
+// messages follow the cbor package's struct tags.
+type Codec struct{}
+
+// Marshal returns the CBOR encoding of v.
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+// Unmarshal decodes the CBOR encoding in data into v.
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	return cbor.Unmarshal(data, v)
+}
+
+// Name returns Name.
+func (Codec) Name() string {
+	return Name
+}
@@ -0,0 +1,74 @@
+package grpccbor_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/picatz/cbor/grpccbor"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type GreetRequest struct {
+	Name string `cbor:"1,keyasint"`
+}
+
+type GreetResponse struct {
+	Message string `cbor:"1,keyasint"`
+	Length  int    `cbor:"2,keyasint"`
+}
+
+// greet is a unary handler for a service defined without protocol buffers.
+func greet(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+	var req GreetRequest
+	if err := dec(&req); err != nil {
+		return nil, err
+	}
+	return &GreetResponse{Message: "hello " + req.Name, Length: len(req.Name)}, nil
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Greeter",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Greet", Handler: greet},
+	},
+}
+
+func TestCodecRegistered(t *testing.T) {
+	if c := encoding.GetCodec(grpccbor.Name); c == nil {
+		t.Fatal("codec not registered")
+	}
+}
+
+func TestUnaryCall(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+
+	server := grpc.NewServer()
+	server.RegisterService(&serviceDesc, struct{}{})
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(grpccbor.Name)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var resp GreetResponse
+	if err := conn.Invoke(context.Background(), "/test.Greeter/Greet", &GreetRequest{Name: "gopher"}, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "hello gopher" || resp.Length != 6 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
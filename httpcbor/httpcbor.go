@@ -0,0 +1,128 @@
+// Package httpcbor provides helpers for using CBOR as the body of HTTP
+// requests and responses with net/http.
+//
+// The media type for CBOR is "application/cbor", defined in RFC 8949.
+//
+// https://www.rfc-editor.org/rfc/rfc8949.html#section-9.3
+package httpcbor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/picatz/cbor"
+)
+
+// ContentType is the media type of CBOR data.
+const ContentType = "application/cbor"
+
+// DefaultMaxBytes is the maximum size of a request body read by
+// DecodeRequest, unless a different limit is set with MaxBytes.
+const DefaultMaxBytes = 1 << 20
+
+var (
+	// ErrUnsupportedMediaType is returned by DecodeRequest when the request
+	// doesn't have a CBOR content type.
+	ErrUnsupportedMediaType = errors.New("httpcbor: unsupported media type")
+
+	// ErrRequestTooLarge is returned by DecodeRequest when the request body
+	// exceeds the size limit.
+	ErrRequestTooLarge = errors.New("httpcbor: request body too large")
+)
+
+// maxBytesKey is the context key of the size limit set by MaxBytes.
+type maxBytesKey struct{}
+
+// MaxBytes returns middleware which limits the size of request bodies to n
+// bytes, using http.MaxBytesReader. DecodeRequest uses n instead of
+// DefaultMaxBytes for requests passed through it.
+func MaxBytes(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			ctx := context.WithValue(r.Context(), maxBytesKey{}, n)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// DecodeRequest decodes the CBOR body of r into the value pointed to by v,
+// like cbor.Unmarshal.
+//
+// The request must have a Content-Type of "application/cbor", or a
+// structured syntax suffix of "+cbor" such as "application/cwt+cbor",
+// otherwise ErrUnsupportedMediaType is returned. Bodies larger than the
+// limit set by MaxBytes, or DefaultMaxBytes, return ErrRequestTooLarge.
+// StatusCode maps these errors to HTTP status codes.
+func DecodeRequest(r *http.Request, v interface{}) error {
+	if !isCBOR(r.Header.Get("Content-Type")) {
+		return fmt.Errorf("%w %q", ErrUnsupportedMediaType, r.Header.Get("Content-Type"))
+	}
+	if r.Body == nil {
+		return errors.New("httpcbor: missing request body")
+	}
+
+	limit := int64(DefaultMaxBytes)
+	if n, ok := r.Context().Value(maxBytesKey{}).(int64); ok {
+		limit = n
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return ErrRequestTooLarge
+		}
+		return err
+	}
+	if int64(len(data)) > limit {
+		return ErrRequestTooLarge
+	}
+
+	return cbor.Unmarshal(data, v)
+}
+
+// EncodeResponse writes the CBOR encoding of v as the body of a response
+// with the given status code, and a Content-Type of "application/cbor".
+//
+// If v can't be encoded, nothing is written and the error is returned.
+func EncodeResponse(w http.ResponseWriter, code int, v interface{}) error {
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(code)
+	_, err = w.Write(data)
+	return err
+}
+
+// StatusCode returns the HTTP status code for an error returned by
+// DecodeRequest: 415 for ErrUnsupportedMediaType, 413 for
+// ErrRequestTooLarge, and 400 for anything else.
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrRequestTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// isCBOR reports whether the media type in a Content-Type header is CBOR.
+func isCBOR(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == ContentType ||
+		(strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+cbor"))
+}
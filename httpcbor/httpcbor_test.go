@@ -0,0 +1,136 @@
+package httpcbor_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/picatz/cbor"
+	"github.com/picatz/cbor/httpcbor"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	body, err := cbor.Marshal(map[string]string{"hello": "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, contentType := range []string{
+		"application/cbor",
+		"application/cbor; charset=binary",
+		"application/cwt+cbor",
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+
+		var v map[string]string
+		if err := httpcbor.DecodeRequest(r, &v); err != nil {
+			t.Fatalf("%s: %v", contentType, err)
+		}
+		if v["hello"] != "world" {
+			t.Fatalf("%s: got %v", contentType, v)
+		}
+	}
+}
+
+func TestDecodeRequestErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		status      int
+	}{
+		{"json", "application/json", []byte{0xa0}, http.StatusUnsupportedMediaType},
+		{"missing content type", "", []byte{0xa0}, http.StatusUnsupportedMediaType},
+		{"too large", "application/cbor", bytes.Repeat([]byte{0x00}, httpcbor.DefaultMaxBytes+1), http.StatusRequestEntityTooLarge},
+		{"malformed", "application/cbor", []byte{0xa1}, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(test.body))
+			r.Header.Set("Content-Type", test.contentType)
+
+			var v interface{}
+			err := httpcbor.DecodeRequest(r, &v)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if got := httpcbor.StatusCode(err); got != test.status {
+				t.Fatalf("got status %d for %v, want %d", got, err, test.status)
+			}
+		})
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	handler := httpcbor.MaxBytes(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v []int
+		if err := httpcbor.DecodeRequest(r, &v); err != nil {
+			http.Error(w, err.Error(), httpcbor.StatusCode(err))
+			return
+		}
+		if err := httpcbor.EncodeResponse(w, http.StatusOK, len(v)); err != nil {
+			t.Error(err)
+		}
+	}))
+
+	tests := []struct {
+		body   []byte
+		status int
+	}{
+		{[]byte{0x83, 0x01, 0x02, 0x03}, http.StatusOK},
+		{[]byte{0x84, 0x01, 0x02, 0x03, 0x04}, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(test.body))
+		r.Header.Set("Content-Type", httpcbor.ContentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Fatalf("got status %d, want %d: %s", w.Code, test.status, w.Body)
+		}
+	}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := httpcbor.EncodeResponse(w, http.StatusCreated, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("got status %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != httpcbor.ContentType {
+		t.Fatalf("got content type %q", got)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, []byte{0x81, 0x61, 0x61}) {
+		t.Fatalf("got body %x", body)
+	}
+
+	// Values which can't be encoded don't write a response.
+	w = httptest.NewRecorder()
+	if err := httpcbor.EncodeResponse(w, http.StatusOK, make(chan int)); err == nil {
+		t.Fatal("expected error")
+	}
+	if w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+		t.Fatal("response written for invalid value")
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	if got := httpcbor.StatusCode(errors.New("other")); got != http.StatusBadRequest {
+		t.Fatalf("got %d", got)
+	}
+}
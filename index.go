@@ -0,0 +1,185 @@
+package cbor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Indexer builds an Index of the items nested in a CBOR document, such as
+// a memory-mapped file, so they can later be accessed at random without
+// parsing the document again.
+type Indexer struct {
+	// Depth is the nesting depth of the items to index. At depth 1, only
+	// the elements of the top-level array, or the values of the top-level
+	// map, are indexed. At depth 2, their own elements or values are also
+	// indexed, and so on. If zero, the depth is 1.
+	//
+	// Tags are transparent: the children of a tagged array or map are
+	// indexed as the children of the tag.
+	Depth int
+}
+
+// Index is the position of the items of a CBOR document, built by an
+// Indexer. The entries are exported, so an index can itself be encoded
+// and stored alongside the document.
+type Index struct {
+	// Entries are the indexed items, in the order they appear in the
+	// document, with each item followed by its indexed descendants. At
+	// depth 1, Entries[i] is the i-th child of the top-level item.
+	Entries []IndexEntry
+}
+
+// IndexEntry is an item of an Index.
+type IndexEntry struct {
+	// Parent is the position in Index.Entries of the item containing this
+	// item, or -1 if it's a child of the top-level item.
+	Parent int
+
+	// Depth is the nesting depth of the item, which is 1 for the children
+	// of the top-level item.
+	Depth int
+
+	// Position is the position of the item in its array, or of its pair
+	// in its map.
+	Position int
+
+	// Descendants is the number of entries following this entry which
+	// are descendants of the item.
+	Descendants int
+
+	// KeyOffset and KeyLength are the position in bytes of the encoded key
+	// of a map value. They are zero for array elements.
+	KeyOffset int
+	KeyLength int
+
+	// Offset and Length are the position in bytes of the encoded item.
+	Offset int
+	Length int
+}
+
+// Item returns the encoded item of the entry in data, which must be the
+// indexed document.
+func (e IndexEntry) Item(data []byte) RawMessage {
+	return data[e.Offset : e.Offset+e.Length : e.Offset+e.Length]
+}
+
+// Key returns the encoded key of the entry in data, which must be the
+// indexed document, or nil for array elements.
+func (e IndexEntry) Key(data []byte) RawMessage {
+	if e.KeyLength == 0 {
+		return nil
+	}
+	return data[e.KeyOffset : e.KeyOffset+e.KeyLength : e.KeyOffset+e.KeyLength]
+}
+
+// Index scans the CBOR document in data once, returning the index of its
+// items. It returns an error if data isn't exactly one well-formed item.
+func (ix Indexer) Index(data []byte) (*Index, error) {
+	depth := ix.Depth
+	if depth <= 0 {
+		depth = 1
+	}
+
+	x := &Index{}
+	n, err := x.scan(data, 0, -1, 0, 0, depth)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(data) {
+		return nil, errors.New("cbor: trailing data after document")
+	}
+	return x, nil
+}
+
+// scan scans the item at off, at the given depth, indexing its children
+// as entries whose parent is at position parent, if the item is an array
+// or map above maxDepth. It returns the length of the item. Unlike depth,
+// nesting also counts enclosing tags, to limit the recursion.
+func (x *Index) scan(data []byte, off, parent, depth, nesting, maxDepth int) (int, error) {
+	if nesting > maxNestingDepth {
+		return 0, ErrMaxDepth
+	}
+
+	mt, ai, arg, n, err := parseHeader(data[off:])
+	if err != nil {
+		return 0, err
+	}
+	if depth >= maxDepth || (mt != MajorTypeArray && mt != MajorTypeMap && mt != MajorTypeTag) {
+		return itemLength(data[off:], nesting)
+	}
+
+	if mt == MajorTypeTag {
+		if ai == 31 {
+			return 0, newError(ErrMalformed, "cbor: invalid indefinite-length tag")
+		}
+		l, err := x.scan(data, off+n, parent, depth, nesting+1, maxDepth)
+		if err != nil {
+			return 0, err
+		}
+		return n + l, nil
+	}
+
+	l := n
+	for i := 0; ai == 31 || uint64(i) < arg; i++ {
+		if off+l >= len(data) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if ai == 31 && data[off+l] == 0xff {
+			return l + 1, nil
+		}
+
+		e := IndexEntry{Parent: parent, Depth: depth + 1, Position: i}
+		if mt == MajorTypeMap {
+			kl, err := itemLength(data[off+l:], nesting+1)
+			if err != nil {
+				return 0, err
+			}
+			e.KeyOffset, e.KeyLength = off+l, kl
+			l += kl
+			if off+l >= len(data) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			if data[off+l] == 0xff {
+				return 0, newError(ErrMalformed, "cbor: indefinite-length map has a key without a value")
+			}
+		}
+
+		pos := len(x.Entries)
+		x.Entries = append(x.Entries, e)
+		vl, err := x.scan(data, off+l, pos, depth+1, nesting+1, maxDepth)
+		if err != nil {
+			return 0, err
+		}
+		x.Entries[pos].Offset, x.Entries[pos].Length = off+l, vl
+		x.Entries[pos].Descendants = len(x.Entries) - pos - 1
+		l += vl
+	}
+	return l, nil
+}
+
+// Lookup returns the entry at the given path, where each element is the
+// position of an item in its array, or of its pair in its map, starting
+// from the children of the top-level item.
+func (x *Index) Lookup(path ...int) (IndexEntry, error) {
+	if len(path) == 0 {
+		return IndexEntry{}, errors.New("cbor: empty index path")
+	}
+
+	// The entries of the children of an item are contiguous, with each
+	// one followed by its descendants.
+	i, end := 0, len(x.Entries)
+	for depth, p := range path {
+		for i < end && x.Entries[i].Position != p {
+			i += x.Entries[i].Descendants + 1
+		}
+		if i >= end {
+			return IndexEntry{}, fmt.Errorf("cbor: no indexed item at %v", path[:depth+1])
+		}
+		if depth < len(path)-1 {
+			end = i + x.Entries[i].Descendants + 1
+			i++
+		}
+	}
+	return x.Entries[i], nil
+}
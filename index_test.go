@@ -0,0 +1,136 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestIndexer(t *testing.T) {
+	// {"a": [1, [2, 3]], "b": 4}
+	data, err := hex.DecodeString("a2616182018202036162" + "04")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := cbor.Indexer{}.Index(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", x.Entries)
+	}
+	for i, want := range []struct{ key, item string }{
+		{"6161", "8201820203"},
+		{"6162", "04"},
+	} {
+		e := x.Entries[i]
+		if got := hex.EncodeToString(e.Key(data)); got != want.key {
+			t.Errorf("entry %d: expected key %s, got %s", i, want.key, got)
+		}
+		if got := hex.EncodeToString(e.Item(data)); got != want.item {
+			t.Errorf("entry %d: expected item %s, got %s", i, want.item, got)
+		}
+	}
+
+	x, err = cbor.Indexer{Depth: 3}.Index(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x.Entries) != 6 {
+		t.Fatalf("expected 6 entries, got %+v", x.Entries)
+	}
+	for _, tt := range []struct {
+		path []int
+		item string
+	}{
+		{[]int{0}, "8201820203"},
+		{[]int{0, 0}, "01"},
+		{[]int{0, 1}, "820203"},
+		{[]int{0, 1, 1}, "03"},
+		{[]int{1}, "04"},
+	} {
+		e, err := x.Lookup(tt.path...)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.path, err)
+		}
+		if e.Depth != len(tt.path) {
+			t.Errorf("%v: expected depth %d, got %d", tt.path, len(tt.path), e.Depth)
+		}
+		if got := hex.EncodeToString(e.Item(data)); got != tt.item {
+			t.Errorf("%v: expected %s, got %s", tt.path, tt.item, got)
+		}
+	}
+	for _, path := range [][]int{{2}, {1, 0}, {0, 1, 2}} {
+		if _, err := x.Lookup(path...); err == nil {
+			t.Errorf("%v: expected an error", path)
+		}
+	}
+}
+
+func TestIndexer_indefinite(t *testing.T) {
+	// 24([_ 1, {_ "a": 2}])
+	data, err := hex.DecodeString("d8189f01bf616102ffff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := cbor.Indexer{Depth: 2}.Index(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := x.Lookup(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(e.Key(data)); got != "6161" {
+		t.Errorf("expected key 6161, got %s", got)
+	}
+	if got := hex.EncodeToString(e.Item(data)); got != "02" {
+		t.Errorf("expected item 02, got %s", got)
+	}
+	if e.Parent != 1 {
+		t.Errorf("expected parent 1, got %d", e.Parent)
+	}
+}
+
+func TestIndexer_malformed(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"82",
+		"8201",
+		"a16161",
+		"bf6161ff",
+		"9f01",
+		"0101",
+		"1c",
+	} {
+		data, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := (cbor.Indexer{Depth: 2}).Index(data); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
+
+func TestIndexer_nestedTags(t *testing.T) {
+	data := append(bytes.Repeat([]byte{0xc6}, 100000), 0x80)
+	if _, err := (cbor.Indexer{Depth: 1}).Index(data); !errors.Is(err, cbor.ErrMaxDepth) {
+		t.Fatalf("expected ErrMaxDepth, got %v", err)
+	}
+
+	data = append(bytes.Repeat([]byte{0xc6}, 10), 0x81, 0x01)
+	x, err := (cbor.Indexer{Depth: 1}).Index(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x.Entries) != 1 || x.Entries[0].Offset != 11 {
+		t.Fatalf("expected one entry at offset 11, got %+v", x.Entries)
+	}
+}
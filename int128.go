@@ -0,0 +1,177 @@
+package cbor
+
+import (
+	"math/big"
+	"math/bits"
+	"reflect"
+)
+
+// Uint128 is an unsigned 128-bit integer, whose value is Hi<<64 | Lo.
+//
+// Values which fit in 64 bits are encoded as plain integers, and larger
+// ones as bignums (RFC 8949, section 3.4.3), so they interoperate with
+// big.Int without the cost of allocating one for each value.
+type Uint128 struct {
+	Hi, Lo uint64
+}
+
+// Int128 is a signed 128-bit integer in two's complement, whose value is
+// Hi<<64 | Lo, where Hi holds the sign.
+//
+// Like Uint128, values which fit in 64 bits are encoded as plain integers,
+// and larger ones as bignums.
+type Int128 struct {
+	Hi int64
+	Lo uint64
+}
+
+// BigInt returns x as a big.Int.
+func (x Uint128) BigInt() *big.Int {
+	hi := new(big.Int).SetUint64(x.Hi)
+	return hi.Lsh(hi, 64).Or(hi, new(big.Int).SetUint64(x.Lo))
+}
+
+// String returns x in decimal.
+func (x Uint128) String() string {
+	return x.BigInt().String()
+}
+
+// BigInt returns x as a big.Int.
+func (x Int128) BigInt() *big.Int {
+	if x.Hi < 0 {
+		// -1-x is the bitwise complement of x.
+		n := Uint128{^uint64(x.Hi), ^x.Lo}.BigInt()
+		return n.Not(n)
+	}
+	return Uint128{uint64(x.Hi), x.Lo}.BigInt()
+}
+
+// String returns x in decimal.
+func (x Int128) String() string {
+	return x.BigInt().String()
+}
+
+var (
+	// uint128Type is the reflect.Type of Uint128.
+	uint128Type = reflect.TypeOf(Uint128{})
+
+	// int128Type is the reflect.Type of Int128.
+	int128Type = reflect.TypeOf(Int128{})
+)
+
+// encodeUint128 writes a Uint128.
+func encodeUint128(e *Encoder, v reflect.Value) error {
+	x := v.Interface().(Uint128)
+	return e.write128(MajorTypeUnsignedInt, x.Hi, x.Lo)
+}
+
+// encodeInt128 writes an Int128.
+func encodeInt128(e *Encoder, v reflect.Value) error {
+	x := v.Interface().(Int128)
+	if x.Hi < 0 {
+		// Negative integers are encoded as -1-x.
+		return e.write128(MajorTypeNegativeInt, ^uint64(x.Hi), ^x.Lo)
+	}
+	return e.write128(MajorTypeUnsignedInt, uint64(x.Hi), x.Lo)
+}
+
+// write128 writes the 128-bit argument hi<<64 | lo of an integer of the
+// major type mt, as a plain integer if it fits in 64 bits, or as a bignum.
+func (e *Encoder) write128(mt MajorType, hi, lo uint64) error {
+	if hi == 0 {
+		return e.writeHeader(mt, lo)
+	}
+	tag := TagPositiveBignum
+	if mt == MajorTypeNegativeInt {
+		tag = TagNegativeBignum
+	}
+	var b [16]byte
+	putUint128(b[:], hi, lo)
+	e.buf = AppendTag(e.buf, uint64(tag))
+	e.buf = AppendBytes(e.buf, b[bits.LeadingZeros64(hi)/8:])
+	return nil
+}
+
+// putUint128 puts hi<<64 | lo into b in big-endian order.
+func putUint128(b []byte, hi, lo uint64) {
+	for i := 0; i < 8; i++ {
+		b[i] = byte(hi >> (56 - 8*i))
+		b[8+i] = byte(lo >> (56 - 8*i))
+	}
+}
+
+// decodeUint128 decodes an unsigned integer or a positive bignum into a
+// Uint128.
+func decodeUint128(dec *Decoder, rv reflect.Value, b byte) error {
+	if !isInteger128(b) {
+		return dec.decodeItem(rv, b)
+	}
+	negative, hi, lo, err := dec.read128(rv, b)
+	if err != nil {
+		return err
+	}
+	if negative {
+		return typeError("negative integer", rv.Type())
+	}
+	rv.Set(reflect.ValueOf(Uint128{hi, lo}))
+	return nil
+}
+
+// decodeInt128 decodes an integer or a bignum into an Int128.
+func decodeInt128(dec *Decoder, rv reflect.Value, b byte) error {
+	if !isInteger128(b) {
+		return dec.decodeItem(rv, b)
+	}
+	negative, hi, lo, err := dec.read128(rv, b)
+	if err != nil {
+		return err
+	}
+	if hi > 1<<63-1 {
+		return typeError("bignum", rv.Type())
+	}
+	if negative {
+		hi, lo = ^hi, ^lo
+	}
+	rv.Set(reflect.ValueOf(Int128{int64(hi), lo}))
+	return nil
+}
+
+// isInteger128 reports whether b is the initial byte of an integer or a
+// tag, which may be a bignum.
+func isInteger128(b byte) bool {
+	mt := MajorType(b >> 5)
+	return mt == MajorTypeUnsignedInt || mt == MajorTypeNegativeInt || mt == MajorTypeTag
+}
+
+// read128 reads the integer or tag whose initial byte b has already been
+// read, as checked by isInteger128, returning whether it's negative, and its argument, which is
+// the magnitude of a bignum, as hi<<64 | lo. Bignums which don't fit in
+// 128 bits are an UnmarshalTypeError for rv.
+func (dec *Decoder) read128(rv reflect.Value, b byte) (negative bool, hi, lo uint64, err error) {
+	if mt := MajorType(b >> 5); mt != MajorTypeTag {
+		lo, err = dec.readArgument(b & 0x1f)
+		return mt == MajorTypeNegativeInt, 0, lo, err
+	}
+
+	tag, err := dec.readArgument(b & 0x1f)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	if Tag(tag) != TagPositiveBignum && Tag(tag) != TagNegativeBignum {
+		return false, 0, 0, typeError("tag "+itoa(int64(tag)), rv.Type())
+	}
+	var magnitude []byte
+	if err := dec.decodeValue(reflect.ValueOf(&magnitude).Elem()); err != nil {
+		return false, 0, 0, err
+	}
+	for len(magnitude) > 0 && magnitude[0] == 0 {
+		magnitude = magnitude[1:]
+	}
+	if len(magnitude) > 16 {
+		return false, 0, 0, typeError("bignum", rv.Type())
+	}
+	for _, c := range magnitude {
+		hi, lo = hi<<8|lo>>56, lo<<8|uint64(c)
+	}
+	return Tag(tag) == TagNegativeBignum, hi, lo, nil
+}
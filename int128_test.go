@@ -0,0 +1,108 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestUint128(t *testing.T) {
+	tests := []struct {
+		x    cbor.Uint128
+		s    string
+		want string
+	}{
+		{cbor.Uint128{}, "0", "00"},
+		{cbor.Uint128{Lo: 1<<64 - 1}, "18446744073709551615", "1bffffffffffffffff"},
+		{cbor.Uint128{Hi: 1}, "18446744073709551616", "c249010000000000000000"},
+		{cbor.Uint128{Hi: 1<<64 - 1, Lo: 1<<64 - 1}, "340282366920938463463374607431768211455", "c250ffffffffffffffffffffffffffffffff"},
+	}
+
+	for _, test := range tests {
+		if s := test.x.String(); s != test.s {
+			t.Fatalf("expected %s, got %s", test.s, s)
+		}
+		b, err := cbor.Marshal(test.x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(b); got != test.want {
+			t.Fatalf("%s: expected %s, got %s", test.s, test.want, got)
+		}
+		var x cbor.Uint128
+		if err := cbor.Unmarshal(b, &x); err != nil {
+			t.Fatal(err)
+		}
+		if x != test.x {
+			t.Fatalf("%s: decoded %s", test.s, x)
+		}
+	}
+}
+
+func TestInt128(t *testing.T) {
+	tests := []struct {
+		x    cbor.Int128
+		s    string
+		want string
+	}{
+		{cbor.Int128{}, "0", "00"},
+		{cbor.Int128{Hi: -1, Lo: 1<<64 - 1}, "-1", "20"},
+		{cbor.Int128{Hi: -1}, "-18446744073709551616", "3bffffffffffffffff"},
+		{cbor.Int128{Hi: -2, Lo: 1<<64 - 1}, "-18446744073709551617", "c349010000000000000000"},
+		{cbor.Int128{Hi: 1<<63 - 1, Lo: 1<<64 - 1}, "170141183460469231731687303715884105727", "c2507fffffffffffffffffffffffffffffff"},
+		{cbor.Int128{Hi: -1 << 63}, "-170141183460469231731687303715884105728", "c3507fffffffffffffffffffffffffffffff"},
+	}
+
+	for _, test := range tests {
+		if s := test.x.String(); s != test.s {
+			t.Fatalf("expected %s, got %s", test.s, s)
+		}
+		b, err := cbor.Marshal(test.x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(b); got != test.want {
+			t.Fatalf("%s: expected %s, got %s", test.s, test.want, got)
+		}
+		var x cbor.Int128
+		if err := cbor.Unmarshal(b, &x); err != nil {
+			t.Fatal(err)
+		}
+		if x != test.x {
+			t.Fatalf("%s: decoded %s", test.s, x)
+		}
+	}
+}
+
+func TestInt128_decode(t *testing.T) {
+	// A bignum with leading zeros, and a field decoded from a bignum.
+	var v struct {
+		N cbor.Uint128 `cbor:"n"`
+	}
+	data, _ := hex.DecodeString("a1616ec24b000001000000000000002a")
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if want := (cbor.Uint128{Hi: 1, Lo: 42}); v.N != want {
+		t.Fatalf("expected %s, got %s", want, v.N)
+	}
+
+	for _, test := range []struct {
+		data string
+		v    interface{}
+	}{
+		{"20", new(cbor.Uint128)},                                     // negative
+		{"c2510100000000000000000000000000000000", new(cbor.Uint128)}, // 129 bits
+		{"c25080000000000000000000000000000000", new(cbor.Int128)},    // 2^127
+		{"c35080000000000000000000000000000000", new(cbor.Int128)},    // -1-2^127
+		{"c100", new(cbor.Int128)},                                    // not a bignum
+		{"6161", new(cbor.Int128)},                                    // a string
+	} {
+		data, _ := hex.DecodeString(test.data)
+		if err := cbor.Unmarshal(data, test.v); !errors.Is(err, cbor.ErrInvalidType) {
+			t.Errorf("%s: expected a type error, got %v", test.data, err)
+		}
+	}
+}
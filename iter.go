@@ -0,0 +1,165 @@
+//go:build go1.23
+
+package cbor
+
+import (
+	"fmt"
+	"io"
+	"iter"
+)
+
+// MapEntry is a key/value pair of a CBOR map.
+type MapEntry struct {
+	Key   RawMessage
+	Value RawMessage
+}
+
+// Values returns an iterator over the remaining items of a CBOR sequence
+// (RFC 8742) read by the decoder, such as a stream of log records.
+//
+// Iteration stops at the end of the input. If an item can't be read, the
+// error is yielded and iteration stops.
+func (dec *Decoder) Values() iter.Seq2[RawMessage, error] {
+	return func(yield func(RawMessage, error) bool) {
+		for {
+			dec.refill()
+			raw, err := dec.readRaw()
+			dec.commit()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(raw, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ArrayElements returns an iterator over the elements of the next item
+// read by the decoder, which must be an array.
+//
+// If the array or an element can't be read, the error is yielded and
+// iteration stops. If iteration stops early, the decoder is left in the
+// middle of the array, and can't be used to read the items after it.
+func (dec *Decoder) ArrayElements() iter.Seq2[RawMessage, error] {
+	return func(yield func(RawMessage, error) bool) {
+		dec.refill()
+		n, err := dec.readContainerHeader(MajorTypeArray)
+		dec.commit()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		for i := uint64(0); n < 0 || i < uint64(n); i++ {
+			dec.refill()
+			raw, done, err := dec.readContainerItem(n < 0)
+			dec.commit()
+			if done {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(raw, nil) {
+				return
+			}
+		}
+	}
+}
+
+// MapEntries returns an iterator over the key/value pairs of the next item
+// read by the decoder, which must be a map.
+//
+// If the map or an entry can't be read, the error is yielded and iteration
+// stops. If iteration stops early, the decoder is left in the middle of
+// the map, and can't be used to read the items after it.
+func (dec *Decoder) MapEntries() iter.Seq2[MapEntry, error] {
+	return func(yield func(MapEntry, error) bool) {
+		dec.refill()
+		n, err := dec.readContainerHeader(MajorTypeMap)
+		dec.commit()
+		if err != nil {
+			yield(MapEntry{}, err)
+			return
+		}
+
+		for i := uint64(0); n < 0 || i < uint64(n); i++ {
+			dec.refill()
+			key, done, err := dec.readContainerItem(n < 0)
+			if done {
+				dec.commit()
+				return
+			}
+			if err != nil {
+				dec.commit()
+				yield(MapEntry{}, err)
+				return
+			}
+			value, err := dec.readRaw()
+			dec.commit()
+			if err != nil {
+				yield(MapEntry{}, unexpectedEOF(err))
+				return
+			}
+			if !yield(MapEntry{Key: key, Value: value}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// readContainerHeader reads the header of an array or map, returning its
+// number of elements or key/value pairs, or -1 if it is indefinite-length.
+func (dec *Decoder) readContainerHeader(want MajorType) (int64, error) {
+	b, err := dec.readByte()
+	if err != nil {
+		return 0, err
+	}
+
+	raw, err := dec.appendHeaderBytes(nil, b)
+	if err != nil {
+		return 0, err
+	}
+	mt, ai, arg, _, err := parseHeader(raw)
+	if err != nil {
+		return 0, err
+	}
+	if mt != want {
+		return 0, fmt.Errorf("cbor: cannot read %s as %s", mt, want)
+	}
+	if ai == 31 {
+		return -1, nil
+	}
+
+	limit, tooLong := dec.options.MaxArrayElements, ErrArrayTooLong
+	if mt == MajorTypeMap {
+		limit, tooLong = dec.options.MaxMapPairs, ErrMapTooLong
+	}
+	if arg > uint64(limit) {
+		return 0, dec.limitExceeded(tooLong)
+	}
+	return int64(arg), nil
+}
+
+// readContainerItem reads the next item of an array or map, reporting
+// whether the end of an indefinite-length container was reached instead.
+func (dec *Decoder) readContainerItem(indefinite bool) (RawMessage, bool, error) {
+	b, err := dec.readByte()
+	if err != nil {
+		return nil, false, unexpectedEOF(err)
+	}
+	if b == 0xff {
+		if indefinite {
+			return nil, true, nil
+		}
+		return nil, false, newError(ErrMalformed, "cbor: unexpected break")
+	}
+	raw, err := dec.appendRawItem(nil, b, 1)
+	return raw, false, err
+}
@@ -0,0 +1,128 @@
+//go:build go1.23
+
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestDecoder_Values(t *testing.T) {
+	// 1, [2, 3], {"a": "b"}
+	data, err := hex.DecodeString("01820203a161616162")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for raw, err := range cbor.NewDecoder(bytes.NewReader(data)).Values() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, hex.EncodeToString(raw))
+	}
+
+	want := []string{"01", "820203", "a161616162"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestDecoder_Values_error(t *testing.T) {
+	// 1, followed by a truncated array.
+	dec := cbor.NewDecoder(bytes.NewReader([]byte{0x01, 0x82, 0x01}))
+
+	var items int
+	var errs []error
+	for _, err := range dec.Values() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		items++
+	}
+	if items != 1 || len(errs) != 1 || !errors.Is(errs[0], io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected items %d and errors %v", items, errs)
+	}
+}
+
+func TestDecoder_ArrayElements(t *testing.T) {
+	for _, s := range []string{
+		"83016161a0",   // [1, "a", {}]
+		"9f016161a0ff", // [_ 1, "a", {}]
+	} {
+		data, err := hex.DecodeString(s + "f5")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := cbor.NewDecoder(bytes.NewReader(data))
+
+		var got []string
+		for raw, err := range dec.ArrayElements() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, hex.EncodeToString(raw))
+		}
+		if len(got) != 3 || got[0] != "01" || got[1] != "6161" || got[2] != "a0" {
+			t.Fatalf("%s: unexpected elements %v", s, got)
+		}
+
+		// The decoder can read the item after the array.
+		var b bool
+		if err := dec.Decode(&b); err != nil || !b {
+			t.Fatalf("%s: expected true after the array, got %v (%v)", s, b, err)
+		}
+	}
+
+	// The next item isn't an array.
+	for _, err := range cbor.NewDecoder(bytes.NewReader([]byte{0xa0})).ArrayElements() {
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+}
+
+func TestDecoder_MapEntries(t *testing.T) {
+	// {_ "a": 1, "b": [2]}
+	data, err := hex.DecodeString("bf616101616281" + "02ff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for entry, err := range cbor.NewDecoder(bytes.NewReader(data)).MapEntries() {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var key string
+		if err := cbor.Unmarshal(entry.Key, &key); err != nil {
+			t.Fatal(err)
+		}
+		got[key] = hex.EncodeToString(entry.Value)
+	}
+	if len(got) != 2 || got["a"] != "01" || got["b"] != "8102" {
+		t.Fatalf("unexpected entries %v", got)
+	}
+
+	// Stop early.
+	var n int
+	for range cbor.NewDecoder(bytes.NewReader(data)).MapEntries() {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 entry, got %d", n)
+	}
+}
@@ -0,0 +1,464 @@
+package cbor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSONNumberMode controls how JSON numbers are encoded when transcoding
+// JSON to CBOR.
+type JSONNumberMode int
+
+const (
+	// JSONNumberAuto encodes JSON numbers without a fraction or exponent
+	// that fit in 64 bits as CBOR integers (major type 0 or 1), and all
+	// other numbers as 64-bit floats.
+	JSONNumberAuto JSONNumberMode = iota
+
+	// JSONNumberFloat encodes all JSON numbers as 64-bit floats, which
+	// matches how encoding/json decodes numbers into interface{} values.
+	JSONNumberFloat
+)
+
+// JSONOptions are the options used when transcoding JSON to CBOR.
+type JSONOptions struct {
+	// NumberMode controls how JSON numbers are encoded.
+	NumberMode JSONNumberMode
+}
+
+// DefaultJSONOptions is the default options used by JSONToCBOR.
+var DefaultJSONOptions = JSONOptions{
+	NumberMode: JSONNumberAuto,
+}
+
+// JSONToCBOR reads JSON values from r and writes their CBOR encoding to w.
+//
+// The JSON input is consumed token by token using a json.Decoder, so documents
+// are never decoded into an intermediate map[string]interface{}. Objects are
+// encoded as CBOR maps in the order the keys appear in the input, and arrays
+// are encoded as CBOR arrays. If r contains more than one JSON value, the
+// output is a CBOR sequence (RFC 8742) with one item per JSON value.
+//
+// If opts is nil, DefaultJSONOptions is used.
+func JSONToCBOR(w io.Writer, r io.Reader, opts *JSONOptions) error {
+	if opts == nil {
+		opts = &DefaultJSONOptions
+	}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	t := &jsonTranscoder{
+		dec:     dec,
+		options: opts,
+	}
+
+	enc := NewEncoder(w)
+	for {
+		err := t.transcode(enc)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := enc.flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// jsonTranscoder converts a stream of JSON tokens into CBOR items.
+type jsonTranscoder struct {
+	dec     *json.Decoder
+	options *JSONOptions
+
+	// body buffers the value being transcoded without the headers of its
+	// arrays and objects, which are in headers in the order they appear in
+	// the output.
+	body    Encoder
+	headers []jsonHeader
+}
+
+// transcode reads the next JSON value from the token stream and writes its
+// CBOR encoding to enc.
+//
+// Since a container's element count is only known once it has been read,
+// the whole value is buffered without the headers of its containers, which
+// are inserted as it's copied to enc. Nested containers would otherwise be
+// copied into their parent once per level of nesting.
+func (t *jsonTranscoder) transcode(enc *Encoder) error {
+	t.body.buf = t.body.buf[:0]
+	t.body.options = enc.options
+	t.headers = t.headers[:0]
+
+	if err := t.transcodeValue(&t.body); err != nil {
+		return err
+	}
+
+	off := 0
+	for _, h := range t.headers {
+		enc.buf = append(enc.buf, t.body.buf[off:h.off]...)
+		enc.buf = appendHeader(enc.buf, h.mt, h.n)
+		off = h.off
+	}
+	enc.buf = append(enc.buf, t.body.buf[off:]...)
+	return nil
+}
+
+// jsonHeader is the header of a CBOR array or map, which is inserted at
+// offset off of the transcoded value once its element count n is known.
+type jsonHeader struct {
+	off int
+	mt  MajorType
+	n   uint64
+}
+
+// beginHeader reserves the header of a container starting at the end of
+// enc's buffer, and returns its index in t.headers for endHeader.
+func (t *jsonTranscoder) beginHeader(enc *Encoder, mt MajorType) int {
+	t.headers = append(t.headers, jsonHeader{off: len(enc.buf), mt: mt})
+	return len(t.headers) - 1
+}
+
+// endHeader sets the element count of the header reserved by beginHeader.
+func (t *jsonTranscoder) endHeader(i int, n uint64) {
+	t.headers[i].n = n
+}
+
+// transcodeValue reads the next JSON value from the token stream and writes
+// its CBOR encoding to enc, which is t.body.
+//
+// Arrays and objects are written without their headers, which are
+// recorded in t.headers, since CBOR definite-length containers need their
+// element count up front.
+func (t *jsonTranscoder) transcodeValue(enc *Encoder) error {
+	tok, err := t.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '[':
+			return t.transcodeArray(enc)
+		case '{':
+			return t.transcodeObject(enc)
+		default:
+			return fmt.Errorf("cbor: unexpected JSON delimiter %q", v)
+		}
+	case string:
+		return enc.writeString(v)
+	case json.Number:
+		return t.transcodeNumber(enc, v)
+	case bool:
+		return enc.writeBool(v)
+	case nil:
+		return enc.writeNull()
+	default:
+		return fmt.Errorf("cbor: unexpected JSON token %T", tok)
+	}
+}
+
+// transcodeArray transcodes the elements of a JSON array, after the opening
+// '[' has been read, into a CBOR array.
+func (t *jsonTranscoder) transcodeArray(enc *Encoder) error {
+	h := t.beginHeader(enc, MajorTypeArray)
+
+	var n uint64
+	for t.dec.More() {
+		if err := t.transcodeValue(enc); err != nil {
+			return unexpectedEOF(err)
+		}
+		n++
+	}
+
+	// Consume the closing ']'.
+	if _, err := t.dec.Token(); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	t.endHeader(h, n)
+	return nil
+}
+
+// transcodeObject transcodes the members of a JSON object, after the opening
+// '{' has been read, into a CBOR map with text string keys.
+func (t *jsonTranscoder) transcodeObject(enc *Encoder) error {
+	h := t.beginHeader(enc, MajorTypeMap)
+
+	var n uint64
+	for t.dec.More() {
+		tok, err := t.dec.Token()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("cbor: invalid JSON object key %v", tok)
+		}
+		if err := enc.writeString(key); err != nil {
+			return err
+		}
+		if err := t.transcodeValue(enc); err != nil {
+			return unexpectedEOF(err)
+		}
+		n++
+	}
+
+	// Consume the closing '}'.
+	if _, err := t.dec.Token(); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	t.endHeader(h, n)
+	return nil
+}
+
+// transcodeNumber encodes a JSON number according to the configured
+// JSONNumberMode.
+func (t *jsonTranscoder) transcodeNumber(enc *Encoder, num json.Number) error {
+	s := num.String()
+
+	if t.options.NumberMode == JSONNumberAuto && !strings.ContainsAny(s, ".eE") {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return enc.writeInt(n)
+		}
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return enc.writeUint(n)
+		}
+		// Integers that don't fit in 64 bits fall back to floats.
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("cbor: invalid JSON number %q: %w", s, err)
+	}
+	return enc.writeFloat(f)
+}
+
+// CBORToJSON reads CBOR items from r and writes their JSON encoding to w,
+// followed by a newline, so a CBOR sequence becomes a stream of JSON
+// values which JSONToCBOR reads back as a sequence.
+//
+// The conversion follows RFC 8949, section 6.1, as for json.RawMessage:
+// byte strings are encoded as base64url strings without padding, tags are
+// left out around their content, and undefined and non-finite floats are
+// encoded as null. Map keys must be text strings or integers. The limits
+// in DefaultDecoderOptions apply to each item.
+func CBORToJSON(w io.Writer, r io.Reader) error {
+	br := bufio.NewReader(r)
+	dec := NewDecoder(br)
+	var (
+		raw RawMessage
+		out []byte
+	)
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		var err error
+		if out, _, err = appendJSON(out[:0], raw); err != nil {
+			return err
+		}
+		out = append(out, '\n')
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+}
+
+// jsonRawMessageType is the reflect.Type of json.RawMessage.
+var jsonRawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// encodeJSONRawMessage writes the CBOR encoding of the JSON value in a
+// json.RawMessage, transcoded with DefaultJSONOptions, rather than the
+// JSON text as a byte string. Empty messages are encoded as null.
+func encodeJSONRawMessage(e *Encoder, v reflect.Value) error {
+	raw := v.Bytes()
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return e.writeNull()
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	t := &jsonTranscoder{dec: dec, options: &DefaultJSONOptions}
+	if err := t.transcode(e); err != nil {
+		return fmt.Errorf("cbor: invalid json.RawMessage: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("cbor: invalid json.RawMessage: more than one JSON value")
+	}
+	return nil
+}
+
+// decodeJSONRawMessage decodes an item into a json.RawMessage by
+// transcoding it to JSON.
+func decodeJSONRawMessage(dec *Decoder, rv reflect.Value, b byte) error {
+	raw, err := dec.appendRawItem(nil, b, dec.depth)
+	if err != nil {
+		return err
+	}
+	out, _, err := appendJSON(nil, raw)
+	if err != nil {
+		return err
+	}
+	rv.SetBytes(out)
+	return nil
+}
+
+// appendJSON appends the JSON encoding of the well-formed CBOR item at the
+// start of data to dst, returning the rest of data.
+//
+// The conversion follows RFC 8949, section 6.1: byte strings are encoded
+// as base64url strings without padding, tags are left out around their
+// content, and undefined and non-finite floats are encoded as null. Map
+// keys must be text strings or integers, which are written as strings.
+func appendJSON(dst, data []byte) ([]byte, []byte, error) {
+	mt, ai, arg, n, err := parseHeader(data)
+	if err != nil {
+		return dst, data, err
+	}
+
+	switch mt {
+	case MajorTypeUnsignedInt:
+		return strconv.AppendUint(dst, arg, 10), data[n:], nil
+	case MajorTypeNegativeInt:
+		return appendNegative(dst, arg), data[n:], nil
+	case MajorTypeByteString, MajorTypeTextString:
+		s, rest, err := readStringBytes(data, mt)
+		if err != nil {
+			return dst, data, err
+		}
+		if mt == MajorTypeByteString {
+			dst = append(dst, '"')
+			dst = append(dst, base64.RawURLEncoding.EncodeToString(s)...)
+			return append(dst, '"'), rest, nil
+		}
+		return appendJSONString(dst, s), rest, nil
+	case MajorTypeArray, MajorTypeMap:
+		open, close := byte('['), byte(']')
+		if mt == MajorTypeMap {
+			open, close = '{', '}'
+		}
+		dst = append(dst, open)
+		data = data[n:]
+		for i := uint64(0); ai == 31 || i < arg; i++ {
+			if len(data) == 0 {
+				return dst, data, io.ErrUnexpectedEOF
+			}
+			if ai == 31 && data[0] == 0xff {
+				data = data[1:]
+				break
+			}
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if mt == MajorTypeMap {
+				if dst, data, err = appendJSONKey(dst, data); err != nil {
+					return dst, data, err
+				}
+				dst = append(dst, ':')
+			}
+			if dst, data, err = appendJSON(dst, data); err != nil {
+				return dst, data, err
+			}
+		}
+		return append(dst, close), data, nil
+	case MajorTypeTag:
+		return appendJSON(dst, data[n:])
+	}
+
+	switch {
+	case ai == 20:
+		return append(dst, "false"...), data[n:], nil
+	case ai == 21:
+		return append(dst, "true"...), data[n:], nil
+	case ai == 22 || ai == 23:
+		return append(dst, "null"...), data[n:], nil
+	case ai >= 25 && ai <= 27:
+		f, rest, err := ReadFloat64(data)
+		if err != nil {
+			return dst, data, err
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return append(dst, "null"...), rest, nil
+		}
+		return strconv.AppendFloat(dst, f, 'g', -1, 64), rest, nil
+	}
+	return dst, data, fmt.Errorf("cbor: cannot transcode simple value %d to JSON", arg)
+}
+
+// appendJSONKey appends the JSON object key of the CBOR map key at the
+// start of data to dst, returning the rest of data.
+func appendJSONKey(dst, data []byte) ([]byte, []byte, error) {
+	mt, _, arg, n, err := parseHeader(data)
+	if err != nil {
+		return dst, data, err
+	}
+	switch mt {
+	case MajorTypeTextString:
+		return appendJSON(dst, data)
+	case MajorTypeUnsignedInt:
+		dst = strconv.AppendUint(append(dst, '"'), arg, 10)
+		return append(dst, '"'), data[n:], nil
+	case MajorTypeNegativeInt:
+		dst = appendNegative(append(dst, '"'), arg)
+		return append(dst, '"'), data[n:], nil
+	}
+	return dst, data, fmt.Errorf("cbor: cannot transcode %s map key to JSON", mt)
+}
+
+// appendNegative appends the decimal value of the negative integer with
+// the given argument, -1-arg, which may be below math.MinInt64.
+func appendNegative(dst []byte, arg uint64) []byte {
+	if arg == math.MaxUint64 {
+		return append(dst, "-18446744073709551616"...)
+	}
+	return strconv.AppendUint(append(dst, '-'), arg+1, 10)
+}
+
+// appendJSONString appends s as a quoted JSON string to dst, replacing
+// invalid UTF-8 with U+FFFD.
+func appendJSONString(dst, s []byte) []byte {
+	const hexDigits = "0123456789abcdef"
+
+	dst = append(dst, '"')
+	for len(s) > 0 {
+		r, size := utf8.DecodeRune(s)
+		switch {
+		case r == '"' || r == '\\':
+			dst = append(dst, '\\', byte(r))
+		case r == '\n':
+			dst = append(dst, '\\', 'n')
+		case r == '\r':
+			dst = append(dst, '\\', 'r')
+		case r == '\t':
+			dst = append(dst, '\\', 't')
+		case r < 0x20:
+			dst = append(dst, '\\', 'u', '0', '0', hexDigits[r>>4], hexDigits[r&0xf])
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, "\\ufffd"...)
+		default:
+			dst = append(dst, s[:size]...)
+		}
+		s = s[size:]
+	}
+	return append(dst, '"')
+}
@@ -0,0 +1,190 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestJSONToCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		opts *cbor.JSONOptions
+		want string
+	}{
+		{
+			name: "object",
+			json: `{"a":[1,-2,3.5,"x",true,null]}`,
+			want: "a16161860121fb400c0000000000006178f5f6",
+		},
+		{
+			name: "key order preserved",
+			json: `{"b":1,"a":2}`,
+			want: "a2616201616102",
+		},
+		{
+			name: "large integers",
+			json: `[18446744073709551615,-9223372036854775808,18446744073709551616]`,
+			want: "831bffffffffffffffff3b7ffffffffffffffffb43f0000000000000",
+		},
+		{
+			name: "float mode",
+			json: `1`,
+			opts: &cbor.JSONOptions{NumberMode: cbor.JSONNumberFloat},
+			want: "fb3ff0000000000000",
+		},
+		{
+			name: "nested",
+			json: `[{"a":[[],{}]},[1]]`,
+			want: "82a161618280a08101",
+		},
+		{
+			name: "long array",
+			json: `[[0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0],[]]`,
+			want: "829818" + strings.Repeat("00", 24) + "80",
+		},
+		{
+			name: "sequence",
+			json: "1 \"a\"\n[]",
+			want: "01616180",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := cbor.JSONToCBOR(&buf, strings.NewReader(test.json), test.opts); err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != test.want {
+				t.Fatalf("expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestJSONToCBOR_longString(t *testing.T) {
+	s := strings.Repeat("x", 300)
+
+	var buf bytes.Buffer
+	if err := cbor.JSONToCBOR(&buf, strings.NewReader(`"`+s+`"`), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	if err := cbor.Unmarshal(buf.Bytes(), &value); err != nil {
+		t.Fatal(err)
+	}
+	if value != s {
+		t.Fatalf("expected %d bytes, got %d", len(s), len(value))
+	}
+}
+
+func TestJSONToCBOR_deep(t *testing.T) {
+	const depth = 5000
+
+	var buf bytes.Buffer
+	in := strings.Repeat("[", depth) + strings.Repeat("]", depth)
+	if err := cbor.JSONToCBOR(&buf, strings.NewReader(in), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Repeat("\x81", depth-1) + "\x80"
+	if got := buf.String(); got != want {
+		t.Fatalf("expected %d bytes, got %d", len(want), len(got))
+	}
+}
+
+func TestJSONToCBOR_truncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := cbor.JSONToCBOR(&buf, strings.NewReader(`{"a":[1,2`), nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestJSONRawMessage(t *testing.T) {
+	type event struct {
+		Kind    string
+		Payload json.RawMessage
+	}
+
+	v := event{Kind: "a", Payload: json.RawMessage(` {"id": 1, "tags": ["x", "y\n"], "ok": true, "n": null} `)}
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The payload is a CBOR map rather than a byte string.
+	want := "a2644b696e646161675061796c6f6164a46269640164746167738261786279" + "0a626f6bf5616ef6"
+	if got := hex.EncodeToString(data); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	var got event
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"id":1,"tags":["x","y\n"],"ok":true,"n":null}`; string(got.Payload) != want {
+		t.Fatalf("expected %s, got %s", want, got.Payload)
+	}
+
+	t.Run("from CBOR", func(t *testing.T) {
+		// {1: h'0102', -2: [1.5, undefined], "s": "<\"\x01\xff>"} with a tag
+		data, err := hex.DecodeString("a30142010221" + "82f93e00f7" + "6173" + "d820" + "653c2201ff3e")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var raw json.RawMessage
+		if err := cbor.Unmarshal(data, &raw); err != nil {
+			t.Fatal(err)
+		}
+		want := `{"1":"AQI","-2":[1.5,null],"s":"<\"\u0001\ufffd>"}`
+		if string(raw) != want {
+			t.Fatalf("expected %s, got %s", want, raw)
+		}
+		if !json.Valid(raw) {
+			t.Fatalf("expected valid JSON, got %s", raw)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := cbor.Marshal(json.RawMessage(`{"a":`)); err == nil {
+			t.Fatal("expected an error for truncated JSON")
+		}
+		if _, err := cbor.Marshal(json.RawMessage(`1 2`)); err == nil {
+			t.Fatal("expected an error for more than one JSON value")
+		}
+		var raw json.RawMessage
+		if err := cbor.Unmarshal([]byte{0xa1, 0x80, 0x01}, &raw); err == nil {
+			t.Fatal("expected an error for an array map key")
+		}
+	})
+}
+
+func TestCBORToJSON(t *testing.T) {
+	// {"a": [1, -2, h'ff'], 3: 1(1.5)}, followed by null.
+	data, _ := hex.DecodeString("a26161830121" + "41ff" + "03c1fb3ff8000000000000" + "f6")
+
+	var buf bytes.Buffer
+	if err := cbor.CBORToJSON(&buf, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "{\"a\":[1,-2,\"_w\"],\"3\":1.5}\nnull\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	// The output can be transcoded back.
+	var back bytes.Buffer
+	if err := cbor.JSONToCBOR(&back, &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cbor.CBORToJSON(&bytes.Buffer{}, bytes.NewReader([]byte{0x82, 0x01})); err == nil {
+		t.Fatal("expected error for truncated input")
+	}
+}
@@ -0,0 +1,626 @@
+package cbor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+// msgpackTimestamp is the MessagePack extension type of timestamps.
+const msgpackTimestamp = -1
+
+// msgpackWriter is the destination of transcoded items, which is buffered
+// while transcoding indefinite-length CBOR items.
+type msgpackWriter interface {
+	io.Writer
+	io.ByteWriter
+}
+
+// MsgpackToCBOR reads MessagePack values from r and writes their CBOR
+// encoding to w.
+//
+// The input is transcoded token by token, without decoding values into an
+// intermediate representation, so maps keep the order of their keys and
+// binary data is copied straight through. If r contains more than one
+// value, the output is a CBOR sequence (RFC 8742) with one item per value.
+//
+// Timestamps are converted to tag 1 Unix times, which are integers unless
+// they have a fractional part. Other extension types, and strings which
+// aren't valid UTF-8, can't be represented in CBOR and return an error.
+func MsgpackToCBOR(w io.Writer, r io.Reader) error {
+	t := &msgpackTranscoder{r: bufio.NewReader(r), w: bufio.NewWriter(w)}
+	for {
+		if _, err := t.r.Peek(1); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := t.msgpackItem(0); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+	return t.w.(*bufio.Writer).Flush()
+}
+
+// CBORToMsgpack reads CBOR items from r and writes their MessagePack
+// encoding to w.
+//
+// The input is transcoded token by token, so definite-length strings are
+// copied straight through. Indefinite-length items are buffered, since
+// MessagePack requires lengths up front. If r contains a CBOR sequence,
+// the output contains one MessagePack value per item.
+//
+// Tag 1 Unix times are converted to timestamps, and undefined to nil.
+// Other tags, simple values, and integers which don't fit in an int64 or
+// uint64 can't be represented in MessagePack and return an error.
+func CBORToMsgpack(w io.Writer, r io.Reader) error {
+	t := &msgpackTranscoder{r: bufio.NewReader(r), w: bufio.NewWriter(w)}
+	for {
+		if _, err := t.r.Peek(1); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := t.cborItem(0); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+	return t.w.(*bufio.Writer).Flush()
+}
+
+// msgpackTranscoder converts between streams of MessagePack and CBOR
+// tokens.
+type msgpackTranscoder struct {
+	r *bufio.Reader
+	w msgpackWriter
+
+	// scratch is used to read and write headers.
+	scratch [16]byte
+}
+
+// readN reads n bytes into the scratch buffer, where n is at most 16.
+func (t *msgpackTranscoder) readN(n int) ([]byte, error) {
+	b := t.scratch[:n]
+	if _, err := io.ReadFull(t.r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// readUint reads a big-endian unsigned integer of n bytes.
+func (t *msgpackTranscoder) readUint(n int) (uint64, error) {
+	b, err := t.readN(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// copyN copies n bytes from the input to the output.
+func (t *msgpackTranscoder) copyN(n uint64) error {
+	if n > math.MaxInt64 {
+		return errors.New("cbor: string too long")
+	}
+	_, err := io.CopyN(t.w, t.r, int64(n))
+	return err
+}
+
+// writeHeader writes a CBOR header.
+func (t *msgpackTranscoder) writeHeader(mt MajorType, n uint64) error {
+	_, err := t.w.Write(appendHeader(t.scratch[:0], mt, n))
+	return err
+}
+
+// msgpackItem reads the next MessagePack value and writes it as CBOR.
+func (t *msgpackTranscoder) msgpackItem(depth int) error {
+	if depth > maxNestingDepth {
+		return errors.New("cbor: exceeded max nesting depth")
+	}
+
+	b, err := t.r.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return t.writeHeader(MajorTypeUnsignedInt, uint64(b))
+	case b >= 0xe0:
+		_, err := t.w.Write(AppendInt(t.scratch[:0], int64(int8(b))))
+		return err
+	case b <= 0x8f:
+		return t.msgpackMap(uint64(b&0x0f), depth)
+	case b <= 0x9f:
+		return t.msgpackArray(uint64(b&0x0f), depth)
+	case b <= 0xbf:
+		return t.msgpackString(uint64(b & 0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return t.w.WriteByte(0xf6)
+	case 0xc2:
+		return t.w.WriteByte(0xf4)
+	case 0xc3:
+		return t.w.WriteByte(0xf5)
+	case 0xc4, 0xc5, 0xc6: // bin 8, 16, 32
+		n, err := t.readUint(1 << (b - 0xc4))
+		if err != nil {
+			return err
+		}
+		if err := t.writeHeader(MajorTypeByteString, n); err != nil {
+			return err
+		}
+		return t.copyN(n)
+	case 0xc7, 0xc8, 0xc9: // ext 8, 16, 32
+		n, err := t.readUint(1 << (b - 0xc7))
+		if err != nil {
+			return err
+		}
+		return t.msgpackExt(n)
+	case 0xca: // float 32
+		data, err := t.readN(4)
+		if err != nil {
+			return err
+		}
+		_, err = t.w.Write(append([]byte{0xfa}, data...))
+		return err
+	case 0xcb: // float 64
+		data, err := t.readN(8)
+		if err != nil {
+			return err
+		}
+		_, err = t.w.Write(append([]byte{0xfb}, data...))
+		return err
+	case 0xcc, 0xcd, 0xce, 0xcf: // uint 8, 16, 32, 64
+		n, err := t.readUint(1 << (b - 0xcc))
+		if err != nil {
+			return err
+		}
+		return t.writeHeader(MajorTypeUnsignedInt, n)
+	case 0xd0, 0xd1, 0xd2, 0xd3: // int 8, 16, 32, 64
+		size := 1 << (b - 0xd0)
+		n, err := t.readUint(size)
+		if err != nil {
+			return err
+		}
+		// Sign extend the value to 64 bits.
+		shift := 64 - 8*size
+		v := int64(n<<shift) >> shift
+		_, err = t.w.Write(AppendInt(t.scratch[:0], v))
+		return err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8: // fixext 1, 2, 4, 8, 16
+		return t.msgpackExt(1 << (b - 0xd4))
+	case 0xd9, 0xda, 0xdb: // str 8, 16, 32
+		n, err := t.readUint(1 << (b - 0xd9))
+		if err != nil {
+			return err
+		}
+		return t.msgpackString(n)
+	case 0xdc, 0xdd: // array 16, 32
+		n, err := t.readUint(2 << (b - 0xdc))
+		if err != nil {
+			return err
+		}
+		return t.msgpackArray(n, depth)
+	case 0xde, 0xdf: // map 16, 32
+		n, err := t.readUint(2 << (b - 0xde))
+		if err != nil {
+			return err
+		}
+		return t.msgpackMap(n, depth)
+	default:
+		return fmt.Errorf("cbor: invalid MessagePack byte 0x%02x", b)
+	}
+}
+
+// msgpackString transcodes a MessagePack string of n bytes, checking that
+// it is valid UTF-8.
+func (t *msgpackTranscoder) msgpackString(n uint64) error {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, t.r, int64(n)); err != nil {
+		return err
+	}
+	if !utf8.Valid(buf.Bytes()) {
+		return errors.New("cbor: invalid UTF-8 in MessagePack string")
+	}
+	if err := t.writeHeader(MajorTypeTextString, n); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(t.w)
+	return err
+}
+
+// msgpackArray transcodes the n elements of a MessagePack array.
+func (t *msgpackTranscoder) msgpackArray(n uint64, depth int) error {
+	if err := t.writeHeader(MajorTypeArray, n); err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		if err := t.msgpackItem(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// msgpackMap transcodes the n key/value pairs of a MessagePack map.
+func (t *msgpackTranscoder) msgpackMap(n uint64, depth int) error {
+	if err := t.writeHeader(MajorTypeMap, n); err != nil {
+		return err
+	}
+	for i := uint64(0); i < 2*n; i++ {
+		if err := t.msgpackItem(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// msgpackExt transcodes a MessagePack extension with n bytes of data,
+// which must be a timestamp.
+func (t *msgpackTranscoder) msgpackExt(n uint64) error {
+	typ, err := t.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if int8(typ) != msgpackTimestamp {
+		return fmt.Errorf("cbor: unsupported MessagePack extension type %d", int8(typ))
+	}
+
+	var (
+		sec  int64
+		nsec uint32
+	)
+	switch n {
+	case 4:
+		v, err := t.readUint(4)
+		if err != nil {
+			return err
+		}
+		sec = int64(v)
+	case 8:
+		v, err := t.readUint(8)
+		if err != nil {
+			return err
+		}
+		nsec, sec = uint32(v>>34), int64(v&(1<<34-1))
+	case 12:
+		data, err := t.readN(12)
+		if err != nil {
+			return err
+		}
+		nsec, sec = binary.BigEndian.Uint32(data), int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		return fmt.Errorf("cbor: invalid MessagePack timestamp length %d", n)
+	}
+	if nsec > 999999999 {
+		return errors.New("cbor: invalid MessagePack timestamp nanoseconds")
+	}
+
+	dst := AppendTag(t.scratch[:0], uint64(TagUnixTime))
+	if nsec == 0 {
+		dst = AppendInt(dst, sec)
+	} else {
+		dst = AppendFloat64(dst, float64(sec)+float64(nsec)/1e9)
+	}
+	_, err = t.w.Write(dst)
+	return err
+}
+
+// writeMsgpackHeader writes the header of a MessagePack string, binary,
+// array, or map using the shortest format. fix is the fixed format
+// prefix, or 0 if there isn't one, with fixMax its maximum length, and
+// formats are the 8, 16, and 32-bit formats, or 0 where they don't exist.
+func (t *msgpackTranscoder) writeMsgpackHeader(n uint64, fix byte, fixMax uint64, formats [3]byte) error {
+	dst := t.scratch[:0]
+	switch {
+	case fix != 0 && n <= fixMax:
+		dst = append(dst, fix|byte(n))
+	case formats[0] != 0 && n <= math.MaxUint8:
+		dst = append(dst, formats[0], byte(n))
+	case n <= math.MaxUint16:
+		dst = append(dst, formats[1], byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		dst = append(dst, formats[2], byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	default:
+		return errors.New("cbor: length too long for MessagePack")
+	}
+	_, err := t.w.Write(dst)
+	return err
+}
+
+// writeMsgpackInt writes v using the shortest MessagePack integer format.
+func (t *msgpackTranscoder) writeMsgpackInt(v int64) error {
+	if v >= 0 {
+		return t.writeMsgpackUint(uint64(v))
+	}
+
+	dst := t.scratch[:0]
+	switch {
+	case v >= -32:
+		dst = append(dst, byte(v))
+	case v >= math.MinInt8:
+		dst = append(dst, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		dst = append(dst, 0xd1, byte(v>>8), byte(v))
+	case v >= math.MinInt32:
+		dst = append(dst, 0xd2, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		dst = append(dst, 0xd3)
+		dst = binary.BigEndian.AppendUint64(dst, uint64(v))
+	}
+	_, err := t.w.Write(dst)
+	return err
+}
+
+// writeMsgpackUint writes v using the shortest MessagePack integer format.
+func (t *msgpackTranscoder) writeMsgpackUint(v uint64) error {
+	dst := t.scratch[:0]
+	switch {
+	case v <= 0x7f:
+		dst = append(dst, byte(v))
+	case v <= math.MaxUint8:
+		dst = append(dst, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		dst = append(dst, 0xcd, byte(v>>8), byte(v))
+	case v <= math.MaxUint32:
+		dst = append(dst, 0xce, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		dst = append(dst, 0xcf)
+		dst = binary.BigEndian.AppendUint64(dst, v)
+	}
+	_, err := t.w.Write(dst)
+	return err
+}
+
+// readCBORHeader reads a CBOR header whose initial byte b has already been
+// read, returning its additional information and argument.
+func (t *msgpackTranscoder) readCBORHeader(b byte) (byte, uint64, error) {
+	ai := b & 0x1f
+	switch {
+	case ai < 24:
+		return ai, uint64(ai), nil
+	case ai <= 27:
+		n, err := t.readUint(1 << (ai - 24))
+		return ai, n, err
+	case ai == 31:
+		return ai, 0, nil
+	default:
+		return 0, 0, newError(ErrMalformed, fmt.Sprintf("cbor: invalid additional information %d", ai))
+	}
+}
+
+// cborItem reads the next CBOR item and writes it as MessagePack.
+func (t *msgpackTranscoder) cborItem(depth int) error {
+	if depth > maxNestingDepth {
+		return errors.New("cbor: exceeded max nesting depth")
+	}
+
+	b, err := t.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b == 0xff {
+		return errors.New("cbor: unexpected break")
+	}
+
+	mt := MajorType(b >> 5)
+	ai, arg, err := t.readCBORHeader(b)
+	if err != nil {
+		return err
+	}
+
+	switch mt {
+	case MajorTypeUnsignedInt, MajorTypeNegativeInt, MajorTypeTag:
+		if ai == 31 {
+			return newError(ErrMalformed, fmt.Sprintf("cbor: invalid indefinite-length %s", mt))
+		}
+	}
+
+	switch mt {
+	case MajorTypeUnsignedInt:
+		return t.writeMsgpackUint(arg)
+	case MajorTypeNegativeInt:
+		if arg > math.MaxInt64 {
+			return fmt.Errorf("cbor: integer -1-%d overflows MessagePack int64", arg)
+		}
+		return t.writeMsgpackInt(-1 - int64(arg))
+	case MajorTypeByteString, MajorTypeTextString:
+		var fix byte
+		var fixMax uint64
+		formats := [3]byte{0xc4, 0xc5, 0xc6}
+		if mt == MajorTypeTextString {
+			fix, fixMax, formats = 0xa0, 31, [3]byte{0xd9, 0xda, 0xdb}
+		}
+
+		if ai != 31 {
+			if err := t.writeMsgpackHeader(arg, fix, fixMax, formats); err != nil {
+				return err
+			}
+			return t.copyN(arg)
+		}
+
+		// Concatenate the chunks of indefinite-length strings.
+		var buf bytes.Buffer
+		for {
+			c, err := t.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if c == 0xff {
+				break
+			}
+			cai, n, err := t.readCBORHeader(c)
+			if err != nil {
+				return err
+			}
+			if MajorType(c>>5) != mt || cai == 31 {
+				return newError(ErrMalformed, fmt.Sprintf("cbor: invalid chunk in indefinite-length %s", mt))
+			}
+			if _, err := io.CopyN(&buf, t.r, int64(n)); err != nil {
+				return err
+			}
+		}
+		if err := t.writeMsgpackHeader(uint64(buf.Len()), fix, fixMax, formats); err != nil {
+			return err
+		}
+		_, err := buf.WriteTo(t.w)
+		return err
+	case MajorTypeArray, MajorTypeMap:
+		fix, formats := byte(0x90), [3]byte{0, 0xdc, 0xdd}
+		if mt == MajorTypeMap {
+			fix, formats = 0x80, [3]byte{0, 0xde, 0xdf}
+		}
+
+		if ai != 31 {
+			if err := t.writeMsgpackHeader(arg, fix, 15, formats); err != nil {
+				return err
+			}
+			count := arg
+			if mt == MajorTypeMap {
+				count *= 2
+			}
+			for i := uint64(0); i < count; i++ {
+				if err := t.cborItem(depth + 1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		// Buffer the items of indefinite-length arrays and maps to count
+		// them.
+		w := t.w
+		var buf bytes.Buffer
+		t.w = &buf
+		defer func() { t.w = w }()
+
+		var items uint64
+		for ; ; items++ {
+			c, err := t.r.Peek(1)
+			if err != nil {
+				return err
+			}
+			if c[0] == 0xff {
+				t.r.ReadByte()
+				break
+			}
+			if err := t.cborItem(depth + 1); err != nil {
+				return err
+			}
+		}
+		if mt == MajorTypeMap {
+			if items%2 != 0 {
+				return errors.New("cbor: indefinite-length map has a key without a value")
+			}
+			items /= 2
+		}
+
+		t.w = w
+		if err := t.writeMsgpackHeader(items, fix, 15, formats); err != nil {
+			return err
+		}
+		_, err := buf.WriteTo(t.w)
+		return err
+	case MajorTypeTag:
+		if Tag(arg) != TagUnixTime {
+			return fmt.Errorf("cbor: cannot convert tag %d to MessagePack", arg)
+		}
+		return t.cborUnixTime()
+	default: // MajorTypeSimple
+		switch ai {
+		case 20:
+			return t.w.WriteByte(0xc2)
+		case 21:
+			return t.w.WriteByte(0xc3)
+		case 22, 23:
+			return t.w.WriteByte(0xc0)
+		case 25:
+			f := float32(float16ToFloat64(uint16(arg)))
+			dst := append(t.scratch[:0], 0xca)
+			dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(f))
+			_, err := t.w.Write(dst)
+			return err
+		case 26:
+			dst := append(t.scratch[:0], 0xca)
+			dst = binary.BigEndian.AppendUint32(dst, uint32(arg))
+			_, err := t.w.Write(dst)
+			return err
+		case 27:
+			dst := append(t.scratch[:0], 0xcb)
+			dst = binary.BigEndian.AppendUint64(dst, arg)
+			_, err := t.w.Write(dst)
+			return err
+		default:
+			return fmt.Errorf("cbor: cannot convert simple value %d to MessagePack", arg)
+		}
+	}
+}
+
+// cborUnixTime reads the content of a tag 1 Unix time, and writes it as
+// a MessagePack timestamp using the shortest format.
+func (t *msgpackTranscoder) cborUnixTime() error {
+	b, err := t.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	ai, arg, err := t.readCBORHeader(b)
+	if err != nil {
+		return err
+	}
+
+	var (
+		sec  int64
+		nsec uint32
+	)
+	switch mt := MajorType(b >> 5); {
+	case mt == MajorTypeUnsignedInt && ai != 31 && arg <= math.MaxInt64:
+		sec = int64(arg)
+	case mt == MajorTypeNegativeInt && ai != 31 && arg <= math.MaxInt64:
+		sec = -1 - int64(arg)
+	case mt == MajorTypeSimple && ai >= 25 && ai <= 27:
+		f := math.Float64frombits(arg)
+		switch ai {
+		case 25:
+			f = float16ToFloat64(uint16(arg))
+		case 26:
+			f = float64(math.Float32frombits(uint32(arg)))
+		}
+		if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return errors.New("cbor: Unix time out of range")
+		}
+		whole := math.Floor(f)
+		sec, nsec = int64(whole), uint32(math.Round((f-whole)*1e9))
+		if nsec == 1e9 {
+			sec, nsec = sec+1, 0
+		}
+	default:
+		return errors.New("cbor: invalid Unix time")
+	}
+
+	dst := t.scratch[:0]
+	switch {
+	case nsec == 0 && sec >= 0 && sec <= math.MaxUint32:
+		dst = append(dst, 0xd6, 0xff)
+		dst = binary.BigEndian.AppendUint32(dst, uint32(sec))
+	case sec >= 0 && sec < 1<<34:
+		dst = append(dst, 0xd7, 0xff)
+		dst = binary.BigEndian.AppendUint64(dst, uint64(nsec)<<34|uint64(sec))
+	default:
+		dst = append(dst, 0xc7, 12, 0xff)
+		dst = binary.BigEndian.AppendUint32(dst, nsec)
+		dst = binary.BigEndian.AppendUint64(dst, uint64(sec))
+	}
+	_, err = t.w.Write(dst)
+	return err
+}
@@ -0,0 +1,142 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestMsgpack(t *testing.T) {
+	// Pairs of equivalent MessagePack and CBOR encodings, which are
+	// transcoded to each other in both directions.
+	tests := []struct {
+		name    string
+		msgpack string
+		cbor    string
+	}{
+		{"positive fixint", "7f", "187f"},
+		{"uint 8", "cc80", "1880"},
+		{"uint 16", "cd0100", "190100"},
+		{"uint 32", "ce00010000", "1a00010000"},
+		{"uint 64", "cf0000000100000000", "1b0000000100000000"},
+		{"negative fixint", "e0", "381f"},
+		{"int 8", "d0df", "3820"},
+		{"int 16", "d1ff7f", "3880"},
+		{"int 64", "d38000000000000000", "3b7fffffffffffffff"},
+		{"nil", "c0", "f6"},
+		{"false", "c2", "f4"},
+		{"true", "c3", "f5"},
+		{"string", "a3666f6f", "63666f6f"},
+		{"binary", "c4020102", "420102"},
+		{"array", "93010203", "83010203"},
+		{"map", "82a16101a16202", "a2616101616202"},
+		{"float 32", "ca3fc00000", "fa3fc00000"},
+		{"float 64", "cb3ff199999999999a", "fb3ff199999999999a"},
+		{"timestamp 32", "d6ff5f5e1000", "c11a5f5e1000"},
+		{"timestamp 64", "d7ff773594005f5e1000", "c1fb41d7d78400200000"},
+		{"timestamp 96", "c70cff00000000ffffffffffffffff", "c120"},
+		{"sequence", "0102", "0102"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msgpack, err := hex.DecodeString(test.msgpack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := hex.DecodeString(test.cbor)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := cbor.MsgpackToCBOR(&buf, bytes.NewReader(msgpack)); err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != test.cbor {
+				t.Errorf("MsgpackToCBOR: expected %s, got %s", test.cbor, got)
+			}
+
+			buf.Reset()
+			if err := cbor.CBORToMsgpack(&buf, bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != test.msgpack {
+				t.Errorf("CBORToMsgpack: expected %s, got %s", test.msgpack, got)
+			}
+		})
+	}
+}
+
+func TestCBORToMsgpack(t *testing.T) {
+	tests := []struct {
+		name string
+		cbor string
+		want string
+	}{
+		{"indefinite-length string", "7f61616162ff", "a26162"},
+		{"indefinite-length array", "9f01819f02ffff", "9201919102"},
+		{"indefinite-length map", "bf616101ff", "81a16101"},
+		{"float 16", "f93e00", "ca3fc00000"},
+		{"undefined", "f7", "c0"},
+		{"long string", "7820" + strings.Repeat("61", 32), "d920" + strings.Repeat("61", 32)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := hex.DecodeString(test.cbor)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := cbor.CBORToMsgpack(&buf, bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != test.want {
+				t.Fatalf("expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestMsgpack_errors(t *testing.T) {
+	toCBOR := []string{
+		"c1",       // never used
+		"d40101",   // unsupported extension type
+		"a1ff",     // invalid UTF-8
+		"d5ff0000", // invalid timestamp length
+		"92",       // truncated
+	}
+	for _, s := range toCBOR {
+		data, _ := hex.DecodeString(s)
+		if err := cbor.MsgpackToCBOR(io.Discard, bytes.NewReader(data)); err == nil {
+			t.Errorf("MsgpackToCBOR(%s): expected an error", s)
+		}
+	}
+
+	toMsgpack := []string{
+		"c249010000000000000000", // bignum
+		"3bffffffffffffffff",     // overflows int64
+		"f0",                     // simple value
+		"ff",                     // break
+		"bf01ff",                 // key without a value
+		"82",                     // truncated
+	}
+	for _, s := range toMsgpack {
+		data, _ := hex.DecodeString(s)
+		if err := cbor.CBORToMsgpack(io.Discard, bytes.NewReader(data)); err == nil {
+			t.Errorf("CBORToMsgpack(%s): expected an error", s)
+		}
+	}
+
+	err := cbor.CBORToMsgpack(io.Discard, bytes.NewReader([]byte{0x82, 0x01}))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package cbor
+
+// MustMarshal is like Marshal but panics if v can't be encoded. It
+// simplifies the encoding of fixtures in tests, and of constants in
+// package initialization, which are known to be encodable.
+func MustMarshal(v interface{}) []byte {
+	data, err := Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// MustUnmarshal is like UnmarshalT but panics if data can't be decoded
+// into a value of type T. It simplifies decoding fixtures in tests, and
+// constants in package initialization, which are known to be valid.
+func MustUnmarshal[T any](data []byte) T {
+	v, err := UnmarshalT[T](data)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -0,0 +1,46 @@
+package cbor_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestMust(t *testing.T) {
+	data := cbor.MustMarshal(map[string]int{"a": 1})
+	if got := cbor.MustUnmarshal[map[string]int](data); got["a"] != 1 {
+		t.Fatalf("expected a = 1, got %v", got)
+	}
+
+	expectPanic := func(t *testing.T, target error, f func()) {
+		t.Helper()
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.Is(err, target) {
+				t.Fatalf("expected a panic with %v, got %v", target, err)
+			}
+		}()
+		f()
+	}
+
+	t.Run("marshal", func(t *testing.T) {
+		var target *cbor.UnsupportedTypeError
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.As(err, &target) {
+				t.Fatalf("expected a panic with an UnsupportedTypeError, got %v", err)
+			}
+		}()
+		cbor.MustMarshal(make(chan int))
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		expectPanic(t, cbor.ErrTruncated, func() {
+			cbor.MustUnmarshal[[]int]([]byte{0x82, 0x01})
+		})
+		expectPanic(t, cbor.ErrInvalidType, func() {
+			cbor.MustUnmarshal[string]([]byte{0x01})
+		})
+	})
+}
@@ -0,0 +1,77 @@
+package cbor
+
+import "reflect"
+
+// Optional is a value which may be absent, for optional protocol fields
+// without using pointers. An absent value is encoded as null, or omitted
+// from structs with the omitempty option, and null, undefined and missing
+// fields are decoded as absent.
+type Optional[T any] struct {
+	// Value is the value, if it's present.
+	Value T
+
+	// Present reports whether the value is present.
+	Present bool
+}
+
+// Some returns an Optional with the value v present.
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{Value: v, Present: true}
+}
+
+// Get returns the value and whether it's present.
+func (o Optional[T]) Get() (T, bool) {
+	return o.Value, o.Present
+}
+
+// optional is implemented by every Optional type.
+func (o Optional[T]) optional() {}
+
+// optionalType is the reflect.Type of the interface implemented by every
+// Optional type.
+var optionalType = reflect.TypeOf((*interface{ optional() })(nil)).Elem()
+
+// nullableValid returns the index of the field of t, a struct, reporting
+// whether its first field is valid, if t is an Optional or one of
+// sqlNullTypes.
+func nullableValid(t reflect.Type) (int, bool) {
+	switch {
+	case sqlNullTypes[t]:
+		f, _ := t.FieldByName("Valid")
+		return f.Index[0], true
+	case t.Kind() == reflect.Struct && t.Implements(optionalType):
+		return 1, true
+	}
+	return 0, false
+}
+
+// compileNullableEncoder returns the encoderFunc for a type whose first
+// field is valid if the field at index valid is true, which encodes null
+// if it isn't, and the first field otherwise.
+func compileNullableEncoder(t reflect.Type, valid int) encoderFunc {
+	enc := encoderFor(t.Field(0).Type)
+	return func(e *Encoder, v reflect.Value) error {
+		if !v.Field(valid).Bool() {
+			return e.writeNull()
+		}
+		return enc(e, v.Field(0))
+	}
+}
+
+// compileNullableDecoder returns the decoderFunc for a type whose first
+// field is valid if the field at index valid is true, which decodes null
+// and undefined as the zero value, and other items into the first field.
+func compileNullableDecoder(t reflect.Type, valid int) decoderFunc {
+	dec := decoderFor(t.Field(0).Type)
+	return func(d *Decoder, rv reflect.Value, b byte) error {
+		if b == 0xf6 || b == 0xf7 {
+			rv.Set(reflect.Zero(t))
+			return nil
+		}
+		if err := dec(d, rv.Field(0), b); err != nil {
+			return err
+		}
+		rv.Field(valid).SetBool(true)
+		return nil
+	}
+}
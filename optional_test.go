@@ -0,0 +1,57 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestOptional(t *testing.T) {
+	type reading struct {
+		Temp  cbor.Optional[float64] `cbor:"1,keyasint,omitempty"`
+		Label cbor.Optional[string]  `cbor:"2,keyasint"`
+	}
+
+	tests := []struct {
+		name string
+		v    reading
+		want string
+	}{
+		{"present", reading{Temp: cbor.Some(21.5), Label: cbor.Some("")}, "a201fb403580000000000002" + "60"},
+		{"absent", reading{}, "a102f6"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := cbor.Marshal(test.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(data); got != test.want {
+				t.Fatalf("expected %s, got %s", test.want, got)
+			}
+
+			got := reading{Temp: cbor.Some(1.0), Label: cbor.Some("stale")}
+			if err := cbor.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			// Absent fields are left as they were, like other types.
+			if !test.v.Temp.Present {
+				got.Temp = cbor.Optional[float64]{}
+			}
+			if !reflect.DeepEqual(got, test.v) {
+				t.Fatalf("expected %+v, got %+v", test.v, got)
+			}
+		})
+	}
+
+	// Undefined is absent too.
+	o, err := cbor.UnmarshalT[cbor.Optional[int]]([]byte{0xf7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := o.Get(); ok || v != 0 {
+		t.Fatalf("expected an absent value, got %v, %v", v, ok)
+	}
+}
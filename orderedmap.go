@@ -0,0 +1,118 @@
+package cbor
+
+import "reflect"
+
+// KeyValue is a key and value pair of an OrderedMap.
+type KeyValue struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// OrderedMap is a map which keeps the order of its pairs. It's encoded as
+// a map with the pairs in the order of the slice, rather than in the
+// random iteration order of Go maps, and decoded with the pairs in the order they were encoded, for
+// protocols where the order of map pairs is significant.
+//
+// Keys are decoded as they would be into interface values, so byte strings
+// are ByteString, and values which are maps are decoded as OrderedMap too.
+// Duplicate keys are kept, and a nil OrderedMap is encoded as null.
+type OrderedMap []KeyValue
+
+// orderedMapType is the reflect.Type of OrderedMap.
+var orderedMapType = reflect.TypeOf(OrderedMap(nil))
+
+// Get returns the value of the first pair of m with the given key, and
+// whether there is one.
+func (m OrderedMap) Get(key interface{}) (interface{}, bool) {
+	for _, kv := range m {
+		if reflect.DeepEqual(kv.Key, key) {
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// encodeOrderedMap writes an OrderedMap as a map, in the order of its pairs.
+func encodeOrderedMap(e *Encoder, v reflect.Value) error {
+	if v.IsNil() {
+		return e.writeNull()
+	}
+	m := v.Interface().(OrderedMap)
+	if err := e.writeHeader(MajorTypeMap, uint64(len(m))); err != nil {
+		return err
+	}
+	if err := e.nest(); err != nil {
+		return err
+	}
+	defer e.unnest()
+	for i := range m {
+		if err := encodeInterface(e, reflect.ValueOf(&m[i].Key).Elem()); err != nil {
+			return err
+		}
+		if err := encodeInterface(e, reflect.ValueOf(&m[i].Value).Elem()); err != nil {
+			return err
+		}
+		if err := e.flushFull(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeOrderedMap decodes a map into an OrderedMap, with its pairs in
+// the order they were encoded. Other items are decoded as usual.
+func decodeOrderedMap(dec *Decoder, rv reflect.Value, b byte) error {
+	if MajorType(b>>5) != MajorTypeMap {
+		return dec.decodeItem(rv, b)
+	}
+	if dec.depth >= dec.options.MaxDepth {
+		return dec.limitExceeded(ErrMaxDepth)
+	}
+	dec.depth++
+	defer func() { dec.depth-- }()
+
+	ai := b & 0x1f
+	n, err := dec.readMapLength(ai)
+	if err != nil {
+		return err
+	}
+
+	m := make(OrderedMap, 0, dec.allocHint(n, 2))
+	for i := uint64(0); ai == 31 || i < n; i++ {
+		c, err := dec.readByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if ai == 31 && c == 0xff {
+			break
+		}
+		if i >= uint64(dec.options.MaxMapPairs) {
+			return dec.limitExceeded(ErrMapTooLong)
+		}
+		var kv KeyValue
+		if err := dec.decodeItem(reflect.ValueOf(&kv.Key).Elem(), c); err != nil {
+			return err
+		}
+		if kv.Key, err = hashableKey(kv.Key); err != nil {
+			return err
+		}
+
+		c, err = dec.readByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if MajorType(c>>5) == MajorTypeMap {
+			var nested OrderedMap
+			err = decodeOrderedMap(dec, reflect.ValueOf(&nested).Elem(), c)
+			kv.Value = nested
+		} else {
+			err = dec.decodeItem(reflect.ValueOf(&kv.Value).Elem(), c)
+		}
+		if err != nil {
+			return dec.pathError(err, keyPath(kv.Key))
+		}
+		m = append(m, kv)
+	}
+	rv.Set(reflect.ValueOf(m))
+	return nil
+}
@@ -0,0 +1,65 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestOrderedMap(t *testing.T) {
+	m := cbor.OrderedMap{
+		{Key: "z", Value: uint64(1)},
+		{Key: "a", Value: cbor.OrderedMap{
+			{Key: uint64(2), Value: "two"},
+			{Key: uint64(1), Value: "one"},
+		}},
+		{Key: cbor.ByteString("\x01"), Value: nil},
+	}
+
+	data, err := cbor.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a3617a016161a2026374776f01636f6e654101f6"
+	if got := hex.EncodeToString(data); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	var got cbor.OrderedMap
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Fatalf("expected %#v, got %#v", m, got)
+	}
+
+	if v, ok := got.Get("a"); !ok || len(v.(cbor.OrderedMap)) != 2 {
+		t.Fatalf("expected nested map for key a, got %#v, %v", v, ok)
+	}
+	if _, ok := got.Get("missing"); ok {
+		t.Fatal("expected no value for a missing key")
+	}
+
+	t.Run("indefinite", func(t *testing.T) {
+		var got cbor.OrderedMap
+		if err := cbor.Unmarshal([]byte{0xbf, 0x61, 0x62, 0x01, 0x61, 0x61, 0x02, 0xff}, &got); err != nil {
+			t.Fatal(err)
+		}
+		want := cbor.OrderedMap{{Key: "b", Value: uint64(1)}, {Key: "a", Value: uint64(2)}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %#v, got %#v", want, got)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		data, err := cbor.Marshal(cbor.OrderedMap(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(data); got != "f6" {
+			t.Fatalf("expected f6, got %s", got)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package cbor
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelBatchSize is the number of items claimed at once by each worker
+// of UnmarshalSequenceParallel, to limit contention on the shared counter.
+const parallelBatchSize = 64
+
+// UnmarshalSequenceParallel decodes the items of a CBOR sequence (RFC 8742)
+// in data into a slice of values of type T, using the given number of
+// workers. If workers is zero or negative, GOMAXPROCS workers are used.
+//
+// The sequence is first split into items with a single well-formedness
+// scan, so no item is decoded if data is malformed. The items are then
+// decoded concurrently with UnmarshalT, so T must be safe to decode in
+// parallel, which is the case unless its UnmarshalCBOR method shares
+// state between values.
+//
+// If any item fails to decode, the error of the first failing item in
+// the sequence is returned, and the remaining items may not be decoded.
+func UnmarshalSequenceParallel[T any](data []byte, workers int) ([]T, error) {
+	items, err := splitSequence(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if batches := (len(items) + parallelBatchSize - 1) / parallelBatchSize; workers > batches {
+		workers = batches
+	}
+
+	out := make([]T, len(items))
+
+	var (
+		next   int64 // next batch to claim
+		failed int32 // set once any item fails
+
+		mu       sync.Mutex
+		firstErr error
+		errIndex = len(items)
+
+		wg sync.WaitGroup
+	)
+
+	// Batches are claimed in order and always decoded up to the first
+	// error in them, so every item before a failing item is decoded, and
+	// the error with the lowest index is the first one in the sequence.
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&failed) == 0 {
+				start := int(atomic.AddInt64(&next, 1)-1) * parallelBatchSize
+				if start >= len(items) {
+					return
+				}
+				end := start + parallelBatchSize
+				if end > len(items) {
+					end = len(items)
+				}
+
+				for i := start; i < end; i++ {
+					v, err := UnmarshalT[T](items[i])
+					if err != nil {
+						atomic.StoreInt32(&failed, 1)
+						mu.Lock()
+						if i < errIndex {
+							firstErr, errIndex = err, i
+						}
+						mu.Unlock()
+						return
+					}
+					out[i] = v
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, fmt.Errorf("cbor: sequence item %d: %w", errIndex, firstErr)
+	}
+	return out, nil
+}
+
+// splitSequence splits a CBOR sequence into its items, checking that each
+// one is well-formed.
+func splitSequence(data []byte) ([][]byte, error) {
+	var items [][]byte
+	for off := 0; off < len(data); {
+		n, err := itemLength(data[off:], 0)
+		if err != nil {
+			return nil, fmt.Errorf("cbor: sequence item %d at offset %d: %w", len(items), off, err)
+		}
+		items = append(items, data[off:off+n:off+n])
+		off += n
+	}
+	return items, nil
+}
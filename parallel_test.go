@@ -0,0 +1,86 @@
+package cbor_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestUnmarshalSequenceParallel(t *testing.T) {
+	type record struct {
+		ID   int    `cbor:"id"`
+		Name string `cbor:"name"`
+	}
+
+	var data []byte
+	for i := 0; i < 1000; i++ {
+		b, err := cbor.Marshal(record{ID: i, Name: "r"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, b...)
+	}
+
+	for _, workers := range []int{0, 1, 7} {
+		records, err := cbor.UnmarshalSequenceParallel[record](data, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1000 {
+			t.Fatalf("expected 1000 records, got %d", len(records))
+		}
+		for i, r := range records {
+			if r.ID != i || r.Name != "r" {
+				t.Fatalf("unexpected record %d: %+v", i, r)
+			}
+		}
+	}
+
+	records, err := cbor.UnmarshalSequenceParallel[record](nil, 0)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("expected no records, got %v (%v)", records, err)
+	}
+}
+
+func TestUnmarshalSequenceParallel_errors(t *testing.T) {
+	// Malformed sequences are rejected before decoding.
+	_, err := cbor.UnmarshalSequenceParallel[int]([]byte{0x01, 0x82, 0x01}, 0)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	// The first item which fails to decode is reported.
+	var data []byte
+	for i := 0; i < 500; i++ {
+		if i == 300 || i == 400 {
+			data = cbor.AppendString(data, "x")
+			continue
+		}
+		data = cbor.AppendInt(data, int64(i))
+	}
+	_, err = cbor.UnmarshalSequenceParallel[int](data, 4)
+	if err == nil || !strings.HasPrefix(err.Error(), "cbor: sequence item 300:") {
+		t.Fatalf("expected an error for item 300, got %v", err)
+	}
+}
+
+func BenchmarkUnmarshalSequenceParallel(b *testing.B) {
+	var data []byte
+	for i := 0; i < 10000; i++ {
+		d, err := cbor.Marshal(map[string]int{"a": i, "b": i * 2})
+		if err != nil {
+			b.Fatal(err)
+		}
+		data = append(data, d...)
+	}
+
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := cbor.UnmarshalSequenceParallel[map[string]int](data, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
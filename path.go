@@ -0,0 +1,166 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrPathNotFound is returned by GetPath when an element of the path
+// doesn't match any item.
+var ErrPathNotFound = errors.New("cbor: path not found")
+
+// GetPath returns the item found by following path from the CBOR item at
+// the start of data, without decoding it.
+//
+// Each element of path is either a string, which selects the value of a
+// text string key in a map, an integer (int, int64 or uint64), which
+// selects the element at that index in an array or the value of that
+// integer key in a map, or a Value, which selects the value of a map key
+// with the same encoding. Tags are skipped, so a path applies to the content
+// of tagged items. For example, to get the audience claim of a CWT in a
+// log record:
+//
+//	aud, err := cbor.GetPath(record, "claims", 3)
+//
+// Only the bytes up to the end of the found item are scanned, and items
+// that aren't on the path are skipped without being decoded. If an element
+// doesn't match any item, the error wraps ErrPathNotFound.
+func GetPath(data []byte, path ...interface{}) (Value, error) {
+	b := data
+	for i, elem := range path {
+		next, err := lookupPath(skipTags(b), elem)
+		if err != nil {
+			if errors.Is(err, ErrPathNotFound) {
+				return Value{}, fmt.Errorf("%w: element %d (%v)", err, i, elem)
+			}
+			return Value{}, err
+		}
+		b = next
+	}
+	return NewValue(b)
+}
+
+// GetPath returns the item found by following path from v, like GetPath.
+func (v Value) GetPath(path ...interface{}) (Value, error) {
+	return GetPath(v.raw, path...)
+}
+
+// skipTags returns b after the headers of any tags at its start.
+func skipTags(b []byte) []byte {
+	for {
+		mt, _, _, n, err := parseHeader(b)
+		if err != nil || mt != MajorTypeTag {
+			return b
+		}
+		b = b[n:]
+	}
+}
+
+// lookupPath returns the bytes starting at the item selected by the path
+// element elem in the array or map at the start of b.
+func lookupPath(b []byte, elem interface{}) ([]byte, error) {
+	index, isIndex, err := pathIndex(elem)
+	if err != nil {
+		return nil, err
+	}
+
+	mt, ai, arg, n, err := parseHeader(b)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mt {
+	case MajorTypeArray:
+		if !isIndex || index < 0 {
+			return nil, ErrPathNotFound
+		}
+	case MajorTypeMap:
+	default:
+		return nil, ErrPathNotFound
+	}
+
+	count := arg
+	if mt == MajorTypeMap {
+		count *= 2
+	}
+
+	b = b[n:]
+	for i := uint64(0); ai == 31 || i < count; i++ {
+		if len(b) == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if ai == 31 && b[0] == 0xff {
+			break
+		}
+		if mt == MajorTypeArray && int64(i) == index {
+			return b, nil
+		}
+
+		l, err := itemLength(b, 0)
+		if err != nil {
+			return nil, err
+		}
+		if mt == MajorTypeMap && i%2 == 0 && keyMatches(b[:l], elem) {
+			return b[l:], nil
+		}
+		b = b[l:]
+	}
+
+	return nil, ErrPathNotFound
+}
+
+// pathIndex returns the integer value of a path element, and whether it
+// is an integer. It returns an error if elem is not a valid path element.
+func pathIndex(elem interface{}) (int64, bool, error) {
+	switch elem := elem.(type) {
+	case string, Value:
+		return 0, false, nil
+	case int:
+		return int64(elem), true, nil
+	case int64:
+		return elem, true, nil
+	case uint64:
+		if elem > 1<<63-1 {
+			// No array can be this long, but map keys this large are
+			// still matched by keyMatches.
+			return -1, true, nil
+		}
+		return int64(elem), true, nil
+	default:
+		return 0, false, fmt.Errorf("cbor: invalid path element type %T", elem)
+	}
+}
+
+// keyMatches reports whether the encoded map key item is selected by the
+// path element elem.
+func keyMatches(item []byte, elem interface{}) bool {
+	switch elem := elem.(type) {
+	case string:
+		return textEqual(item, elem)
+	case Value:
+		return bytes.Equal(item, elem.raw)
+	case uint64:
+		mt, _, arg, _, err := parseHeader(item)
+		return err == nil && mt == MajorTypeUnsignedInt && arg == elem
+	default:
+		n, _, _ := pathIndex(elem)
+		return intEqual(item, n)
+	}
+}
+
+// intEqual reports whether the encoded item is an integer equal to n.
+func intEqual(item []byte, n int64) bool {
+	mt, _, arg, _, err := parseHeader(item)
+	if err != nil {
+		return false
+	}
+	switch mt {
+	case MajorTypeUnsignedInt:
+		return n >= 0 && arg == uint64(n)
+	case MajorTypeNegativeInt:
+		return n < 0 && arg == uint64(-1-n)
+	}
+	return false
+}
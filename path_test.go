@@ -0,0 +1,97 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestGetPath(t *testing.T) {
+	// {"id": 7, "claims": 61({1: "iss", 3: ["a", "b"], -1: true}), "tags": [_ "x", "y"]}
+	data, err := hex.DecodeString("a36269640766636c61696d73d83da3016369737303826161616220f564746167739f61786179ff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path []interface{}
+		want string
+	}{
+		{nil, hex.EncodeToString(data)},
+		{[]interface{}{"id"}, "07"},
+		{[]interface{}{"claims", 1}, "63697373"},
+		{[]interface{}{"claims", int64(3), 1}, "6162"},
+		{[]interface{}{"claims", -1}, "f5"},
+		{[]interface{}{"tags", uint64(1)}, "6179"},
+	}
+
+	for _, test := range tests {
+		v, err := cbor.GetPath(data, test.path...)
+		if err != nil {
+			t.Fatalf("GetPath(%v): %v", test.path, err)
+		}
+		if got := hex.EncodeToString(v.Raw()); got != test.want {
+			t.Fatalf("GetPath(%v) = %s, want %s", test.path, got, test.want)
+		}
+	}
+
+	claims, err := cbor.GetPath(data, "claims")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss, err := claims.GetPath(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err := iss.Decode(&s); err != nil || s != "iss" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+}
+
+func TestGetPathNotFound(t *testing.T) {
+	// {"a": [1, 2], 1: "b"}
+	data, err := hex.DecodeString("a26161820102016162")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range [][]interface{}{
+		{"b"},
+		{"a", 2},
+		{"a", -1},
+		{"a", "x"},
+		{2},
+		{1, 0},
+	} {
+		if _, err := cbor.GetPath(data, path...); !errors.Is(err, cbor.ErrPathNotFound) {
+			t.Fatalf("GetPath(%v): got error %v, want ErrPathNotFound", path, err)
+		}
+	}
+
+	if _, err := cbor.GetPath(data, 1.5); err == nil || errors.Is(err, cbor.ErrPathNotFound) {
+		t.Fatalf("got error %v for invalid path element", err)
+	}
+}
+
+func TestGetPathSkipsTrailingBytes(t *testing.T) {
+	// The array is truncated, but the path only needs its first element.
+	data, err := hex.DecodeString("8301")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := cbor.GetPath(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(v.Raw()); got != "01" {
+		t.Fatalf("got %s, want 01", got)
+	}
+
+	if _, err := cbor.GetPath(data, 2); err == nil || errors.Is(err, cbor.ErrPathNotFound) {
+		t.Fatalf("got error %v for truncated array", err)
+	}
+}
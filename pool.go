@@ -0,0 +1,59 @@
+package cbor
+
+import "sync"
+
+// maxPooledBuffer is the capacity above which the buffers of encoders and
+// decoders aren't returned to their pools, so a few large values don't
+// keep their memory in use.
+const maxPooledBuffer = 64 << 10
+
+// encoderPool is a pool of the Encoders used by Marshal, which have no
+// underlying writer.
+var encoderPool = sync.Pool{
+	New: func() any { return new(Encoder) },
+}
+
+// getEncoder returns an Encoder from the pool, with an empty buffer.
+func getEncoder() *Encoder {
+	e := encoderPool.Get().(*Encoder)
+	e.buf = e.buf[:0]
+	e.options = DefaultEncoderOptions
+	return e
+}
+
+// putEncoder returns an Encoder from getEncoder to the pool. The encoder
+// must not be used afterwards.
+func putEncoder(e *Encoder) {
+	if cap(e.buf) > maxPooledBuffer {
+		return
+	}
+	encoderPool.Put(e)
+}
+
+// decoderPool is a pool of the Decoders used by Unmarshal, which read from
+// memory and don't keep stats.
+var decoderPool = sync.Pool{
+	New: func() any { return new(Decoder) },
+}
+
+// getDecoder returns a Decoder from the pool, reading from data.
+func getDecoder(data []byte) *Decoder {
+	dec := decoderPool.Get().(*Decoder)
+	*dec = Decoder{
+		buffer:  dec.buffer[:0],
+		options: &DefaultDecoderOptions,
+		mem:     true,
+		data:    data,
+	}
+	return dec
+}
+
+// putDecoder returns a Decoder from getDecoder to the pool. The decoder
+// must not be used afterwards.
+func putDecoder(dec *Decoder) {
+	if cap(dec.buffer) > maxPooledBuffer {
+		return
+	}
+	dec.data = nil
+	decoderPool.Put(dec)
+}
@@ -0,0 +1,28 @@
+package cbor
+
+import "errors"
+
+// RawMessage is a raw encoded CBOR item.
+//
+// It implements Marshaler and Unmarshaler, so it can be used to delay
+// decoding part of a document, or to embed a precomputed encoding, like
+// json.RawMessage. Unlike Value, it owns a copy of its bytes, and doesn't
+// provide methods to navigate them.
+type RawMessage []byte
+
+// MarshalCBOR returns m as the CBOR encoding of m, or null if m is nil.
+func (m RawMessage) MarshalCBOR() ([]byte, error) {
+	if m == nil {
+		return []byte{0xf6}, nil
+	}
+	return m, nil
+}
+
+// UnmarshalCBOR sets *m to a copy of data.
+func (m *RawMessage) UnmarshalCBOR(data []byte) error {
+	if m == nil {
+		return errors.New("cbor: UnmarshalCBOR on nil *RawMessage")
+	}
+	*m = append((*m)[0:0], data...)
+	return nil
+}
@@ -0,0 +1,37 @@
+package cbor_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestRawMessage(t *testing.T) {
+	type message struct {
+		Kind string          `cbor:"kind"`
+		Body cbor.RawMessage `cbor:"body"`
+	}
+
+	data, err := cbor.Marshal(message{Kind: "ping", Body: cbor.RawMessage{0x82, 0x01, 0x02}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m message
+	if err := cbor.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Kind != "ping" || !bytes.Equal(m.Body, []byte{0x82, 0x01, 0x02}) {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+
+	// A nil RawMessage is encoded as null.
+	data, err = cbor.Marshal(cbor.RawMessage(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0xf6}) {
+		t.Fatalf("expected null, got %x", data)
+	}
+}
@@ -0,0 +1,435 @@
+package cbor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// maxNestingDepth is the maximum nesting depth of arrays, maps, and tags
+// accepted when scanning encoded items.
+const maxNestingDepth = 1000
+
+// parseHeader parses the header of the item at the start of data, returning
+// its major type, additional information, argument, and the length of the
+// header in bytes.
+//
+// For indefinite-length items (additional information 31), the argument is
+// zero.
+func parseHeader(data []byte) (mt MajorType, ai byte, arg uint64, n int, err error) {
+	if len(data) == 0 {
+		return 0, 0, 0, 0, io.ErrUnexpectedEOF
+	}
+
+	mt = MajorType(data[0] >> 5)
+	ai = data[0] & 0x1f
+
+	switch {
+	case ai < 24:
+		return mt, ai, uint64(ai), 1, nil
+	case ai == 24:
+		if len(data) < 2 {
+			return 0, 0, 0, 0, io.ErrUnexpectedEOF
+		}
+		return mt, ai, uint64(data[1]), 2, nil
+	case ai == 25:
+		if len(data) < 3 {
+			return 0, 0, 0, 0, io.ErrUnexpectedEOF
+		}
+		return mt, ai, uint64(binary.BigEndian.Uint16(data[1:])), 3, nil
+	case ai == 26:
+		if len(data) < 5 {
+			return 0, 0, 0, 0, io.ErrUnexpectedEOF
+		}
+		return mt, ai, uint64(binary.BigEndian.Uint32(data[1:])), 5, nil
+	case ai == 27:
+		if len(data) < 9 {
+			return 0, 0, 0, 0, io.ErrUnexpectedEOF
+		}
+		return mt, ai, binary.BigEndian.Uint64(data[1:]), 9, nil
+	case ai == 31:
+		return mt, ai, 0, 1, nil
+	default:
+		return 0, 0, 0, 0, newError(ErrMalformed, fmt.Sprintf("cbor: invalid additional information %d", ai))
+	}
+}
+
+// itemLength returns the length in bytes of the well-formed CBOR item at
+// the start of data.
+func itemLength(data []byte, depth int) (int, error) {
+	if depth > maxNestingDepth {
+		return 0, ErrMaxDepth
+	}
+
+	mt, ai, arg, n, err := parseHeader(data)
+	if err != nil {
+		return 0, err
+	}
+
+	switch mt {
+	case MajorTypeUnsignedInt, MajorTypeNegativeInt:
+		if ai == 31 {
+			return 0, newError(ErrMalformed, fmt.Sprintf("cbor: invalid indefinite-length %s", mt))
+		}
+		return n, nil
+	case MajorTypeByteString, MajorTypeTextString:
+		if ai != 31 {
+			if arg > uint64(len(data)-n) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return n + int(arg), nil
+		}
+
+		// Indefinite-length strings are a sequence of definite-length
+		// chunks of the same major type, terminated by a break.
+		off := n
+		for {
+			if off >= len(data) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			if data[off] == 0xff {
+				return off + 1, nil
+			}
+			cmt, cai, carg, cn, err := parseHeader(data[off:])
+			if err != nil {
+				return 0, err
+			}
+			if cmt != mt || cai == 31 {
+				return 0, newError(ErrMalformed, fmt.Sprintf("cbor: invalid chunk in indefinite-length %s", mt))
+			}
+			if carg > uint64(len(data)-off-cn) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			off += cn + int(carg)
+		}
+	case MajorTypeArray, MajorTypeMap:
+		off := n
+		if ai == 31 {
+			items := 0
+			for {
+				if off >= len(data) {
+					return 0, io.ErrUnexpectedEOF
+				}
+				if data[off] == 0xff {
+					if mt == MajorTypeMap && items%2 != 0 {
+						return 0, newError(ErrMalformed, "cbor: indefinite-length map has a key without a value")
+					}
+					return off + 1, nil
+				}
+				l, err := itemLength(data[off:], depth+1)
+				if err != nil {
+					return 0, err
+				}
+				off += l
+				items++
+			}
+		}
+
+		// Every item is at least one byte long, which bounds the number
+		// of items before any work is done.
+		count := arg
+		if mt == MajorTypeMap {
+			if count > math.MaxUint64/2 {
+				return 0, io.ErrUnexpectedEOF
+			}
+			count *= 2
+		}
+		if count > uint64(len(data)-off) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		for i := uint64(0); i < count; i++ {
+			l, err := itemLength(data[off:], depth+1)
+			if err != nil {
+				return 0, err
+			}
+			off += l
+		}
+		return off, nil
+	case MajorTypeTag:
+		if ai == 31 {
+			return 0, newError(ErrMalformed, "cbor: invalid indefinite-length tag")
+		}
+		l, err := itemLength(data[n:], depth+1)
+		if err != nil {
+			return 0, err
+		}
+		return n + l, nil
+	default: // MajorTypeSimple
+		switch {
+		case ai == 31:
+			return 0, newError(ErrMalformed, "cbor: unexpected break")
+		case ai == 24 && arg < 32:
+			return 0, newError(ErrMalformed, fmt.Sprintf("cbor: invalid two-byte simple value %d", arg))
+		}
+		return n, nil
+	}
+}
+
+// Skip skips the CBOR item at the start of b, returning the remaining bytes.
+//
+// The skipped item is checked to be well-formed, so Skip can be used to
+// find the boundaries of items, such as the raw bytes of a value with
+// b[:len(b)-len(rest)].
+func Skip(b []byte) (rest []byte, err error) {
+	n, err := itemLength(b, 0)
+	if err != nil {
+		return b, err
+	}
+	return b[n:], nil
+}
+
+// NextType returns the major type of the CBOR item at the start of b,
+// without consuming it.
+func NextType(b []byte) (MajorType, error) {
+	if len(b) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return MajorType(b[0] >> 5), nil
+}
+
+// Header is the header of a CBOR item, as read by ReadHeader.
+type Header struct {
+	// MajorType is the major type of the item.
+	MajorType MajorType
+
+	// AdditionalInfo is the low 5 bits of the initial byte, which tell
+	// the size of the argument, from 24 for 1 byte to 27 for 8 bytes, or
+	// hold it if it's below 24. For MajorTypeSimple, 25 to 27 are floats
+	// of 16, 32 and 64 bits.
+	AdditionalInfo byte
+
+	// Argument is the argument of the header, such as the value of an
+	// integer or the length of a string. For floats, it's their bits.
+	Argument uint64
+}
+
+// Indefinite reports whether the header is of an indefinite-length item,
+// or is the break code if its major type is MajorTypeSimple.
+func (h Header) Indefinite() bool {
+	return h.AdditionalInfo == 31
+}
+
+// ReadHeader reads the header of the CBOR item at the start of b,
+// returning it and the remaining bytes, which start with the content of
+// strings, or the next item otherwise. The break code is read as an
+// indefinite header of MajorTypeSimple.
+func ReadHeader(b []byte) (Header, []byte, error) {
+	mt, ai, arg, n, err := parseHeader(b)
+	if err != nil {
+		return Header{}, b, err
+	}
+	return Header{MajorType: mt, AdditionalInfo: ai, Argument: arg}, b[n:], nil
+}
+
+// readTypedHeader parses the header at the start of b, checking that it
+// has the wanted major type and is not indefinite-length.
+func readTypedHeader(b []byte, want MajorType) (uint64, []byte, error) {
+	mt, ai, arg, n, err := parseHeader(b)
+	if err != nil {
+		return 0, b, err
+	}
+	if mt != want {
+		return 0, b, fmt.Errorf("cbor: cannot read %s as %s", mt, want)
+	}
+	if ai == 31 {
+		return 0, b, fmt.Errorf("cbor: unexpected indefinite-length %s", mt)
+	}
+	return arg, b[n:], nil
+}
+
+// ReadUint reads a CBOR unsigned integer from the start of b, returning its
+// value and the remaining bytes.
+func ReadUint(b []byte) (uint64, []byte, error) {
+	return readTypedHeader(b, MajorTypeUnsignedInt)
+}
+
+// ReadInt reads a CBOR unsigned or negative integer from the start of b,
+// returning its value and the remaining bytes.
+func ReadInt(b []byte) (int64, []byte, error) {
+	mt, err := NextType(b)
+	if err != nil {
+		return 0, b, err
+	}
+
+	switch mt {
+	case MajorTypeUnsignedInt:
+		n, rest, err := readTypedHeader(b, mt)
+		if err != nil {
+			return 0, b, err
+		}
+		if n > math.MaxInt64 {
+			return 0, b, fmt.Errorf("cbor: integer %d overflows int64", n)
+		}
+		return int64(n), rest, nil
+	case MajorTypeNegativeInt:
+		n, rest, err := readTypedHeader(b, mt)
+		if err != nil {
+			return 0, b, err
+		}
+		if n > math.MaxInt64 {
+			return 0, b, fmt.Errorf("cbor: integer -1-%d overflows int64", n)
+		}
+		return -1 - int64(n), rest, nil
+	default:
+		return 0, b, fmt.Errorf("cbor: cannot read %s as integer", mt)
+	}
+}
+
+// ReadBool reads a CBOR boolean from the start of b, returning its value and
+// the remaining bytes.
+func ReadBool(b []byte) (bool, []byte, error) {
+	if len(b) == 0 {
+		return false, b, io.ErrUnexpectedEOF
+	}
+	switch b[0] {
+	case 0xf4:
+		return false, b[1:], nil
+	case 0xf5:
+		return true, b[1:], nil
+	default:
+		return false, b, fmt.Errorf("cbor: cannot read %X as bool", b[0])
+	}
+}
+
+// ReadFloat64 reads a CBOR half, single, or double precision float from the
+// start of b, returning its value and the remaining bytes.
+func ReadFloat64(b []byte) (float64, []byte, error) {
+	if len(b) == 0 {
+		return 0, b, io.ErrUnexpectedEOF
+	}
+	switch b[0] {
+	case 0xf9:
+		if len(b) < 3 {
+			return 0, b, io.ErrUnexpectedEOF
+		}
+		return float16ToFloat64(binary.BigEndian.Uint16(b[1:])), b[3:], nil
+	case 0xfa:
+		if len(b) < 5 {
+			return 0, b, io.ErrUnexpectedEOF
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b[1:]))), b[5:], nil
+	case 0xfb:
+		if len(b) < 9 {
+			return 0, b, io.ErrUnexpectedEOF
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b[1:])), b[9:], nil
+	default:
+		return 0, b, fmt.Errorf("cbor: cannot read %X as float", b[0])
+	}
+}
+
+// readStringBytes reads the content of a definite or indefinite-length
+// string with the given major type. The returned slice aliases b for
+// definite-length strings.
+func readStringBytes(b []byte, mt MajorType) ([]byte, []byte, error) {
+	got, ai, arg, n, err := parseHeader(b)
+	if err != nil {
+		return nil, b, err
+	}
+	if got != mt {
+		return nil, b, fmt.Errorf("cbor: cannot read %s as %s", got, mt)
+	}
+
+	if ai != 31 {
+		if arg > uint64(len(b)-n) {
+			return nil, b, io.ErrUnexpectedEOF
+		}
+		end := n + int(arg)
+		return b[n:end:end], b[end:], nil
+	}
+
+	l, err := itemLength(b, 0)
+	if err != nil {
+		return nil, b, err
+	}
+
+	var buf []byte
+	for chunks := b[n : l-1]; len(chunks) > 0; {
+		var chunk []byte
+		chunk, chunks, err = readStringBytes(chunks, mt)
+		if err != nil {
+			return nil, b, err
+		}
+		buf = append(buf, chunk...)
+	}
+	if buf == nil {
+		buf = []byte{}
+	}
+	return buf, b[l:], nil
+}
+
+// ReadString reads a CBOR text string from the start of b, returning its
+// value and the remaining bytes.
+func ReadString(b []byte) (string, []byte, error) {
+	s, rest, err := readStringBytes(b, MajorTypeTextString)
+	if err != nil {
+		return "", b, err
+	}
+	return string(s), rest, nil
+}
+
+// ReadBytes reads a CBOR byte string from the start of b, returning a copy
+// of its value and the remaining bytes.
+func ReadBytes(b []byte) ([]byte, []byte, error) {
+	s, rest, err := readStringBytes(b, MajorTypeByteString)
+	if err != nil {
+		return nil, b, err
+	}
+	return append([]byte{}, s...), rest, nil
+}
+
+// ReadArrayHeader reads the header of a definite-length CBOR array from the
+// start of b, returning the number of elements and the remaining bytes.
+func ReadArrayHeader(b []byte) (int, []byte, error) {
+	n, rest, err := readTypedHeader(b, MajorTypeArray)
+	if err != nil {
+		return 0, b, err
+	}
+	if n > uint64(len(rest)) {
+		return 0, b, io.ErrUnexpectedEOF
+	}
+	return int(n), rest, nil
+}
+
+// ReadMapHeader reads the header of a definite-length CBOR map from the
+// start of b, returning the number of key/value pairs and the remaining
+// bytes.
+func ReadMapHeader(b []byte) (int, []byte, error) {
+	n, rest, err := readTypedHeader(b, MajorTypeMap)
+	if err != nil {
+		return 0, b, err
+	}
+	if n > uint64(len(rest))/2 {
+		return 0, b, io.ErrUnexpectedEOF
+	}
+	return int(n), rest, nil
+}
+
+// ReadTag reads a CBOR tag header from the start of b, returning the tag
+// number and the remaining bytes, which start with the tag content.
+func ReadTag(b []byte) (uint64, []byte, error) {
+	return readTypedHeader(b, MajorTypeTag)
+}
+
+// float16ToFloat64 converts an IEEE 754 half-precision float to a float64.
+func float16ToFloat64(h uint16) float64 {
+	sign := uint64(h>>15) << 63
+	exp := (h >> 10) & 0x1f
+	frac := uint64(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		// Zero and subnormal numbers.
+		f := math.Ldexp(float64(frac), -24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		// Infinity and NaN, preserving the NaN payload.
+		return math.Float64frombits(sign | 0x7ff<<52 | frac<<42)
+	default:
+		return math.Float64frombits(sign | (uint64(exp)+1023-15)<<52 | frac<<42)
+	}
+}
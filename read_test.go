@@ -0,0 +1,68 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestReadHeader(t *testing.T) {
+	tests := []struct {
+		data string
+		want cbor.Header
+	}{
+		{"17", cbor.Header{MajorType: cbor.MajorTypeUnsignedInt, AdditionalInfo: 23, Argument: 23}},
+		{"3903e7", cbor.Header{MajorType: cbor.MajorTypeNegativeInt, AdditionalInfo: 25, Argument: 999}},
+		{"5a00010000", cbor.Header{MajorType: cbor.MajorTypeByteString, AdditionalInfo: 26, Argument: 65536}},
+		{"9f", cbor.Header{MajorType: cbor.MajorTypeArray, AdditionalInfo: 31}},
+		{"fb3ff8000000000000", cbor.Header{MajorType: cbor.MajorTypeSimple, AdditionalInfo: 27, Argument: 0x3ff8000000000000}},
+		{"ff", cbor.Header{MajorType: cbor.MajorTypeSimple, AdditionalInfo: 31}},
+	}
+	for _, test := range tests {
+		data, err := hex.DecodeString(test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, rest, err := cbor.ReadHeader(append(data, 0x01))
+		if err != nil {
+			t.Fatalf("%s: %v", test.data, err)
+		}
+		if h != test.want || len(rest) != 1 {
+			t.Errorf("%s: expected %+v, got %+v with %d bytes left", test.data, test.want, h, len(rest))
+		}
+
+		// Definite headers are appended back as they were read, except
+		// for floats, which aren't encoded by AppendHeader.
+		if h.Indefinite() {
+			continue
+		}
+		if h.MajorType != cbor.MajorTypeSimple {
+			if got := cbor.AppendHeader(nil, h.MajorType, h.Argument); hex.EncodeToString(got) != test.data {
+				t.Errorf("expected AppendHeader to append %s, got %x", test.data, got)
+			}
+			if n := cbor.HeaderLen(h.Argument); n != len(data) {
+				t.Errorf("expected HeaderLen(%d) to be %d, got %d", h.Argument, len(data), n)
+			}
+		}
+	}
+
+	if _, _, err := cbor.ReadHeader([]byte{0x19, 0x01}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+	if _, _, err := cbor.ReadHeader([]byte{0x1c}); !errors.Is(err, cbor.ErrMalformed) {
+		t.Fatalf("expected malformed error, got %v", err)
+	}
+}
+
+func TestAppendIndefiniteHeader(t *testing.T) {
+	b := cbor.AppendIndefiniteHeader(nil, cbor.MajorTypeArray)
+	b = cbor.AppendUint(b, 1)
+	b = cbor.AppendBreak(b)
+	var got []int
+	if err := cbor.Unmarshal(b, &got); err != nil || len(got) != 1 || got[0] != 1 {
+		t.Fatalf("expected [1], got %v (err %v)", got, err)
+	}
+}
@@ -0,0 +1,130 @@
+package cbor
+
+import (
+	"fmt"
+)
+
+// Scanner walks the CBOR items of a buffer, such as a capture of a CBOR
+// sequence, reporting their boundaries without decoding them, so they can
+// be split, indexed or sampled. Each top-level item is checked to be
+// well-formed before it, or any item nested in it, is reported.
+//
+// Like bufio.Scanner, successive calls to Scan step through the items,
+// and Scan returns false at the end of the buffer or on the first error,
+// which is returned by Err. Scanning doesn't allocate, except for the
+// nesting of items deeper than 16 levels when nested items are reported.
+type Scanner struct {
+	data   []byte
+	off    int
+	nested bool
+	item   ScanItem
+	err    error
+
+	// ends are the end offsets of the items containing the next item,
+	// when nested items are reported. It starts as a slice of endsBuf.
+	ends    []int
+	endsBuf [16]int
+}
+
+// ScanItem is the position of an item reported by a Scanner.
+type ScanItem struct {
+	// Start and End are the offsets of the first byte of the item, and of
+	// the byte after it.
+	Start, End int
+
+	// MajorType is the major type of the item.
+	MajorType MajorType
+
+	// Depth is the nesting depth of the item, which is 0 for top-level
+	// items, 1 for their elements, keys, values and tag contents, and so
+	// on.
+	Depth int
+}
+
+// NewScanner returns a Scanner reporting the top-level items of data.
+func NewScanner(data []byte) *Scanner {
+	s := &Scanner{}
+	s.Reset(data)
+	return s
+}
+
+// Reset makes the scanner start over on data, keeping its settings, so it
+// can be reused without allocating.
+func (s *Scanner) Reset(data []byte) {
+	s.data, s.off, s.item, s.err = data, 0, ScanItem{}, nil
+	if cap(s.ends) == 0 {
+		s.ends = s.endsBuf[:0]
+	}
+	s.ends = s.ends[:0]
+}
+
+// SetNested sets whether the items nested in arrays, maps and tags are
+// also reported, after the item containing them, which is known as a
+// pre-order walk. Keys and values of maps are reported in turn. The
+// chunks of indefinite-length strings aren't reported.
+//
+// It's disabled by default.
+func (s *Scanner) SetNested(on bool) {
+	s.nested = on
+}
+
+// Scan advances to the next item, which is then returned by Item and
+// Bytes. It returns false at the end of the data, or if an item isn't
+// well-formed.
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+
+	// Leave the containers which end here, skipping the break codes of
+	// indefinite-length ones.
+	for len(s.ends) > 0 && s.off >= s.ends[len(s.ends)-1]-1 {
+		if s.off == s.ends[len(s.ends)-1]-1 {
+			if s.data[s.off] != 0xff {
+				break
+			}
+			s.off++
+		}
+		s.ends = s.ends[:len(s.ends)-1]
+	}
+	if s.off >= len(s.data) {
+		return false
+	}
+
+	// The length of each item is found by checking it's well-formed, so
+	// top-level items are checked as a whole before the items nested in
+	// them are reported.
+	depth := len(s.ends)
+	n, err := itemLength(s.data[s.off:], depth)
+	if err != nil {
+		s.err = fmt.Errorf("cbor: item at offset %d: %w", s.off, err)
+		return false
+	}
+
+	mt, _, _, h, _ := parseHeader(s.data[s.off:])
+	s.item = ScanItem{Start: s.off, End: s.off + n, MajorType: mt, Depth: depth}
+	if s.nested && (mt == MajorTypeArray || mt == MajorTypeMap || mt == MajorTypeTag) {
+		s.ends = append(s.ends, s.off+n)
+		s.off += h
+	} else {
+		s.off += n
+	}
+	return true
+}
+
+// Item returns the position of the item found by the last call to Scan.
+func (s *Scanner) Item() ScanItem {
+	return s.item
+}
+
+// Bytes returns the encoded bytes of the item found by the last call to
+// Scan, which alias the data of the scanner.
+func (s *Scanner) Bytes() []byte {
+	return s.data[s.item.Start:s.item.End]
+}
+
+// Err returns the error which stopped the scanner, or nil if it stopped
+// at the end of the data.
+func (s *Scanner) Err() error {
+	return s.err
+}
@@ -0,0 +1,73 @@
+package cbor_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestScanner(t *testing.T) {
+	// 1, [2, {"a": 3}], 1(4), [_ 5, (_ h'06')], 7
+	data := decodeHex(t, "018202a1616103c1049f055f4106ffff07")
+
+	scan := func(nested bool) []string {
+		s := cbor.NewScanner(data)
+		s.SetNested(nested)
+		var got []string
+		for s.Scan() {
+			item := s.Item()
+			got = append(got, fmt.Sprintf("%d:%d-%d:%x", item.Depth, item.Start, item.End, s.Bytes()[:1]))
+		}
+		if err := s.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	want := []string{"0:0-1:01", "0:1-7:82", "0:7-9:c1", "0:9-16:9f", "0:16-17:07"}
+	if got := scan(false); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	want = []string{
+		"0:0-1:01",
+		"0:1-7:82", "1:2-3:02", "1:3-7:a1", "2:4-6:61", "2:6-7:03",
+		"0:7-9:c1", "1:8-9:04",
+		"0:9-16:9f", "1:10-11:05", "1:11-15:5f",
+		"0:16-17:07",
+	}
+	if got := scan(true); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestScanner_error(t *testing.T) {
+	// 1, then a truncated array whose items aren't reported.
+	s := cbor.NewScanner(decodeHex(t, "01820203"[:6]))
+	s.SetNested(true)
+	n := 0
+	for s.Scan() {
+		n++
+	}
+	if n != 1 || !errors.Is(s.Err(), io.ErrUnexpectedEOF) {
+		t.Fatalf("expected one item and unexpected EOF, got %d items and %v", n, s.Err())
+	}
+}
+
+func BenchmarkScanner(b *testing.B) {
+	data, err := cbor.Marshal([]interface{}{1, "two", []int{3, 4}, map[string]int{"five": 5}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	s := cbor.NewScanner(data)
+	s.SetNested(true)
+	for i := 0; i < b.N; i++ {
+		s.Reset(data)
+		for s.Scan() {
+		}
+	}
+}
@@ -0,0 +1,439 @@
+// Package senml implements the CBOR representation of Sensor Measurement
+// Lists (SenML) on top of the cbor package.
+//
+// SenML is defined in RFC 8428.
+//
+// https://www.rfc-editor.org/rfc/rfc8428.html
+package senml
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+// ContentType is the media type of SenML packs encoded with CBOR.
+const ContentType = "application/senml+cbor"
+
+// Version is the SenML version implemented by this package, which is used
+// when a pack doesn't have a base version.
+const Version = 10
+
+// relativeTimeLimit is the smallest time which is absolute; times below it
+// are relative to the current time. See RFC 8428, section 4.5.3.
+const relativeTimeLimit = 1 << 28
+
+// ErrUnsupportedVersion is returned by Normalize when a pack has a base
+// version newer than Version.
+var ErrUnsupportedVersion = errors.New("senml: unsupported version")
+
+// Labels of SenML fields in CBOR, defined in RFC 8428, section 6.
+const (
+	LabelBaseVersion = -1
+	LabelBaseName    = -2
+	LabelBaseTime    = -3
+	LabelBaseUnit    = -4
+	LabelBaseValue   = -5
+	LabelBaseSum     = -6
+	LabelName        = 0
+	LabelUnit        = 1
+	LabelValue       = 2
+	LabelStringValue = 3
+	LabelBoolValue   = 4
+	LabelSum         = 5
+	LabelTime        = 6
+	LabelUpdateTime  = 7
+	LabelDataValue   = 8
+)
+
+// Record is a SenML record.
+//
+// The base fields apply to the record they are in, and all the following
+// records of a pack, until they are replaced. Zero values of the base
+// fields, Name, Unit, Time, and UpdateTime are considered absent, and are
+// omitted by Marshal. The value fields and Sum are absent when nil.
+type Record struct {
+	BaseVersion uint    `cbor:"-1,keyasint"`
+	BaseName    string  `cbor:"-2,keyasint"`
+	BaseTime    float64 `cbor:"-3,keyasint"`
+	BaseUnit    string  `cbor:"-4,keyasint"`
+	BaseValue   float64 `cbor:"-5,keyasint"`
+	BaseSum     float64 `cbor:"-6,keyasint"`
+
+	Name        string   `cbor:"0,keyasint"`
+	Unit        string   `cbor:"1,keyasint"`
+	Value       *float64 `cbor:"2,keyasint"`
+	StringValue *string  `cbor:"3,keyasint"`
+	BoolValue   *bool    `cbor:"4,keyasint"`
+	DataValue   []byte   `cbor:"8,keyasint"`
+	Sum         *float64 `cbor:"5,keyasint"`
+
+	// Time is the time of the measurement in seconds since the Unix epoch,
+	// or relative to the current time if it is less than 2^28.
+	Time float64 `cbor:"6,keyasint"`
+
+	// UpdateTime is the maximum number of seconds before the sensor
+	// provides an updated measurement.
+	UpdateTime float64 `cbor:"7,keyasint"`
+}
+
+// Pack is a list of SenML records.
+type Pack []Record
+
+// Marshal returns the CBOR encoding of the pack, an array of maps keyed by
+// the SenML labels, omitting absent fields.
+//
+// Numbers which are whole are encoded as integers, and other numbers as
+// the shortest float which represents them exactly.
+func Marshal(p Pack) ([]byte, error) {
+	data := cbor.AppendArrayHeader(nil, len(p))
+	for i := range p {
+		data = p[i].append(data)
+	}
+	return data, nil
+}
+
+// append appends the CBOR encoding of the record to dst.
+func (r *Record) append(dst []byte) []byte {
+	type field struct {
+		label  int64
+		append func([]byte) []byte
+	}
+
+	var fields []field
+	number := func(label int64, f float64) {
+		fields = append(fields, field{label, func(dst []byte) []byte { return appendNumber(dst, f) }})
+	}
+	text := func(label int64, s string) {
+		fields = append(fields, field{label, func(dst []byte) []byte { return cbor.AppendString(dst, s) }})
+	}
+
+	if r.BaseVersion != 0 {
+		fields = append(fields, field{LabelBaseVersion, func(dst []byte) []byte {
+			return cbor.AppendUint(dst, uint64(r.BaseVersion))
+		}})
+	}
+	if r.BaseName != "" {
+		text(LabelBaseName, r.BaseName)
+	}
+	if r.BaseTime != 0 {
+		number(LabelBaseTime, r.BaseTime)
+	}
+	if r.BaseUnit != "" {
+		text(LabelBaseUnit, r.BaseUnit)
+	}
+	if r.BaseValue != 0 {
+		number(LabelBaseValue, r.BaseValue)
+	}
+	if r.BaseSum != 0 {
+		number(LabelBaseSum, r.BaseSum)
+	}
+	if r.Name != "" {
+		text(LabelName, r.Name)
+	}
+	if r.Unit != "" {
+		text(LabelUnit, r.Unit)
+	}
+	if r.Value != nil {
+		number(LabelValue, *r.Value)
+	}
+	if r.StringValue != nil {
+		text(LabelStringValue, *r.StringValue)
+	}
+	if r.BoolValue != nil {
+		fields = append(fields, field{LabelBoolValue, func(dst []byte) []byte {
+			return cbor.AppendBool(dst, *r.BoolValue)
+		}})
+	}
+	if r.Sum != nil {
+		number(LabelSum, *r.Sum)
+	}
+	if r.Time != 0 {
+		number(LabelTime, r.Time)
+	}
+	if r.UpdateTime != 0 {
+		number(LabelUpdateTime, r.UpdateTime)
+	}
+	if r.DataValue != nil {
+		fields = append(fields, field{LabelDataValue, func(dst []byte) []byte {
+			return cbor.AppendBytes(dst, r.DataValue)
+		}})
+	}
+
+	dst = cbor.AppendMapHeader(dst, len(fields))
+	for _, f := range fields {
+		dst = cbor.AppendInt(dst, f.label)
+		dst = f.append(dst)
+	}
+	return dst
+}
+
+// appendNumber appends f to dst as an integer if it is whole, or as the
+// shortest float which represents it exactly.
+func appendNumber(dst []byte, f float64) []byte {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<63 && !(f == 0 && math.Signbit(f)) {
+		return cbor.AppendInt(dst, int64(f))
+	}
+	if float64(float32(f)) == f || math.IsNaN(f) {
+		return cbor.AppendFloat32(dst, float32(f))
+	}
+	return cbor.AppendFloat64(dst, f)
+}
+
+// Unmarshal decodes a CBOR SenML pack.
+//
+// Unknown integer labels are ignored. Text labels are not defined for the
+// CBOR representation, and are ignored too, unless they end with an
+// underscore, which marks fields that must be understood.
+func Unmarshal(data []byte) (Pack, error) {
+	n, rest, err := cbor.ReadArrayHeader(data)
+	if err != nil {
+		return nil, fmt.Errorf("senml: invalid pack: %w", err)
+	}
+
+	p := make(Pack, n)
+	for i := range p {
+		if rest, err = p[i].read(rest); err != nil {
+			return nil, fmt.Errorf("senml: record %d: %w", i, err)
+		}
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("senml: unexpected data after pack")
+	}
+	return p, nil
+}
+
+// read decodes the CBOR encoding of a record from the start of b into r,
+// returning the remaining bytes.
+func (r *Record) read(b []byte) ([]byte, error) {
+	n, b, err := cbor.ReadMapHeader(b)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < n; i++ {
+		mt, err := cbor.NextType(b)
+		if err != nil {
+			return nil, err
+		}
+		if mt == cbor.MajorTypeTextString {
+			var label string
+			label, b, err = cbor.ReadString(b)
+			if err != nil {
+				return nil, err
+			}
+			if strings.HasSuffix(label, "_") {
+				return nil, fmt.Errorf("unsupported must-understand field %q", label)
+			}
+			if b, err = cbor.Skip(b); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var label int64
+		label, b, err = cbor.ReadInt(b)
+		if err != nil {
+			return nil, err
+		}
+
+		switch label {
+		case LabelBaseVersion:
+			var v uint64
+			v, b, err = cbor.ReadUint(b)
+			if err == nil && v > math.MaxUint32 {
+				err = errors.New("base version is out of range")
+			}
+			r.BaseVersion = uint(v)
+		case LabelBaseName:
+			r.BaseName, b, err = cbor.ReadString(b)
+		case LabelBaseTime:
+			r.BaseTime, b, err = readNumber(b)
+		case LabelBaseUnit:
+			r.BaseUnit, b, err = cbor.ReadString(b)
+		case LabelBaseValue:
+			r.BaseValue, b, err = readNumber(b)
+		case LabelBaseSum:
+			r.BaseSum, b, err = readNumber(b)
+		case LabelName:
+			r.Name, b, err = cbor.ReadString(b)
+		case LabelUnit:
+			r.Unit, b, err = cbor.ReadString(b)
+		case LabelValue:
+			var v float64
+			v, b, err = readNumber(b)
+			r.Value = &v
+		case LabelStringValue:
+			var s string
+			s, b, err = cbor.ReadString(b)
+			r.StringValue = &s
+		case LabelBoolValue:
+			var v bool
+			v, b, err = cbor.ReadBool(b)
+			r.BoolValue = &v
+		case LabelSum:
+			var v float64
+			v, b, err = readNumber(b)
+			r.Sum = &v
+		case LabelTime:
+			r.Time, b, err = readNumber(b)
+		case LabelUpdateTime:
+			r.UpdateTime, b, err = readNumber(b)
+		case LabelDataValue:
+			r.DataValue, b, err = cbor.ReadBytes(b)
+		default:
+			b, err = cbor.Skip(b)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("label %d: %w", label, err)
+		}
+	}
+	return b, nil
+}
+
+// readNumber reads a CBOR integer or float from the start of b.
+func readNumber(b []byte) (float64, []byte, error) {
+	mt, err := cbor.NextType(b)
+	if err != nil {
+		return 0, b, err
+	}
+
+	switch mt {
+	case cbor.MajorTypeUnsignedInt:
+		n, rest, err := cbor.ReadUint(b)
+		return float64(n), rest, err
+	case cbor.MajorTypeNegativeInt:
+		n, rest, err := cbor.ReadInt(b)
+		return float64(n), rest, err
+	default:
+		return cbor.ReadFloat64(b)
+	}
+}
+
+// Normalize returns the resolved records of the pack, as described by RFC
+// 8428, section 4.6.
+//
+// The base fields are applied to each record and removed: names are
+// prefixed with the base name, the base unit is used for records without
+// a unit, base values and sums are added to the values and sums which are
+// present, and the base time is added to the time. Relative times are then
+// resolved against now. If the pack has a base version, it is kept in all
+// the resolved records.
+//
+// An error is returned if a resolved record has an invalid name, doesn't
+// have exactly one value field or a sum, or if the pack uses a version
+// newer than Version.
+func (p Pack) Normalize(now time.Time) (Pack, error) {
+	var base Record
+	nowSeconds := float64(now.UnixNano()) / float64(time.Second)
+
+	resolved := make(Pack, len(p))
+	for i, r := range p {
+		if r.BaseVersion != 0 {
+			base.BaseVersion = r.BaseVersion
+		}
+		if r.BaseName != "" {
+			base.BaseName = r.BaseName
+		}
+		if r.BaseTime != 0 {
+			base.BaseTime = r.BaseTime
+		}
+		if r.BaseUnit != "" {
+			base.BaseUnit = r.BaseUnit
+		}
+		if r.BaseValue != 0 {
+			base.BaseValue = r.BaseValue
+		}
+		if r.BaseSum != 0 {
+			base.BaseSum = r.BaseSum
+		}
+
+		if base.BaseVersion > Version {
+			return nil, fmt.Errorf("%w %d", ErrUnsupportedVersion, base.BaseVersion)
+		}
+
+		rr := Record{
+			Name:        base.BaseName + r.Name,
+			Unit:        r.Unit,
+			StringValue: r.StringValue,
+			BoolValue:   r.BoolValue,
+			DataValue:   r.DataValue,
+			Time:        base.BaseTime + r.Time,
+			UpdateTime:  r.UpdateTime,
+		}
+		if base.BaseVersion != Version {
+			rr.BaseVersion = base.BaseVersion
+		}
+		if rr.Unit == "" {
+			rr.Unit = base.BaseUnit
+		}
+		if r.Value != nil {
+			v := base.BaseValue + *r.Value
+			rr.Value = &v
+		}
+		if r.Sum != nil {
+			s := base.BaseSum + *r.Sum
+			rr.Sum = &s
+		}
+		if rr.Time < relativeTimeLimit {
+			rr.Time += nowSeconds
+		}
+
+		if err := rr.validate(); err != nil {
+			return nil, fmt.Errorf("senml: record %d: %w", i, err)
+		}
+		resolved[i] = rr
+	}
+	return resolved, nil
+}
+
+// validate checks the name and value fields of a resolved record.
+func (r *Record) validate() error {
+	if !validName(r.Name) {
+		return fmt.Errorf("invalid name %q", r.Name)
+	}
+
+	values := 0
+	if r.Value != nil {
+		values++
+	}
+	if r.StringValue != nil {
+		values++
+	}
+	if r.BoolValue != nil {
+		values++
+	}
+	if r.DataValue != nil {
+		values++
+	}
+	switch {
+	case values > 1:
+		return errors.New("more than one value field")
+	case values == 0 && r.Sum == nil:
+		return errors.New("missing value or sum")
+	}
+	return nil
+}
+
+// validName reports whether name is a valid resolved name, which starts
+// with a letter or digit, and only contains letters, digits, and the
+// characters "-:./_".
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case i > 0 && strings.IndexByte("-:./_", c) != -1:
+		default:
+			return false
+		}
+	}
+	return true
+}
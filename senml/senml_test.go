@@ -0,0 +1,160 @@
+package senml_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/picatz/cbor/senml"
+)
+
+func float(f float64) *float64 { return &f }
+
+// pack is the multiple datapoints example from RFC 8428, section 5.1.2.
+var pack = senml.Pack{
+	{BaseName: "urn:dev:ow:10e2073a0108006:", BaseTime: 1.276020076001e+09, BaseUnit: "A", BaseVersion: 5, Name: "voltage", Unit: "V", Value: float(120.1)},
+	{Name: "current", Time: -5, Value: float(1.2)},
+	{Name: "current", Time: -4, Value: float(1.3)},
+	{Name: "current", Time: -3, Value: float(1.4)},
+	{Name: "current", Time: -2, Value: float(1.5)},
+	{Name: "current", Time: -1, Value: float(1.6)},
+	{Name: "current", Value: float(1.7)},
+}
+
+func TestMarshal(t *testing.T) {
+	data, err := senml.Marshal(pack[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// [{-1: 5, -2: "urn:dev:ow:10e2073a0108006:", -3: 1276020076.001, -4: "A", 0: "voltage", 1: "V", 2: 120.1},
+	//  {0: "current", 2: 1.2, 6: -5}]
+	want := "82a720052178" + "1b" + hex.EncodeToString([]byte("urn:dev:ow:10e2073a0108006:")) +
+		"22fb41d303a15b001062" + "236141" + "0067766f6c74616765" + "016156" + "02fb405e066666666666" +
+		"a30067" + hex.EncodeToString([]byte("current")) + "02fb3ff3333333333333" + "0624"
+	if got := hex.EncodeToString(data); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	got, err := senml.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].BaseName != pack[0].BaseName || got[0].BaseVersion != 5 || *got[0].Value != 120.1 ||
+		got[1].Time != -5 || *got[1].Value != 1.2 {
+		t.Fatalf("unexpected pack: %+v", got)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	// [{0: "switch", 4: true, 8: h'0102', 99: "ignored", "x": 1}]
+	data, err := hex.DecodeString("81a50066737769746368" + "04f5" + "08420102" + "186367" + hex.EncodeToString([]byte("ignored")) + "617801")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := senml.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) != 1 || p[0].Name != "switch" || p[0].BoolValue == nil || !*p[0].BoolValue || !bytes.Equal(p[0].DataValue, []byte{1, 2}) {
+		t.Fatalf("unexpected pack: %+v", p)
+	}
+
+	// [{"x_": 1}]
+	if _, err := senml.Unmarshal([]byte{0x81, 0xa1, 0x62, 'x', '_', 0x01}); err == nil {
+		t.Fatal("expected an error for a must-understand field")
+	}
+
+	// [{0: 1}]
+	if _, err := senml.Unmarshal([]byte{0x81, 0xa1, 0x00, 0x01}); err == nil {
+		t.Fatal("expected an error for a name which isn't a string")
+	}
+}
+
+func TestPack_Normalize(t *testing.T) {
+	now := time.Unix(1_300_000_000, 0)
+
+	resolved, err := pack.Normalize(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The resolved records from RFC 8428, section 5.1.7.
+	want := []struct {
+		name  string
+		unit  string
+		value float64
+		time  float64
+	}{
+		{"urn:dev:ow:10e2073a0108006:voltage", "V", 120.1, 1.276020076001e+09},
+		{"urn:dev:ow:10e2073a0108006:current", "A", 1.2, 1.276020071001e+09},
+		{"urn:dev:ow:10e2073a0108006:current", "A", 1.3, 1.276020072001e+09},
+		{"urn:dev:ow:10e2073a0108006:current", "A", 1.4, 1.276020073001e+09},
+		{"urn:dev:ow:10e2073a0108006:current", "A", 1.5, 1.276020074001e+09},
+		{"urn:dev:ow:10e2073a0108006:current", "A", 1.6, 1.276020075001e+09},
+		{"urn:dev:ow:10e2073a0108006:current", "A", 1.7, 1.276020076001e+09},
+	}
+	if len(resolved) != len(want) {
+		t.Fatalf("expected %d records, got %d", len(want), len(resolved))
+	}
+	for i, w := range want {
+		r := resolved[i]
+		if r.Name != w.name || r.Unit != w.unit || *r.Value != w.value || r.Time != w.time ||
+			r.BaseVersion != 5 || r.BaseName != "" || r.BaseTime != 0 || r.BaseUnit != "" {
+			t.Errorf("record %d: unexpected %+v", i, r)
+		}
+	}
+
+	// The original pack isn't modified.
+	if pack[1].Name != "current" || pack[1].Time != -5 {
+		t.Fatalf("unexpected pack: %+v", pack[1])
+	}
+}
+
+func TestPack_Normalize_relative(t *testing.T) {
+	now := time.Unix(1_300_000_000, 0)
+
+	p := senml.Pack{
+		{BaseName: "dev/", BaseValue: 10, BaseSum: 100, Name: "temp", Value: float(1.5)},
+		{Name: "energy", Time: -60, Sum: float(5)},
+	}
+	resolved, err := p.Normalize(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved[0].Name != "dev/temp" || *resolved[0].Value != 11.5 || resolved[0].Time != 1_300_000_000 || resolved[0].BaseVersion != 0 {
+		t.Fatalf("unexpected record: %+v", resolved[0])
+	}
+	if resolved[1].Name != "dev/energy" || resolved[1].Value != nil || *resolved[1].Sum != 105 || resolved[1].Time != 1_299_999_940 {
+		t.Fatalf("unexpected record: %+v", resolved[1])
+	}
+}
+
+func TestPack_Normalize_errors(t *testing.T) {
+	s := "on"
+	tests := []struct {
+		name string
+		pack senml.Pack
+	}{
+		{"missing name", senml.Pack{{Value: float(1)}}},
+		{"invalid name", senml.Pack{{Name: "-temp", Value: float(1)}}},
+		{"invalid character", senml.Pack{{Name: "temp#1", Value: float(1)}}},
+		{"missing value", senml.Pack{{Name: "temp"}}},
+		{"multiple values", senml.Pack{{Name: "temp", Value: float(1), StringValue: &s}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.pack.Normalize(time.Now()); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+
+	_, err := senml.Pack{{BaseVersion: 11, Name: "temp", Value: float(1)}}.Normalize(time.Now())
+	if !errors.Is(err, senml.ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package cbor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SequenceWriterOptions are the options of a SequenceWriter.
+type SequenceWriterOptions struct {
+	// Framed, if true, writes each item as an encoded CBOR data item: a
+	// byte string containing the encoding of the item, tagged with TagCBOR
+	// (RFC 8949, Section 3.4.5.1). This prefixes each item with its length,
+	// so readers can skip items without parsing them, and the output is
+	// still a valid CBOR sequence.
+	Framed bool
+
+	// SyncEvery is the number of items written between calls to the sync
+	// hook. If zero, the hook is only called by SequenceWriter.Sync.
+	SyncEvery int
+
+	// Sync is the hook called to commit the written items to stable
+	// storage. If nil, the Sync method of the writer is used if it has
+	// one, such as (*os.File).Sync.
+	Sync func() error
+}
+
+// SequenceWriter writes a CBOR sequence (RFC 8742), such as an append-only
+// log, one item at a time. Each item is written with a single call to
+// Write, so it can be decoded independently of the items before it, by
+// the Decoder or the Values iterator.
+//
+// If a write fails, the underlying writer may contain a partial item, so
+// the error is returned by all later calls.
+//
+// It is not safe to be called from multiple goroutines.
+type SequenceWriter struct {
+	w    io.Writer
+	opts SequenceWriterOptions
+	sync func() error
+
+	// buf holds the item being written.
+	buf []byte
+
+	// unsynced is the number of items written since the last sync.
+	unsynced int
+
+	err error
+}
+
+// NewSequenceWriter returns a SequenceWriter which appends items to w,
+// using the given options. If opts is nil, items are written unframed,
+// and never synced.
+func NewSequenceWriter(w io.Writer, opts *SequenceWriterOptions) *SequenceWriter {
+	sw := &SequenceWriter{w: w}
+	if opts != nil {
+		sw.opts = *opts
+	}
+	sw.sync = sw.opts.Sync
+	if sw.sync == nil {
+		if s, ok := w.(interface{ Sync() error }); ok {
+			sw.sync = s.Sync
+		}
+	}
+	return sw
+}
+
+// Encode appends the CBOR encoding of v to the sequence.
+//
+// See the documentation for Encoder.Encode for details about the conversion
+// of Go values to CBOR.
+func (sw *SequenceWriter) Encode(v interface{}) error {
+	if sw.err != nil {
+		return sw.err
+	}
+	data, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+	return sw.write(data)
+}
+
+// WriteRaw appends an already encoded item to the sequence. It returns an
+// error if item isn't exactly one well-formed CBOR data item.
+func (sw *SequenceWriter) WriteRaw(item []byte) error {
+	if sw.err != nil {
+		return sw.err
+	}
+	n, err := itemLength(item, 0)
+	if err != nil {
+		return err
+	}
+	if n != len(item) {
+		return errors.New("cbor: trailing data after item")
+	}
+	return sw.write(item)
+}
+
+// write writes a well-formed item, framing it if needed, and syncs if
+// SyncEvery items have been written since the last sync.
+func (sw *SequenceWriter) write(item []byte) error {
+	data := item
+	if sw.opts.Framed {
+		sw.buf = AppendTag(sw.buf[:0], uint64(TagCBOR))
+		sw.buf = AppendBytes(sw.buf, item)
+		data = sw.buf
+	}
+
+	if _, err := sw.w.Write(data); err != nil {
+		sw.err = err
+		return err
+	}
+
+	sw.unsynced++
+	if sw.opts.SyncEvery > 0 && sw.unsynced >= sw.opts.SyncEvery {
+		return sw.Sync()
+	}
+	return nil
+}
+
+// Sync calls the sync hook to commit the items written since the last
+// sync to stable storage. It does nothing if there is no hook, or no item
+// was written since the last sync.
+func (sw *SequenceWriter) Sync() error {
+	if sw.err != nil {
+		return sw.err
+	}
+	if sw.sync == nil || sw.unsynced == 0 {
+		return nil
+	}
+	if err := sw.sync(); err != nil {
+		sw.err = err
+		return err
+	}
+	sw.unsynced = 0
+	return nil
+}
+
+// SplitSequence splits data, a CBOR sequence (RFC 8742), into its items.
+// The items are subslices of data rather than copies, whose capacity ends
+// with them, so appending to an item doesn't overwrite the next one.
+//
+// Each item is checked to be well-formed. If one isn't, the items before
+// it are returned, with an error giving its offset.
+func SplitSequence(data []byte) ([]RawMessage, error) {
+	var items []RawMessage
+	for off := 0; off < len(data); {
+		n, err := itemLength(data[off:], 0)
+		if err != nil {
+			return items, fmt.Errorf("cbor: sequence item %d at offset %d: %w", len(items), off, err)
+		}
+		items = append(items, RawMessage(data[off:off+n:off+n]))
+		off += n
+	}
+	return items, nil
+}
+
+// JoinSequence concatenates items into a CBOR sequence (RFC 8742), such as
+// the items of sequences split with SplitSequence. It returns an error if
+// an item isn't exactly one well-formed CBOR data item, since the items
+// couldn't be split again.
+func JoinSequence(items ...RawMessage) ([]byte, error) {
+	size := 0
+	for i, item := range items {
+		n, err := itemLength(item, 0)
+		if err != nil {
+			return nil, fmt.Errorf("cbor: sequence item %d: %w", i, err)
+		}
+		if n != len(item) {
+			return nil, fmt.Errorf("cbor: sequence item %d: trailing data after item", i)
+		}
+		size += n
+	}
+
+	data := make([]byte, 0, size)
+	for _, item := range items {
+		data = append(data, item...)
+	}
+	return data, nil
+}
@@ -0,0 +1,226 @@
+package cbor_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestSequenceWriter(t *testing.T) {
+	var buf bytes.Buffer
+	sw := cbor.NewSequenceWriter(&buf, nil)
+
+	if err := sw.Encode(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.WriteRaw([]byte{0x82, 0x01, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Encode("a"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.Bytes(), []byte{0x01, 0x82, 0x01, 0x02, 0x61, 0x61}; !bytes.Equal(got, want) {
+		t.Fatalf("expected %x, got %x", want, got)
+	}
+
+	// Each item is decodable from the sequence.
+	dec := cbor.NewDecoder(&buf)
+	var (
+		n int
+		a []int
+		s string
+	)
+	if err := dec.Decode(&n); err != nil || n != 1 {
+		t.Fatalf("expected 1, got %v (%v)", n, err)
+	}
+	if err := dec.Decode(&a); err != nil || len(a) != 2 {
+		t.Fatalf("expected [1 2], got %v (%v)", a, err)
+	}
+	if err := dec.Decode(&s); err != nil || s != "a" {
+		t.Fatalf("expected \"a\", got %q (%v)", s, err)
+	}
+}
+
+func TestSequenceWriter_WriteRaw_invalid(t *testing.T) {
+	var buf bytes.Buffer
+	sw := cbor.NewSequenceWriter(&buf, nil)
+
+	for _, item := range [][]byte{
+		nil,
+		{0x82, 0x01},
+		{0x01, 0x02},
+	} {
+		if err := sw.WriteRaw(item); err == nil {
+			t.Errorf("expected an error for %x", item)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing written, got %x", buf.Bytes())
+	}
+}
+
+func TestSequenceWriter_framed(t *testing.T) {
+	var buf bytes.Buffer
+	sw := cbor.NewSequenceWriter(&buf, &cbor.SequenceWriterOptions{Framed: true})
+
+	if err := sw.Encode([]int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Encode(true); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{0xd8, 0x18, 0x43, 0x82, 0x01, 0x02, 0xd8, 0x18, 0x41, 0xf5}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("expected %x, got %x", want, buf.Bytes())
+	}
+
+	// Readers can unwrap each item without parsing it.
+	data := buf.Bytes()
+	var items [][]byte
+	for len(data) > 0 {
+		tag, rest, err := cbor.ReadTag(data)
+		if err != nil || tag != uint64(cbor.TagCBOR) {
+			t.Fatalf("expected tag 24, got %d (%v)", tag, err)
+		}
+		item, rest, err := cbor.ReadBytes(rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+		data = rest
+	}
+	if len(items) != 2 || !bytes.Equal(items[0], []byte{0x82, 0x01, 0x02}) || !bytes.Equal(items[1], []byte{0xf5}) {
+		t.Fatalf("unexpected items %x", items)
+	}
+}
+
+func TestSequenceWriter_sync(t *testing.T) {
+	var (
+		buf   bytes.Buffer
+		syncs int
+		fail  error
+	)
+	sw := cbor.NewSequenceWriter(&buf, &cbor.SequenceWriterOptions{
+		SyncEvery: 2,
+		Sync: func() error {
+			syncs++
+			return fail
+		},
+	})
+
+	for i := 0; i < 5; i++ {
+		if err := sw.Encode(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if syncs != 2 {
+		t.Fatalf("expected 2 syncs, got %d", syncs)
+	}
+
+	// The last item is synced explicitly, after which there is nothing
+	// left to sync.
+	if err := sw.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if syncs != 3 {
+		t.Fatalf("expected 3 syncs, got %d", syncs)
+	}
+
+	// Sync errors are sticky.
+	fail = errors.New("disk full")
+	if err := sw.Encode(5); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Encode(6); err != fail {
+		t.Fatalf("expected %v, got %v", fail, err)
+	}
+	if err := sw.Encode(7); err != fail {
+		t.Fatalf("expected %v, got %v", fail, err)
+	}
+	if n := buf.Len(); n != 7 {
+		t.Fatalf("expected 7 items written, got %d bytes", n)
+	}
+}
+
+func TestSequenceWriter_file(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log.cbor")
+
+	for _, v := range []string{"first", "second"} {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sw := cbor.NewSequenceWriter(f, &cbor.SequenceWriterOptions{SyncEvery: 1})
+		if err := sw.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	dec := cbor.NewDecoder(f)
+	for _, want := range []string{"first", "second"} {
+		var got string
+		if err := dec.Decode(&got); err != nil || got != want {
+			t.Fatalf("expected %q, got %q (%v)", want, got, err)
+		}
+	}
+}
+
+func TestSplitSequence(t *testing.T) {
+	// 1, [2, 3], "a"
+	data := decodeHex(t, "018202036161")
+	items, err := cbor.SplitSequence(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || !bytes.Equal(items[1], []byte{0x82, 0x02, 0x03}) {
+		t.Fatalf("unexpected items % x", items)
+	}
+
+	// Items alias data, without room to grow into the next item.
+	if &items[1][0] != &data[1] || cap(items[1]) != 3 {
+		t.Fatal("expected items to be subslices of data")
+	}
+	_ = append(items[0], 0x00)
+	if data[1] != 0x82 {
+		t.Fatal("appending to an item overwrote the next one")
+	}
+
+	joined, err := cbor.JoinSequence(items...)
+	if err != nil || !bytes.Equal(joined, data) {
+		t.Fatalf("expected % x, got % x (err %v)", data, joined, err)
+	}
+
+	items, err = cbor.SplitSequence(append(data, 0x82, 0x01))
+	if len(items) != 3 || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected 3 items and unexpected EOF, got %d items and %v", len(items), err)
+	}
+	if items, err := cbor.SplitSequence(nil); len(items) != 0 || err != nil {
+		t.Fatalf("expected no items, got %v (err %v)", items, err)
+	}
+}
+
+func TestJoinSequence_invalid(t *testing.T) {
+	for _, item := range []cbor.RawMessage{{0x82, 0x01}, {0x01, 0x02}, {}} {
+		if _, err := cbor.JoinSequence(cbor.RawMessage{0x01}, item); err == nil {
+			t.Errorf("expected error joining % x", item)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package cbor
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// simpleRegistry holds the simple values registered with
+// RegisterSimpleValue.
+var simpleRegistry struct {
+	sync.RWMutex
+
+	// values are the Go values of the registered simple values, and
+	// simples are the simple values of each registered Go value, by type.
+	values  map[SimpleValue]interface{}
+	simples map[reflect.Type]map[interface{}]SimpleValue
+}
+
+// RegisterSimpleValue registers v as the meaning of the simple value n,
+// which must be unassigned by RFC 8949: from 0 to 19, or from 32 to 255.
+// The simple value is decoded into v when decoding into an empty interface
+// or a value of the type of v, and values of the type of v equal to it are
+// encoded as the simple value, such as
+//
+//	type Sensor int
+//
+//	const UnknownSensor Sensor = -1
+//
+//	cbor.RegisterSimpleValue(40, UnknownSensor)
+//
+// for UnknownSensor to be encoded as simple(40), and simple(40) decoded as
+// UnknownSensor. Other values of the type are encoded as usual.
+//
+// The type of v must be comparable, and registered before values of it
+// are first encoded, usually in an init function. It returns an error if
+// n is assigned or already registered, or v is already registered.
+func RegisterSimpleValue(n SimpleValue, v interface{}) error {
+	if n < 0 || n > 255 || n >= 20 && n < 32 {
+		return fmt.Errorf("cbor: simple value %d is not unassigned", n)
+	}
+	t := reflect.TypeOf(v)
+	if t == nil || !t.Comparable() {
+		return fmt.Errorf("cbor: cannot register simple value %d as a value of non-comparable type %v", n, t)
+	}
+
+	simpleRegistry.Lock()
+	defer simpleRegistry.Unlock()
+	if _, ok := simpleRegistry.values[n]; ok {
+		return fmt.Errorf("cbor: simple value %d is already registered", n)
+	}
+	if m, ok := simpleRegistry.simples[t][v]; ok {
+		return fmt.Errorf("cbor: %v is already registered as simple value %d", v, m)
+	}
+	if simpleRegistry.values == nil {
+		simpleRegistry.values = make(map[SimpleValue]interface{})
+		simpleRegistry.simples = make(map[reflect.Type]map[interface{}]SimpleValue)
+	}
+	if simpleRegistry.simples[t] == nil {
+		simpleRegistry.simples[t] = make(map[interface{}]SimpleValue)
+	}
+	simpleRegistry.values[n] = v
+	simpleRegistry.simples[t][v] = n
+	return nil
+}
+
+// registeredValue returns the Go value registered for the simple value n.
+func registeredValue(n SimpleValue) (interface{}, bool) {
+	simpleRegistry.RLock()
+	defer simpleRegistry.RUnlock()
+	v, ok := simpleRegistry.values[n]
+	return v, ok
+}
+
+// simpleEncoder wraps the encoderFunc f of t to encode the values of t
+// registered with RegisterSimpleValue as their simple value, if any are.
+func simpleEncoder(t reflect.Type, f encoderFunc) encoderFunc {
+	simpleRegistry.RLock()
+	_, ok := simpleRegistry.simples[t]
+	simpleRegistry.RUnlock()
+	if !ok {
+		return f
+	}
+	return func(e *Encoder, v reflect.Value) error {
+		if v.CanInterface() {
+			simpleRegistry.RLock()
+			n, ok := simpleRegistry.simples[t][v.Interface()]
+			simpleRegistry.RUnlock()
+			if ok {
+				e.buf = appendHeader(e.buf, MajorTypeSimple, uint64(n))
+				return nil
+			}
+		}
+		return f(e, v)
+	}
+}
+
+// decodeRegisteredValue decodes the unassigned simple value n into rv,
+// which must be an empty interface or of the type of its registered value.
+func decodeRegisteredValue(rv reflect.Value, n SimpleValue) error {
+	v, ok := registeredValue(n)
+	if !ok {
+		return newError(ErrInvalidType, fmt.Sprintf("cbor: unregistered simple value %d", n))
+	}
+	switch {
+	case rv.Kind() == reflect.Interface && rv.NumMethod() == 0,
+		rv.Type() == reflect.TypeOf(v):
+		rv.Set(reflect.ValueOf(v))
+		return nil
+	}
+	return typeError("simple value "+itoa(int64(n)), rv.Type())
+}
@@ -0,0 +1,75 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+type sensor int
+
+const (
+	unknownSensor sensor = -1
+	brokenSensor  sensor = -2
+)
+
+func init() {
+	if err := cbor.RegisterSimpleValue(40, unknownSensor); err != nil {
+		panic(err)
+	}
+	if err := cbor.RegisterSimpleValue(5, brokenSensor); err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisterSimpleValue(t *testing.T) {
+	data, err := cbor.Marshal([]sensor{1, unknownSensor, brokenSensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(data), "8301f828e5"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	var got []sensor
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[1] != unknownSensor || got[2] != brokenSensor {
+		t.Fatalf("unexpected decoded value %v", got)
+	}
+
+	var v interface{}
+	if err := cbor.Unmarshal([]byte{0xf8, 0x28}, &v); err != nil || v != unknownSensor {
+		t.Fatalf("expected unknownSensor, got %#v (err %v)", v, err)
+	}
+
+	// Registered values are only decoded into their own type.
+	var n int
+	if err := cbor.Unmarshal([]byte{0xf8, 0x28}, &n); !errors.Is(err, cbor.ErrInvalidType) {
+		t.Fatalf("expected invalid type error, got %v", err)
+	}
+	if err := cbor.Unmarshal([]byte{0xf8, 0x29}, &v); !errors.Is(err, cbor.ErrInvalidType) {
+		t.Fatalf("expected error for an unregistered simple value, got %v", err)
+	}
+	if err := cbor.Unmarshal([]byte{0xf8, 0x05}, &v); !errors.Is(err, cbor.ErrMalformed) {
+		t.Fatalf("expected malformed error for a two-byte simple value below 32, got %v", err)
+	}
+
+	for _, test := range []struct {
+		n cbor.SimpleValue
+		v interface{}
+	}{
+		{20, sensor(7)},     // assigned
+		{40, sensor(7)},     // already registered
+		{41, unknownSensor}, // value already registered
+		{42, []int{1}},      // not comparable
+		{256, sensor(7)},    // out of range
+	} {
+		if err := cbor.RegisterSimpleValue(test.n, test.v); err == nil {
+			t.Errorf("expected error registering %v as simple value %d", test.v, test.n)
+		}
+	}
+}
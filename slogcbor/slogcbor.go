@@ -0,0 +1,273 @@
+//go:build go1.21
+
+// Package slogcbor provides a log/slog handler which writes log records as
+// a CBOR sequence (RFC 8742).
+//
+// https://www.rfc-editor.org/rfc/rfc8742.html
+package slogcbor
+
+import (
+	"context"
+	"encoding"
+	"io"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+// Handler is a slog.Handler which writes each record as a CBOR map to an
+// io.Writer, so the output is a CBOR sequence of one map per record.
+//
+// Records are encoded like slog.JSONHandler encodes them: the built-in
+// attributes use the keys slog.TimeKey, slog.LevelKey, slog.SourceKey and
+// slog.MessageKey, and groups are nested maps. Times are RFC 3339 text
+// strings with nanoseconds, durations are integer nanoseconds, levels are
+// strings, and errors are their Error text. Other values are encoded with
+// cbor.Marshal, or as text if they implement encoding.TextMarshaler but not
+// cbor.Marshaler.
+type Handler struct {
+	opts slog.HandlerOptions
+	goas []groupOrAttrs
+
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+// groupOrAttrs is a group or attributes added by WithGroup or WithAttrs.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// NewHandler returns a Handler which writes to w, using the given options.
+// If opts is nil, the default options are used.
+func NewHandler(w io.Writer, opts *slog.HandlerOptions) *Handler {
+	h := &Handler{w: w, mu: &sync.Mutex{}}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+// WithAttrs returns a new Handler whose records include the given
+// attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(groupOrAttrs{attrs: attrs})
+}
+
+// WithGroup returns a new Handler which nests the attributes of its
+// records, and those added later with WithAttrs, in a group with the given
+// name.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(groupOrAttrs{group: name})
+}
+
+// with returns a copy of the handler with goa added.
+func (h *Handler) with(goa groupOrAttrs) *Handler {
+	h2 := *h
+	h2.goas = make([]groupOrAttrs, len(h.goas)+1)
+	copy(h2.goas, h.goas)
+	h2.goas[len(h.goas)] = goa
+	return &h2
+}
+
+// Handle writes the record as a CBOR map, with a single call to Write.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	var (
+		entries []byte
+		n, c    int
+		err     error
+	)
+
+	builtins := make([]slog.Attr, 0, 4)
+	if !r.Time.IsZero() {
+		builtins = append(builtins, slog.Time(slog.TimeKey, r.Time))
+	}
+	builtins = append(builtins, slog.Any(slog.LevelKey, r.Level))
+	if h.opts.AddSource && r.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := fs.Next()
+		builtins = append(builtins, slog.Any(slog.SourceKey, &slog.Source{
+			Function: f.Function,
+			File:     f.File,
+			Line:     f.Line,
+		}))
+	}
+	builtins = append(builtins, slog.String(slog.MessageKey, r.Message))
+
+	for _, a := range builtins {
+		if entries, c, err = h.appendAttr(entries, nil, a); err != nil {
+			return err
+		}
+		n += c
+	}
+
+	if entries, c, err = h.appendGroups(entries, 0, nil, r); err != nil {
+		return err
+	}
+	n += c
+
+	data := cbor.AppendMapHeader(make([]byte, 0, len(entries)+9), n)
+	data = append(data, entries...)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.w.Write(data)
+	return err
+}
+
+// appendGroups appends the entries of the attributes and groups added with
+// WithAttrs and WithGroup, starting from h.goas[i], followed by the
+// attributes of the record, returning the number of entries appended.
+// Groups without any attributes are omitted.
+func (h *Handler) appendGroups(dst []byte, i int, groups []string, r slog.Record) ([]byte, int, error) {
+	var (
+		n, c int
+		err  error
+	)
+	for ; i < len(h.goas); i++ {
+		goa := h.goas[i]
+		if goa.group != "" {
+			var inner []byte
+			inner, c, err = h.appendGroups(nil, i+1, append(groups[:len(groups):len(groups)], goa.group), r)
+			if err != nil {
+				return nil, 0, err
+			}
+			if c > 0 {
+				dst = cbor.AppendString(dst, goa.group)
+				dst = cbor.AppendMapHeader(dst, c)
+				dst = append(dst, inner...)
+				n++
+			}
+			return dst, n, nil
+		}
+
+		for _, a := range goa.attrs {
+			if dst, c, err = h.appendAttr(dst, groups, a); err != nil {
+				return nil, 0, err
+			}
+			n += c
+		}
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		dst, c, err = h.appendAttr(dst, groups, a)
+		n += c
+		return err == nil
+	})
+	return dst, n, err
+}
+
+// appendAttr appends the map entry of an attribute, returning the number of
+// entries appended. Empty attributes and groups are omitted, and the
+// attributes of groups without a key are inlined.
+func (h *Handler) appendAttr(dst []byte, groups []string, a slog.Attr) ([]byte, int, error) {
+	a.Value = a.Value.Resolve()
+	if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return dst, 0, nil
+	}
+
+	if a.Value.Kind() != slog.KindGroup {
+		dst = cbor.AppendString(dst, a.Key)
+		dst, err := appendValue(dst, a.Value)
+		return dst, 1, err
+	}
+
+	attrs := a.Value.Group()
+	if a.Key != "" {
+		groups = append(groups[:len(groups):len(groups)], a.Key)
+	}
+
+	var (
+		inner []byte
+		n, c  int
+		err   error
+	)
+	for _, ga := range attrs {
+		if inner, c, err = h.appendAttr(inner, groups, ga); err != nil {
+			return nil, 0, err
+		}
+		n += c
+	}
+	switch {
+	case n == 0:
+		return dst, 0, nil
+	case a.Key == "":
+		return append(dst, inner...), n, nil
+	}
+
+	dst = cbor.AppendString(dst, a.Key)
+	dst = cbor.AppendMapHeader(dst, n)
+	return append(dst, inner...), 1, nil
+}
+
+// appendValue appends the CBOR encoding of a resolved value which isn't a
+// group.
+func appendValue(dst []byte, v slog.Value) ([]byte, error) {
+	switch v.Kind() {
+	case slog.KindString:
+		return cbor.AppendString(dst, v.String()), nil
+	case slog.KindInt64:
+		return cbor.AppendInt(dst, v.Int64()), nil
+	case slog.KindUint64:
+		return cbor.AppendUint(dst, v.Uint64()), nil
+	case slog.KindFloat64:
+		return cbor.AppendFloat64(dst, v.Float64()), nil
+	case slog.KindBool:
+		return cbor.AppendBool(dst, v.Bool()), nil
+	case slog.KindDuration:
+		return cbor.AppendInt(dst, int64(v.Duration())), nil
+	case slog.KindTime:
+		return cbor.AppendString(dst, v.Time().Format(time.RFC3339Nano)), nil
+	}
+
+	switch x := v.Any().(type) {
+	case *slog.Source:
+		dst = cbor.AppendMapHeader(dst, 3)
+		dst = cbor.AppendString(dst, "function")
+		dst = cbor.AppendString(dst, x.Function)
+		dst = cbor.AppendString(dst, "file")
+		dst = cbor.AppendString(dst, x.File)
+		dst = cbor.AppendString(dst, "line")
+		return cbor.AppendInt(dst, int64(x.Line)), nil
+	case cbor.Marshaler:
+		// Encoded by cbor.Marshal below, even if it's also an error or
+		// encoding.TextMarshaler.
+	case error:
+		return cbor.AppendString(dst, x.Error()), nil
+	case encoding.TextMarshaler:
+		text, err := x.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return cbor.AppendString(dst, string(text)), nil
+	}
+
+	data, err := cbor.Marshal(v.Any())
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, data...), nil
+}
@@ -0,0 +1,117 @@
+//go:build go1.21
+
+package slogcbor_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/picatz/cbor"
+	"github.com/picatz/cbor/slogcbor"
+)
+
+// parseRecords decodes the CBOR sequence written by a Handler.
+func parseRecords(t *testing.T, data []byte) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	for len(data) > 0 {
+		rest, err := cbor.Skip(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var v interface{}
+		if err := cbor.Unmarshal(data[:len(data)-len(rest)], &v); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, stringKeys(v).(map[string]any))
+		data = rest
+	}
+	return records
+}
+
+// stringKeys converts the maps in a decoded value to map[string]any.
+func stringKeys(v any) any {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return v
+	}
+	sm := make(map[string]any, len(m))
+	for k, v := range m {
+		sm[k.(string)] = stringKeys(v)
+	}
+	return sm
+}
+
+func TestHandler_slogtest(t *testing.T) {
+	var buf bytes.Buffer
+	h := slogcbor.NewHandler(&buf, nil)
+
+	err := slogtest.TestHandler(h, func() []map[string]any {
+		return parseRecords(t, buf.Bytes())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slogcbor.NewHandler(&buf, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == "secret" {
+				return slog.String("secret", "REDACTED")
+			}
+			return a
+		},
+	}))
+
+	logger.With("service", "api").WithGroup("req").Debug("handled",
+		"status", 200,
+		"took", 1500*time.Millisecond,
+		"err", errors.New("boom"),
+		"secret", "hunter2",
+		"at", time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+	)
+
+	records := parseRecords(t, buf.Bytes())
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	r := records[0]
+
+	if r[slog.LevelKey] != "DEBUG" || r[slog.MessageKey] != "handled" || r["service"] != "api" {
+		t.Fatalf("unexpected record: %v", r)
+	}
+	source, ok := r[slog.SourceKey].(map[string]any)
+	if !ok || !strings.HasSuffix(source["file"].(string), "slogcbor_test.go") {
+		t.Fatalf("unexpected source: %v", r[slog.SourceKey])
+	}
+
+	req, ok := r["req"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected a req group, got %v", r)
+	}
+	if req["status"] != uint64(200) || req["took"] != uint64(1500*time.Millisecond) || req["err"] != "boom" ||
+		req["secret"] != "REDACTED" || req["at"] != "2024-01-02T03:04:05.000000006Z" {
+		t.Fatalf("unexpected group: %v", req)
+	}
+}
+
+func TestHandler_Enabled(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slogcbor.NewHandler(&buf, nil))
+
+	logger.Debug("hidden")
+	if buf.Len() != 0 {
+		t.Fatal("expected debug records to be discarded by default")
+	}
+}
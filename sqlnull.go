@@ -0,0 +1,21 @@
+package cbor
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+// sqlNullTypes are the nullable types of database/sql, which are encoded
+// as null when they aren't valid, and as their value otherwise, like an
+// Optional. Each is a struct with the value as its first field, and a
+// Valid field.
+var sqlNullTypes = map[reflect.Type]bool{
+	reflect.TypeOf(sql.NullString{}):  true,
+	reflect.TypeOf(sql.NullInt64{}):   true,
+	reflect.TypeOf(sql.NullInt32{}):   true,
+	reflect.TypeOf(sql.NullInt16{}):   true,
+	reflect.TypeOf(sql.NullByte{}):    true,
+	reflect.TypeOf(sql.NullFloat64{}): true,
+	reflect.TypeOf(sql.NullBool{}):    true,
+	reflect.TypeOf(sql.NullTime{}):    true,
+}
@@ -0,0 +1,75 @@
+package cbor_test
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+func TestSQLNullTypes(t *testing.T) {
+	type row struct {
+		Name    sql.NullString
+		Age     sql.NullInt64
+		Score   sql.NullFloat64
+		Active  sql.NullBool
+		Created sql.NullTime
+	}
+
+	valid := row{
+		Name:    sql.NullString{String: "ada", Valid: true},
+		Age:     sql.NullInt64{Int64: 36, Valid: true},
+		Score:   sql.NullFloat64{Float64: 1.5, Valid: true},
+		Active:  sql.NullBool{Bool: true, Valid: true},
+		Created: sql.NullTime{Time: time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC), Valid: true},
+	}
+
+	tests := []struct {
+		name string
+		v    row
+		want string
+	}{
+		{"valid", valid, "a5" +
+			"644e616d6563616461" +
+			"6341676518" + "24" +
+			"6553636f7265fb3ff8000000000000" +
+			"66416374697665f5" +
+			"6743726561746564" + "c074323031332d30332d32315432303a30343a30305a"},
+		{"null", row{}, "a5" +
+			"644e616d65f6" +
+			"63416765f6" +
+			"6553636f7265f6" +
+			"66416374697665f6" +
+			"6743726561746564f6"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := cbor.Marshal(test.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(data); got != test.want {
+				t.Fatalf("expected %s, got %s", test.want, got)
+			}
+
+			// Decoding resets values which were set before.
+			got := valid
+			if err := cbor.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.v) {
+				t.Fatalf("expected %+v, got %+v", test.v, got)
+			}
+		})
+	}
+
+	// Values which don't match the type are an error.
+	var n sql.NullInt64
+	if err := cbor.Unmarshal([]byte{0x61, 0x61}, &n); err == nil {
+		t.Fatal("expected an error decoding a string into sql.NullInt64")
+	}
+}
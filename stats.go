@@ -0,0 +1,266 @@
+package cbor
+
+import (
+	"io"
+)
+
+// Stats are counters about the CBOR data processed by a Decoder or an
+// Encoder over its lifetime, which can be exported as metrics to monitor
+// the data exchanged with peers, and to detect abusive ones.
+//
+// Bytes and LimitsExceeded are always counted. Items and MaxDepth are only
+// counted once EnableStats has been called, since counting them means
+// scanning every byte a second time.
+type Stats struct {
+	// Items is the number of data items, including nested items such as
+	// the elements of arrays and the keys and values of maps. The chunks
+	// of indefinite-length strings are not counted separately.
+	Items uint64
+
+	// Bytes is the number of bytes read or written.
+	Bytes uint64
+
+	// MaxDepth is the deepest nesting of any item, where top-level items
+	// have depth 1, and the items in arrays, maps, and tags are one deeper
+	// than their parent.
+	MaxDepth int
+
+	// LimitsExceeded is the number of items rejected because they exceeded
+	// a limit, such as those in DecoderOptions.
+	LimitsExceeded uint64
+}
+
+// Stats returns the counters of the data read by the decoder.
+//
+// The counters cover all the bytes read from the underlying reader, so
+// they include the items which failed to decode.
+func (dec *Decoder) Stats() Stats {
+	return dec.stats.stats
+}
+
+// EnableStats makes the decoder count the items it reads, and their
+// depth, in its Stats. It must be called before the first item is read.
+func (dec *Decoder) EnableStats() {
+	dec.stats.enabled = true
+}
+
+// Stats returns the counters of the data written by the encoder.
+func (e *Encoder) Stats() Stats {
+	return e.stats.stats
+}
+
+// EnableStats makes the encoder count the items it writes, and their
+// depth, in its Stats. It must be called before the first item is written.
+func (e *Encoder) EnableStats() {
+	e.stats.enabled = true
+}
+
+// limitExceeded counts an item rejected by a limit, returning err, which
+// should be or wrap one of the errors for exceeded limits.
+func (dec *Decoder) limitExceeded(err error) error {
+	if dec.stats != nil {
+		dec.stats.stats.LimitsExceeded++
+	}
+	return err
+}
+
+// statsReader counts the data items read from an io.Reader.
+type statsReader struct {
+	r io.Reader
+
+	// br is r as an io.ByteReader, if it is one, so single bytes can be
+	// read without going through Read.
+	br  io.ByteReader
+	buf [1]byte
+
+	statsScanner
+}
+
+// Read implements io.Reader.
+func (r *statsReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.scan(p[:n])
+	return n, err
+}
+
+// ReadByte implements io.ByteReader. It must only be called if br is set.
+func (r *statsReader) ReadByte() (byte, error) {
+	b, err := r.br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	r.buf[0] = b
+	r.scan(r.buf[:])
+	return b, nil
+}
+
+// statsWriter counts the data items written to an io.Writer.
+type statsWriter struct {
+	w io.Writer
+	statsScanner
+}
+
+// Write implements io.Writer.
+func (w *statsWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.scan(p[:n])
+	return n, err
+}
+
+// statsFrame is an array, map, tag, or indefinite-length string whose
+// content is being scanned.
+type statsFrame struct {
+	// remaining is the number of items left in the frame, or -1 if it is
+	// terminated by a break.
+	remaining int64
+
+	// chunks is true for indefinite-length strings, whose chunks have the
+	// major type mt.
+	chunks bool
+	mt     MajorType
+}
+
+// statsScanner incrementally scans a stream of CBOR items, as it is read or
+// written in arbitrary pieces, to count the items and their depth.
+//
+// If the stream is malformed, scanning stops, but bytes are still counted.
+// Until enabled is set, only the bytes are counted.
+type statsScanner struct {
+	stats   Stats
+	enabled bool
+
+	stack []statsFrame
+
+	// The header being scanned, with argLeft bytes of its argument left.
+	mt      MajorType
+	ai      byte
+	arg     uint64
+	argLeft int
+
+	// skip is the number of bytes left in the content of a string.
+	skip uint64
+
+	malformed bool
+}
+
+// scan scans the next bytes of the stream.
+func (s *statsScanner) scan(p []byte) {
+	s.stats.Bytes += uint64(len(p))
+	if !s.enabled {
+		return
+	}
+
+	for len(p) > 0 && !s.malformed {
+		switch {
+		case s.skip > 0:
+			n := s.skip
+			if n > uint64(len(p)) {
+				n = uint64(len(p))
+			}
+			s.skip -= n
+			p = p[n:]
+		case s.argLeft > 0:
+			s.arg = s.arg<<8 | uint64(p[0])
+			s.argLeft--
+			p = p[1:]
+			if s.argLeft == 0 {
+				s.header(s.mt, s.ai, s.arg)
+			}
+		default:
+			b := p[0]
+			p = p[1:]
+			mt, ai := MajorType(b>>5), b&0x1f
+			switch {
+			case b == 0xff:
+				s.closeIndefinite()
+			case ai < 24:
+				s.header(mt, ai, uint64(ai))
+			case ai <= 27:
+				s.mt, s.ai, s.arg, s.argLeft = mt, ai, 0, 1<<(ai-24)
+			case ai == 31:
+				s.header(mt, ai, 0)
+			default:
+				s.malformed = true
+			}
+		}
+	}
+}
+
+// header handles a complete item header.
+func (s *statsScanner) header(mt MajorType, ai byte, arg uint64) {
+	// The chunks of indefinite-length strings are part of their string.
+	if n := len(s.stack); n > 0 && s.stack[n-1].chunks {
+		if mt != s.stack[n-1].mt || ai == 31 {
+			s.malformed = true
+			return
+		}
+		s.skip = arg
+		return
+	}
+
+	s.stats.Items++
+	if depth := len(s.stack) + 1; depth > s.stats.MaxDepth {
+		s.stats.MaxDepth = depth
+	}
+
+	switch mt {
+	case MajorTypeByteString, MajorTypeTextString:
+		if ai == 31 {
+			s.stack = append(s.stack, statsFrame{remaining: -1, chunks: true, mt: mt})
+			return
+		}
+		s.skip = arg
+	case MajorTypeArray, MajorTypeMap:
+		if ai == 31 {
+			s.stack = append(s.stack, statsFrame{remaining: -1})
+			return
+		}
+		if arg > 1<<61 {
+			s.malformed = true
+			return
+		}
+		if mt == MajorTypeMap {
+			arg *= 2
+		}
+		if arg > 0 {
+			s.stack = append(s.stack, statsFrame{remaining: int64(arg)})
+			return
+		}
+	case MajorTypeTag:
+		s.stack = append(s.stack, statsFrame{remaining: 1})
+		return
+	default:
+		if ai == 31 {
+			s.malformed = true
+			return
+		}
+	}
+	s.complete()
+}
+
+// closeIndefinite handles a break, which closes the innermost frame.
+func (s *statsScanner) closeIndefinite() {
+	n := len(s.stack)
+	if n == 0 || s.stack[n-1].remaining >= 0 {
+		s.malformed = true
+		return
+	}
+	s.stack = s.stack[:n-1]
+	s.complete()
+}
+
+// complete counts an item as complete in its enclosing frame, closing the
+// frames which have no remaining items.
+func (s *statsScanner) complete() {
+	for n := len(s.stack); n > 0; n-- {
+		top := &s.stack[n-1]
+		if top.remaining < 0 {
+			return
+		}
+		top.remaining--
+		if top.remaining > 0 {
+			return
+		}
+		s.stack = s.stack[:n-1]
+	}
+}
@@ -0,0 +1,154 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/picatz/cbor"
+)
+
+// rawItem is an encoded item which marshals itself.
+type rawItem []byte
+
+func (r rawItem) MarshalCBOR() ([]byte, error) {
+	return r, nil
+}
+
+func TestDecoder_Stats(t *testing.T) {
+	// [1, {"a": [2]}, "x"] followed by 3
+	data, err := hex.DecodeString("8301a1616181026178" + "03")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := cbor.NewDecoder(iotest.OneByteReader(bytes.NewReader(data)))
+	dec.EnableStats()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	want := cbor.Stats{Items: 7, Bytes: 9, MaxDepth: 4}
+	if got := dec.Stats(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	want = cbor.Stats{Items: 8, Bytes: 10, MaxDepth: 4}
+	if got := dec.Stats(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+// byteReader is an io.ByteReader which counts the single bytes read with
+// Read rather than ReadByte.
+type byteReader struct {
+	*bytes.Reader
+	singleReads int
+}
+
+func (r *byteReader) Read(p []byte) (int, error) {
+	if len(p) == 1 {
+		r.singleReads++
+	}
+	return r.Reader.Read(p)
+}
+
+func TestDecoder_Stats_byteReader(t *testing.T) {
+	// [1, {"ab": [2]}, "xy"] followed by 3
+	data, err := hex.DecodeString("8301a16261628102627879" + "03")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &byteReader{Reader: bytes.NewReader(data)}
+	dec := cbor.NewDecoder(r)
+	dec.EnableStats()
+
+	var v, w interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&w); err != nil {
+		t.Fatal(err)
+	}
+	if w != uint64(3) {
+		t.Fatalf("expected 3, got %#v", w)
+	}
+	want := cbor.Stats{Items: 8, Bytes: 12, MaxDepth: 4}
+	if got := dec.Stats(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if r.singleReads != 0 {
+		t.Fatalf("expected header bytes to be read with ReadByte, got %d single-byte reads", r.singleReads)
+	}
+}
+
+func TestDecoder_Stats_limitExceeded(t *testing.T) {
+	// A byte string of 10,001 bytes, which is over the default limit.
+	dec := cbor.NewDecoder(bytes.NewReader([]byte{0x59, 0x27, 0x11}))
+
+	var b []byte
+	if err := dec.Decode(&b); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := dec.Stats(); got.LimitsExceeded != 1 || got.Bytes != 3 {
+		t.Fatalf("unexpected stats: %+v", got)
+	}
+}
+
+func TestEncoder_Stats(t *testing.T) {
+	enc := cbor.NewEncoder(io.Discard)
+	enc.EnableStats()
+
+	if err := enc.Encode(map[string][]int{"a": {1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	want := cbor.Stats{Items: 5, Bytes: 6, MaxDepth: 3}
+	if got := enc.Stats(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	// [_ (_ "a", "b"), 24(h'')], with chunks which aren't counted.
+	item, err := hex.DecodeString("9f7f61616162ffd81840ff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(rawItem(item)); err != nil {
+		t.Fatal(err)
+	}
+	want = cbor.Stats{Items: 9, Bytes: 17, MaxDepth: 3}
+	if got := enc.Stats(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestStats_disabled(t *testing.T) {
+	// Without EnableStats, only the bytes and the limits are counted.
+	// [1, [2]] followed by a byte string over the default limit.
+	dec := cbor.NewDecoder(bytes.NewReader([]byte{0x82, 0x01, 0x81, 0x02, 0x59, 0x27, 0x11}))
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&v); err == nil {
+		t.Fatal("expected an error")
+	}
+	if want, got := (cbor.Stats{Bytes: 7, LimitsExceeded: 1}), dec.Stats(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	enc := cbor.NewEncoder(io.Discard)
+	if err := enc.Encode([]int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := (cbor.Stats{Bytes: 3}), enc.Stats(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
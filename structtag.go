@@ -0,0 +1,206 @@
+package cbor
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// structTag is a parsed cbor struct tag. Its grammar is
+//
+//	tag     = "-" | name { "," option }
+//	name    = "'" { any character but "'" } "'" | { any character but "," }
+//	option  = "keyasint" | "omitempty" | "omitzero" | "toarray" | "intkeys" |
+//	          "transform=" { any character but "," }
+//
+// so names containing commas can be quoted, as in `cbor:"'a,b',omitempty"`.
+// The tag "-" leaves the field out, while "-," is a field with the key "-".
+type structTag struct {
+	name   string
+	ignore bool
+
+	keyAsInt  bool
+	omitEmpty bool
+	omitZero  bool
+
+	// transform is the name of the transform applied to the field before
+	// it's encoded. See SetFieldTransform.
+	transform string
+
+	// toArray and intKeys are options of the struct type, set on a blank
+	// field.
+	toArray bool
+	intKeys bool
+}
+
+// parseStructTag parses a cbor struct tag. Unless an error is returned
+// for the name, the options which could be parsed are returned along with
+// an error for the others, so that encoding and decoding, which ignore
+// invalid tags, use as much of them as possible.
+func parseStructTag(tag string) (structTag, error) {
+	var st structTag
+	if tag == "-" {
+		st.ignore = true
+		return st, nil
+	}
+
+	rest := tag
+	if strings.HasPrefix(tag, "'") {
+		end := strings.IndexByte(tag[1:], '\'')
+		if end == -1 {
+			return st, fmt.Errorf("unterminated quoted name in tag %q", tag)
+		}
+		st.name, rest = tag[1:end+1], tag[end+2:]
+		if rest != "" && rest[0] != ',' {
+			return st, fmt.Errorf("unexpected %q after quoted name in tag %q", rest, tag)
+		}
+		rest = strings.TrimPrefix(rest, ",")
+	} else {
+		st.name, rest, _ = strings.Cut(tag, ",")
+	}
+
+	var err error
+	for rest != "" {
+		var opt string
+		opt, rest, _ = strings.Cut(rest, ",")
+		if strings.HasPrefix(opt, "transform=") {
+			switch {
+			case st.transform != "" && err == nil:
+				err = fmt.Errorf("duplicate option %q in tag %q", "transform", tag)
+			case opt == "transform=" && err == nil:
+				err = fmt.Errorf("empty transform name in tag %q", tag)
+			}
+			st.transform = strings.TrimPrefix(opt, "transform=")
+			continue
+		}
+		var set *bool
+		switch opt {
+		case "keyasint":
+			set = &st.keyAsInt
+		case "omitempty":
+			set = &st.omitEmpty
+		case "omitzero":
+			set = &st.omitZero
+		case "toarray":
+			set = &st.toArray
+		case "intkeys":
+			set = &st.intKeys
+		}
+		if set == nil {
+			if err == nil {
+				err = fmt.Errorf("unknown option %q in tag %q", opt, tag)
+			}
+			continue
+		}
+		if *set && err == nil {
+			err = fmt.Errorf("duplicate option %q in tag %q", opt, tag)
+		}
+		*set = true
+	}
+	return st, err
+}
+
+// CheckStructTags checks the cbor struct tags of the type of v, usually a
+// struct or a pointer to one, and of the struct types of its fields,
+// elements and map keys and values. It returns a *StructTagError for the
+// first invalid tag found, such as an unknown option, a keyasint option
+// without an integer name, a type option like toarray on a field other
+// than a blank one, or two fields with the same key.
+//
+// Encoding and decoding ignore what they can't parse in tags, so calling
+// CheckStructTags in a test catches mistakes which would otherwise make
+// fields silently use the wrong key.
+func CheckStructTags(v interface{}) error {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil
+	}
+	return checkStructTags(t, make(map[reflect.Type]bool))
+}
+
+// checkStructTags implements CheckStructTags, with the struct types
+// already checked.
+func checkStructTags(t reflect.Type, seen map[reflect.Type]bool) error {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			t = t.Elem()
+			continue
+		case reflect.Map:
+			if err := checkStructTags(t.Key(), seen); err != nil {
+				return err
+			}
+			t = t.Elem()
+			continue
+		}
+		break
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	keys := make(map[string]string)
+	for _, k := range structKeys(t, false) {
+		if other, ok := keys[k.name]; ok {
+			return &StructTagError{
+				Type:  t,
+				Field: t.Field(k.index).Name,
+				Tag:   t.Field(k.index).Tag.Get("cbor"),
+				msg:   fmt.Sprintf("key %q is also the key of field %s", k.name, other),
+			}
+		}
+		keys[k.name] = t.Field(k.index).Name
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("cbor")
+		if ok {
+			if err := checkFieldTag(field, tag); err != nil {
+				return &StructTagError{Type: t, Field: field.Name, Tag: tag, msg: err.Error()}
+			}
+		}
+		if !ignoredField(field) {
+			if err := checkStructTags(field.Type, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkFieldTag checks the cbor tag of a struct field.
+func checkFieldTag(field reflect.StructField, tag string) error {
+	st, err := parseStructTag(tag)
+	if err != nil {
+		return err
+	}
+	if field.Name == "_" {
+		if st.intKeys && st.name != "" {
+			if _, err := strconv.ParseInt(st.name, 10, 64); err != nil {
+				return fmt.Errorf("intkeys base %q isn't an integer", st.name)
+			}
+		}
+		if st.keyAsInt || st.omitEmpty || st.omitZero || st.transform != "" {
+			return fmt.Errorf("field options in tag %q of a blank field", tag)
+		}
+		return nil
+	}
+	if st.toArray || st.intKeys {
+		return fmt.Errorf("type options in tag %q of a field which isn't blank", tag)
+	}
+	if st.keyAsInt {
+		name := st.name
+		if name == "" {
+			name = field.Name
+		}
+		if _, err := strconv.ParseInt(name, 10, 64); err != nil {
+			if _, err := strconv.ParseUint(name, 10, 64); err != nil {
+				return fmt.Errorf("keyasint key %q isn't an integer", name)
+			}
+		}
+	}
+	return nil
+}
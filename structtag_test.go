@@ -0,0 +1,162 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+func TestStructTagOptions(t *testing.T) {
+	type point struct {
+		_ struct{} `cbor:",toarray"`
+		X int
+		Y int
+	}
+	type shape struct {
+		Name    string    `cbor:"'name, full'"`
+		Created time.Time `cbor:",omitzero"`
+		Count   int       `cbor:",omitzero"`
+		Origin  point
+	}
+
+	v := shape{Name: "a", Origin: point{X: 1, Y: -2}}
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// {"name, full": "a", "Origin": [1, -2]}
+	want := "a2" + "6a6e616d652c2066756c6c" + "6161" + "664f726967696e" + "820121"
+	if got := hex.EncodeToString(data); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	var got shape
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != v {
+		t.Fatalf("expected %+v, got %+v", v, got)
+	}
+
+	var p point
+	if err := cbor.Unmarshal([]byte{0x81, 0x01}, &p); !errors.Is(err, cbor.ErrInvalidType) {
+		t.Fatalf("expected error wrapping %v, got %v", cbor.ErrInvalidType, err)
+	}
+}
+
+func TestCheckStructTags(t *testing.T) {
+	type valid struct {
+		_     struct{} `cbor:"1,intkeys"`
+		A     int
+		B     string `cbor:"'b,c',omitempty,omitzero"`
+		C     bool   `cbor:"-"`
+		D     bool   `cbor:"-,"`
+		E     int    `cbor:"-7,keyasint"`
+		F     string `cbor:"f,omitempty,transform=pii"`
+		inner int
+	}
+	if err := cbor.CheckStructTags(&valid{}); err != nil {
+		t.Fatal(err)
+	}
+
+	type nested struct {
+		X int `cbor:",omitempy"`
+	}
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"unknown option", struct {
+			A int `cbor:"a,omitempy"`
+		}{}, `unknown option "omitempy"`},
+		{"duplicate option", struct {
+			A int `cbor:"a,omitempty,omitempty"`
+		}{}, `duplicate option "omitempty"`},
+		{"unterminated quote", struct {
+			A int `cbor:"'a,omitempty"`
+		}{}, "unterminated quoted name"},
+		{"keyasint name", struct {
+			A int `cbor:",keyasint"`
+		}{}, `keyasint key "A" isn't an integer`},
+		{"type option", struct {
+			A int `cbor:",toarray"`
+		}{}, "isn't blank"},
+		{"empty transform", struct {
+			A int `cbor:"a,transform="`
+		}{}, "empty transform name"},
+		{"duplicate transform", struct {
+			A int `cbor:"a,transform=x,transform=y"`
+		}{}, `duplicate option "transform"`},
+		{"duplicate key", struct {
+			A int `cbor:"x"`
+			B int `cbor:"x"`
+		}{}, "also the key of field A"},
+		{"nested", struct {
+			N map[string][]*nested
+		}{}, `field X of cbor_test.nested`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := cbor.CheckStructTags(test.v)
+			var tagErr *cbor.StructTagError
+			if !errors.As(err, &tagErr) {
+				t.Fatalf("expected *StructTagError, got %v", err)
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("expected error containing %q, got %q", test.want, err)
+			}
+		})
+	}
+}
+
+type jsonTagged struct {
+	Name   string `json:"name"`
+	Note   string `json:"note,omitempty"`
+	Secret string `json:"-"`
+	ID     int    `json:"id,string"`
+	Both   int    `json:"j" cbor:"c"`
+	Plain  int
+}
+
+func TestJSONTags(t *testing.T) {
+	v := jsonTagged{Name: "x", Secret: "s", ID: 1, Both: 2, Plain: 3}
+
+	enc := cbor.NewEncoderBuffer(nil)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if got := enc.Bytes()[0]; got != 0xa6 {
+		t.Fatalf("expected json tags to be ignored by default, got header %x", got)
+	}
+
+	enc = cbor.NewEncoderBuffer(nil)
+	enc.SetJSONTags(true)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	want := "a4" + "646e616d656178" + "62696401" + "616302" + "65506c61696e03"
+	if got := hex.EncodeToString(enc.Bytes()); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	dec := cbor.NewDecoder(bytes.NewReader(append(enc.Bytes(), decodeHex(t, "a166536563726574617a")...)))
+	dec.SetJSONTags(true)
+	var got jsonTagged
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (jsonTagged{Name: "x", ID: 1, Both: 2, Plain: 3}); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	// Fields with the json tag "-" are left out.
+	if err := dec.Decode(&got); err != nil || got.Secret != "" {
+		t.Fatalf("expected Secret to be left out, got %q (err %v)", got.Secret, err)
+	}
+}
@@ -0,0 +1,67 @@
+package cbor
+
+import (
+	"reflect"
+)
+
+// UnknownTagMode controls how a Decoder decodes tags it doesn't know.
+type UnknownTagMode int
+
+const (
+	// UnknownTagError makes unknown tags an error.
+	UnknownTagError UnknownTagMode = iota
+
+	// UnknownTagUnwrap ignores unknown tags, decoding their content as if
+	// it weren't tagged.
+	UnknownTagUnwrap
+
+	// UnknownTagPreserve decodes unknown tags into empty interfaces as
+	// TagValue values, keeping their number. Decoding them into other
+	// types is an error, as with UnknownTagError.
+	UnknownTagPreserve
+)
+
+// TagValue is a tagged item, with any tag number. Tags are decoded into a
+// TagValue whatever their number, with their content decoded as into an
+// empty interface, and unknown tags are decoded into empty interfaces as
+// TagValue values with UnknownTagPreserve.
+//
+// A TagValue is encoded as its tag number followed by the encoding of its
+// content.
+type TagValue struct {
+	Number  uint64
+	Content interface{}
+}
+
+// tagValueType is the reflect.Type of TagValue.
+var tagValueType = reflect.TypeOf(TagValue{})
+
+// encodeTagValue writes a TagValue.
+func encodeTagValue(e *Encoder, v reflect.Value) error {
+	tv := v.Interface().(TagValue)
+	e.buf = AppendTag(e.buf, tv.Number)
+	return encodeInterface(e, v.Field(1))
+}
+
+// decodeTagValue decodes a tag of any number into a TagValue.
+func decodeTagValue(dec *Decoder, rv reflect.Value, b byte) error {
+	if MajorType(b>>5) != MajorTypeTag {
+		return typeError(MajorType(b>>5).String(), rv.Type())
+	}
+	n, err := dec.readArgument(b & 0x1f)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	return dec.decodeTagContent(rv, n)
+}
+
+// decodeTagContent decodes the content of a tag with the number n into rv,
+// an empty interface or a TagValue, as a TagValue.
+func (dec *Decoder) decodeTagContent(rv reflect.Value, n uint64) error {
+	tv := TagValue{Number: n}
+	if err := dec.decodeValue(reflect.ValueOf(&tv.Content).Elem()); err != nil {
+		return err
+	}
+	rv.Set(reflect.ValueOf(tv))
+	return nil
+}
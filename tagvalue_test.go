@@ -0,0 +1,98 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestUnknownTagMode(t *testing.T) {
+	// [99(1), 2]
+	data := decodeHex(t, "82d8630102")
+
+	decode := func(mode cbor.UnknownTagMode, v interface{}) error {
+		dec := cbor.NewDecoder(bytes.NewReader(data))
+		dec.SetUnknownTagMode(mode)
+		return dec.Decode(v)
+	}
+
+	var v []interface{}
+	if err := decode(cbor.UnknownTagError, &v); err == nil {
+		t.Fatal("expected error for an unknown tag")
+	}
+
+	if err := decode(cbor.UnknownTagUnwrap, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v[0] != uint64(1) {
+		t.Fatalf("expected the tag to be unwrapped, got %#v", v[0])
+	}
+	var n []int
+	if err := decode(cbor.UnknownTagUnwrap, &n); err != nil || n[0] != 1 {
+		t.Fatalf("expected the tag to be unwrapped, got %v (err %v)", n, err)
+	}
+
+	if err := decode(cbor.UnknownTagPreserve, &v); err != nil {
+		t.Fatal(err)
+	}
+	if want := (cbor.TagValue{Number: 99, Content: uint64(1)}); v[0] != want {
+		t.Fatalf("expected %#v, got %#v", want, v[0])
+	}
+	if err := decode(cbor.UnknownTagPreserve, &n); err == nil {
+		t.Fatal("expected error preserving a tag in an int")
+	}
+}
+
+func TestTagValue(t *testing.T) {
+	data, err := cbor.Marshal(cbor.TagValue{Number: 99, Content: []interface{}{"a", cbor.TagValue{Number: 100, Content: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(data), "d863826161d86402"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	// Tags of any number are decoded into a TagValue, with their content
+	// decoded as into an empty interface.
+	var tv cbor.TagValue
+	if err := cbor.Unmarshal(decodeHex(t, "d863826161f5"), &tv); err != nil {
+		t.Fatal(err)
+	}
+	if content, ok := tv.Content.([]interface{}); tv.Number != 99 || !ok || len(content) != 2 || content[0] != "a" {
+		t.Fatalf("unexpected tag value %#v", tv)
+	}
+	if err := cbor.Unmarshal([]byte{0x01}, &tv); err == nil {
+		t.Fatal("expected error decoding an untagged item into a TagValue")
+	}
+}
+
+func TestTagValueMapKeys(t *testing.T) {
+	decode := func(data string) (interface{}, error) {
+		dec := cbor.NewDecoder(bytes.NewReader(decodeHex(t, data)))
+		dec.SetUnknownTagMode(cbor.UnknownTagPreserve)
+		var v interface{}
+		err := dec.Decode(&v)
+		return v, err
+	}
+
+	// {99([1, 2]): 1}
+	v, err := decode("a1d86382010201")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := cbor.NewArrayKey(uint64(1), uint64(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := v.(map[interface{}]interface{})[cbor.TagValue{Number: 99, Content: k}]; got != uint64(1) {
+		t.Fatalf("expected 1, got %#v in %#v", got, v)
+	}
+
+	// {99({1: 2}): 1}
+	if _, err := decode("a1d863a1010201"); !errors.Is(err, cbor.ErrInvalidType) {
+		t.Fatalf("expected ErrInvalidType, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+// Package testvectors provides the examples of encoded CBOR data items from
+// Appendix A of RFC 8949, for checking the conformance of encoders and
+// decoders.
+//
+// The vectors are embedded in the same JSON format used by the
+// github.com/cbor/test-vectors repository.
+//
+// https://www.rfc-editor.org/rfc/rfc8949.html#appendix-A
+package testvectors
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+//go:embed appendix_a.json
+var appendixA []byte
+
+// Vector is an example CBOR data item.
+type Vector struct {
+	// CBOR is the encoded data item.
+	CBOR []byte
+
+	// Hex is the hexadecimal encoding of CBOR.
+	Hex string
+
+	// RoundTrip is true if CBOR is the preferred serialization of the data
+	// item, so that an encoder of the decoded value should produce the same
+	// bytes. It is false for items encoded with indefinite lengths, or
+	// with floats which have a shorter encoding.
+	RoundTrip bool
+
+	// Decoded is the value of the data item in the JSON data model, if it
+	// has one. Numbers are json.Number values, to preserve integers which
+	// don't fit in a float64, maps are map[string]interface{} and arrays
+	// are []interface{}.
+	//
+	// It is nil if the item is null, or if it can't be represented in
+	// JSON, in which case Diagnostic is set.
+	Decoded interface{}
+
+	// Diagnostic is the diagnostic notation of the data item, described in
+	// section 8 of RFC 8949, for items which can't be represented in JSON.
+	Diagnostic string
+}
+
+// HasDecoded reports whether the vector's value is given by Decoded rather
+// than Diagnostic.
+func (v Vector) HasDecoded() bool {
+	return v.Diagnostic == ""
+}
+
+// AppendixA returns the examples of encoded CBOR data items from Appendix A
+// of RFC 8949, in the order they appear in the RFC.
+//
+// Each call returns a new slice, which the caller may modify.
+func AppendixA() []Vector {
+	vectors, err := parse(appendixA)
+	if err != nil {
+		panic(err)
+	}
+	return vectors
+}
+
+// parse decodes test vectors in the JSON format of the
+// github.com/cbor/test-vectors repository.
+func parse(data []byte) ([]Vector, error) {
+	var raw []struct {
+		CBOR       []byte          `json:"cbor"`
+		Hex        string          `json:"hex"`
+		RoundTrip  bool            `json:"roundtrip"`
+		Decoded    json.RawMessage `json:"decoded"`
+		Diagnostic string          `json:"diagnostic"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("testvectors: %w", err)
+	}
+
+	vectors := make([]Vector, len(raw))
+	for i, r := range raw {
+		vectors[i] = Vector{
+			CBOR:       r.CBOR,
+			Hex:        r.Hex,
+			RoundTrip:  r.RoundTrip,
+			Diagnostic: r.Diagnostic,
+		}
+		if r.Decoded == nil {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(r.Decoded))
+		dec.UseNumber()
+		if err := dec.Decode(&vectors[i].Decoded); err != nil {
+			return nil, fmt.Errorf("testvectors: vector %s: %w", r.Hex, err)
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,30 @@
+package testvectors_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/picatz/cbor/testvectors"
+)
+
+func TestAppendixA(t *testing.T) {
+	vectors := testvectors.AppendixA()
+	if len(vectors) != 81 {
+		t.Fatalf("expected 81 vectors, got %d", len(vectors))
+	}
+
+	for _, v := range vectors {
+		if got := hex.EncodeToString(v.CBOR); got != v.Hex {
+			t.Errorf("vector %s: CBOR is %s", v.Hex, got)
+		}
+		if v.HasDecoded() == (v.Diagnostic != "") {
+			t.Errorf("vector %s: expected either a decoded value or diagnostic notation", v.Hex)
+		}
+	}
+
+	// The slice is a copy.
+	vectors[0].Hex = "ff"
+	if testvectors.AppendixA()[0].Hex != "00" {
+		t.Fatal("expected AppendixA to return a new slice")
+	}
+}
@@ -0,0 +1,136 @@
+package cbor_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/picatz/cbor"
+	"github.com/picatz/cbor/testvectors"
+)
+
+// knownDecodeFailures are the Appendix A vectors which Unmarshal can't yet
+// decode into an interface{} matching their decoded value, and why.
+var knownDecodeFailures = map[string]string{}
+
+// knownEncodeFailures are the Appendix A vectors in preferred serialization
+// which Marshal doesn't reproduce after they are decoded, and why.
+var knownEncodeFailures = map[string]string{
+	"3bffffffffffffffff":   "negative integers below math.MinInt64 are decoded as big.Int, which is encoded as a bignum",
+	"f90000":               "floats are always encoded as float64",
+	"f90001":               "floats are always encoded as float64",
+	"f90400":               "floats are always encoded as float64",
+	"f93c00":               "floats are always encoded as float64",
+	"f93e00":               "floats are always encoded as float64",
+	"f97bff":               "floats are always encoded as float64",
+	"f97c00":               "floats are always encoded as float64",
+	"f97e00":               "floats are always encoded as float64",
+	"f98000":               "floats are always encoded as float64",
+	"f9c400":               "floats are always encoded as float64",
+	"f9fc00":               "floats are always encoded as float64",
+	"fa47c35000":           "floats are always encoded as float64",
+	"fa7f7fffff":           "floats are always encoded as float64",
+	"f7":                   "undefined is decoded as nil",
+	"c11a514b67b0":         "epoch times are decoded as time.Time, which is encoded as a date/time string",
+	"c1fb41d452d9ec200000": "epoch times are decoded as time.Time, which is encoded as a date/time string",
+}
+
+func TestAppendixA(t *testing.T) {
+	for _, v := range testvectors.AppendixA() {
+		v := v
+		t.Run(v.Hex, func(t *testing.T) {
+			// Every vector is a single well-formed item.
+			rest, err := cbor.Skip(v.CBOR)
+			if err != nil {
+				t.Fatalf("expected a well-formed item: %v", err)
+			}
+			if len(rest) != 0 {
+				t.Fatalf("expected a single item, got %d trailing bytes", len(rest))
+			}
+
+			var decoded interface{}
+			decodeErr := cbor.Unmarshal(v.CBOR, &decoded)
+
+			if v.HasDecoded() {
+				ok := decodeErr == nil && matchesDecoded(decoded, v.Decoded)
+				reason, known := knownDecodeFailures[v.Hex]
+				switch {
+				case !ok && !known:
+					t.Errorf("decoded %#v (err %v), expected %v", decoded, decodeErr, v.Decoded)
+				case ok && known:
+					t.Errorf("decoded correctly, remove it from knownDecodeFailures (%s)", reason)
+				}
+			}
+
+			if v.RoundTrip && decodeErr == nil {
+				// Preferred serialization doesn't fix the order of map
+				// keys, so only the length of the encoding is compared
+				// along with its value.
+				encoded, err := cbor.Marshal(decoded)
+				ok := err == nil && len(encoded) == len(v.CBOR) && cbor.Equal(encoded, v.CBOR)
+				reason, known := knownEncodeFailures[v.Hex]
+				switch {
+				case !ok && !known:
+					t.Errorf("encoded %#v as %x (err %v)", decoded, encoded, err)
+				case ok && known:
+					t.Errorf("encoded correctly, remove it from knownEncodeFailures (%s)", reason)
+				}
+			}
+		})
+	}
+}
+
+// matchesDecoded reports whether a value decoded into an interface{} is
+// equal to the decoded value of a test vector, in the JSON data model.
+func matchesDecoded(got, want interface{}) bool {
+	switch want := want.(type) {
+	case nil:
+		return got == nil
+	case bool, string:
+		return got == want
+	case json.Number:
+		s := want.String()
+		if strings.ContainsAny(s, ".eE") {
+			f, ok := got.(float64)
+			w, err := strconv.ParseFloat(s, 64)
+			return ok && err == nil && f == w && math.Signbit(f) == math.Signbit(w)
+		}
+		switch got := got.(type) {
+		case uint64:
+			return strconv.FormatUint(got, 10) == s
+		case int64:
+			return strconv.FormatInt(got, 10) == s
+		case int:
+			return strconv.Itoa(got) == s
+		case *big.Int:
+			return got.String() == s
+		}
+		return false
+	case []interface{}:
+		got, ok := got.([]interface{})
+		if !ok || len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if !matchesDecoded(got[i], want[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		got, ok := got.(map[interface{}]interface{})
+		if !ok || len(got) != len(want) {
+			return false
+		}
+		for k, w := range want {
+			if !matchesDecoded(got[k], w) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package cbor
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// timeType is the reflect.Type of time.Time.
+var timeType = reflect.TypeOf(time.Time{})
+
+// TimeMode controls how time.Time values are encoded.
+type TimeMode int
+
+const (
+	// TimeString encodes times as date/time strings with tag 0, formatted
+	// with the TimeLayout of the encoder.
+	TimeString TimeMode = iota
+
+	// TimeUnix encodes times as integer seconds since the Unix epoch,
+	// truncating any fraction of a second.
+	TimeUnix
+
+	// TimeUnixMicro encodes times as floating-point seconds since the Unix
+	// epoch, with microsecond precision.
+	TimeUnixMicro
+
+	// TimeUnixDynamic encodes times as integer seconds since the Unix
+	// epoch, or floating-point seconds if they have a fraction of a
+	// second.
+	TimeUnixDynamic
+)
+
+// encodeTime writes a time.Time as a date/time string (tag 0) formatted
+// with the TimeLayout of the encoder, or time.RFC3339Nano if it's empty,
+// or as a number of seconds in the other time modes. Zero times are
+// encoded as null in the other modes, since they would otherwise be
+// encoded as a date in year 1.
+func encodeTime(e *Encoder, v reflect.Value) error {
+	t := v.Interface().(time.Time)
+	if e.options.TimeMode != TimeString {
+		if t.IsZero() {
+			return e.writeNull()
+		}
+		if e.options.TimeTag {
+			e.buf = AppendTag(e.buf, uint64(TagUnixTime))
+		}
+		switch e.options.TimeMode {
+		case TimeUnixMicro:
+			return e.writeFloat(float64(t.UnixMicro()) / 1e6)
+		case TimeUnixDynamic:
+			if t.Nanosecond() != 0 {
+				return e.writeFloat(float64(t.Unix()) + float64(t.Nanosecond())/1e9)
+			}
+		}
+		return e.writeInt(t.Unix())
+	}
+
+	layout := e.options.TimeLayout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	e.buf = AppendTag(e.buf, uint64(TagDateTimeString))
+	e.buf = AppendString(e.buf, t.Format(layout))
+	return nil
+}
+
+// decodeTime decodes an item into a time.Time. Date/time strings, with or
+// without tag 0, are parsed as RFC 3339 or with the TimeLayouts of the
+// decoder, keeping their UTC offset, numbers, with or without tag 1, are
+// seconds since the Unix epoch, and extended times (TagExtendedTime) must
+// be in UTC.
+func decodeTime(dec *Decoder, rv reflect.Value, b byte) error {
+	t, err := dec.readTime(rv.Type(), b)
+	if err != nil {
+		return err
+	}
+	if t.TimeScale != TimeScaleUTC {
+		return newError(ErrInvalidType, "cbor: cannot decode extended time in TAI into time.Time")
+	}
+	rv.Set(reflect.ValueOf(t.Time))
+	return nil
+}
+
+// readTime reads a time item for a value of type rt, given its initial
+// byte b, as an ExtendedTime.
+func (dec *Decoder) readTime(rt reflect.Type, b byte) (ExtendedTime, error) {
+	if MajorType(b>>5) == MajorTypeTag {
+		tag, err := dec.readArgument(b & 0x1f)
+		if err != nil {
+			return ExtendedTime{}, err
+		}
+		if tag == TagExtendedTime {
+			return dec.readExtendedTime()
+		}
+		if Tag(tag) != TagDateTimeString && Tag(tag) != TagUnixTime {
+			return ExtendedTime{}, typeError("tag "+itoa(int64(tag)), rt)
+		}
+		if b, err = dec.readByte(); err != nil {
+			return ExtendedTime{}, err
+		}
+		if Tag(tag) == TagDateTimeString && MajorType(b>>5) != MajorTypeTextString {
+			return ExtendedTime{}, newError(ErrInvalidType, "cbor: date/time string is not a text string")
+		}
+	}
+
+	var t ExtendedTime
+	switch {
+	case MajorType(b>>5) == MajorTypeTextString:
+		var s string
+		if err := dec.decodeItem(reflect.ValueOf(&s).Elem(), b); err != nil {
+			return t, err
+		}
+		var err error
+		if t.Time, err = dec.parseTime(s); err != nil {
+			return t, err
+		}
+	case MajorType(b>>5) == MajorTypeUnsignedInt || MajorType(b>>5) == MajorTypeNegativeInt:
+		var n int64
+		if err := dec.decodeItem(reflect.ValueOf(&n).Elem(), b); err != nil {
+			return t, err
+		}
+		t.Time = time.Unix(n, 0)
+	case b == 0xf9 || b == 0xfa || b == 0xfb:
+		var f float64
+		if err := decodeFloatPlan(dec, reflect.ValueOf(&f).Elem(), b); err != nil {
+			return t, err
+		}
+		secs, frac := math.Modf(f)
+		if math.IsNaN(f) || secs < math.MinInt64 || secs >= math.MaxInt64 {
+			return t, newError(ErrInvalidType, "cbor: invalid epoch time")
+		}
+		t.Time = time.Unix(int64(secs), int64(frac*1e9))
+	case b == 0xf6 || b == 0xf7:
+	default:
+		return t, typeError("major type "+itoa(int64(b>>5)), rt)
+	}
+	return t, nil
+}
+
+// DateTime is a date/time string (tag 0) along with the time it's parsed
+// as, for applications which must keep the string as it was produced,
+// such as audit logs. Decoding into a time.Time keeps the UTC offset of
+// the string, but not its exact text, such as the precision of its
+// fraction of a second.
+type DateTime struct {
+	// Time is the time of the string, with its UTC offset.
+	Time time.Time
+
+	// Text is the date/time string. If it's empty, Time is formatted
+	// with the TimeLayout of the encoder instead.
+	Text string
+}
+
+// dateTimeType is the reflect.Type of DateTime.
+var dateTimeType = reflect.TypeOf(DateTime{})
+
+// encodeDateTime writes a DateTime as a date/time string, which is its
+// Text as it is, if set.
+func encodeDateTime(e *Encoder, v reflect.Value) error {
+	dt := v.Interface().(DateTime)
+	if dt.Text == "" {
+		layout := e.options.TimeLayout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		dt.Text = dt.Time.Format(layout)
+	}
+	e.buf = AppendTag(e.buf, uint64(TagDateTimeString))
+	return e.writeString(dt.Text)
+}
+
+// decodeDateTime decodes a date/time string, with or without tag 0, into
+// a DateTime, parsing it like decodeTime.
+func decodeDateTime(dec *Decoder, rv reflect.Value, b byte) error {
+	if MajorType(b>>5) == MajorTypeTag {
+		tag, err := dec.readArgument(b & 0x1f)
+		if err != nil {
+			return err
+		}
+		if Tag(tag) != TagDateTimeString {
+			return typeError("tag "+itoa(int64(tag)), rv.Type())
+		}
+		if b, err = dec.readByte(); err != nil {
+			return unexpectedEOF(err)
+		}
+		if MajorType(b>>5) != MajorTypeTextString {
+			return newError(ErrInvalidType, "cbor: date/time string is not a text string")
+		}
+	}
+
+	var dt DateTime
+	switch {
+	case MajorType(b>>5) == MajorTypeTextString:
+		if err := dec.decodeItem(reflect.ValueOf(&dt.Text).Elem(), b); err != nil {
+			return err
+		}
+		var err error
+		if dt.Time, err = dec.parseTime(dt.Text); err != nil {
+			return err
+		}
+	case b == 0xf6 || b == 0xf7:
+	default:
+		return typeError("major type "+itoa(int64(b>>5)), rv.Type())
+	}
+	rv.Set(reflect.ValueOf(dt))
+	return nil
+}
+
+// parseTime parses a date/time string as RFC 3339, or with the first of
+// the decoder's TimeLayouts that matches it.
+func (dec *Decoder) parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err == nil {
+		return t, nil
+	}
+	for _, layout := range dec.options.TimeLayouts {
+		if t, lerr := time.Parse(layout, s); lerr == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, newError(ErrInvalidType, "cbor: invalid date/time string "+strconv.Quote(s))
+}
@@ -0,0 +1,150 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/picatz/cbor"
+)
+
+func TestTime(t *testing.T) {
+	want := time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)
+
+	// Times are encoded as RFC 3339 date/time strings by default.
+	data, err := cbor.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(data); got != "c074323031332d30332d32315432303a30343a30305a" {
+		t.Fatalf("unexpected encoding %s", got)
+	}
+
+	tests := []struct {
+		name string
+		hex  string
+	}{
+		{"tag 0", "c074323031332d30332d32315432303a30343a30305a"},
+		{"untagged", "74323031332d30332d32315432303a30343a30305a"},
+		{"tag 1", "c11a514b67b0"},
+		{"tag 1 float", "c1fb41d452d9ec000000"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(test.hex)
+			var got time.Time
+			if err := cbor.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(want) {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+		})
+	}
+
+	t.Run("layouts", func(t *testing.T) {
+		const layout = "2006-01-02 15:04:05"
+
+		var buf bytes.Buffer
+		enc := cbor.NewEncoder(&buf)
+		enc.SetTimeLayout(layout)
+		if err := enc.Encode(want); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+
+		var got time.Time
+		if err := cbor.Unmarshal(data, &got); err == nil {
+			t.Fatal("expected an error without the layout")
+		}
+
+		dec := cbor.NewDecoder(bytes.NewReader(data))
+		dec.SetTimeLayouts(time.RFC1123, layout)
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("interface", func(t *testing.T) {
+		var got interface{}
+		if err := cbor.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if tm, ok := got.(time.Time); !ok || !tm.Equal(want) {
+			t.Fatalf("expected %v, got %#v", want, got)
+		}
+	})
+}
+
+func TestTime_invalidEpoch(t *testing.T) {
+	for _, s := range []string{
+		"c1fb7ff8000000000000", // NaN
+		"c1fb7ff0000000000000", // +Inf
+		"c1fbfff0000000000000", // -Inf
+		"c1fb43e0000000000000", // 2^63
+		"c1fbc3e0000000000001", // below -2^63
+	} {
+		data, _ := hex.DecodeString(s)
+
+		var tm time.Time
+		if err := cbor.Unmarshal(data, &tm); !errors.Is(err, cbor.ErrInvalidType) {
+			t.Errorf("%s: expected ErrInvalidType, got %v (%v)", s, err, tm)
+		}
+		var et cbor.EpochTime
+		if err := cbor.Unmarshal(data, &et); !errors.Is(err, cbor.ErrInvalidType) {
+			t.Errorf("%s: expected ErrInvalidType for EpochTime, got %v (%v)", s, err, et.Time)
+		}
+		var v interface{}
+		if err := cbor.Unmarshal(data, &v); err == nil {
+			t.Errorf("%s: expected an error for interfaces, got %v", s, v)
+		}
+	}
+}
+
+func TestTime_offset(t *testing.T) {
+	const s = "2024-03-01T10:00:00.50+05:30"
+	data := append([]byte{0xc0, 0x78, byte(len(s))}, s...)
+
+	var tm time.Time
+	if err := cbor.Unmarshal(data, &tm); err != nil {
+		t.Fatal(err)
+	}
+	if _, offset := tm.Zone(); offset != 5*3600+30*60 {
+		t.Fatalf("expected the offset of the string, got %d", offset)
+	}
+
+	var dt cbor.DateTime
+	if err := cbor.Unmarshal(data, &dt); err != nil {
+		t.Fatal(err)
+	}
+	if dt.Text != s || !dt.Time.Equal(tm) {
+		t.Fatalf("unexpected date/time %+v", dt)
+	}
+
+	// The text is encoded as it is, rather than reformatted as
+	// "2024-03-01T10:00:00.5+05:30".
+	got, err := cbor.Marshal(dt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected %x, got %x", data, got)
+	}
+
+	got, err = cbor.Marshal(cbor.DateTime{Time: tm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-03-01T10:00:00.5+05:30"; !bytes.HasSuffix(got, []byte(want)) {
+		t.Fatalf("expected %s, got %q", want, got)
+	}
+
+	if err := cbor.Unmarshal([]byte{0xc1, 0x00}, &dt); err == nil {
+		t.Fatal("expected an error for an epoch time")
+	}
+}
@@ -0,0 +1,138 @@
+package cbor
+
+import "io"
+
+// Transform copies the CBOR sequence (RFC 8742) read from r to w, letting
+// filter drop or rewrite items by their path on the way, such as to scrub
+// secrets from captured traffic or to downsample telemetry, without
+// decoding the items into Go values.
+//
+// The filter is called with each item of the sequence, whose path is
+// empty, and with each element of the arrays and each value of the maps
+// in the items it keeps, in order. The path of an element is the path of
+// its array followed by its index, as an int, and the path of a map value
+// is the path of its map followed by its key, as a string for text
+// strings, an int64 for integers, or a Value for other keys, like the
+// paths of a Patch. Tags are kept, and paths apply to the content of
+// tagged items. The path and the item are only valid until filter
+// returns.
+//
+// The filter returns the item to write in place of raw, or nil to keep
+// raw, descending into its elements if it's an array or a map, and false
+// to drop it, along with its key if it's a map value. Arrays and maps
+// whose elements are dropped or rewritten are re-encoded with definite
+// lengths; everything else is copied as is.
+//
+// Items are read one at a time, so the whole sequence is never held in
+// memory. If an item read from r is malformed, or filter returns a
+// malformed item, the items before it have been written to w.
+func Transform(r io.Reader, w io.Writer, filter func(path []interface{}, raw RawMessage) (RawMessage, bool)) error {
+	dec := NewDecoder(r)
+	t := &transformer{filter: filter}
+	var out []byte
+	for {
+		raw, err := dec.ReadRaw()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		out, _, err = t.appendItem(out[:0], raw)
+		if err != nil {
+			return err
+		}
+		if len(out) == 0 {
+			continue
+		}
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+}
+
+// transformer is the state of a Transform call.
+type transformer struct {
+	filter func(path []interface{}, raw RawMessage) (RawMessage, bool)
+
+	// path is the path of the item being filtered, whose backing array is
+	// reused for every item.
+	path []interface{}
+}
+
+// appendItem appends the filtered item to dst, reporting whether it was
+// changed, which includes being dropped.
+func (t *transformer) appendItem(dst, item []byte) ([]byte, bool, error) {
+	repl, keep := t.filter(t.path, item)
+	switch {
+	case !keep:
+		return dst, true, nil
+	case repl != nil:
+		if n, err := itemLength(repl, 0); err != nil || n != len(repl) {
+			return nil, false, newError(ErrMalformed, "cbor: Transform filter returned a malformed item")
+		}
+		return append(dst, repl...), true, nil
+	}
+
+	tags, content := splitTags(item)
+	v := Value{raw: content}
+	mt := v.Type()
+	if mt != MajorTypeArray && mt != MajorTypeMap {
+		return append(dst, item...), false, nil
+	}
+
+	var (
+		body    []byte
+		count   int
+		changed bool
+		key     []byte
+		err     error
+	)
+	i := 0
+	v.each(func(elem []byte) bool {
+		if mt == MajorTypeMap && key == nil {
+			key = elem
+			return true
+		}
+
+		start := len(body)
+		if mt == MajorTypeMap {
+			body = append(body, key...)
+			t.path = append(t.path, pathElem(key))
+		} else {
+			t.path = append(t.path, i)
+		}
+		end := len(body)
+
+		var c bool
+		body, c, err = t.appendItem(body, elem)
+		t.path = t.path[:len(t.path)-1]
+		if err != nil {
+			return false
+		}
+		if len(body) == end {
+			// The element was dropped, along with its key.
+			body = body[:start]
+		} else {
+			count++
+		}
+		changed = changed || c
+		key = nil
+		i++
+		return true
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !changed {
+		return append(dst, item...), false, nil
+	}
+	dst = append(dst, tags...)
+	if mt == MajorTypeArray {
+		dst = AppendArrayHeader(dst, count)
+	} else {
+		dst = AppendMapHeader(dst, count)
+	}
+	return append(dst, body...), true, nil
+}
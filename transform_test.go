@@ -0,0 +1,105 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestTransform(t *testing.T) {
+	type record struct {
+		User     string `cbor:"user"`
+		Password string `cbor:"password"`
+		Readings []int  `cbor:"readings"`
+	}
+	type scrubbed struct {
+		User     string `cbor:"user"`
+		Readings []int  `cbor:"readings"`
+	}
+
+	var in, want []byte
+	for i, r := range []record{
+		{"alice", "secret", []int{1, 2, 3, 4}},
+		{"bob", "hunter2", []int{5}},
+		{"carol", "pa55", []int{6, 7, 8}},
+	} {
+		b, err := cbor.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			// A self-described item, whose tag is kept.
+			b = append([]byte{0xd9, 0xd9, 0xf7}, b...)
+		}
+		in = append(in, b...)
+
+		if i == 1 {
+			continue
+		}
+		var readings []int
+		for j := 0; j < len(r.Readings); j += 2 {
+			readings = append(readings, r.Readings[j])
+		}
+		b, err = cbor.Marshal(scrubbed{"***", readings})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			b = append([]byte{0xd9, 0xd9, 0xf7}, b...)
+		}
+		want = append(want, b...)
+	}
+	// An indefinite-length array which isn't changed is copied as is.
+	in = append(in, 0x9f, 0x01, 0x02, 0xff)
+	want = append(want, 0x9f, 0x01, 0x02, 0xff)
+
+	items := 0
+	var out bytes.Buffer
+	err := cbor.Transform(bytes.NewReader(in), &out, func(path []interface{}, raw cbor.RawMessage) (cbor.RawMessage, bool) {
+		switch {
+		case len(path) == 0:
+			// Drop the second record.
+			items++
+			return nil, items != 2
+		case path[0] == "password":
+			return nil, false
+		case path[0] == "user":
+			return cbor.AppendString(nil, "***"), true
+		case len(path) == 2 && path[0] == "readings":
+			return nil, path[1].(int)%2 == 0
+		}
+		return nil, true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("expected %x, got %x", want, got)
+	}
+}
+
+func TestTransform_errors(t *testing.T) {
+	keep := func([]interface{}, cbor.RawMessage) (cbor.RawMessage, bool) {
+		return nil, true
+	}
+
+	// [1, 2] followed by a truncated array: the first item is written.
+	in, _ := hex.DecodeString("8201028201")
+	var out bytes.Buffer
+	if err := cbor.Transform(bytes.NewReader(in), &out, keep); !errors.Is(err, cbor.ErrTruncated) {
+		t.Fatalf("expected %v, got %v", cbor.ErrTruncated, err)
+	}
+	if got := hex.EncodeToString(out.Bytes()); got != "820102" {
+		t.Fatalf("expected 820102, got %s", got)
+	}
+
+	malformed := func([]interface{}, cbor.RawMessage) (cbor.RawMessage, bool) {
+		return cbor.RawMessage{0x82, 0x01}, true
+	}
+	if err := cbor.Transform(bytes.NewReader(in), &out, malformed); !errors.Is(err, cbor.ErrMalformed) {
+		t.Fatalf("expected %v, got %v", cbor.ErrMalformed, err)
+	}
+}
@@ -0,0 +1,206 @@
+package cbor
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// TypeEncoderFunc returns the CBOR encoding of v, a value of the type it's
+// registered for with SetTypeEncoder. The encoding must be exactly one
+// well-formed CBOR item.
+type TypeEncoderFunc func(v interface{}) ([]byte, error)
+
+// TypeDecoderFunc decodes the CBOR item in data into v, a pointer to a
+// value of the type it's registered for with SetTypeDecoder.
+type TypeDecoderFunc func(data []byte, v interface{}) error
+
+// TransformFunc returns the value to encode in place of v, such as a hash
+// or a redacted copy of it, for a struct field or a type it's registered
+// for with SetFieldTransform or SetTypeTransform. A nil result is encoded
+// as null.
+type TransformFunc func(v interface{}) (interface{}, error)
+
+// SetTypeEncoder registers f to encode values of type t, in place of the
+// encoding the encoder would otherwise use for them, including the
+// MarshalCBOR method of t. It allows types from other packages, such as
+// decimal types or protobuf timestamps, to be encoded without wrapper
+// types. A nil f removes the function registered for t.
+//
+// The function is registered on e only, and applies wherever a value of
+// type t is encoded, including struct fields, elements and map keys.
+func (e *Encoder) SetTypeEncoder(t reflect.Type, f TypeEncoderFunc) {
+	funcs := make(map[reflect.Type]TypeEncoderFunc, len(e.options.TypeEncoders)+1)
+	for k, v := range e.options.TypeEncoders {
+		funcs[k] = v
+	}
+	if f == nil {
+		delete(funcs, t)
+	} else {
+		funcs[t] = f
+	}
+	e.options.TypeEncoders = funcs
+}
+
+// SetTypeTransform registers f to transform values of type t before they
+// are encoded, wherever they are, so policies such as redacting personal
+// data can be enforced for a type in one place. The value returned by f
+// is encoded as usual, including with the function registered for its
+// type with SetTypeEncoder, but isn't transformed again if it's of type
+// t. A nil f removes the function registered for t.
+//
+// The function is registered on e only.
+func (e *Encoder) SetTypeTransform(t reflect.Type, f TransformFunc) {
+	funcs := make(map[reflect.Type]TransformFunc, len(e.options.TypeTransforms)+1)
+	for k, v := range e.options.TypeTransforms {
+		funcs[k] = v
+	}
+	if f == nil {
+		delete(funcs, t)
+	} else {
+		funcs[t] = f
+	}
+	e.options.TypeTransforms = funcs
+}
+
+// SetFieldTransform registers f as the transform with the given name,
+// which transforms the struct fields with the option transform=name in
+// their cbor tag before they are encoded, such as
+//
+//	type User struct {
+//		ID    int    `cbor:"id"`
+//		Email string `cbor:"email,transform=pii"`
+//	}
+//
+//	enc.SetFieldTransform("pii", func(v interface{}) (interface{}, error) {
+//		sum := sha256.Sum256([]byte(v.(string)))
+//		return sum[:], nil
+//	})
+//
+// Encoding a field whose transform isn't registered is an error, so data
+// meant to be transformed is never encoded as it is. A nil f removes the
+// transform with the name.
+//
+// The transform is registered on e only, and doesn't apply to decoding.
+func (e *Encoder) SetFieldTransform(name string, f TransformFunc) {
+	funcs := make(map[string]TransformFunc, len(e.options.FieldTransforms)+1)
+	for k, v := range e.options.FieldTransforms {
+		funcs[k] = v
+	}
+	if f == nil {
+		delete(funcs, name)
+	} else {
+		funcs[name] = f
+	}
+	e.options.FieldTransforms = funcs
+}
+
+// SetTypeDecoder registers f to decode items into values of type t, in
+// place of the decoding the decoder would otherwise use for them,
+// including the UnmarshalCBOR method of t. It allows types from other
+// packages, such as decimal types or protobuf timestamps, to be decoded
+// without wrapper types. A nil f removes the function registered for t.
+//
+// Unlike the limits, the function is registered on dec only, and not in
+// DefaultDecoderOptions. It applies wherever a value of type t is decoded,
+// including struct fields, elements and map keys, but not to interface
+// values, which are decoded as usual.
+func (dec *Decoder) SetTypeDecoder(t reflect.Type, f TypeDecoderFunc) {
+	options := dec.ownOptions()
+	funcs := make(map[reflect.Type]TypeDecoderFunc, len(options.TypeDecoders)+1)
+	for k, v := range options.TypeDecoders {
+		funcs[k] = v
+	}
+	if f == nil {
+		delete(funcs, t)
+	} else {
+		funcs[t] = f
+	}
+	options.TypeDecoders = funcs
+}
+
+// typeFuncEncoder wraps the encoderFunc f of t to use the functions
+// registered for t in the options of the Encoder, if any.
+func typeFuncEncoder(t reflect.Type, f encoderFunc) encoderFunc {
+	return func(e *Encoder, v reflect.Value) error {
+		if len(e.options.TypeEncoders) == 0 && len(e.options.TypeTransforms) == 0 || !v.CanInterface() {
+			return f(e, v)
+		}
+		if fn, ok := e.options.TypeTransforms[t]; ok {
+			r, err := fn(v.Interface())
+			if err != nil {
+				return fmt.Errorf("cbor: error calling transform for type %s: %w", t, err)
+			}
+			if r == nil {
+				return e.writeNull()
+			}
+			if v = reflect.ValueOf(r); v.Type() != t {
+				return encoderFor(v.Type())(e, v)
+			}
+		}
+		fn, ok := e.options.TypeEncoders[t]
+		if !ok {
+			return f(e, v)
+		}
+
+		b, err := fn(v.Interface())
+		if err != nil {
+			return fmt.Errorf("cbor: error calling type encoder for type %s: %w", t, err)
+		}
+		if n, err := itemLength(b, 0); err != nil || n != len(b) {
+			if err == nil {
+				err = errors.New("trailing data after item")
+			}
+			return fmt.Errorf("cbor: error calling type encoder for type %s: %w", t, err)
+		}
+		e.buf = append(e.buf, b...)
+		return nil
+	}
+}
+
+// transformEncoder wraps the encoderFunc f of a struct field with the
+// transform option, to encode the value returned by the transform with the
+// given name in place of the field.
+func transformEncoder(name string, f encoderFunc) encoderFunc {
+	return func(e *Encoder, v reflect.Value) error {
+		fn, ok := e.options.FieldTransforms[name]
+		if !ok {
+			return fmt.Errorf("cbor: transform %q is not registered", name)
+		}
+		r, err := fn(v.Interface())
+		if err != nil {
+			return fmt.Errorf("cbor: error calling transform %q: %w", name, err)
+		}
+		if r == nil {
+			return e.writeNull()
+		}
+		rv := reflect.ValueOf(r)
+		if rv.Type() == v.Type() {
+			return f(e, rv)
+		}
+		return encoderFor(rv.Type())(e, rv)
+	}
+}
+
+// typeFuncDecoder wraps the decoderFunc f of t to use the function
+// registered for t in the options of the Decoder, if any.
+func typeFuncDecoder(t reflect.Type, f decoderFunc) decoderFunc {
+	return func(dec *Decoder, rv reflect.Value, b byte) error {
+		if len(dec.options.TypeDecoders) == 0 || !rv.CanAddr() {
+			return f(dec, rv, b)
+		}
+		fn, ok := dec.options.TypeDecoders[t]
+		if !ok {
+			return f(dec, rv, b)
+		}
+
+		raw, err := dec.appendRawItem(nil, b, 0)
+		if err != nil {
+			return err
+		}
+		if err := fn(raw, rv.Addr().Interface()); err != nil {
+			return fmt.Errorf("cbor: error calling type decoder for type %s: %w", t, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,178 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+// money stands in for a type from another package, which can't be given
+// MarshalCBOR and UnmarshalCBOR methods.
+type money struct {
+	cents int64
+}
+
+var moneyType = reflect.TypeOf(money{})
+
+func encodeMoney(v interface{}) ([]byte, error) {
+	m := v.(money)
+	return cbor.Marshal(fmt.Sprintf("%d.%02d", m.cents/100, m.cents%100))
+}
+
+func decodeMoney(data []byte, v interface{}) error {
+	var s string
+	if err := cbor.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	var units, cents int64
+	if _, err := fmt.Sscanf(s, "%d.%d", &units, &cents); err != nil {
+		return err
+	}
+	v.(*money).cents = units*100 + cents
+	return nil
+}
+
+func TestTypeFuncs(t *testing.T) {
+	type order struct {
+		Total money
+		Items []money
+		Taxes map[money]string
+	}
+	v := order{
+		Total: money{1234},
+		Items: []money{{1000}, {234}},
+		Taxes: map[money]string{{50}: "vat"},
+	}
+
+	var buf bytes.Buffer
+	enc := cbor.NewEncoder(&buf)
+	enc.SetTypeEncoder(moneyType, encodeMoney)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if err := cbor.EncodeSlice(enc, []money{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	want := "a3" +
+		"65546f74616c" + "6531322e3334" +
+		"654974656d73" + "82" + "6531302e3030" + "64322e3334" +
+		"655461786573" + "a1" + "64302e3530" + "63766174" +
+		"81" + "64302e3031"
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	dec := cbor.NewDecoder(&buf)
+	dec.SetTypeDecoder(moneyType, decodeMoney)
+	var got order
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Fatalf("expected %+v, got %+v", v, got)
+	}
+
+	// The functions are only registered on the encoder and decoder.
+	if cbor.DefaultDecoderOptions.TypeDecoders != nil {
+		t.Fatal("expected no type decoders in DefaultDecoderOptions")
+	}
+	data, err := cbor.Marshal(money{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(data); got != "a0" {
+		t.Fatalf("expected a0, got %s", got)
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		enc := cbor.NewEncoder(&bytes.Buffer{})
+		enc.SetTypeEncoder(moneyType, func(interface{}) ([]byte, error) { return nil, errBoom })
+		if err := enc.Encode(money{}); !errors.Is(err, errBoom) {
+			t.Fatalf("expected error wrapping errBoom, got %v", err)
+		}
+		enc.SetTypeEncoder(moneyType, func(interface{}) ([]byte, error) { return []byte{0x01, 0x02}, nil })
+		if err := enc.Encode(money{}); err == nil {
+			t.Fatal("expected error for trailing data")
+		}
+
+		dec := cbor.NewDecoder(bytes.NewReader([]byte{0x01}))
+		dec.SetTypeDecoder(moneyType, func([]byte, interface{}) error { return errBoom })
+		var m money
+		if err := dec.Decode(&m); !errors.Is(err, errBoom) {
+			t.Fatalf("expected error wrapping errBoom, got %v", err)
+		}
+	})
+}
+
+func TestTransforms(t *testing.T) {
+	type contact struct {
+		Email string  `cbor:"email,transform=pii"`
+		Phone *string `cbor:"phone,omitempty,transform=pii"`
+		Tags  []money `cbor:"tags,transform=first"`
+		Note  string  `cbor:"note,transform=upper"`
+		Total money   `cbor:"total"`
+	}
+	redact := func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			return fmt.Sprintf("<%d bytes>", len(s)), nil
+		}
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	enc := cbor.NewEncoder(&buf)
+	enc.SetFieldTransform("pii", redact)
+	enc.SetFieldTransform("first", func(v interface{}) (interface{}, error) {
+		return v.([]money)[:1], nil
+	})
+	enc.SetFieldTransform("upper", func(v interface{}) (interface{}, error) {
+		return strings.ToUpper(v.(string)), nil
+	})
+	enc.SetTypeEncoder(moneyType, encodeMoney)
+	enc.SetTypeTransform(moneyType, func(v interface{}) (interface{}, error) {
+		// Amounts are rounded to whole units, and encoded with the type
+		// encoder of money since they have the same type.
+		m := v.(money)
+		return money{cents: m.cents / 100 * 100}, nil
+	})
+
+	phone := "555-0100"
+	v := contact{Email: "a@example.com", Phone: &phone, Tags: []money{{150}, {250}}, Note: "hi", Total: money{1234}}
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := cbor.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"email": "<13 bytes>",
+		"phone": nil,
+		"tags":  []interface{}{"1.00"},
+		"note":  "HI",
+		"total": "12.00",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// Fields with a transform which isn't registered aren't encoded.
+	enc = cbor.NewEncoder(&buf)
+	enc.SetFieldTransform("first", func(v interface{}) (interface{}, error) { return nil, nil })
+	enc.SetFieldTransform("upper", func(v interface{}) (interface{}, error) { return nil, errors.New("failed") })
+	if err := enc.Encode(v); err == nil || !strings.Contains(err.Error(), `transform "pii" is not registered`) {
+		t.Fatalf("expected an error for the missing transform, got %v", err)
+	}
+	enc.SetFieldTransform("pii", redact)
+	if err := enc.Encode(v); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Fatalf("expected the error of the transform, got %v", err)
+	}
+}
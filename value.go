@@ -0,0 +1,185 @@
+package cbor
+
+import "errors"
+
+// Value is a lazily decoded CBOR item, which wraps its encoded bytes.
+//
+// Navigating a Value with Get and Index only scans the bytes needed to
+// find the wanted item, without decoding the rest of the document, so it's
+// well suited to extracting a few fields from large messages. The item is
+// checked to be well-formed when the Value is created.
+//
+// Value implements Marshaler and Unmarshaler, so it can be used as a struct
+// field or map value to delay decoding part of a document, similar to
+// json.RawMessage.
+//
+// The zero Value contains no item.
+type Value struct {
+	raw []byte
+}
+
+// NewValue returns a Value wrapping the CBOR item at the start of data. Any
+// data after the item is ignored.
+//
+// The returned Value aliases data, which must not be modified while the
+// Value is in use.
+func NewValue(data []byte) (Value, error) {
+	n, err := itemLength(data, 0)
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{raw: data[:n:n]}, nil
+}
+
+// Raw returns the encoded bytes of the item, which must not be modified.
+func (v Value) Raw() []byte {
+	return v.raw
+}
+
+// Type returns the major type of the item. The major type of tagged items
+// is MajorTypeTag. It returns -1 for the zero Value.
+func (v Value) Type() MajorType {
+	if len(v.raw) == 0 {
+		return -1
+	}
+	return MajorType(v.raw[0] >> 5)
+}
+
+// Len returns the number of elements of an array, the number of key/value
+// pairs of a map, or the length in bytes of a byte or text string. It
+// returns 0 for other items.
+func (v Value) Len() int {
+	if len(v.raw) == 0 {
+		return 0
+	}
+
+	mt, ai, arg, _, _ := parseHeader(v.raw)
+	switch mt {
+	case MajorTypeByteString, MajorTypeTextString:
+		if ai == 31 {
+			s, _, _ := readStringBytes(v.raw, mt)
+			return len(s)
+		}
+		return int(arg)
+	case MajorTypeArray, MajorTypeMap:
+		if ai == 31 {
+			n := 0
+			v.each(func([]byte) bool {
+				n++
+				return true
+			})
+			if mt == MajorTypeMap {
+				n /= 2
+			}
+			return n
+		}
+		return int(arg)
+	default:
+		return 0
+	}
+}
+
+// Index returns the i'th element of an array. It reports false if the item
+// isn't an array or i is out of range.
+func (v Value) Index(i int) (Value, bool) {
+	if v.Type() != MajorTypeArray || i < 0 {
+		return Value{}, false
+	}
+
+	var elem Value
+	v.each(func(item []byte) bool {
+		if i == 0 {
+			elem.raw = item
+			return false
+		}
+		i--
+		return true
+	})
+	return elem, elem.raw != nil
+}
+
+// Get returns the value of the text string key in a map. It reports false
+// if the item isn't a map or doesn't contain the key.
+func (v Value) Get(key string) (Value, bool) {
+	if v.Type() != MajorTypeMap {
+		return Value{}, false
+	}
+
+	var (
+		val   Value
+		isKey = true
+		found bool
+	)
+	v.each(func(item []byte) bool {
+		if isKey {
+			found = textEqual(item, key)
+		} else if found {
+			val.raw = item
+			return false
+		}
+		isKey = !isKey
+		return true
+	})
+	return val, val.raw != nil
+}
+
+// Decode decodes the item into the value pointed to by into, like
+// Unmarshal.
+func (v Value) Decode(into interface{}) error {
+	if len(v.raw) == 0 {
+		return errors.New("cbor: Decode called on zero Value")
+	}
+	return Unmarshal(v.raw, into)
+}
+
+// MarshalCBOR implements Marshaler, returning the wrapped item. The zero
+// Value is encoded as null.
+func (v Value) MarshalCBOR() ([]byte, error) {
+	if len(v.raw) == 0 {
+		return []byte{0xf6}, nil
+	}
+	return v.raw, nil
+}
+
+// UnmarshalCBOR implements Unmarshaler, storing a copy of data.
+func (v *Value) UnmarshalCBOR(data []byte) error {
+	n, err := itemLength(data, 0)
+	if err != nil {
+		return err
+	}
+	v.raw = append([]byte{}, data[:n]...)
+	return nil
+}
+
+// each calls fn with the encoded bytes of each item in an array or map,
+// with map keys and values as separate items, until fn returns false.
+func (v Value) each(fn func(item []byte) bool) {
+	_, ai, arg, n, _ := parseHeader(v.raw)
+
+	count := arg
+	if v.Type() == MajorTypeMap {
+		count *= 2
+	}
+
+	b := v.raw[n:]
+	for i := uint64(0); ai == 31 || i < count; i++ {
+		if ai == 31 && b[0] == 0xff {
+			return
+		}
+		// The item was checked when the Value was created.
+		l, _ := itemLength(b, 0)
+		if !fn(b[:l:l]) {
+			return
+		}
+		b = b[l:]
+	}
+}
+
+// textEqual reports whether the encoded item is a text string equal to s.
+func textEqual(item []byte, s string) bool {
+	if MajorType(item[0]>>5) != MajorTypeTextString {
+		return false
+	}
+	b, _, err := readStringBytes(item, MajorTypeTextString)
+	return err == nil && string(b) == s
+}
@@ -0,0 +1,164 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/picatz/cbor"
+)
+
+func TestValue(t *testing.T) {
+	// {"a": 1, "b": [2, {"c": "d"}], "e": h'0102', 4: "four"}
+	data, err := hex.DecodeString("a461610161628202a16163616461654201020464666f7572")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := cbor.NewValue(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Type() != cbor.MajorTypeMap || v.Len() != 4 {
+		t.Fatalf("got %v with length %d", v.Type(), v.Len())
+	}
+
+	b, ok := v.Get("b")
+	if !ok {
+		t.Fatal("missing key b")
+	}
+	if b.Type() != cbor.MajorTypeArray || b.Len() != 2 {
+		t.Fatalf("got %v with length %d", b.Type(), b.Len())
+	}
+
+	elem, ok := b.Index(1)
+	if !ok {
+		t.Fatal("missing index 1")
+	}
+	c, ok := elem.Get("c")
+	if !ok {
+		t.Fatal("missing key c")
+	}
+	var s string
+	if err := c.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s != "d" {
+		t.Fatalf("got %q, want %q", s, "d")
+	}
+
+	e, ok := v.Get("e")
+	if !ok || e.Type() != cbor.MajorTypeByteString || e.Len() != 2 {
+		t.Fatalf("unexpected value for e: %x", e.Raw())
+	}
+
+	if _, ok := v.Get("four"); ok {
+		t.Fatal("found value for a map value")
+	}
+	if _, ok := v.Get("z"); ok {
+		t.Fatal("found missing key")
+	}
+	if _, ok := b.Index(2); ok {
+		t.Fatal("found out of range index")
+	}
+	if _, ok := v.Index(0); ok {
+		t.Fatal("indexed a map")
+	}
+}
+
+func TestValueIndefiniteLength(t *testing.T) {
+	// {_ "a": [_ 1, 2], (_ "b", "c"): "d"}
+	data, err := hex.DecodeString("bf61619f0102ff7f61626163ff6164ff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := cbor.NewValue(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Len() != 2 {
+		t.Fatalf("got length %d, want 2", v.Len())
+	}
+
+	a, ok := v.Get("a")
+	if !ok || a.Len() != 2 {
+		t.Fatalf("unexpected value for a: %x", a.Raw())
+	}
+	if two, ok := a.Index(1); !ok || hex.EncodeToString(two.Raw()) != "02" {
+		t.Fatalf("unexpected element: %x", two.Raw())
+	}
+
+	d, ok := v.Get("bc")
+	if !ok || hex.EncodeToString(d.Raw()) != "6164" {
+		t.Fatalf("unexpected value for bc: %x", d.Raw())
+	}
+}
+
+func TestValueErrors(t *testing.T) {
+	for _, in := range []string{"", "82", "a1", "ff", "7f61"} {
+		data, err := hex.DecodeString(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cbor.NewValue(data); err == nil {
+			t.Fatalf("expected error for %q", in)
+		}
+	}
+
+	var v cbor.Value
+	if v.Len() != 0 {
+		t.Fatal("zero Value has non-zero length")
+	}
+	if err := v.Decode(new(interface{})); err == nil {
+		t.Fatal("expected error decoding zero Value")
+	}
+}
+
+func TestValueUnmarshal(t *testing.T) {
+	// {"kind": "point", "data": [1, 2]}
+	data, err := hex.DecodeString("a2646b696e6465706f696e746464617461820102")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]cbor.Value
+	if err := cbor.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	var pt []int
+	if err := m["data"].Decode(&pt); err != nil {
+		t.Fatal(err)
+	}
+	if len(pt) != 2 || pt[0] != 1 || pt[1] != 2 {
+		t.Fatalf("got %v", pt)
+	}
+
+	b, err := cbor.Marshal(m["data"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(b); got != "820102" {
+		t.Fatalf("got %s, want 820102", got)
+	}
+}
+
+func BenchmarkValueGet(b *testing.B) {
+	data, err := hex.DecodeString("a461610161628202a16163616461654201020464666f7572")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	v, err := cbor.NewValue(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := v.Get("e"); !ok {
+			b.Fatal("missing key")
+		}
+	}
+}